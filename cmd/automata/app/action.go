@@ -0,0 +1,166 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/fsutil"
+	"github.com/shikanime-studio/automata/internal/notify"
+)
+
+// ActionInputs are the inputs of the GitHub Action, passed by the runner as
+// INPUT_* environment variables.
+type ActionInputs struct {
+	// Paths are the directories to update, "." by default.
+	Paths []string
+	// Strategies are the update commands to run, e.g. kustomization or
+	// githubworkflow, "all" by default.
+	Strategies []string
+	// Token overrides the GitHub token from config.
+	Token string
+	// DryRun runs the updates on a copy of the workspace, leaving it
+	// untouched.
+	DryRun bool
+	// ReportPath is the file the applied updates are written to as JSON.
+	ReportPath string
+}
+
+// ReadActionInputs reads the action inputs with getenv. Lists are separated
+// by newlines or commas and booleans follow the YAML 1.2 core schema, like
+// the inputs of JavaScript actions.
+func ReadActionInputs(getenv func(string) string) (ActionInputs, error) {
+	input := func(name string) string {
+		return strings.TrimSpace(getenv("INPUT_" + strings.ToUpper(strings.ReplaceAll(name, " ", "_"))))
+	}
+	in := ActionInputs{
+		Paths:      actionList(input("paths")),
+		Strategies: actionList(input("strategies")),
+		Token:      input("token"),
+		ReportPath: input("report-path"),
+	}
+	switch v := input("dry-run"); v {
+	case "", "false", "False", "FALSE":
+	case "true", "True", "TRUE":
+		in.DryRun = true
+	default:
+		return ActionInputs{}, fmt.Errorf("input dry-run: %q is not a boolean", v)
+	}
+	if len(in.Paths) == 0 {
+		in.Paths = []string{"."}
+	}
+	if len(in.Strategies) == 0 {
+		in.Strategies = []string{"all"}
+	}
+	if in.ReportPath == "" {
+		in.ReportPath = filepath.Join(getenv("RUNNER_TEMP"), "automata-report.json")
+	}
+	return in, nil
+}
+
+// actionList splits a list input on newlines and commas.
+func actionList(s string) []string {
+	var items []string
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == ',' }) {
+		if f = strings.TrimSpace(f); f != "" {
+			items = append(items, f)
+		}
+	}
+	return items
+}
+
+// NewActionCmd creates the "action" command run by the GitHub Action. It
+// reads its inputs from INPUT_* environment variables, runs the selected
+// update commands and sets the updated and report-path outputs through
+// GITHUB_OUTPUT from the report of the run.
+func NewActionCmd(cfg *config.Config, report func() notify.Report) *cobra.Command {
+	return &cobra.Command{
+		Use:   "action",
+		Short: "Run as a GitHub Action",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			in, err := ReadActionInputs(os.Getenv)
+			if err != nil {
+				return err
+			}
+			if in.Token != "" {
+				cfg.SetGitHubToken(in.Token)
+			}
+			if err := runAction(cmd, in); err != nil {
+				return err
+			}
+			r := report()
+			if err := notify.WriteEvents(in.ReportPath, r.Updates); err != nil {
+				return err
+			}
+			return setActionOutputs(os.Getenv("GITHUB_OUTPUT"), map[string]string{
+				"updated":     strconv.FormatBool(len(r.Updates) > 0),
+				"report-path": in.ReportPath,
+			})
+		},
+	}
+}
+
+// runAction runs the update commands of the inputs over their paths, on a
+// copy of the working directory for dry runs.
+func runAction(cmd *cobra.Command, in ActionInputs) error {
+	paths := in.Paths
+	if in.DryRun {
+		dir, err := os.MkdirTemp("", "automata-action-")
+		if err != nil {
+			return fmt.Errorf("create temp dir: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		if err := fsutil.CopyDir(".", dir); err != nil {
+			return fmt.Errorf("copy workspace: %w", err)
+		}
+		paths = make([]string, 0, len(in.Paths))
+		for _, p := range in.Paths {
+			if !filepath.IsLocal(p) {
+				return fmt.Errorf("dry-run path %s is outside the workspace", p)
+			}
+			paths = append(paths, filepath.Join(dir, p))
+		}
+	}
+	for _, s := range in.Strategies {
+		sub, _, err := cmd.Root().Find([]string{"update", s})
+		if err != nil || sub.Name() != s || sub.RunE == nil {
+			return fmt.Errorf("unknown strategy %q", s)
+		}
+		sub.SetContext(withDryRun(cmd.Context(), in.DryRun))
+		if err := sub.RunE(sub, paths); err != nil {
+			return fmt.Errorf("%s: %w", s, err)
+		}
+	}
+	return nil
+}
+
+// setActionOutputs appends the outputs to the GITHUB_OUTPUT file, or does
+// nothing outside GitHub Actions.
+func setActionOutputs(file string, outputs map[string]string) error {
+	if file == "" {
+		return nil
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", file, err)
+	}
+	defer func() { _ = f.Close() }()
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := fmt.Fprintf(f, "%s=%s\n", name, outputs[name]); err != nil {
+			return fmt.Errorf("write %s: %w", file, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"context"
+	"strings"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/helm"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+)
+
+// runCheckAppVersions cross-checks the images pinned under dirs against the
+// application versions of the charts bumped during the run, as declared under
+// app-versions in the config file.
+func runCheckAppVersions(
+	ctx context.Context,
+	cfg *config.Config,
+	bumps *ikio.ChartBumps,
+	dirs []string,
+) error {
+	decls, err := cfg.AppVersions()
+	if err != nil {
+		return err
+	}
+	pins, err := ikio.ResolveAppVersionPins(ctx, decls, bumps.Charts(), helm.AppVersion)
+	if err != nil {
+		return err
+	}
+	if len(pins) == 0 {
+		return nil
+	}
+	for _, d := range dirs {
+		r := strings.TrimSpace(d)
+		if r == "" {
+			continue
+		}
+		if err := ikio.CheckAppVersions(ctx, pins, r).Execute(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
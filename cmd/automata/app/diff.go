@@ -0,0 +1,62 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shikanime-studio/automata/internal/fsutil"
+	"github.com/shikanime-studio/automata/internal/git"
+	"github.com/shikanime-studio/automata/internal/inventory"
+)
+
+// NewDiffCmd creates the "diff" command that prints the version-level
+// differences between the dependencies of two trees. Each tree is a directory
+// or a git ref of the repository in the current directory; with a single
+// argument, the ref is compared against the working tree.
+func NewDiffCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff (REF|DIR) [REF|DIR]",
+		Short: "Compare the dependencies of two trees or git refs",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				args = append(args, ".")
+			}
+			from, err := collectTree(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			to, err := collectTree(cmd.Context(), args[1])
+			if err != nil {
+				return err
+			}
+			for _, c := range inventory.Diff(from, to) {
+				if _, err := fmt.Fprintln(cmd.OutOrStdout(), c); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// collectTree lists the dependencies of a directory or git ref from a
+// disposable copy, leaving the original tree untouched.
+func collectTree(ctx context.Context, tree string) ([]inventory.Dependency, error) {
+	dir, err := os.MkdirTemp("", "automata-diff-")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	if info, err := os.Stat(tree); err == nil && info.IsDir() {
+		if err := fsutil.CopyDir(tree, dir); err != nil {
+			return nil, fmt.Errorf("copy %s: %w", tree, err)
+		}
+	} else if err := (&git.Repo{Dir: "."}).Export(ctx, tree, dir); err != nil {
+		return nil, err
+	}
+	return inventory.Collect(ctx, dir)
+}
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/shikanime-studio/automata/internal/buildinfo"
+)
+
+// NewDocsCmd creates the hidden "docs" command generating documentation of
+// the command tree for packagers.
+func NewDocsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:    "docs",
+		Short:  "Generate documentation",
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(NewDocsManCmd())
+	return cmd
+}
+
+// NewDocsManCmd creates the "docs man" command writing a section 1 man page
+// per command to DIR. Pages are dated from SOURCE_DATE_EPOCH when set, so
+// packaged builds are reproducible.
+func NewDocsManCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "man [DIR]",
+		Short: "Generate man pages",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := "."
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return fmt.Errorf("create %s: %w", dir, err)
+			}
+			date, err := sourceDate()
+			if err != nil {
+				return err
+			}
+			return writeManPages(cmd.Root(), dir, date)
+		},
+	}
+}
+
+// sourceDate returns the date of SOURCE_DATE_EPOCH, or the current date.
+func sourceDate() (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Now().UTC(), nil
+	}
+	sec, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %w", epoch, err)
+	}
+	return time.Unix(sec, 0).UTC(), nil
+}
+
+// writeManPages writes the man page of cmd and of its available subcommands.
+func writeManPages(cmd *cobra.Command, dir string, date time.Time) error {
+	for _, c := range cmd.Commands() {
+		if !c.IsAvailableCommand() || c.IsAdditionalHelpTopicCommand() {
+			continue
+		}
+		if err := writeManPages(c, dir, date); err != nil {
+			return err
+		}
+	}
+	path := filepath.Join(dir, manName(cmd)+".1")
+	if err := os.WriteFile(path, manPage(cmd, date), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
+
+// manName returns the page name of a command, e.g. automata-update-all.
+func manName(cmd *cobra.Command) string {
+	return strings.ReplaceAll(cmd.CommandPath(), " ", "-")
+}
+
+// manPage renders the roff man page of a command.
+func manPage(cmd *cobra.Command, date time.Time) []byte {
+	var b bytes.Buffer
+	name := manName(cmd)
+	fmt.Fprintf(&b, ".TH \"%s\" \"1\" \"%s\" \"automata %s\" \"Automata Manual\"\n",
+		strings.ToUpper(name), date.Format("Jan 2006"), buildinfo.Get().Version)
+	fmt.Fprintf(&b, ".SH NAME\n%s \\- %s\n", name, roff(cmd.Short))
+	fmt.Fprintf(&b, ".SH SYNOPSIS\n\\fB%s\\fP\n", roff(cmd.UseLine()))
+	desc := cmd.Long
+	if desc == "" {
+		desc = cmd.Short
+	}
+	fmt.Fprintf(&b, ".SH DESCRIPTION\n%s\n", roff(desc))
+	manFlags(&b, "OPTIONS", cmd.NonInheritedFlags())
+	manFlags(&b, "OPTIONS INHERITED FROM PARENT COMMANDS", cmd.InheritedFlags())
+	var related []string
+	if cmd.HasParent() {
+		related = append(related, fmt.Sprintf("\\fB%s\\fP(1)", manName(cmd.Parent())))
+	}
+	for _, c := range cmd.Commands() {
+		if c.IsAvailableCommand() && !c.IsAdditionalHelpTopicCommand() {
+			related = append(related, fmt.Sprintf("\\fB%s\\fP(1)", manName(c)))
+		}
+	}
+	if len(related) > 0 {
+		fmt.Fprintf(&b, ".SH SEE ALSO\n%s\n", strings.Join(related, ", "))
+	}
+	return b.Bytes()
+}
+
+// manFlags renders the visible flags of a set as a tagged paragraph list.
+func manFlags(b *bytes.Buffer, section string, flags *pflag.FlagSet) {
+	var items []string
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Hidden {
+			return
+		}
+		name := "\\-\\-" + roff(f.Name)
+		if f.Shorthand != "" {
+			name = "\\-" + f.Shorthand + ", " + name
+		}
+		if t := f.Value.Type(); t != "bool" {
+			name += " " + t
+		}
+		usage := f.Usage
+		if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "[]" {
+			usage += fmt.Sprintf(" (default %s)", f.DefValue)
+		}
+		items = append(items, fmt.Sprintf(".TP\n\\fB%s\\fP\n%s\n", name, roff(usage)))
+	})
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(b, ".SH %s\n%s", section, strings.Join(items, ""))
+}
+
+// roff escapes text for roff, keeping lines from starting with a control
+// character.
+func roff(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\e")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = "\\&" + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
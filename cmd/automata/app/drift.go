@@ -0,0 +1,58 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shikanime-studio/automata/internal/drift"
+)
+
+// NewDriftCmd creates the "drift" command comparing the image tags declared
+// by the kustomizations under a directory with the images running in a
+// cluster, printing each container running a tag no kustomization of its
+// namespace declares.
+func NewDriftCmd() *cobra.Command {
+	var (
+		kf        *kubeFlags
+		namespace string
+		selector  string
+	)
+	cmd := &cobra.Command{
+		Use:   "drift [DIR]",
+		Short: "Compare kustomization image tags with the images running in a cluster",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := "."
+			if len(args) > 0 {
+				root = args[0]
+			}
+			declared, err := drift.ReadKustomizations(root)
+			if err != nil {
+				return err
+			}
+			c, err := kf.client()
+			if err != nil {
+				return err
+			}
+			workloads, err := c.ListWorkloads(cmd.Context(), namespace, selector)
+			if err != nil {
+				return err
+			}
+			drifts := drift.Detect(declared, workloads)
+			for _, d := range drifts {
+				if _, err := fmt.Fprintln(cmd.OutOrStdout(), d); err != nil {
+					return err
+				}
+			}
+			if len(drifts) > 0 {
+				return fmt.Errorf("found %d drifted containers", len(drifts))
+			}
+			return nil
+		},
+	}
+	kf = addKubeFlags(cmd)
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "only compare the workloads of a namespace")
+	cmd.Flags().StringVarP(&selector, "selector", "l", "", "label selector of the workloads")
+	return cmd
+}
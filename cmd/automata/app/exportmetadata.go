@@ -0,0 +1,96 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/fsutil"
+	"github.com/shikanime-studio/automata/internal/git"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/helm"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/mirror"
+	"github.com/shikanime-studio/automata/internal/policy"
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+// lookupOnly runs the lookups of an updater, so they are recorded in the
+// metadata snapshot, without updating anything.
+type lookupOnly[T any] struct {
+	u updater.Updater[T]
+}
+
+// Update looks up the latest version and reports no update.
+func (l lookupOnly[T]) Update(ctx context.Context, v T, opts ...updater.Option) (string, error) {
+	_, err := l.u.Update(ctx, v, opts...)
+	return "", err
+}
+
+// NewExportMetadataCmd creates the "export-metadata" command run on a
+// connected machine: it looks up the versions of every dependency the
+// updates of the directories track, on disposable copies, and writes them to
+// a snapshot that --offline serves in air-gapped networks.
+func NewExportMetadataCmd(cfg *config.Config) *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "export-metadata [DIR...]",
+		Short: "Export the versions of dependencies for offline updates",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				args = []string{"."}
+			}
+			snap := mirror.NewSnapshot(time.Now().UTC())
+			ctx := mirror.WithRecorder(policy.WithEngine(cmd.Context(), nil), snap)
+			sources := newRuleSources(ctx, cfg)
+			sources = ikio.RuleSources{
+				Image:  lookupOnly[*container.ImageRef]{sources.Image},
+				GitHub: lookupOnly[*github.ActionRef]{sources.GitHub},
+				Helm:   lookupOnly[*helm.ChartRef]{sources.Helm},
+				Git:    lookupOnly[*git.RepoRef]{sources.Git},
+				Plugin: sources.Plugin,
+			}
+			tree, err := newTreeConfig(cfg, sources)
+			if err != nil {
+				return err
+			}
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				if err := exportTree(ctx, tree, r); err != nil {
+					return err
+				}
+			}
+			return snap.Write(output)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", mirror.SnapshotFile, "file the snapshot is written to")
+	return cmd
+}
+
+// exportTree runs the update pipelines over a disposable copy of a directory
+// with lookup-only updaters.
+func exportTree(ctx context.Context, c ikio.TreeConfig, tree string) error {
+	dir, err := os.MkdirTemp("", "automata-export-")
+	if err != nil {
+		return fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := fsutil.CopyDir(tree, dir); err != nil {
+		return fmt.Errorf("copy %s: %w", tree, err)
+	}
+	for _, p := range ikio.TreePipelines(ctx, c, dir) {
+		if err := p.Execute(); err != nil {
+			return fmt.Errorf("export %s: %w", tree, err)
+		}
+	}
+	return nil
+}
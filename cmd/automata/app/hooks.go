@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	"github.com/shikanime-studio/automata/internal/timeout"
+)
+
+// addHooks wraps the subcommands of cmd to run the hooks declared in the
+// config file around them. Those of updates of a remote repository run in
+// its clone, once it exists, by runRemoteHooks.
+func addHooks(cfg *config.Config, cmd *cobra.Command) {
+	for _, c := range cmd.Commands() {
+		if c.RunE == nil {
+			continue
+		}
+		run := c.RunE
+		c.RunE = func(cmd *cobra.Command, args []string) error {
+			hooks, err := cfg.Hooks()
+			if err != nil {
+				return err
+			}
+			ctx := policy.WithApplied(cmd.Context(), policy.NewApplied())
+			if repo := cmd.Flags().Lookup("repo"); repo != nil && repo.Value.String() != "" {
+				cmd.SetContext(context.WithValue(ctx, remoteHooksKey{}, remoteHooks{hooks, cmd.Name()}))
+				return run(cmd, args)
+			}
+			cmd.SetContext(ctx)
+			return runWithHooks(ctx, hooks, cmd.Name(), updateRoot(args), args, func() error {
+				return run(cmd, args)
+			})
+		}
+	}
+}
+
+type remoteHooksKey struct{}
+
+// remoteHooks are the hooks of a command updating a remote repository.
+type remoteHooks struct {
+	hooks config.Hooks
+	name  string
+}
+
+// runRemoteHooks runs the update of the clone at root, updating dirs, with
+// the hooks of the command of the context around it.
+func runRemoteHooks(ctx context.Context, root string, dirs []string, update func() error) error {
+	h, ok := ctx.Value(remoteHooksKey{}).(remoteHooks)
+	if !ok {
+		return update()
+	}
+	return runWithHooks(ctx, h.hooks, h.name, root, dirs, update)
+}
+
+// runWithHooks runs the pre-update hooks, the update and the post-update
+// hooks, stopping at the first failure, then the on-failure hooks when one
+// happened. Failing on-failure hooks are only logged. Hooks run from root,
+// the repository being updated.
+func runWithHooks(
+	ctx context.Context,
+	hooks config.Hooks,
+	name, root string,
+	args []string,
+	update func() error,
+) error {
+	env := []string{
+		"AUTOMATA_COMMAND=" + name,
+		"AUTOMATA_PATHS=" + strings.Join(args, "\n"),
+		"AUTOMATA_ROOT=" + root,
+	}
+	err := runHooks(ctx, "pre-update", hooks.PreUpdate, root, env)
+	if err == nil {
+		err = update()
+	}
+	if err == nil {
+		err = runHooks(ctx, "post-update", hooks.PostUpdate, root, env)
+	}
+	if err == nil {
+		return nil
+	}
+	env = append(env, "AUTOMATA_ERROR="+err.Error())
+	if hookErr := runHooks(ctx, "on-failure", hooks.OnFailure, root, env); hookErr != nil {
+		slog.ErrorContext(ctx, "on-failure hook failed", logging.Failed.Attr(), "err", hookErr)
+	}
+	return err
+}
+
+// runHooks runs the commands of a hook one after the other from dir,
+// stopping at the first failure. Commands see the run environment of runEnv
+// on top of env.
+func runHooks(ctx context.Context, hook string, commands []string, dir string, env []string) error {
+	for _, command := range commands {
+		slog.InfoContext(ctx, "running hook", "hook", hook, "command", command, "dir", dir)
+		hctx, cancel := timeout.Context(ctx, timeout.Script)
+		cmd := exec.CommandContext(hctx, "sh", "-c", command)
+		cmd.Dir = dir
+		cmd.Env = append(append(os.Environ(), runEnv(ctx)...), env...)
+		out, err := cmd.CombinedOutput()
+		cancel()
+		if len(out) > 0 {
+			slog.InfoContext(ctx, "hook output", "hook", hook, "command", command, "output", string(out))
+		}
+		if err != nil {
+			return fmt.Errorf("%s hook %q: %w", hook, command, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/scaffold"
+)
+
+// NewInitCmd creates the "init" command that inspects a repository for the
+// formats automata updates and writes a starter .automata.yaml at its root,
+// printing example images annotations for its kustomizations, or adding them
+// with --annotate.
+func NewInitCmd() *cobra.Command {
+	var force, annotate bool
+	cmd := &cobra.Command{
+		Use:   "init [DIR]",
+		Short: "Generate a starter configuration for a repository",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := "."
+			if len(args) > 0 {
+				root = args[0]
+			}
+			d, err := scaffold.Detect(root)
+			if err != nil {
+				return err
+			}
+			if d.Empty() {
+				return fmt.Errorf("no kustomization, workflow, flake or compose file found in %s", root)
+			}
+			path := filepath.Join(root, config.Files[0])
+			if existing := config.Find(root); existing != "" && existing != path && !force {
+				return fmt.Errorf("%s: %w", existing, scaffold.ErrExists)
+			}
+			if err := scaffold.Write(path, d, force); err != nil {
+				return err
+			}
+			annotations, err := scaffold.Annotations(cmd.Context(), root, annotate)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "detected %d kustomizations, %d workflows, %d flakes and %d compose files\n",
+				len(d.Kustomizations), len(d.Workflows), len(d.Flakes), len(d.ComposeFiles))
+			fmt.Fprintf(out, "wrote %s\n", path)
+			files := make([]string, 0, len(annotations))
+			for f := range annotations {
+				files = append(files, f)
+			}
+			sort.Strings(files)
+			for _, f := range files {
+				if annotate {
+					fmt.Fprintf(out, "annotated %s\n", f)
+					continue
+				}
+				fmt.Fprintf(out, "\n%s:\nmetadata:\n  annotations:\n    automata.shikanime.studio/images: '%s'\n",
+					f, strings.ReplaceAll(annotations[f], "'", "''"))
+			}
+			fmt.Fprintf(out, "\nupdate with:\n  %s\n", strings.Join(d.Commands(), "\n  "))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&force, "force", false, "overwrite an existing config file")
+	cmd.Flags().BoolVar(&annotate, "annotate", false, "add the example images annotations to kustomizations")
+	return cmd
+}
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/shikanime-studio/automata/internal/kube"
+)
+
+// kubeFlags selects the cluster a command talks to.
+type kubeFlags struct {
+	kubeconfig string
+	context    string
+}
+
+// addKubeFlags registers the --kubeconfig and --context flags on cmd.
+func addKubeFlags(cmd *cobra.Command) *kubeFlags {
+	f := &kubeFlags{}
+	cmd.Flags().StringVar(&f.kubeconfig, "kubeconfig", "", "path to the kubeconfig file, in-cluster config when unset")
+	cmd.Flags().StringVar(&f.context, "context", "", "kubeconfig context to use")
+	return f
+}
+
+// client creates a client of the selected cluster.
+func (f *kubeFlags) client() (*kube.Client, error) {
+	cfg, err := kube.Load(f.kubeconfig, f.context)
+	if err != nil {
+		return nil, err
+	}
+	return kube.NewClient(cfg)
+}
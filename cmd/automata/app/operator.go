@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/operator"
+)
+
+// NewOperatorCmd creates the "operator" command running automata in a
+// cluster: it reconciles DependencyUpdatePolicy resources by cloning the
+// repository of their Flux or Argo CD source, running every update operation
+// and pushing the result, on the interval of each policy.
+func NewOperatorCmd(cfg *config.Config) *cobra.Command {
+	var (
+		kf        *kubeFlags
+		namespace string
+		resync    time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "operator",
+		Short: "Reconcile DependencyUpdatePolicy resources in a cluster",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, err := kf.client()
+			if err != nil {
+				return err
+			}
+			update := func(ctx context.Context, t operator.Target) error {
+				return runUpdateRemote(ctx, cfg, remoteOptions{
+					url:         t.URL,
+					branch:      t.Branch,
+					message:     t.Message,
+					pullRequest: t.PullRequest,
+				}, t.Paths)
+			}
+			return operator.New(c, update, namespace).Run(cmd.Context(), resync)
+		},
+	}
+	kf = addKubeFlags(cmd)
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "only reconcile the policies of a namespace")
+	cmd.Flags().DurationVar(&resync, "resync", time.Minute, "how often policies are checked for due updates")
+	cmd.AddCommand(&cobra.Command{
+		Use:   "crd",
+		Short: "Print the DependencyUpdatePolicy CustomResourceDefinition",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			_, err := cmd.OutOrStdout().Write(operator.CRD)
+			return err
+		},
+	})
+	return cmd
+}
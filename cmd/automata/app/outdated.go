@@ -0,0 +1,71 @@
+package app
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+)
+
+// NewOutdatedCmd creates the "outdated" command listing the containers of
+// the live workloads of a cluster whose image tag is behind the latest one
+// of its registry. It is the main command of the kubectl plugin.
+func NewOutdatedCmd() *cobra.Command {
+	var (
+		kf            *kubeFlags
+		namespace     string
+		allNamespaces bool
+		selector      string
+	)
+	cmd := &cobra.Command{
+		Use:   "outdated",
+		Short: "List the outdated images running in a cluster",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			c, err := kf.client()
+			if err != nil {
+				return err
+			}
+			switch {
+			case allNamespaces:
+				namespace = ""
+			case namespace == "":
+				namespace = c.Namespace()
+			}
+			workloads, err := c.ListWorkloads(ctx, namespace, selector)
+			if err != nil {
+				return err
+			}
+			resolve := ikio.ResolveImage(container.NewUpdater())
+			latest := map[string]string{}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "NAMESPACE\tWORKLOAD\tCONTAINER\tIMAGE\tLATEST")
+			for _, wl := range workloads {
+				for _, ct := range wl.Containers {
+					image, ok := latest[ct.Image]
+					if !ok {
+						if image, err = resolve.Resolve(ctx, ct.Image); err != nil {
+							return fmt.Errorf("%s: container %s: %w", wl, ct.Name, err)
+						}
+						latest[ct.Image] = image
+					}
+					if image == "" {
+						continue
+					}
+					fmt.Fprintf(w, "%s\t%s/%s\t%s\t%s\t%s\n",
+						wl.Namespace, wl.Kind, wl.Name, ct.Name, ct.Image, image)
+				}
+			}
+			return w.Flush()
+		},
+	}
+	kf = addKubeFlags(cmd)
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "namespace of the workloads, the one of the context by default")
+	cmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "list the workloads of all namespaces")
+	cmd.Flags().StringVarP(&selector, "selector", "l", "", "label selector of the workloads")
+	return cmd
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shikanime-studio/automata/internal/plugin"
+)
+
+// NewPluginCmd creates the "plugin" command inspecting the plugins found on
+// PATH.
+func NewPluginCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plugin",
+		Short: "Inspect source and format plugins",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the plugins found on PATH",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			for _, p := range plugin.Discover() {
+				if _, err := fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s\n", p.Kind, p.Name, p.Path); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	return cmd
+}
+
+// addFormatPlugins adds a subcommand to the update command for each format
+// plugin on PATH, unless a built-in subcommand has its name.
+func addFormatPlugins(cmd *cobra.Command) {
+	for _, p := range plugin.Discover() {
+		if p.Kind != "format" {
+			continue
+		}
+		if c, _, err := cmd.Find([]string{p.Name}); err == nil && c != cmd {
+			continue
+		}
+		cmd.AddCommand(&cobra.Command{
+			Use:                p.Name + " [DIR...]",
+			Short:              "Run the " + p.Name + " format plugin",
+			DisableFlagParsing: true,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return plugin.RunFormat(cmd.Context(), p.Path, args)
+			},
+		})
+	}
+}
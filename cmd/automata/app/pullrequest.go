@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/policy"
+	"github.com/shikanime-studio/automata/internal/releasenotes"
+)
+
+// defaultBody introduces the body of pull requests without a body template.
+const defaultBody = "Dependency updates generated by automata.\n"
+
+// majorSummary summarizes the major bumps among the applied updates for the
+// pull request publishing them, and returns the label rating its risk. Each
+// major bump is summarized from the release notes between both versions when
+// its dependency is hosted on GitHub, and rated medium risk otherwise.
+// Without major bumps, the summary is empty and no label is set.
+func majorSummary(ctx context.Context, gc *github.Client, applied []policy.Proposal) (string, []string) {
+	var b strings.Builder
+	var risk releasenotes.Risk
+	for _, p := range applied {
+		if !policy.IsMajor(p.From, p.To) {
+			continue
+		}
+		if risk == "" {
+			b.WriteString("\n## Major Upgrades\n")
+		}
+		f, found := majorFindings(ctx, gc, p)
+		risk = releasenotes.Max(risk, releasenotes.Assess(f, found))
+		b.WriteString("\n" + releasenotes.Summary(p.Name, p.From, p.To, f, found))
+	}
+	if risk == "" {
+		return "", nil
+	}
+	return b.String(), []string{risk.Label()}
+}
+
+// majorFindings scans the release notes of a major bump, reporting whether
+// any were found.
+func majorFindings(ctx context.Context, gc *github.Client, p policy.Proposal) (releasenotes.Findings, bool) {
+	var f releasenotes.Findings
+	url := p.Name
+	if p.Source == "github" {
+		url = "https://github.com/" + p.Name
+	}
+	owner, repo, err := github.ParseRepoURL(url)
+	if err != nil {
+		return f, false
+	}
+	notes, err := gc.ReleaseNotes(ctx, owner, repo, p.From, p.To)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to read release notes", "name", p.Name, "err", err)
+		return f, false
+	}
+	for _, n := range notes {
+		f = f.Merge(releasenotes.Scan(n))
+	}
+	return f, len(notes) > 0
+}
+
+// autoMerges reports whether the pull request publishing the applied updates
+// merges automatically: auto-merge must be enabled and every update must be
+// of an allowed type. Major bumps and versions that are not semantic versions
+// always stay manual.
+func autoMerges(am config.AutoMerge, applied []policy.Proposal) bool {
+	if am.Method == "" || len(applied) == 0 {
+		return false
+	}
+	for _, p := range applied {
+		t := policy.UpdateType(p.From, p.To)
+		if t == "" || t == "major" || !slices.Contains(am.Updates, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// attributable reports whether the changes of a run in the clone at root
+// are all attributable to the recorded updates, so the pull request may
+// merge automatically: every changed file must hold a recorded update, or
+// be the changelog, and update scripts must have changed nothing.
+func attributable(ctx context.Context, cfg *config.Config, root string, changed, recorded []string, scripted bool) bool {
+	if scripted {
+		slog.InfoContext(ctx, "update scripts changed files, not auto-merging")
+		return false
+	}
+	known := make(map[string]bool, len(recorded)+1)
+	for _, f := range recorded {
+		known[filepath.Clean(f)] = true
+	}
+	if file := cfg.Changelog(); file != "" {
+		known[filepath.Join(root, file)] = true
+	}
+	for _, f := range changed {
+		if !known[filepath.Join(root, f)] {
+			slog.InfoContext(ctx, "unrecorded change, not auto-merging", "file", f)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/endoflife"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/notify"
+	"github.com/shikanime-studio/automata/internal/policy"
+)
+
+// Run holds what the commands updating dependencies need beyond the config:
+// the major-version hold of their proposals and the notifiers, dashboard and
+// end-of-life checker publishing their report. Its zero value publishes
+// nothing.
+type Run struct {
+	Hold      config.MajorHold
+	Notifiers []notify.Notifier
+	Dashboard *notify.Dashboard
+	EndOfLife *endoflife.Checker
+}
+
+// NewRun builds the Run of the config and returns a context applying its
+// policies, validation and k0s platforms. It is only called by the
+// commands updating dependencies, so the others still run on a config it
+// cannot be built from. Every section is built and their errors are joined.
+func NewRun(ctx context.Context, cfg *config.Config) (context.Context, Run, error) {
+	var (
+		r    Run
+		errs []error
+		err  error
+	)
+	wrap := func(section string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", section, err))
+		}
+	}
+	r.Notifiers, err = newNotifiers(cfg)
+	wrap("notifications", err)
+	r.Hold, err = cfg.MajorHold()
+	wrap("hold-majors", err)
+	engine, err := newPolicyEngine(cfg, r.Hold)
+	wrap("policies", err)
+	r.Dashboard, err = newDashboard(cfg)
+	wrap("dashboard", err)
+	r.EndOfLife, err = newEndOfLifeChecker(cfg)
+	wrap("endoflife", err)
+	validation, err := cfg.Validation()
+	wrap("validate", err)
+	platforms, err := cfg.K0sPlatforms()
+	wrap("k0s-platforms", err)
+	if len(errs) > 0 {
+		return ctx, Run{}, errors.Join(errs...)
+	}
+	ctx = policy.WithEngine(ctx, engine)
+	ctx = kio.WithValidation(ctx, validation)
+	ctx = kio.WithK0sPlatforms(ctx, platforms)
+	return ctx, r, nil
+}
+
+// newNotifiers builds the notifiers declared in the configuration.
+func newNotifiers(cfg *config.Config) ([]notify.Notifier, error) {
+	decls, err := cfg.Notifications()
+	if err != nil {
+		return nil, err
+	}
+	return notify.NewAll(decls)
+}
+
+// newPolicyEngine compiles the policies, update schedule, major-version hold
+// and Kubernetes version skew declared in the configuration.
+func newPolicyEngine(cfg *config.Config, hold config.MajorHold) (*policy.Engine, error) {
+	decls, err := cfg.Policies()
+	if err != nil {
+		return nil, err
+	}
+	sc, err := cfg.Schedule()
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := policy.NewSchedule(sc)
+	if err != nil {
+		return nil, err
+	}
+	opts := []policy.Option{policy.WithSchedule(schedule)}
+	if hold.Enabled {
+		opts = append(opts, policy.WithMajorHold(hold.Allow))
+	}
+	k, err := cfg.Kubernetes()
+	if err != nil {
+		return nil, err
+	}
+	if k.Version == "" && k.Cluster != "" {
+		if k.Version, err = kio.ReadK0sctlKubernetesVersion(k.Cluster); err != nil {
+			return nil, err
+		}
+	}
+	if k.Version != "" {
+		opts = append(opts, policy.WithKubernetesSkew(k.Version, k.Components))
+	}
+	return policy.New(decls, opts...)
+}
+
+// newDashboard creates the dependency dashboard declared in the
+// configuration, or returns nil when none is.
+func newDashboard(cfg *config.Config) (*notify.Dashboard, error) {
+	d, err := cfg.Dashboard()
+	if err != nil {
+		return nil, err
+	}
+	if d.Repository == "" {
+		return nil, nil
+	}
+	return notify.NewDashboard(github.NewClient(context.Background(), cfg), d)
+}
+
+// newEndOfLifeChecker creates the end-of-life checker of the products
+// declared in the configuration, or returns nil when none are.
+func newEndOfLifeChecker(cfg *config.Config) (*endoflife.Checker, error) {
+	decls, err := cfg.EndOfLife()
+	if err != nil {
+		return nil, err
+	}
+	if len(decls) == 0 {
+		return nil, nil
+	}
+	return endoflife.NewChecker(endoflife.NewClient(), decls), nil
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shikanime-studio/automata/internal/buildinfo"
+	"github.com/shikanime-studio/automata/internal/sbom"
+)
+
+// NewSBOMCmd creates the "sbom" command writing the images, charts, actions
+// and flake inputs pinned in a directory or git ref as a CycloneDX document,
+// for compliance tooling to consume.
+func NewSBOMCmd() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "sbom [DIR|REF]",
+		Short: "Write the pinned dependencies as a CycloneDX SBOM",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tree := "."
+			if len(args) > 0 {
+				tree = args[0]
+			}
+			deps, err := collectTree(cmd.Context(), tree)
+			if err != nil {
+				return err
+			}
+			name := tree
+			if info, err := os.Stat(tree); err == nil && info.IsDir() {
+				if abs, err := filepath.Abs(tree); err == nil {
+					name = filepath.Base(abs)
+				}
+			}
+			date, err := sourceDate()
+			if err != nil {
+				return err
+			}
+			data, err := sbom.New(name, buildinfo.Get().Version, date, deps).Marshal()
+			if err != nil {
+				return err
+			}
+			if output == "" || output == "-" {
+				_, err = cmd.OutOrStdout().Write(data)
+				return err
+			}
+			return os.WriteFile(output, data, 0o644)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file the SBOM is written to, stdout by default")
+	return cmd
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/policy"
+	"github.com/shikanime-studio/automata/internal/testsource"
+)
+
+// defaultFixturesDir is the directory of the golden cases of the repository.
+const defaultFixturesDir = "testdata/fixtures"
+
+// NewTestFixturesCmd creates the hidden "test-fixtures" command running every
+// update pipeline over the input tree of each golden case under DIR, offline,
+// and comparing the result with its golden tree, so behavior changes show up
+// as reviewable diffs. With --update, the golden trees are rewritten instead.
+func NewTestFixturesCmd(cfg *config.Config) *cobra.Command {
+	var update bool
+	cmd := &cobra.Command{
+		Use:    "test-fixtures [DIR]",
+		Short:  "Compare the updates of golden cases with their golden output",
+		Hidden: true,
+		Args:   cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := defaultFixturesDir
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			ctx := policy.WithEngine(cmd.Context(), nil)
+			tree := ikio.TreeConfig{Sources: newRuleSources(ctx, cfg)}
+			mismatches, err := testsource.RunGolden(ctx, dir, update, func(ctx context.Context, dir string) error {
+				for _, p := range ikio.TreePipelines(ctx, tree, dir) {
+					if err := p.Execute(); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, m := range mismatches {
+				if _, err := fmt.Fprint(cmd.OutOrStdout(), m); err != nil {
+					return err
+				}
+			}
+			if len(mismatches) > 0 {
+				return fmt.Errorf("%d files differ from their golden output", len(mismatches))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&update, "update", false, "rewrite the golden trees from the results")
+	return cmd
+}
@@ -8,7 +8,9 @@ import (
 )
 
 // NewUpdateCmd creates the umbrella "update" command and wires its subcommands.
-// It shows help when invoked without a subcommand.
+// It shows help when invoked without a subcommand. Format plugins found on
+// PATH are added as subcommands, and every subcommand runs the configured
+// hooks around it.
 func NewUpdateCmd(cfg *config.Config) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "update",
@@ -19,9 +21,35 @@ func NewUpdateCmd(cfg *config.Config) *cobra.Command {
 	}
 	cmd.AddCommand(NewUpdateAllCmd(cfg))
 	cmd.AddCommand(NewUpdateKustomizationCmd())
+	cmd.AddCommand(NewUpdateAzurePipelinesCmd(cfg))
+	cmd.AddCommand(NewUpdateCircleCICmd())
+	cmd.AddCommand(NewUpdateClusterCmd())
+	cmd.AddCommand(NewUpdateComposeCmd())
+	cmd.AddCommand(NewUpdateCrossplaneCmd())
+	cmd.AddCommand(NewUpdateDevContainerCmd())
+	cmd.AddCommand(NewUpdateDocsCmd(cfg))
+	cmd.AddCommand(NewUpdateDroneCmd())
 	cmd.AddCommand(NewUpdateGitHubWorkflowCmd(cfg))
-	cmd.AddCommand(NewUpdateK0sctlCmd())
-	cmd.AddCommand(NewUpdateScriptCmd())
+	cmd.AddCommand(NewUpdateHelmChartCmd(cfg))
+	cmd.AddCommand(NewUpdateJsonnetCmd(cfg))
+	cmd.AddCommand(NewUpdateK0sctlCmd(cfg))
+	cmd.AddCommand(NewUpdateKOpsCmd(cfg))
+	cmd.AddCommand(NewUpdateNixCmd(cfg))
+	cmd.AddCommand(NewUpdateNixFetchCmd(cfg))
+	cmd.AddCommand(NewUpdateNixpkgsCmd(cfg))
+	cmd.AddCommand(NewUpdateOPACmd(cfg))
+	cmd.AddCommand(NewUpdateOperatorCmd(cfg))
+	cmd.AddCommand(NewUpdatePolicyBundleCmd(cfg))
+	cmd.AddCommand(NewUpdateRancherCmd(cfg))
+	cmd.AddCommand(NewUpdateRegexCmd(cfg))
+	cmd.AddCommand(NewUpdateRuleCmd(cfg))
+	cmd.AddCommand(NewUpdateScriptCmd(cfg))
+	cmd.AddCommand(NewUpdateSkaffoldCmd(cfg))
+	cmd.AddCommand(NewUpdateTalosCmd(cfg))
+	cmd.AddCommand(NewUpdateTektonCmd())
+	cmd.AddCommand(NewUpdateToolVersionCmd(cfg))
 	cmd.AddCommand(NewUpdateFlakeCmd())
+	addFormatPlugins(cmd)
+	addHooks(cfg, cmd)
 	return cmd
 }
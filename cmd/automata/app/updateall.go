@@ -1,52 +1,479 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/shikanime-studio/automata/internal/changelog"
 	"github.com/shikanime-studio/automata/internal/config"
-	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/fsutil"
+	"github.com/shikanime-studio/automata/internal/git"
 	"github.com/shikanime-studio/automata/internal/github"
-	"github.com/shikanime-studio/automata/internal/helm"
 	ikio "github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/message"
+	"github.com/shikanime-studio/automata/internal/policy"
+	"github.com/shikanime-studio/automata/internal/rancher"
 )
 
-// NewUpdateAllCmd returns a command that runs all update operations over directories.
+// Defaults of the branch and commit message of remote updates, when neither
+// set nor templated, and of the group named in templates.
+const (
+	defaultBranch  = "automata/update"
+	defaultMessage = "chore: update dependencies"
+	defaultGroup   = "dependencies"
+)
+
+// remoteOptions configures how updates to a remote repository are published.
+// An empty branch or message is rendered from its template.
+type remoteOptions struct {
+	url         string
+	branch      string
+	message     string
+	group       string
+	pullRequest bool
+}
+
+// NewUpdateAllCmd returns a command that runs all update operations over
+// directories. With --repo, the repository is cloned to a temporary directory
+// and the updates are pushed to a branch, with DIR arguments taken relative to
+// the repository root.
 func NewUpdateAllCmd(cfg *config.Config) *cobra.Command {
-	return &cobra.Command{
+	var remote remoteOptions
+	cmd := &cobra.Command{
 		Use:   "all [DIR...]",
 		Short: "Run all update operations",
-		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cu := container.NewUpdater()
-			hu := helm.NewUpdater()
-			gu := github.NewUpdater(github.NewClient(cmd.Context(), cfg))
-
-			var g errgroup.Group
-			for _, a := range args {
-				r := strings.TrimSpace(a)
-				if r == "" {
-					continue
-				}
-				g.Go(
-					func() error {
-						return ikio.UpdateKustomization(cmd.Context(), cu, r).Execute()
-					},
-				)
-				g.Go(func() error {
-					return ikio.UpdateK0sctlConfigs(cmd.Context(), hu, r).Execute()
-				})
-				g.Go(func() error {
-					return ikio.UpdateGitHubWorkflows(cmd.Context(), gu, r).Execute()
-				})
-				g.Go(func() error {
-					return runUpdateScript(cmd.Context(), r)
-				})
-				return g.Wait()
+			if remote.url != "" {
+				return runUpdateRemote(cmd.Context(), cfg, remote, args)
+			}
+			if len(args) == 0 {
+				return errors.New("requires at least 1 arg(s), only received 0")
 			}
-			return g.Wait()
+			applied := policy.NewApplied()
+			err := runUpdateAll(policy.WithApplied(cmd.Context(), applied), cfg, args, nil)
+			return errors.Join(err, writeChangelog(cmd.Context(), cfg, updateRoot(args), applied))
 		},
 	}
+	cmd.Flags().StringVar(&remote.url, "repo", "", "clone and update a remote repository")
+	cmd.Flags().StringVar(&remote.branch, "branch", "",
+		"branch to push updates to (default from the branch template, or "+defaultBranch+")")
+	cmd.Flags().StringVar(&remote.message, "message", "",
+		"commit message and pull request title (default from the commit-message template, or \""+defaultMessage+"\")")
+	cmd.Flags().StringVar(&remote.group, "group", defaultGroup, "name of the updates published together, for templates")
+	cmd.Flags().BoolVar(&remote.pullRequest, "pull-request", false, "open a GitHub pull request for the pushed branch")
+	return cmd
+}
+
+// runUpdateAll runs the update pipelines of every format over the given
+// directories, then the update scripts, and finally aligns the images
+// following the bumped charts. Directories are updated concurrently, the
+// pipelines of one in turn, as several of them may write the same file. When
+// set, scripts wraps the run of the update scripts, e.g. to watch the files
+// they change.
+func runUpdateAll(
+	ctx context.Context,
+	cfg *config.Config,
+	args []string,
+	scripts func(run func() error) error,
+) error {
+	sources := newRuleSources(ctx, cfg)
+	tree, err := newTreeConfig(cfg, sources)
+	if err != nil {
+		return err
+	}
+	docs, err := newDocsRules(cfg, sources)
+	if err != nil {
+		return err
+	}
+	sc, err := cfg.Scripts()
+	if err != nil {
+		return err
+	}
+	rc, err := cfg.Rancher()
+	if err != nil {
+		return err
+	}
+	tree.Resources = append(
+		ikio.OperatorRules(sources.Image, sources.GitHub),
+		ikio.KOpsRules(sources.Image, sources.GitHub, sources.Plugin)...,
+	)
+	tree.Rancher = ikio.RancherRules(rancher.NewUpdater(rancher.NewClient()), rc.Channel)
+	bumps := ikio.NewChartBumps()
+	ctx = ikio.WithChartBumps(ctx, bumps)
+
+	var g errgroup.Group
+	for _, a := range args {
+		r := strings.TrimSpace(a)
+		if r == "" {
+			continue
+		}
+		g.Go(func() error {
+			for _, p := range ikio.TreePipelines(ctx, tree, r) {
+				if err := p.Execute(); err != nil {
+					return err
+				}
+			}
+			return runUpdateDocs(ctx, docs, r)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	// Scripts run last, so the updates applied before are passed to them.
+	runScripts := func() error {
+		for _, a := range args {
+			if r := strings.TrimSpace(a); r != "" {
+				g.Go(func() error { return runUpdateScript(ctx, sc, r) })
+			}
+		}
+		return g.Wait()
+	}
+	if scripts == nil {
+		scripts = func(run func() error) error { return run() }
+	}
+	if err := scripts(runScripts); err != nil {
+		return err
+	}
+	return runCheckAppVersions(ctx, cfg, bumps, args)
+}
+
+// writeChangelog appends the applied updates to the changelog of the
+// repository at root, when one is configured.
+func writeChangelog(ctx context.Context, cfg *config.Config, root string, applied *policy.Applied) error {
+	file := cfg.Changelog()
+	if file == "" {
+		return nil
+	}
+	return changelog.Append(ctx, filepath.Join(root, file), time.Now().UTC(), applied.Proposals())
+}
+
+// updateRoot returns the root of the repository updated in place: the top of
+// the git worktree of the first directory, or the directory itself outside of
+// one. Without directories, it is the working directory, and files stand for
+// their directory.
+func updateRoot(dirs []string) string {
+	dir := "."
+	if len(dirs) > 0 {
+		dir = dirs[0]
+	}
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir
+	}
+	return fsutil.Worktree(abs)
+}
+
+// runUpdateRemote clones the remote repository, runs every update operation in
+// the clone and pushes the result to a branch, optionally opening a pull
+// request against the default branch.
+func runUpdateRemote(ctx context.Context, cfg *config.Config, o remoteOptions, args []string) error {
+	signing, err := cfg.Signing()
+	if err != nil {
+		return err
+	}
+	am, err := cfg.AutoMerge()
+	if err != nil {
+		return err
+	}
+	if err := checkAutoMerge(am); err != nil {
+		return err
+	}
+	tmpls, err := cfg.Templates()
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp("", "automata-")
+	if err != nil {
+		return fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	repo, err := git.Clone(ctx, o.url, dir)
+	if err != nil {
+		return err
+	}
+	base, err := repo.CurrentBranch(ctx)
+	if err != nil {
+		return err
+	}
+
+	dirs := []string{dir}
+	if len(args) > 0 {
+		dirs = dirs[:0]
+		for _, a := range args {
+			if r := strings.TrimSpace(a); r != "" {
+				dirs = append(dirs, filepath.Join(dir, r))
+			}
+		}
+	}
+	applied := policy.NewApplied()
+	ctx = policy.WithApplied(ctx, applied)
+	var scripted bool
+	watchScripts := func(run func() error) error {
+		before, err := repo.WorkTree(ctx)
+		if err != nil {
+			return err
+		}
+		if err := run(); err != nil {
+			return err
+		}
+		after, err := repo.WorkTree(ctx)
+		scripted = before != after
+		return err
+	}
+	err = runRemoteHooks(ctx, dir, dirs, func() error {
+		if err := runUpdateAll(ctx, cfg, dirs, watchScripts); err != nil {
+			return err
+		}
+		return writeChangelog(ctx, cfg, dir, applied)
+	})
+	if err != nil {
+		return err
+	}
+	if o.group == "" {
+		o.group = defaultGroup
+	}
+	data := message.NewData(o.group, applied.Proposals())
+	prefix, exact := o.branch, true
+	if o.branch == "" {
+		if o.branch, err = message.Render("branch", tmpls.Branch, defaultBranch, data); err != nil {
+			return err
+		}
+		if prefix, exact, err = message.BranchPrefix(tmpls.Branch, defaultBranch, o.group); err != nil {
+			return err
+		}
+		if prefix == "" {
+			prefix, exact = o.branch, true
+		}
+	}
+	stale := staleBranches{prefix: prefix, exact: exact}
+	if o.message == "" {
+		if o.message, err = message.Render("commit-message", tmpls.CommitMessage, defaultMessage, data); err != nil {
+			return err
+		}
+	}
+
+	changed, err := repo.ChangedFiles(ctx)
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		slog.InfoContext(ctx, "no updates", "repo", o.url)
+		if !o.pullRequest {
+			return nil
+		}
+		owner, name, err := github.ParseRepoURL(o.url)
+		if err != nil {
+			return err
+		}
+		return closeStale(ctx, github.NewClient(ctx, cfg), owner, name, repo, o, stale, staleReason)
+	}
+	if err := repo.Checkout(ctx, o.branch); err != nil {
+		return err
+	}
+	if err := repo.CommitAll(ctx, o.message, git.WithSigning(git.Signing(signing))); err != nil {
+		return err
+	}
+	refresh, err := refreshBranch(ctx, repo, o, cfg.Changelog())
+	if err != nil || !refresh {
+		return err
+	}
+	if err := repo.Push(ctx, o.branch); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "pushed updates", "repo", o.url, "branch", o.branch)
+
+	if !o.pullRequest {
+		return nil
+	}
+	owner, name, err := github.ParseRepoURL(o.url)
+	if err != nil {
+		return err
+	}
+	gc := github.NewClient(ctx, cfg)
+	subject, _, _ := strings.Cut(o.message, "\n")
+	title, err := message.Render("title", tmpls.Title, subject, data)
+	if err != nil {
+		return err
+	}
+	summary, labels := majorSummary(ctx, gc, applied.Proposals())
+	data.Summary = summary
+	body, err := message.Render("body", tmpls.Body, defaultBody+summary, data)
+	if err != nil {
+		return err
+	}
+	merge := autoMerges(am, applied.Proposals()) &&
+		attributable(ctx, cfg, dir, changed, applied.Files(), scripted)
+	if merge {
+		labels = append(labels, am.Label)
+	}
+	pr, found, err := gc.FindPullRequest(ctx, owner, name, o.branch)
+	if err != nil {
+		return err
+	}
+	if found {
+		if err := gc.UpdatePullRequest(ctx, owner, name, pr, title, body, labels...); err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "refreshed pull request", "url", pr.URL)
+	} else {
+		pr, err = gc.CreatePullRequest(ctx, owner, name, o.branch, base, title, body, labels...)
+		if err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "opened pull request", "url", pr.URL)
+	}
+	stale.keep = o.branch
+	if err := closeStale(ctx, gc, owner, name, repo, o, stale, supersededReason+pr.URL); err != nil {
+		return err
+	}
+	if !merge {
+		return nil
+	}
+	if err := gc.EnableAutoMerge(ctx, pr, am.Method); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "enabled auto-merge", "url", pr.URL, "method", am.Method)
+	return nil
+}
+
+// refreshBranch reports whether the update commit should be pushed over the
+// update branch of a previous run. The branch is fetched so the push only
+// replaces it as fetched; it is left as is when it already has the same
+// content but for the changelog, whose sections are dated by run, or when
+// someone else pushed to it.
+func refreshBranch(ctx context.Context, repo *git.Repo, o remoteOptions, changelog string) (bool, error) {
+	exists, err := repo.FetchBranch(ctx, o.branch)
+	if err != nil || !exists {
+		return true, err
+	}
+	var excludes []string
+	if changelog != "" {
+		excludes = append(excludes, changelog)
+	}
+	same, err := repo.SameTree(ctx, "HEAD", "origin/"+o.branch, excludes...)
+	if err != nil {
+		return false, err
+	}
+	if same {
+		slog.InfoContext(ctx, "update branch up to date", "repo", o.url, "branch", o.branch)
+		return false, nil
+	}
+	edited, err := branchEdited(ctx, repo, o.branch)
+	if err != nil {
+		return false, err
+	}
+	if edited {
+		slog.WarnContext(ctx, "update branch edited, not refreshing", "repo", o.url, "branch", o.branch)
+		return false, nil
+	}
+	return true, nil
+}
+
+// branchEdited reports whether the last commit of a fetched update branch
+// was committed by someone else than the identity updates are committed
+// with.
+func branchEdited(ctx context.Context, repo *git.Repo, branch string) (bool, error) {
+	committer, err := repo.Committer(ctx, "origin/"+branch)
+	if err != nil {
+		return false, err
+	}
+	identity, err := repo.Identity(ctx)
+	if err != nil {
+		return false, err
+	}
+	return committer != identity, nil
+}
+
+// staleReason is the comment left on the pull requests closed once a run
+// finds nothing left to update.
+const staleReason = "Closing: the dependencies this pull request updates are " +
+	"up to date or no longer in the repository."
+
+// supersededReason is the comment left on the pull requests closed for the
+// pull request of a later run, followed by its URL.
+const supersededReason = "Closing: superseded by "
+
+// staleBranches selects the update branches of earlier runs of a group.
+type staleBranches struct {
+	// prefix is the part of the branch names that does not depend on the
+	// updates, or the branch name itself when exact.
+	prefix string
+	exact  bool
+	// keep is the branch of the current run, left open.
+	keep string
+}
+
+// match reports whether branch is an update branch of the group other than
+// the kept one.
+func (s staleBranches) match(branch string) bool {
+	if branch == s.keep {
+		return false
+	}
+	return branch == s.prefix || !s.exact && strings.HasPrefix(branch, s.prefix)
+}
+
+// closeStale closes the open pull requests of the update branches of
+// earlier runs of the group, commenting with reason, and deletes their
+// branches. A run finding nothing left to update closes them all, as their
+// updates were applied or superseded on the base branch or their
+// dependencies removed; a run publishing updates closes those of the
+// branches it does not push to, as its updates supersede theirs. Branches
+// someone else pushed to are kept.
+func closeStale(
+	ctx context.Context,
+	gc *github.Client,
+	owner, name string,
+	repo *git.Repo,
+	o remoteOptions,
+	stale staleBranches,
+	reason string,
+) error {
+	prs, err := gc.ListPullRequests(ctx, owner, name, stale.prefix)
+	if err != nil {
+		return err
+	}
+	for _, pr := range prs {
+		if !stale.match(pr.Branch) {
+			continue
+		}
+		exists, err := repo.FetchBranch(ctx, pr.Branch)
+		if err != nil {
+			return err
+		}
+		if exists {
+			edited, err := branchEdited(ctx, repo, pr.Branch)
+			if err != nil {
+				return err
+			}
+			if edited {
+				slog.WarnContext(ctx, "update branch edited, not closing",
+					"repo", o.url, "branch", pr.Branch, "url", pr.URL)
+				continue
+			}
+		}
+		if err := gc.ClosePullRequest(ctx, owner, name, pr, reason); err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "closed stale pull request", "url", pr.URL)
+		if !exists {
+			continue
+		}
+		if err := repo.DeleteBranch(ctx, pr.Branch); err != nil {
+			return err
+		}
+	}
+	return nil
 }
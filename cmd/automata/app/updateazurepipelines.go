@@ -0,0 +1,39 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/azure"
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/github"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+)
+
+// NewUpdateAzurePipelinesCmd updates task versions and container images in
+// azure-pipelines files under each directory.
+func NewUpdateAzurePipelinesCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "azurepipelines [DIR...]",
+		Short: "Update Azure Pipelines tasks and images",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cu := container.NewUpdater()
+			tu := azure.NewUpdater(github.NewClient(cmd.Context(), cfg))
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error {
+					return ikio.UpdateAzurePipelines(cmd.Context(), cu, tu, r).Execute()
+				})
+			}
+			return g.Wait()
+		},
+	}
+}
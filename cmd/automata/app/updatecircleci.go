@@ -0,0 +1,37 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/circleci"
+	"github.com/shikanime-studio/automata/internal/container"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+)
+
+// NewUpdateCircleCICmd updates orb versions and docker executor images in
+// .circleci/config.yml under each directory.
+func NewUpdateCircleCICmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "circleci [DIR...]",
+		Short: "Update CircleCI orbs and docker images",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cu := container.NewUpdater()
+			ou := circleci.NewUpdater()
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error {
+					return ikio.UpdateCircleCIConfigs(cmd.Context(), cu, ou, r).Execute()
+				})
+			}
+			return g.Wait()
+		},
+	}
+}
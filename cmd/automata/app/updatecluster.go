@@ -0,0 +1,72 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/logging"
+)
+
+// AutoUpdateLabel opts workloads into in-cluster image updates.
+const AutoUpdateLabel = "automata.shikanime.studio/auto-update"
+
+// NewUpdateClusterCmd creates the "update cluster" command patching the
+// images of live Deployments, StatefulSets and DaemonSets to the latest tags
+// passing policy, for clusters not managed through GitOps. Only workloads
+// matching the selector, by default those labeled
+// automata.shikanime.studio/auto-update=true, are patched.
+func NewUpdateClusterCmd() *cobra.Command {
+	var (
+		kf        *kubeFlags
+		namespace string
+		selector  string
+		dryRun    bool
+	)
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "Patch the images of live workloads in a cluster",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			c, err := kf.client()
+			if err != nil {
+				return err
+			}
+			workloads, err := c.ListWorkloads(ctx, namespace, selector)
+			if err != nil {
+				return err
+			}
+			resolve := ikio.ResolveImage(container.NewUpdater())
+			for _, w := range workloads {
+				for _, ct := range w.Containers {
+					ctx := logging.WithAttrs(ctx, "workload", w.String(), "container", ct.Name)
+					image, err := resolve.Resolve(ctx, ct.Image)
+					if err != nil {
+						return fmt.Errorf("%s: container %s: %w", w, ct.Name, err)
+					}
+					if image == "" {
+						continue
+					}
+					if dryRun {
+						slog.InfoContext(ctx, "would patch image", "from", ct.Image, "to", image)
+						continue
+					}
+					if err := c.SetImage(ctx, w, ct, image); err != nil {
+						return err
+					}
+					slog.InfoContext(ctx, "patched image", "from", ct.Image, "to", image)
+				}
+			}
+			return nil
+		},
+	}
+	kf = addKubeFlags(cmd)
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "only patch the workloads of a namespace")
+	cmd.Flags().StringVarP(&selector, "selector", "l", AutoUpdateLabel+"=true", "label selector of the workloads to patch")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "log the patches without applying them")
+	return cmd
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+)
+
+// NewUpdateComposeCmd updates the service images of the Compose files under
+// each directory.
+func NewUpdateComposeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "compose [DIR...]",
+		Short: "Update Compose service images",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			u := container.NewUpdater()
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error {
+					return ikio.UpdateComposeFiles(cmd.Context(), u, r).Execute()
+				})
+			}
+			return g.Wait()
+		},
+	}
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+)
+
+// NewUpdateCrossplaneCmd updates the package references of Crossplane
+// providers, configurations and functions under each directory.
+func NewUpdateCrossplaneCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "crossplane [DIR...]",
+		Short: "Update Crossplane packages",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			u := container.NewUpdater()
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error {
+					return ikio.UpdateCrossplanePackages(cmd.Context(), u, r).Execute()
+				})
+			}
+			return g.Wait()
+		},
+	}
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/textfile"
+)
+
+// NewUpdateDocsCmd syncs the versions of fenced install snippets and badges in
+// Markdown files through the rules declared under docs in the config file.
+func NewUpdateDocsCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "docs [DIR...]",
+		Short: "Sync versions in Markdown snippets and badges",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rules, err := newDocsRules(cfg, newRuleSources(cmd.Context(), cfg))
+			if err != nil {
+				return err
+			}
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error { return runUpdateDocs(cmd.Context(), rules, r) })
+			}
+			return g.Wait()
+		},
+	}
+}
+
+func newDocsRules(cfg *config.Config, s ikio.RuleSources) ([]textfile.Rule, error) {
+	decls, err := cfg.DocsRules()
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]textfile.Rule, 0, len(decls))
+	for i, d := range decls {
+		resolver, err := ikio.NewRuleResolver(d, s)
+		if err != nil {
+			return nil, fmt.Errorf("docs rule %d: %w", i, err)
+		}
+		files := textfile.MarkdownFiles
+		if d.Files != "" {
+			files = []string{d.Files}
+		}
+		expr := d.Regex
+		if expr == "" {
+			if d.Source != "image" {
+				return nil, fmt.Errorf("docs rule %d: rule requires regex", i)
+			}
+			expr = textfile.ImageVersionRegex(d.Image)
+		}
+		rule, err := textfile.NewRule(files, expr, resolver)
+		if err != nil {
+			return nil, fmt.Errorf("docs rule %d: %w", i, err)
+		}
+		rule.Regions = textfile.MarkdownRegions
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func runUpdateDocs(ctx context.Context, rules []textfile.Rule, root string) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	return textfile.Update(ctx, rules, root)
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+)
+
+// NewUpdateDroneCmd updates step images of Drone and Woodpecker pipelines
+// under each directory, based on the images annotation configuration.
+func NewUpdateDroneCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "drone [DIR...]",
+		Short: "Update Drone and Woodpecker step images",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			u := container.NewUpdater()
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error {
+					return ikio.UpdateDronePipelines(cmd.Context(), u, r).Execute()
+				})
+			}
+			return g.Wait()
+		},
+	}
+}
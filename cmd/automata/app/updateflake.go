@@ -2,7 +2,9 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
 	"os/exec"
@@ -12,7 +14,11 @@ import (
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/shikanime-studio/automata/internal/flake"
 	"github.com/shikanime-studio/automata/internal/fsutil"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	"github.com/shikanime-studio/automata/internal/timeout"
 )
 
 // NewUpdateFlakeCmd runs `nix flake update` for directories containing flake.nix.
@@ -39,6 +45,7 @@ func NewUpdateFlakeCmd() *cobra.Command {
 // runUpdateFlake walks the directory tree and executes `nix flake update` for each found flake.nix.
 func runUpdateFlake(ctx context.Context, root string) error {
 	var g errgroup.Group
+	g.SetLimit(fsutil.Workers)
 	handler := func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -52,8 +59,8 @@ func runUpdateFlake(ctx context.Context, root string) error {
 		return nil
 	}
 	handler = fsutil.SkipHidden(root, handler)
-	handler = fsutil.SkipGitIgnored(ctx, root, handler)
-	if err := filepath.WalkDir(root, handler); err != nil {
+	handler = fsutil.SkipIgnored(root, handler)
+	if err := fsutil.Walk(root, handler); err != nil {
 		return fmt.Errorf("scan for flake.nix: %w", err)
 	}
 	return g.Wait()
@@ -62,6 +69,13 @@ func runUpdateFlake(ctx context.Context, root string) error {
 func createFlakeUpdateJob(ctx context.Context, dir string) func() error {
 	return func() error {
 		slog.InfoContext(ctx, "running nix flake update", "dir", dir)
+		lock := filepath.Join(dir, "flake.lock")
+		before, err := os.ReadFile(lock)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("read %s: %w", lock, err)
+		}
+		ctx, cancel := timeout.Context(ctx, timeout.Nix)
+		defer cancel()
 		cmd := exec.CommandContext(ctx, "nix", "flake", "update")
 		cmd.Dir = dir
 		cmd.Env = os.Environ()
@@ -71,10 +85,32 @@ func createFlakeUpdateJob(ctx context.Context, dir string) func() error {
 			slog.InfoContext(ctx, "nix flake update output", "dir", dir, "output", string(out))
 		}
 		if runErr != nil {
-			slog.WarnContext(ctx, "nix flake update failed", "dir", dir, "err", runErr)
+			slog.WarnContext(ctx, "nix flake update failed", logging.Failed.Attr(), "dir", dir, "err", runErr)
 			return fmt.Errorf("nix flake update in %s: %w", dir, runErr)
 		}
+		if before != nil {
+			reportFlakeBumps(ctx, lock, before)
+		}
 		slog.InfoContext(ctx, "nix flake update completed", "dir", dir)
 		return nil
 	}
 }
+
+// reportFlakeBumps reports the inputs bumped in a flake.lock since before,
+// recording them for the commits and pull requests publishing the run.
+func reportFlakeBumps(ctx context.Context, lock string, before []byte) {
+	after, err := os.ReadFile(lock)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to read flake lock", logging.Failed.Attr(), "file", lock, "err", err)
+		return
+	}
+	bumps, err := flake.Diff(before, after)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to compare flake lock", logging.Failed.Attr(), "file", lock, "err", err)
+		return
+	}
+	ctx = policy.WithFile(ctx, lock)
+	for _, b := range bumps {
+		policy.Record(ctx, policy.Proposal{Source: "flake", Name: b.Name, From: b.From, To: b.To})
+	}
+}
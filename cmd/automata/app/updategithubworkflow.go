@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -9,10 +10,13 @@ import (
 	"github.com/shikanime-studio/automata/internal/config"
 	"github.com/shikanime-studio/automata/internal/github"
 	ikio "github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/toolversion"
 )
 
 // NewUpdateGitHubWorkflowCmd creates the "githubworkflow" command that updates
-// GitHub Actions versions in workflow files.
+// GitHub Actions versions in workflow files, with the tag selection declared
+// under workflow-actions in the config file, then the tool version inputs
+// declared under workflow-inputs.
 func NewUpdateGitHubWorkflowCmd(cfg *config.Config) *cobra.Command {
 	return &cobra.Command{
 		Use:   "githubworkflow [DIR...]",
@@ -20,17 +24,49 @@ func NewUpdateGitHubWorkflowCmd(cfg *config.Config) *cobra.Command {
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			u := github.NewUpdater(github.NewClient(cmd.Context(), cfg))
+			tools, err := newTools(cfg)
+			if err != nil {
+				return err
+			}
+			inputs, err := toolversion.LookupTools(cfg.WorkflowInputs(), tools)
+			if err != nil {
+				return err
+			}
+			decls, err := cfg.WorkflowActions()
+			if err != nil {
+				return err
+			}
+			actions, err := ikio.NewActionConfigs(decls)
+			if err != nil {
+				return err
+			}
 			var g errgroup.Group
 			for _, a := range args {
 				r := strings.TrimSpace(a)
 				if r == "" {
 					continue
 				}
-				g.Go(
-					func() error { return ikio.UpdateGitHubWorkflows(cmd.Context(), u, r).Execute() },
-				)
+				g.Go(func() error { return runUpdateGitHubWorkflow(cmd.Context(), u, actions, inputs, r) })
 			}
 			return g.Wait()
 		},
 	}
 }
+
+// runUpdateGitHubWorkflow updates actions then tool inputs in the workflows of
+// root. Both pipelines rewrite the same files, so they run sequentially.
+func runUpdateGitHubWorkflow(
+	ctx context.Context,
+	u github.Updater,
+	actions map[string]ikio.ActionConfig,
+	inputs map[string]toolversion.Tool,
+	root string,
+) error {
+	if err := ikio.UpdateGitHubWorkflows(ctx, u, actions, root).Execute(); err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		return nil
+	}
+	return ikio.UpdateGitHubWorkflowInputs(ctx, u, inputs, root).Execute()
+}
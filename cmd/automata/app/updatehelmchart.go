@@ -0,0 +1,42 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/helm"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+)
+
+// NewUpdateHelmChartCmd updates the charts of kustomization helmCharts entries
+// and Flux HelmReleases with their latest versions, then the images following
+// the bumped charts.
+func NewUpdateHelmChartCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "helmchart [DIR...]",
+		Short: "Update kustomization and Flux Helm charts",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			u := helm.NewUpdater()
+			bumps := ikio.NewChartBumps()
+			ctx := ikio.WithChartBumps(cmd.Context(), bumps)
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(
+					func() error { return ikio.UpdateHelmCharts(ctx, u, r).Execute() },
+				)
+			}
+			if err := g.Wait(); err != nil {
+				return err
+			}
+			return runCheckAppVersions(ctx, cfg, bumps, args)
+		},
+	}
+}
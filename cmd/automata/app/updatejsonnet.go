@@ -0,0 +1,66 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/textfile"
+)
+
+// NewUpdateJsonnetCmd updates the jsonnet version constants declared under
+// jsonnet in the config file.
+func NewUpdateJsonnetCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "jsonnet [DIR...]",
+		Short: "Update jsonnet version constants",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			decls, err := cfg.JsonnetRules()
+			if err != nil {
+				return err
+			}
+			sources := newRuleSources(cmd.Context(), cfg)
+			rules := make([]textfile.Rule, 0, len(decls))
+			for i, d := range decls {
+				rule, err := newJsonnetRule(d, sources)
+				if err != nil {
+					return fmt.Errorf("jsonnet rule %d: %w", i, err)
+				}
+				rules = append(rules, rule)
+			}
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error { return textfile.Update(cmd.Context(), rules, r) })
+			}
+			return g.Wait()
+		},
+	}
+}
+
+func newJsonnetRule(d config.JsonnetRule, s ikio.RuleSources) (textfile.Rule, error) {
+	resolver, err := ikio.NewRuleResolver(d.Rule, s)
+	if err != nil {
+		return textfile.Rule{}, err
+	}
+	files := textfile.JsonnetFiles
+	if d.Files != "" {
+		files = []string{d.Files}
+	}
+	expr := d.Regex
+	if expr == "" {
+		if d.Constant == "" {
+			return textfile.Rule{}, fmt.Errorf("rule requires constant or regex")
+		}
+		expr = textfile.JsonnetConstantRegex(d.Constant)
+	}
+	return textfile.NewRule(files, expr, resolver)
+}
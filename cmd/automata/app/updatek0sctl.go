@@ -6,18 +6,22 @@ import (
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/shikanime-studio/automata/internal/config"
 	"github.com/shikanime-studio/automata/internal/helm"
 	ikio "github.com/shikanime-studio/automata/internal/kio"
 )
 
-// NewUpdateK0sctlCmd updates k0sctl clusters with the latest chart versions.
-func NewUpdateK0sctlCmd() *cobra.Command {
+// NewUpdateK0sctlCmd updates k0sctl clusters with the latest chart versions,
+// then the images following the bumped charts.
+func NewUpdateK0sctlCmd(cfg *config.Config) *cobra.Command {
 	return &cobra.Command{
 		Use:   "k0sctl [DIR...]",
 		Short: "Update k0sctl with latest chart versions",
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			u := helm.NewUpdater()
+			bumps := ikio.NewChartBumps()
+			ctx := ikio.WithChartBumps(cmd.Context(), bumps)
 			var g errgroup.Group
 			for _, a := range args {
 				r := strings.TrimSpace(a)
@@ -25,10 +29,13 @@ func NewUpdateK0sctlCmd() *cobra.Command {
 					continue
 				}
 				g.Go(
-					func() error { return ikio.UpdateK0sctlConfigs(cmd.Context(), u, r).Execute() },
+					func() error { return ikio.UpdateK0sctlConfigs(ctx, u, r).Execute() },
 				)
 			}
-			return g.Wait()
+			if err := g.Wait(); err != nil {
+				return err
+			}
+			return runCheckAppVersions(ctx, cfg, bumps, args)
 		},
 	}
 }
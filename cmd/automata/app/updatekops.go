@@ -0,0 +1,42 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/github"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/plugin"
+)
+
+// NewUpdateKOpsCmd updates the Kubernetes version, addons and machine images
+// of kOps clusters and instance groups.
+func NewUpdateKOpsCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "kops [DIR...]",
+		Short: "Update kOps cluster specs",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rules := ikio.KOpsRules(
+				container.NewUpdater(),
+				github.NewUpdater(github.NewClient(cmd.Context(), cfg)),
+				plugin.NewUpdater(),
+			)
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error {
+					return ikio.UpdateResources(cmd.Context(), rules, r).Execute()
+				})
+			}
+			return g.Wait()
+		},
+	}
+}
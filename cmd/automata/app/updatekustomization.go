@@ -11,11 +11,12 @@ import (
 )
 
 // NewUpdateKustomizationCmd updates kustomize image tags across a directory tree.
-// It scans for kustomization.yaml files and updates image tags based on
-// the images annotation configuration and chosen registry strategy.
+// It scans for kustomization.yaml, kustomization.yml and Kustomization files,
+// or updates the kustomization files passed explicitly, based on the images
+// annotation configuration and chosen registry strategy.
 func NewUpdateKustomizationCmd() *cobra.Command {
 	return &cobra.Command{
-		Use:   "kustomization [DIR...]",
+		Use:   "kustomization [DIR|FILE...]",
 		Short: "Update kustomize image tags",
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/textfile"
+)
+
+// NewUpdateNixCmd updates the versions assigned to Nix attributes, such as
+// the options of NixOS and Home Manager modules, through the rules declared
+// under nix in the config file.
+func NewUpdateNixCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "nix [DIR...]",
+		Short: "Update versions of Nix attributes",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			decls, err := cfg.NixRules()
+			if err != nil {
+				return err
+			}
+			sources := newRuleSources(cmd.Context(), cfg)
+			rules := make([]textfile.Rule, 0, len(decls))
+			for i, d := range decls {
+				rule, err := newNixRule(d, sources)
+				if err != nil {
+					return fmt.Errorf("nix rule %d: %w", i, err)
+				}
+				rules = append(rules, rule)
+			}
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error { return textfile.Update(cmd.Context(), rules, r) })
+			}
+			return g.Wait()
+		},
+	}
+}
+
+func newNixRule(d config.Rule, s ikio.RuleSources) (textfile.Rule, error) {
+	if d.Path == "" {
+		return textfile.Rule{}, fmt.Errorf("rule requires path")
+	}
+	resolver, err := ikio.NewRuleResolver(d, s)
+	if err != nil {
+		return textfile.Rule{}, err
+	}
+	files := textfile.NixFiles
+	if d.Files != "" {
+		files = []string{d.Files}
+	}
+	expr := d.Regex
+	if expr == "" {
+		expr = textfile.NixVersionRegex
+	}
+	rule, err := textfile.NewRule(files, expr, resolver)
+	if err != nil {
+		return textfile.Rule{}, err
+	}
+	rule.Regions = textfile.NixAttributeRegions(d.Path)
+	return rule, nil
+}
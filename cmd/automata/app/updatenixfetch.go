@@ -0,0 +1,35 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/nixfetch"
+)
+
+// NewUpdateNixFetchCmd creates the "update nixfetch" command bumping the
+// versions of the Nix package definitions found under each directory to the
+// latest GitHub tag, along with the hashes of their fetchers.
+func NewUpdateNixFetchCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "nixfetch [DIR...]",
+		Short: "Update versions and hashes of Nix fetchers",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			u := nixfetch.NewUpdater(github.NewUpdater(github.NewClient(cmd.Context(), cfg)))
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error { return u.Update(cmd.Context(), r) })
+			}
+			return g.Wait()
+		},
+	}
+}
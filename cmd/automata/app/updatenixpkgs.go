@@ -0,0 +1,38 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/nixpkgs"
+)
+
+// NewUpdateNixpkgsCmd creates the "update nixpkgs" command bumping the
+// nixpkgs pins of niv, npins and fetchTarball calls found under each
+// directory to the latest release of their channel.
+func NewUpdateNixpkgsCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "nixpkgs [DIR...]",
+		Short: "Update nixpkgs pins of niv, npins and fetchTarball",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n, err := cfg.Nixpkgs()
+			if err != nil {
+				return err
+			}
+			u := nixpkgs.NewUpdater(n.Channel)
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error { return u.Update(cmd.Context(), r) })
+			}
+			return g.Wait()
+		},
+	}
+}
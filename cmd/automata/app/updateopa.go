@@ -0,0 +1,44 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+)
+
+// NewUpdateOPACmd updates the revisions of the OPA bundles declared under
+// opa-bundles in the config file, pinned by the OPA configs under each
+// directory, and the OPA runtime images of its workloads.
+func NewUpdateOPACmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "opa [DIR...]",
+		Short: "Update OPA bundle revisions and runtime images",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			decls, err := cfg.OPABundles()
+			if err != nil {
+				return err
+			}
+			sources := newRuleSources(cmd.Context(), cfg)
+			bundles, err := ikio.NewOPABundles(decls, sources)
+			if err != nil {
+				return err
+			}
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error {
+					return ikio.UpdateOPAManifests(cmd.Context(), bundles, sources.Image, r).Execute()
+				})
+			}
+			return g.Wait()
+		},
+	}
+}
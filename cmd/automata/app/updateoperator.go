@@ -0,0 +1,40 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/github"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+)
+
+// NewUpdateOperatorCmd updates component versions embedded in operator custom
+// resources such as CloudNativePG, Strimzi and ECK.
+func NewUpdateOperatorCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "operator [DIR...]",
+		Short: "Update operator custom resource versions",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rules := ikio.OperatorRules(
+				container.NewUpdater(),
+				github.NewUpdater(github.NewClient(cmd.Context(), cfg)),
+			)
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error {
+					return ikio.UpdateResources(cmd.Context(), rules, r).Execute()
+				})
+			}
+			return g.Wait()
+		},
+	}
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/github"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+)
+
+// NewUpdatePolicyBundleCmd updates the Kyverno and Gatekeeper policy libraries
+// referenced by the manifests under each directory.
+func NewUpdatePolicyBundleCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "policybundle [DIR...]",
+		Short: "Update Kyverno and Gatekeeper policy bundles",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cu := container.NewUpdater()
+			gu := github.NewUpdater(github.NewClient(cmd.Context(), cfg))
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error {
+					return ikio.UpdatePolicyBundles(cmd.Context(), cu, gu, r).Execute()
+				})
+			}
+			return g.Wait()
+		},
+	}
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/helm"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/rancher"
+)
+
+// NewUpdateRancherCmd updates the k3s and RKE2 releases pinned by upgrade
+// plans and provisioning clusters to the latest of their release channel, and
+// the HelmChart resources of their manifests directories, then the images
+// following the bumped charts.
+func NewUpdateRancherCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rancher [DIR...]",
+		Short: "Update k3s and RKE2 releases and HelmChart resources",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rc, err := cfg.Rancher()
+			if err != nil {
+				return err
+			}
+			rules := ikio.RancherRules(rancher.NewUpdater(rancher.NewClient()), rc.Channel)
+			hu := helm.NewUpdater()
+			bumps := ikio.NewChartBumps()
+			ctx := ikio.WithChartBumps(cmd.Context(), bumps)
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error {
+					return ikio.UpdateRancherManifests(ctx, rules, hu, r).Execute()
+				})
+			}
+			if err := g.Wait(); err != nil {
+				return err
+			}
+			return runCheckAppVersions(ctx, cfg, bumps, args)
+		},
+	}
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/textfile"
+)
+
+// NewUpdateRegexCmd updates versions embedded in plain-text files, such as
+// Makefiles, shell scripts and docs, through the rules declared under regex in
+// the config file.
+func NewUpdateRegexCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "regex [DIR...]",
+		Short: "Update versions in plain-text files",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			decls, err := cfg.RegexRules()
+			if err != nil {
+				return err
+			}
+			rules, err := newTextRules(decls, newRuleSources(cmd.Context(), cfg))
+			if err != nil {
+				return err
+			}
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error { return textfile.Update(cmd.Context(), rules, r) })
+			}
+			return g.Wait()
+		},
+	}
+}
+
+func newTextRules(decls []config.Rule, s ikio.RuleSources) ([]textfile.Rule, error) {
+	rules := make([]textfile.Rule, 0, len(decls))
+	for i, d := range decls {
+		resolver, err := ikio.NewRuleResolver(d, s)
+		if err != nil {
+			return nil, fmt.Errorf("regex rule %d: %w", i, err)
+		}
+		if d.Files == "" || d.Regex == "" {
+			return nil, fmt.Errorf("regex rule %d: rule requires files and regex", i)
+		}
+		rule, err := textfile.NewRule([]string{d.Files}, d.Regex, resolver)
+		if err != nil {
+			return nil, fmt.Errorf("regex rule %d: %w", i, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/git"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/helm"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/plugin"
+	"github.com/shikanime-studio/automata/internal/toolversion"
+)
+
+// NewUpdateRuleCmd applies the custom rules declared in the config file.
+// Each rule targets a file glob and YAML path and resolves versions from an
+// image, GitHub, Helm or git source.
+func NewUpdateRuleCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rule [DIR...]",
+		Short: "Update values declared by config rules",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			decls, err := cfg.Rules()
+			if err != nil {
+				return err
+			}
+			rules, err := ikio.NewPathRules(decls, newRuleSources(cmd.Context(), cfg))
+			if err != nil {
+				return err
+			}
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error {
+					return ikio.UpdatePathRules(cmd.Context(), rules, r).Execute()
+				})
+			}
+			return g.Wait()
+		},
+	}
+}
+
+// newRuleSources creates the updaters config rules resolve versions with.
+func newRuleSources(ctx context.Context, cfg *config.Config) ikio.RuleSources {
+	return ikio.RuleSources{
+		Image:  container.NewUpdater(),
+		GitHub: github.NewUpdater(github.NewClient(ctx, cfg)),
+		Helm:   helm.NewUpdater(),
+		Git:    git.NewUpdater(),
+		Plugin: plugin.NewUpdater(),
+	}
+}
+
+// newTreeConfig creates the config of the pipelines updating every format of
+// a tree, resolving versions with the given sources.
+func newTreeConfig(cfg *config.Config, s ikio.RuleSources) (ikio.TreeConfig, error) {
+	tools, err := newTools(cfg)
+	if err != nil {
+		return ikio.TreeConfig{}, err
+	}
+	inputs, err := toolversion.LookupTools(cfg.WorkflowInputs(), tools)
+	if err != nil {
+		return ikio.TreeConfig{}, err
+	}
+	decls, err := cfg.Rules()
+	if err != nil {
+		return ikio.TreeConfig{}, err
+	}
+	rules, err := ikio.NewPathRules(decls, s)
+	if err != nil {
+		return ikio.TreeConfig{}, err
+	}
+	bundleDecls, err := cfg.OPABundles()
+	if err != nil {
+		return ikio.TreeConfig{}, err
+	}
+	bundles, err := ikio.NewOPABundles(bundleDecls, s)
+	if err != nil {
+		return ikio.TreeConfig{}, err
+	}
+	actionDecls, err := cfg.WorkflowActions()
+	if err != nil {
+		return ikio.TreeConfig{}, err
+	}
+	actions, err := ikio.NewActionConfigs(actionDecls)
+	if err != nil {
+		return ikio.TreeConfig{}, err
+	}
+	return ikio.TreeConfig{
+		Sources: s,
+		Inputs:  inputs,
+		Rules:   rules,
+		Bundles: bundles,
+		Actions: actions,
+	}, nil
+}
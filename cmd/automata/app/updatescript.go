@@ -8,21 +8,37 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/shikanime-studio/automata/internal/config"
 	"github.com/shikanime-studio/automata/internal/fsutil"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	"github.com/shikanime-studio/automata/internal/timeout"
 )
 
-// NewUpdateScriptCmd runs all update.sh scripts found under the provided directory.
-func NewUpdateScriptCmd() *cobra.Command {
-	return &cobra.Command{
+// NewUpdateScriptCmd runs all update.sh and update.d scripts found under the
+// provided directory.
+func NewUpdateScriptCmd(cfg *config.Config) *cobra.Command {
+	var dry bool
+	cmd := &cobra.Command{
 		Use:   "updatescript [DIR...]",
 		Short: "Run all update.sh scripts",
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			sc, err := cfg.Scripts()
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			if dry {
+				ctx = withDryRun(ctx, true)
+			}
 			var g errgroup.Group
 			for _, a := range args {
 				r := strings.TrimSpace(a)
@@ -30,56 +46,129 @@ func NewUpdateScriptCmd() *cobra.Command {
 					continue
 				}
 				rr := r
-				g.Go(func() error { return runUpdateScript(cmd.Context(), rr) })
+				g.Go(func() error { return runUpdateScript(ctx, sc, rr) })
 			}
 			return g.Wait()
 		},
 	}
+	cmd.Flags().BoolVar(&dry, "dry-run", false, "set AUTOMATA_DRY_RUN for the scripts to only report changes")
+	return cmd
+}
+
+type dryRunKey struct{}
+
+// withDryRun returns a context telling the scripts run with it whether the
+// run is a dry run.
+func withDryRun(ctx context.Context, dry bool) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, dry)
+}
+
+// isDryRun reports whether the context is a dry run.
+func isDryRun(ctx context.Context) bool {
+	dry, _ := ctx.Value(dryRunKey{}).(bool)
+	return dry
 }
 
-// runUpdateScript walks the directory tree starting at root and executes every update.sh found.
-func runUpdateScript(ctx context.Context, root string) error {
+// runUpdateScript walks the directory tree starting at root and executes
+// every update.sh found, and the *.sh scripts of every update.d directory in
+// lexical order.
+func runUpdateScript(ctx context.Context, sc config.Scripts, root string) error {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", root, err)
+	}
 	var g errgroup.Group
+	g.SetLimit(fsutil.Workers)
 	handler := func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 		if d.IsDir() {
-			return nil
+			if d.Name() != "update.d" {
+				return nil
+			}
+			scripts, err := filepath.Glob(filepath.Join(path, "*.sh"))
+			if err != nil {
+				return err
+			}
+			sort.Strings(scripts)
+			if len(scripts) > 0 {
+				g.Go(createUpdateScriptJob(ctx, sc, abs, scripts...))
+			}
+			return filepath.SkipDir
 		}
-		if filepath.Base(path) == "update.sh" {
-			g.Go(createUpdateScriptJob(ctx, path))
+		if d.Name() == "update.sh" {
+			g.Go(createUpdateScriptJob(ctx, sc, abs, path))
 		}
 		return nil
 	}
 	handler = fsutil.SkipHidden(root, handler)
-	handler = fsutil.SkipGitIgnored(ctx, root, handler)
-	if err := filepath.WalkDir(root, handler); err != nil {
+	handler = fsutil.SkipIgnored(root, handler)
+	if err := fsutil.Walk(root, handler); err != nil {
 		return fmt.Errorf("scan for update.sh: %w", err)
 	}
 	return g.Wait()
 }
 
-func createUpdateScriptJob(ctx context.Context, scriptPath string) func() error {
+// createUpdateScriptJob returns a job running scripts one after the other,
+// stopping at the first failure.
+func createUpdateScriptJob(ctx context.Context, sc config.Scripts, root string, scripts ...string) func() error {
 	return func() error {
-		dir := filepath.Dir(scriptPath)
-		slog.InfoContext(ctx, "running update script", "script", scriptPath)
-		// Note: "update.sh" relies on the script being in PATH or the behavior of the shell/OS.
-		// If the intention is to run the script found at scriptPath, usually one would use the absolute path or "./update.sh".
-		// Preserving original behavior:
-		cmd := exec.CommandContext(ctx, "update.sh")
-		cmd.Dir = dir
-		cmd.Env = os.Environ()
-
-		out, runErr := cmd.CombinedOutput()
-		if len(out) > 0 {
-			slog.InfoContext(ctx, "update.sh output", "script", scriptPath, "output", string(out))
-		}
-		if runErr != nil {
-			slog.WarnContext(ctx, "update.sh failed", "script", scriptPath, "err", runErr)
-			return fmt.Errorf("run %s: %w", scriptPath, runErr)
+		for _, script := range scripts {
+			if err := runScript(ctx, sc, root, script); err != nil {
+				return err
+			}
 		}
-		slog.InfoContext(ctx, "update script completed", "script", scriptPath)
 		return nil
 	}
 }
+
+// runScript executes a script by its absolute path from its directory, with
+// the interpreter of sc when set, passing the run context in AUTOMATA_*
+// environment variables.
+func runScript(ctx context.Context, sc config.Scripts, root, scriptPath string) error {
+	path, err := filepath.Abs(scriptPath)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", scriptPath, err)
+	}
+	argv := append(strings.Fields(sc.Interpreter), path)
+	slog.InfoContext(ctx, "running update script", "script", scriptPath)
+	ctx, cancel := timeout.Context(ctx, timeout.Script)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Env = append(os.Environ(), scriptEnv(ctx, root)...)
+
+	out, runErr := cmd.CombinedOutput()
+	if len(out) > 0 {
+		slog.InfoContext(ctx, "update.sh output", "script", scriptPath, "output", string(out))
+	}
+	if runErr != nil {
+		slog.WarnContext(ctx, "update.sh failed", logging.Failed.Attr(), "script", scriptPath, "err", runErr)
+		return fmt.Errorf("run %s: %w", scriptPath, runErr)
+	}
+	slog.InfoContext(ctx, "update script completed", "script", scriptPath)
+	return nil
+}
+
+// scriptEnv returns the environment describing the run to scripts: the
+// absolute root directory walked, along with runEnv.
+func scriptEnv(ctx context.Context, root string) []string {
+	return append([]string{"AUTOMATA_ROOT=" + root}, runEnv(ctx)...)
+}
+
+// runEnv returns the environment describing the run to scripts and hooks:
+// whether the run is a dry run, and the updates applied so far, one "source
+// name from to" line each.
+func runEnv(ctx context.Context) []string {
+	var updates []string
+	if a := policy.AppliedFrom(ctx); a != nil {
+		for _, p := range a.Proposals() {
+			updates = append(updates, strings.Join([]string{p.Source, p.Name, p.From, p.To}, " "))
+		}
+	}
+	return []string{
+		"AUTOMATA_DRY_RUN=" + strconv.FormatBool(isDryRun(ctx)),
+		"AUTOMATA_UPDATES=" + strings.Join(updates, "\n"),
+	}
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/helm"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+)
+
+// NewUpdateSkaffoldCmd updates images and Helm chart versions declared in
+// skaffold.yaml files under each directory, then the images following the
+// bumped charts.
+func NewUpdateSkaffoldCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "skaffold [DIR...]",
+		Short: "Update skaffold images and chart versions",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cu := container.NewUpdater()
+			hu := helm.NewUpdater()
+			bumps := ikio.NewChartBumps()
+			ctx := ikio.WithChartBumps(cmd.Context(), bumps)
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error {
+					return ikio.UpdateSkaffoldConfigs(ctx, cu, hu, r).Execute()
+				})
+			}
+			if err := g.Wait(); err != nil {
+				return err
+			}
+			return runCheckAppVersions(ctx, cfg, bumps, args)
+		},
+	}
+}
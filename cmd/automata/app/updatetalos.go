@@ -0,0 +1,38 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/github"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+)
+
+// NewUpdateTalosCmd updates Talos machine configs and Omni cluster templates
+// with the latest Talos, system extension and Kubernetes versions.
+func NewUpdateTalosCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "talos [DIR...]",
+		Short: "Update Talos machine configs and Omni cluster templates",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cu := container.NewUpdater()
+			gu := github.NewUpdater(github.NewClient(cmd.Context(), cfg))
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error {
+					return ikio.UpdateTalosConfigs(cmd.Context(), cu, gu, r).Execute()
+				})
+			}
+			return g.Wait()
+		},
+	}
+}
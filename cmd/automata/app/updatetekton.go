@@ -0,0 +1,35 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+)
+
+// NewUpdateTektonCmd updates step images and OCI bundle references of Tekton
+// resources under each directory.
+func NewUpdateTektonCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "tekton [DIR...]",
+		Short: "Update Tekton images and bundles",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			u := container.NewUpdater()
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error {
+					return ikio.UpdateTektonResources(cmd.Context(), u, r).Execute()
+				})
+			}
+			return g.Wait()
+		},
+	}
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/toolversion"
+)
+
+// NewUpdateToolVersionCmd updates tool pins in .tool-versions and mise
+// configuration files found under each directory, with the upstreams and tag
+// selection declared under tool-versions in the config file.
+func NewUpdateToolVersionCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "toolversion [DIR...]",
+		Short: "Update asdf and mise tool versions",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			u := github.NewUpdater(github.NewClient(cmd.Context(), cfg))
+			tools, err := newTools(cfg)
+			if err != nil {
+				return err
+			}
+			var g errgroup.Group
+			for _, a := range args {
+				r := strings.TrimSpace(a)
+				if r == "" {
+					continue
+				}
+				g.Go(func() error {
+					return toolversion.Update(cmd.Context(), u, tools, r)
+				})
+			}
+			return g.Wait()
+		},
+	}
+}
+
+// newTools returns the known tools with the declarations under tool-versions
+// in the config file.
+func newTools(cfg *config.Config) (map[string]toolversion.Tool, error) {
+	decls, err := cfg.ToolVersions()
+	if err != nil {
+		return nil, err
+	}
+	return toolversion.NewTools(decls)
+}
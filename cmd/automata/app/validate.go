@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/message"
+	"github.com/shikanime-studio/automata/internal/timeout"
+)
+
+// NewValidateCmd creates the "validate" command that lints the configuration
+// of a repository before it silently causes updates to be skipped: the keys
+// of the .automata.yaml of a directory, the rules, policies and notifications
+// it declares, and the JSON annotations of the kustomizations and pipelines
+// under the directory, printing each problem with its file and line.
+func NewValidateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate [DIR]",
+		Short: "Validate .automata.yaml, its rules and the annotations of manifests",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := "."
+			if len(args) > 0 {
+				root = args[0]
+			}
+			cfg, err := config.Load(root)
+			if err != nil {
+				return err
+			}
+			problems := lintConfig(cmd.Context(), cfg)
+			lints, err := kio.LintPackage(root)
+			if err != nil {
+				return err
+			}
+			for _, l := range lints {
+				problems = append(problems, l)
+			}
+			for _, p := range problems {
+				if _, err := fmt.Fprintln(cmd.OutOrStdout(), p); err != nil {
+					return err
+				}
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("found %d configuration problems", len(problems))
+			}
+			return nil
+		},
+	}
+}
+
+// lintConfig checks the config file and builds every rule, policy and
+// notifier it declares, without resolving any version.
+func lintConfig(ctx context.Context, cfg *config.Config) []error {
+	var problems []error
+	if err := cfg.Check(); err != nil {
+		problems = unjoin(err)
+	}
+	prefix := func(err error) error {
+		return fmt.Errorf("%s: %w", cfg.File(), err)
+	}
+	if _, _, err := NewRun(ctx, cfg); err != nil {
+		for _, err := range unjoin(err) {
+			problems = append(problems, prefix(err))
+		}
+	}
+	if decls, err := cfg.Timeouts(); err != nil {
+		problems = append(problems, prefix(err))
+	} else if _, err := timeout.New(decls, nil); err != nil {
+		problems = append(problems, prefix(err))
+	}
+	if _, err := newTools(cfg); err != nil {
+		problems = append(problems, prefix(err))
+	}
+	var sources kio.RuleSources
+	if decls, err := cfg.Rules(); err != nil {
+		problems = append(problems, prefix(err))
+	} else if _, err := kio.NewPathRules(decls, sources); err != nil {
+		problems = append(problems, prefix(err))
+	}
+	if decls, err := cfg.RegexRules(); err != nil {
+		problems = append(problems, prefix(err))
+	} else if _, err := newTextRules(decls, sources); err != nil {
+		problems = append(problems, prefix(err))
+	}
+	if _, err := newDocsRules(cfg, sources); err != nil {
+		problems = append(problems, prefix(err))
+	}
+	if am, err := cfg.AutoMerge(); err != nil {
+		problems = append(problems, prefix(err))
+	} else if err := checkAutoMerge(am); err != nil {
+		problems = append(problems, prefix(err))
+	}
+	if t, err := cfg.Templates(); err != nil {
+		problems = append(problems, prefix(err))
+	} else {
+		for _, tmpl := range [][2]string{
+			{"commit-message", t.CommitMessage},
+			{"branch", t.Branch},
+			{"title", t.Title},
+			{"body", t.Body},
+		} {
+			if _, err := message.Parse(tmpl[0], tmpl[1]); err != nil {
+				problems = append(problems, prefix(err))
+			}
+		}
+	}
+	if decls, err := cfg.OPABundles(); err != nil {
+		problems = append(problems, prefix(err))
+	} else if _, err := kio.NewOPABundles(decls, sources); err != nil {
+		problems = append(problems, prefix(err))
+	}
+	if decls, err := cfg.WorkflowActions(); err != nil {
+		problems = append(problems, prefix(err))
+	} else if _, err := kio.NewActionConfigs(decls); err != nil {
+		problems = append(problems, prefix(err))
+	}
+	decls, err := cfg.JsonnetRules()
+	if err != nil {
+		problems = append(problems, prefix(err))
+	}
+	for i, d := range decls {
+		if _, err := newJsonnetRule(d, sources); err != nil {
+			problems = append(problems, prefix(fmt.Errorf("jsonnet rule %d: %w", i, err)))
+		}
+	}
+	return problems
+}
+
+// checkAutoMerge checks the merge method and update types of the auto-merge
+// settings.
+func checkAutoMerge(am config.AutoMerge) error {
+	if _, ok := github.MergeMethods[am.Method]; am.Method != "" && !ok {
+		return fmt.Errorf("automerge: unknown merge method %q", am.Method)
+	}
+	for _, u := range am.Updates {
+		if u != "patch" && u != "minor" {
+			return fmt.Errorf("automerge: unknown update type %q, want patch or minor", u)
+		}
+	}
+	return nil
+}
+
+// unjoin splits errors joined with errors.Join.
+func unjoin(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shikanime-studio/automata/internal/buildinfo"
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/github"
+)
+
+// NewVersionCmd creates the "version" command that prints the version,
+// commit and build date of automata and, with --check, whether a newer
+// release exists.
+func NewVersionCmd(cfg *config.Config) *cobra.Command {
+	var check bool
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the version and build information",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			info := buildinfo.Get()
+			out := cmd.OutOrStdout()
+			fmt.Fprintln(out, info)
+			if !check {
+				return nil
+			}
+			ctx := cmd.Context()
+			latest, newer, err := buildinfo.Check(ctx, github.NewClient(ctx, cfg), info.Version)
+			if err != nil {
+				return err
+			}
+			switch {
+			case newer:
+				fmt.Fprintf(out, "a newer release is available: %s, see https://github.com/%s/%s/releases\n",
+					latest, buildinfo.Repository.Owner, buildinfo.Repository.Repo)
+			case buildinfo.Development(info.Version):
+				fmt.Fprintf(out, "development build, the latest release is %s\n", latest)
+			default:
+				fmt.Fprintln(out, "automata is up to date")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&check, "check", false, "check whether a newer release exists")
+	return cmd
+}
@@ -3,15 +3,34 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 
 	"github.com/shikanime-studio/automata/cmd/automata/app"
+	"github.com/shikanime-studio/automata/internal/buildinfo"
 	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/endoflife"
+	"github.com/shikanime-studio/automata/internal/fsutil"
+	"github.com/shikanime-studio/automata/internal/helm"
+	"github.com/shikanime-studio/automata/internal/license"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/mirror"
+	"github.com/shikanime-studio/automata/internal/notify"
+	"github.com/shikanime-studio/automata/internal/retry"
+	"github.com/shikanime-studio/automata/internal/timeout"
+	"github.com/shikanime-studio/automata/internal/updater"
 )
 
+// levels holds the log level of each subsystem, tuned by the --quiet and
+// --verbose flags.
+var levels *logging.Levels
+
 // init configures the global logger using values from the application
 // configuration.
 func init() {
@@ -20,30 +39,229 @@ func init() {
 		slog.Error("failed to initialize config", "err", err)
 		os.Exit(1)
 	}
-	opts := &slog.HandlerOptions{Level: cfg.LogLevel(), AddSource: cfg.LogSource()}
+	levels = logging.NewLevels(cfg.LogLevel())
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug, AddSource: cfg.LogSource()}
 	var h slog.Handler
 	if cfg.LogFormat() == "json" {
 		h = slog.NewJSONHandler(os.Stderr, opts)
 	} else {
 		h = slog.NewTextHandler(os.Stderr, opts)
 	}
-	slog.SetDefault(slog.New(h))
+	slog.SetDefault(slog.New(logging.NewHandler(h, levels)))
 }
 
 // main constructs the root Cobra command, wires subcommands, and executes it.
 func main() {
-	rootCmd := &cobra.Command{
-		Use:   "automata",
-		Short: "Automata CLI",
-	}
+	var quiet, verbose []string
+	var timeouts map[string]string
+	var offline string
+	var allowDowngrade bool
+	var run app.Run
 	cfg, err := config.New()
 	if err != nil {
 		slog.Error("failed to initialize config", "err", err)
 		os.Exit(1)
 	}
-	rootCmd.AddCommand(app.NewUpdateCmd(cfg))
-	if err := rootCmd.Execute(); err != nil {
-		slog.Error("command execution failed", "err", err)
+	// withConfig gives the commands calling registries and APIs the
+	// timeouts, retries and credentials of the config.
+	withConfig := func(cmd *cobra.Command, _ []string) error {
+		decls, err := cfg.Timeouts()
+		if err != nil {
+			return err
+		}
+		t, err := timeout.New(decls, timeouts)
+		if err != nil {
+			return err
+		}
+		retries, err := cfg.Retry()
+		if err != nil {
+			return err
+		}
+		ctx := timeout.WithTimeouts(cmd.Context(), t)
+		ctx = retry.WithPolicy(ctx, retry.NewPolicy(retries))
+		ctx, err = withCredentials(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		cmd.SetContext(ctx)
+		return nil
+	}
+	// withRun also builds the policies and the publishers of the report for
+	// the commands updating dependencies.
+	withRun := func(cmd *cobra.Command, args []string) error {
+		if err := withConfig(cmd, args); err != nil {
+			return err
+		}
+		ctx, r, err := app.NewRun(cmd.Context(), cfg)
+		if err != nil {
+			return err
+		}
+		run = r
+		cmd.SetContext(ctx)
+		return nil
+	}
+	// The persistent hooks of a command run after those of the root.
+	cobra.EnableTraverseRunHooks = true
+	rootCmd := &cobra.Command{
+		Use:     "automata",
+		Short:   "Automata CLI",
+		Version: buildinfo.Get().Version,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			levels.Set(slog.LevelWarn, quiet...)
+			levels.Set(slog.LevelDebug, verbose...)
+			ctx := cmd.Context()
+			if offline != "" {
+				snap, err := mirror.Load(offline)
+				if err != nil {
+					return err
+				}
+				ctx = mirror.WithOffline(ctx, snap)
+			}
+			ctx = mirror.WithMemo(ctx, mirror.NewMemo())
+			ctx = updater.WithDowngrades(ctx, allowDowngrade)
+			cmd.SetContext(logging.WithSubsystem(ctx, cmd.Name()))
+			return nil
+		},
+	}
+	rootCmd.CompletionOptions.HiddenDefaultCmd = true
+	rootCmd.PersistentFlags().StringSliceVar(&quiet, "quiet", nil,
+		"only log warnings, for all subsystems or the listed ones")
+	rootCmd.PersistentFlags().Lookup("quiet").NoOptDefVal = "all"
+	rootCmd.PersistentFlags().StringSliceVar(&verbose, "verbose", nil,
+		"log debug records, for all subsystems or the listed ones")
+	rootCmd.PersistentFlags().Lookup("verbose").NoOptDefVal = "all"
+	rootCmd.PersistentFlags().StringToStringVar(&timeouts, "timeout", nil,
+		"bound external calls per operation, e.g. registry=30s,nix=5m")
+	rootCmd.PersistentFlags().StringVar(&offline, "offline", "",
+		"read versions from a metadata snapshot or mirror directory instead of the network")
+	rootCmd.PersistentFlags().BoolVar(&allowDowngrade, "allow-downgrade", false,
+		"move dependencies whose version is no longer published to the greatest one left")
+	h := slog.Default().Handler()
+	if os.Getenv("GITHUB_ACTIONS") == "true" {
+		h = notify.NewAnnotator(h, os.Stdout)
+	}
+	rec := notify.NewRecorder(h)
+	slog.SetDefault(slog.New(rec))
+	rootCmd.AddCommand(withHook(app.NewUpdateCmd(cfg), withRun))
+	rootCmd.AddCommand(withHook(app.NewDiffCmd(), withConfig))
+	rootCmd.AddCommand(withHook(app.NewDriftCmd(), withConfig))
+	rootCmd.AddCommand(withHook(app.NewExportMetadataCmd(cfg), withConfig))
+	rootCmd.AddCommand(withHook(app.NewSBOMCmd(), withConfig))
+	rootCmd.AddCommand(app.NewValidateCmd())
+	rootCmd.AddCommand(app.NewInitCmd())
+	rootCmd.AddCommand(app.NewVersionCmd(cfg))
+	rootCmd.AddCommand(app.NewDocsCmd())
+	rootCmd.AddCommand(withHook(app.NewTestFixturesCmd(cfg), withConfig))
+	rootCmd.AddCommand(app.NewPluginCmd())
+	rootCmd.AddCommand(withHook(app.NewOperatorCmd(cfg), withRun))
+	rootCmd.AddCommand(withHook(app.NewActionCmd(cfg, rec.Report), withRun))
+	ctx := logging.WithAttrs(context.Background(), "run", logging.NewRunID())
+	ctx = fsutil.WithBackups(ctx, cfg.Backup())
+	ctx = license.WithCheck(ctx, cfg.CheckLicenses())
+	runCtx, stop := cancelOnSignal(ctx)
+	err = rootCmd.ExecuteContext(runCtx)
+	stop()
+	checkEndOfLife(ctx, run.EndOfLife, rec.Report())
+	report := rec.Report()
+	report.Err = err
+	if err != nil {
+		slog.ErrorContext(ctx, "command execution failed", "err", err)
+	}
+	publish(ctx, report, cfg.SARIF(), run)
+	if err != nil {
 		os.Exit(1)
 	}
 }
+
+// withHook sets the persistent pre-run hook of a command and returns it.
+func withHook(cmd *cobra.Command, hook func(*cobra.Command, []string) error) *cobra.Command {
+	cmd.PersistentPreRunE = hook
+	return cmd
+}
+
+// withCredentials returns a context authenticating Helm repositories and
+// container registries with the credentials of the config. Those kept in
+// secret managers, like the GitHub token, are only fetched once the
+// repository, registry or API they authenticate is first called.
+func withCredentials(ctx context.Context, cfg *config.Config) (context.Context, error) {
+	if _, err := cfg.GitHubTokenFrom(); err != nil {
+		return nil, err
+	}
+	repos, err := cfg.HelmRepositories()
+	if err != nil {
+		return nil, err
+	}
+	creds, err := cfg.RegistryCredentials()
+	if err != nil {
+		return nil, err
+	}
+	return container.WithCredentials(helm.WithRepositories(ctx, repos), creds), nil
+}
+
+// cancelOnSignal returns a context canceled on SIGINT or SIGTERM, so commands
+// stop looking up versions, finish their in-flight writes and return, letting
+// the partial report of the run be published. A second signal terminates the
+// process. The returned function stops listening for signals.
+func cancelOnSignal(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig, ok := <-sigs
+		if !ok {
+			return
+		}
+		signal.Stop(sigs)
+		slog.WarnContext(ctx, "interrupted, finishing in-flight writes", "signal", sig)
+		cancel()
+	}()
+	return ctx, func() {
+		signal.Stop(sigs)
+		close(sigs)
+		cancel()
+	}
+}
+
+// checkEndOfLife warns about the tracked dependencies of a run pinned to a
+// release cycle past its end of life.
+func checkEndOfLife(ctx context.Context, eol *endoflife.Checker, report notify.Report) {
+	if eol == nil {
+		return
+	}
+	for _, d := range report.Dependencies() {
+		if err := eol.Check(ctx, d.Attrs["name"], d.Attrs["version"]); err != nil {
+			slog.ErrorContext(ctx, "failed to check end of life", "name", d.Attrs["name"], "err", err)
+		}
+	}
+}
+
+// publish writes the SARIF log and the GitHub Actions job summary of a run
+// and, for the commands updating dependencies, the held majors report,
+// refreshes the dashboard and sends the notifications.
+func publish(ctx context.Context, report notify.Report, sarif string, run app.Run) {
+	if run.Hold.Enabled && run.Hold.Report != "" {
+		if err := notify.WriteEvents(run.Hold.Report, report.PendingMajors); err != nil {
+			slog.ErrorContext(ctx, "failed to write pending majors", "err", err)
+		}
+	}
+	if sarif != "" {
+		if err := notify.WriteSARIF(sarif, report); err != nil {
+			slog.ErrorContext(ctx, "failed to write sarif log", "err", err)
+		}
+	}
+	if summary := os.Getenv("GITHUB_STEP_SUMMARY"); summary != "" {
+		if err := notify.WriteStepSummary(summary, report); err != nil {
+			slog.ErrorContext(ctx, "failed to write job summary", "err", err)
+		}
+	}
+	if run.Dashboard != nil && (len(report.Tracked) > 0 || !report.Empty()) {
+		if err := run.Dashboard.Notify(ctx, report); err != nil {
+			slog.ErrorContext(ctx, "failed to update dependency dashboard", "err", err)
+		}
+	}
+	if !report.Empty() {
+		if err := notify.NotifyAll(ctx, run.Notifiers, report); err != nil {
+			slog.ErrorContext(ctx, "failed to send notifications", "err", err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Package main is the kubectl-automata plugin, run by kubectl as
+// "kubectl automata" against the current kubeconfig context to compare the
+// images running in a cluster with their registries.
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shikanime-studio/automata/cmd/automata/app"
+	"github.com/shikanime-studio/automata/internal/buildinfo"
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/retry"
+)
+
+// main wires the plugin commands and executes them.
+func main() {
+	cfg, err := config.New()
+	if err != nil {
+		slog.Error("failed to initialize config", "err", err)
+		os.Exit(1)
+	}
+	opts := &slog.HandlerOptions{Level: max(cfg.LogLevel(), slog.LevelWarn)}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, opts)))
+	retries, err := cfg.Retry()
+	if err != nil {
+		slog.Error("failed to initialize retries", "err", err)
+		os.Exit(1)
+	}
+	rootCmd := &cobra.Command{
+		Use:     "kubectl-automata",
+		Short:   "Compare the images running in a cluster with their registries",
+		Version: buildinfo.Get().Version,
+		Annotations: map[string]string{
+			cobra.CommandDisplayNameAnnotation: "kubectl automata",
+		},
+	}
+	rootCmd.CompletionOptions.HiddenDefaultCmd = true
+	rootCmd.AddCommand(app.NewOutdatedCmd())
+	ctx := logging.WithAttrs(context.Background(), "run", logging.NewRunID())
+	ctx = retry.WithPolicy(ctx, retry.NewPolicy(retries))
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		os.Exit(1)
+	}
+}
@@ -82,12 +82,28 @@
             devlib.devenvModules.shikanime-studio
           ];
 
-          packages.default = pkgs.buildGoModule {
+          packages.default = pkgs.buildGoModule rec {
             pname = "automata";
             version = "v0.1.0";
             src = lib.cleanSource ./.;
-            subPackages = [ "cmd/automata" ];
+            subPackages = [
+              "cmd/automata"
+              "cmd/kubectl-automata"
+            ];
             vendorHash = null;
+            ldflags = [
+              "-X github.com/shikanime-studio/automata/internal/buildinfo.Version=${version}"
+              "-X github.com/shikanime-studio/automata/internal/buildinfo.Commit=${inputs.self.shortRev or inputs.self.dirtyShortRev or ""}"
+            ];
+            nativeBuildInputs = [ pkgs.installShellFiles ];
+            postInstall = lib.optionalString (pkgs.stdenv.buildPlatform.canExecute pkgs.stdenv.hostPlatform) ''
+              installShellCompletion --cmd automata \
+                --bash <($out/bin/automata completion bash) \
+                --fish <($out/bin/automata completion fish) \
+                --zsh <($out/bin/automata completion zsh)
+              $out/bin/automata docs man man
+              installManPage man/*.1
+            '';
             meta = {
               description = "Automata CLI";
               homepage = "https://github.com/shikanime-studio/automata";
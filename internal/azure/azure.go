@@ -0,0 +1,96 @@
+// Package azure resolves Azure Pipelines task versions from the upstream
+// azure-pipelines-tasks repository.
+package azure
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TaskRef represents an Azure Pipelines task reference "Name@major".
+type TaskRef struct {
+	Name    string
+	Version string
+}
+
+// String returns the "Name@major" form of the task reference.
+func (t TaskRef) String() string {
+	return fmt.Sprintf("%s@%s", t.Name, t.Version)
+}
+
+// ParseTaskRef parses a task reference like "NodeTool@0".
+func ParseTaskRef(s string) (*TaskRef, error) {
+	name, version, ok := strings.Cut(strings.TrimSpace(s), "@")
+	if !ok || name == "" || version == "" {
+		return nil, fmt.Errorf("invalid task reference %q, expected <name>@<major>", s)
+	}
+	return &TaskRef{Name: name, Version: version}, nil
+}
+
+// DirectoryLister lists the entries of a repository directory.
+type DirectoryLister interface {
+	ListDirectory(ctx context.Context, owner, repo, path string) ([]string, error)
+}
+
+// Tasks indexes the major versions of the upstream tasks. Task sources live in
+// directories named "<Name>V<major>".
+type Tasks struct {
+	l    DirectoryLister
+	once sync.Once
+	dirs []string
+	err  error
+}
+
+// NewTasks creates a task index backed by the given lister. The upstream
+// directory is listed once, on first use.
+func NewTasks(l DirectoryLister) *Tasks {
+	return &Tasks{l: l}
+}
+
+// Majors returns the available major versions of the named task.
+func (t *Tasks) Majors(ctx context.Context, name string) ([]int, error) {
+	t.once.Do(func() {
+		t.dirs, t.err = t.l.ListDirectory(ctx, "microsoft", "azure-pipelines-tasks", "Tasks")
+	})
+	if t.err != nil {
+		return nil, fmt.Errorf("list tasks: %w", t.err)
+	}
+	var majors []int
+	for _, d := range t.dirs {
+		rest, ok := strings.CutPrefix(d, name+"V")
+		if !ok {
+			continue
+		}
+		m, err := strconv.Atoi(rest)
+		if err != nil {
+			continue
+		}
+		majors = append(majors, m)
+	}
+	return majors, nil
+}
+
+// FindLatestMajor returns the highest major version of the task, or its
+// current version when no greater one is found.
+func (t *Tasks) FindLatestMajor(ctx context.Context, task *TaskRef) (string, error) {
+	current, err := strconv.Atoi(task.Version)
+	if err != nil {
+		slog.DebugContext(ctx, "skip task with non-numeric version", "task", task.String())
+		return task.Version, nil
+	}
+	majors, err := t.Majors(ctx, task.Name)
+	if err != nil {
+		return "", err
+	}
+	best := current
+	for _, m := range majors {
+		if m > best {
+			best = m
+		}
+	}
+	return strconv.Itoa(best), nil
+}
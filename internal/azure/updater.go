@@ -0,0 +1,38 @@
+package azure
+
+import (
+	"context"
+
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+// Updater finds the latest major versions of Azure Pipelines tasks.
+type Updater struct {
+	tasks *Tasks
+}
+
+// NewUpdater constructs an Updater listing tasks with the given lister.
+func NewUpdater(l DirectoryLister) Updater {
+	return Updater{tasks: NewTasks(l)}
+}
+
+// Update returns the latest major version for the given task reference.
+func (u Updater) Update(
+	ctx context.Context,
+	task *TaskRef,
+	_ ...updater.Option,
+) (string, error) {
+	ctx = logging.WithAttrs(logging.WithSubsystem(ctx, "azure"), "dependency", task.Name)
+	latest, err := u.tasks.FindLatestMajor(ctx, task)
+	if err != nil {
+		return "", err
+	}
+	return policy.Apply(ctx, policy.Proposal{
+		Source: "azure",
+		Name:   task.Name,
+		From:   task.Version,
+		To:     latest,
+	})
+}
@@ -0,0 +1,110 @@
+// Package buildinfo describes the running build of automata and checks
+// whether a newer release exists.
+package buildinfo
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+// Build metadata, set at link time with
+// -ldflags "-X github.com/shikanime-studio/automata/internal/buildinfo.Version=v1.2.3".
+// Unset values fall back to the module and VCS information embedded by the
+// Go toolchain.
+var (
+	Version = ""
+	Commit  = ""
+	Date    = ""
+)
+
+// Repository is the GitHub repository automata is released from.
+var Repository = github.ActionRef{Owner: "shikanime-studio", Repo: "automata"}
+
+// Info describes a build.
+type Info struct {
+	Version   string
+	Commit    string
+	Date      string
+	GoVersion string
+	Platform  string
+}
+
+// Get returns the information of the running build.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.Date == "":
+				info.Date = s.Value
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
+
+// String renders the build information on a single line.
+func (i Info) String() string {
+	s := "automata " + i.Version
+	if i.Commit != "" {
+		s += " (" + i.Commit
+		if i.Date != "" {
+			s += ", " + i.Date
+		}
+		s += ")"
+	}
+	return s + " " + i.GoVersion + " " + i.Platform
+}
+
+// pseudoVersion matches the versions the Go toolchain stamps on builds of
+// untagged commits, e.g. v0.0.0-20240501120000-abcdef123456+dirty.
+var pseudoVersion = regexp.MustCompile(`-(0\.)?\d{14}-[0-9a-f]{12}(\+dirty)?$`)
+
+// Development reports whether the version is a development build rather than
+// a release.
+func Development(version string) bool {
+	return version == "dev" || pseudoVersion.MatchString(version)
+}
+
+// Check returns the latest release of automata and whether it is newer than
+// the given version, comparing them as updates compare tags. Development
+// builds have no version to compare and are never reported as outdated.
+func Check(ctx context.Context, c *github.Client, version string) (string, bool, error) {
+	ref := Repository
+	ref.Version = version
+	if Development(version) {
+		ref.Version = "latest"
+	}
+	latest, err := c.FindLatestActionTag(ctx, &ref)
+	if err != nil {
+		return "", false, fmt.Errorf("find latest release: %w", err)
+	}
+	if Development(version) {
+		return latest, false, nil
+	}
+	cmp, err := updater.Compare(version, latest)
+	if err != nil {
+		return "", false, fmt.Errorf("compare %s with %s: %w", version, latest, err)
+	}
+	return latest, cmp == updater.Greater, nil
+}
@@ -0,0 +1,37 @@
+package buildinfo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetPrefersLinkTimeValues(t *testing.T) {
+	t.Cleanup(func() { Version, Commit, Date = "", "", "" })
+	Version, Commit, Date = "v1.2.3", "abc1234", "2024-05-01T00:00:00Z"
+	info := Get()
+	if info.Version != "v1.2.3" || info.Commit != "abc1234" || info.Date != "2024-05-01T00:00:00Z" {
+		t.Fatalf("unexpected info %+v", info)
+	}
+	if s := info.String(); !strings.HasPrefix(s, "automata v1.2.3 (abc1234, 2024-05-01T00:00:00Z) go") {
+		t.Fatalf("unexpected string %q", s)
+	}
+}
+
+func TestGetDefaultsToDev(t *testing.T) {
+	if info := Get(); info.Version == "" {
+		t.Fatalf("expected a version, got %+v", info)
+	}
+}
+
+func TestDevelopment(t *testing.T) {
+	for v, want := range map[string]bool{
+		"dev":                                true,
+		"v0.0.0-20240501120000-abcdef123456": true,
+		"v0.1.1-0.20240501120000-abcdef123456+dirty": true,
+		"v0.1.0": false,
+	} {
+		if got := Development(v); got != want {
+			t.Fatalf("Development(%q) = %v, want %v", v, got, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package changelog maintains a Markdown changelog of the dependency updates
+// applied to a repository, an auditable history kept next to the code.
+package changelog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/fsutil"
+	"github.com/shikanime-studio/automata/internal/policy"
+)
+
+// title heads a new changelog.
+const title = "# Dependency Changelog\n"
+
+// Entry renders the changelog line of an applied update, linking the new
+// version to its release page when its source has one.
+func Entry(p policy.Proposal) string {
+	to := "`" + p.To + "`"
+	if link := policy.ReleaseLink(p.Source, p.Name, p.To); link != "" {
+		to = fmt.Sprintf("[`%s`](%s)", p.To, link)
+	}
+	return fmt.Sprintf("- %s `%s`: `%s` → %s\n", p.Source, p.Name, p.From, to)
+}
+
+// Render adds the entries of the applied updates to the changelog content
+// under a section of the given date, newest first. Entries join the section
+// of the date when it is the latest one.
+func Render(content []byte, date time.Time, updates []policy.Proposal) []byte {
+	if len(updates) == 0 {
+		return content
+	}
+	var entries strings.Builder
+	for _, p := range updates {
+		entries.WriteString(Entry(p))
+	}
+	heading := "## " + date.Format(time.DateOnly) + "\n"
+	s := string(content)
+	if strings.TrimSpace(s) == "" {
+		s = title
+	}
+	i := strings.Index(s, "\n## ")
+	if i < 0 {
+		return []byte(strings.TrimRight(s, "\n") + "\n\n" + heading + "\n" + entries.String())
+	}
+	head, rest := s[:i+1], s[i+1:]
+	if strings.HasPrefix(rest, heading) {
+		section, after, found := strings.Cut(rest, "\n## ")
+		out := head + strings.TrimRight(section, "\n") + "\n" + entries.String()
+		if found {
+			out += "\n## " + after
+		}
+		return []byte(out)
+	}
+	return []byte(head + heading + "\n" + entries.String() + "\n" + rest)
+}
+
+// Append adds the entries of the applied updates to the changelog at path,
+// creating it when missing. The changelog is rewritten atomically, keeping a
+// backup when the context asks to.
+func Append(ctx context.Context, path string, date time.Time, updates []policy.Proposal) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	out := Render(content, date, updates)
+	if bytes.Equal(out, content) {
+		return nil
+	}
+	return fsutil.WriteFile(path, out, 0o644, fsutil.Backups(ctx))
+}
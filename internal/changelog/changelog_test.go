@@ -0,0 +1,46 @@
+package changelog
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/policy"
+)
+
+func TestAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "CHANGELOG-deps.md")
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 9, 0, 0, 0, time.UTC) }
+	checkout := policy.Proposal{Source: "github", Name: "actions/checkout", From: "v4", To: "v5"}
+	redis := policy.Proposal{Source: "image", Name: "registry.example.com/redis", From: "7.0", To: "7.2"}
+	nginx := policy.Proposal{Source: "image", Name: "nginx", From: "1.27.0", To: "1.27.1"}
+	steps := []struct {
+		date    time.Time
+		updates []policy.Proposal
+	}{
+		{day(14), []policy.Proposal{checkout}},
+		{day(16), []policy.Proposal{redis}},
+		{day(16), []policy.Proposal{nginx}},
+		{day(16), nil},
+	}
+	for _, s := range steps {
+		if err := Append(context.Background(), path, s.date, s.updates); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Dependency Changelog\n" +
+		"\n## 2026-10-16\n\n" +
+		"- image `registry.example.com/redis`: `7.0` → `7.2`\n" +
+		"- image `nginx`: `1.27.0` → [`1.27.1`](https://hub.docker.com/_/nginx/tags?name=1.27.1)\n" +
+		"\n## 2026-10-14\n\n" +
+		"- github `actions/checkout`: `v4` → [`v5`](https://github.com/actions/checkout/releases/tag/v5)\n"
+	if string(got) != want {
+		t.Fatalf("unexpected changelog:\n%s\nwant:\n%s", got, want)
+	}
+}
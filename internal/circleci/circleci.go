@@ -0,0 +1,143 @@
+// Package circleci provides helpers to parse CircleCI orb references and
+// resolve their versions from the orb registry.
+package circleci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+// RegistryURL is the GraphQL endpoint of the CircleCI orb registry.
+const RegistryURL = "https://circleci.com/graphql-unstable"
+
+// OrbRef represents an orb reference "namespace/name@version".
+type OrbRef struct {
+	Namespace string
+	Name      string
+	Version   string
+}
+
+// String returns the "namespace/name@version" form of the orb reference.
+func (o OrbRef) String() string {
+	return fmt.Sprintf("%s/%s@%s", o.Namespace, o.Name, o.Version)
+}
+
+// ParseOrbRef parses an orb reference like "circleci/node@5.1.0".
+func ParseOrbRef(s string) (*OrbRef, error) {
+	path, version, ok := strings.Cut(strings.TrimSpace(s), "@")
+	if !ok || version == "" {
+		return nil, fmt.Errorf("invalid orb %q, expected <namespace>/<name>@<version>", s)
+	}
+	ns, name, ok := strings.Cut(path, "/")
+	if !ok || ns == "" || name == "" {
+		return nil, fmt.Errorf("invalid orb %q, expected <namespace>/<name>@<version>", s)
+	}
+	return &OrbRef{Namespace: ns, Name: name, Version: version}, nil
+}
+
+// ListVersions returns the published versions of the orb.
+func ListVersions(ctx context.Context, orb *OrbRef) ([]string, error) {
+	body, err := json.Marshal(map[string]any{
+		"query": `query($name: String!) { orb(name: $name) { versions(count: 200) { version } } }`,
+		"variables": map[string]string{
+			"name": orb.Namespace + "/" + orb.Name,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal orb query: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, RegistryURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create orb request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("query orb registry: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.WarnContext(ctx, "close orb registry response", "err", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("query orb registry: unexpected status %s", resp.Status)
+	}
+	var out struct {
+		Data struct {
+			Orb *struct {
+				Versions []struct {
+					Version string `json:"version"`
+				} `json:"versions"`
+			} `json:"orb"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode orb registry response: %w", err)
+	}
+	if out.Data.Orb == nil {
+		return nil, fmt.Errorf("orb %s/%s not found", orb.Namespace, orb.Name)
+	}
+	vers := make([]string, 0, len(out.Data.Orb.Versions))
+	for _, v := range out.Data.Orb.Versions {
+		vers = append(vers, v.Version)
+	}
+	return vers, nil
+}
+
+type findLatestOptions struct {
+	excludes      map[string]struct{}
+	updateOptions []updater.Option
+}
+
+// FindLatestOption configures the search for the latest orb version.
+type FindLatestOption func(*findLatestOptions)
+
+// WithExcludes specifies a set of versions to exclude from consideration.
+func WithExcludes(excludes map[string]struct{}) FindLatestOption {
+	return func(o *findLatestOptions) {
+		o.excludes = excludes
+	}
+}
+
+// WithUpdateOptions specifies options to use for version comparison.
+func WithUpdateOptions(opts ...updater.Option) FindLatestOption {
+	return func(o *findLatestOptions) {
+		o.updateOptions = opts
+	}
+}
+
+func makeFindLatestOptions(opts ...FindLatestOption) findLatestOptions {
+	o := findLatestOptions{
+		excludes: make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// FindLatestVersion returns the latest version of the orb based on the
+// provided options.
+func FindLatestVersion(ctx context.Context, orb *OrbRef, opts ...FindLatestOption) (string, error) {
+	o := makeFindLatestOptions(opts...)
+	vers, err := ListVersions(ctx, orb)
+	if err != nil {
+		return "", err
+	}
+	return updater.SelectLatest(
+		ctx,
+		orb.Version,
+		vers,
+		updater.WithExcludes(o.excludes),
+		updater.WithCompareOptions(o.updateOptions...),
+		updater.WithLogAttrs("orb", orb.String()),
+	)
+}
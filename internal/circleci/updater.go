@@ -0,0 +1,49 @@
+package circleci
+
+import (
+	"context"
+	"slices"
+
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// Updater finds the latest versions of CircleCI orbs.
+type Updater struct {
+	opts []FindLatestOption
+}
+
+// NewUpdater constructs an Updater with optional find-latest options.
+func NewUpdater(opts ...FindLatestOption) Updater {
+	return Updater{
+		opts: opts,
+	}
+}
+
+// Update returns the latest version for the given orb reference.
+func (u Updater) Update(
+	ctx context.Context,
+	orb *OrbRef,
+	opts ...update.Option,
+) (string, error) {
+	ctx = logging.WithAttrs(logging.WithSubsystem(ctx, "orb"), "dependency", orb.Namespace+"/"+orb.Name)
+	find := func(ctx context.Context, extra ...update.Option) (string, error) {
+		return FindLatestVersion(
+			ctx,
+			orb,
+			append(slices.Clone(u.opts), WithUpdateOptions(slices.Concat(opts, extra)...))...,
+		)
+	}
+	latest, err := find(ctx)
+	if err != nil {
+		return "", err
+	}
+	return policy.Apply(ctx, policy.Proposal{
+		Source:   "orb",
+		Name:     orb.Namespace + "/" + orb.Name,
+		From:     orb.Version,
+		To:       latest,
+		Reselect: find,
+	})
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/schema"
+)
+
+// sections maps the keys of the config file to a new value of the
+// declaration they decode into.
+var sections = map[string]func() any{
+	"log_level":            func() any { return new(string) },
+	"log_format":           func() any { return new(string) },
+	"log_source":           func() any { return new(bool) },
+	"github_token":         func() any { return new(string) },
+	"github-token-from":    func() any { return new(SecretRef) },
+	"backup":               func() any { return new(bool) },
+	"automerge":            func() any { return new(AutoMerge) },
+	"check-licenses":       func() any { return new(bool) },
+	"changelog":            func() any { return new(string) },
+	"sarif":                func() any { return new(string) },
+	"rules":                func() any { return new([]Rule) },
+	"workflow-inputs":      func() any { return new(map[string]string) },
+	"workflow-actions":     func() any { return new([]WorkflowAction) },
+	"tool-versions":        func() any { return new([]ToolVersion) },
+	"jsonnet":              func() any { return new([]JsonnetRule) },
+	"opa-bundles":          func() any { return new([]OPABundle) },
+	"rancher":              func() any { return new(Rancher) },
+	"regex":                func() any { return new([]Rule) },
+	"docs":                 func() any { return new([]Rule) },
+	"signing":              func() any { return new(Signing) },
+	"nix":                  func() any { return new([]Rule) },
+	"nixpkgs":              func() any { return new(Nixpkgs) },
+	"notifications":        func() any { return new([]Notification) },
+	"policies":             func() any { return new([]Policy) },
+	"schedule":             func() any { return new(Schedule) },
+	"scripts":              func() any { return new(Scripts) },
+	"hold-majors":          func() any { return new(MajorHold) },
+	"dashboard":            func() any { return new(Dashboard) },
+	"endoflife":            func() any { return new([]EndOfLife) },
+	"k0s-platforms":        func() any { return new([]K0sPlatform) },
+	"kubernetes":           func() any { return new(Kubernetes) },
+	"app-versions":         func() any { return new([]AppVersion) },
+	"retry":                func() any { return new(Retry) },
+	"helm-repositories":    func() any { return new([]HelmRepository) },
+	"registry-credentials": func() any { return new([]RegistryCredential) },
+	"hooks":                func() any { return new(Hooks) },
+	"templates":            func() any { return new(Templates) },
+	"timeouts":             func() any { return new(Timeouts) },
+	"validate":             func() any { return new(Validation) },
+}
+
+// File returns the path of the config file in use, relative to the working
+// directory when under it, empty when none was found.
+func (c *Config) File() string {
+	file := c.v.ConfigFileUsed()
+	if wd, err := os.Getwd(); err == nil && filepath.IsAbs(file) {
+		if rel, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return file
+}
+
+// Check validates the config file: every key must be known and every section
+// must decode into its declaration without unknown fields. Errors are
+// prefixed with the file and line of the offending key.
+func (c *Config) Check() error {
+	file := c.File()
+	if file == "" {
+		return nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	root, err := yaml.Parse(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	known := make([]string, 0, len(sections))
+	for key := range sections {
+		known = append(known, key)
+	}
+	sort.Strings(known)
+	var errs []error
+	err = root.VisitFields(func(field *yaml.MapNode) error {
+		key := field.Key.YNode().Value
+		line := field.Key.YNode().Line
+		decl, ok := sections[key]
+		if !ok {
+			msg := fmt.Sprintf("unknown key %q", key)
+			if near := schema.Nearest(key, known); near != "" {
+				msg += fmt.Sprintf(", did you mean %q?", near)
+			}
+			errs = append(errs, fmt.Errorf("%s:%d: %s", file, line, msg))
+			return nil
+		}
+		if err := c.v.UnmarshalKey(key, decl(), func(dc *mapstructure.DecoderConfig) {
+			dc.ErrorUnused = true
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %s: %s", file, line, key, decodeError(err)))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return errors.Join(errs...)
+}
+
+// decodeError flattens the multi-line report of mapstructure to its causes,
+// naming the section root instead of an empty field.
+func decodeError(err error) string {
+	var causes []string
+	for _, line := range strings.Split(err.Error(), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "decoding failed") {
+			continue
+		}
+		causes = append(causes, strings.TrimPrefix(line, "'' "))
+	}
+	return strings.Join(causes, "; ")
+}
@@ -2,7 +2,12 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -10,11 +15,51 @@ import (
 // Config wraps application configuration and environment bindings.
 type Config struct{ v *viper.Viper }
 
-// New constructs a new Config with defaults and environment bindings.
+// Files are the names of the config file, by precedence.
+var Files = []string{".automata.yaml", ".automata.yml", "automata.yaml", "automata.yml"}
+
+// Find returns the path of the config file of dir, or "" when it has none.
+func Find(dir string) string {
+	for _, name := range Files {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// New constructs a new Config with defaults, environment bindings and the
+// optional .automata.yaml, or automata.yaml, file found in the working
+// directory.
 func New() (*Config, error) {
+	return Load(".")
+}
+
+// Load is New for the config file found in dir.
+func Load(dir string) (*Config, error) {
 	v := viper.New()
 	v.AutomaticEnv()
 
+	v.SetConfigName("automata")
+	v.SetConfigType("yaml")
+	v.AddConfigPath(dir)
+	if path := Find(dir); path != "" {
+		v.SetConfigFile(path)
+	}
+	if err := v.BindEnv("config", "AUTOMATA_CONFIG"); err != nil {
+		return nil, err
+	}
+	if p := v.GetString("config"); p != "" {
+		v.SetConfigFile(p)
+	}
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("read config: %w", err)
+		}
+	}
+
 	v.SetDefault("log_level", "info")
 	v.SetDefault("log_format", "text")
 	v.SetDefault("log_source", false)
@@ -71,7 +116,752 @@ func (c *Config) LogSource() bool {
 	return c.v.GetBool("log_source")
 }
 
+// SecretRef points to a secret kept in an external secret manager, fetched
+// at runtime through the CLI of its provider instead of being stored in plain
+// text.
+type SecretRef struct {
+	// Provider is vault, aws, gcp or exec.
+	Provider string `mapstructure:"provider"`
+	// Path is the path of a Vault KV secret, e.g. secret/ci.
+	Path string `mapstructure:"path"`
+	// Field is the field of the Vault secret, value by default.
+	Field string `mapstructure:"field"`
+	// Name is the ID of an AWS secret or the name of a GCP secret.
+	Name string `mapstructure:"name"`
+	// Region is the AWS region of the secret, that of the AWS config by
+	// default.
+	Region string `mapstructure:"region"`
+	// Project is the GCP project of the secret, that of the gcloud config
+	// by default.
+	Project string `mapstructure:"project"`
+	// Version is the GCP secret version, latest by default.
+	Version string `mapstructure:"version"`
+	// Command is the credential helper run by the exec provider, printing
+	// the secret on its standard output.
+	Command []string `mapstructure:"command"`
+	// Key extracts a key of a secret holding a JSON object.
+	Key string `mapstructure:"key"`
+}
+
+// GitHubTokenFrom returns the secret declared under github-token-from in the
+// config file, fetched when no GitHub token is set, or nil.
+func (c *Config) GitHubTokenFrom() (*SecretRef, error) {
+	if !c.v.IsSet("github-token-from") {
+		return nil, nil
+	}
+	var ref SecretRef
+	if err := c.v.UnmarshalKey("github-token-from", &ref); err != nil {
+		return nil, fmt.Errorf("unmarshal github-token-from: %w", err)
+	}
+	return &ref, nil
+}
+
 // GitHubToken returns the GitHub token from config.
 func (c *Config) GitHubToken() string {
 	return c.v.GetString("github_token")
 }
+
+// SetGitHubToken overrides the GitHub token from config.
+func (c *Config) SetGitHubToken(token string) {
+	c.v.Set("github_token", token)
+}
+
+// Backup reports whether files rewritten by updates keep a copy of their
+// previous content, declared under backup in the config file.
+func (c *Config) Backup() bool {
+	return c.v.GetBool("backup")
+}
+
+// CheckLicenses reports whether updates of GitHub-sourced dependencies and
+// images compare the licenses of the current and the new version, declared
+// under check-licenses in the config file.
+func (c *Config) CheckLicenses() bool {
+	return c.v.GetBool("check-licenses")
+}
+
+// Changelog returns the path, relative to the updated repository, of the
+// Markdown changelog the applied updates are appended to, declared under
+// changelog in the config file. No changelog is kept when it is empty.
+func (c *Config) Changelog() string {
+	return c.v.GetString("changelog")
+}
+
+// SARIF returns the path the outdated dependencies of a run are written to as
+// a SARIF log, declared under sarif in the config file.
+func (c *Config) SARIF() string {
+	return c.v.GetString("sarif")
+}
+
+// Rule declares a value to update in arbitrary YAML files.
+type Rule struct {
+	// Files is a glob matched against file paths relative to the target
+	// directory, or against their base name.
+	Files string `mapstructure:"files"`
+	// Path is the dot-separated YAML path of the value, e.g. spec.version.
+	Path string `mapstructure:"path"`
+	// Source selects where versions are resolved: image, github, helm, git,
+	// or the name of a source plugin.
+	Source string `mapstructure:"source"`
+	// Image is the image name for the image source.
+	Image string `mapstructure:"image"`
+	// Repository is the owner/repo for the github source, the repository URL
+	// for the helm and git sources.
+	Repository string `mapstructure:"repository"`
+	// Chart is the chart name for the helm source.
+	Chart string `mapstructure:"chart"`
+	// Regex extracts the version from the current value through its
+	// "version" named group; the whole value is used when unset.
+	Regex string `mapstructure:"regex"`
+	// Ordering ranks versions: semver (default), numeric for build numbers
+	// or lexical for plain dates.
+	Ordering string `mapstructure:"ordering"`
+}
+
+// Rules returns the custom update rules declared in the config file.
+func (c *Config) Rules() ([]Rule, error) {
+	var rules []Rule
+	if err := c.v.UnmarshalKey("rules", &rules); err != nil {
+		return nil, fmt.Errorf("unmarshal rules: %w", err)
+	}
+	return rules, nil
+}
+
+// WorkflowInputs returns the setup action inputs to bump in workflows, mapped
+// to the name of the tool whose version they pin.
+func (c *Config) WorkflowInputs() map[string]string {
+	return c.v.GetStringMapString("workflow-inputs")
+}
+
+// WorkflowAction declares how the tags of a GitHub action used by workflows
+// are selected.
+type WorkflowAction struct {
+	// Name is the action as owner/repo; the declaration without one applies
+	// to the actions no other declaration names.
+	Name string `mapstructure:"name"`
+	// TagRegex extracts the version from tags through named groups, as the
+	// tag-regex of the images annotation.
+	TagRegex string `mapstructure:"tag-regex"`
+	// ExcludeTags are tags never updated to.
+	ExcludeTags []string `mapstructure:"exclude-tags"`
+	// IncludePrereleases allows updates to prerelease tags.
+	IncludePrereleases bool `mapstructure:"include-prereleases"`
+}
+
+// WorkflowActions returns the action declarations under workflow-actions in
+// the config file.
+func (c *Config) WorkflowActions() ([]WorkflowAction, error) {
+	var actions []WorkflowAction
+	if err := c.v.UnmarshalKey("workflow-actions", &actions); err != nil {
+		return nil, fmt.Errorf("unmarshal workflow actions: %w", err)
+	}
+	return actions, nil
+}
+
+// ToolVersion declares how the versions of a tool pinned in .tool-versions
+// and mise configuration files are selected.
+type ToolVersion struct {
+	// Name is the asdf plugin or mise tool name.
+	Name string `mapstructure:"name"`
+	// Owner and Repo are the GitHub repository releasing the tool; they
+	// default to those of a known tool.
+	Owner string `mapstructure:"owner"`
+	Repo  string `mapstructure:"repo"`
+	// TagRegex extracts the version from tags through its version named
+	// group, and must also match the pinned versions.
+	TagRegex string `mapstructure:"tag-regex"`
+	// ExcludeTags are versions, or the tags of versions, never updated to.
+	ExcludeTags []string `mapstructure:"exclude-tags"`
+	// IncludePrereleases allows updates to prerelease tags.
+	IncludePrereleases bool `mapstructure:"include-prereleases"`
+	// UpdateStrategy is FullUpdate, MinorUpdate or PatchUpdate.
+	UpdateStrategy string `mapstructure:"update-strategy"`
+}
+
+// ToolVersions returns the tool declarations under tool-versions in the
+// config file.
+func (c *Config) ToolVersions() ([]ToolVersion, error) {
+	var tools []ToolVersion
+	if err := c.v.UnmarshalKey("tool-versions", &tools); err != nil {
+		return nil, fmt.Errorf("unmarshal tool versions: %w", err)
+	}
+	return tools, nil
+}
+
+// JsonnetRule declares a jsonnet version constant to update.
+type JsonnetRule struct {
+	Rule `mapstructure:",squash"`
+	// Constant is the name of the local constant holding the version. It is
+	// ignored when Regex is set.
+	Constant string `mapstructure:"constant"`
+}
+
+// JsonnetRules returns the jsonnet rules declared in the config file.
+func (c *Config) JsonnetRules() ([]JsonnetRule, error) {
+	var rules []JsonnetRule
+	if err := c.v.UnmarshalKey("jsonnet", &rules); err != nil {
+		return nil, fmt.Errorf("unmarshal jsonnet rules: %w", err)
+	}
+	return rules, nil
+}
+
+// OPABundle declares the source the revision of an OPA bundle follows. Its
+// Files and Path are ignored, and Regex, when set, extracts the revision from
+// the bundle resource.
+type OPABundle struct {
+	Rule `mapstructure:",squash"`
+	// Name is the name of the bundle under bundles in OPA configs.
+	Name string `mapstructure:"name"`
+}
+
+// OPABundles returns the OPA bundles declared under opa-bundles in the config
+// file.
+func (c *Config) OPABundles() ([]OPABundle, error) {
+	var bundles []OPABundle
+	if err := c.v.UnmarshalKey("opa-bundles", &bundles); err != nil {
+		return nil, fmt.Errorf("unmarshal opa bundles: %w", err)
+	}
+	return bundles, nil
+}
+
+// RegexRules returns the plain-text rules declared under regex in the config
+// file. Each rule requires files and a regex with a "version" group.
+func (c *Config) RegexRules() ([]Rule, error) {
+	var rules []Rule
+	if err := c.v.UnmarshalKey("regex", &rules); err != nil {
+		return nil, fmt.Errorf("unmarshal regex rules: %w", err)
+	}
+	return rules, nil
+}
+
+// NixRules returns the rules declared under nix in the config file, each
+// updating the string values assigned to the attribute path of its path in
+// Nix expressions.
+func (c *Config) NixRules() ([]Rule, error) {
+	var rules []Rule
+	if err := c.v.UnmarshalKey("nix", &rules); err != nil {
+		return nil, fmt.Errorf("unmarshal nix rules: %w", err)
+	}
+	return rules, nil
+}
+
+// DocsRules returns the rules declared under docs in the config file, applied
+// to fenced snippets and badges of Markdown files.
+func (c *Config) DocsRules() ([]Rule, error) {
+	var rules []Rule
+	if err := c.v.UnmarshalKey("docs", &rules); err != nil {
+		return nil, fmt.Errorf("unmarshal docs rules: %w", err)
+	}
+	return rules, nil
+}
+
+// Signing configures how automated commits are signed.
+type Signing struct {
+	// Format is "openpgp", "ssh", "x509" or "gitsign"; commits are unsigned
+	// when unset.
+	Format string `mapstructure:"format"`
+	// Key is the GPG key ID, or the SSH key path or public key.
+	Key string `mapstructure:"key"`
+	// Program overrides the signing program.
+	Program string `mapstructure:"program"`
+}
+
+// Signing returns the commit signing settings declared under signing in the
+// config file.
+func (c *Config) Signing() (Signing, error) {
+	var s Signing
+	if err := c.v.UnmarshalKey("signing", &s); err != nil {
+		return Signing{}, fmt.Errorf("unmarshal signing: %w", err)
+	}
+	return s, nil
+}
+
+// Notification declares where the report of a run is sent.
+type Notification struct {
+	// Type is the backend: slack, discord, matrix or smtp.
+	Type string `mapstructure:"type"`
+	// URL is the incoming webhook URL, or the homeserver URL for matrix.
+	URL string `mapstructure:"url"`
+	// Room is the matrix room ID.
+	Room string `mapstructure:"room"`
+	// Token is the matrix access token.
+	Token string `mapstructure:"token"`
+	// Template is a text/template rendering the report; a summary listing
+	// updates and failures is used when unset.
+	Template string `mapstructure:"template"`
+	// SMTP configures the smtp backend.
+	SMTP SMTP `mapstructure:"smtp"`
+}
+
+// SMTP configures email delivery of run reports.
+type SMTP struct {
+	// Host and Port address the mail server; Port defaults to 587.
+	Host string `mapstructure:"host"`
+	Port int    `mapstructure:"port"`
+	// Username and Password enable PLAIN authentication when set.
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// TLS is "starttls" (the default), "tls" for implicit TLS or "none".
+	TLS string `mapstructure:"tls"`
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile string `mapstructure:"ca-file"`
+	// From is the sender address and To the recipients.
+	From string   `mapstructure:"from"`
+	To   []string `mapstructure:"to"`
+	// Subject is the email subject.
+	Subject string `mapstructure:"subject"`
+}
+
+// Notifications returns the notifications declared in the config file, with
+// environment variables expanded in their URL, token and SMTP password.
+func (c *Config) Notifications() ([]Notification, error) {
+	var ns []Notification
+	if err := c.v.UnmarshalKey("notifications", &ns); err != nil {
+		return nil, fmt.Errorf("unmarshal notifications: %w", err)
+	}
+	for i := range ns {
+		ns[i].URL = os.ExpandEnv(ns[i].URL)
+		ns[i].Token = os.ExpandEnv(ns[i].Token)
+		ns[i].SMTP.Password = os.ExpandEnv(ns[i].SMTP.Password)
+	}
+	return ns, nil
+}
+
+// Policy declares an action taken on the updates matching a CEL expression
+// or a Rego query.
+type Policy struct {
+	// Expr is a CEL expression over source, name, from, to and file.
+	Expr string `mapstructure:"expr"`
+	// Rego is a Rego query over the same fields under input, evaluated by
+	// the opa CLI, instead of Expr.
+	Rego string `mapstructure:"rego"`
+	// Modules are the Rego files or directories the query may refer to.
+	Modules []string `mapstructure:"modules"`
+	// Action is approve, reject or manual.
+	Action string `mapstructure:"action"`
+}
+
+// Policies returns the policies declared in the config file, evaluated in
+// order against each proposed update.
+func (c *Config) Policies() ([]Policy, error) {
+	var ps []Policy
+	if err := c.v.UnmarshalKey("policies", &ps); err != nil {
+		return nil, fmt.Errorf("unmarshal policies: %w", err)
+	}
+	return ps, nil
+}
+
+// Schedule declares when updates may be written.
+type Schedule struct {
+	// Days are the allowed weekdays, e.g. saturday, or weekdays and weekends.
+	Days []string `mapstructure:"days"`
+	// Hours is the allowed "HH-HH" hour range, e.g. 22-06.
+	Hours string `mapstructure:"hours"`
+	// Freezes are date ranges during which nothing is written.
+	Freezes []Freeze `mapstructure:"freezes"`
+	// Timezone is the IANA zone of the schedule, UTC when unset.
+	Timezone string `mapstructure:"timezone"`
+}
+
+// Freeze is an inclusive YYYY-MM-DD date range; To defaults to From.
+type Freeze struct {
+	From string `mapstructure:"from"`
+	To   string `mapstructure:"to"`
+}
+
+// Schedule returns the update window declared under schedule in the config
+// file.
+func (c *Config) Schedule() (Schedule, error) {
+	var s Schedule
+	if err := c.v.UnmarshalKey("schedule", &s); err != nil {
+		return Schedule{}, fmt.Errorf("unmarshal schedule: %w", err)
+	}
+	return s, nil
+}
+
+// MajorHold declares that major-version bumps need an explicit opt-in.
+type MajorHold struct {
+	// Enabled holds major bumps instead of applying them.
+	Enabled bool `mapstructure:"enabled"`
+	// Allow lists the dependency names whose majors are applied anyway.
+	Allow []string `mapstructure:"allow"`
+	// Report is the file the held majors are written to as JSON.
+	Report string `mapstructure:"report"`
+}
+
+// MajorHold returns the major-version hold declared under hold-majors in the
+// config file.
+func (c *Config) MajorHold() (MajorHold, error) {
+	var h MajorHold
+	if err := c.v.UnmarshalKey("hold-majors", &h); err != nil {
+		return MajorHold{}, fmt.Errorf("unmarshal hold-majors: %w", err)
+	}
+	return h, nil
+}
+
+// AutoMerge declares the pull requests merged automatically once their
+// required checks pass.
+type AutoMerge struct {
+	// Method is the merge method, squash, rebase or merge; pull requests are
+	// never merged automatically when unset.
+	Method string `mapstructure:"method"`
+	// Updates are the update types merged automatically, patch by default.
+	// Major bumps always stay manual.
+	Updates []string `mapstructure:"updates"`
+	// Label is added to the pull requests set to merge automatically,
+	// "automerge" by default.
+	Label string `mapstructure:"label"`
+}
+
+// AutoMerge returns the auto-merge settings declared under automerge in the
+// config file.
+func (c *Config) AutoMerge() (AutoMerge, error) {
+	var a AutoMerge
+	if err := c.v.UnmarshalKey("automerge", &a); err != nil {
+		return AutoMerge{}, fmt.Errorf("unmarshal automerge: %w", err)
+	}
+	if len(a.Updates) == 0 {
+		a.Updates = []string{"patch"}
+	}
+	if a.Label == "" {
+		a.Label = "automerge"
+	}
+	return a, nil
+}
+
+// Templates declares the Go templates rendering how updates are published.
+// Empty templates keep the defaults.
+type Templates struct {
+	// CommitMessage renders the commit message.
+	CommitMessage string `mapstructure:"commit-message"`
+	// Branch renders the name of the branch the updates are pushed to.
+	Branch string `mapstructure:"branch"`
+	// Title renders the pull request title, the commit subject by default.
+	Title string `mapstructure:"title"`
+	// Body renders the pull request body.
+	Body string `mapstructure:"body"`
+}
+
+// Templates returns the templates declared under templates in the config
+// file.
+func (c *Config) Templates() (Templates, error) {
+	var t Templates
+	if err := c.v.UnmarshalKey("templates", &t); err != nil {
+		return Templates{}, fmt.Errorf("unmarshal templates: %w", err)
+	}
+	return t, nil
+}
+
+// Dashboard declares the GitHub issue summarizing each run.
+type Dashboard struct {
+	// Repository is the owner/repo the issue lives in; no dashboard is kept
+	// when unset.
+	Repository string `mapstructure:"repository"`
+	// Title identifies the issue, "Dependency Dashboard" by default.
+	Title string `mapstructure:"title"`
+	// Template is a text/template rendering the issue body.
+	Template string `mapstructure:"template"`
+}
+
+// Dashboard returns the dependency dashboard declared under dashboard in the
+// config file.
+func (c *Config) Dashboard() (Dashboard, error) {
+	var d Dashboard
+	if err := c.v.UnmarshalKey("dashboard", &d); err != nil {
+		return Dashboard{}, fmt.Errorf("unmarshal dashboard: %w", err)
+	}
+	return d, nil
+}
+
+// EndOfLife maps a dependency to its product on endoflife.date.
+type EndOfLife struct {
+	// Name is the dependency name, e.g. an image or chart name.
+	Name string `mapstructure:"name"`
+	// Product is the endoflife.date product, e.g. kubernetes or postgresql.
+	Product string `mapstructure:"product"`
+	// SuggestMajor suggests the latest supported release in the warning,
+	// even when the update strategy stays within the current major.
+	SuggestMajor bool `mapstructure:"suggest-major"`
+}
+
+// EndOfLife returns the products checked on endoflife.date, declared under
+// endoflife in the config file.
+func (c *Config) EndOfLife() ([]EndOfLife, error) {
+	var decls []EndOfLife
+	if err := c.v.UnmarshalKey("endoflife", &decls); err != nil {
+		return nil, fmt.Errorf("unmarshal endoflife: %w", err)
+	}
+	return decls, nil
+}
+
+// Kubernetes declares the cluster version Kubernetes components are kept
+// within the supported version skew of.
+type Kubernetes struct {
+	// Version is the cluster version, e.g. v1.30.2.
+	Version string `mapstructure:"version"`
+	// Cluster is a k0sctl configuration the version is read from when
+	// Version is unset.
+	Cluster string `mapstructure:"cluster"`
+	// Components are the dependency names versioned with Kubernetes, in
+	// addition to the built-in ones.
+	Components []string `mapstructure:"components"`
+}
+
+// Kubernetes returns the cluster declared under kubernetes in the config file.
+func (c *Config) Kubernetes() (Kubernetes, error) {
+	var k Kubernetes
+	if err := c.v.UnmarshalKey("kubernetes", &k); err != nil {
+		return Kubernetes{}, fmt.Errorf("unmarshal kubernetes: %w", err)
+	}
+	return k, nil
+}
+
+// AppVersion ties the image of a chart component to the chart application
+// version.
+type AppVersion struct {
+	// Chart is the chart name.
+	Chart string `mapstructure:"chart"`
+	// Image is the image name pinned for the component.
+	Image string `mapstructure:"image"`
+	// Fix rewrites mismatched image tags instead of reporting them.
+	Fix bool `mapstructure:"fix"`
+}
+
+// AppVersions returns the chart images declared under app-versions in the
+// config file.
+func (c *Config) AppVersions() ([]AppVersion, error) {
+	var decls []AppVersion
+	if err := c.v.UnmarshalKey("app-versions", &decls); err != nil {
+		return nil, fmt.Errorf("unmarshal app-versions: %w", err)
+	}
+	return decls, nil
+}
+
+// HelmRepository declares the credentials of the Helm repositories under a
+// URL.
+type HelmRepository struct {
+	// URL is the repository URL; it also applies to the URLs under it.
+	URL string `mapstructure:"url"`
+	// Username and Password authenticate with basic auth.
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// Token authenticates with a bearer token instead.
+	Token string `mapstructure:"token"`
+	// CAFile is a PEM bundle verifying the repository certificate.
+	CAFile string `mapstructure:"ca-file"`
+	// CertFile and KeyFile are a client certificate and its key.
+	CertFile string `mapstructure:"cert-file"`
+	KeyFile  string `mapstructure:"key-file"`
+	// InsecureSkipTLSVerify skips the verification of the repository
+	// certificate.
+	InsecureSkipTLSVerify bool `mapstructure:"insecure-skip-tls-verify"`
+	// PasswordFrom is the secret the password is fetched from, replacing
+	// Password.
+	PasswordFrom *SecretRef `mapstructure:"password-from"`
+	// TokenFrom is the secret the token is fetched from, replacing Token.
+	TokenFrom *SecretRef `mapstructure:"token-from"`
+}
+
+// HelmRepositories returns the Helm repository credentials declared under
+// helm-repositories in the config file, with environment variables expanded
+// in their username, password and token.
+func (c *Config) HelmRepositories() ([]HelmRepository, error) {
+	var repos []HelmRepository
+	if err := c.v.UnmarshalKey("helm-repositories", &repos); err != nil {
+		return nil, fmt.Errorf("unmarshal helm repositories: %w", err)
+	}
+	for i := range repos {
+		repos[i].Username = os.ExpandEnv(repos[i].Username)
+		repos[i].Password = os.ExpandEnv(repos[i].Password)
+		repos[i].Token = os.ExpandEnv(repos[i].Token)
+	}
+	return repos, nil
+}
+
+// RegistryCredential declares the credentials of a container registry,
+// tried before those of the docker config.
+type RegistryCredential struct {
+	// Registry is the registry host, e.g. ghcr.io.
+	Registry string `mapstructure:"registry"`
+	// Username and Password authenticate with basic auth.
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// PasswordFrom is the secret the password is fetched from, replacing
+	// Password.
+	PasswordFrom *SecretRef `mapstructure:"password-from"`
+}
+
+// RegistryCredentials returns the container registry credentials declared
+// under registry-credentials in the config file, with environment variables
+// expanded in their username and password.
+func (c *Config) RegistryCredentials() ([]RegistryCredential, error) {
+	var creds []RegistryCredential
+	if err := c.v.UnmarshalKey("registry-credentials", &creds); err != nil {
+		return nil, fmt.Errorf("unmarshal registry credentials: %w", err)
+	}
+	for i := range creds {
+		creds[i].Username = os.ExpandEnv(creds[i].Username)
+		creds[i].Password = os.ExpandEnv(creds[i].Password)
+	}
+	return creds, nil
+}
+
+// Retry declares how registry and API calls failing transiently are retried.
+type Retry struct {
+	// Attempts is the maximum number of calls, 1 disabling retries.
+	Attempts int `mapstructure:"attempts"`
+	// BaseDelay is the delay before the first retry, e.g. 500ms, doubled
+	// for each next one.
+	BaseDelay time.Duration `mapstructure:"base-delay"`
+	// MaxDelay caps the delay between attempts.
+	MaxDelay time.Duration `mapstructure:"max-delay"`
+	// Jitter randomizes each delay by up to this fraction of it.
+	Jitter *float64 `mapstructure:"jitter"`
+}
+
+// Retry returns the retry policy declared under retry in the config file.
+func (c *Config) Retry() (Retry, error) {
+	var r Retry
+	if err := c.v.UnmarshalKey("retry", &r); err != nil {
+		return Retry{}, fmt.Errorf("unmarshal retry: %w", err)
+	}
+	return r, nil
+}
+
+// Timeouts declares how long each kind of external call may take, e.g. 30s;
+// zero keeps the default.
+type Timeouts struct {
+	Registry   time.Duration `mapstructure:"registry"`
+	GitHub     time.Duration `mapstructure:"github"`
+	Helm       time.Duration `mapstructure:"helm"`
+	Git        time.Duration `mapstructure:"git"`
+	Nix        time.Duration `mapstructure:"nix"`
+	Script     time.Duration `mapstructure:"script"`
+	Plugin     time.Duration `mapstructure:"plugin"`
+	Kubernetes time.Duration `mapstructure:"kubernetes"`
+	Secret     time.Duration `mapstructure:"secret"`
+}
+
+// Timeouts returns the call timeouts declared under timeouts in the config
+// file.
+func (c *Config) Timeouts() (Timeouts, error) {
+	var t Timeouts
+	if err := c.v.UnmarshalKey("timeouts", &t); err != nil {
+		return Timeouts{}, fmt.Errorf("unmarshal timeouts: %w", err)
+	}
+	return t, nil
+}
+
+// Nixpkgs configures the nixpkgs pins of projects without flakes.
+type Nixpkgs struct {
+	// Channel is followed by fetchTarball calls of nixpkgs revisions, e.g.
+	// nixos-unstable. They are left alone when unset.
+	Channel string `mapstructure:"channel"`
+}
+
+// Nixpkgs returns the nixpkgs settings declared under nixpkgs in the config
+// file.
+func (c *Config) Nixpkgs() (Nixpkgs, error) {
+	var n Nixpkgs
+	if err := c.v.UnmarshalKey("nixpkgs", &n); err != nil {
+		return Nixpkgs{}, fmt.Errorf("unmarshal nixpkgs: %w", err)
+	}
+	return n, nil
+}
+
+// Hooks declares the shell commands run around each update command, from
+// the working directory with sh -c. A failing pre-update hook aborts the
+// update.
+type Hooks struct {
+	// PreUpdate run before the update.
+	PreUpdate []string `mapstructure:"pre-update"`
+	// PostUpdate run after the update succeeded.
+	PostUpdate []string `mapstructure:"post-update"`
+	// OnFailure run after the update or one of its hooks failed.
+	OnFailure []string `mapstructure:"on-failure"`
+}
+
+// Hooks returns the hooks declared under hooks in the config file.
+func (c *Config) Hooks() (Hooks, error) {
+	var h Hooks
+	if err := c.v.UnmarshalKey("hooks", &h); err != nil {
+		return Hooks{}, fmt.Errorf("unmarshal hooks: %w", err)
+	}
+	return h, nil
+}
+
+// Scripts configures how update.sh and update.d scripts are run.
+type Scripts struct {
+	// Interpreter runs the scripts, e.g. "bash -eu", the script path being
+	// appended to its arguments. Scripts are executed directly, through
+	// their shebang, when unset.
+	Interpreter string `mapstructure:"interpreter"`
+}
+
+// Scripts returns the script settings declared under scripts in the config
+// file.
+func (c *Config) Scripts() (Scripts, error) {
+	var s Scripts
+	if err := c.v.UnmarshalKey("scripts", &s); err != nil {
+		return Scripts{}, fmt.Errorf("unmarshal scripts: %w", err)
+	}
+	return s, nil
+}
+
+// Validation enables the checks of the files rewritten by updates, on top of
+// parsing them again. Files failing a check are reverted.
+type Validation struct {
+	// Kustomize builds rewritten kustomizations with kustomize.
+	Kustomize bool `mapstructure:"kustomize"`
+	// Workflows validates the structure of rewritten GitHub workflows.
+	Workflows bool `mapstructure:"workflows"`
+	// K0sctl validates rewritten k0sctl configurations.
+	K0sctl bool `mapstructure:"k0sctl"`
+}
+
+// Validation returns the checks declared under validate in the config file.
+func (c *Config) Validation() (Validation, error) {
+	var v Validation
+	if err := c.v.UnmarshalKey("validate", &v); err != nil {
+		return Validation{}, fmt.Errorf("unmarshal validate: %w", err)
+	}
+	return v, nil
+}
+
+// K0sPlatform declares a host platform supported by a range of k0s versions.
+type K0sPlatform struct {
+	// OS is the operating system declared by hosts, any when empty.
+	OS string `mapstructure:"os"`
+	// Arch is the architecture declared by hosts, any when empty.
+	Arch string `mapstructure:"arch"`
+	// Since is the first supporting k0s version, e.g. v1.30, any when empty.
+	Since string `mapstructure:"since"`
+	// Until is the first k0s version dropping support, none when empty.
+	Until string `mapstructure:"until"`
+}
+
+// K0sPlatforms returns the host platforms declared under k0s-platforms in
+// the config file.
+func (c *Config) K0sPlatforms() ([]K0sPlatform, error) {
+	var p []K0sPlatform
+	if err := c.v.UnmarshalKey("k0s-platforms", &p); err != nil {
+		return nil, fmt.Errorf("unmarshal k0s-platforms: %w", err)
+	}
+	return p, nil
+}
+
+// Rancher configures the k3s and RKE2 releases followed by update rancher.
+type Rancher struct {
+	// Channel is the release channel followed, e.g. stable, latest or
+	// v1.30, stable when unset.
+	Channel string `mapstructure:"channel"`
+}
+
+// Rancher returns the release settings declared under rancher in the config
+// file.
+func (c *Config) Rancher() (Rancher, error) {
+	var r Rancher
+	if err := c.v.UnmarshalKey("rancher", &r); err != nil {
+		return Rancher{}, fmt.Errorf("unmarshal rancher: %w", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,69 @@
+package container
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/secret"
+)
+
+type credentialsKey struct{}
+
+// WithCredentials returns a context authenticating the registries called
+// with it with the given credentials, before those of the docker config.
+// The secret of a credential is only fetched when its registry is called.
+func WithCredentials(ctx context.Context, creds []config.RegistryCredential) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, &credentials{
+		decls:    creds,
+		resolved: make(map[int]config.RegistryCredential),
+	})
+}
+
+// keychain returns the keychain of the registries called with the context:
+// its credentials, then those of the docker config.
+func keychain(ctx context.Context) authn.Keychain {
+	creds, ok := ctx.Value(credentialsKey{}).(*credentials)
+	if !ok {
+		return authn.DefaultKeychain
+	}
+	return authn.NewMultiKeychain(creds, authn.DefaultKeychain)
+}
+
+// credentials is a keychain of declared registry credentials, with the
+// secrets of those already used.
+type credentials struct {
+	decls []config.RegistryCredential
+
+	mu       sync.Mutex
+	resolved map[int]config.RegistryCredential
+}
+
+// Resolve returns the credentials of the registry of the resource, or
+// anonymous ones to defer to the next keychain.
+func (c *credentials) Resolve(r authn.Resource) (authn.Authenticator, error) {
+	return c.ResolveContext(context.Background(), r)
+}
+
+// ResolveContext is Resolve, fetching secrets with ctx.
+func (c *credentials) ResolveContext(ctx context.Context, r authn.Resource) (authn.Authenticator, error) {
+	for i, cred := range c.decls {
+		if cred.Registry != r.RegistryStr() {
+			continue
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		resolved, ok := c.resolved[i]
+		if !ok {
+			var err error
+			if resolved, err = secret.ResolveRegistryCredential(ctx, cred); err != nil {
+				return nil, err
+			}
+			c.resolved[i] = resolved
+		}
+		return &authn.Basic{Username: resolved.Username, Password: resolved.Password}, nil
+	}
+	return authn.Anonymous, nil
+}
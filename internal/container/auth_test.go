@@ -0,0 +1,46 @@
+package container
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/shikanime-studio/automata/internal/config"
+)
+
+func TestKeychain(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	ctx := WithCredentials(context.Background(), []config.RegistryCredential{
+		{Registry: "ghcr.io", Username: "ci", Password: "token"},
+		{Registry: "registry.example.com", PasswordFrom: &config.SecretRef{Provider: "exec", Command: []string{"false"}}},
+	})
+	for registry, want := range map[string]*authn.AuthConfig{
+		"ghcr.io": {Username: "ci", Password: "token"},
+		"quay.io": {},
+	} {
+		reg, err := name.NewRegistry(registry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth, err := keychain(ctx).Resolve(reg)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", registry, err)
+		}
+		got, err := auth.Authorization()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", registry, err)
+		}
+		if *got != *want {
+			t.Errorf("%s: got %+v, want %+v", registry, got, want)
+		}
+	}
+	reg, err := name.NewRegistry("registry.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keychain(ctx).Resolve(reg); err == nil {
+		t.Error("registry.example.com: expected the secret to be fetched")
+	}
+}
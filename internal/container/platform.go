@@ -0,0 +1,137 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/shikanime-studio/automata/internal/retry"
+	"github.com/shikanime-studio/automata/internal/timeout"
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+// getDescriptor fetches the manifest descriptor of an image reference
+// (auth keychain, fallback anonymous), retrying transient registry failures.
+func getDescriptor(ctx context.Context, ref string) (*remote.Descriptor, error) {
+	desc, err := retry.Value(ctx, nil, func() (*remote.Descriptor, error) {
+		return crane.Get(
+			ref,
+			crane.WithAuthFromKeychain(keychain(ctx)),
+			crane.WithContext(ctx),
+		)
+	})
+	if err != nil {
+		slog.DebugContext(
+			ctx,
+			"get manifest with keychain failed, falling back to anonymous",
+			"image",
+			ref,
+			"err",
+			err,
+		)
+		desc, err = retry.Value(ctx, nil, func() (*remote.Descriptor, error) {
+			return crane.Get(
+				ref,
+				crane.WithAuth(authn.Anonymous),
+				crane.WithContext(ctx),
+			)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get manifest for %s (anonymous): %w", ref, err)
+		}
+	}
+	return desc, nil
+}
+
+// Platforms returns the platforms, e.g. linux/arm64, an image tag is built
+// for. Attestation manifests of unknown platform are ignored.
+func Platforms(ctx context.Context, imageRef *ImageRef) ([]string, error) {
+	ctx, cancel := timeout.Context(ctx, timeout.Registry)
+	defer cancel()
+	desc, err := getDescriptor(ctx, imageRef.String())
+	if err != nil {
+		return nil, err
+	}
+	if !desc.MediaType.IsIndex() {
+		img, err := desc.Image()
+		if err != nil {
+			return nil, fmt.Errorf("read image %s: %w", imageRef, err)
+		}
+		cfg, err := img.ConfigFile()
+		if err != nil {
+			return nil, fmt.Errorf("read config of %s: %w", imageRef, err)
+		}
+		return []string{cfg.Platform().String()}, nil
+	}
+	idx, err := desc.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("read index %s: %w", imageRef, err)
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("read index manifest %s: %w", imageRef, err)
+	}
+	var platforms []string
+	for _, m := range manifest.Manifests {
+		if m.Platform == nil || m.Platform.OS == "unknown" {
+			continue
+		}
+		platforms = append(platforms, m.Platform.String())
+	}
+	return platforms, nil
+}
+
+// PlatformLister lists the platforms of an image tag.
+type PlatformLister func(ctx context.Context, imageRef *ImageRef) ([]string, error)
+
+// CheckPlatforms returns a candidate check that rejects tags missing any of
+// the required platforms. The platforms of the current tag are required when
+// none are given.
+func CheckPlatforms(imageRef ImageRef, required []string, list PlatformLister) updater.CandidateCheck {
+	var (
+		once    sync.Once
+		listErr error
+	)
+	return func(ctx context.Context, candidate string) (bool, error) {
+		once.Do(func() {
+			if len(required) == 0 {
+				required, listErr = list(ctx, &imageRef)
+			}
+		})
+		if listErr != nil {
+			return false, fmt.Errorf("list platforms of %s: %w", imageRef, listErr)
+		}
+		platforms, err := list(ctx, &ImageRef{Name: imageRef.Name, Tag: candidate})
+		if err != nil {
+			return false, fmt.Errorf("list platforms of %s:%s: %w", imageRef.Name, candidate, err)
+		}
+		for _, p := range required {
+			if !slices.Contains(platforms, p) {
+				slog.InfoContext(
+					ctx,
+					"skipped tag missing platform",
+					"image",
+					imageRef.Name,
+					"tag",
+					candidate,
+					"platform",
+					p,
+				)
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+}
+
+// WithPlatforms verifies that the selected tag provides the required
+// platforms, or those of the current tag when none are given.
+func WithPlatforms(imageRef ImageRef, required ...string) updater.Option {
+	return updater.WithCandidateCheck(CheckPlatforms(imageRef, required, Platforms))
+}
@@ -0,0 +1,34 @@
+package container
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCheckPlatforms(t *testing.T) {
+	platforms := map[string][]string{
+		"1.0.0": {"linux/amd64", "linux/arm64"},
+		"1.1.0": {"linux/amd64"},
+		"1.2.0": {"linux/amd64", "linux/arm64", "linux/arm/v7"},
+	}
+	list := func(_ context.Context, ref *ImageRef) ([]string, error) {
+		return platforms[ref.Tag], nil
+	}
+	ref := ImageRef{Name: "docker.io/library/nginx", Tag: "1.0.0"}
+
+	check := CheckPlatforms(ref, nil, list)
+	for tag, want := range map[string]bool{"1.1.0": false, "1.2.0": true} {
+		ok, err := check(context.Background(), tag)
+		if err != nil {
+			t.Fatalf("check(%s) error: %v", tag, err)
+		}
+		if ok != want {
+			t.Fatalf("check(%s) = %v, want %v", tag, ok, want)
+		}
+	}
+
+	check = CheckPlatforms(ref, []string{"linux/arm/v7"}, list)
+	if ok, _ := check(context.Background(), "1.1.0"); ok {
+		t.Fatalf("check(1.1.0) accepted a tag missing linux/arm/v7")
+	}
+}
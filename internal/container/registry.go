@@ -2,44 +2,101 @@ package container
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 
+	"github.com/shikanime-studio/automata/internal/license"
+	"github.com/shikanime-studio/automata/internal/mirror"
+	"github.com/shikanime-studio/automata/internal/retry"
+	"github.com/shikanime-studio/automata/internal/timeout"
 	"github.com/shikanime-studio/automata/internal/updater"
 )
 
-// ListTags fetches tags for the given image (auth keychain, fallback anonymous).
+// ListTags fetches tags for the given image (auth keychain, fallback anonymous),
+// retrying transient registry failures.
 func ListTags(ctx context.Context, imageRef *ImageRef) ([]string, error) {
+	return mirror.FetchVersions(ctx, mirror.Images, imageRef.Name, func(ctx context.Context) ([]string, error) {
+		return listTags(ctx, imageRef)
+	})
+}
+
+// listTags fetches the tags of an image from its registry.
+func listTags(ctx context.Context, imageRef *ImageRef) ([]string, error) {
 	// Try with keychain, then fallback to anonymous; forward any provided crane options.
-	tags, err := crane.ListTags(
-		imageRef.Name,
-		crane.WithAuthFromKeychain(authn.DefaultKeychain),
-		crane.WithContext(ctx),
-	)
+	tags, err := retry.Value(ctx, nil, func() ([]string, error) {
+		ctx, cancel := timeout.Context(ctx, timeout.Registry)
+		defer cancel()
+		return crane.ListTags(
+			imageRef.Name,
+			crane.WithAuthFromKeychain(keychain(ctx)),
+			crane.WithContext(ctx),
+		)
+	})
 	if err != nil {
-		slog.Debug(
+		slog.DebugContext(
+			ctx,
 			"list tags with keychain failed, falling back to anonymous",
 			"image",
 			imageRef.Name,
 			"err",
 			err,
 		)
-		tags, err = crane.ListTags(
-			imageRef.Name,
-			crane.WithAuth(authn.Anonymous),
-			crane.WithContext(ctx),
-		)
+		tags, err = retry.Value(ctx, nil, func() ([]string, error) {
+			ctx, cancel := timeout.Context(ctx, timeout.Registry)
+			defer cancel()
+			return crane.ListTags(
+				imageRef.Name,
+				crane.WithAuth(authn.Anonymous),
+				crane.WithContext(ctx),
+			)
+		})
 		if err != nil {
-			slog.Error("list tags failed", "image", imageRef.Name, "err", err)
+			slog.ErrorContext(ctx, "list tags failed", "image", imageRef.Name, "err", err)
 			return nil, fmt.Errorf("list tags for %s (anonymous): %w", imageRef.Name, err)
 		}
 	}
 	return tags, nil
 }
 
+// License returns the licenses an image declares in its OCI licenses label,
+// or "" when it declares none.
+func License(ctx context.Context, image string) (string, error) {
+	data, err := retry.Value(ctx, nil, func() ([]byte, error) {
+		ctx, cancel := timeout.Context(ctx, timeout.Registry)
+		defer cancel()
+		return crane.Config(
+			image,
+			crane.WithAuthFromKeychain(keychain(ctx)),
+			crane.WithContext(ctx),
+		)
+	})
+	if err != nil {
+		return "", fmt.Errorf("get config of %s: %w", image, err)
+	}
+	var cfg v1.ConfigFile
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return "", fmt.Errorf("decode config of %s: %w", image, err)
+	}
+	return cfg.Config.Labels[license.ImageLabel], nil
+}
+
+// Digest returns the digest of the manifest an image tag currently points to
+// (auth keychain, fallback anonymous).
+func Digest(ctx context.Context, imageRef *ImageRef) (string, error) {
+	ctx, cancel := timeout.Context(ctx, timeout.Registry)
+	defer cancel()
+	desc, err := getDescriptor(ctx, imageRef.Name+":"+imageRef.Tag)
+	if err != nil {
+		return "", err
+	}
+	return desc.Digest.String(), nil
+}
+
 type findLatestTagOptions struct {
 	excludes      map[string]struct{}
 	updateOptions []updater.Option
@@ -85,58 +142,12 @@ func FindLatestTag(
 	if err != nil {
 		return "", fmt.Errorf("list tags: %w", err)
 	}
-	bestTag := imageRef.Tag
-	for _, tag := range tags {
-		if _, ok := o.excludes[tag]; ok {
-			slog.DebugContext(
-				ctx,
-				"tag excluded by exclude list",
-				"tag",
-				tag,
-				"image",
-				imageRef.String(),
-			)
-			continue
-		}
-		cmp, err := updater.Compare(bestTag, tag, o.updateOptions...)
-		if err != nil {
-			if updater.IsNotValid(err) {
-				slog.DebugContext(
-					ctx,
-					err.Error(),
-					"tag",
-					tag,
-					"image",
-					imageRef.String(),
-					"err",
-					err,
-				)
-				continue
-			}
-			return "", fmt.Errorf("compare tags: %w", err)
-		}
-		switch cmp {
-		case updater.Equal:
-			slog.DebugContext(
-				ctx,
-				"tag is equal to baseline",
-				"tag",
-				tag,
-				"image",
-				imageRef.String(),
-			)
-		case updater.Greater:
-			bestTag = tag
-		case updater.Less:
-			slog.DebugContext(
-				ctx,
-				"tag is less than baseline",
-				"tag",
-				tag,
-				"image",
-				imageRef.String(),
-			)
-		}
-	}
-	return bestTag, nil
+	return updater.SelectLatest(
+		ctx,
+		imageRef.Tag,
+		tags,
+		updater.WithExcludes(o.excludes),
+		updater.WithCompareOptions(o.updateOptions...),
+		updater.WithLogAttrs("image", imageRef.String()),
+	)
 }
@@ -0,0 +1,50 @@
+package container
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/mirror"
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+func TestFindLatestTag_Strategy(t *testing.T) {
+	snap := mirror.NewSnapshot(time.Time{})
+	snap.Add(mirror.Images, "ghcr.io/acme/app", []mirror.Candidate{
+		{Version: "1.2.4"}, {Version: "1.2.9"}, {Version: "1.3.0"}, {Version: "1.3.1"}, {Version: "2.0.0"},
+	})
+	ctx := mirror.WithOffline(context.Background(), snap)
+	for strategy, want := range map[updater.Strategy]string{
+		updater.FullUpdate:  "2.0.0",
+		updater.MinorUpdate: "1.3.1",
+		updater.PatchUpdate: "1.2.9",
+	} {
+		got, err := FindLatestTag(
+			ctx,
+			&ImageRef{Name: "ghcr.io/acme/app", Tag: "1.2.3"},
+			WithUpdateOptions(updater.WithStrategy(strategy)),
+		)
+		if err != nil {
+			t.Fatalf("strategy %d: unexpected error: %v", strategy, err)
+		}
+		if got != want {
+			t.Errorf("strategy %d: got %s, want %s", strategy, got, want)
+		}
+	}
+}
+
+func TestFindLatestTag_Latest(t *testing.T) {
+	snap := mirror.NewSnapshot(time.Time{})
+	snap.Add(mirror.Images, "ghcr.io/acme/app", []mirror.Candidate{
+		{Version: "1.0"}, {Version: "1.10.0"}, {Version: "1.2.0"}, {Version: "1.9.0"}, {Version: "main"},
+	})
+	ctx := mirror.WithOffline(context.Background(), snap)
+	got, err := FindLatestTag(ctx, &ImageRef{Name: "ghcr.io/acme/app", Tag: "latest"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "1.10.0" {
+		t.Errorf("got %s, want 1.10.0", got)
+	}
+}
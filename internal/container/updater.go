@@ -2,7 +2,11 @@ package container
 
 import (
 	"context"
+	"slices"
 
+	"github.com/shikanime-studio/automata/internal/license"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
 	"github.com/shikanime-studio/automata/internal/updater"
 )
 
@@ -24,9 +28,33 @@ func (u Updater) Update(
 	imageRef *ImageRef,
 	opts ...updater.Option,
 ) (string, error) {
-	return FindLatestTag(
-		ctx,
-		imageRef,
-		WithUpdateOptions(append(u.opts, opts...)...),
-	)
+	ctx = logging.WithAttrs(logging.WithSubsystem(ctx, "image"), "dependency", imageRef.Name)
+	find := func(ctx context.Context, extra ...updater.Option) (string, error) {
+		return FindLatestTag(ctx, imageRef, WithUpdateOptions(slices.Concat(u.opts, opts, extra)...))
+	}
+	latest, err := find(ctx)
+	if err != nil {
+		return "", err
+	}
+	tag, err := policy.Apply(ctx, policy.Proposal{
+		Source:   "image",
+		Name:     imageRef.Name,
+		From:     imageRef.Tag,
+		To:       latest,
+		Reselect: find,
+	})
+	if err != nil {
+		return "", err
+	}
+	license.Check(ctx, "image", imageRef.Name, imageRef.Tag, tag,
+		func(ctx context.Context, tag string) (string, error) {
+			return License(ctx, imageRef.Name+":"+tag)
+		})
+	return tag, nil
+}
+
+// Digest returns the digest the tag of the provided image reference points to.
+func (u Updater) Digest(ctx context.Context, imageRef *ImageRef) (string, error) {
+	ctx = logging.WithAttrs(logging.WithSubsystem(ctx, "image"), "dependency", imageRef.Name)
+	return Digest(ctx, imageRef)
 }
@@ -0,0 +1,148 @@
+// Package drift compares the image tags declared by kustomizations with the
+// images running in a cluster, catching manual edits that automated updates
+// would otherwise silently fight.
+package drift
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/kube"
+)
+
+// Declared is an image tag set by a kustomization.
+type Declared struct {
+	// File is the kustomization file, relative to the package root.
+	File string
+	// Namespace is the namespace of the kustomization, empty when unset.
+	Namespace string
+	// Image is the name of the image deployed, its newName when set.
+	Image string
+	// Tag is the tag the kustomization sets.
+	Tag string
+}
+
+// ReadKustomizations lists the image tags set by the kustomizations under
+// root.
+func ReadKustomizations(root string) ([]Declared, error) {
+	nodes, err := kio.LocalPackageReader{
+		PackagePath:    root,
+		MatchFilesGlob: ikio.KustomizationFiles,
+	}.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read kustomizations: %w", err)
+	}
+	var declared []Declared
+	for _, node := range nodes {
+		file, _, err := kioutil.GetFileAnnotations(node)
+		if err != nil {
+			return nil, err
+		}
+		namespace, err := node.GetString("namespace")
+		if err != nil {
+			namespace = ""
+		}
+		images, err := node.Pipe(yaml.Lookup("images"))
+		if err != nil || images == nil {
+			continue
+		}
+		elems, err := images.Elements()
+		if err != nil {
+			return nil, fmt.Errorf("%s: images: %w", file, err)
+		}
+		for _, img := range elems {
+			name, _ := img.GetString("name")
+			newName, _ := img.GetString("newName")
+			tag, _ := img.GetString("newTag")
+			if tag == "" {
+				continue
+			}
+			if newName != "" {
+				name = newName
+			}
+			declared = append(declared, Declared{
+				File:      filepath.ToSlash(file),
+				Namespace: namespace,
+				Image:     name,
+				Tag:       tag,
+			})
+		}
+	}
+	return declared, nil
+}
+
+// Drift is a container running an image tag no kustomization in its scope
+// declares.
+type Drift struct {
+	Workload  kube.Workload
+	Container string
+	Image     string
+	// Running is the tag the container runs.
+	Running string
+	// Declared are the tags declared for the image in the namespace of the
+	// workload.
+	Declared []Declared
+}
+
+func (d Drift) String() string {
+	tags := make([]string, len(d.Declared))
+	for i, decl := range d.Declared {
+		tags[i] = decl.Tag + " (" + decl.File + ")"
+	}
+	return fmt.Sprintf("%s: container %s runs %s:%s, declared %s",
+		d.Workload, d.Container, d.Image, d.Running, strings.Join(tags, ", "))
+}
+
+// Detect returns the containers of the workloads whose image is declared by
+// kustomizations applying to their namespace with other tags. Images no
+// kustomization declares and digest-pinned images are ignored.
+func Detect(declared []Declared, workloads []kube.Workload) []Drift {
+	var drifts []Drift
+	for _, w := range workloads {
+		for _, ct := range w.Containers {
+			ref, err := container.ParseImageRef(ct.Image)
+			if err != nil || ref.Digest != "" || !strings.HasSuffix(ct.Image, ":"+ref.Tag) {
+				continue
+			}
+			var scope []Declared
+			for _, d := range declared {
+				if normalize(d.Image) == ref.Name && (d.Namespace == "" || d.Namespace == w.Namespace) {
+					scope = append(scope, d)
+				}
+			}
+			if len(scope) == 0 || slices.ContainsFunc(scope, func(d Declared) bool { return d.Tag == ref.Tag }) {
+				continue
+			}
+			drifts = append(drifts, Drift{
+				Workload:  w,
+				Container: ct.Name,
+				Image:     strings.TrimSuffix(ct.Image, ":"+ref.Tag),
+				Running:   ref.Tag,
+				Declared:  scope,
+			})
+		}
+	}
+	sort.SliceStable(drifts, func(i, j int) bool {
+		return drifts[i].Workload.String() < drifts[j].Workload.String()
+	})
+	return drifts
+}
+
+// normalize returns the fully qualified name of an image, so that nginx and
+// docker.io/library/nginx compare equal.
+func normalize(image string) string {
+	ref, err := container.ParseImageRef(image)
+	if err != nil {
+		return image
+	}
+	return ref.Name
+}
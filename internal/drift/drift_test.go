@@ -0,0 +1,86 @@
+package drift
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/shikanime-studio/automata/internal/kube"
+)
+
+func TestReadKustomizations(t *testing.T) {
+	dir := t.TempDir()
+	for path, data := range map[string]string{
+		"base/kustomization.yaml": `images:
+  - name: nginx
+    newTag: 1.25.0
+  - name: app
+    newName: ghcr.io/org/app
+    newTag: v2.0.0
+  - name: untagged
+    digest: sha256:abc
+`,
+		"prod/kustomization.yaml": `namespace: prod
+images:
+  - name: nginx
+    newTag: 1.27.0
+`,
+	} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := ReadKustomizations(dir)
+	if err != nil {
+		t.Fatalf("ReadKustomizations: %v", err)
+	}
+	want := []Declared{
+		{File: "base/kustomization.yaml", Image: "nginx", Tag: "1.25.0"},
+		{File: "base/kustomization.yaml", Image: "ghcr.io/org/app", Tag: "v2.0.0"},
+		{File: "prod/kustomization.yaml", Namespace: "prod", Image: "nginx", Tag: "1.27.0"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadKustomizations = %+v, want %+v", got, want)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	declared := []Declared{
+		{File: "prod/kustomization.yaml", Namespace: "prod", Image: "nginx", Tag: "1.27.0"},
+		{File: "dev/kustomization.yaml", Namespace: "dev", Image: "nginx", Tag: "1.25.0"},
+		{File: "kustomization.yaml", Image: "ghcr.io/org/app", Tag: "v2.0.0"},
+	}
+	workload := func(ns, name, image string) kube.Workload {
+		return kube.Workload{
+			Kind:       "Deployment",
+			Namespace:  ns,
+			Name:       name,
+			Containers: []kube.Container{{Name: name, Image: image}},
+		}
+	}
+	workloads := []kube.Workload{
+		workload("prod", "web", "nginx:1.27.0"),
+		workload("dev", "web", "nginx:1.26.0"),
+		workload("prod", "app", "ghcr.io/org/app:v1.9.0"),
+		workload("prod", "pinned", "ghcr.io/org/app@sha256:abc"),
+		workload("prod", "other", "redis:7"),
+		workload("staging", "web", "nginx:1.20.0"),
+		workload("prod", "qualified", "docker.io/library/nginx:1.27.0"),
+	}
+	got := Detect(declared, workloads)
+	want := []Drift{
+		{Workload: workloads[1], Container: "web", Image: "nginx", Running: "1.26.0", Declared: declared[1:2]},
+		{Workload: workloads[2], Container: "app", Image: "ghcr.io/org/app", Running: "v1.9.0", Declared: declared[2:]},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Detect = %+v, want %+v", got, want)
+	}
+	if s := got[0].String(); s != "Deployment/dev/web: container web runs nginx:1.26.0, declared 1.25.0 (dev/kustomization.yaml)" {
+		t.Errorf("String = %q", s)
+	}
+}
@@ -0,0 +1,192 @@
+// Package endoflife looks up the release cycles of products on endoflife.date
+// and warns when a pinned version is past its end of life.
+package endoflife
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/logging"
+)
+
+// APIURL is the base URL of the endoflife.date API.
+const APIURL = "https://endoflife.date/api"
+
+// Cycle is a release cycle of a product, e.g. Kubernetes 1.28.
+type Cycle struct {
+	// Cycle is the cycle name, e.g. "1.28" or "16".
+	Cycle string
+	// EOL is the end of life date; zero when unknown.
+	EOL time.Time
+	// Ended is set when the cycle is flagged as ended without a date.
+	Ended bool
+	// Latest is the latest release of the cycle.
+	Latest string
+}
+
+// UnmarshalJSON parses a cycle, whose eol field is either a date or a
+// boolean.
+func (c *Cycle) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Cycle  json.RawMessage `json:"cycle"`
+		EOL    json.RawMessage `json:"eol"`
+		Latest string          `json:"latest"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.Cycle = strings.Trim(string(raw.Cycle), `"`)
+	c.Latest = raw.Latest
+	var date string
+	if err := json.Unmarshal(raw.EOL, &date); err == nil {
+		t, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			return fmt.Errorf("parse eol of %s: %w", c.Cycle, err)
+		}
+		c.EOL = t
+		return nil
+	}
+	return json.Unmarshal(raw.EOL, &c.Ended)
+}
+
+// IsEOL reports whether the cycle is past its end of life at now.
+func (c Cycle) IsEOL(now time.Time) bool {
+	return c.Ended || (!c.EOL.IsZero() && !now.Before(c.EOL))
+}
+
+// Matches reports whether version belongs to the cycle, ignoring a leading
+// "v".
+func (c Cycle) Matches(version string) bool {
+	v := strings.TrimPrefix(strings.TrimPrefix(version, "v"), "V")
+	return v == c.Cycle || strings.HasPrefix(v, c.Cycle+".") || strings.HasPrefix(v, c.Cycle+"-")
+}
+
+// FindCycle returns the most specific cycle version belongs to.
+func FindCycle(cycles []Cycle, version string) (Cycle, bool) {
+	var (
+		best  Cycle
+		found bool
+	)
+	for _, c := range cycles {
+		if c.Matches(version) && (!found || len(c.Cycle) > len(best.Cycle)) {
+			best, found = c, true
+		}
+	}
+	return best, found
+}
+
+// Supported returns the first cycle not past its end of life. Cycles are
+// listed newest first by the API.
+func Supported(cycles []Cycle, now time.Time) (Cycle, bool) {
+	for _, c := range cycles {
+		if !c.IsEOL(now) {
+			return c, true
+		}
+	}
+	return Cycle{}, false
+}
+
+// Client fetches release cycles, caching them per product.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	mu         sync.Mutex
+	cache      map[string][]Cycle
+}
+
+// NewClient creates a client of the public endoflife.date API.
+func NewClient() *Client {
+	return &Client{BaseURL: APIURL, HTTPClient: http.DefaultClient}
+}
+
+// Cycles returns the release cycles of a product, newest first.
+func (c *Client) Cycles(ctx context.Context, product string) ([]Cycle, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cycles, ok := c.cache[product]; ok {
+		return cycles, nil
+	}
+	url := fmt.Sprintf("%s/%s.json", strings.TrimSuffix(c.BaseURL, "/"), product)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create endoflife request: %w", err)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("query endoflife.date: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.WarnContext(ctx, "close endoflife response", "err", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("query endoflife.date for %s: unexpected status %s", product, resp.Status)
+	}
+	var cycles []Cycle
+	if err := json.NewDecoder(resp.Body).Decode(&cycles); err != nil {
+		return nil, fmt.Errorf("decode cycles of %s: %w", product, err)
+	}
+	if c.cache == nil {
+		c.cache = make(map[string][]Cycle)
+	}
+	c.cache[product] = cycles
+	return cycles, nil
+}
+
+// Checker warns about dependencies pinned to a cycle past its end of life.
+type Checker struct {
+	client   *Client
+	products map[string]config.EndOfLife
+	now      func() time.Time
+}
+
+// NewChecker creates a checker of the products declared in the config file,
+// keyed by dependency name.
+func NewChecker(client *Client, decls []config.EndOfLife) *Checker {
+	products := make(map[string]config.EndOfLife, len(decls))
+	for _, d := range decls {
+		products[d.Name] = d
+	}
+	return &Checker{client: client, products: products, now: time.Now}
+}
+
+// Check logs an "end of life" warning when the version of the named
+// dependency belongs to a cycle past its end of life. The latest release of
+// the newest supported cycle is suggested when the product asks for it.
+// Dependencies without a declared product are ignored.
+func (c *Checker) Check(ctx context.Context, name, version string) error {
+	d, ok := c.products[name]
+	if !ok {
+		return nil
+	}
+	cycles, err := c.client.Cycles(ctx, d.Product)
+	if err != nil {
+		return err
+	}
+	cycle, ok := FindCycle(cycles, version)
+	if !ok {
+		slog.DebugContext(ctx, "no release cycle matches version",
+			"product", d.Product, "name", name, "version", version)
+		return nil
+	}
+	if !cycle.IsEOL(c.now()) {
+		return nil
+	}
+	attrs := []any{logging.EndOfLife.Attr(), "product", d.Product, "name", name, "version", version, "cycle", cycle.Cycle}
+	if !cycle.EOL.IsZero() {
+		attrs = append(attrs, "eol", cycle.EOL.Format(time.DateOnly))
+	}
+	if supported, ok := Supported(cycles, c.now()); ok && d.SuggestMajor {
+		attrs = append(attrs, "suggested", supported.Latest)
+	}
+	slog.WarnContext(ctx, "end of life version", attrs...)
+	return nil
+}
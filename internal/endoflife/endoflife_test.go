@@ -0,0 +1,91 @@
+package endoflife
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/config"
+)
+
+const kubernetes = `[
+  {"cycle": "1.31", "eol": "2025-10-28", "latest": "1.31.2"},
+  {"cycle": "1.30", "eol": "2025-06-28", "latest": "1.30.6"},
+  {"cycle": "1.28", "eol": "2024-10-28", "latest": "1.28.15"},
+  {"cycle": "1.27", "eol": true, "latest": "1.27.16"}
+]`
+
+func TestFindCycle(t *testing.T) {
+	cycles := []Cycle{{Cycle: "1.28"}, {Cycle: "1.2"}, {Cycle: "16"}}
+	cases := map[string]string{
+		"v1.28.3":       "1.28",
+		"1.2.0":         "1.2",
+		"16.4-bookworm": "16",
+		"1.3.0":         "",
+	}
+	for v, want := range cases {
+		c, ok := FindCycle(cycles, v)
+		if want == "" {
+			if ok {
+				t.Fatalf("FindCycle(%q) = %s, want none", v, c.Cycle)
+			}
+			continue
+		}
+		if !ok || c.Cycle != want {
+			t.Fatalf("FindCycle(%q) = %s, %v, want %s", v, c.Cycle, ok, want)
+		}
+	}
+}
+
+func TestChecker_Check(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/kubernetes.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(kubernetes))
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	ctx := context.Background()
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(prev)
+
+	c := NewChecker(&Client{BaseURL: srv.URL, HTTPClient: srv.Client()}, []config.EndOfLife{
+		{Name: "registry.k8s.io/kube-apiserver", Product: "kubernetes", SuggestMajor: true},
+	})
+	c.now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	for _, v := range []string{"v1.30.2", "v1.28.3", "v1.27.1"} {
+		if err := c.Check(ctx, "registry.k8s.io/kube-apiserver", v); err != nil {
+			t.Fatalf("Check(%s) error: %v", v, err)
+		}
+	}
+	if err := c.Check(ctx, "docker.io/library/nginx", "1.0.0"); err != nil {
+		t.Fatalf("Check(nginx) error: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("requests = %d, want 1", requests)
+	}
+	out := logs.String()
+	if strings.Contains(out, "version=v1.30.2") {
+		t.Fatalf("supported version reported:\n%s", out)
+	}
+	for _, want := range []string{
+		"version=v1.28.3 cycle=1.28 eol=2024-10-28 suggested=1.31.2",
+		"version=v1.27.1 cycle=1.27 suggested=1.31.2",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in:\n%s", want, out)
+		}
+	}
+}
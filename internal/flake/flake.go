@@ -0,0 +1,111 @@
+// Package flake compares Nix flake lock files, so the inputs bumped by
+// `nix flake update` are reported instead of an opaque lock file change.
+package flake
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"golang.org/x/mod/semver"
+)
+
+// lockFile is the subset of flake.lock compared.
+type lockFile struct {
+	Root  string `json:"root"`
+	Nodes map[string]struct {
+		Inputs map[string]json.RawMessage `json:"inputs"`
+		Locked struct {
+			Rev          string `json:"rev"`
+			LastModified int64  `json:"lastModified"`
+		} `json:"locked"`
+		Original struct {
+			Ref string `json:"ref"`
+		} `json:"original"`
+	} `json:"nodes"`
+}
+
+// Input is a direct input locked by a flake.lock.
+type Input struct {
+	// Rev is the locked revision.
+	Rev string
+	// Ref is the branch or tag the input follows, if any.
+	Ref string
+	// LastModified is the commit time of the revision, in Unix seconds.
+	LastModified int64
+}
+
+// Version returns the tag the input follows when it is a version, the short
+// locked revision otherwise.
+func (i Input) Version() string {
+	if v := i.Ref; semver.IsValid(v) || semver.IsValid("v"+v) {
+		return v
+	}
+	return shortRev(i.Rev)
+}
+
+// Parse returns the direct inputs of a flake.lock by name. Inputs following
+// another input lock nothing of their own and are left out.
+func Parse(data []byte) (map[string]Input, error) {
+	var lock lockFile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("parse flake.lock: %w", err)
+	}
+	inputs := make(map[string]Input)
+	for name, raw := range lock.Nodes[lock.Root].Inputs {
+		var node string
+		if err := json.Unmarshal(raw, &node); err != nil {
+			continue
+		}
+		n := lock.Nodes[node]
+		if n.Locked.Rev == "" {
+			continue
+		}
+		inputs[name] = Input{Rev: n.Locked.Rev, Ref: n.Original.Ref, LastModified: n.Locked.LastModified}
+	}
+	return inputs, nil
+}
+
+// Bump is a direct input locked at a new revision.
+type Bump struct {
+	// Name is the input name.
+	Name string
+	// From and To are the versions before and after, see Input.Version.
+	From string
+	To   string
+}
+
+// Diff returns the inputs locked at a different revision in after than in
+// before, sorted by name. Inputs added or removed are not bumps.
+func Diff(before, after []byte) ([]Bump, error) {
+	old, err := Parse(before)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := Parse(after)
+	if err != nil {
+		return nil, err
+	}
+	var bumps []Bump
+	for name, i := range cur {
+		o, ok := old[name]
+		if !ok || o.Rev == i.Rev {
+			continue
+		}
+		from, to := o.Version(), i.Version()
+		if from == to {
+			from, to = shortRev(o.Rev), shortRev(i.Rev)
+		}
+		bumps = append(bumps, Bump{Name: name, From: from, To: to})
+	}
+	sort.Slice(bumps, func(i, j int) bool { return bumps[i].Name < bumps[j].Name })
+	return bumps, nil
+}
+
+// shortRev abbreviates a revision.
+func shortRev(rev string) string {
+	if len(rev) > 7 {
+		return rev[:7]
+	}
+	return rev
+}
@@ -0,0 +1,70 @@
+package flake
+
+import (
+	"reflect"
+	"testing"
+)
+
+const before = `{
+  "nodes": {
+    "nixpkgs": {
+      "locked": {"lastModified": 1717000000, "rev": "aaaaaaaaaaaaaaaaaaaa", "type": "github"},
+      "original": {"owner": "NixOS", "ref": "nixos-24.05", "repo": "nixpkgs", "type": "github"}
+    },
+    "devenv": {
+      "locked": {"rev": "1111111111111111", "type": "github"},
+      "original": {"owner": "cachix", "ref": "v1.0.7", "repo": "devenv", "type": "github"}
+    },
+    "flake-utils": {
+      "locked": {"rev": "cccccccccccccccc", "type": "github"},
+      "original": {"owner": "numtide", "repo": "flake-utils", "type": "github"}
+    },
+    "root": {
+      "inputs": {"nixpkgs": "nixpkgs", "devenv": "devenv", "flake-utils": "flake-utils", "systems": ["flake-utils", "systems"]}
+    }
+  },
+  "root": "root",
+  "version": 7
+}`
+
+const after = `{
+  "nodes": {
+    "nixpkgs": {
+      "locked": {"lastModified": 1718000000, "rev": "bbbbbbbbbbbbbbbbbbbb", "type": "github"},
+      "original": {"owner": "NixOS", "ref": "nixos-24.05", "repo": "nixpkgs", "type": "github"}
+    },
+    "devenv": {
+      "locked": {"rev": "2222222222222222", "type": "github"},
+      "original": {"owner": "cachix", "ref": "v1.1.0", "repo": "devenv", "type": "github"}
+    },
+    "flake-utils": {
+      "locked": {"rev": "cccccccccccccccc", "type": "github"},
+      "original": {"owner": "numtide", "repo": "flake-utils", "type": "github"}
+    },
+    "root": {
+      "inputs": {"nixpkgs": "nixpkgs", "devenv": "devenv", "flake-utils": "flake-utils"}
+    }
+  },
+  "root": "root",
+  "version": 7
+}`
+
+func TestDiffReportsBumpedInputs(t *testing.T) {
+	got, err := Diff([]byte(before), []byte(after))
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	want := []Bump{
+		{Name: "devenv", From: "v1.0.7", To: "v1.1.0"},
+		{Name: "nixpkgs", From: "aaaaaaa", To: "bbbbbbb"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestDiffRejectsInvalidLock(t *testing.T) {
+	if _, err := Diff([]byte(before), []byte("{")); err == nil {
+		t.Fatalf("expected an error for an invalid lock file")
+	}
+}
@@ -0,0 +1,131 @@
+package fsutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// BackupSuffix is appended to the path of the copy kept of overwritten files.
+const BackupSuffix = ".bak"
+
+type backupKey struct{}
+
+// WithBackups returns a context whose file writes keep a copy of the previous
+// content next to the file.
+func WithBackups(ctx context.Context, keep bool) context.Context {
+	return context.WithValue(ctx, backupKey{}, keep)
+}
+
+// Backups reports whether file writes made with the context keep a backup.
+func Backups(ctx context.Context) bool {
+	keep, _ := ctx.Value(backupKey{}).(bool)
+	return keep
+}
+
+// WriteFile atomically replaces the content of the file at path: data is
+// written to a temporary file of the same directory, synced, then renamed
+// over path, so a crash leaves either the previous or the new content, never
+// a truncated file. Existing files keep their permissions, new ones get perm,
+// and files already holding data are left untouched. With backup, the
+// previous content is kept at path with BackupSuffix.
+func WriteFile(path string, data []byte, perm fs.FileMode, backup bool) error {
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+		prev, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		if bytes.Equal(prev, data) {
+			return nil
+		}
+		if backup {
+			if err := WriteFile(path+BackupSuffix, prev, perm, false); err != nil {
+				return fmt.Errorf("back up %s: %w", path, err)
+			}
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file for %s: %w", path, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("chmod %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("sync %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// CheckFunc validates the content written to a file.
+type CheckFunc func(path string, data []byte) error
+
+// AtomicFS is the on-disk kyaml file system writing files with WriteFile, for
+// the kio package writers.
+type AtomicFS struct {
+	filesys.FileSystem
+	// Backup keeps the previous content of overwritten files.
+	Backup bool
+	// Check validates rewritten files once on disk; the previous content is
+	// restored when it fails.
+	Check CheckFunc
+}
+
+// NewAtomicFS returns the on-disk file system writing atomically and checking
+// rewritten files, keeping backups when the context asks to.
+func NewAtomicFS(ctx context.Context, check CheckFunc) filesys.FileSystemOrOnDisk {
+	return filesys.FileSystemOrOnDisk{
+		FileSystem: AtomicFS{FileSystem: filesys.MakeFsOnDisk(), Backup: Backups(ctx), Check: check},
+	}
+}
+
+// WriteFile atomically replaces the content of the named file, reverting it
+// when the check of the new content fails.
+func (a AtomicFS) WriteFile(name string, data []byte) error {
+	prev, err := os.ReadFile(name)
+	existed := err == nil
+	if existed && bytes.Equal(prev, data) {
+		return nil
+	}
+	if err := WriteFile(name, data, 0o644, a.Backup); err != nil {
+		return err
+	}
+	if a.Check == nil {
+		return nil
+	}
+	checkErr := a.Check(name, data)
+	if checkErr == nil {
+		return nil
+	}
+	if existed {
+		err = WriteFile(name, prev, 0o644, false)
+	} else {
+		err = os.Remove(name)
+	}
+	if err != nil {
+		return fmt.Errorf("revert %s: %w", name, errors.Join(checkErr, err))
+	}
+	return fmt.Errorf("invalid rewrite of %s, reverted: %w", name, checkErr)
+}
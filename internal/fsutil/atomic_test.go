@@ -0,0 +1,46 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileReplacesAtomicallyWithBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kustomization.yaml")
+	if err := os.WriteFile(path, []byte("newTag: 1.0.0\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := WriteFile(path, []byte("newTag: 1.1.0\n"), 0o644, true); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil || string(got) != "newTag: 1.1.0\n" {
+		t.Fatalf("unexpected content %q: %v", got, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected permissions to be kept, got %v: %v", info.Mode(), err)
+	}
+	backup, err := os.ReadFile(path + BackupSuffix)
+	if err != nil || string(backup) != "newTag: 1.0.0\n" {
+		t.Fatalf("unexpected backup %q: %v", backup, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("expected no temporary file left, got %v: %v", entries, err)
+	}
+}
+
+func TestWriteFileSkipsUnchangedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "values.yaml")
+	if err := os.WriteFile(path, []byte("tag: 1.0.0\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := WriteFile(path, []byte("tag: 1.0.0\n"), 0o644, true); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := os.Stat(path + BackupSuffix); !os.IsNotExist(err) {
+		t.Fatalf("expected no backup of an unchanged file, got %v", err)
+	}
+}
@@ -2,9 +2,8 @@
 package fsutil
 
 import (
-	"context"
 	"io/fs"
-	"os/exec"
+	"os"
 	"path/filepath"
 	"strings"
 )
@@ -29,29 +28,35 @@ func IsHidden(path string) bool {
 	return strings.HasPrefix(filepath.Base(path), ".")
 }
 
-// SkipGitIgnored returns a WalkDirFunc that skips files ignored by git.
-// It requires the root directory to run the git command in.
-func SkipGitIgnored(ctx context.Context, root string, next fs.WalkDirFunc) fs.WalkDirFunc {
-	return func(path string, d fs.DirEntry, err error) error {
+// CopyDir copies the regular files of the tree rooted at src into dst,
+// skipping the .git directory and the paths ignored in its worktree, as the
+// update commands do, so that they find the same files in the copy.
+func CopyDir(src, dst string) error {
+	return filepath.WalkDir(src, SkipIgnored(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
 		if err != nil {
 			return err
 		}
-		if IsGitIgnored(ctx, root, path) {
-			if d.IsDir() {
+		if d.IsDir() {
+			if path != src && d.Name() == ".git" {
 				return filepath.SkipDir
 			}
+			return os.MkdirAll(filepath.Join(dst, rel), 0o755)
+		}
+		if !d.Type().IsRegular() {
 			return nil
 		}
-		return next(path, d, err)
-	}
-}
-
-// IsGitIgnored reports whether the given path is ignored by git.
-func IsGitIgnored(ctx context.Context, root, path string) bool {
-	cmd := exec.CommandContext(ctx, "git", "check-ignore", "-q", "--", path)
-	cmd.Dir = root
-	if err := cmd.Run(); err == nil {
-		return true
-	}
-	return false
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), data, info.Mode().Perm())
+	}))
 }
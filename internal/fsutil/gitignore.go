@@ -0,0 +1,120 @@
+package fsutil
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	gitignore "github.com/monochromegane/go-gitignore"
+)
+
+// IgnoreFiles are the ignore files read in each directory, in gitignore
+// syntax. An .automataignore excludes files tracked in git from updates.
+var IgnoreFiles = []string{".gitignore", ".automataignore"}
+
+// Ignorer matches paths against the ignore files of a git worktree without
+// running git: the IgnoreFiles of each directory, applying to it and below,
+// and .git/info/exclude. Ignore files are read once per directory. Unlike
+// git, a negated pattern only re-includes paths ignored by the same file.
+type Ignorer struct {
+	top     string
+	exclude gitignore.IgnoreMatcher
+	mu      sync.Mutex
+	layers  map[string]gitignore.IgnoreMatcher
+}
+
+// NewIgnorer creates an Ignorer for the worktree containing root, or rooted
+// at root when it is not in one.
+func NewIgnorer(root string) (*Ignorer, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	i := &Ignorer{top: Worktree(abs), layers: make(map[string]gitignore.IgnoreMatcher)}
+	exclude := filepath.Join(i.top, ".git", "info", "exclude")
+	if m, err := gitignore.NewGitIgnore(exclude, i.top); err == nil {
+		i.exclude = m
+	}
+	return i, nil
+}
+
+// Worktree returns the top directory of the git worktree containing dir, or
+// dir when it is not in one.
+func Worktree(dir string) string {
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+// Ignored reports whether a path is ignored by the ignore files of its
+// parent directories within the worktree.
+func (i *Ignorer) Ignored(path string, isDir bool) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(i.top, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	if i.exclude != nil && i.exclude.Match(abs, isDir) {
+		return true
+	}
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		for _, name := range IgnoreFiles {
+			if m := i.layer(filepath.Join(dir, name)); m != nil && m.Match(abs, isDir) {
+				return true
+			}
+		}
+		if dir == i.top || dir == filepath.Dir(dir) {
+			return false
+		}
+	}
+}
+
+// layer returns the matcher of an ignore file, loading it on first use, or
+// nil when the file does not exist.
+func (i *Ignorer) layer(file string) gitignore.IgnoreMatcher {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if m, ok := i.layers[file]; ok {
+		return m
+	}
+	m, err := gitignore.NewGitIgnore(file)
+	if err != nil {
+		m = nil
+	}
+	i.layers[file] = m
+	return m
+}
+
+// SkipIgnored returns a WalkDirFunc that skips the files and directories
+// ignored in the worktree containing root.
+func SkipIgnored(root string, next fs.WalkDirFunc) fs.WalkDirFunc {
+	i, err := NewIgnorer(root)
+	return func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if err != nil {
+			return errors.Join(err, next(path, d, walkErr))
+		}
+		if i.Ignored(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return next(path, d, walkErr)
+	}
+}
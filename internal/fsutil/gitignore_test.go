@@ -0,0 +1,161 @@
+package fsutil
+
+import (
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// worktreeFixture creates a worktree with nested ignore files.
+func worktreeFixture(t testing.TB) string {
+	t.Helper()
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		".git/info/exclude":         "local.yaml\n",
+		".gitignore":                "/dist\n*.log\n",
+		"apps/.gitignore":           "charts/\n",
+		"apps/.automataignore":      "vendor/\n",
+		"apps/vendor/crd.yaml":      "",
+		"apps/kustomization.yaml":   "",
+		"apps/charts/values.yaml":   "",
+		"apps/web/debug.log":        "",
+		"apps/web/local.yaml":       "",
+		"apps/web/deployment.yaml":  "",
+		"dist/kustomization.yaml":   "",
+		"infra/dist/kustomize.yaml": "",
+	} {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	return root
+}
+
+func TestCopyDirSkipsIgnoredPaths(t *testing.T) {
+	root := worktreeFixture(t)
+	if err := os.WriteFile(filepath.Join(root, ".circleci.yml"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, ".circleci"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".circleci", "config.yml"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dst := t.TempDir()
+	if err := CopyDir(root, dst); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	var got []string
+	err := filepath.WalkDir(dst, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dst, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	want := []string{
+		".circleci/config.yml", ".circleci.yml", ".gitignore",
+		"apps/.automataignore", "apps/.gitignore", "apps/kustomization.yaml", "apps/web/deployment.yaml",
+		"infra/dist/kustomize.yaml",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestSkipIgnoredLayersIgnoreFiles(t *testing.T) {
+	root := worktreeFixture(t)
+	var got []string
+	err := filepath.WalkDir(filepath.Join(root, "apps"), SkipIgnored(filepath.Join(root, "apps"),
+		func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				rel, _ := filepath.Rel(root, path)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			return err
+		}))
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	want := []string{"apps/.automataignore", "apps/.gitignore", "apps/kustomization.yaml", "apps/web/deployment.yaml"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestIgnorerAnchorsPatternsToTheirDirectory(t *testing.T) {
+	root := worktreeFixture(t)
+	i, err := NewIgnorer(root)
+	if err != nil {
+		t.Fatalf("new ignorer: %v", err)
+	}
+	if !i.Ignored(filepath.Join(root, "dist"), true) {
+		t.Fatalf("expected /dist to be ignored")
+	}
+	if i.Ignored(filepath.Join(root, "infra", "dist"), true) {
+		t.Fatalf("expected infra/dist not to match the anchored /dist pattern")
+	}
+}
+
+// benchmarkPaths lists the files of the fixture worktree.
+func benchmarkPaths(b *testing.B, root string) []string {
+	b.Helper()
+	var paths []string
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			paths = append(paths, path)
+		}
+		return err
+	})
+	return paths
+}
+
+func BenchmarkIgnorer(b *testing.B) {
+	root := worktreeFixture(b)
+	paths := benchmarkPaths(b, root)
+	b.ResetTimer()
+	for b.Loop() {
+		i, err := NewIgnorer(root)
+		if err != nil {
+			b.Fatalf("new ignorer: %v", err)
+		}
+		for _, path := range paths {
+			i.Ignored(path, false)
+		}
+	}
+}
+
+// BenchmarkGitCheckIgnore measures the former approach of running git per
+// path, for comparison with BenchmarkIgnorer.
+func BenchmarkGitCheckIgnore(b *testing.B) {
+	if _, err := exec.LookPath("git"); err != nil {
+		b.Skip("git not found")
+	}
+	root := worktreeFixture(b)
+	if err := os.RemoveAll(filepath.Join(root, ".git")); err != nil {
+		b.Fatalf("remove: %v", err)
+	}
+	if out, err := exec.Command("git", "init", "-q", root).CombinedOutput(); err != nil {
+		b.Fatalf("git init: %v: %s", err, out)
+	}
+	paths := benchmarkPaths(b, root)
+	b.ResetTimer()
+	for b.Loop() {
+		for _, path := range paths {
+			cmd := exec.Command("git", "check-ignore", "-q", path)
+			cmd.Dir = root
+			_ = cmd.Run()
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package fsutil
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Workers bounds the directories a Walk reads at once, and the jobs the
+// walkers of the update commands run at once.
+var Workers = runtime.GOMAXPROCS(0)
+
+// Walk walks the file tree rooted at root like filepath.WalkDir, but reads
+// up to Workers directories in parallel. fn is called concurrently and in no
+// defined order across directories. Returning filepath.SkipDir from a
+// directory skips it, from a file skips the rest of its directory, and
+// filepath.SkipAll or an error stops the walk.
+func Walk(root string, fn fs.WalkDirFunc) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		d := fs.FileInfoToDirEntry(info)
+		err = fn(root, d, nil)
+		if err == nil && d.IsDir() {
+			w := &walker{fn: fn}
+			w.g.SetLimit(Workers)
+			w.g.Go(func() error { return w.dir(root, d) })
+			err = w.g.Wait()
+		}
+	}
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// walker walks directories on a bounded group of goroutines.
+type walker struct {
+	fn      fs.WalkDirFunc
+	g       errgroup.Group
+	stopped atomic.Bool
+}
+
+// dir walks the entries of a directory, handing its subdirectories to idle
+// workers or walking them inline when none is.
+func (w *walker) dir(path string, d fs.DirEntry) error {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		if err := w.fn(path, d, err); err != nil {
+			return w.stop(err)
+		}
+	}
+	for _, e := range entries {
+		if w.stopped.Load() {
+			return nil
+		}
+		sub := filepath.Join(path, e.Name())
+		if err := w.fn(sub, e, nil); err != nil {
+			if errors.Is(err, filepath.SkipDir) {
+				if e.IsDir() {
+					continue
+				}
+				return nil
+			}
+			return w.stop(err)
+		}
+		if !e.IsDir() {
+			continue
+		}
+		if !w.g.TryGo(func() error { return w.dir(sub, e) }) {
+			if err := w.dir(sub, e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// stop stops the walk on an error, a SkipDir returned for an unreadable
+// directory only skipping it.
+func (w *walker) stop(err error) error {
+	if errors.Is(err, filepath.SkipDir) {
+		return nil
+	}
+	w.stopped.Store(true)
+	return err
+}
@@ -0,0 +1,101 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// treeFixture creates a tree of nested packages, each with a manifest.
+func treeFixture(t testing.TB, width, depth int) string {
+	t.Helper()
+	root := t.TempDir()
+	var create func(dir string, depth int)
+	create = func(dir string, depth int) {
+		if err := os.WriteFile(filepath.Join(dir, "flake.nix"), nil, 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if depth == 0 {
+			return
+		}
+		for i := range width {
+			sub := filepath.Join(dir, fmt.Sprintf("pkg%d", i))
+			if err := os.Mkdir(sub, 0o755); err != nil {
+				t.Fatalf("mkdir: %v", err)
+			}
+			create(sub, depth-1)
+		}
+	}
+	create(root, depth)
+	return root
+}
+
+// collect walks root with walk, returning the sorted visited paths.
+func collect(t *testing.T, walk func(string, fs.WalkDirFunc) error, root string, skip string) []string {
+	t.Helper()
+	var mu sync.Mutex
+	var got []string
+	err := walk(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == skip {
+			return filepath.SkipDir
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	slices.Sort(got)
+	return got
+}
+
+func TestWalkVisitsLikeWalkDir(t *testing.T) {
+	root := treeFixture(t, 3, 3)
+	want := collect(t, filepath.WalkDir, root, "pkg1")
+	if got := collect(t, Walk, root, "pkg1"); !slices.Equal(got, want) {
+		t.Fatalf("expected %d paths, got %d: %v", len(want), len(got), got)
+	}
+}
+
+func TestWalkStopsOnError(t *testing.T) {
+	root := treeFixture(t, 3, 3)
+	stop := errors.New("stop")
+	err := Walk(root, func(path string, d fs.DirEntry, err error) error {
+		if filepath.Base(path) == "pkg2" {
+			return stop
+		}
+		return err
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("expected the walk to stop with %v, got %v", stop, err)
+	}
+	if err := Walk(root, func(string, fs.DirEntry, error) error { return filepath.SkipAll }); err != nil {
+		t.Fatalf("expected SkipAll to stop without error, got %v", err)
+	}
+}
+
+func BenchmarkWalk(b *testing.B) {
+	root := treeFixture(b, 6, 4)
+	for b.Loop() {
+		_ = Walk(root, func(string, fs.DirEntry, error) error { return nil })
+	}
+}
+
+// BenchmarkWalkDir measures the sequential filepath.WalkDir, for comparison
+// with BenchmarkWalk.
+func BenchmarkWalkDir(b *testing.B) {
+	root := treeFixture(b, 6, 4)
+	for b.Loop() {
+		_ = filepath.WalkDir(root, func(string, fs.DirEntry, error) error { return nil })
+	}
+}
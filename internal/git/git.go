@@ -0,0 +1,109 @@
+// Package git provides helpers to resolve tag versions from remote git
+// repositories.
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/shikanime-studio/automata/internal/mirror"
+	"github.com/shikanime-studio/automata/internal/timeout"
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+// RepoRef identifies a remote git repository and its current tag.
+type RepoRef struct {
+	URL     string
+	Version string
+}
+
+func (r *RepoRef) String() string {
+	return fmt.Sprintf("%s@%s", r.URL, r.Version)
+}
+
+// ListTags returns the tag names advertised by the remote repository.
+func ListTags(ctx context.Context, repo *RepoRef) ([]string, error) {
+	return mirror.FetchVersions(ctx, mirror.Git, repo.URL, func(ctx context.Context) ([]string, error) {
+		return lsRemoteTags(ctx, repo.URL)
+	})
+}
+
+// lsRemoteTags lists the tags of a remote repository with git ls-remote.
+func lsRemoteTags(ctx context.Context, url string) ([]string, error) {
+	ctx, cancel := timeout.Context(ctx, timeout.Git)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", "ls-remote", "--tags", "--refs", url)
+	cmd.Env = os.Environ()
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-remote failed: %w", err)
+	}
+	var tags []string
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		_, ref, ok := strings.Cut(s.Text(), "\t")
+		if !ok {
+			continue
+		}
+		tags = append(tags, strings.TrimPrefix(ref, "refs/tags/"))
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("read git ls-remote output: %w", err)
+	}
+	return tags, nil
+}
+
+type findLatestOptions struct {
+	excludes      map[string]struct{}
+	updateOptions []updater.Option
+}
+
+// FindLatestOption configures the search for the latest tag.
+type FindLatestOption func(*findLatestOptions)
+
+// WithExcludes specifies a set of tags to exclude from consideration.
+func WithExcludes(excludes map[string]struct{}) FindLatestOption {
+	return func(o *findLatestOptions) {
+		o.excludes = excludes
+	}
+}
+
+// WithUpdateOptions specifies options to use for version comparison.
+func WithUpdateOptions(opts ...updater.Option) FindLatestOption {
+	return func(o *findLatestOptions) {
+		o.updateOptions = opts
+	}
+}
+
+func makeFindLatestOptions(opts ...FindLatestOption) findLatestOptions {
+	o := findLatestOptions{
+		excludes: make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// FindLatestTag returns the latest tag for the given repository based on the
+// provided options.
+func FindLatestTag(ctx context.Context, repo *RepoRef, opts ...FindLatestOption) (string, error) {
+	o := makeFindLatestOptions(opts...)
+	tags, err := ListTags(ctx, repo)
+	if err != nil {
+		return "", err
+	}
+	return updater.SelectLatest(
+		ctx,
+		repo.Version,
+		tags,
+		updater.WithExcludes(o.excludes),
+		updater.WithCompareOptions(o.updateOptions...),
+		updater.WithLogAttrs("repo", repo.String()),
+	)
+}
@@ -0,0 +1,311 @@
+package git
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Repo is a local git working tree.
+type Repo struct {
+	Dir string
+}
+
+// Clone clones the repository at url into dir with a shallow history.
+func Clone(ctx context.Context, url, dir string) (*Repo, error) {
+	r := &Repo{Dir: dir}
+	if _, err := r.run(ctx, "clone", "--depth", "1", url, dir); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// CurrentBranch returns the checked out branch name.
+func (r *Repo) CurrentBranch(ctx context.Context) (string, error) {
+	out, err := r.run(ctx, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// IsDirty reports whether the working tree has uncommitted changes.
+func (r *Repo) IsDirty(ctx context.Context) (bool, error) {
+	out, err := r.run(ctx, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(out) != "", nil
+}
+
+// WorkTree stages the working tree and returns the hash of its tree, so
+// changes between two calls can be detected.
+func (r *Repo) WorkTree(ctx context.Context) (string, error) {
+	if _, err := r.run(ctx, "add", "-A"); err != nil {
+		return "", err
+	}
+	out, err := r.run(ctx, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// ChangedFiles stages the working tree and returns the paths, relative to
+// the root of the repository, of the files changed since HEAD.
+func (r *Repo) ChangedFiles(ctx context.Context) ([]string, error) {
+	if _, err := r.run(ctx, "add", "-A"); err != nil {
+		return nil, err
+	}
+	out, err := r.run(ctx, "diff", "--cached", "--name-only", "--no-renames", "-z", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, f := range strings.Split(out, "\x00") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// Checkout creates or resets the branch at the current commit and checks it out.
+func (r *Repo) Checkout(ctx context.Context, branch string) error {
+	_, err := r.run(ctx, "checkout", "-B", branch)
+	return err
+}
+
+// Signing configures how commits are signed.
+type Signing struct {
+	// Format is the signature format: "openpgp", "ssh", "x509" or "gitsign"
+	// for keyless Sigstore signing.
+	Format string
+	// Key is the signing key: a GPG key ID, or an SSH key path or public key.
+	// It is unused by gitsign.
+	Key string
+	// Program overrides the signing program, e.g. gpg2 or ssh-keygen.
+	Program string
+}
+
+// Enabled reports whether commits should be signed.
+func (s Signing) Enabled() bool {
+	return s.Format != ""
+}
+
+// configs returns the git config entries enabling the signature.
+func (s Signing) configs() ([]string, error) {
+	format, program := s.Format, s.Program
+	switch format {
+	case "openpgp", "ssh", "x509":
+	case "gitsign":
+		format = "x509"
+		if program == "" {
+			program = "gitsign"
+		}
+	default:
+		return nil, fmt.Errorf("unknown signing format %q", s.Format)
+	}
+	configs := []string{"gpg.format=" + format}
+	if s.Key != "" {
+		configs = append(configs, "user.signingkey="+s.Key)
+	}
+	if program != "" {
+		configs = append(configs, "gpg."+format+".program="+program)
+	}
+	return configs, nil
+}
+
+type commitOptions struct {
+	signing Signing
+}
+
+// CommitOption configures how changes are committed.
+type CommitOption func(*commitOptions)
+
+// WithSigning signs the commit as configured.
+func WithSigning(s Signing) CommitOption {
+	return func(o *commitOptions) { o.signing = s }
+}
+
+// CommitAll stages every change and commits it with the given message.
+func (r *Repo) CommitAll(ctx context.Context, message string, opts ...CommitOption) error {
+	var o commitOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if _, err := r.run(ctx, "add", "-A"); err != nil {
+		return err
+	}
+	if !o.signing.Enabled() {
+		_, err := r.run(ctx, "commit", "-m", message)
+		return err
+	}
+	configs, err := o.signing.configs()
+	if err != nil {
+		return err
+	}
+	_, err = r.runConfig(ctx, configs, "commit", "-S", "-m", message)
+	return err
+}
+
+// Push pushes the branch to origin, replacing a previous push of the same
+// branch as long as it was not updated by someone else: the branch must be
+// at the commit fetched by FetchBranch, or not exist when it was not fetched.
+func (r *Repo) Push(ctx context.Context, branch string) error {
+	expect := ""
+	if out, err := r.run(ctx, "rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+branch); err == nil {
+		expect = strings.TrimSpace(out)
+	}
+	_, err := r.run(ctx, "push", "--force-with-lease=refs/heads/"+branch+":"+expect, "origin", branch)
+	return err
+}
+
+// FetchBranch fetches the tip of a branch of origin into its remote-tracking
+// ref, so a later push only replaces it as fetched. It reports false when
+// origin has no such branch.
+func (r *Repo) FetchBranch(ctx context.Context, branch string) (bool, error) {
+	out, err := r.run(ctx, "ls-remote", "--heads", "origin", "refs/heads/"+branch)
+	if err != nil {
+		return false, err
+	}
+	if strings.TrimSpace(out) == "" {
+		return false, nil
+	}
+	if _, err := r.run(ctx, "fetch", "--depth", "1", "origin",
+		"+refs/heads/"+branch+":refs/remotes/origin/"+branch); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SameTree reports whether two commits have the same content, but for the
+// excluded paths.
+func (r *Repo) SameTree(ctx context.Context, a, b string, excludes ...string) (bool, error) {
+	if len(excludes) > 0 {
+		args := []string{"diff", "--name-only", a, b, "--", "."}
+		for _, e := range excludes {
+			args = append(args, ":(exclude)"+e)
+		}
+		out, err := r.run(ctx, args...)
+		if err != nil {
+			return false, err
+		}
+		return strings.TrimSpace(out) == "", nil
+	}
+	out, err := r.run(ctx, "rev-parse", a+"^{tree}", b+"^{tree}")
+	if err != nil {
+		return false, err
+	}
+	trees := strings.Fields(out)
+	return len(trees) == 2 && trees[0] == trees[1], nil
+}
+
+// Committer returns the email of the committer of a commit.
+func (r *Repo) Committer(ctx context.Context, ref string) (string, error) {
+	out, err := r.run(ctx, "log", "-1", "--format=%ce", ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Identity returns the email new commits are committed with.
+func (r *Repo) Identity(ctx context.Context) (string, error) {
+	out, err := r.run(ctx, "var", "GIT_COMMITTER_IDENT")
+	if err != nil {
+		return "", err
+	}
+	_, rest, _ := strings.Cut(out, "<")
+	email, _, _ := strings.Cut(rest, ">")
+	return email, nil
+}
+
+// DeleteBranch deletes a branch of origin.
+func (r *Repo) DeleteBranch(ctx context.Context, branch string) error {
+	_, err := r.run(ctx, "push", "origin", "--delete", "refs/heads/"+branch)
+	return err
+}
+
+func (r *Repo) run(ctx context.Context, args ...string) (string, error) {
+	return r.runConfig(ctx, nil, args...)
+}
+
+// runConfig runs a git subcommand with the given "key=value" config overrides.
+func (r *Repo) runConfig(ctx context.Context, configs []string, args ...string) (string, error) {
+	var full []string
+	for _, c := range configs {
+		full = append(full, "-c", c)
+	}
+	cmd := exec.CommandContext(ctx, "git", append(full, args...)...)
+	if args[0] != "clone" {
+		cmd.Dir = r.Dir
+	}
+	cmd.Env = os.Environ()
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// Export writes the tree of ref into dir, which must exist, without touching
+// the working tree or the index.
+func (r *Repo) Export(ctx context.Context, ref, dir string) error {
+	archive := filepath.Join(dir, ".automata-export.tar")
+	if _, err := r.run(ctx, "archive", "--format=tar", "-o", archive, ref); err != nil {
+		return err
+	}
+	defer os.Remove(archive)
+	f, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("open archive: %w", err)
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read archive: %w", err)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(h.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(filepath.Separator)) {
+			return fmt.Errorf("archive entry %q escapes %s", h.Name, dir)
+		}
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return fmt.Errorf("create %s: %w", target, err)
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, tr, os.FileMode(h.Mode).Perm()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// writeFile writes the content of r to path, creating its parent directory.
+func writeFile(path string, r io.Reader, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return f.Close()
+}
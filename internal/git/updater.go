@@ -0,0 +1,49 @@
+package git
+
+import (
+	"context"
+	"slices"
+
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// Updater finds the latest tags of remote git repositories.
+type Updater struct {
+	opts []FindLatestOption
+}
+
+// NewUpdater constructs an Updater with optional find-latest options.
+func NewUpdater(opts ...FindLatestOption) Updater {
+	return Updater{
+		opts: opts,
+	}
+}
+
+// Update returns the latest tag for the given repository reference.
+func (u Updater) Update(
+	ctx context.Context,
+	repo *RepoRef,
+	opts ...update.Option,
+) (string, error) {
+	ctx = logging.WithAttrs(logging.WithSubsystem(ctx, "git"), "dependency", repo.URL)
+	find := func(ctx context.Context, extra ...update.Option) (string, error) {
+		return FindLatestTag(
+			ctx,
+			repo,
+			append(slices.Clone(u.opts), WithUpdateOptions(slices.Concat(opts, extra)...))...,
+		)
+	}
+	latest, err := find(ctx)
+	if err != nil {
+		return "", err
+	}
+	return policy.Apply(ctx, policy.Proposal{
+		Source:   "git",
+		Name:     repo.URL,
+		From:     repo.Version,
+		To:       latest,
+		Reselect: find,
+	})
+}
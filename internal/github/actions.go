@@ -3,9 +3,19 @@ package github
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
+	"unicode"
 )
 
+// actionName matches the owner or repository name of an action: GitHub only
+// allows ASCII letters, digits, hyphens, underscores and dots in them.
+var actionName = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,100}$`)
+
+// maxActionVersion bounds the length of the version of an action, a git ref
+// name.
+const maxActionVersion = 255
+
 // ActionRef represents a parsed GitHub Action reference "owner/repo@version".
 type ActionRef struct {
 	Owner   string
@@ -20,27 +30,47 @@ func (a ActionRef) String() string {
 }
 
 // ParseActionRef parses a GitHub Actions `uses` string like "owner/repo@v1".
+// The owner and repository must be valid GitHub names, and the version a ref
+// name without spaces or control characters.
 func ParseActionRef(uses string) (ref *ActionRef, err error) {
 	s := strings.TrimSpace(uses)
 	if s == "" {
 		return nil, fmt.Errorf("empty uses")
 	}
-	parts := strings.SplitN(s, "@", 2)
-	path := strings.TrimSpace(parts[0])
+	path, version, _ := strings.Cut(s, "@")
+	path = strings.TrimSpace(path)
 	if path == "" {
 		return nil, fmt.Errorf("invalid uses: empty action or version")
 	}
-	pathParts := strings.Split(path, "/")
-	if len(pathParts) != 2 || strings.TrimSpace(pathParts[0]) == "" ||
-		strings.TrimSpace(pathParts[1]) == "" {
+	owner, repo, ok := strings.Cut(path, "/")
+	owner, repo = strings.TrimSpace(owner), strings.TrimSpace(repo)
+	if !ok || !actionName.MatchString(owner) || !actionName.MatchString(repo) {
 		return nil, fmt.Errorf("invalid action path %q, expected <owner>/<repo>", path)
 	}
-	version := "latest"
-	if len(parts) == 2 {
-		version = strings.TrimSpace(parts[1])
-	}
+	version = strings.TrimSpace(version)
 	if version == "" {
 		version = "latest"
 	}
-	return &ActionRef{Owner: pathParts[0], Repo: pathParts[1], Version: version}, nil
+	if len(version) > maxActionVersion || strings.ContainsFunc(version, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r) || r == unicode.ReplacementChar
+	}) {
+		return nil, fmt.Errorf("invalid action version %q", version)
+	}
+	return &ActionRef{Owner: owner, Repo: repo, Version: version}, nil
+}
+
+// ParseRepoURL extracts the owner and repository name from a GitHub clone URL
+// such as "git@github.com:owner/repo.git" or "https://github.com/owner/repo".
+func ParseRepoURL(url string) (owner, repo string, err error) {
+	s := strings.TrimSuffix(strings.TrimSpace(url), ".git")
+	for _, prefix := range []string{"git@github.com:", "ssh://git@github.com/", "https://github.com/"} {
+		if rest, ok := strings.CutPrefix(s, prefix); ok {
+			owner, repo, ok := strings.Cut(rest, "/")
+			if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+				break
+			}
+			return owner, repo, nil
+		}
+	}
+	return "", "", fmt.Errorf("invalid GitHub repository URL %q", url)
 }
@@ -0,0 +1,42 @@
+package github
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzParseActionRef(f *testing.F) {
+	for _, seed := range []string{
+		"actions/checkout@v4",
+		"actions/checkout",
+		"actions/checkout@",
+		"docker/build-push-action@v5.3.0",
+		"owner/repo@1b2c3d4e5f",
+		"github/codeql-action/init@v3",
+		"@v1",
+		"/repo@v1",
+		" owner / repo @ v1 ",
+		"owner/repo@v1@v2",
+		"ówner/répo@v1.0.0-ß",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, uses string) {
+		ref, err := ParseActionRef(uses)
+		if err != nil {
+			return
+		}
+		for name, part := range map[string]string{"owner": ref.Owner, "repo": ref.Repo, "version": ref.Version} {
+			if part == "" || part != strings.TrimSpace(part) {
+				t.Fatalf("ParseActionRef(%q) %s = %q", uses, name, part)
+			}
+		}
+		again, err := ParseActionRef(ref.String())
+		if err != nil {
+			t.Fatalf("ParseActionRef(%q) = %v: %v", ref.String(), ref, err)
+		}
+		if *again != *ref {
+			t.Fatalf("ParseActionRef(%q) = %v, want %v", ref.String(), again, ref)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/shikanime-studio/automata/internal/mirror"
+)
+
+// tagsBatchSize bounds the repositories of a GraphQL tags query, keeping its
+// cost well under the node limit of the API.
+const tagsBatchSize = 50
+
+// tagsFragment selects a page of tags of a repository and the cursor of the
+// next one.
+const tagsFragment = `fragment tags on RefConnection {
+  nodes { name }
+  pageInfo { hasNextPage endCursor }
+}`
+
+// PrefetchTags fetches the tags of the repositories, by owner/repo, with
+// GraphQL queries of up to 50 repositories each instead of a REST call per
+// repository, so that later lookups of their actions consume no rate limit.
+// Like the REST lookups, it follows the pages of each repository so that
+// both select from every tag. Repositories already prefetched are skipped,
+// as are all of them when the client is unauthenticated, which the GraphQL
+// API refuses, or the context is offline. Repositories the queries could not
+// resolve are left to REST lookups.
+func (gc *Client) PrefetchTags(ctx context.Context, repos []string) error {
+	if !gc.authenticated || mirror.Offline(ctx) {
+		return nil
+	}
+	var pending []string
+	seen := make(map[string]struct{})
+	for _, r := range repos {
+		if _, ok := seen[r]; ok {
+			continue
+		}
+		seen[r] = struct{}{}
+		if _, ok := gc.prefetched(r); !ok && strings.Count(r, "/") == 1 {
+			pending = append(pending, r)
+		}
+	}
+	for len(pending) > 0 {
+		batch := pending[:min(tagsBatchSize, len(pending))]
+		pending = pending[len(batch):]
+		if err := gc.prefetchTags(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefetchTags fetches every tag of a batch of repositories, querying the
+// next page of those with more tags until none has.
+func (gc *Client) prefetchTags(ctx context.Context, repos []string) error {
+	tags := make(map[string][]string, len(repos))
+	cursors := make(map[string]string, len(repos))
+	for len(repos) > 0 {
+		page, err := gc.queryTags(ctx, repos, cursors)
+		if err != nil {
+			return err
+		}
+		var next []string
+		for i, r := range repos {
+			repo := page[i]
+			if repo == nil {
+				// Missing or inaccessible, the REST lookup reports why.
+				delete(tags, r)
+				continue
+			}
+			for _, n := range repo.Refs.Nodes {
+				tags[r] = append(tags[r], n.Name)
+			}
+			if tags[r] == nil {
+				tags[r] = []string{}
+			}
+			if repo.Refs.PageInfo.HasNextPage {
+				cursors[r] = repo.Refs.PageInfo.EndCursor
+				next = append(next, r)
+			}
+		}
+		repos = next
+	}
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if gc.tags == nil {
+		gc.tags = make(map[string][]string)
+	}
+	for r, names := range tags {
+		gc.tags[r] = names
+	}
+	slog.DebugContext(ctx, "prefetched tags", "repositories", len(tags))
+	return nil
+}
+
+// tagsPage is a page of tags of a repository.
+type tagsPage struct {
+	Refs struct {
+		Nodes []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+		PageInfo struct {
+			HasNextPage bool   `json:"hasNextPage"`
+			EndCursor   string `json:"endCursor"`
+		} `json:"pageInfo"`
+	} `json:"refs"`
+}
+
+// queryTags fetches in one query the page of tags of each repository after
+// its cursor, returning nil for the repositories it could not resolve.
+func (gc *Client) queryTags(ctx context.Context, repos []string, cursors map[string]string) ([]*tagsPage, error) {
+	var params, fields []string
+	vars := make(map[string]any, 3*len(repos))
+	for i, r := range repos {
+		owner, name, _ := strings.Cut(r, "/")
+		vars[fmt.Sprintf("o%d", i)] = owner
+		vars[fmt.Sprintf("n%d", i)] = name
+		if c, ok := cursors[r]; ok {
+			vars[fmt.Sprintf("a%d", i)] = c
+		}
+		params = append(params, fmt.Sprintf("$o%d: String!, $n%d: String!, $a%d: String", i, i, i))
+		fields = append(fields, fmt.Sprintf(
+			"  r%d: repository(owner: $o%d, name: $n%d) "+
+				"{ refs(refPrefix: \"refs/tags/\", first: 100, after: $a%d) { ...tags } }",
+			i, i, i, i))
+	}
+	query := fmt.Sprintf("query(%s) {\n%s\n}\n%s", strings.Join(params, ", "), strings.Join(fields, "\n"), tagsFragment)
+	var resp struct {
+		Data   map[string]*tagsPage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	_, err := call(ctx, gc, func(ctx context.Context) (*http.Response, error) {
+		req, err := gc.c.NewRequest(http.MethodPost, "graphql", map[string]any{
+			"query":     query,
+			"variables": vars,
+		})
+		if err != nil {
+			return nil, err
+		}
+		r, err := gc.c.Do(ctx, req, &resp)
+		if r == nil {
+			return nil, err
+		}
+		return r.Response, err
+	})
+	if err != nil {
+		logRateLimited(ctx, err, "repositories", len(repos))
+		return nil, fmt.Errorf("github query tags: %w", err)
+	}
+	if resp.Data == nil && len(resp.Errors) > 0 {
+		return nil, fmt.Errorf("github query tags: %s", resp.Errors[0].Message)
+	}
+	page := make([]*tagsPage, len(repos))
+	for i := range repos {
+		page[i] = resp.Data[fmt.Sprintf("r%d", i)]
+	}
+	return page, nil
+}
+
+// prefetched returns the prefetched tags of a repository, by owner/repo.
+func (gc *Client) prefetched(repo string) ([]string, bool) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	names, ok := gc.tags[repo]
+	return names, ok
+}
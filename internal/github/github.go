@@ -2,13 +2,24 @@ package github
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 
 	"github.com/google/go-github/v55/github"
+	"golang.org/x/mod/semver"
 	"golang.org/x/time/rate"
 
 	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/mirror"
+	"github.com/shikanime-studio/automata/internal/retry"
+	"github.com/shikanime-studio/automata/internal/secret"
+	"github.com/shikanime-studio/automata/internal/timeout"
 	"github.com/shikanime-studio/automata/internal/updater"
 )
 
@@ -16,6 +27,13 @@ import (
 type Client struct {
 	c *github.Client
 	l *rate.Limiter
+	// authenticated is set when the client has a token, which the GraphQL
+	// API requires.
+	authenticated bool
+
+	mu sync.Mutex
+	// tags are the tag names prefetched by owner/repo.
+	tags map[string][]string
 }
 
 // NewLimiter creates a new rate limiter for GitHub API calls.
@@ -36,14 +54,72 @@ func NewLimiter(ctx context.Context, authenticated bool) *rate.Limiter {
 	return limiter
 }
 
-// NewClient creates a new GitHub client using configuration.
+// NewClient creates a new GitHub client using configuration. A token kept
+// in a secret manager is only fetched by the first request, so commands
+// never calling GitHub do not reach the secret manager.
 func NewClient(ctx context.Context, cfg *config.Config) *Client {
-	tok := cfg.GitHubToken()
+	if tok := cfg.GitHubToken(); tok != "" {
+		return NewTokenClient(ctx, tok)
+	}
+	ref, err := cfg.GitHubTokenFrom()
+	if err == nil && ref == nil {
+		return NewTokenClient(ctx, "")
+	}
+	slog.InfoContext(ctx, "Using authenticated GitHub client")
+	t := &secretTransport{
+		fetch: func(ctx context.Context) (string, error) {
+			if err != nil {
+				return "", err
+			}
+			return secret.Fetch(ctx, *ref)
+		},
+		base: http.DefaultTransport,
+	}
+	return &Client{
+		c:             github.NewClient(&http.Client{Transport: t}),
+		l:             NewLimiter(ctx, true),
+		authenticated: true,
+	}
+}
+
+// secretTransport authenticates requests with a token fetched from a secret
+// manager by the first of them.
+type secretTransport struct {
+	fetch func(ctx context.Context) (string, error)
+	base  http.RoundTripper
+
+	mu  sync.Mutex
+	tok string
+}
+
+// RoundTrip fetches the token unless it already was, and sends the request
+// with it.
+func (t *secretTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	if t.tok == "" {
+		tok, err := t.fetch(req.Context())
+		if err != nil {
+			t.mu.Unlock()
+			return nil, fmt.Errorf("github token: %w", err)
+		}
+		t.tok = tok
+	}
+	tok := t.tok
+	t.mu.Unlock()
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+tok)
+	return t.base.RoundTrip(req)
+}
+
+// NewTokenClient creates a new GitHub client authenticated with the given
+// token, or an unauthenticated one when it is empty.
+func NewTokenClient(ctx context.Context, tok string) *Client {
 	if tok != "" {
 		slog.InfoContext(ctx, "Using authenticated GitHub client")
 		return &Client{
-			c: github.NewClient(nil).WithAuthToken(tok),
-			l: NewLimiter(ctx, true),
+			c:             github.NewClient(nil).WithAuthToken(tok),
+			l:             NewLimiter(ctx, true),
+			authenticated: true,
 		}
 	}
 	slog.WarnContext(ctx, "Using unauthenticated GitHub client (rate limited)")
@@ -87,70 +163,434 @@ func (gc *Client) FindLatestActionTag(
 	action *ActionRef,
 	opts ...FindLatestOption,
 ) (string, error) {
+	o := makeFindLatestOptions(opts...)
+	names, err := gc.listTags(ctx, action)
+	if err != nil {
+		return "", err
+	}
+	return updater.SelectLatest(
+		ctx,
+		action.Version,
+		names,
+		updater.WithExcludes(o.excludes),
+		updater.WithCompareOptions(o.updateOptions...),
+		updater.WithLogAttrs("action", action.String()),
+	)
+}
+
+// listTags returns the tag names of the repository of an action, from the
+// metadata snapshot when offline, then from the prefetched tags.
+func (gc *Client) listTags(ctx context.Context, action *ActionRef) ([]string, error) {
+	name := action.Owner + "/" + action.Repo
+	return mirror.FetchVersions(ctx, mirror.Actions, name, func(ctx context.Context) ([]string, error) {
+		if names, ok := gc.prefetched(name); ok {
+			return names, nil
+		}
+		return gc.listAllTags(ctx, action)
+	})
+}
+
+// listAllTags returns the names of every tag of the repository of an
+// action, following the pages of the API so that the latest one is selected
+// from the whole set rather than the first page.
+func (gc *Client) listAllTags(ctx context.Context, action *ActionRef) ([]string, error) {
+	var names []string
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		var resp *github.Response
+		tags, err := call(ctx, gc, func(ctx context.Context) ([]*github.RepositoryTag, error) {
+			tags, r, err := gc.c.Repositories.ListTags(ctx, action.Owner, action.Repo, opts)
+			resp = r
+			return tags, err
+		})
+		if err != nil {
+			logRateLimited(ctx, err, "action", action.String())
+			return nil, fmt.Errorf("github list tags: %w", err)
+		}
+		for _, t := range tags {
+			names = append(names, t.GetName())
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return names, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// License returns the SPDX identifier of the license of a repository at a
+// ref, or "" when it has none GitHub recognizes.
+func (gc *Client) License(ctx context.Context, owner, repo, ref string) (string, error) {
+	u := fmt.Sprintf("repos/%s/%s/license?ref=%s", owner, repo, url.QueryEscape(ref))
+	l, err := call(ctx, gc, func(ctx context.Context) (*github.RepositoryLicense, error) {
+		req, err := gc.c.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		l := new(github.RepositoryLicense)
+		_, err = gc.c.Do(ctx, req, l)
+		return l, err
+	})
+	var respErr *github.ErrorResponse
+	if errors.As(err, &respErr) && respErr.Response != nil &&
+		respErr.Response.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if err != nil {
+		logRateLimited(ctx, err, "repository", owner+"/"+repo, "ref", ref)
+		return "", fmt.Errorf("github get license: %w", err)
+	}
+	return l.GetLicense().GetSPDXID(), nil
+}
+
+// ListDirectory returns the entry names of a directory in a repository.
+func (gc *Client) ListDirectory(ctx context.Context, owner, repo, path string) ([]string, error) {
+	entries, err := call(ctx, gc, func(ctx context.Context) ([]*github.RepositoryContent, error) {
+		_, entries, _, err := gc.c.Repositories.GetContents(ctx, owner, repo, path, nil)
+		return entries, err
+	})
+	if err != nil {
+		logRateLimited(ctx, err, "repository", owner+"/"+repo, "path", path)
+		return nil, fmt.Errorf("github get contents: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.GetName())
+	}
+	return names, nil
+}
+
+// PullRequest identifies a pull request.
+type PullRequest struct {
+	Number int
+	// NodeID is the GraphQL identifier of the pull request.
+	NodeID string
+	URL    string
+	// Branch is the head branch of the pull request.
+	Branch string
+}
+
+// CreatePullRequest opens a pull request from head into base with the given
+// labels.
+func (gc *Client) CreatePullRequest(
+	ctx context.Context,
+	owner, repo, head, base, title, body string,
+	labels ...string,
+) (PullRequest, error) {
 	if err := gc.l.Wait(ctx); err != nil {
-		return "", fmt.Errorf("rate limiter: %w", err)
+		return PullRequest{}, fmt.Errorf("rate limiter: %w", err)
 	}
-	o := makeFindLatestOptions(opts...)
-	tags, _, err := gc.c.Repositories.ListTags(ctx, action.Owner, action.Repo, nil)
+	pr, _, err := gc.c.PullRequests.Create(ctx, owner, repo, &github.NewPullRequest{
+		Title: github.String(title),
+		Head:  github.String(head),
+		Base:  github.String(base),
+		Body:  github.String(body),
+	})
+	if err != nil {
+		return PullRequest{}, fmt.Errorf("github create pull request: %w", err)
+	}
+	created := PullRequest{Number: pr.GetNumber(), NodeID: pr.GetNodeID(), URL: pr.GetHTMLURL(), Branch: head}
+	return created, gc.addLabels(ctx, owner, repo, created.Number, labels)
+}
+
+// FindPullRequest returns the open pull request from the head branch of the
+// repository, reporting false when there is none.
+func (gc *Client) FindPullRequest(ctx context.Context, owner, repo, head string) (PullRequest, bool, error) {
+	prs, err := call(ctx, gc, func(ctx context.Context) ([]*github.PullRequest, error) {
+		prs, _, err := gc.c.PullRequests.List(ctx, owner, repo, &github.PullRequestListOptions{
+			State: "open",
+			Head:  owner + ":" + head,
+		})
+		return prs, err
+	})
+	if err != nil {
+		return PullRequest{}, false, fmt.Errorf("github list pull requests: %w", err)
+	}
+	if len(prs) == 0 {
+		return PullRequest{}, false, nil
+	}
+	pr := prs[0]
+	return PullRequest{
+		Number: pr.GetNumber(),
+		NodeID: pr.GetNodeID(),
+		URL:    pr.GetHTMLURL(),
+		Branch: pr.GetHead().GetRef(),
+	}, true, nil
+}
+
+// ListPullRequests returns the open pull requests of the repository whose
+// head branch is in the repository itself and starts with prefix.
+func (gc *Client) ListPullRequests(ctx context.Context, owner, repo, prefix string) ([]PullRequest, error) {
+	var found []PullRequest
+	opts := &github.PullRequestListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		var resp *github.Response
+		prs, err := call(ctx, gc, func(ctx context.Context) ([]*github.PullRequest, error) {
+			prs, r, err := gc.c.PullRequests.List(ctx, owner, repo, opts)
+			resp = r
+			return prs, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("github list pull requests: %w", err)
+		}
+		for _, pr := range prs {
+			head := pr.GetHead()
+			if head.GetRepo().GetFullName() != owner+"/"+repo || !strings.HasPrefix(head.GetRef(), prefix) {
+				continue
+			}
+			found = append(found, PullRequest{
+				Number: pr.GetNumber(),
+				NodeID: pr.GetNodeID(),
+				URL:    pr.GetHTMLURL(),
+				Branch: head.GetRef(),
+			})
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return found, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// UpdatePullRequest replaces the title and body of a pull request and adds
+// labels to it.
+func (gc *Client) UpdatePullRequest(
+	ctx context.Context,
+	owner, repo string,
+	pr PullRequest,
+	title, body string,
+	labels ...string,
+) error {
+	_, err := call(ctx, gc, func(ctx context.Context) (*github.PullRequest, error) {
+		edited, _, err := gc.c.PullRequests.Edit(ctx, owner, repo, pr.Number, &github.PullRequest{
+			Title: github.String(title),
+			Body:  github.String(body),
+		})
+		return edited, err
+	})
+	if err != nil {
+		return fmt.Errorf("github edit pull request: %w", err)
+	}
+	return gc.addLabels(ctx, owner, repo, pr.Number, labels)
+}
+
+// ClosePullRequest comments on a pull request with the reason it is closed,
+// then closes it.
+func (gc *Client) ClosePullRequest(ctx context.Context, owner, repo string, pr PullRequest, reason string) error {
+	_, err := call(ctx, gc, func(ctx context.Context) (*github.IssueComment, error) {
+		c, _, err := gc.c.Issues.CreateComment(ctx, owner, repo, pr.Number, &github.IssueComment{
+			Body: github.String(reason),
+		})
+		return c, err
+	})
+	if err != nil {
+		return fmt.Errorf("github comment pull request: %w", err)
+	}
+	_, err = call(ctx, gc, func(ctx context.Context) (*github.PullRequest, error) {
+		closed, _, err := gc.c.PullRequests.Edit(ctx, owner, repo, pr.Number, &github.PullRequest{
+			State: github.String("closed"),
+		})
+		return closed, err
+	})
+	if err != nil {
+		return fmt.Errorf("github close pull request: %w", err)
+	}
+	return nil
+}
+
+// addLabels adds labels to an issue or pull request.
+func (gc *Client) addLabels(ctx context.Context, owner, repo string, number int, labels []string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	_, err := call(ctx, gc, func(ctx context.Context) ([]*github.Label, error) {
+		l, _, err := gc.c.Issues.AddLabelsToIssue(ctx, owner, repo, number, labels)
+		return l, err
+	})
+	if err != nil {
+		return fmt.Errorf("github add labels: %w", err)
+	}
+	return nil
+}
+
+// MergeMethods maps the merge methods of the configuration to their GraphQL
+// names.
+var MergeMethods = map[string]string{
+	"merge":  "MERGE",
+	"squash": "SQUASH",
+	"rebase": "REBASE",
+}
+
+// enableAutoMerge is the GraphQL mutation enabling auto-merge, which the REST
+// API does not offer.
+const enableAutoMerge = `mutation($id: ID!, $method: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method}) {
+    clientMutationId
+  }
+}`
+
+// EnableAutoMerge sets a pull request to merge with the given method, merge,
+// squash or rebase, once its required checks pass. The repository must allow
+// auto-merge.
+func (gc *Client) EnableAutoMerge(ctx context.Context, pr PullRequest, method string) error {
+	m, ok := MergeMethods[method]
+	if !ok {
+		return fmt.Errorf("unknown merge method %q", method)
+	}
+	if err := gc.l.Wait(ctx); err != nil {
+		return fmt.Errorf("rate limiter: %w", err)
+	}
+	req, err := gc.c.NewRequest(http.MethodPost, "graphql", map[string]any{
+		"query":     enableAutoMerge,
+		"variables": map[string]string{"id": pr.NodeID, "method": m},
+	})
 	if err != nil {
-		return "", fmt.Errorf("github list tags: %w", err)
-	}
-	bestTag := action.Version
-	for _, t := range tags {
-		if _, ok := o.excludes[*t.Name]; ok {
-			slog.DebugContext(
-				ctx,
-				"tag excluded by exclude list",
-				"tag",
-				*t.Name,
-				"action",
-				action.String(),
-				"baseline",
-				action.Version,
-			)
-			continue
-		}
-		cmp, err := updater.Compare(bestTag, *t.Name, o.updateOptions...)
+		return fmt.Errorf("github enable auto-merge: %w", err)
+	}
+	var resp struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if _, err := gc.c.Do(ctx, req, &resp); err != nil {
+		return fmt.Errorf("github enable auto-merge: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("github enable auto-merge: %s", resp.Errors[0].Message)
+	}
+	return nil
+}
+
+// ReleaseNotes returns the bodies of the releases of a repository after from
+// and up to to, newest first. Releases not tagged with a semantic version, and
+// drafts, are skipped. Pages of releases are listed until one reaches from, as
+// releases are listed from the newest.
+func (gc *Client) ReleaseNotes(ctx context.Context, owner, repo, from, to string) ([]string, error) {
+	lower, err := updater.Canonical(from)
+	if err != nil || !semver.IsValid(lower) {
+		return nil, fmt.Errorf("%q is not a semantic version", from)
+	}
+	upper, err := updater.Canonical(to)
+	if err != nil || !semver.IsValid(upper) {
+		return nil, fmt.Errorf("%q is not a semantic version", to)
+	}
+	var notes []string
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		var resp *github.Response
+		releases, err := call(ctx, gc, func(ctx context.Context) ([]*github.RepositoryRelease, error) {
+			releases, r, err := gc.c.Repositories.ListReleases(ctx, owner, repo, opts)
+			resp = r
+			return releases, err
+		})
+		if err != nil {
+			logRateLimited(ctx, err, "repository", owner+"/"+repo)
+			return nil, fmt.Errorf("github list releases: %w", err)
+		}
+		reached := false
+		for _, r := range releases {
+			v, err := updater.Canonical(r.GetTagName())
+			if err != nil || !semver.IsValid(v) || r.GetDraft() {
+				continue
+			}
+			switch {
+			case semver.Compare(v, lower) <= 0:
+				reached = true
+			case semver.Compare(v, upper) <= 0:
+				notes = append(notes, r.GetBody())
+			}
+		}
+		if reached || resp == nil || resp.NextPage == 0 {
+			return notes, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// UpsertIssue updates the body of the open issue with the given title, or
+// creates it, and returns its URL.
+func (gc *Client) UpsertIssue(ctx context.Context, owner, repo, title, body string) (string, error) {
+	opts := &github.IssueListByRepoOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		var resp *github.Response
+		issues, err := call(ctx, gc, func(ctx context.Context) ([]*github.Issue, error) {
+			issues, r, err := gc.c.Issues.ListByRepo(ctx, owner, repo, opts)
+			resp = r
+			return issues, err
+		})
 		if err != nil {
-			if updater.IsNotValid(err) {
-				slog.DebugContext(
-					ctx,
-					err.Error(),
-					"tag",
-					*t.Name,
-					"action",
-					action.String(),
-					"baseline",
-					action.Version,
-					"err",
-					err,
-				)
+			return "", fmt.Errorf("github list issues: %w", err)
+		}
+		for _, i := range issues {
+			if i.GetTitle() != title || i.IsPullRequest() {
 				continue
 			}
-			return "", fmt.Errorf("failed to compare tags: %w", err)
-		}
-		switch cmp {
-		case updater.Equal:
-			slog.DebugContext(
-				ctx,
-				"tag is equal to baseline",
-				"tag",
-				*t.Name,
-				"action",
-				action.String(),
-			)
-		case updater.Greater:
-			bestTag = *t.Name
-		case updater.Less:
-			slog.DebugContext(
-				ctx,
-				"tag is less than baseline",
-				"tag",
-				*t.Name,
-				"action",
-				action.String(),
-			)
-		}
-	}
-	return bestTag, nil
+			edited, err := call(ctx, gc, func(ctx context.Context) (*github.Issue, error) {
+				edited, _, err := gc.c.Issues.Edit(ctx, owner, repo, i.GetNumber(), &github.IssueRequest{
+					Body: github.String(body),
+				})
+				return edited, err
+			})
+			if err != nil {
+				return "", fmt.Errorf("github edit issue: %w", err)
+			}
+			return edited.GetHTMLURL(), nil
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	if err := gc.l.Wait(ctx); err != nil {
+		return "", fmt.Errorf("rate limiter: %w", err)
+	}
+	created, _, err := gc.c.Issues.Create(ctx, owner, repo, &github.IssueRequest{
+		Title: github.String(title),
+		Body:  github.String(body),
+	})
+	if err != nil {
+		return "", fmt.Errorf("github create issue: %w", err)
+	}
+	return created.GetHTMLURL(), nil
+}
+
+// call runs an idempotent API call under the rate limiter and the GitHub
+// timeout, retrying transient failures and server errors.
+func call[T any](ctx context.Context, gc *Client, fn func(context.Context) (T, error)) (T, error) {
+	return retry.Value(ctx, isRetryable, func() (T, error) {
+		if err := gc.l.Wait(ctx); err != nil {
+			var zero T
+			return zero, fmt.Errorf("rate limiter: %w", err)
+		}
+		ctx, cancel := timeout.Context(ctx, timeout.GitHub)
+		defer cancel()
+		return fn(ctx)
+	})
+}
+
+// isRetryable reports whether an API call failed transiently or with a server
+// error. Rate-limited calls are not retried, as limits reset far later.
+func isRetryable(err error) bool {
+	var rateErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &rateErr) || errors.As(err, &abuseErr) {
+		return false
+	}
+	var respErr *github.ErrorResponse
+	if errors.As(err, &respErr) && respErr.Response != nil {
+		return retry.IsServerError(respErr.Response.StatusCode)
+	}
+	return retry.IsTransient(err)
+}
+
+// logRateLimited warns about lookups GitHub refused for exceeding its rate
+// limits.
+func logRateLimited(ctx context.Context, err error, args ...any) {
+	var rateErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &rateErr) || errors.As(err, &abuseErr) {
+		slog.WarnContext(ctx, "rate limited lookup", append(append([]any{logging.RateLimited.Attr()}, args...), "err", err)...)
+	}
 }
@@ -0,0 +1,277 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/shikanime-studio/automata/internal/mirror"
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+func TestFindLatestActionTag_Strategy(t *testing.T) {
+	snap := mirror.NewSnapshot(time.Time{})
+	snap.Add(mirror.Actions, "actions/checkout", []mirror.Candidate{
+		{Version: "v1.2.4"}, {Version: "v1.2.9"}, {Version: "v1.3.0"}, {Version: "v1.3.1"}, {Version: "v2.0.0"},
+	})
+	ctx := mirror.WithOffline(context.Background(), snap)
+	gc := NewTokenClient(ctx, "")
+	for strategy, want := range map[updater.Strategy]string{
+		updater.FullUpdate:  "v2.0.0",
+		updater.MinorUpdate: "v1.3.1",
+		updater.PatchUpdate: "v1.2.9",
+	} {
+		got, err := gc.FindLatestActionTag(
+			ctx,
+			&ActionRef{Owner: "actions", Repo: "checkout", Version: "v1.2.3"},
+			WithUpdateOptions(updater.WithStrategy(strategy)),
+		)
+		if err != nil {
+			t.Fatalf("strategy %d: unexpected error: %v", strategy, err)
+		}
+		if got != want {
+			t.Errorf("strategy %d: got %s, want %s", strategy, got, want)
+		}
+	}
+}
+
+func TestFindLatestActionTag_Pages(t *testing.T) {
+	pages := [][]map[string]any{
+		{{"name": "v1.0.0"}, {"name": "v1.1.0"}},
+		{{"name": "v2.0.0"}, {"name": "v1.2.0"}},
+	}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("per_page"); got != "100" {
+			t.Errorf("got per_page %q, want 100", got)
+		}
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			_, _ = fmt.Sscan(p, &page)
+		}
+		if page < len(pages) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?per_page=100&page=%d>; rel="next"`, srv.URL, r.URL.Path, page+1))
+		}
+		_ = json.NewEncoder(w).Encode(pages[page-1])
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	gc := NewTokenClient(ctx, "")
+	gc.c.BaseURL, _ = url.Parse(srv.URL + "/")
+	gc.l = rate.NewLimiter(rate.Inf, 1)
+	got, err := gc.FindLatestActionTag(ctx, &ActionRef{Owner: "org", Repo: "action", Version: "v1.0.0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "v2.0.0" {
+		t.Errorf("got %s, want v2.0.0 from the second page", got)
+	}
+}
+
+func TestPrefetchTags(t *testing.T) {
+	var queries int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/graphql" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		queries++
+		var body struct {
+			Variables map[string]string `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		// Tags are served one per page, the cursor being the index of the
+		// next one.
+		tags := map[string][]string{
+			"actions/checkout": {"v4.1.0", "v4.2.0"},
+			"actions/setup-go": {"v5.1.0", "v5.0.0", "v4.0.0"},
+		}
+		data := map[string]any{}
+		for i := 0; body.Variables[fmt.Sprintf("o%d", i)] != ""; i++ {
+			repo := body.Variables[fmt.Sprintf("o%d", i)] + "/" + body.Variables[fmt.Sprintf("n%d", i)]
+			var at int
+			if c := body.Variables[fmt.Sprintf("a%d", i)]; c != "" {
+				_, _ = fmt.Sscan(c, &at)
+			}
+			data[fmt.Sprintf("r%d", i)] = map[string]any{"refs": map[string]any{
+				"nodes": []map[string]string{{"name": tags[repo][at]}},
+				"pageInfo": map[string]any{
+					"hasNextPage": at+1 < len(tags[repo]),
+					"endCursor":   fmt.Sprint(at + 1),
+				},
+			}}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	gc := NewTokenClient(ctx, "token")
+	gc.c.BaseURL, _ = url.Parse(srv.URL + "/")
+	gc.l = rate.NewLimiter(rate.Inf, 1)
+	repos := []string{"actions/checkout", "actions/setup-go", "actions/checkout"}
+	if err := gc.PrefetchTags(ctx, repos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := gc.PrefetchTags(ctx, repos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queries != 3 {
+		t.Errorf("got %d queries, want one per page of the repository with the most tags", queries)
+	}
+	for ref, want := range map[string]string{
+		"actions/checkout@v4.1.0": "v4.2.0",
+		"actions/setup-go@v5.0.0": "v5.1.0",
+	} {
+		action, err := ParseActionRef(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := gc.FindLatestActionTag(ctx, action)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", ref, err)
+		}
+		if got != want {
+			t.Errorf("%s: got %s, want %s", ref, got, want)
+		}
+	}
+}
+
+func TestReleaseNotes(t *testing.T) {
+	pages := [][]map[string]any{
+		{
+			{"tag_name": "v3.0.0", "body": "three"},
+			{"tag_name": "v2.1.0", "body": "two.one"},
+			{"tag_name": "nightly", "body": "nightly"},
+			{"tag_name": "v2.0.0", "body": "draft", "draft": true},
+		},
+		{
+			{"tag_name": "v2.0.0", "body": "two"},
+			{"tag_name": "v1.0.0", "body": "one"},
+		},
+		{
+			{"tag_name": "v0.9.0", "body": "zero"},
+		},
+	}
+	var requests int
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			_, _ = fmt.Sscan(p, &page)
+		}
+		if page < len(pages) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=%d>; rel="next"`, srv.URL, r.URL.Path, page+1))
+		}
+		_ = json.NewEncoder(w).Encode(pages[page-1])
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	gc := NewTokenClient(ctx, "")
+	gc.c.BaseURL, _ = url.Parse(srv.URL + "/")
+	gc.l = rate.NewLimiter(rate.Inf, 1)
+	notes, err := gc.ReleaseNotes(ctx, "org", "app", "1.0.0", "v2.1.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fmt.Sprint(notes); got != "[two.one two]" {
+		t.Errorf("got notes %s", got)
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+	if _, err := gc.ReleaseNotes(ctx, "org", "app", "main", "v2.1.0"); err == nil {
+		t.Error("expected an error for a version that is not semantic")
+	}
+}
+
+func TestListPullRequests(t *testing.T) {
+	pr := func(number int, repo, ref string) map[string]any {
+		return map[string]any{
+			"number": number,
+			"head":   map[string]any{"ref": ref, "repo": map[string]any{"full_name": repo}},
+		}
+	}
+	pages := [][]map[string]any{
+		{
+			pr(1, "org/app", "automata/deps/nginx"),
+			pr(2, "org/app", "feature"),
+			pr(3, "fork/app", "automata/deps/redis"),
+		},
+		{
+			pr(4, "org/app", "automata/deps/redis"),
+		},
+	}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("state"); got != "open" {
+			t.Errorf("got state %q, want open", got)
+		}
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			_, _ = fmt.Sscan(p, &page)
+		}
+		if page < len(pages) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?state=open&page=%d>; rel="next"`, srv.URL, r.URL.Path, page+1))
+		}
+		_ = json.NewEncoder(w).Encode(pages[page-1])
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	gc := NewTokenClient(ctx, "")
+	gc.c.BaseURL, _ = url.Parse(srv.URL + "/")
+	gc.l = rate.NewLimiter(rate.Inf, 1)
+	prs, err := gc.ListPullRequests(ctx, "org", "app", "automata/deps/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, p := range prs {
+		got = append(got, fmt.Sprintf("%d:%s", p.Number, p.Branch))
+	}
+	if fmt.Sprint(got) != "[1:automata/deps/nginx 4:automata/deps/redis]" {
+		t.Errorf("got pull requests %v", got)
+	}
+}
+
+func TestSecretTransportFetchesTokenOnce(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("got Authorization %q", got)
+		}
+	}))
+	defer srv.Close()
+
+	fetches := 0
+	c := &http.Client{Transport: &secretTransport{
+		fetch: func(context.Context) (string, error) {
+			fetches++
+			return "secret", nil
+		},
+		base: http.DefaultTransport,
+	}}
+	for range 2 {
+		resp, err := c.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = resp.Body.Close()
+	}
+	if fetches != 1 {
+		t.Errorf("got %d fetches, want 1", fetches)
+	}
+}
@@ -2,7 +2,11 @@ package github
 
 import (
 	"context"
+	"slices"
 
+	"github.com/shikanime-studio/automata/internal/license"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
 	update "github.com/shikanime-studio/automata/internal/updater"
 )
 
@@ -26,9 +30,45 @@ func (u Updater) Update(
 	action *ActionRef,
 	opts ...update.Option,
 ) (string, error) {
-	return u.c.FindLatestActionTag(
-		ctx,
-		action,
-		append(u.opts, WithUpdateOptions(opts...))...,
+	ctx = logging.WithAttrs(
+		logging.WithSubsystem(ctx, "github"),
+		"dependency",
+		action.Owner+"/"+action.Repo,
 	)
+	find := func(ctx context.Context, extra ...update.Option) (string, error) {
+		return u.c.FindLatestActionTag(
+			ctx,
+			action,
+			append(slices.Clone(u.opts), WithUpdateOptions(slices.Concat(opts, extra)...))...,
+		)
+	}
+	latest, err := find(ctx)
+	if err != nil {
+		return "", err
+	}
+	version, err := policy.Apply(ctx, policy.Proposal{
+		Source:   "github",
+		Name:     action.Owner + "/" + action.Repo,
+		From:     action.Version,
+		To:       latest,
+		Reselect: find,
+	})
+	if err != nil {
+		return "", err
+	}
+	license.Check(ctx, "github", action.Owner+"/"+action.Repo, action.Version, version,
+		func(ctx context.Context, ref string) (string, error) {
+			return u.c.License(ctx, action.Owner, action.Repo, ref)
+		})
+	return version, nil
+}
+
+// Prefetch fetches the tags of the repositories of the actions in batched
+// queries, so that updating each of them makes no further call.
+func (u Updater) Prefetch(ctx context.Context, actions []*ActionRef) error {
+	repos := make([]string, 0, len(actions))
+	for _, a := range actions {
+		repos = append(repos, a.Owner+"/"+a.Repo)
+	}
+	return u.c.PrefetchTags(ctx, repos)
 }
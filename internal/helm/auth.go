@@ -0,0 +1,126 @@
+package helm
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/crane"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/secret"
+)
+
+type repositoriesKey struct{}
+
+// repositories are the declared repositories of a context, with the secrets
+// of those already fetched from.
+type repositories struct {
+	decls []config.HelmRepository
+
+	mu       sync.Mutex
+	resolved map[int]config.HelmRepository
+}
+
+// WithRepositories returns a context authenticating the repositories fetched
+// with it with the given credentials. The secrets of a repository are only
+// fetched when it first is.
+func WithRepositories(ctx context.Context, repos []config.HelmRepository) context.Context {
+	return context.WithValue(ctx, repositoriesKey{}, &repositories{
+		decls:    repos,
+		resolved: make(map[int]config.HelmRepository),
+	})
+}
+
+// Repository returns the credentials of the repository at url from the
+// context: those of the longest declared URL it is or is under.
+func Repository(ctx context.Context, url string) (config.HelmRepository, bool, error) {
+	repos, _ := ctx.Value(repositoriesKey{}).(*repositories)
+	if repos == nil {
+		return config.HelmRepository{}, false, nil
+	}
+	url = strings.TrimSuffix(url, "/")
+	best := -1
+	for i, r := range repos.decls {
+		prefix := strings.TrimSuffix(r.URL, "/")
+		if prefix == "" || (url != prefix && !strings.HasPrefix(url, prefix+"/")) {
+			continue
+		}
+		if best < 0 || len(prefix) > len(strings.TrimSuffix(repos.decls[best].URL, "/")) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return config.HelmRepository{}, false, nil
+	}
+	repos.mu.Lock()
+	defer repos.mu.Unlock()
+	if r, ok := repos.resolved[best]; ok {
+		return r, true, nil
+	}
+	r, err := secret.ResolveHelmRepository(ctx, repos.decls[best])
+	if err != nil {
+		return r, true, err
+	}
+	repos.resolved[best] = r
+	return r, true, nil
+}
+
+// transport returns the transport of the requests to a repository, trusting
+// its CA bundle and presenting its client certificate.
+func transport(repo config.HelmRepository) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if repo.CAFile == "" && repo.CertFile == "" && !repo.InsecureSkipTLSVerify {
+		return t, nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: repo.InsecureSkipTLSVerify}
+	if repo.CAFile != "" {
+		ca, err := os.ReadFile(repo.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("ca file %s has no valid certificate", repo.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if repo.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(repo.CertFile, repo.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
+
+// authorize sets the credentials of a repository on a request: its bearer
+// token, else its basic auth credentials.
+func authorize(req *http.Request, repo config.HelmRepository) {
+	switch {
+	case repo.Token != "":
+		req.Header.Set("Authorization", "Bearer "+repo.Token)
+	case repo.Username != "" || repo.Password != "":
+		req.SetBasicAuth(repo.Username, repo.Password)
+	}
+}
+
+// authenticator returns the credentials of an OCI repository, deferring to
+// the docker config when it declares none.
+func authenticator(repo config.HelmRepository) crane.Option {
+	switch {
+	case repo.Token != "":
+		return crane.WithAuth(&authn.Bearer{Token: repo.Token})
+	case repo.Username != "" || repo.Password != "":
+		return crane.WithAuth(&authn.Basic{Username: repo.Username, Password: repo.Password})
+	}
+	return crane.WithAuthFromKeychain(authn.DefaultKeychain)
+}
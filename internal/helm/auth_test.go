@@ -0,0 +1,114 @@
+package helm
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/testsource"
+)
+
+func TestRepository(t *testing.T) {
+	ctx := WithRepositories(context.Background(), []config.HelmRepository{
+		{URL: "https://charts.example.com/", Username: "org"},
+		{URL: "https://charts.example.com/private", Username: "team"},
+		{URL: "https://broken.example.com", PasswordFrom: &config.SecretRef{Provider: "exec", Command: []string{"false"}}},
+	})
+	tests := map[string]string{
+		"https://charts.example.com":               "org",
+		"https://charts.example.com/public":        "org",
+		"https://charts.example.com/private/":      "team",
+		"https://charts.example.com/private-other": "org",
+		"https://other.example.com":                "",
+	}
+	for url, want := range tests {
+		repo, ok, err := Repository(ctx, url)
+		if err != nil {
+			t.Fatalf("Repository(%s): %v", url, err)
+		}
+		if ok != (want != "") || repo.Username != want {
+			t.Errorf("Repository(%s) = %q, %v, want %q", url, repo.Username, ok, want)
+		}
+	}
+	if _, _, err := Repository(ctx, "https://broken.example.com/charts"); err == nil {
+		t.Error("Repository(https://broken.example.com/charts): expected the secret to be fetched")
+	}
+}
+
+func TestListReleasesAuthenticates(t *testing.T) {
+	index := `entries:
+  app:
+    - version: 1.1.0
+      appVersion: "2.1"
+    - version: 1.0.0
+      appVersion: "2.0"
+  other:
+    - version: 0.1.0
+`
+	var requests int
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/bearer/index.yaml" && r.URL.Path != "/basic/index.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		user, pass, basic := r.BasicAuth()
+		bearer := r.Header.Get("Authorization") == "Bearer secret"
+		if r.URL.Path == "/bearer/index.yaml" && !bearer ||
+			r.URL.Path == "/basic/index.yaml" && (!basic || user != "org" || pass != "secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(index))
+	}))
+	defer srv.Close()
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(ca, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithRepositories(context.Background(), []config.HelmRepository{
+		{URL: srv.URL + "/bearer", Token: "secret", CAFile: ca},
+		{URL: srv.URL + "/basic", Username: "org", Password: "secret", CAFile: ca},
+	})
+
+	for _, repo := range []string{"bearer", "basic"} {
+		releases, err := searchReleases(ctx, &ChartRef{RepoURL: srv.URL + "/" + repo, Name: "app"})
+		if err != nil {
+			t.Fatalf("%s: searchReleases: %v", repo, err)
+		}
+		want := []Release{{Version: "1.1.0", AppVersion: "2.1"}, {Version: "1.0.0", AppVersion: "2.0"}}
+		if !reflect.DeepEqual(releases, want) {
+			t.Errorf("%s: releases = %+v, want %+v", repo, releases, want)
+		}
+	}
+	if _, err := searchReleases(ctx, &ChartRef{RepoURL: srv.URL + "/bearer", Name: "other"}); err != nil {
+		t.Errorf("searchReleases(other): %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("got %d index requests, want 2", requests)
+	}
+	if _, err := searchReleases(ctx, &ChartRef{RepoURL: srv.URL + "/bearer", Name: "missing"}); err == nil {
+		t.Error("searchReleases(missing) succeeded, want chart not found")
+	}
+}
+
+func TestListReleasesOCI(t *testing.T) {
+	reg := testsource.NewRegistry(map[string][]string{"charts/app": {"1.0.0", "1.1.0_build.1"}})
+	defer reg.Close()
+	chart := &ChartRef{RepoURL: "oci://" + reg.Host() + "/charts", Name: "app"}
+	releases, err := searchReleases(context.Background(), chart)
+	if err != nil {
+		t.Fatalf("searchReleases: %v", err)
+	}
+	want := []Release{{Version: "1.0.0"}, {Version: "1.1.0+build.1"}}
+	if !reflect.DeepEqual(releases, want) {
+		t.Errorf("releases = %+v, want %+v", releases, want)
+	}
+}
@@ -3,12 +3,9 @@ package helm
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"log/slog"
-	"os"
-	"os/exec"
 
+	"github.com/shikanime-studio/automata/internal/mirror"
 	"github.com/shikanime-studio/automata/internal/updater"
 )
 
@@ -23,52 +20,65 @@ func (c *ChartRef) String() string {
 	return fmt.Sprintf("%s/%s:%s", c.RepoURL, c.Name, c.Version)
 }
 
+// Release is a published version of a chart.
+type Release struct {
+	Version    string
+	AppVersion string
+}
+
 // ListVersions returns all versions available for the given chart in the repo.
 func ListVersions(ctx context.Context, chart *ChartRef) ([]string, error) {
-	repoAdd := exec.CommandContext(
-		ctx,
-		"helm",
-		"repo",
-		"add",
-		chart.Name,
-		chart.RepoURL,
-		"--force-update",
-	)
-	repoAdd.Env = os.Environ()
-	if err := repoAdd.Run(); err != nil {
-		return nil, fmt.Errorf("helm repo add failed: %w", err)
+	releases, err := ListReleases(ctx, chart)
+	if err != nil {
+		return nil, err
 	}
-	repoUpdate := exec.CommandContext(ctx, "helm", "repo", "update")
-	repoUpdate.Env = os.Environ()
-	if err := repoUpdate.Run(); err != nil {
-		return nil, fmt.Errorf("helm repo update failed: %w", err)
+	vers := make([]string, 0, len(releases))
+	for _, r := range releases {
+		if r.Version != "" {
+			vers = append(vers, r.Version)
+		}
 	}
-	search := exec.CommandContext(
-		ctx,
-		"helm",
-		"search",
-		"repo",
-		chart.Name,
-		"--output",
-		"json",
-		"--versions",
-	)
-	out, err := search.Output()
+	return vers, nil
+}
+
+// AppVersion returns the default application version of the chart version.
+func AppVersion(ctx context.Context, chart *ChartRef) (string, error) {
+	releases, err := ListReleases(ctx, chart)
 	if err != nil {
-		return nil, fmt.Errorf("helm search repo failed: %w", err)
+		return "", err
 	}
-
-	var list []map[string]any
-	if err := json.Unmarshal(out, &list); err != nil {
-		return nil, fmt.Errorf("helm search repo unmarshal failed: %w", err)
+	for _, r := range releases {
+		if r.Version == chart.Version {
+			return r.AppVersion, nil
+		}
 	}
-	vers := make([]string, 0, len(list))
-	for _, it := range list {
-		if v, ok := it["version"].(string); ok && v != "" {
-			vers = append(vers, v)
+	return "", fmt.Errorf("version %s of chart %s not found", chart.Version, chart.Name)
+}
+
+// ListReleases returns all releases available for the given chart in the repo,
+// retrying failed index fetches. The repository is authenticated with the
+// credentials of the context; OCI repositories, without an index, list their
+// tags.
+func ListReleases(ctx context.Context, chart *ChartRef) ([]Release, error) {
+	candidates, err := mirror.Fetch(ctx, mirror.Charts, chart.RepoURL+"/"+chart.Name, func(ctx context.Context) ([]mirror.Candidate, error) {
+		releases, err := searchReleases(ctx, chart)
+		if err != nil {
+			return nil, err
+		}
+		candidates := make([]mirror.Candidate, len(releases))
+		for i, r := range releases {
+			candidates[i] = mirror.Candidate{Version: r.Version, AppVersion: r.AppVersion}
 		}
+		return candidates, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return vers, nil
+	releases := make([]Release, len(candidates))
+	for i, c := range candidates {
+		releases[i] = Release{Version: c.Version, AppVersion: c.AppVersion}
+	}
+	return releases, nil
 }
 
 type findLatestOptions struct {
@@ -115,59 +125,12 @@ func FindLatestVersion(
 	if err != nil {
 		return "", err
 	}
-	bestVers := chart.Version
-	for _, v := range vers {
-		if _, ok := o.excludes[v]; ok {
-			slog.DebugContext(
-				ctx,
-				"chart version excluded by exclude list",
-				"version",
-				v,
-				"chart",
-				chart.String(),
-			)
-			continue
-		}
-		cmp, err := updater.Compare(bestVers, v, o.updateOptions...)
-		if err != nil {
-			if updater.IsNotValid(err) {
-				slog.DebugContext(
-					ctx,
-					err.Error(),
-					"version",
-					v,
-					"chart",
-					chart.String(),
-					"err",
-					err,
-				)
-				continue
-			}
-			return "", fmt.Errorf("failed to compare versions: %w", err)
-		}
-		switch cmp {
-		case updater.Equal:
-			slog.DebugContext(
-				ctx,
-				"chart version is equal to baseline",
-				"version",
-				v,
-				"chart",
-				chart.String(),
-			)
-			bestVers = v
-		case updater.Greater:
-			bestVers = v
-		case updater.Less:
-			slog.DebugContext(
-				ctx,
-				"chart version is less than baseline",
-				"version",
-				v,
-				"chart",
-				chart.String(),
-			)
-		}
-	}
-	return bestVers, nil
+	return updater.SelectLatest(
+		ctx,
+		chart.Version,
+		vers,
+		updater.WithExcludes(o.excludes),
+		updater.WithCompareOptions(o.updateOptions...),
+		updater.WithLogAttrs("chart", chart.String()),
+	)
 }
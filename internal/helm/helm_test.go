@@ -0,0 +1,32 @@
+package helm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/mirror"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+func TestFindLatestVersion_Strategy(t *testing.T) {
+	chart := ChartRef{RepoURL: "https://charts.example.com", Name: "app", Version: "1.2.3"}
+	snap := mirror.NewSnapshot(time.Time{})
+	snap.Add(mirror.Charts, chart.RepoURL+"/"+chart.Name, []mirror.Candidate{
+		{Version: "1.2.4"}, {Version: "1.2.9"}, {Version: "1.3.0"}, {Version: "1.3.1"}, {Version: "2.0.0"},
+	})
+	ctx := mirror.WithOffline(context.Background(), snap)
+	for strategy, want := range map[update.Strategy]string{
+		update.FullUpdate:  "2.0.0",
+		update.MinorUpdate: "1.3.1",
+		update.PatchUpdate: "1.2.9",
+	} {
+		got, err := FindLatestVersion(ctx, &chart, WithUpdateOptions(update.WithStrategy(strategy)))
+		if err != nil {
+			t.Fatalf("strategy %d: unexpected error: %v", strategy, err)
+		}
+		if got != want {
+			t.Errorf("strategy %d: got %s, want %s", strategy, got, want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/retry"
+	"github.com/shikanime-studio/automata/internal/timeout"
+)
+
+// indexTTL is how long a fetched repository index is reused, as it lists
+// the releases of every chart of the repository.
+const indexTTL = 5 * time.Minute
+
+// repoIndex is a cached repository index.
+type repoIndex struct {
+	mu      sync.Mutex
+	fetched time.Time
+	entries map[string][]Release
+}
+
+// indexes caches the repository indexes by URL.
+var indexes sync.Map
+
+// index is the subset of the index.yaml of a repository automata reads.
+type index struct {
+	Entries map[string][]struct {
+		Version    string `yaml:"version"`
+		AppVersion string `yaml:"appVersion"`
+	} `yaml:"entries"`
+}
+
+// statusError is an unexpected status of a repository.
+type statusError struct {
+	url    string
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("get %s: unexpected status %s", e.url, e.status)
+}
+
+// searchReleases lists the releases of a chart from the index of its
+// repository, or from the tags of its OCI repository.
+func searchReleases(ctx context.Context, chart *ChartRef) ([]Release, error) {
+	repo, _, err := Repository(ctx, chart.RepoURL)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(chart.RepoURL, "oci://") {
+		return ociReleases(ctx, chart, repo)
+	}
+	v, _ := indexes.LoadOrStore(strings.TrimSuffix(chart.RepoURL, "/"), &repoIndex{})
+	ri := v.(*repoIndex)
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+	if ri.entries == nil || time.Since(ri.fetched) > indexTTL {
+		entries, err := fetchIndex(ctx, chart.RepoURL, repo)
+		if err != nil {
+			return nil, err
+		}
+		ri.entries, ri.fetched = entries, time.Now()
+	}
+	releases, ok := ri.entries[chart.Name]
+	if !ok {
+		return nil, fmt.Errorf("chart %s not found in %s", chart.Name, chart.RepoURL)
+	}
+	return releases, nil
+}
+
+// fetchIndex fetches the index of a repository, retrying server errors.
+func fetchIndex(ctx context.Context, repoURL string, repo config.HelmRepository) (map[string][]Release, error) {
+	t, err := transport(repo)
+	if err != nil {
+		return nil, fmt.Errorf("helm repository %s: %w", repoURL, err)
+	}
+	client := &http.Client{Transport: t}
+	url := strings.TrimSuffix(repoURL, "/") + "/index.yaml"
+	data, err := retry.Value(ctx, isRetryable, func() ([]byte, error) {
+		ctx, cancel := timeout.Context(ctx, timeout.Helm)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("create index request: %w", err)
+		}
+		authorize(req, repo)
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("get %s: %w", url, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, &statusError{url: url, code: resp.StatusCode, status: resp.Status}
+		}
+		return io.ReadAll(resp.Body)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var idx index
+	if err := yaml.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", url, err)
+	}
+	entries := make(map[string][]Release, len(idx.Entries))
+	for name, versions := range idx.Entries {
+		releases := make([]Release, len(versions))
+		for i, v := range versions {
+			releases[i] = Release{Version: v.Version, AppVersion: v.AppVersion}
+		}
+		entries[name] = releases
+	}
+	return entries, nil
+}
+
+// ociReleases lists the releases of a chart of an OCI repository from its
+// tags, in which helm replaces the "+" of versions with "_". Tags carry no
+// application version.
+func ociReleases(ctx context.Context, chart *ChartRef, repo config.HelmRepository) ([]Release, error) {
+	t, err := transport(repo)
+	if err != nil {
+		return nil, fmt.Errorf("helm repository %s: %w", chart.RepoURL, err)
+	}
+	ref := strings.TrimSuffix(strings.TrimPrefix(chart.RepoURL, "oci://"), "/") + "/" + chart.Name
+	tags, err := retry.Value(ctx, nil, func() ([]string, error) {
+		ctx, cancel := timeout.Context(ctx, timeout.Helm)
+		defer cancel()
+		return crane.ListTags(ref, authenticator(repo), crane.WithTransport(t), crane.WithContext(ctx))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list tags of %s: %w", ref, err)
+	}
+	releases := make([]Release, len(tags))
+	for i, tag := range tags {
+		releases[i] = Release{Version: strings.ReplaceAll(tag, "_", "+")}
+	}
+	return releases, nil
+}
+
+// isRetryable reports whether fetching an index failed with a server error
+// or for a transient reason.
+func isRetryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return retry.IsServerError(se.code) || se.code == http.StatusTooManyRequests
+	}
+	return retry.IsTransient(err)
+}
@@ -0,0 +1,99 @@
+package helm
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// Preset is the well-known source and upgrade path of a popular chart,
+// applied when its references configure none.
+type Preset struct {
+	// Repositories are the repositories publishing the chart, the canonical
+	// one first.
+	Repositories []string
+	// SameMajor keeps the chart within the major version it is on.
+	SameMajor bool
+	// MinorStep bounds the minor versions a bump moves forward by, e.g. 1
+	// for charts to be upgraded one minor at a time, 0 for no bound.
+	MinorStep int
+}
+
+// Presets maps chart names to their presets.
+var Presets = map[string]Preset{
+	// cert-manager supports upgrades one minor version at a time, its CRDs
+	// and webhooks changing between them.
+	"cert-manager": {
+		Repositories: []string{"https://charts.jetstack.io", "oci://quay.io/jetstack/charts"},
+		SameMajor:    true,
+		MinorStep:    1,
+	},
+	// ingress-nginx chart majors rename values and drop controller releases.
+	"ingress-nginx": {
+		Repositories: []string{"https://kubernetes.github.io/ingress-nginx"},
+		SameMajor:    true,
+	},
+}
+
+// LookupPreset returns the preset of a chart, when it has one and is pulled
+// from one of its repositories, or from none.
+func LookupPreset(chart *ChartRef) (Preset, bool) {
+	p, ok := Presets[chart.Name]
+	if !ok {
+		return Preset{}, false
+	}
+	repo := strings.TrimSuffix(chart.RepoURL, "/")
+	if repo != "" && !slices.Contains(p.Repositories, repo) {
+		return Preset{}, false
+	}
+	return p, true
+}
+
+// Repository returns the canonical repository of the chart.
+func (p Preset) Repository() string {
+	if len(p.Repositories) == 0 {
+		return ""
+	}
+	return p.Repositories[0]
+}
+
+// Options returns the comparison options bounding the versions a chart on
+// the given version may be updated to.
+func (p Preset) Options(current string) []update.Option {
+	if !p.SameMajor && p.MinorStep == 0 {
+		return nil
+	}
+	base := "v" + strings.TrimPrefix(current, "v")
+	if !semver.IsValid(base) {
+		return nil
+	}
+	return []update.Option{
+		update.WithCandidateCheck(func(_ context.Context, candidate string) (bool, error) {
+			return p.allows(base, "v"+strings.TrimPrefix(candidate, "v")), nil
+		}),
+	}
+}
+
+// allows reports whether the preset allows an update from base to target,
+// both canonical semantic versions.
+func (p Preset) allows(base, target string) bool {
+	if p.SameMajor && semver.Major(base) != semver.Major(target) {
+		return false
+	}
+	if p.MinorStep > 0 && semver.Major(base) == semver.Major(target) {
+		return minor(target)-minor(base) <= p.MinorStep
+	}
+	return true
+}
+
+// minor returns the minor version of a canonical semantic version.
+func minor(v string) int {
+	_, rest, _ := strings.Cut(semver.MajorMinor(v), ".")
+	n, _ := strconv.Atoi(rest)
+	return n
+}
@@ -0,0 +1,53 @@
+package helm
+
+import (
+	"context"
+	"testing"
+
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+func TestLookupPreset(t *testing.T) {
+	tests := []struct {
+		chart ChartRef
+		want  bool
+	}{
+		{ChartRef{Name: "cert-manager", RepoURL: "https://charts.jetstack.io/"}, true},
+		{ChartRef{Name: "cert-manager", RepoURL: "oci://quay.io/jetstack/charts"}, true},
+		{ChartRef{Name: "cert-manager", RepoURL: "https://charts.example.com"}, false},
+		{ChartRef{Name: "ingress-nginx"}, true},
+		{ChartRef{Name: "app", RepoURL: "https://charts.example.com"}, false},
+	}
+	for _, tt := range tests {
+		if _, ok := LookupPreset(&tt.chart); ok != tt.want {
+			t.Errorf("LookupPreset(%s) = %v, want %v", tt.chart.String(), ok, tt.want)
+		}
+	}
+}
+
+func TestPresetOptions(t *testing.T) {
+	tests := []struct {
+		name       string
+		baseline   string
+		candidates []string
+		want       string
+	}{
+		{"cert-manager", "v1.13.3", []string{"v1.13.6", "v1.14.5", "v1.15.1", "v2.0.0"}, "v1.14.5"},
+		{"cert-manager", "v1.14.5", []string{"v1.14.6"}, "v1.14.6"},
+		{"ingress-nginx", "4.9.1", []string{"4.10.1", "4.11.2", "5.0.0"}, "4.11.2"},
+	}
+	for _, tt := range tests {
+		got, err := update.SelectLatest(
+			context.Background(),
+			tt.baseline,
+			tt.candidates,
+			update.WithCompareOptions(Presets[tt.name].Options(tt.baseline)...),
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s from %s = %s, want %s", tt.name, tt.baseline, got, tt.want)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package helm
 
 import (
 	"context"
+	"slices"
 
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
 	update "github.com/shikanime-studio/automata/internal/updater"
 )
 
@@ -18,15 +21,34 @@ func NewUpdater(opts ...FindLatestOption) Updater {
 	}
 }
 
-// Update returns the latest version for the given chart reference.
+// Update returns the latest version for the given chart reference. Charts
+// with a preset are updated along its upgrade path unless the options select
+// one of their own.
 func (u Updater) Update(
 	ctx context.Context,
 	chart *ChartRef,
 	opts ...update.Option,
 ) (string, error) {
-	return FindLatestVersion(
-		ctx,
-		chart,
-		append(u.opts, WithUpdateOptions(opts...))...,
-	)
+	ctx = logging.WithAttrs(logging.WithSubsystem(ctx, "helm"), "dependency", chart.Name)
+	if p, ok := LookupPreset(chart); ok {
+		opts = append(p.Options(chart.Version), opts...)
+	}
+	find := func(ctx context.Context, extra ...update.Option) (string, error) {
+		return FindLatestVersion(
+			ctx,
+			chart,
+			append(slices.Clone(u.opts), WithUpdateOptions(slices.Concat(opts, extra)...))...,
+		)
+	}
+	latest, err := find(ctx)
+	if err != nil {
+		return "", err
+	}
+	return policy.Apply(ctx, policy.Proposal{
+		Source:   "helm",
+		Name:     chart.Name,
+		From:     chart.Version,
+		To:       latest,
+		Reselect: find,
+	})
 }
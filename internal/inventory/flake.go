@@ -0,0 +1,98 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// skipDirs are the directories never searched for flake locks.
+var skipDirs = []string{".git", "node_modules", "vendor", ".direnv"}
+
+// flakeLock is the subset of flake.lock the inventory reads.
+type flakeLock struct {
+	Root  string `json:"root"`
+	Nodes map[string]struct {
+		Inputs map[string]json.RawMessage `json:"inputs"`
+		Locked struct {
+			Type  string `json:"type"`
+			Owner string `json:"owner"`
+			Repo  string `json:"repo"`
+			URL   string `json:"url"`
+			Rev   string `json:"rev"`
+		} `json:"locked"`
+	} `json:"nodes"`
+}
+
+// CollectFlakes lists the direct inputs locked by the flake.lock files of the
+// tree rooted at dir, at their locked revision. Inputs following another
+// input are skipped.
+func CollectFlakes(dir string) ([]Dependency, error) {
+	var deps []Dependency
+	err := filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() {
+			if path != dir && slices.Contains(skipDirs, e.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if e.Name() != "flake.lock" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		found, err := readFlakeLock(path, filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		deps = append(deps, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("collect flake inputs: %w", err)
+	}
+	return deps, nil
+}
+
+// readFlakeLock lists the direct inputs of a flake.lock file.
+func readFlakeLock(path, rel string) ([]Dependency, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lock flakeLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", rel, err)
+	}
+	var deps []Dependency
+	for input, raw := range lock.Nodes[lock.Root].Inputs {
+		var node string
+		if err := json.Unmarshal(raw, &node); err != nil {
+			continue
+		}
+		locked := lock.Nodes[node].Locked
+		if locked.Rev == "" {
+			continue
+		}
+		repository := locked.URL
+		if locked.Owner != "" {
+			repository = fmt.Sprintf("%s:%s/%s", locked.Type, locked.Owner, locked.Repo)
+		}
+		deps = append(deps, Dependency{
+			Source:     "flake",
+			Name:       input,
+			Version:    locked.Rev,
+			File:       rel,
+			Repository: repository,
+		})
+	}
+	return deps, nil
+}
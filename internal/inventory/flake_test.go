@@ -0,0 +1,43 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCollectFlakes(t *testing.T) {
+	dir := t.TempDir()
+	lock := `{
+  "nodes": {
+    "nixpkgs": {"locked": {"type": "github", "owner": "NixOS", "repo": "nixpkgs", "rev": "abc"}},
+    "tarball": {"locked": {"type": "tarball", "url": "https://example.com/src.tar.gz", "rev": "def"}},
+    "utils": {"inputs": {"nixpkgs": ["nixpkgs"]}, "locked": {"type": "path", "path": "./utils"}},
+    "root": {"inputs": {"nixpkgs": "nixpkgs", "tarball": "tarball", "utils": "utils", "follows": ["nixpkgs"]}}
+  },
+  "root": "root",
+  "version": 7
+}`
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "flake.lock"), []byte(lock), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := CollectFlakes(dir)
+	if err != nil {
+		t.Fatalf("CollectFlakes: %v", err)
+	}
+	inv := &Inventory{}
+	for _, d := range got {
+		inv.Add(d)
+	}
+	want := []Dependency{
+		{Source: "flake", Name: "nixpkgs", Version: "abc", File: "sub/flake.lock", Repository: "github:NixOS/nixpkgs"},
+		{Source: "flake", Name: "tarball", Version: "def", File: "sub/flake.lock", Repository: "https://example.com/src.tar.gz"},
+	}
+	if deps := inv.Dependencies(); !reflect.DeepEqual(deps, want) {
+		t.Errorf("CollectFlakes = %+v, want %+v", deps, want)
+	}
+}
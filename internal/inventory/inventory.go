@@ -0,0 +1,229 @@
+// Package inventory lists the dependencies automata tracks in a tree and
+// compares the inventories of two trees.
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+
+	"github.com/shikanime-studio/automata/internal/azure"
+	"github.com/shikanime-studio/automata/internal/circleci"
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/helm"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/policy"
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+// Dependency is a dependency pinned in a file.
+type Dependency struct {
+	Source  string
+	Name    string
+	Version string
+	File    string
+	// Repository locates the dependency when its name alone does not, e.g.
+	// the repository URL of a chart or the locked URL of a flake input.
+	Repository string
+}
+
+// Inventory collects the dependencies looked up by the update pipelines.
+type Inventory struct {
+	mu   sync.Mutex
+	deps []Dependency
+}
+
+// Add records a dependency.
+func (i *Inventory) Add(d Dependency) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.deps = append(i.deps, d)
+}
+
+// Dependencies returns the recorded dependencies, deduplicated and sorted by
+// source, name and file.
+func (i *Inventory) Dependencies() []Dependency {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	seen := make(map[Dependency]bool)
+	var deps []Dependency
+	for _, d := range i.deps {
+		if !seen[d] {
+			seen[d] = true
+			deps = append(deps, d)
+		}
+	}
+	sort.Slice(deps, func(a, b int) bool {
+		x, y := deps[a], deps[b]
+		if x.Source != y.Source {
+			return x.Source < y.Source
+		}
+		if x.Name != y.Name {
+			return x.Name < y.Name
+		}
+		if x.File != y.File {
+			return x.File < y.File
+		}
+		return x.Version < y.Version
+	})
+	return deps
+}
+
+// recorder is an updater recording each lookup and reporting no update, so
+// pipelines list dependencies without querying any source or rewriting them.
+type recorder[T any] struct {
+	inv    *Inventory
+	source string
+	ref    func(T) (name, version, repository string)
+}
+
+// Update records the dependency and returns no version.
+func (r recorder[T]) Update(ctx context.Context, v T, _ ...updater.Option) (string, error) {
+	name, version, repository := r.ref(v)
+	r.inv.Add(Dependency{
+		Source:     r.source,
+		Name:       name,
+		Version:    version,
+		File:       policy.File(ctx),
+		Repository: repository,
+	})
+	return "", nil
+}
+
+// namedPipeline is an update pipeline named in errors.
+type namedPipeline struct {
+	name     string
+	pipeline kio.Pipeline
+}
+
+// Collect lists the dependencies of the tree rooted at dir by running the
+// update pipelines with recording updaters, and the inputs of its flake
+// locks. The pipelines write the files
+// they read back, so dir should be a disposable copy.
+func Collect(ctx context.Context, dir string) ([]Dependency, error) {
+	inv := &Inventory{}
+	cu := recorder[*container.ImageRef]{inv, "image", func(r *container.ImageRef) (string, string, string) {
+		return r.Name, r.Tag, ""
+	}}
+	hu := recorder[*helm.ChartRef]{inv, "helm", func(r *helm.ChartRef) (string, string, string) {
+		return r.Name, r.Version, r.RepoURL
+	}}
+	gu := recorder[*github.ActionRef]{inv, "github", func(r *github.ActionRef) (string, string, string) {
+		return r.Owner + "/" + r.Repo, r.Version, ""
+	}}
+	au := recorder[*azure.TaskRef]{inv, "azure", func(r *azure.TaskRef) (string, string, string) {
+		return r.Name, r.Version, ""
+	}}
+	ou := recorder[*circleci.OrbRef]{inv, "orb", func(r *circleci.OrbRef) (string, string, string) {
+		return r.Namespace + "/" + r.Name, r.Version, ""
+	}}
+	pipelines := []namedPipeline{
+		{"kustomization", ikio.UpdateKustomization(ctx, cu, dir)},
+		{"k0sctl", ikio.UpdateK0sctlConfigs(ctx, hu, dir)},
+		{"helmchart", ikio.UpdateHelmCharts(ctx, hu, dir)},
+		{"talos", ikio.UpdateTalosConfigs(ctx, cu, gu, dir)},
+		{"githubworkflow", ikio.UpdateGitHubWorkflows(ctx, gu, nil, dir)},
+		{"skaffold", ikio.UpdateSkaffoldConfigs(ctx, cu, hu, dir)},
+		{"drone", ikio.UpdateDronePipelines(ctx, cu, dir)},
+		{"tekton", ikio.UpdateTektonResources(ctx, cu, dir)},
+		{"crossplane", ikio.UpdateCrossplanePackages(ctx, cu, dir)},
+		{"policybundle", ikio.UpdatePolicyBundles(ctx, cu, gu, dir)},
+		{"opa", ikio.UpdateOPAManifests(ctx, nil, cu, dir)},
+		{"circleci", ikio.UpdateCircleCIConfigs(ctx, cu, ou, dir)},
+		{"azurepipelines", ikio.UpdateAzurePipelines(ctx, cu, au, dir)},
+		{"devcontainer", ikio.UpdateDevContainers(ctx, cu, dir)},
+	}
+	for _, p := range pipelines {
+		if !inputsExist(p.pipeline) {
+			continue
+		}
+		if err := p.pipeline.Execute(); err != nil {
+			return nil, fmt.Errorf("collect %s dependencies: %w", p.name, err)
+		}
+	}
+	flakes, err := CollectFlakes(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range flakes {
+		inv.Add(d)
+	}
+	return inv.Dependencies(), nil
+}
+
+// inputsExist reports whether the directories a pipeline reads exist, as
+// pipelines such as the CircleCI one read a fixed subdirectory.
+func inputsExist(p kio.Pipeline) bool {
+	for _, in := range p.Inputs {
+		r, ok := in.(kio.LocalPackageReader)
+		if !ok {
+			continue
+		}
+		if _, err := os.Stat(r.PackagePath); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Change is a version-level difference of a dependency between two trees.
+type Change struct {
+	Source string
+	Name   string
+	File   string
+	// From is empty for added dependencies.
+	From string
+	// To is empty for removed dependencies.
+	To string
+}
+
+// String returns the change as "source name (file): from -> to".
+func (c Change) String() string {
+	from, to := c.From, c.To
+	if from == "" {
+		from = "(none)"
+	}
+	if to == "" {
+		to = "(none)"
+	}
+	return fmt.Sprintf("%s %s (%s): %s -> %s", c.Source, c.Name, c.File, from, to)
+}
+
+// Diff returns the dependencies whose versions differ from a to b, matched by
+// source, name and file.
+func Diff(a, b []Dependency) []Change {
+	type key struct{ source, name, file string }
+	versions := func(deps []Dependency) map[key]string {
+		m := make(map[key]string, len(deps))
+		for _, d := range deps {
+			k := key{d.Source, d.Name, d.File}
+			if v, ok := m[k]; ok && v != d.Version {
+				m[k] = v + "," + d.Version
+				continue
+			}
+			m[k] = d.Version
+		}
+		return m
+	}
+	from, to := versions(a), versions(b)
+	var changes []Change
+	for k, v := range from {
+		if to[k] != v {
+			changes = append(changes, Change{Source: k.source, Name: k.name, File: k.file, From: v, To: to[k]})
+		}
+	}
+	for k, v := range to {
+		if _, ok := from[k]; !ok {
+			changes = append(changes, Change{Source: k.source, Name: k.name, File: k.file, To: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].String() < changes[j].String()
+	})
+	return changes
+}
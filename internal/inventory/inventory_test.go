@@ -0,0 +1,69 @@
+package inventory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCollect(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "app"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	kustomization := `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+metadata:
+  annotations:
+    automata.shikanime.studio/images: '[{"name":"nginx"}]'
+images:
+  - name: nginx
+    newName: docker.io/library/nginx
+    newTag: 1.25.0
+`
+	path := filepath.Join(dir, "app", "kustomization.yaml")
+	if err := os.WriteFile(path, []byte(kustomization), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	deps, err := Collect(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	want := Dependency{
+		Source:  "image",
+		Name:    "docker.io/library/nginx",
+		Version: "1.25.0",
+		File:    "app/kustomization.yaml",
+	}
+	if len(deps) != 1 || deps[0] != want {
+		t.Fatalf("unexpected dependencies: %+v", deps)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := []Dependency{
+		{Source: "image", Name: "nginx", Version: "1.25.0", File: "a.yaml"},
+		{Source: "github", Name: "actions/checkout", Version: "v3", File: "ci.yaml"},
+		{Source: "helm", Name: "cert-manager", Version: "1.0.0", File: "cluster.yaml"},
+	}
+	b := []Dependency{
+		{Source: "image", Name: "nginx", Version: "1.27.1", File: "a.yaml"},
+		{Source: "github", Name: "actions/checkout", Version: "v3", File: "ci.yaml"},
+		{Source: "image", Name: "redis", Version: "7.0.0", File: "b.yaml"},
+	}
+	got := Diff(a, b)
+	want := []string{
+		"helm cert-manager (cluster.yaml): 1.0.0 -> (none)",
+		"image nginx (a.yaml): 1.25.0 -> 1.27.1",
+		"image redis (b.yaml): (none) -> 7.0.0",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected changes: %v", got)
+	}
+	for i, c := range got {
+		if c.String() != want[i] {
+			t.Fatalf("change %d = %q, want %q", i, c, want[i])
+		}
+	}
+}
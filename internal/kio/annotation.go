@@ -0,0 +1,149 @@
+package kio
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/schema"
+)
+
+//go:embed schemas/*.schema.json
+var schemaFiles embed.FS
+
+// AnnotationSchemas maps the JSON annotations automata reads to the JSON
+// Schema their value must follow.
+var AnnotationSchemas = map[string]*schema.Schema{
+	ImagesAnnotation:   mustReadSchema("schemas/images.schema.json"),
+	LiteralsAnnotation: mustReadSchema("schemas/literals.schema.json"),
+	ChartsAnnotation:   mustReadSchema("schemas/charts.schema.json"),
+}
+
+// mustReadSchema parses an embedded schema.
+func mustReadSchema(name string) *schema.Schema {
+	data, err := schemaFiles.ReadFile(name)
+	if err != nil {
+		panic(err)
+	}
+	return schema.MustParse(data)
+}
+
+// validateAnnotation checks the value of a JSON annotation against its
+// schema, joining the violations positioned in the value.
+func validateAnnotation(annotation string, node *yaml.RNode) error {
+	errs := AnnotationSchemas[annotation].Validate([]byte(node.YNode().Value))
+	if len(errs) == 0 {
+		return nil
+	}
+	joined := make([]error, len(errs))
+	for i, err := range errs {
+		joined[i] = err
+	}
+	return fmt.Errorf("invalid %s annotation: %w", annotation, errors.Join(joined...))
+}
+
+// AnnotationError is a schema violation of an annotation, positioned in the
+// file declaring it.
+type AnnotationError struct {
+	// File is the path of the file relative to the linted directory.
+	File string
+	// Annotation is the key of the invalid annotation.
+	Annotation string
+	// Violation is the schema violation, positioned in File.
+	Violation schema.Error
+}
+
+// Error formats the violation as file:line:column: annotation pointer:
+// message.
+func (e AnnotationError) Error() string {
+	return fmt.Sprintf(
+		"%s:%d:%d: %s%s: %s", e.File, e.Violation.Line, e.Violation.Column,
+		e.Annotation, e.Violation.Pointer, e.Violation.Message)
+}
+
+// LintAnnotations validates the JSON annotations of a resource against their
+// schema, positioning each violation in the resource file.
+func LintAnnotations(node *yaml.RNode) ([]AnnotationError, error) {
+	file, _, err := kioutil.GetFileAnnotations(node)
+	if err != nil {
+		return nil, fmt.Errorf("get file annotations: %w", err)
+	}
+	annotations, err := node.Pipe(yaml.Lookup("metadata", "annotations"))
+	if err != nil {
+		return nil, fmt.Errorf("lookup annotations: %w", err)
+	}
+	if annotations == nil {
+		return nil, nil
+	}
+	var lints []AnnotationError
+	for _, key := range []string{ImagesAnnotation, LiteralsAnnotation, ChartsAnnotation} {
+		field := annotations.Field(key)
+		if field == nil || yaml.IsMissingOrNull(field.Value) {
+			continue
+		}
+		for _, e := range AnnotationSchemas[key].Validate([]byte(field.Value.YNode().Value)) {
+			e.Line, e.Column = filePosition(field.Key.YNode(), field.Value.YNode(), e.Line, e.Column)
+			lints = append(lints, AnnotationError{File: file, Annotation: key, Violation: e})
+		}
+	}
+	return lints, nil
+}
+
+// filePosition maps a position in the scalar value of a key to the file:
+// block scalars start on the line after their indicator, indented two spaces
+// deeper than their key, while flow scalars start at their node, after the
+// opening quote.
+func filePosition(key, n *yaml.Node, line, column int) (int, int) {
+	switch n.Style {
+	case yaml.LiteralStyle, yaml.FoldedStyle:
+		return n.Line + line, key.Column + 1 + column
+	case yaml.SingleQuotedStyle, yaml.DoubleQuotedStyle:
+		if line == 1 {
+			return n.Line, n.Column + column
+		}
+	default:
+		if line == 1 {
+			return n.Line, n.Column + column - 1
+		}
+	}
+	return n.Line + line - 1, column
+}
+
+// LintPackage validates the JSON annotations of the kustomizations, Drone or
+// Woodpecker pipelines and k0sctl configs under path, returning the
+// violations sorted by file and position.
+func LintPackage(path string) ([]AnnotationError, error) {
+	nodes, err := kio.LocalPackageReader{
+		PackagePath:    path,
+		MatchFilesGlob: []string{"*.yml", "*.yaml", "Kustomization"},
+		FileSkipFunc: func(relPath string) bool {
+			return !slices.Contains(KustomizationFiles, filepath.Base(relPath)) &&
+				!IsDronePipelineFile(relPath) && filepath.Base(relPath) != "cluster.yaml"
+		},
+	}.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	var lints []AnnotationError
+	for _, node := range nodes {
+		l, err := LintAnnotations(node)
+		if err != nil {
+			return nil, err
+		}
+		lints = append(lints, l...)
+	}
+	sort.SliceStable(lints, func(i, j int) bool {
+		if lints[i].File != lints[j].File {
+			return lints[i].File < lints[j].File
+		}
+		return lints[i].Violation.Line < lints[j].Violation.Line
+	})
+	return lints, nil
+}
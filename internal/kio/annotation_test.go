@@ -0,0 +1,57 @@
+package kio
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestLintPackagePositionsViolationsInFiles(t *testing.T) {
+	dir := t.TempDir()
+	doc := `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+metadata:
+  annotations:
+    automata.shikanime.studio/images: |
+      [
+        {
+          "name": "app",
+          "calver-policy": "same-day"
+        }
+      ]
+    automata.shikanime.studio/literals: '[{"image":"app"}]'
+`
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(doc), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.yaml"), []byte(doc), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	lints, err := LintPackage(dir)
+	if err != nil {
+		t.Fatalf("LintPackage: %v", err)
+	}
+	want := []string{
+		`kustomization.yaml:9:28: automata.shikanime.studio/images/0/calver-policy: "same-day" is not one of "any", "same-year", "same-month"`,
+		`kustomization.yaml:12:43: automata.shikanime.studio/literals/0: missing required property "key"`,
+	}
+	if len(lints) != len(want) {
+		t.Fatalf("got %d lints, want %d: %v", len(lints), len(want), lints)
+	}
+	for i, l := range lints {
+		if l.Error() != want[i] {
+			t.Fatalf("lint %d = %q, want %q", i, l.Error(), want[i])
+		}
+	}
+}
+
+func TestGetKustomizationImagesConfig_ReportsSchemaViolations(t *testing.T) {
+	node := yaml.NewStringRNode(`[{"name":"app","update-stratgy":"FullUpdate"}]`)
+	_, err := GetKustomizationImagesConfig(node)
+	if err == nil || !strings.Contains(err.Error(), `did you mean "update-strategy"`) {
+		t.Fatalf("expected a field-level error, got %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/helm"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+)
+
+// ChartBumps records the chart versions bumped during a run, keyed by chart
+// name.
+type ChartBumps struct {
+	mu     sync.Mutex
+	charts map[string]helm.ChartRef
+}
+
+// NewChartBumps creates an empty ChartBumps.
+func NewChartBumps() *ChartBumps {
+	return &ChartBumps{charts: make(map[string]helm.ChartRef)}
+}
+
+// Charts returns a copy of the bumped charts.
+func (b *ChartBumps) Charts() map[string]helm.ChartRef {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make(map[string]helm.ChartRef, len(b.charts))
+	for k, v := range b.charts {
+		out[k] = v
+	}
+	return out
+}
+
+type chartBumpsKey struct{}
+
+// WithChartBumps returns a context recording chart bumps into b.
+func WithChartBumps(ctx context.Context, b *ChartBumps) context.Context {
+	return context.WithValue(ctx, chartBumpsKey{}, b)
+}
+
+// recordChartBump records a chart bump when the context carries ChartBumps.
+func recordChartBump(ctx context.Context, chart helm.ChartRef) {
+	b, _ := ctx.Value(chartBumpsKey{}).(*ChartBumps)
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.charts[chart.Name] = chart
+}
+
+// AppVersionResolver returns the default application version of a chart
+// version.
+type AppVersionResolver func(ctx context.Context, chart *helm.ChartRef) (string, error)
+
+// AppVersionPin expects the image pinned for a chart component to follow the
+// application version of the chart.
+type AppVersionPin struct {
+	// Image is the normalized image name.
+	Image string
+	// AppVersion is the application version of the bumped chart.
+	AppVersion string
+	// Fix rewrites mismatched tags instead of reporting them.
+	Fix bool
+}
+
+// ResolveAppVersionPins resolves the application version of the bumped charts
+// that have declared images. Charts left untouched by the run are skipped.
+func ResolveAppVersionPins(
+	ctx context.Context,
+	decls []config.AppVersion,
+	bumps map[string]helm.ChartRef,
+	resolve AppVersionResolver,
+) ([]AppVersionPin, error) {
+	var pins []AppVersionPin
+	for _, d := range decls {
+		chart, ok := bumps[d.Chart]
+		if !ok {
+			continue
+		}
+		appVersion, err := resolve(ctx, &chart)
+		if err != nil {
+			return nil, fmt.Errorf("resolve app version of %s: %w", chart.String(), err)
+		}
+		if appVersion == "" {
+			continue
+		}
+		ref, err := container.ParseImageRef(d.Image)
+		if err != nil {
+			return nil, fmt.Errorf("parse image %s: %w", d.Image, err)
+		}
+		pins = append(pins, AppVersionPin{Image: ref.Name, AppVersion: appVersion, Fix: d.Fix})
+	}
+	return pins, nil
+}
+
+// CheckAppVersions builds a pipeline that compares the images pinned in the
+// YAML files under path against the application versions of their charts.
+// Only the files holding a fixed tag are written back, and nothing is written
+// when no pin fixes mismatches.
+func CheckAppVersions(ctx context.Context, pins []AppVersionPin, path string) kio.Pipeline {
+	ctx = policy.WithDir(ctx, path)
+	p := kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{
+				PackagePath:    path,
+				MatchFilesGlob: []string{"*.yaml", "*.yml"},
+			},
+		},
+		Filters: []kio.Filter{
+			kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+				modified := make(map[*yaml.RNode]bool)
+				for _, node := range nodes {
+					fixed, err := checkAppVersionsNode(withFile(ctx, node), pins, node)
+					if err != nil {
+						return nil, err
+					}
+					modified[node] = fixed
+				}
+				return KeepFilesWith(nodes, func(node *yaml.RNode) bool {
+					return modified[node]
+				})
+			}),
+		},
+	}
+	if slices.ContainsFunc(pins, func(p AppVersionPin) bool { return p.Fix }) {
+		p.Outputs = []kio.Writer{
+			newPackageWriter(ctx, path),
+		}
+	}
+	return p
+}
+
+// CheckAppVersionsNode checks the image references of one resource, written
+// either as "name:tag" strings, as Helm values with repository and tag
+// fields, or as kustomization images with name, newName and newTag fields.
+// Tags are compared ignoring a leading "v", which mismatched fixes preserve.
+// Fixes go through policy.Apply as image updates.
+func CheckAppVersionsNode(ctx context.Context, pins []AppVersionPin) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		if _, err := checkAppVersionsNode(ctx, pins, node); err != nil {
+			return nil, err
+		}
+		return node, nil
+	})
+}
+
+// checkAppVersionsNode checks one resource, reporting whether a tag was
+// fixed.
+func checkAppVersionsNode(ctx context.Context, pins []AppVersionPin, node *yaml.RNode) (bool, error) {
+	return checkAppVersions(ctx, pins, node.YNode())
+}
+
+func checkAppVersions(ctx context.Context, pins []AppVersionPin, n *yaml.Node) (bool, error) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if !strings.Contains(n.Value, ":") || strings.Contains(n.Value, "@") || !hasExplicitTag(n.Value) {
+			return false, nil
+		}
+		ref, err := container.ParseImageRef(n.Value)
+		if err != nil {
+			return false, nil
+		}
+		tag, ok, err := checkAppVersion(ctx, pins, ref.Name, ref.Tag)
+		if err != nil || !ok {
+			return false, err
+		}
+		n.Value = strings.TrimSuffix(n.Value, ":"+ref.Tag) + ":" + tag
+		return true, nil
+	case yaml.MappingNode:
+		fields := map[string]*yaml.Node{}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			fields[n.Content[i].Value] = n.Content[i+1]
+		}
+		name, tag := mappingImage(fields)
+		if name != nil && tag != nil && name.Kind == yaml.ScalarNode && tag.Kind == yaml.ScalarNode {
+			ref, err := container.ParseImageRef(name.Value)
+			if err == nil {
+				fixed, ok, err := checkAppVersion(ctx, pins, ref.Name, tag.Value)
+				if err != nil {
+					return false, err
+				}
+				if ok {
+					tag.Value = fixed
+					return true, nil
+				}
+			}
+		}
+	}
+	modified := false
+	for _, c := range n.Content {
+		fixed, err := checkAppVersions(ctx, pins, c)
+		if err != nil {
+			return false, err
+		}
+		modified = modified || fixed
+	}
+	return modified, nil
+}
+
+// mappingImage returns the image name and tag nodes of a mapping, if any.
+func mappingImage(fields map[string]*yaml.Node) (*yaml.Node, *yaml.Node) {
+	if tag, ok := fields["newTag"]; ok {
+		if name, ok := fields["newName"]; ok {
+			return name, tag
+		}
+		return fields["name"], tag
+	}
+	return fields["repository"], fields["tag"]
+}
+
+// checkAppVersion compares the tag of the named image with its pin, returning
+// the fixed tag when it must be rewritten. The fix is proposed to the policy
+// engine like any image update, so rules, holds and the schedule apply.
+func checkAppVersion(ctx context.Context, pins []AppVersionPin, name, tag string) (string, bool, error) {
+	for _, p := range pins {
+		if p.Image != name {
+			continue
+		}
+		if strings.TrimPrefix(tag, "v") == strings.TrimPrefix(p.AppVersion, "v") {
+			return "", false, nil
+		}
+		if !p.Fix {
+			slog.WarnContext(ctx, "app version mismatch", logging.Failed.Attr(),
+				"image", name, "tag", tag, "app-version", p.AppVersion)
+			return "", false, nil
+		}
+		fixed := strings.TrimPrefix(p.AppVersion, "v")
+		if strings.HasPrefix(tag, "v") {
+			fixed = "v" + fixed
+		}
+		fixed, err := policy.Apply(ctx, policy.Proposal{Source: "image", Name: name, From: tag, To: fixed})
+		if err != nil {
+			return "", false, fmt.Errorf("apply policy to %s: %w", name, err)
+		}
+		if fixed == "" || fixed == tag {
+			return "", false, nil
+		}
+		slog.InfoContext(ctx, "updated image to chart app version",
+			"image", name, "from", tag, "to", fixed)
+		return fixed, true, nil
+	}
+	return "", false, nil
+}
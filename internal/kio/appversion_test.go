@@ -0,0 +1,118 @@
+package kio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/helm"
+)
+
+func TestResolveAppVersionPins(t *testing.T) {
+	ctx := context.Background()
+	bumps := NewChartBumps()
+	recordChartBump(WithChartBumps(ctx, bumps), helm.ChartRef{
+		RepoURL: "https://kubernetes.github.io/ingress-nginx",
+		Name:    "ingress-nginx",
+		Version: "4.11.2",
+	})
+	pins, err := ResolveAppVersionPins(
+		ctx,
+		[]config.AppVersion{
+			{Chart: "ingress-nginx", Image: "registry.k8s.io/ingress-nginx/controller", Fix: true},
+			{Chart: "cert-manager", Image: "quay.io/jetstack/cert-manager-controller"},
+		},
+		bumps.Charts(),
+		func(_ context.Context, c *helm.ChartRef) (string, error) {
+			if c.Version != "4.11.2" {
+				t.Fatalf("unexpected chart version: %s", c.Version)
+			}
+			return "1.11.2", nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pins) != 1 || pins[0].AppVersion != "1.11.2" || !pins[0].Fix {
+		t.Fatalf("unexpected pins: %+v", pins)
+	}
+}
+
+func TestCheckAppVersionsNode(t *testing.T) {
+	doc := `controller:
+  image:
+    repository: registry.k8s.io/ingress-nginx/controller
+    tag: v1.10.0
+sidecar: registry.k8s.io/ingress-nginx/controller:v1.10.0
+images:
+  - name: nginx
+    newName: registry.k8s.io/ingress-nginx/controller
+    newTag: 1.10.0
+other: docker.io/library/redis:7.0.0`
+	rn := yaml.MustParse(doc)
+	pins := []AppVersionPin{
+		{Image: "registry.k8s.io/ingress-nginx/controller", AppVersion: "1.11.2", Fix: true},
+	}
+	if _, err := CheckAppVersionsNode(context.Background(), pins).Filter(rn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := rn.MustString()
+	for _, want := range []string{
+		"tag: v1.11.2",
+		"sidecar: registry.k8s.io/ingress-nginx/controller:v1.11.2",
+		"newTag: 1.11.2",
+		"other: docker.io/library/redis:7.0.0",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in:\n%s", want, out)
+		}
+	}
+
+	rn = yaml.MustParse(doc)
+	pins[0].Fix = false
+	if _, err := CheckAppVersionsNode(context.Background(), pins).Filter(rn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(rn.MustString(), "1.11.2") {
+		t.Fatalf("report-only pin rewrote tags:\n%s", rn.MustString())
+	}
+}
+
+func TestCheckAppVersions_WritesOnlyFixedFiles(t *testing.T) {
+	pinned := "image: registry.k8s.io/ingress-nginx/controller:v1.10.0\n"
+	other := "image:   docker.io/library/redis:7.0.0\nlist:\n- a\n"
+	for _, fix := range []bool{true, false} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "pinned.yaml"), []byte(pinned), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "other.yaml"), []byte(other), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		pins := []AppVersionPin{
+			{Image: "registry.k8s.io/ingress-nginx/controller", AppVersion: "1.11.2", Fix: fix},
+		}
+		if err := CheckAppVersions(context.Background(), pins, dir).Execute(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := os.ReadFile(filepath.Join(dir, "other.yaml"))
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if string(got) != other {
+			t.Fatalf("fix=%v rewrote an untouched file:\n%s", fix, got)
+		}
+		got, err = os.ReadFile(filepath.Join(dir, "pinned.yaml"))
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if fixed := strings.Contains(string(got), "v1.11.2"); fixed != fix {
+			t.Fatalf("fix=%v got:\n%s", fix, got)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/azure"
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// UpdateAzurePipelines builds a pipeline that updates task versions and
+// container images of the azure-pipelines files under path.
+func UpdateAzurePipelines(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	tu update.Updater[*azure.TaskRef],
+	path string,
+) kio.Pipeline {
+	ctx = policy.WithDir(ctx, path)
+	return kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{
+				PackagePath:    path,
+				MatchFilesGlob: []string{"azure-pipelines*.yml", "azure-pipelines*.yaml"},
+			},
+		},
+		Filters: []kio.Filter{
+			UpdateAzurePipelinesTasks(ctx, cu, tu),
+		},
+		Outputs: []kio.Writer{
+			newPackageWriter(ctx, path),
+		},
+	}
+}
+
+// UpdateAzurePipelinesTasks runs task and image updates across pipeline files.
+func UpdateAzurePipelinesTasks(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	tu update.Updater[*azure.TaskRef],
+) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		g := errgroup.Group{}
+		for _, node := range nodes {
+			g.Go(func() error {
+				if err := node.PipeE(UpdateAzurePipeline(withFile(ctx, node), cu, tu)); err != nil {
+					return err
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+		return nodes, nil
+	})
+}
+
+// UpdateAzurePipeline updates every task reference and container image of one
+// pipeline, at any depth of stages, jobs and templates.
+func UpdateAzurePipeline(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	tu update.Updater[*azure.TaskRef],
+) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		if err := updateAzureNode(ctx, cu, tu, node.YNode(), ""); err != nil {
+			return nil, err
+		}
+		return node, nil
+	})
+}
+
+func updateAzureNode(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	tu update.Updater[*azure.TaskRef],
+	n *yaml.Node,
+	parent string,
+) error {
+	switch n.Kind {
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			if err := updateAzureNode(ctx, cu, tu, c, parent); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i].Value, n.Content[i+1]
+			switch {
+			case key == "task" && value.Kind == yaml.ScalarNode:
+				if err := updateAzureTask(ctx, tu, value); err != nil {
+					return err
+				}
+			case key == "container" && value.Kind == yaml.ScalarNode && parent != "containers",
+				key == "image" && (parent == "container" || parent == "containers"):
+				if err := updateAzureImage(ctx, cu, value); err != nil {
+					return err
+				}
+			}
+			if err := updateAzureNode(ctx, cu, tu, value, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func updateAzureTask(ctx context.Context, tu update.Updater[*azure.TaskRef], n *yaml.Node) error {
+	task, err := azure.ParseTaskRef(n.Value)
+	if err != nil {
+		return fmt.Errorf("parse task ref: %w", err)
+	}
+	latest, err := tu.Update(atNode(ctx, n), task)
+	if err != nil {
+		return fmt.Errorf("find latest task version: %w", err)
+	}
+	if latest == "" || latest == task.Version {
+		return nil
+	}
+	n.Value = azure.TaskRef{Name: task.Name, Version: latest}.String()
+	slog.InfoContext(ctx, "updated task", "task", task.Name, "from", task.Version, "to", latest)
+	return nil
+}
+
+func updateAzureImage(ctx context.Context, cu update.Updater[*container.ImageRef], n *yaml.Node) error {
+	latest, err := ResolveImage(cu).Resolve(atNode(ctx, n), n.Value)
+	if err != nil {
+		return fmt.Errorf("resolve image %s: %w", n.Value, err)
+	}
+	if latest == "" {
+		return nil
+	}
+	slog.InfoContext(ctx, "updated container image", "from", n.Value, "to", latest)
+	n.Value = latest
+	return nil
+}
@@ -0,0 +1,63 @@
+package kio
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/azure"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+type fakeTaskUpdater struct {
+	latest string
+}
+
+func (f fakeTaskUpdater) Update(
+	_ context.Context,
+	_ *azure.TaskRef,
+	_ ...update.Option,
+) (string, error) {
+	return f.latest, nil
+}
+
+func TestUpdateAzurePipeline(t *testing.T) {
+	doc := `pool:
+  vmImage: ubuntu-latest
+container: node:18.0
+resources:
+  containers:
+  - container: redis
+    image: redis:18.0
+stages:
+- stage: build
+  jobs:
+  - job: build
+    steps:
+    - task: NodeTool@0
+      inputs:
+        versionSpec: 18.x`
+	rn := yaml.MustParse(doc)
+	_, err := UpdateAzurePipeline(
+		context.Background(),
+		fakeImageUpdater{latest: "20.0"},
+		fakeTaskUpdater{latest: "1"},
+	).Filter(rn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := rn.MustString()
+	for _, want := range []string{
+		"container: node:20.0",
+		"image: redis:20.0",
+		"container: redis\n",
+		"task: NodeTool@1",
+		"vmImage: ubuntu-latest",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in:\n%s", want, out)
+		}
+	}
+}
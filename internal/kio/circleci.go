@@ -0,0 +1,167 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/circleci"
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// UpdateCircleCIConfigs builds a pipeline that updates orb versions and docker
+// executor images of .circleci/config.yml.
+func UpdateCircleCIConfigs(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	ou update.Updater[*circleci.OrbRef],
+	path string,
+) kio.Pipeline {
+	ctx = policy.WithDir(ctx, filepath.Join(path, ".circleci"))
+	return kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{
+				PackagePath:    filepath.Join(path, ".circleci"),
+				MatchFilesGlob: []string{"config.yml", "config.yaml"},
+			},
+		},
+		Filters: []kio.Filter{
+			UpdateCircleCIConfigsOrbs(ctx, cu, ou),
+		},
+		Outputs: []kio.Writer{
+			newPackageWriter(ctx, filepath.Join(path, ".circleci")),
+		},
+	}
+}
+
+// UpdateCircleCIConfigsOrbs runs orb and image updates across config files.
+func UpdateCircleCIConfigsOrbs(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	ou update.Updater[*circleci.OrbRef],
+) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		g := errgroup.Group{}
+		for _, node := range nodes {
+			g.Go(func() error {
+				if err := node.PipeE(UpdateCircleCIConfig(withFile(ctx, node), cu, ou)); err != nil {
+					return err
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+		return nodes, nil
+	})
+}
+
+// UpdateCircleCIConfig updates the orbs and the docker images of the executors
+// and jobs of one config.
+func UpdateCircleCIConfig(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	ou update.Updater[*circleci.OrbRef],
+) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		orbsNode, err := node.Pipe(yaml.Lookup("orbs"))
+		if err != nil {
+			return nil, fmt.Errorf("lookup orbs: %w", err)
+		}
+		if orbsNode != nil {
+			if err := orbsNode.VisitFields(func(f *yaml.MapNode) error {
+				return updateCircleCIOrb(ctx, ou, f.Value)
+			}); err != nil {
+				return nil, err
+			}
+		}
+		for _, section := range []string{"executors", "jobs"} {
+			sectionNode, err := node.Pipe(yaml.Lookup(section))
+			if err != nil {
+				return nil, fmt.Errorf("lookup %s: %w", section, err)
+			}
+			if sectionNode == nil {
+				continue
+			}
+			if err := sectionNode.VisitFields(func(f *yaml.MapNode) error {
+				return updateCircleCIDocker(ctx, cu, f.Value)
+			}); err != nil {
+				return nil, err
+			}
+		}
+		return node, nil
+	})
+}
+
+func updateCircleCIOrb(
+	ctx context.Context,
+	ou update.Updater[*circleci.OrbRef],
+	node *yaml.RNode,
+) error {
+	if node.YNode().Kind != yaml.ScalarNode {
+		return nil
+	}
+	orb, err := circleci.ParseOrbRef(yaml.GetValue(node))
+	if err != nil {
+		slog.WarnContext(ctx, "skip orb", logging.Failed.Attr(), "err", err)
+		return nil
+	}
+	latest, err := ou.Update(atNode(ctx, node.YNode()), orb)
+	if err != nil {
+		return fmt.Errorf("find latest orb version: %w", err)
+	}
+	if latest == "" || latest == orb.Version {
+		return nil
+	}
+	node.YNode().Value = circleci.OrbRef{
+		Namespace: orb.Namespace,
+		Name:      orb.Name,
+		Version:   latest,
+	}.String()
+	slog.InfoContext(ctx, "updated orb", "orb", orb.Namespace+"/"+orb.Name, "from", orb.Version, "to", latest)
+	return nil
+}
+
+func updateCircleCIDocker(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	node *yaml.RNode,
+) error {
+	dockerNode, err := node.Pipe(yaml.Lookup("docker"))
+	if err != nil {
+		return fmt.Errorf("lookup docker: %w", err)
+	}
+	images, err := dockerNode.Elements()
+	if err != nil {
+		return fmt.Errorf("get docker elements: %w", err)
+	}
+	for _, img := range images {
+		imageNode, err := img.Pipe(yaml.Get("image"))
+		if err != nil {
+			return fmt.Errorf("get image: %w", err)
+		}
+		current := yaml.GetValue(imageNode)
+		if current == "" {
+			continue
+		}
+		latest, err := ResolveImage(cu).Resolve(atNode(ctx, imageNode.YNode()), current)
+		if err != nil {
+			return fmt.Errorf("resolve image %s: %w", current, err)
+		}
+		if latest == "" {
+			continue
+		}
+		imageNode.YNode().Value = latest
+		slog.InfoContext(ctx, "updated docker image", "from", current, "to", latest)
+	}
+	return nil
+}
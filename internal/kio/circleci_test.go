@@ -0,0 +1,60 @@
+package kio
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/circleci"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+type fakeOrbUpdater struct {
+	latest string
+}
+
+func (f fakeOrbUpdater) Update(
+	_ context.Context,
+	_ *circleci.OrbRef,
+	_ ...update.Option,
+) (string, error) {
+	return f.latest, nil
+}
+
+func TestUpdateCircleCIConfig(t *testing.T) {
+	doc := `version: 2.1
+orbs:
+  node: circleci/node@5.1.0
+executors:
+  default:
+    docker:
+    - image: cimg/base:2023.01
+jobs:
+  build:
+    docker:
+    - image: cimg/node:18.17
+    machine:
+      image: ubuntu-2204:2023.07.2`
+	rn := yaml.MustParse(doc)
+	_, err := UpdateCircleCIConfig(
+		context.Background(),
+		fakeImageUpdater{latest: "2024.02"},
+		fakeOrbUpdater{latest: "5.2.0"},
+	).Filter(rn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := rn.MustString()
+	for _, want := range []string{
+		"node: circleci/node@5.2.0",
+		"image: cimg/base:2024.02",
+		"image: cimg/node:2024.02",
+		"image: ubuntu-2204:2023.07.2",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// IsComposeFile reports whether the relative path is a Compose file or one
+// of its overrides, e.g. compose.yaml or docker-compose.prod.yml.
+func IsComposeFile(relPath string) bool {
+	base := filepath.Base(relPath)
+	ext := filepath.Ext(base)
+	if ext != ".yml" && ext != ".yaml" {
+		return false
+	}
+	name, _, _ := strings.Cut(strings.TrimSuffix(base, ext), ".")
+	return name == "compose" || name == "docker-compose"
+}
+
+// UpdateComposeFiles builds a pipeline that updates the tagged images of the
+// services of the Compose files under path, keeping the variant of their
+// tag. The indentation of their sequences is kept, as Compose files commonly
+// indent them.
+func UpdateComposeFiles(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+	path string,
+) kio.Pipeline {
+	ctx = policy.WithDir(ctx, path)
+	return kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{
+				PackagePath:       path,
+				MatchFilesGlob:    []string{"*.yml", "*.yaml"},
+				PreserveSeqIndent: true,
+				FileSkipFunc: func(relPath string) bool {
+					return !IsComposeFile(relPath)
+				},
+			},
+		},
+		Filters: []kio.Filter{
+			UpdateComposeFilesImages(ctx, u),
+		},
+		Outputs: []kio.Writer{
+			newPackageWriter(ctx, path),
+		},
+	}
+}
+
+// UpdateComposeFilesImages runs service image updates across Compose files.
+func UpdateComposeFilesImages(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		g := errgroup.Group{}
+		for _, node := range nodes {
+			g.Go(func() error {
+				return node.PipeE(UpdateComposeImages(withFile(ctx, node), u))
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+		return nodes, nil
+	})
+}
+
+// UpdateComposeImages updates the images of the services of one Compose
+// file. Untagged images and digests are left alone.
+func UpdateComposeImages(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		services, err := node.Pipe(yaml.Lookup("services"))
+		if err != nil {
+			return nil, fmt.Errorf("lookup services: %w", err)
+		}
+		if services == nil {
+			return node, nil
+		}
+		err = services.VisitFields(func(f *yaml.MapNode) error {
+			imageNode, err := f.Value.Pipe(yaml.Get("image"))
+			if err != nil {
+				return fmt.Errorf("get image of service %s: %w", f.Key.YNode().Value, err)
+			}
+			current := yaml.GetValue(imageNode)
+			if current == "" {
+				return nil
+			}
+			latest, err := ResolveImage(u).Resolve(atNode(ctx, imageNode.YNode()), current)
+			if err != nil {
+				return fmt.Errorf("resolve image %s: %w", current, err)
+			}
+			if latest == "" {
+				return nil
+			}
+			imageNode.YNode().Value = latest
+			slog.InfoContext(ctx, "updated service image", "service", f.Key.YNode().Value, "from", current, "to", latest)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return node, nil
+	})
+}
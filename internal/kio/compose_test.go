@@ -0,0 +1,46 @@
+package kio
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestUpdateComposeImages(t *testing.T) {
+	doc := `services:
+  web:
+    image: nginx:1.25-alpine
+  db:
+    image: postgres@sha256:0000000000000000000000000000000000000000000000000000000000000000
+  app:
+    build: .
+  cache:
+    image: redis`
+	rn := yaml.MustParse(doc)
+	if _, err := UpdateComposeImages(context.Background(), fakeImageUpdater{latest: "1.27-alpine"}).Filter(rn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := rn.MustString()
+	for _, want := range []string{"image: nginx:1.27-alpine", "image: postgres@sha256:", "image: redis\n"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestIsComposeFile(t *testing.T) {
+	for p, want := range map[string]bool{
+		"compose.yaml":              true,
+		"svc/docker-compose.yml":    true,
+		"docker-compose.prod.yaml":  true,
+		"compose.json":              false,
+		"deploy/kustomization.yaml": false,
+		"composer.yaml":             false,
+	} {
+		if got := IsComposeFile(p); got != want {
+			t.Fatalf("IsComposeFile(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// CrossplaneGroup is the API group of Crossplane packages.
+const CrossplaneGroup = "pkg.crossplane.io"
+
+// CrossplaneKinds are the kinds of Crossplane packages.
+var CrossplaneKinds = []string{"Provider", "Configuration", "Function"}
+
+// CrossplaneRegistry is the registry Crossplane pulls packages from when
+// their reference names none.
+var CrossplaneRegistry = "xpkg.crossplane.io"
+
+// IsCrossplanePackage reports whether the node is a Crossplane package.
+func IsCrossplanePackage(node *yaml.RNode) bool {
+	group, _, _ := strings.Cut(node.GetApiVersion(), "/")
+	return group == CrossplaneGroup && slices.Contains(CrossplaneKinds, node.GetKind())
+}
+
+// UpdateCrossplanePackages builds a pipeline that updates the package
+// references of the Crossplane providers, configurations and functions under
+// path.
+func UpdateCrossplanePackages(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+	path string,
+) kio.Pipeline {
+	ctx = policy.WithDir(ctx, path)
+	return kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{
+				PackagePath:    path,
+				MatchFilesGlob: []string{"*.yaml", "*.yml"},
+			},
+		},
+		Filters: []kio.Filter{
+			UpdateCrossplanePackagesVersions(ctx, u),
+		},
+		Outputs: []kio.Writer{
+			newPackageWriter(ctx, path),
+		},
+	}
+}
+
+// UpdateCrossplanePackagesVersions runs package updates across Crossplane
+// packages, dropping files without any.
+func UpdateCrossplanePackagesVersions(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		g := errgroup.Group{}
+		for _, node := range nodes {
+			if !IsCrossplanePackage(node) {
+				continue
+			}
+			g.Go(func() error {
+				if err := node.PipeE(UpdateCrossplanePackage(withFile(ctx, node), u)); err != nil {
+					slog.WarnContext(ctx, "crossplane update failed", logging.Failed.Attr(), "name", node.GetName(), "err", err)
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+		return KeepFilesWith(nodes, IsCrossplanePackage)
+	})
+}
+
+// UpdateCrossplanePackage updates the spec.package reference of one
+// Crossplane package to the latest tag of its repository. When the images
+// annotation of the package lists its repository, as written, the tags are
+// selected with its options and its excluded tags are skipped, as for
+// kustomizations. Untagged and digest-pinned references are left alone.
+func UpdateCrossplanePackage(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		packageNode, err := node.Pipe(yaml.Lookup("spec", "package"))
+		if err != nil {
+			return nil, fmt.Errorf("lookup package: %w", err)
+		}
+		current := yaml.GetValue(packageNode)
+		if current == "" || strings.Contains(current, "@") || !hasExplicitTag(current) {
+			return node, nil
+		}
+		imageAnnotationNode, err := node.Pipe(GetImagesAnnotation())
+		if err != nil {
+			return nil, fmt.Errorf("get images annotation: %w", err)
+		}
+		imageConfigsByName, err := GetKustomizationImagesConfig(imageAnnotationNode)
+		if err != nil {
+			return nil, fmt.Errorf("get image config: %w", err)
+		}
+		ref, err := container.ParseImageRef(current)
+		if err != nil {
+			return nil, fmt.Errorf("parse package ref %s: %w", current, err)
+		}
+		name := current[:len(current)-len(ref.Tag)-1]
+		ref.Name = name
+		if registry, _, ok := strings.Cut(name, "/"); !ok || !strings.ContainsAny(registry, ".:") {
+			ref.Name = CrossplaneRegistry + "/" + name
+		}
+		var opts []update.Option
+		cfg, ok := imageConfigsByName[name]
+		if ok {
+			opts = cfg.UpdateOptions(ref)
+		}
+		latest, err := u.Update(atNode(ctx, packageNode.YNode()), &ref, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("find latest tag: %w", err)
+		}
+		if latest == "" || latest == ref.Tag || slices.Contains(cfg.Excludes, latest) {
+			return node, nil
+		}
+		packageNode.YNode().Value = name + ":" + latest
+		slog.InfoContext(ctx, "updated crossplane package", "package", name, "from", ref.Tag, "to", latest)
+		return node, nil
+	})
+}
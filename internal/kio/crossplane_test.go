@@ -0,0 +1,94 @@
+package kio
+
+import (
+	"context"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// lookupImageUpdater records the image it looked up.
+type lookupImageUpdater struct {
+	latest string
+	name   string
+}
+
+func (f *lookupImageUpdater) Update(
+	_ context.Context,
+	ref *container.ImageRef,
+	_ ...update.Option,
+) (string, error) {
+	f.name = ref.Name
+	return f.latest, nil
+}
+
+func TestUpdateCrossplanePackage(t *testing.T) {
+	cases := []struct {
+		name, pkg, annotation string
+		latest, want, lookup  string
+	}{
+		{
+			name:   "qualified",
+			pkg:    "xpkg.upbound.io/crossplane-contrib/provider-aws:v0.43.0",
+			latest: "v0.47.1",
+			want:   "xpkg.upbound.io/crossplane-contrib/provider-aws:v0.47.1",
+			lookup: "xpkg.upbound.io/crossplane-contrib/provider-aws",
+		},
+		{
+			name:   "default registry",
+			pkg:    "crossplane-contrib/function-patch-and-transform:v0.5.0",
+			latest: "v0.7.0",
+			want:   "crossplane-contrib/function-patch-and-transform:v0.7.0",
+			lookup: CrossplaneRegistry + "/crossplane-contrib/function-patch-and-transform",
+		},
+		{
+			name:       "excluded",
+			pkg:        "xpkg.upbound.io/upbound/provider-gcp:v1.0.0",
+			annotation: `[{"name":"xpkg.upbound.io/upbound/provider-gcp","exclude-tags":["v1.1.0"]}]`,
+			latest:     "v1.1.0",
+			want:       "xpkg.upbound.io/upbound/provider-gcp:v1.0.0",
+			lookup:     "xpkg.upbound.io/upbound/provider-gcp",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rn := yaml.MustParse(`apiVersion: pkg.crossplane.io/v1
+kind: Provider
+metadata:
+  name: provider
+spec:
+  package: ` + c.pkg)
+			if c.annotation != "" {
+				if err := rn.PipeE(yaml.SetAnnotation(ImagesAnnotation, c.annotation)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			u := &lookupImageUpdater{latest: c.latest}
+			if _, err := UpdateCrossplanePackage(context.Background(), u).Filter(rn); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, _ := rn.GetString("spec.package"); got != c.want {
+				t.Errorf("package = %s, want %s", got, c.want)
+			}
+			if u.name != c.lookup {
+				t.Errorf("looked up %s, want %s", u.name, c.lookup)
+			}
+		})
+	}
+}
+
+func TestIsCrossplanePackage(t *testing.T) {
+	for doc, want := range map[string]bool{
+		"apiVersion: pkg.crossplane.io/v1\nkind: Configuration":         true,
+		"apiVersion: pkg.crossplane.io/v1beta1\nkind: Function":         true,
+		"apiVersion: pkg.crossplane.io/v1\nkind: ProviderRevision":      false,
+		"apiVersion: apiextensions.crossplane.io/v1\nkind: Composition": false,
+	} {
+		if got := IsCrossplanePackage(yaml.MustParse(doc)); got != want {
+			t.Errorf("IsCrossplanePackage(%q) = %v, want %v", doc, got, want)
+		}
+	}
+}
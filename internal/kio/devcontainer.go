@@ -0,0 +1,137 @@
+package kio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/fsutil"
+	"github.com/shikanime-studio/automata/internal/policy"
+	"github.com/shikanime-studio/automata/internal/textfile"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// UpdateDevContainers builds a pipeline that updates the image and feature
+// versions of the devcontainer.json in the .devcontainer directory. The file
+// is JSON with comments, which kyaml can neither parse nor write back without
+// re-sorting its keys, so it is edited as text by the filter rather than read
+// and written by the pipeline.
+func UpdateDevContainers(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+	path string,
+) kio.Pipeline {
+	dir := filepath.Join(path, ".devcontainer")
+	ctx = policy.WithDir(ctx, dir)
+	return kio.Pipeline{
+		Filters: []kio.Filter{
+			UpdateDevContainerFile(ctx, u, dir, "devcontainer.json"),
+		},
+	}
+}
+
+// UpdateDevContainerFile updates the named devcontainer definition of dir in
+// place, writing it back only when its content changed. A missing file is
+// left alone.
+func UpdateDevContainerFile(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+	dir, name string,
+) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nodes, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("stat %s: %w", path, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		content, err := UpdateDevContainer(policy.WithFile(ctx, name), u, string(data))
+		if err != nil {
+			return nil, fmt.Errorf("update %s: %w", path, err)
+		}
+		if content == string(data) {
+			return nodes, nil
+		}
+		if err := fsutil.WriteFile(path, []byte(content), info.Mode().Perm(), fsutil.Backups(ctx)); err != nil {
+			return nil, err
+		}
+		return nodes, nil
+	})
+}
+
+// UpdateDevContainer updates the image tag and the OCI feature references of
+// one devcontainer definition, rewriting only their string literals so
+// comments, trailing commas and key order are kept.
+func UpdateDevContainer(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+	content string,
+) (string, error) {
+	type edit struct {
+		start, end int
+		value      string
+	}
+	var edits []edit
+	for _, s := range textfile.JSONCStrings(content) {
+		if s.Escaped() {
+			continue
+		}
+		line, column := textfile.Position(content, s.Start)
+		ctx := policy.WithPosition(ctx, line, column)
+
+		switch {
+		case !s.Key && slices.Equal(s.Path, []string{"image"}):
+			latest, err := ResolveImage(u).Resolve(ctx, s.Value)
+			if err != nil {
+				return "", fmt.Errorf("resolve image %s: %w", s.Value, err)
+			}
+			if latest != "" {
+				edits = append(edits, edit{s.Start, s.End, latest})
+				slog.InfoContext(ctx, "updated devcontainer image", "from", s.Value, "to", latest)
+			}
+		case s.Key && len(s.Path) == 2 && s.Path[0] == "features" && isOCIFeature(s.Value):
+			latest, err := ResolveImage(u).Resolve(ctx, s.Value)
+			if err != nil {
+				return "", fmt.Errorf("resolve feature %s: %w", s.Value, err)
+			}
+			if latest != "" {
+				edits = append(edits, edit{s.Start, s.End, latest})
+				slog.InfoContext(ctx, "updated devcontainer feature", "from", s.Value, "to", latest)
+			}
+		}
+	}
+	var b strings.Builder
+	last := 0
+	for _, e := range edits {
+		b.WriteString(content[last:e.start])
+		b.WriteString(e.value)
+		last = e.end
+	}
+	b.WriteString(content[last:])
+	return b.String(), nil
+}
+
+// isOCIFeature reports whether a feature identifier is a tagged OCI reference,
+// as opposed to a local path, a tarball URL or an untagged reference.
+func isOCIFeature(feature string) bool {
+	if strings.HasPrefix(feature, ".") || strings.Contains(feature, "://") {
+		return false
+	}
+	return hasExplicitTag(feature)
+}
@@ -0,0 +1,78 @@
+package kio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateDevContainer(t *testing.T) {
+	doc := `{
+  "image": "mcr.microsoft.com/devcontainers/go:1.22",
+  "features": {
+    "ghcr.io/devcontainers/features/node:1": {"version": "lts"},
+    "./local-feature": {}
+  }
+}`
+	got, err := UpdateDevContainer(context.Background(), fakeImageUpdater{latest: "2"}, doc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{
+  "image": "mcr.microsoft.com/devcontainers/go:2",
+  "features": {
+    "ghcr.io/devcontainers/features/node:2": {"version": "lts"},
+    "./local-feature": {}
+  }
+}`
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUpdateDevContainers_JSONC(t *testing.T) {
+	dir := t.TempDir()
+	doc := `// Dev container of the repository.
+{
+  "name": "automata",
+  /* Pinned by automata. */
+  "image": "mcr.microsoft.com/devcontainers/go:1.22", // base image
+  "features": {
+    "ghcr.io/devcontainers/features/node:1": {},
+  },
+  "customizations": {"vscode": {"extensions": ["golang.go"]}},
+}
+`
+	if err := os.MkdirAll(filepath.Join(dir, ".devcontainer"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	file := filepath.Join(dir, ".devcontainer", "devcontainer.json")
+	if err := os.WriteFile(file, []byte(doc), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := UpdateDevContainers(context.Background(), fakeImageUpdater{latest: "2"}, dir).Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	want := `// Dev container of the repository.
+{
+  "name": "automata",
+  /* Pinned by automata. */
+  "image": "mcr.microsoft.com/devcontainers/go:2", // base image
+  "features": {
+    "ghcr.io/devcontainers/features/node:2": {},
+  },
+  "customizations": {"vscode": {"extensions": ["golang.go"]}},
+}
+`
+	if string(got) != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if err := UpdateDevContainers(context.Background(), fakeImageUpdater{latest: "2"}, t.TempDir()).Execute(); err != nil {
+		t.Fatalf("missing devcontainer: %v", err)
+	}
+}
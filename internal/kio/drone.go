@@ -0,0 +1,173 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// IsDronePipelineFile reports whether the relative path is a Drone or
+// Woodpecker pipeline definition.
+func IsDronePipelineFile(relPath string) bool {
+	switch filepath.Base(relPath) {
+	case ".drone.yml", ".drone.yaml", ".woodpecker.yml", ".woodpecker.yaml":
+		return true
+	}
+	return filepath.Base(filepath.Dir(relPath)) == ".woodpecker"
+}
+
+// UpdateDronePipelines builds a pipeline that updates step images of the
+// Drone and Woodpecker pipelines under path, configured by the images
+// annotation used for kustomizations.
+func UpdateDronePipelines(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+	path string,
+) kio.Pipeline {
+	ctx = policy.WithDir(ctx, path)
+	return kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{
+				PackagePath:    path,
+				MatchFilesGlob: []string{"*.yml", "*.yaml"},
+				FileSkipFunc: func(relPath string) bool {
+					return !IsDronePipelineFile(relPath)
+				},
+			},
+		},
+		Filters: []kio.Filter{
+			UpdateDronePipelinesImages(ctx, u),
+		},
+		Outputs: []kio.Writer{
+			newPackageWriter(ctx, path),
+		},
+	}
+}
+
+// UpdateDronePipelinesImages runs step image updates across pipeline files.
+func UpdateDronePipelinesImages(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		g := errgroup.Group{}
+		for _, node := range nodes {
+			g.Go(func() error {
+				if err := node.PipeE(UpdateDronePipelineImages(withFile(ctx, node), u)); err != nil {
+					return err
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+		return nodes, nil
+	})
+}
+
+// UpdateDronePipelineImages updates the images of the steps, services and
+// clone steps of one pipeline whose image is listed in the images annotation.
+// Steps may be declared as a list or, for Woodpecker, as a map.
+func UpdateDronePipelineImages(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		imageAnnotationNode, err := node.Pipe(GetImagesAnnotation())
+		if err != nil {
+			return nil, fmt.Errorf("get images annotation: %w", err)
+		}
+		imageConfigsByName, err := GetKustomizationImagesConfig(imageAnnotationNode)
+		if err != nil {
+			return nil, fmt.Errorf("get image config: %w", err)
+		}
+		if len(imageConfigsByName) == 0 {
+			return node, nil
+		}
+		for _, section := range []string{"steps", "services", "clone", "pipeline"} {
+			sectionNode, err := node.Pipe(yaml.Lookup(section))
+			if err != nil {
+				return nil, fmt.Errorf("lookup %s: %w", section, err)
+			}
+			if sectionNode == nil {
+				continue
+			}
+			var steps []*yaml.RNode
+			switch sectionNode.YNode().Kind {
+			case yaml.SequenceNode:
+				steps, err = sectionNode.Elements()
+				if err != nil {
+					return nil, fmt.Errorf("get %s elements: %w", section, err)
+				}
+			case yaml.MappingNode:
+				if err := sectionNode.VisitFields(func(f *yaml.MapNode) error {
+					steps = append(steps, f.Value)
+					return nil
+				}); err != nil {
+					return nil, err
+				}
+			}
+			for _, step := range steps {
+				if err := step.PipeE(UpdateDroneStepImage(ctx, u, imageConfigsByName)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return node, nil
+	})
+}
+
+// UpdateDroneStepImage updates the image of one step.
+func UpdateDroneStepImage(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+	imageConfigsByName map[string]KustomizationImagesConfig,
+) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		imageNode, err := node.Pipe(yaml.Get("image"))
+		if err != nil {
+			return nil, fmt.Errorf("get image: %w", err)
+		}
+		current := yaml.GetValue(imageNode)
+		if current == "" {
+			return node, nil
+		}
+		ref, err := container.ParseImageRef(current)
+		if err != nil {
+			return nil, fmt.Errorf("parse image ref %s: %w", current, err)
+		}
+		name := current
+		if hasExplicitTag(current) {
+			name = current[:len(current)-len(ref.Tag)-1]
+		}
+		cfg, ok := imageConfigsByName[name]
+		if !ok {
+			return node, nil
+		}
+		latest, err := u.Update(atNode(ctx, imageNode.YNode()), &ref, cfg.UpdateOptions(ref)...)
+		if err != nil {
+			return nil, fmt.Errorf("find latest tag: %w", err)
+		}
+		if latest == "" || latest == ref.Tag {
+			return node, nil
+		}
+		for _, e := range cfg.Excludes {
+			if e == latest {
+				return node, nil
+			}
+		}
+		imageNode.YNode().Value = name + ":" + latest
+		slog.InfoContext(ctx, "updated step image", "image", name, "from", ref.Tag, "to", latest)
+		return node, nil
+	})
+}
@@ -0,0 +1,48 @@
+package kio
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestUpdateDronePipelineImages(t *testing.T) {
+	doc := `kind: pipeline
+metadata:
+  annotations:
+    automata.shikanime.studio/images: '[{"name":"golang"},{"name":"redis","exclude-tags":["8"]}]'
+steps:
+  build:
+    image: golang:1.21
+  lint:
+    image: alpine:3.18
+services:
+- name: cache
+  image: redis:7`
+	rn := yaml.MustParse(doc)
+	_, err := UpdateDronePipelineImages(context.Background(), fakeImageUpdater{latest: "8"}).Filter(rn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := rn.MustString()
+	for _, want := range []string{"image: golang:8", "image: alpine:3.18", "image: redis:7"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestIsDronePipelineFile(t *testing.T) {
+	for p, want := range map[string]bool{
+		".drone.yml":                true,
+		"svc/.woodpecker.yaml":      true,
+		".woodpecker/build.yml":     true,
+		"deploy/kustomization.yaml": false,
+	} {
+		if got := IsDronePipelineFile(p); got != want {
+			t.Fatalf("IsDronePipelineFile(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
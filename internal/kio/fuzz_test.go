@@ -0,0 +1,38 @@
+package kio
+
+import (
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func FuzzAnnotations(f *testing.F) {
+	for _, seed := range []string{
+		`[{"name": "nginx", "tag-regex": "^(?P<version>\\d+\\.\\d+\\.\\d+)$"}]`,
+		`[{"name": "app", "update-strategy": "MinorUpdate", "exclude-tags": ["1.2.3"]}]`,
+		`[{"name": "repo/app", "version-regex": "^app-(?P<version>.*)$", "ordering": "numeric"}]`,
+		`[{"name": "ß", "invalid-tag": "latest", "keep-floating-tag": true}]`,
+		`[{"name": "x", "tag-regex": "("}]`,
+		`[{"name": "x"`,
+		`{"name": "x"}`,
+		`[]`,
+		``,
+		`[{"name": "\ud800"}]`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		_, _ = GetKustomizationImagesConfig(yaml.NewStringRNode(value))
+		node := yaml.NewMapRNode(nil)
+		if err := node.PipeE(yaml.SetAnnotation(ChartsAnnotation, value)); err != nil {
+			t.Fatalf("set annotation: %v", err)
+		}
+		_, _ = GetChartsConfig(node)
+		if err := node.PipeE(yaml.SetAnnotation(ImagesAnnotation, value)); err != nil {
+			t.Fatalf("set annotation: %v", err)
+		}
+		if _, err := LintAnnotations(node); err != nil {
+			t.Fatalf("LintAnnotations(%q): %v", value, err)
+		}
+	})
+}
@@ -6,25 +6,33 @@ import (
 	"fmt"
 	"log/slog"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"golang.org/x/sync/errgroup"
 	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 
+	"github.com/shikanime-studio/automata/internal/config"
 	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	"github.com/shikanime-studio/automata/internal/toolversion"
 	update "github.com/shikanime-studio/automata/internal/updater"
 )
 
 // UpdateGitHubWorkflows builds a kyaml pipeline that rewrites a
-// workflow directory, skipping git-ignored files.
-// UpdateGitHubWorkflows builds a kyaml pipeline that rewrites a
-// workflow directory, skipping git-ignored files.
+// workflow directory, skipping git-ignored files. Tags are selected with the
+// configs of the actions, by owner/repo.
 func UpdateGitHubWorkflows(
 	ctx context.Context,
 	u update.Updater[*github.ActionRef],
+	configs map[string]ActionConfig,
 	path string,
 ) kio.Pipeline {
+	ctx = policy.WithDir(ctx, filepath.Join(path, ".github", "workflows"))
 	return kio.Pipeline{
 		Inputs: []kio.Reader{
 			kio.LocalPackageReader{
@@ -33,12 +41,10 @@ func UpdateGitHubWorkflows(
 			},
 		},
 		Filters: []kio.Filter{
-			UpdateGitHubWorkflowsAction(ctx, u),
+			UpdateGitHubWorkflowsAction(ctx, u, configs),
 		},
 		Outputs: []kio.Writer{
-			kio.LocalPackageWriter{
-				PackagePath: filepath.Join(path, ".github", "workflows"),
-			},
+			newPackageWriter(ctx, filepath.Join(path, ".github", "workflows")),
 		},
 	}
 }
@@ -47,12 +53,14 @@ func UpdateGitHubWorkflows(
 func UpdateGitHubWorkflowsAction(
 	ctx context.Context,
 	u update.Updater[*github.ActionRef],
+	configs map[string]ActionConfig,
 ) kio.Filter {
 	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		prefetchActions(ctx, u, nodes)
 		g := errgroup.Group{}
 		for _, node := range nodes {
 			g.Go(func() error {
-				if err := node.PipeE(UpdateGitHubWorkflowAction(ctx, u)); err != nil {
+				if err := node.PipeE(UpdateGitHubWorkflowAction(withFile(ctx, node), u, configs)); err != nil {
 					return err
 				}
 				return nil
@@ -65,12 +73,65 @@ func UpdateGitHubWorkflowsAction(
 	})
 }
 
-// UpdateGitHubWorkflowAction updates all jobs within a single workflow.
+// ActionPrefetcher fetches the tags of many actions at once. GitHub updaters
+// implementing it are handed the actions of every workflow before they are
+// updated, so that their lookups are batched.
+type ActionPrefetcher interface {
+	Prefetch(ctx context.Context, actions []*github.ActionRef) error
+}
+
+// prefetchActions hands the actions the steps of the workflows use to the
+// updater when it batches lookups. A failure only loses the batching, each
+// action then being looked up on its own.
+func prefetchActions(ctx context.Context, u update.Updater[*github.ActionRef], nodes []*yaml.RNode) {
+	p, ok := u.(ActionPrefetcher)
+	if !ok {
+		return
+	}
+	var actions []*github.ActionRef
+	for _, node := range nodes {
+		jobs, err := node.Pipe(yaml.Lookup("jobs"))
+		if err != nil || jobs == nil {
+			continue
+		}
+		_ = jobs.VisitFields(func(job *yaml.MapNode) error {
+			steps, err := job.Value.Pipe(yaml.Lookup("steps"))
+			if err != nil || steps == nil {
+				return nil
+			}
+			elems, err := steps.Elements()
+			if err != nil {
+				return nil
+			}
+			for _, step := range elems {
+				uses, err := step.Pipe(yaml.Get("uses"))
+				if err != nil || uses == nil {
+					continue
+				}
+				if ref, err := github.ParseActionRef(strings.TrimSpace(yaml.GetValue(uses))); err == nil {
+					actions = append(actions, ref)
+				}
+			}
+			return nil
+		})
+	}
+	if len(actions) == 0 {
+		return
+	}
+	if err := p.Prefetch(ctx, actions); err != nil {
+		slog.WarnContext(ctx, "failed to prefetch action tags", "err", err)
+	}
+}
+
+// UpdateGitHubWorkflowAction updates all jobs within a single workflow,
+// following the action configs and the automata comments of the workflow.
 func UpdateGitHubWorkflowAction(
 	ctx context.Context,
 	u update.Updater[*github.ActionRef],
+	configs map[string]ActionConfig,
 ) yaml.Filter {
 	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		directives := GetWorkflowDirectives(node)
 		jobsNode, err := node.Pipe(yaml.Lookup("jobs"))
 		if err != nil {
 			slog.WarnContext(ctx, "failed to lookup jobs", "err", err)
@@ -86,19 +147,22 @@ func UpdateGitHubWorkflowAction(
 			return nil, fmt.Errorf("get job fields: %w", err)
 		}
 		for _, j := range jobNames {
-			if err := jobsNode.PipeE(UpdateGitHubWorkflowJob(ctx, u, j)); err != nil {
-				slog.WarnContext(ctx, "job processing error", "job", j, "err", err)
+			if err := jobsNode.PipeE(UpdateGitHubWorkflowJob(ctx, u, j, configs, directives)); err != nil {
+				slog.WarnContext(ctx, "job processing error", logging.Failed.Attr(), "job", j, "err", err)
 			}
 		}
 		return node, nil
 	})
 }
 
-// UpdateGitHubWorkflowJob updates all steps within the named job.
+// UpdateGitHubWorkflowJob updates all steps within the named job, following
+// the given action configs and workflow directives.
 func UpdateGitHubWorkflowJob(
 	ctx context.Context,
 	u update.Updater[*github.ActionRef],
 	name string,
+	configs map[string]ActionConfig,
+	directives []WorkflowDirective,
 ) yaml.Filter {
 	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
 		jobNode, err := node.Pipe(yaml.Lookup(name))
@@ -121,7 +185,7 @@ func UpdateGitHubWorkflowJob(
 			return nil, fmt.Errorf("get steps: %w", err)
 		}
 		for _, step := range stepElems {
-			if err := step.PipeE(UpdateGitHubWorkflowStep(ctx, u, name)); err != nil {
+			if err := step.PipeE(UpdateGitHubWorkflowStep(ctx, u, name, configs, directives)); err != nil {
 				return nil, fmt.Errorf("step processing error: %w", err)
 			}
 		}
@@ -129,11 +193,15 @@ func UpdateGitHubWorkflowJob(
 	})
 }
 
-// UpdateGitHubWorkflowStep updates a step's uses to the latest action tag.
+// UpdateGitHubWorkflowStep updates a step's uses to the latest action tag,
+// selected with the config of its action, then the workflow directives naming
+// its action or none, then the automata comments of the step.
 func UpdateGitHubWorkflowStep(
 	ctx context.Context,
 	u update.Updater[*github.ActionRef],
 	name string,
+	configs map[string]ActionConfig,
+	directives []WorkflowDirective,
 ) yaml.Filter {
 	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
 		usesNode, err := node.Pipe(yaml.Get("uses"))
@@ -152,7 +220,15 @@ func UpdateGitHubWorkflowStep(
 		if err != nil {
 			return nil, fmt.Errorf("parse action ref: %w", err)
 		}
-		latest, err := u.Update(ctx, actionRef)
+		cfg, err := GetActionConfig(configs, directives, actionRef, node, usesNode)
+		if err != nil {
+			return nil, fmt.Errorf("action config for %s/%s: %w", actionRef.Owner, actionRef.Repo, err)
+		}
+		if cfg.Pin {
+			slog.InfoContext(ctx, "skip pinned action", "job", name, "action", actionRef.String())
+			return node, nil
+		}
+		latest, err := u.Update(atNode(ctx, usesNode.YNode()), actionRef, cfg.UpdateOptions()...)
 		if err != nil {
 			return nil, fmt.Errorf("find latest tag: %w", err)
 		}
@@ -160,14 +236,15 @@ func UpdateGitHubWorkflowStep(
 			slog.InfoContext(ctx, "no suitable tag found", "action", actionRef.String())
 			return node, nil
 		}
+		if latest == actionRef.Version {
+			return node, nil
+		}
 		newActionRef := github.ActionRef{
 			Owner:   actionRef.Owner,
 			Repo:    actionRef.Repo,
 			Version: latest,
 		}
-		if err := node.PipeE(yaml.SetField("uses", yaml.NewStringRNode(newActionRef.String()))); err != nil {
-			return nil, fmt.Errorf("set uses for %s/%s: %w", actionRef.Owner, actionRef.Repo, err)
-		}
+		usesNode.YNode().Value = newActionRef.String()
 		slog.InfoContext(ctx,
 			"updated action",
 			"job",
@@ -182,3 +259,307 @@ func UpdateGitHubWorkflowStep(
 		return node, nil
 	})
 }
+
+// UpdateGitHubWorkflowInputs builds a kyaml pipeline that bumps the tool
+// versions passed to setup actions through the given step inputs.
+func UpdateGitHubWorkflowInputs(
+	ctx context.Context,
+	u update.Updater[*github.ActionRef],
+	inputs map[string]toolversion.Tool,
+	path string,
+) kio.Pipeline {
+	ctx = policy.WithDir(ctx, filepath.Join(path, ".github", "workflows"))
+	return kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{
+				PackagePath:    filepath.Join(path, ".github", "workflows"),
+				MatchFilesGlob: []string{"*.yml", "*.yaml"},
+			},
+		},
+		Filters: []kio.Filter{
+			UpdateGitHubWorkflowsInputs(ctx, u, inputs),
+		},
+		Outputs: []kio.Writer{
+			newPackageWriter(ctx, filepath.Join(path, ".github", "workflows")),
+		},
+	}
+}
+
+// UpdateGitHubWorkflowsInputs applies tool input updates across workflow files.
+func UpdateGitHubWorkflowsInputs(
+	ctx context.Context,
+	u update.Updater[*github.ActionRef],
+	inputs map[string]toolversion.Tool,
+) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		g := errgroup.Group{}
+		for _, node := range nodes {
+			g.Go(func() error {
+				if err := node.PipeE(UpdateGitHubWorkflowInputsNode(withFile(ctx, node), u, inputs)); err != nil {
+					return err
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+		return nodes, nil
+	})
+}
+
+// UpdateGitHubWorkflowInputsNode bumps the tool version inputs of every step
+// within a single workflow.
+func UpdateGitHubWorkflowInputsNode(
+	ctx context.Context,
+	u update.Updater[*github.ActionRef],
+	inputs map[string]toolversion.Tool,
+) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		jobsNode, err := node.Pipe(yaml.Lookup("jobs"))
+		if err != nil {
+			return nil, fmt.Errorf("lookup jobs: %w", err)
+		}
+		if jobsNode == nil {
+			return node, nil
+		}
+		jobNames, err := jobsNode.Fields()
+		if err != nil {
+			return nil, fmt.Errorf("get job fields: %w", err)
+		}
+		for _, j := range jobNames {
+			stepsNode, err := jobsNode.Pipe(yaml.Lookup(j, "steps"))
+			if err != nil {
+				return nil, fmt.Errorf("lookup steps for job %s: %w", j, err)
+			}
+			steps, err := stepsNode.Elements()
+			if err != nil {
+				return nil, fmt.Errorf("get steps: %w", err)
+			}
+			for _, step := range steps {
+				withNode, err := step.Pipe(yaml.Lookup("with"))
+				if err != nil {
+					return nil, fmt.Errorf("lookup with: %w", err)
+				}
+				if withNode == nil {
+					continue
+				}
+				for input, tool := range inputs {
+					inputNode, err := withNode.Pipe(yaml.Get(input))
+					if err != nil {
+						return nil, fmt.Errorf("get %s: %w", input, err)
+					}
+					current := strings.TrimSpace(yaml.GetValue(inputNode))
+					if current == "" {
+						continue
+					}
+					latest, err := tool.Resolve(atNode(ctx, inputNode.YNode()), u, current)
+					if err != nil {
+						slog.WarnContext(ctx, "resolve input failed", logging.Failed.Attr(), "job", j, "input", input, "err", err)
+						continue
+					}
+					if latest == "" || latest == current {
+						continue
+					}
+					inputNode.YNode().Value = latest
+					slog.InfoContext(
+						ctx,
+						"updated tool input",
+						"job",
+						j,
+						"input",
+						input,
+						"from",
+						current,
+						"to",
+						latest,
+					)
+				}
+			}
+		}
+		return node, nil
+	})
+}
+
+// ActionDirectivePrefix starts the YAML comments of a workflow configuring its
+// action updates, e.g. "# automata: strategy=patch exclude=v5.0.0 pin".
+const ActionDirectivePrefix = "automata:"
+
+// WorkflowDirective is an automata comment heading a workflow, applying to
+// the action it names with action=owner/repo, or to all of its actions.
+type WorkflowDirective struct {
+	Action  string
+	Options []string
+}
+
+// ActionConfig describes action update behavior from the config file and
+// automata comments.
+type ActionConfig struct {
+	Transform   *regexp.Regexp
+	Excludes    []string
+	Prereleases bool
+	// Pin keeps the action on its current version.
+	Pin      bool
+	Strategy update.Strategy
+}
+
+// NewActionConfigs builds the ActionConfigs of the action declarations of the
+// config file, by owner/repo.
+func NewActionConfigs(decls []config.WorkflowAction) (map[string]ActionConfig, error) {
+	configs := make(map[string]ActionConfig, len(decls))
+	for _, d := range decls {
+		c := ActionConfig{Excludes: d.ExcludeTags, Prereleases: d.IncludePrereleases}
+		if d.TagRegex != "" {
+			re, err := regexp.Compile(d.TagRegex)
+			if err != nil {
+				return nil, fmt.Errorf("workflow action %s: invalid tag-regex %q: %w", d.Name, d.TagRegex, err)
+			}
+			c.Transform = re
+		}
+		configs[d.Name] = c
+	}
+	return configs, nil
+}
+
+// UpdateOptions returns the comparison options the config selects tags with.
+// Excluded tags are dropped so the next greatest one is selected instead.
+func (c ActionConfig) UpdateOptions() []update.Option {
+	options := []update.Option{
+		update.WithStrategy(c.Strategy),
+		update.WithPrereleases(c.Prereleases),
+	}
+	if c.Transform != nil {
+		options = append(options, update.WithTransform(c.Transform))
+	}
+	if len(c.Excludes) > 0 {
+		options = append(options, update.WithCandidateCheck(func(_ context.Context, candidate string) (bool, error) {
+			return !slices.Contains(c.Excludes, candidate), nil
+		}))
+	}
+	return options
+}
+
+// apply applies the options of an automata comment, in order, over the
+// config: strategy=full|minor|patch replaces the strategy, exclude=a,b adds
+// excluded tags and pin, or pin=false, pins the action.
+func (c *ActionConfig) apply(options []string) error {
+	for _, opt := range options {
+		key, value, hasValue := strings.Cut(opt, "=")
+		switch key {
+		case "strategy":
+			s, err := parseActionStrategy(value)
+			if err != nil {
+				return err
+			}
+			c.Strategy = s
+		case "exclude":
+			for _, tag := range strings.Split(value, ",") {
+				if tag != "" {
+					c.Excludes = append(c.Excludes, tag)
+				}
+			}
+		case "pin":
+			if !hasValue {
+				c.Pin = true
+				continue
+			}
+			pin, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid pin %q: %w", value, err)
+			}
+			c.Pin = pin
+		default:
+			return fmt.Errorf("unknown option %q", opt)
+		}
+	}
+	return nil
+}
+
+// parseActionStrategy parses the short strategy names of automata comments,
+// or the names of update.ParseStrategy.
+func parseActionStrategy(s string) (update.Strategy, error) {
+	switch s {
+	case "full":
+		return update.FullUpdate, nil
+	case "minor":
+		return update.MinorUpdate, nil
+	case "patch":
+		return update.PatchUpdate, nil
+	default:
+		return update.ParseStrategy(s)
+	}
+}
+
+// actionDirectives returns the options of the automata comments among the
+// given comment blocks.
+func actionDirectives(comments ...string) [][]string {
+	var out [][]string
+	for _, comment := range comments {
+		for _, line := range strings.Split(comment, "\n") {
+			line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "#"))
+			if rest, ok := strings.CutPrefix(line, ActionDirectivePrefix); ok {
+				out = append(out, strings.Fields(rest))
+			}
+		}
+	}
+	return out
+}
+
+// GetWorkflowDirectives reads the automata comments heading a workflow or
+// one of its top-level keys.
+func GetWorkflowDirectives(node *yaml.RNode) []WorkflowDirective {
+	comments := []string{node.YNode().HeadComment}
+	if doc := node.Document(); doc != nil {
+		comments = append(comments, doc.HeadComment)
+	}
+	if node.YNode().Kind == yaml.MappingNode {
+		for i := 0; i < len(node.Content()); i += 2 {
+			comments = append(comments, node.Content()[i].HeadComment)
+		}
+	}
+	var out []WorkflowDirective
+	for _, options := range actionDirectives(comments...) {
+		d := WorkflowDirective{}
+		for _, opt := range options {
+			if action, ok := strings.CutPrefix(opt, "action="); ok {
+				d.Action = action
+			} else {
+				d.Options = append(d.Options, opt)
+			}
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// GetActionConfig builds the config of the action of a step from its config,
+// or the config naming no action, then the workflow directives naming it or no
+// action, then the automata comments heading the step or trailing its uses,
+// later options overriding earlier ones.
+func GetActionConfig(
+	configs map[string]ActionConfig,
+	directives []WorkflowDirective,
+	action *github.ActionRef,
+	step, uses *yaml.RNode,
+) (ActionConfig, error) {
+	name := action.Owner + "/" + action.Repo
+	cfg, ok := configs[name]
+	if !ok {
+		cfg = configs[""]
+	}
+	cfg.Excludes = slices.Clone(cfg.Excludes)
+	for _, d := range directives {
+		if d.Action != "" && d.Action != name {
+			continue
+		}
+		if err := cfg.apply(d.Options); err != nil {
+			return ActionConfig{}, err
+		}
+	}
+	for _, options := range actionDirectives(step.YNode().HeadComment, uses.YNode().LineComment) {
+		if err := cfg.apply(options); err != nil {
+			return ActionConfig{}, err
+		}
+	}
+	return cfg, nil
+}
@@ -2,12 +2,17 @@ package kio
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 
+	"github.com/shikanime-studio/automata/internal/config"
 	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/toolversion"
 	update "github.com/shikanime-studio/automata/internal/updater"
 )
 
@@ -27,6 +32,53 @@ func (f fakeUpdater) Update(
 	return f.latest, nil
 }
 
+// prefetchingUpdater records the actions prefetched before any update.
+type prefetchingUpdater struct {
+	fakeUpdater
+	prefetched *[]string
+	updated    *atomic.Bool
+}
+
+func (p prefetchingUpdater) Prefetch(_ context.Context, actions []*github.ActionRef) error {
+	if p.updated.Load() {
+		return errors.New("prefetched after an update")
+	}
+	for _, a := range actions {
+		*p.prefetched = append(*p.prefetched, a.String())
+	}
+	return nil
+}
+
+func (p prefetchingUpdater) Update(ctx context.Context, a *github.ActionRef, opts ...update.Option) (string, error) {
+	p.updated.Store(true)
+	return p.fakeUpdater.Update(ctx, a, opts...)
+}
+
+func TestUpdateGitHubWorkflowsAction_Prefetch(t *testing.T) {
+	nodes := []*yaml.RNode{
+		yaml.MustParse(`jobs:
+  build:
+    steps:
+    - uses: actions/checkout@v4
+    - run: make
+`),
+		yaml.MustParse(`jobs:
+  test:
+    steps:
+    - uses: actions/setup-go@v5
+`),
+	}
+	var prefetched []string
+	u := prefetchingUpdater{fakeUpdater: fakeUpdater{latest: "v6"}, prefetched: &prefetched, updated: new(atomic.Bool)}
+	if _, err := UpdateGitHubWorkflowsAction(context.Background(), u, nil).Filter(nodes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"actions/checkout@v4", "actions/setup-go@v5"}
+	if !slices.Equal(prefetched, want) {
+		t.Errorf("got prefetched %v, want %v", prefetched, want)
+	}
+}
+
 func TestUpdateGitHubWorkflowStep_UpdatesUses(t *testing.T) {
 	doc := `uses: actions/checkout@v1`
 	rn := yaml.MustParse(doc)
@@ -34,6 +86,8 @@ func TestUpdateGitHubWorkflowStep_UpdatesUses(t *testing.T) {
 		context.Background(),
 		fakeUpdater{latest: "v2"},
 		"build",
+		nil,
+		nil,
 	).Filter(rn)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -54,6 +108,8 @@ func TestUpdateGitHubWorkflowStep_NoUses(t *testing.T) {
 		context.Background(),
 		fakeUpdater{latest: "v2"},
 		"build",
+		nil,
+		nil,
 	).Filter(rn)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -77,7 +133,7 @@ func TestUpdateGitHubWorkflowsAction_UpdatesJobs(t *testing.T) {
     - uses: actions/checkout@v1
 `
 	node := yaml.MustParse(doc)
-	filter := UpdateGitHubWorkflowsAction(context.Background(), fakeUpdater{latest: "v6"})
+	filter := UpdateGitHubWorkflowsAction(context.Background(), fakeUpdater{latest: "v6"}, nil)
 	_, err := filter.Filter([]*yaml.RNode{node})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -114,6 +170,8 @@ func TestUpdateGitHubWorkflowStep_EmptyLatestNoChange(t *testing.T) {
 		context.Background(),
 		fakeUpdater{latest: ""},
 		"build",
+		nil,
+		nil,
 	).Filter(rn)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -133,8 +191,144 @@ func TestUpdateGitHubWorkflowAction_NoJobs(t *testing.T) {
 	_, err := UpdateGitHubWorkflowAction(
 		context.Background(),
 		fakeUpdater{latest: "v2"},
+		nil,
 	).Filter(node)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+// selectingActionUpdater selects the latest of its tags with the options it
+// is given.
+type selectingActionUpdater struct {
+	tags []string
+}
+
+func (f selectingActionUpdater) Update(
+	ctx context.Context,
+	action *github.ActionRef,
+	opts ...update.Option,
+) (string, error) {
+	return update.SelectLatest(ctx, action.Version, f.tags, update.WithCompareOptions(opts...))
+}
+
+func TestUpdateGitHubWorkflowAction_Directives(t *testing.T) {
+	doc := `# automata: strategy=minor
+# automata: action=actions/setup-go exclude=v5.3.0
+
+name: ci
+jobs:
+  build:
+    steps:
+    - uses: actions/checkout@v4.1.0
+    - uses: actions/setup-go@v5.1.0
+    # automata: pin
+    - uses: actions/cache@v4.0.0
+    - uses: docker/login-action@v3.0.0 # automata: strategy=patch
+`
+	node := yaml.MustParse(doc)
+	u := selectingActionUpdater{tags: []string{
+		"v3.0.1", "v3.1.0", "v4.0.1", "v4.2.0", "v5.0.0", "v5.2.0", "v5.3.0", "v6.0.0",
+	}}
+	if _, err := UpdateGitHubWorkflowAction(context.Background(), u, nil).Filter(node); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := node.MustString()
+	for _, want := range []string{
+		"uses: actions/checkout@v4.2.0\n",
+		"uses: actions/setup-go@v5.2.0\n",
+		"uses: actions/cache@v4.0.0\n",
+		"uses: docker/login-action@v3.0.1 # automata: strategy=patch\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestUpdateGitHubWorkflowAction_Configs(t *testing.T) {
+	doc := `jobs:
+  build:
+    steps:
+    - uses: actions/checkout@v4.1.0
+    - uses: acme/deploy@release-1.0.0
+    - uses: docker/login-action@v3.0.0 # automata: strategy=minor exclude=v3.2.0-rc.1
+`
+	configs, err := NewActionConfigs([]config.WorkflowAction{
+		{Name: "actions/checkout", ExcludeTags: []string{"v4.2.0"}},
+		{Name: "acme/deploy", TagRegex: `^release-(?P<version>\d+\.\d+\.\d+)$`},
+		{IncludePrereleases: true},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	node := yaml.MustParse(doc)
+	u := selectingActionUpdater{tags: []string{
+		"v3.1.0", "v3.2.0-rc.1", "v3.2.0-rc.2", "v4.1.1", "v4.2.0", "v4.3.0-rc.1", "release-1.1.0",
+	}}
+	if _, err := UpdateGitHubWorkflowAction(context.Background(), u, configs).Filter(node); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := node.MustString()
+	for _, want := range []string{
+		"uses: actions/checkout@v4.1.1\n",
+		"uses: acme/deploy@release-1.1.0\n",
+		"uses: docker/login-action@v3.2.0-rc.2 # automata: strategy=minor exclude=v3.2.0-rc.1\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestNewActionConfigs_InvalidRegex(t *testing.T) {
+	if _, err := NewActionConfigs([]config.WorkflowAction{{Name: "a/b", TagRegex: "("}}); err == nil {
+		t.Fatal("expected error for invalid tag-regex")
+	}
+}
+
+func TestUpdateGitHubWorkflowStep_InvalidDirective(t *testing.T) {
+	rn := yaml.MustParse(`uses: actions/checkout@v1 # automata: strategy=sideways`)
+	_, err := UpdateGitHubWorkflowStep(
+		context.Background(),
+		fakeUpdater{latest: "v2"},
+		"build",
+		nil,
+		nil,
+	).Filter(rn)
+	if err == nil {
+		t.Fatal("expected error for invalid strategy")
+	}
+}
+
+func TestUpdateGitHubWorkflowInputsNode(t *testing.T) {
+	doc := `jobs:
+  build:
+    steps:
+    - uses: actions/setup-go@v5
+      with:
+        go-version: 1.21.3
+    - uses: actions/setup-node@v4
+      with:
+        node-version: ${{ matrix.node }}`
+	rn := yaml.MustParse(doc)
+	inputs := map[string]toolversion.Tool{
+		"go-version":   toolversion.DefaultTools["go"],
+		"node-version": toolversion.DefaultTools["node"],
+	}
+	_, err := UpdateGitHubWorkflowInputsNode(
+		context.Background(),
+		fakeUpdater{latest: "go1.22.1"},
+		inputs,
+	).Filter(rn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := rn.MustString()
+	if !strings.Contains(out, "go-version: 1.22.1") {
+		t.Fatalf("expected go-version bump, got:\n%s", out)
+	}
+	if !strings.Contains(out, "node-version: ${{ matrix.node }}") {
+		t.Fatalf("expected expression to be preserved, got:\n%s", out)
+	}
+}
@@ -0,0 +1,202 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/helm"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// FluxHelmGroup is the API group of Flux Helm releases.
+const FluxHelmGroup = "helm.toolkit.fluxcd.io"
+
+// UpdateHelmCharts builds a pipeline that updates the charts of the
+// kustomization helmCharts entries and Flux HelmReleases under path. Only
+// files holding one of them are written back.
+func UpdateHelmCharts(
+	ctx context.Context,
+	u update.Updater[*helm.ChartRef],
+	path string,
+) kio.Pipeline {
+	ctx = policy.WithDir(ctx, path)
+	return kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{
+				PackagePath:    path,
+				MatchFilesGlob: append([]string{"*.yaml", "*.yml"}, KustomizationFiles...),
+			},
+		},
+		Filters: []kio.Filter{
+			UpdateHelmChartsVersions(ctx, u),
+		},
+		Outputs: []kio.Writer{
+			newPackageWriter(ctx, path),
+		},
+	}
+}
+
+// UpdateHelmChartsVersions updates the charts across the loaded files,
+// resolving the repositories of HelmReleases from the HelmRepository sources
+// among them, and drops the files holding no chart.
+func UpdateHelmChartsVersions(ctx context.Context, u update.Updater[*helm.ChartRef]) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		repos := make(map[string]string)
+		for _, node := range nodes {
+			if isFluxSource(node, "HelmRepository") {
+				url, _ := node.GetString("spec.url")
+				repos[node.GetName()] = url
+			}
+		}
+		matched, err := KeepFilesWith(nodes, func(node *yaml.RNode) bool {
+			return IsHelmRelease(node) || (isKustomizationFile(node) && node.Field("helmCharts") != nil)
+		})
+		if err != nil {
+			return nil, err
+		}
+		g := errgroup.Group{}
+		for _, node := range matched {
+			g.Go(func() error {
+				ctx := withFile(ctx, node)
+				if err := node.PipeE(
+					UpdateKustomizationHelmCharts(ctx, u),
+					UpdateHelmRelease(ctx, u, repos),
+				); err != nil {
+					slog.WarnContext(ctx, "helm chart update failed", logging.Failed.Attr(), "path", policy.File(ctx), "err", err)
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+		return matched, nil
+	})
+}
+
+// IsHelmRelease reports whether the document is a Flux HelmRelease.
+func IsHelmRelease(node *yaml.RNode) bool {
+	group, _, _ := strings.Cut(node.GetApiVersion(), "/")
+	return group == FluxHelmGroup && node.GetKind() == "HelmRelease"
+}
+
+// isChartVersion reports whether a chart version is pinned, rather than a
+// range such as 1.x or >=1.0.0 <2.0.0.
+func isChartVersion(v string) bool {
+	if v == "" || strings.ContainsAny(v, " *<>=^~|") {
+		return false
+	}
+	for _, part := range strings.Split(v, ".") {
+		if part == "x" || part == "X" {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateKustomizationHelmCharts updates the versions of the helmCharts
+// entries of a kustomization pulling a chart from a repository. Local charts
+// and other documents are left alone.
+func UpdateKustomizationHelmCharts(ctx context.Context, u update.Updater[*helm.ChartRef]) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		if !isKustomizationFile(node) {
+			return node, nil
+		}
+		charts, err := node.Pipe(yaml.Lookup("helmCharts"))
+		if err != nil {
+			return nil, fmt.Errorf("lookup helmCharts: %w", err)
+		}
+		if charts == nil {
+			return node, nil
+		}
+		elems, err := charts.Elements()
+		if err != nil {
+			return nil, fmt.Errorf("helmCharts: %w", err)
+		}
+		for _, elem := range elems {
+			name, _ := elem.GetString("name")
+			repo, _ := elem.GetString("repo")
+			if name == "" || repo == "" {
+				continue
+			}
+			if err := updateChartVersion(ctx, u, elem, []string{"version"}, repo, name); err != nil {
+				return nil, err
+			}
+		}
+		return node, nil
+	})
+}
+
+// UpdateHelmRelease updates the chart version of a Flux HelmRelease. The
+// repository is the url of the HelmRepository it references, among repos by
+// name, or the canonical repository of the preset of the chart. Version
+// ranges, releases of a GitRepository or Bucket, and other documents are
+// left alone.
+func UpdateHelmRelease(
+	ctx context.Context,
+	u update.Updater[*helm.ChartRef],
+	repos map[string]string,
+) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		if !IsHelmRelease(node) {
+			return node, nil
+		}
+		name, _ := node.GetString("spec.chart.spec.chart")
+		kind, _ := node.GetString("spec.chart.spec.sourceRef.kind")
+		source, _ := node.GetString("spec.chart.spec.sourceRef.name")
+		if name == "" || kind != "HelmRepository" {
+			return node, nil
+		}
+		repo := repos[source]
+		if repo == "" {
+			p, ok := helm.LookupPreset(&helm.ChartRef{Name: name})
+			if !ok {
+				slog.DebugContext(ctx, "skipped chart of unknown repository", "chart", name, "source", source)
+				return node, nil
+			}
+			repo = p.Repository()
+		}
+		if err := updateChartVersion(ctx, u, node, []string{"spec", "chart", "spec", "version"}, repo, name); err != nil {
+			return nil, err
+		}
+		return node, nil
+	})
+}
+
+// updateChartVersion resolves and rewrites the chart version at path.
+func updateChartVersion(
+	ctx context.Context,
+	u update.Updater[*helm.ChartRef],
+	node *yaml.RNode,
+	path []string,
+	repo, name string,
+) error {
+	versionNode, err := node.Pipe(yaml.Lookup(path...))
+	if err != nil {
+		return fmt.Errorf("lookup version: %w", err)
+	}
+	version := yaml.GetValue(versionNode)
+	if !isChartVersion(version) {
+		return nil
+	}
+	latest, err := u.Update(atNode(ctx, versionNode.YNode()), &helm.ChartRef{RepoURL: repo, Name: name, Version: version})
+	if err != nil {
+		return fmt.Errorf("failed to fetch chart version: %w", err)
+	}
+	if latest == "" || latest == version {
+		return nil
+	}
+	versionNode.YNode().Value = latest
+	recordChartBump(ctx, helm.ChartRef{RepoURL: repo, Name: name, Version: latest})
+	slog.InfoContext(ctx, "updated chart version",
+		"name", name, "from", version, "to", latest, "repo", repo)
+	return nil
+}
@@ -0,0 +1,158 @@
+package kio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/helm"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// recordingHelmUpdater records the charts it looked up.
+type recordingHelmUpdater struct {
+	latest string
+	charts *[]helm.ChartRef
+}
+
+func (f recordingHelmUpdater) Update(
+	_ context.Context,
+	chart *helm.ChartRef,
+	_ ...update.Option,
+) (string, error) {
+	*f.charts = append(*f.charts, *chart)
+	return f.latest, nil
+}
+
+func TestUpdateKustomizationHelmCharts(t *testing.T) {
+	rn := yaml.MustParse(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+helmCharts:
+  - name: ingress-nginx
+    repo: https://kubernetes.github.io/ingress-nginx
+    version: 4.10.0
+    releaseName: ingress-nginx
+  - name: local
+    version: 1.0.0
+`)
+	var charts []helm.ChartRef
+	u := recordingHelmUpdater{latest: "4.11.2", charts: &charts}
+	if _, err := UpdateKustomizationHelmCharts(context.Background(), u).Filter(rn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	elems, err := rn.Pipe(yaml.Lookup("helmCharts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _ := elems.Elements()
+	if got, _ := list[0].GetString("version"); got != "4.11.2" {
+		t.Errorf("ingress-nginx version = %s, want 4.11.2", got)
+	}
+	if got, _ := list[1].GetString("version"); got != "1.0.0" {
+		t.Errorf("local chart version = %s, want 1.0.0", got)
+	}
+	if len(charts) != 1 {
+		t.Errorf("looked up %d charts, want 1", len(charts))
+	}
+}
+
+func TestUpdateHelmRelease(t *testing.T) {
+	release := func(source, version string) *yaml.RNode {
+		return yaml.MustParse(`apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: cert-manager
+spec:
+  chart:
+    spec:
+      chart: cert-manager
+      version: "` + version + `"
+      sourceRef:
+        kind: HelmRepository
+        name: ` + source)
+	}
+	tests := []struct {
+		name, source, version string
+		repos                 map[string]string
+		want, repo            string
+	}{
+		{
+			name: "declared source", source: "jetstack", version: "v1.14.0",
+			repos: map[string]string{"jetstack": "https://charts.example.com/jetstack"},
+			want:  "v1.15.0", repo: "https://charts.example.com/jetstack",
+		},
+		{
+			name: "preset", source: "jetstack", version: "v1.14.0",
+			want: "v1.15.0", repo: "https://charts.jetstack.io",
+		},
+		{
+			name: "range", source: "jetstack", version: "1.x",
+			want: "1.x",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rn := release(tt.source, tt.version)
+			var charts []helm.ChartRef
+			u := recordingHelmUpdater{latest: "v1.15.0", charts: &charts}
+			if _, err := UpdateHelmRelease(context.Background(), u, tt.repos).Filter(rn); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, _ := rn.GetString("spec.chart.spec.version"); got != tt.want {
+				t.Errorf("version = %s, want %s", got, tt.want)
+			}
+			if tt.repo != "" && (len(charts) != 1 || charts[0].RepoURL != tt.repo) {
+				t.Errorf("looked up %v, want repository %s", charts, tt.repo)
+			}
+		})
+	}
+}
+
+func TestUpdateHelmCharts_ResolvesHelmRepository(t *testing.T) {
+	dir := t.TempDir()
+	repository := `apiVersion: source.toolkit.fluxcd.io/v1
+kind: HelmRepository
+metadata:
+  name: internal
+spec:
+  url: https://charts.example.com
+`
+	release := `apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: app
+spec:
+  chart:
+    spec:
+      chart: app
+      version: 1.0.0
+      sourceRef:
+        kind: HelmRepository
+        name: internal
+`
+	if err := os.WriteFile(filepath.Join(dir, "repository.yaml"), []byte(repository), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "release.yaml"), []byte(release), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var charts []helm.ChartRef
+	u := recordingHelmUpdater{latest: "1.1.0", charts: &charts}
+	if err := UpdateHelmCharts(context.Background(), u, dir).Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "release.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "version: 1.1.0") {
+		t.Errorf("release not bumped:\n%s", got)
+	}
+	if len(charts) != 1 || charts[0].RepoURL != "https://charts.example.com" {
+		t.Errorf("looked up %v", charts)
+	}
+}
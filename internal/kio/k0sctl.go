@@ -2,8 +2,11 @@ package kio
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"regexp"
+	"slices"
 	"strings"
 
 	"golang.org/x/sync/errgroup"
@@ -11,15 +14,18 @@ import (
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 
 	"github.com/shikanime-studio/automata/internal/helm"
-	"github.com/shikanime-studio/automata/internal/updater"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
 )
 
 // UpdateK0sctlConfigs builds a pipeline to update helm chart versions in k0sctl configs.
 func UpdateK0sctlConfigs(
 	ctx context.Context,
-	u updater.Updater[*helm.ChartRef],
+	u update.Updater[*helm.ChartRef],
 	path string,
 ) kio.Pipeline {
+	ctx = policy.WithDir(ctx, path)
 	return kio.Pipeline{
 		Inputs: []kio.Reader{
 			kio.LocalPackageReader{
@@ -31,18 +37,127 @@ func UpdateK0sctlConfigs(
 			UpdateK0sctlConfigsCharts(ctx, u),
 		},
 		Outputs: []kio.Writer{
-			kio.LocalPackageWriter{PackagePath: path},
+			newPackageWriter(ctx, path),
 		},
 	}
 }
 
+// ChartsAnnotation is the annotation of a k0sctl config configuring the
+// version selection of its charts.
+const ChartsAnnotation = "automata.shikanime.studio/charts"
+
+// ChartsConfig describes chart update behavior from annotation.
+type ChartsConfig struct {
+	// Name is the chart name, as repository/chart or chart.
+	Name      string
+	Transform *regexp.Regexp
+	Excludes  []string
+	Ordering  update.Ordering
+	Strategy  update.Strategy
+}
+
+// UpdateOptions returns the comparison options the config selects versions
+// with.
+func (c ChartsConfig) UpdateOptions() []update.Option {
+	options := []update.Option{
+		update.WithOrdering(c.Ordering),
+		update.WithStrategy(c.Strategy),
+	}
+	if c.Transform != nil {
+		options = append(options, update.WithTransform(c.Transform))
+	}
+	return options
+}
+
+// UnmarshalJSON parses the JSON representation of ChartsConfig.
+func (c *ChartsConfig) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Name            string   `json:"name"`
+		VersionRegex    string   `json:"version-regex"`
+		ExcludeVersions []string `json:"exclude-versions"`
+		Ordering        string   `json:"ordering"`
+		UpdateStrategy  string   `json:"update-strategy"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.Name = raw.Name
+	if raw.VersionRegex != "" {
+		re, err := regexp.Compile(raw.VersionRegex)
+		if err != nil {
+			return fmt.Errorf("invalid version-regex %q: %w", raw.VersionRegex, err)
+		}
+		c.Transform = re
+	}
+	c.Excludes = raw.ExcludeVersions
+	ordering, err := update.ParseOrdering(raw.Ordering)
+	if err != nil {
+		return err
+	}
+	c.Ordering = ordering
+	strategy, err := update.ParseStrategy(raw.UpdateStrategy)
+	if err != nil {
+		return err
+	}
+	c.Strategy = strategy
+	return nil
+}
+
+// GetChartsConfig reads chart config from the charts annotation of a node,
+// validated against its schema.
+func GetChartsConfig(node *yaml.RNode) (map[string]ChartsConfig, error) {
+	annotation, err := node.Pipe(yaml.GetAnnotation(ChartsAnnotation))
+	if err != nil {
+		return nil, fmt.Errorf("get charts annotation: %w", err)
+	}
+	if yaml.IsMissingOrNull(annotation) {
+		return nil, nil
+	}
+	if err := validateAnnotation(ChartsAnnotation, annotation); err != nil {
+		return nil, err
+	}
+	var configs []ChartsConfig
+	if err := json.Unmarshal([]byte(annotation.YNode().Value), &configs); err != nil {
+		return nil, fmt.Errorf("unmarshal charts config from annotation: %w", err)
+	}
+	cfgByName := make(map[string]ChartsConfig, len(configs))
+	for _, c := range configs {
+		cfgByName[c.Name] = c
+	}
+	return cfgByName, nil
+}
+
+// K0sctlKubernetesVersion returns the Kubernetes version of a k0sctl
+// configuration, read from spec.k0s.version without its k0s build suffix.
+func K0sctlKubernetesVersion(node *yaml.RNode) (string, error) {
+	versionNode, err := node.Pipe(yaml.Lookup("spec", "k0s", "version"))
+	if err != nil {
+		return "", fmt.Errorf("lookup k0s version: %w", err)
+	}
+	version, _, _ := strings.Cut(yaml.GetValue(versionNode), "+")
+	if version == "" {
+		return "", fmt.Errorf("k0s version is not set")
+	}
+	return version, nil
+}
+
+// ReadK0sctlKubernetesVersion reads the Kubernetes version of the k0sctl
+// configuration at path.
+func ReadK0sctlKubernetesVersion(path string) (string, error) {
+	node, err := yaml.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", path, err)
+	}
+	return K0sctlKubernetesVersion(node)
+}
+
 // UpdateK0sctlConfigsCharts runs chart updates across all loaded config files.
-func UpdateK0sctlConfigsCharts(ctx context.Context, u updater.Updater[*helm.ChartRef]) kio.Filter {
+func UpdateK0sctlConfigsCharts(ctx context.Context, u update.Updater[*helm.ChartRef]) kio.Filter {
 	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
 		g := errgroup.Group{}
 		for _, node := range nodes {
 			g.Go(func() error {
-				if err := node.PipeE(UpdateK0sctlConfig(ctx, u)); err != nil {
+				if err := node.PipeE(UpdateK0sctlConfig(withFile(ctx, node), u)); err != nil {
 					return err
 				}
 				return nil
@@ -55,9 +170,31 @@ func UpdateK0sctlConfigsCharts(ctx context.Context, u updater.Updater[*helm.Char
 	})
 }
 
-// UpdateK0sctlConfig updates charts inside one k0sctl configuration.
-func UpdateK0sctlConfig(ctx context.Context, u updater.Updater[*helm.ChartRef]) yaml.Filter {
+// UpdateK0sctlConfig updates charts inside one k0sctl configuration. Charts
+// are left alone when the k0s version does not support the platform of a
+// host, as the configuration could not be applied anyway.
+func UpdateK0sctlConfig(ctx context.Context, u update.Updater[*helm.ChartRef]) yaml.Filter {
 	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		versionNode, err := node.Pipe(yaml.Lookup("spec", "k0s", "version"))
+		if err != nil {
+			return nil, fmt.Errorf("lookup k0s version: %w", err)
+		}
+		if version := yaml.GetValue(versionNode); version != "" {
+			host, unsupported, err := unsupportedK0sctlHost(ctx, node, version)
+			if err != nil {
+				return nil, err
+			}
+			if unsupported {
+				slog.InfoContext(ctx, "incompatible k0s version skipped chart updates", logging.Incompatible.Attr(),
+					"name", "k0s", "version", version, "file", policy.File(ctx),
+					"host", host.Name, "os", host.OS, "arch", host.Arch)
+				return node, nil
+			}
+		}
+		chartConfigs, err := GetChartsConfig(node)
+		if err != nil {
+			return nil, fmt.Errorf("get chart config: %w", err)
+		}
 		repos := map[string]string{}
 		reposNode, err := node.Pipe(
 			yaml.Lookup("spec", "k0s", "config", "spec", "extensions", "helm", "repositories"),
@@ -98,8 +235,8 @@ func UpdateK0sctlConfig(ctx context.Context, u updater.Updater[*helm.ChartRef])
 		g := errgroup.Group{}
 		for _, node := range charts {
 			g.Go(func() error {
-				if err := node.PipeE(UpdateK0sctlConfigchart(ctx, u, repos)); err != nil {
-					slog.WarnContext(ctx, "chart update failed", "err", err)
+				if err := node.PipeE(UpdateK0sctlConfigchart(ctx, u, repos, chartConfigs)); err != nil {
+					slog.WarnContext(ctx, "chart update failed", logging.Failed.Attr(), "err", err)
 				}
 				return nil
 			})
@@ -111,11 +248,14 @@ func UpdateK0sctlConfig(ctx context.Context, u updater.Updater[*helm.ChartRef])
 	})
 }
 
-// UpdateK0sctlConfigchart updates a single chart entry version in the config.
+// UpdateK0sctlConfigchart updates a single chart entry version in the config,
+// selecting versions with the config of the chart, by chartname or chart
+// name, when one is given.
 func UpdateK0sctlConfigchart(
 	ctx context.Context,
-	u updater.Updater[*helm.ChartRef],
+	u update.Updater[*helm.ChartRef],
 	repos map[string]string,
+	configs map[string]ChartsConfig,
 ) yaml.Filter {
 	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
 		chartNameNode, err := node.Pipe(yaml.Get("chartname"))
@@ -130,6 +270,10 @@ func UpdateK0sctlConfigchart(
 		if len(parts) != 2 {
 			return nil, fmt.Errorf("chart name is invalid: %s", chartName)
 		}
+		cfg, ok := configs[chartName]
+		if !ok {
+			cfg = configs[parts[1]]
+		}
 		chartName = parts[1]
 		repoName := parts[0]
 		repoURL, ok := repos[repoName]
@@ -150,13 +294,18 @@ func UpdateK0sctlConfigchart(
 		}
 
 		ref := &helm.ChartRef{RepoURL: repoURL, Name: chartName, Version: version}
-		ver, err := u.Update(ctx, ref)
+		pos := node
+		if versionNode != nil {
+			pos = versionNode
+		}
+		ver, err := u.Update(atNode(ctx, pos.YNode()), ref, cfg.UpdateOptions()...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to fetch chart version: %w", err)
 		}
-		if ver == "" {
+		if ver == "" || ver == version || slices.Contains(cfg.Excludes, ver) {
 			return node, nil
 		}
+		recordChartBump(ctx, helm.ChartRef{RepoURL: repoURL, Name: chartName, Version: ver})
 		if err := node.PipeE(yaml.SetField("version", yaml.NewStringRNode(ver))); err != nil {
 			return nil, fmt.Errorf("set version failed: %w", err)
 		}
@@ -35,6 +35,7 @@ version: 1.0.0`
 		context.Background(),
 		fakeHelmUpdater{latest: "1.1.0"},
 		repos,
+		nil,
 	).Filter(rn)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -57,6 +58,7 @@ version: 1.0.0`
 		context.Background(),
 		fakeHelmUpdater{latest: ""},
 		repos,
+		nil,
 	).Filter(rn)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -79,6 +81,7 @@ version: 1.0.0`
 		context.Background(),
 		fakeHelmUpdater{latest: "1.2.0"},
 		repos,
+		nil,
 	).Filter(rn)
 	if err == nil {
 		t.Fatalf("expected error for invalid repo URL")
@@ -145,3 +148,96 @@ func TestUpdateK0sctlConfig_NoCharts(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestK0sctlKubernetesVersion(t *testing.T) {
+	rn := yaml.MustParse(`apiVersion: k0sctl.k0sproject.io/v1beta1
+kind: Cluster
+spec:
+  k0s:
+    version: v1.30.2+k0s.0`)
+	got, err := K0sctlKubernetesVersion(rn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "v1.30.2" {
+		t.Fatalf("unexpected version: %s", got)
+	}
+}
+
+// selectingHelmUpdater selects the latest of its versions with the options
+// it is given.
+type selectingHelmUpdater struct {
+	versions []string
+}
+
+func (f selectingHelmUpdater) Update(
+	ctx context.Context,
+	chart *helm.ChartRef,
+	opts ...update.Option,
+) (string, error) {
+	return update.SelectLatest(ctx, chart.Version, f.versions, update.WithCompareOptions(opts...))
+}
+
+func TestUpdateK0sctlConfig_ChartsAnnotation(t *testing.T) {
+	doc := `apiVersion: k0sctl.k0sproject.io/v1beta1
+kind: Cluster
+metadata:
+  name: k0s
+  annotations:
+    automata.shikanime.studio/charts: |
+      [
+        {"name": "repo/app", "update-strategy": "MinorUpdate"},
+        {"name": "other", "update-strategy": "PatchUpdate", "exclude-versions": ["0.1.2"]},
+        {"name": "tagged", "version-regex": "^app-(?P<version>\\d+\\.\\d+\\.\\d+)$"}
+      ]
+spec:
+  k0s:
+    config:
+      spec:
+        extensions:
+          helm:
+            repositories:
+            - name: repo
+              url: https://example.com
+            charts:
+            - chartname: repo/app
+              version: 1.0.0
+            - chartname: repo/other
+              version: 0.1.0
+            - chartname: repo/tagged
+              version: app-1.0.0
+            - chartname: repo/plain
+              version: 1.0.0`
+	rn := yaml.MustParse(doc)
+	u := selectingHelmUpdater{versions: []string{
+		"1.2.0", "2.0.0", "0.1.2", "0.2.0", "app-1.1.0", "app-2.0.0",
+	}}
+	if _, err := UpdateK0sctlConfig(context.Background(), u).Filter(rn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	chartsNode, err := rn.Pipe(
+		yaml.Lookup("spec", "k0s", "config", "spec", "extensions", "helm", "charts"),
+	)
+	if err != nil {
+		t.Fatalf("lookup charts: %v", err)
+	}
+	elems, err := chartsNode.Elements()
+	if err != nil {
+		t.Fatalf("elements: %v", err)
+	}
+	want := []string{"1.2.0", "0.1.0", "app-2.0.0", "2.0.0"}
+	for i, e := range elems {
+		if got, _ := e.GetString("version"); got != want[i] {
+			t.Errorf("chart %d version = %s, want %s", i, got, want[i])
+		}
+	}
+}
+
+func TestGetChartsConfig_Invalid(t *testing.T) {
+	rn := yaml.MustParse(`metadata:
+  annotations:
+    automata.shikanime.studio/charts: '[{"name":"app","update-strategy":"Patch"}]'`)
+	if _, err := GetChartsConfig(rn); err == nil {
+		t.Fatal("expected an error for an unknown strategy")
+	}
+}
@@ -0,0 +1,149 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/config"
+)
+
+// DefaultK0sPlatforms are the host platforms k0s supports when none are
+// configured: the architectures it publishes binaries for, on any operating
+// system.
+var DefaultK0sPlatforms = []config.K0sPlatform{
+	{Arch: "amd64"},
+	{Arch: "arm64"},
+	{Arch: "arm"},
+}
+
+type k0sPlatformsKey struct{}
+
+// WithK0sPlatforms returns a context checking k0s bumps against the given
+// host platforms instead of DefaultK0sPlatforms.
+func WithK0sPlatforms(ctx context.Context, platforms []config.K0sPlatform) context.Context {
+	return context.WithValue(ctx, k0sPlatformsKey{}, platforms)
+}
+
+// k0sPlatforms returns the host platforms of the context.
+func k0sPlatforms(ctx context.Context) []config.K0sPlatform {
+	if p, _ := ctx.Value(k0sPlatformsKey{}).([]config.K0sPlatform); len(p) > 0 {
+		return p
+	}
+	return DefaultK0sPlatforms
+}
+
+// K0sctlHost is the platform a k0sctl host declares.
+type K0sctlHost struct {
+	// Name is the address of the host, or its position when it has none.
+	Name string
+	// OS and Arch are the operating system and architecture the host
+	// declares, empty when left to k0sctl to detect.
+	OS   string
+	Arch string
+}
+
+// K0sctlHosts returns the hosts of a k0sctl configuration, read from
+// spec.hosts.
+func K0sctlHosts(node *yaml.RNode) ([]K0sctlHost, error) {
+	hostsNode, err := node.Pipe(yaml.Lookup("spec", "hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("lookup hosts: %w", err)
+	}
+	if hostsNode == nil {
+		return nil, nil
+	}
+	elems, err := hostsNode.Elements()
+	if err != nil {
+		return nil, fmt.Errorf("hosts: %w", err)
+	}
+	hosts := make([]K0sctlHost, 0, len(elems))
+	for i, e := range elems {
+		h := K0sctlHost{Name: strconv.Itoa(i)}
+		for _, p := range [][]string{{"ssh", "address"}, {"winRM", "address"}, {"openSSH", "address"}} {
+			if n, err := e.Pipe(yaml.Lookup(p...)); err == nil && yaml.GetValue(n) != "" {
+				h.Name = yaml.GetValue(n)
+				break
+			}
+		}
+		if n, err := e.Pipe(yaml.Lookup("localhost", "enabled")); err == nil && yaml.GetValue(n) == "true" {
+			h.Name = "localhost"
+		}
+		if n, err := e.Pipe(yaml.Get("os")); err == nil {
+			h.OS = yaml.GetValue(n)
+		}
+		if n, err := e.Pipe(yaml.Get("arch")); err == nil {
+			h.Arch = yaml.GetValue(n)
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// UnsupportedK0sHost returns the first host whose platform the k0s version
+// does not support. A host is supported when a platform matching its
+// operating system and architecture covers the version; hosts declaring
+// neither are left to k0sctl and always supported, as are versions that are
+// not semantic versions.
+func UnsupportedK0sHost(platforms []config.K0sPlatform, version string, hosts []K0sctlHost) (K0sctlHost, bool) {
+	v := k0sSemver(version)
+	if !semver.IsValid(v) {
+		return K0sctlHost{}, false
+	}
+	for _, h := range hosts {
+		if h.OS == "" && h.Arch == "" {
+			continue
+		}
+		supported := false
+		for _, p := range platforms {
+			if matchesK0sPlatform(p, h) && coversK0sVersion(p, v) {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return h, true
+		}
+	}
+	return K0sctlHost{}, false
+}
+
+// matchesK0sPlatform reports whether the platform applies to the host.
+func matchesK0sPlatform(p config.K0sPlatform, h K0sctlHost) bool {
+	return (p.OS == "" || strings.EqualFold(p.OS, h.OS)) &&
+		(p.Arch == "" || strings.EqualFold(p.Arch, h.Arch))
+}
+
+// coversK0sVersion reports whether the canonical version is within the
+// version range of the platform.
+func coversK0sVersion(p config.K0sPlatform, v string) bool {
+	if p.Since != "" && semver.Compare(v, k0sSemver(p.Since)) < 0 {
+		return false
+	}
+	if p.Until != "" && semver.Compare(v, k0sSemver(p.Until)) >= 0 {
+		return false
+	}
+	return true
+}
+
+// k0sSemver returns a k0s version as a semantic version without its k0s build
+// suffix, e.g. v1.30.2 for 1.30.2+k0s.0.
+func k0sSemver(version string) string {
+	v, _, _ := strings.Cut(strings.TrimSpace(version), "+")
+	return "v" + strings.TrimPrefix(v, "v")
+}
+
+// unsupportedK0sctlHost returns the first host of a k0sctl configuration the
+// k0s version does not support on the platforms of the context.
+func unsupportedK0sctlHost(ctx context.Context, node *yaml.RNode, version string) (K0sctlHost, bool, error) {
+	hosts, err := K0sctlHosts(node)
+	if err != nil {
+		return K0sctlHost{}, false, err
+	}
+	h, ok := UnsupportedK0sHost(k0sPlatforms(ctx), version, hosts)
+	return h, ok, nil
+}
@@ -0,0 +1,127 @@
+package kio
+
+import (
+	"context"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/config"
+)
+
+func TestUnsupportedK0sHost(t *testing.T) {
+	platforms := []config.K0sPlatform{
+		{Arch: "amd64"},
+		{OS: "alpine", Arch: "arm", Until: "v1.31"},
+	}
+	cases := []struct {
+		version string
+		host    K0sctlHost
+		want    bool
+	}{
+		{"v1.31.1+k0s.0", K0sctlHost{Arch: "amd64"}, false},
+		{"v1.31.1+k0s.0", K0sctlHost{OS: "alpine", Arch: "arm"}, true},
+		{"v1.30.4+k0s.0", K0sctlHost{OS: "alpine", Arch: "arm"}, false},
+		{"v1.30.4+k0s.0", K0sctlHost{OS: "debian", Arch: "arm"}, true},
+		{"v1.30.4+k0s.0", K0sctlHost{Arch: "s390x"}, true},
+		{"v1.30.4+k0s.0", K0sctlHost{}, false},
+		{"latest", K0sctlHost{Arch: "s390x"}, false},
+	}
+	for _, c := range cases {
+		_, got := UnsupportedK0sHost(platforms, c.version, []K0sctlHost{c.host})
+		if got != c.want {
+			t.Errorf("UnsupportedK0sHost(%s, %+v) = %v, want %v", c.version, c.host, got, c.want)
+		}
+	}
+}
+
+func TestK0sctlHosts(t *testing.T) {
+	rn := yaml.MustParse(`spec:
+  hosts:
+  - role: controller
+    ssh:
+      address: 10.0.0.1
+    os: alpine
+    arch: arm64
+  - role: worker
+    localhost:
+      enabled: true`)
+	hosts, err := K0sctlHosts(rn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []K0sctlHost{{Name: "10.0.0.1", OS: "alpine", Arch: "arm64"}, {Name: "localhost"}}
+	if len(hosts) != len(want) || hosts[0] != want[0] || hosts[1] != want[1] {
+		t.Fatalf("unexpected hosts: %+v", hosts)
+	}
+}
+
+func TestUpdateK0sctlConfig_SkipsUnsupportedHosts(t *testing.T) {
+	rn := yaml.MustParse(`spec:
+  hosts:
+  - role: single
+    arch: s390x
+  k0s:
+    version: v1.30.2+k0s.0
+    config:
+      spec:
+        extensions:
+          helm:
+            repositories:
+            - name: repo
+              url: https://example.com
+            charts:
+            - chartname: repo/app
+              version: 1.0.0`)
+	_, err := UpdateK0sctlConfig(context.Background(), fakeHelmUpdater{latest: "2.0.0"}).Filter(rn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	verNode, err := rn.Pipe(yaml.Lookup("spec", "k0s", "config", "spec", "extensions", "helm", "charts", "0", "version"))
+	if err != nil {
+		t.Fatalf("lookup version: %v", err)
+	}
+	if got := yaml.GetValue(verNode); got != "1.0.0" {
+		t.Fatalf("unexpected version: %s", got)
+	}
+}
+
+func TestUpdatePathRulesNode_RejectsUnsupportedK0s(t *testing.T) {
+	rule, err := NewPathRule(config.Rule{
+		Files:      "cluster.yaml",
+		Path:       "spec.k0s.version",
+		Source:     "github",
+		Repository: "k0sproject/k0s",
+	}, RuleSources{GitHub: fakeUpdater{latest: "v1.31.1+k0s.0"}})
+	if err != nil {
+		t.Fatalf("new rule: %v", err)
+	}
+	doc := `apiVersion: k0sctl.k0sproject.io/v1beta1
+kind: Cluster
+spec:
+  hosts:
+  - role: single
+    arch: arm
+  k0s:
+    version: v1.30.4+k0s.0`
+	for _, c := range []struct {
+		platforms []config.K0sPlatform
+		want      string
+	}{
+		{nil, "v1.31.1+k0s.0"},
+		{[]config.K0sPlatform{{Arch: "arm", Until: "v1.31"}}, "v1.30.4+k0s.0"},
+	} {
+		rn := yaml.MustParse(doc)
+		ctx := WithK0sPlatforms(context.Background(), c.platforms)
+		if _, err := UpdatePathRulesNode(ctx, []PathRule{rule}).Filter(rn); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		versionNode, err := rn.Pipe(yaml.Lookup("spec", "k0s", "version"))
+		if err != nil {
+			t.Fatalf("lookup version: %v", err)
+		}
+		if got := yaml.GetValue(versionNode); got != c.want {
+			t.Fatalf("platforms %+v: unexpected version %s", c.platforms, got)
+		}
+	}
+}
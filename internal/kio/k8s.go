@@ -1,11 +1,84 @@
 package kio
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
 
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/policy"
 )
 
+// withFile records the file a resource was read from in the context, so
+// policies can match on it, along with the index of its document in the file.
+func withFile(ctx context.Context, node *yaml.RNode) context.Context {
+	p, index, err := kioutil.GetFileAnnotations(node)
+	if err != nil || p == "" {
+		return ctx
+	}
+	ctx = policy.WithFile(ctx, p)
+	if i, err := strconv.Atoi(index); err == nil {
+		ctx = context.WithValue(ctx, documentKey{}, i)
+	}
+	return ctx
+}
+
+type documentKey struct{}
+
+// atNode returns a context recording the position of the node holding the
+// version being updated, so reports point at it. The reader decodes each
+// document of a file on its own, so the line of the document in the file is
+// added to the line of the node. Nodes added by filters have no position.
+func atNode(ctx context.Context, n *yaml.Node) context.Context {
+	line := n.Line
+	if i, ok := ctx.Value(documentKey{}).(int); ok && i > 0 && line > 0 {
+		line += documentLine(policy.Path(ctx), i) - 1
+	}
+	return policy.WithPosition(ctx, line, n.Column)
+}
+
+// documentSeparator separates the documents of a file, as the kyaml reader
+// splits them.
+var documentSeparator = regexp.MustCompile(`\n---.*\n`)
+
+// documentLine returns the line the document of the given index starts at in
+// the file at path, or 1 when it cannot be read. Like the kyaml reader, only
+// documents holding more than comments are indexed.
+func documentLine(path string, index int) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 1
+	}
+	content := strings.ReplaceAll(string(data), "\r\n", "\n")
+	i, start := 0, 0
+	for _, loc := range append(documentSeparator.FindAllStringIndex(content, -1), []int{len(content), len(content)}) {
+		if hasDocumentContent(content[start:loc[0]]) {
+			if i == index {
+				return strings.Count(content[:start], "\n") + 1
+			}
+			i++
+		}
+		start = loc[1]
+	}
+	return 1
+}
+
+// hasDocumentContent reports whether a document holds more than blank lines
+// and comments.
+func hasDocumentContent(doc string) bool {
+	for _, line := range strings.Split(doc, "\n") {
+		if line = strings.TrimSpace(line); line != "" && line != "---" && !strings.HasPrefix(line, "#") {
+			return true
+		}
+	}
+	return false
+}
+
 // RecommandedLabelsSetter sets Kubernetes recommended labels on resources.
 type RecommandedLabelsSetter struct {
 	Name    string `yaml:"name,omitempty"`
@@ -41,3 +114,31 @@ func (s RecommandedLabelsSetter) Filter(rn *yaml.RNode) (*yaml.RNode, error) {
 func SetRecommandedLabels(name, version string) RecommandedLabelsSetter {
 	return RecommandedLabelsSetter{Name: name, Version: version}
 }
+
+// KeepFilesWith returns the nodes of every file that holds at least one node
+// matching the predicate, so writers leave unrelated files untouched while
+// multi-document files are written back whole.
+func KeepFilesWith(nodes []*yaml.RNode, match func(*yaml.RNode) bool) ([]*yaml.RNode, error) {
+	files := map[string]bool{}
+	for _, node := range nodes {
+		if !match(node) {
+			continue
+		}
+		p, _, err := kioutil.GetFileAnnotations(node)
+		if err != nil {
+			return nil, fmt.Errorf("get file annotations: %w", err)
+		}
+		files[p] = true
+	}
+	var kept []*yaml.RNode
+	for _, node := range nodes {
+		p, _, err := kioutil.GetFileAnnotations(node)
+		if err != nil {
+			return nil, fmt.Errorf("get file annotations: %w", err)
+		}
+		if files[p] {
+			kept = append(kept, node)
+		}
+	}
+	return kept, nil
+}
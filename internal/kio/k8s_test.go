@@ -1,8 +1,10 @@
 package kio
 
 import (
+	"strings"
 	"testing"
 
+	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
@@ -44,3 +46,37 @@ func TestRecommandedLabelsSetter_Filter(t *testing.T) {
 		}
 	}
 }
+
+func TestKeepFilesWith(t *testing.T) {
+	nodes, err := (&kio.ByteReader{Reader: strings.NewReader(`apiVersion: tekton.dev/v1
+kind: Task
+metadata:
+  name: a
+  annotations:
+    config.kubernetes.io/path: tasks.yaml
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+  annotations:
+    config.kubernetes.io/path: tasks.yaml
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: c
+  annotations:
+    config.kubernetes.io/path: other.yaml
+`)}).Read()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	kept, err := KeepFilesWith(nodes, IsTektonResource)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(kept) != 2 || kept[0].GetName() != "a" || kept[1].GetName() != "b" {
+		t.Fatalf("unexpected kept nodes: %d", len(kept))
+	}
+}
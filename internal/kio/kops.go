@@ -0,0 +1,131 @@
+package kio
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/plugin"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// KOpsGroup is the API group of kOps resources.
+const KOpsGroup = "kops.k8s.io"
+
+// KOpsImages are the fields of a kOps cluster spec holding the image of a
+// component or addon.
+var KOpsImages = [][]string{
+	{"spec", "kubeAPIServer", "image"},
+	{"spec", "kubeControllerManager", "image"},
+	{"spec", "kubeScheduler", "image"},
+	{"spec", "kubeProxy", "image"},
+	{"spec", "kubeDNS", "coreDNSImage"},
+	{"spec", "clusterAutoscaler", "image"},
+	{"spec", "certManager", "image"},
+	{"spec", "metricsServer", "image"},
+	{"spec", "externalDNS", "image"},
+	{"spec", "nodeProblemDetector", "image"},
+}
+
+// AMISource is the source plugin machine images of kOps instance groups are
+// resolved with, as automata-source-ami.
+const AMISource = "ami"
+
+// amiDate matches the build date versioning machine image names, e.g.
+// 20240301 in ubuntu-jammy-22.04-amd64-server-20240301.
+var amiDate = regexp.MustCompile(`\b(?P<version>20\d{6}(?:[.-]\d+)?)`)
+
+// KOpsRules returns rules for kOps clusters and instance groups. The
+// Kubernetes version and the Cilium version follow their GitHub releases, the
+// component and addon images the tags of their registry, and the machine
+// images of instance groups the versions listed by the ami source plugin,
+// when one is on PATH.
+func KOpsRules(
+	cu update.Updater[*container.ImageRef],
+	gu update.Updater[*github.ActionRef],
+	pu update.Updater[*plugin.Request],
+) []ResourceRule {
+	rules := []ResourceRule{
+		{
+			Group:    KOpsGroup,
+			Kind:     "Cluster",
+			Path:     []string{"spec", "kubernetesVersion"},
+			Resolver: keepVersionPrefix(ResolveGitHubTag(gu, "kubernetes", "kubernetes")),
+		},
+		{
+			Group:    KOpsGroup,
+			Kind:     "Cluster",
+			Path:     []string{"spec", "networking", "cilium", "version"},
+			Resolver: keepVersionPrefix(ResolveGitHubTag(gu, "cilium", "cilium")),
+		},
+	}
+	for _, path := range KOpsImages {
+		rules = append(rules, ResourceRule{
+			Group:    KOpsGroup,
+			Kind:     "Cluster",
+			Path:     path,
+			Resolver: ResolveImage(cu),
+		})
+	}
+	return append(rules, ResourceRule{
+		Group:    KOpsGroup,
+		Kind:     "InstanceGroup",
+		Path:     []string{"spec", "image"},
+		Resolver: ResolveAMI(pu),
+	})
+}
+
+// keepVersionPrefix resolves versions with r, keeping the "v" prefix of the
+// current version, as kOps accepts both 1.29.3 and v1.29.3. Versions given
+// as URLs, e.g. of a custom Kubernetes build, are left alone.
+func keepVersionPrefix(r VersionResolver) VersionResolver {
+	return VersionResolverFunc(func(ctx context.Context, current string) (string, error) {
+		if strings.Contains(current, "/") {
+			return "", nil
+		}
+		latest, err := r.Resolve(ctx, current)
+		if err != nil || latest == "" {
+			return latest, err
+		}
+		if strings.HasPrefix(current, "v") {
+			return "v" + strings.TrimPrefix(latest, "v"), nil
+		}
+		return strings.TrimPrefix(latest, "v"), nil
+	})
+}
+
+// ResolveAMI resolves machine image names versioned by their build date with
+// the ami source plugin. The plugin is asked for the versions of the name
+// with its date replaced by "*", as an image name filter, and the date is
+// replaced in the name as written. Image IDs, SSM parameters, undated names
+// and runs without the plugin are left alone.
+func ResolveAMI(u update.Updater[*plugin.Request]) VersionResolver {
+	return VersionResolverFunc(func(ctx context.Context, current string) (string, error) {
+		if strings.HasPrefix(current, "ami-") || strings.HasPrefix(current, "ssm:") {
+			return "", nil
+		}
+		m := amiDate.FindStringSubmatchIndex(current)
+		if m == nil {
+			return "", nil
+		}
+		if _, err := plugin.LookupSource(AMISource); err != nil {
+			slog.DebugContext(ctx, "skipped machine image without source plugin", "image", current, "err", err)
+			return "", nil
+		}
+		start, end := m[2], m[3]
+		version := current[start:end]
+		latest, err := u.Update(ctx, &plugin.Request{
+			Source:  AMISource,
+			Name:    current[:start] + "*" + current[end:],
+			Image:   current,
+			Version: version,
+		})
+		if err != nil || latest == "" || latest == version {
+			return "", err
+		}
+		return current[:start] + latest + current[end:], nil
+	})
+}
@@ -0,0 +1,90 @@
+package kio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/plugin"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+type fakePluginUpdater struct {
+	latest string
+	req    *plugin.Request
+}
+
+func (f *fakePluginUpdater) Update(
+	_ context.Context,
+	req *plugin.Request,
+	_ ...update.Option,
+) (string, error) {
+	f.req = req
+	return f.latest, nil
+}
+
+func TestKOpsRules_Cluster(t *testing.T) {
+	rn := yaml.MustParse(`apiVersion: kops.k8s.io/v1alpha2
+kind: Cluster
+metadata:
+  name: k8s.example.com
+spec:
+  kubernetesVersion: 1.29.3
+  networking:
+    cilium:
+      version: v1.15.1
+  kubeProxy:
+    image: registry.k8s.io/kube-proxy:v1.29.3`)
+	rules := KOpsRules(
+		fakeImageUpdater{latest: "v1.30.1"},
+		fakeUpdater{latest: "v1.30.1"},
+		&fakePluginUpdater{},
+	)
+	if _, err := UpdateResourceVersions(context.Background(), rules).Filter(rn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, c := range []struct {
+		path []string
+		want string
+	}{
+		{[]string{"spec", "kubernetesVersion"}, "1.30.1"},
+		{[]string{"spec", "networking", "cilium", "version"}, "v1.30.1"},
+		{[]string{"spec", "kubeProxy", "image"}, "registry.k8s.io/kube-proxy:v1.30.1"},
+	} {
+		n, err := rn.Pipe(yaml.Lookup(c.path...))
+		if err != nil {
+			t.Fatalf("lookup %v: %v", c.path, err)
+		}
+		if got := yaml.GetValue(n); got != c.want {
+			t.Errorf("%v = %s, want %s", c.path, got, c.want)
+		}
+	}
+}
+
+func TestResolveAMI(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, plugin.SourcePrefix+AMISource), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	pu := &fakePluginUpdater{latest: "20240501"}
+	current := "099720109477/ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-20240301"
+	got, err := ResolveAMI(pu).Resolve(context.Background(), current)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "099720109477/ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-20240501"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if want := "099720109477/ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-*"; pu.req.Name != want {
+		t.Fatalf("requested %s, want %s", pu.req.Name, want)
+	}
+	for _, image := range []string{"ami-0123456789abcdef0", "ssm:/aws/service/canonical/ubuntu"} {
+		if got, err := ResolveAMI(pu).Resolve(context.Background(), image); err != nil || got != "" {
+			t.Errorf("Resolve(%s) = %q, %v, want it left alone", image, got, err)
+		}
+	}
+}
@@ -3,60 +3,139 @@ package kio
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"regexp"
+	"sync"
 
 	"golang.org/x/sync/errgroup"
 	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 
 	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
 	update "github.com/shikanime-studio/automata/internal/updater"
 )
 
-// UpdateKustomization creates a kustomize pipeline to update image tags
-// and recommended labels for images defined in the kustomization.yaml at the given directory.
+// KustomizationFiles are the file names kustomize accepts for a
+// kustomization.
+var KustomizationFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// UpdateKustomization creates a kustomize pipeline to update image tags,
+// recommended labels and generator literals for images defined in the
+// kustomizations under the given directory, or in the given kustomization
+// file alone.
 func UpdateKustomization(
 	ctx context.Context,
 	u update.Updater[*container.ImageRef],
 	path string,
 ) kio.Pipeline {
+	reader := kio.LocalPackageReader{
+		PackagePath:    path,
+		MatchFilesGlob: KustomizationFiles,
+	}
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		name := filepath.Base(path)
+		path = filepath.Dir(path)
+		reader = kio.LocalPackageReader{
+			PackagePath:    path,
+			MatchFilesGlob: []string{name},
+			FileSkipFunc:   func(relPath string) bool { return relPath != name },
+		}
+	}
+	ctx = withFileEdits(policy.WithDir(ctx, path))
 	return kio.Pipeline{
-		Inputs: []kio.Reader{
-			kio.LocalPackageReader{
-				PackagePath:    path,
-				MatchFilesGlob: []string{"kustomization.yaml"},
-			},
-		},
+		Inputs: []kio.Reader{reader},
 		Filters: []kio.Filter{
-			UpdateKustomizationsImages(ctx, u),
+			UpdateKustomizationsImages(ctx, u, path),
 			UpdateKustomizationsLabels(ctx),
+			UpdateKustomizationsLiterals(ctx),
 		},
 		Outputs: []kio.Writer{
-			kio.LocalPackageWriter{PackagePath: path},
+			newPackageWriter(ctx, path),
 		},
 	}
 }
 
-// UpdateKustomizationsImages runs image tag updates across kustomization files.
+// UpdateKustomizationsImages runs image tag updates across kustomization files
+// read from root, bases before the overlays referencing them. Overlays pinning
+// the tag a base moved away from follow it, and bumped tags are propagated to
+// each package's patches and replacements.
 func UpdateKustomizationsImages(
 	ctx context.Context,
 	u update.Updater[*container.ImageRef],
+	root string,
 ) kio.Filter {
 	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
-		g := errgroup.Group{}
-		for _, node := range nodes {
-			g.Go(func() error {
-				if err := node.PipeE(UpdateKustomizationImages(ctx, u)); err != nil {
-					return err
-				}
-				return nil
-			})
-		}
-		if err := g.Wait(); err != nil {
+		tree, err := NewKustomizationTree(nodes)
+		if err != nil {
 			return nil, err
 		}
+		var mu sync.Mutex
+		bumps := make(map[string]map[string]TagBump, len(nodes))
+		for _, level := range tree.Levels {
+			g := errgroup.Group{}
+			for _, node := range level {
+				g.Go(func() error {
+					key, err := KustomizationKey(node)
+					if err != nil {
+						return err
+					}
+					inherited := make(map[string]TagBump)
+					mu.Lock()
+					for _, p := range tree.Parents[key] {
+						for name, b := range bumps[p] {
+							inherited[name] = b
+						}
+					}
+					mu.Unlock()
+
+					before, err := GetKustomizationImageTags(node)
+					if err != nil {
+						return err
+					}
+					if err := node.PipeE(UpdateKustomizationImages(withFile(ctx, node), u)); err != nil {
+						return err
+					}
+					if err := node.PipeE(InheritKustomizationTags(withFile(ctx, node), inherited)); err != nil {
+						return err
+					}
+					after, err := GetKustomizationImageTags(node)
+					if err != nil {
+						return err
+					}
+					dir, err := GetKustomizationDir(root, node)
+					if err != nil {
+						return err
+					}
+					own := make(map[string]TagBump)
+					for name, b := range inherited {
+						if _, ok := before[name]; !ok {
+							own[name] = b
+						}
+					}
+					for name, from := range before {
+						if to := after[name]; to != from {
+							own[name] = TagBump{From: from, To: to}
+						}
+						if err := node.PipeE(PropagateKustomizationTag(ctx, dir, name, from, after[name])); err != nil {
+							return fmt.Errorf("propagate tag for %s: %w", name, err)
+						}
+					}
+					mu.Lock()
+					bumps[key] = own
+					mu.Unlock()
+					return nil
+				})
+			}
+			if err := g.Wait(); err != nil {
+				return nil, err
+			}
+		}
 		return nodes, nil
 	})
 }
@@ -88,7 +167,7 @@ func UpdateKustomizationImages(
 		for _, img := range imageNodes {
 			nameNode, err := img.Pipe(yaml.Get("name"))
 			if err != nil {
-				slog.WarnContext(ctx, "missing name in images entry", "err", err)
+				slog.WarnContext(ctx, "missing name in images entry", logging.Failed.Attr(), "err", err)
 				continue
 			}
 			name := yaml.GetValue(nameNode)
@@ -101,11 +180,6 @@ func UpdateKustomizationImages(
 				return nil, fmt.Errorf("get newName for %s: %w", name, err)
 			}
 
-			options := []update.Option{}
-			if cfg.Transform != nil {
-				options = append(options, update.WithTransform(cfg.Transform))
-			}
-
 			imageRef := container.ImageRef{Name: yaml.GetValue(newNameNode)}
 
 			newTagNode, err := img.Pipe(yaml.Get("newTag"))
@@ -118,16 +192,41 @@ func UpdateKustomizationImages(
 			} else {
 				imageRef.Tag = "latest"
 			}
+			pos := img
+			if newTagNode != nil {
+				pos = newTagNode
+			}
+			ctx := atNode(ctx, pos.YNode())
 
+			if cfg.KeepFloatingTag {
+				if err := updateKustomizationImageDigest(ctx, u, img, imageRef); err != nil {
+					return nil, err
+				}
+				continue
+			}
 			excludes := map[string]struct{}{}
 			for _, e := range cfg.Excludes {
 				excludes[e] = struct{}{}
 			}
-			latest, err := u.Update(ctx, &imageRef, options...)
+			latest, err := u.Update(ctx, &imageRef, cfg.UpdateOptions(imageRef)...)
+			if errors.Is(err, update.ErrInvalidBaseline) {
+				switch cfg.InvalidTag {
+				case SkipInvalidTag:
+					slog.InfoContext(ctx, "unversioned image skipped", logging.Unversioned.Attr(),
+						"name", name, "image", imageRef.Name, "version", imageRef.Tag, "path", policy.File(ctx))
+					continue
+				case LatestInvalidTag:
+					latestRef := container.ImageRef{Name: imageRef.Name, Tag: "latest"}
+					latest, err = u.Update(ctx, &latestRef, cfg.UpdateOptions(latestRef)...)
+					if latest == latestRef.Tag {
+						latest = ""
+					}
+				}
+			}
 			if err != nil {
 				return nil, fmt.Errorf("find latest tag: %w", err)
 			}
-			if latest == "" {
+			if latest == "" || latest == newTag {
 				continue
 			}
 			if _, excluded := excludes[latest]; excluded {
@@ -143,7 +242,9 @@ func UpdateKustomizationImages(
 				name,
 				"image",
 				imageRef.String(),
-				"tag",
+				"from",
+				newTag,
+				"to",
 				latest,
 			)
 		}
@@ -151,6 +252,42 @@ func UpdateKustomizationImages(
 	})
 }
 
+// ImageDigester resolves the digest an image tag points to. Image updaters
+// implementing it record the digests of floating tags.
+type ImageDigester interface {
+	Digest(ctx context.Context, imageRef *container.ImageRef) (string, error)
+}
+
+// updateKustomizationImageDigest sets the digest of an images entry to the
+// one its tag points to, leaving the tag alone so a moved floating tag still
+// shows in git. Nothing is done when the updater resolves no digest.
+func updateKustomizationImageDigest(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+	img *yaml.RNode,
+	imageRef container.ImageRef,
+) error {
+	d, ok := u.(ImageDigester)
+	if !ok {
+		slog.DebugContext(ctx, "skip digest of floating tag", "image", imageRef.String())
+		return nil
+	}
+	digest, err := d.Digest(ctx, &imageRef)
+	if err != nil {
+		return fmt.Errorf("get digest of %s: %w", imageRef.String(), err)
+	}
+	current, _ := img.GetString("digest")
+	if digest == "" || digest == current {
+		return nil
+	}
+	if err := img.PipeE(yaml.SetField("digest", yaml.NewStringRNode(digest))); err != nil {
+		return fmt.Errorf("set digest for %s: %w", imageRef.Name, err)
+	}
+	slog.InfoContext(ctx, "updated image digest", "image", imageRef.String(), "from", current, "to", digest)
+	policy.Record(ctx, policy.Proposal{Source: "image", Name: imageRef.Name, From: current, To: digest})
+	return nil
+}
+
 // UpdateKustomizationsLabels sets recommended labels across kustomization files.
 func UpdateKustomizationsLabels(ctx context.Context) kio.Filter {
 	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
@@ -210,7 +347,7 @@ func UpdateKustomizationLabelsNode(ctx context.Context) yaml.Filter {
 			for _, img := range imageNodes {
 				nameNode, err := img.Pipe(yaml.Get("name"))
 				if err != nil {
-					slog.WarnContext(ctx, "missing name in images entry", "err", err)
+					slog.WarnContext(ctx, "missing name in images entry", logging.Failed.Attr(), "err", err)
 					continue
 				}
 				name := yaml.GetValue(nameNode)
@@ -312,19 +449,74 @@ func SetKustomizationImage(name, newName, newTag string) KustomizationImagesEntr
 	return KustomizationImagesEntrySetter{Name: name, NewName: newName, NewTag: newTag}
 }
 
+// InvalidTagPolicy is how an image whose tag is not a version is updated.
+type InvalidTagPolicy int
+
+// InvalidTagPolicy values.
+const (
+	// FailInvalidTag fails the update of the file.
+	FailInvalidTag InvalidTagPolicy = iota
+	// SkipInvalidTag leaves the image alone, reporting it as unversioned.
+	SkipInvalidTag
+	// LatestInvalidTag updates the image to its newest release, as an image
+	// on latest.
+	LatestInvalidTag
+)
+
 // KustomizationImagesConfig describes image update behavior from annotation.
 type KustomizationImagesConfig struct {
-	Name      string
-	Transform *regexp.Regexp
-	Excludes  []string
+	Name         string
+	Transform    *regexp.Regexp
+	Excludes     []string
+	CalVerPolicy update.CalVerPolicy
+	Ordering     update.Ordering
+	Strategy     update.Strategy
+	// Prereleases allows updates to prerelease tags, and from them to
+	// releases.
+	Prereleases bool
+	// KeepFloatingTag keeps the tag, e.g. latest or stable, and records the
+	// digest it points to instead.
+	KeepFloatingTag bool
+	// InvalidTag handles tags that are not versions, e.g. main.
+	InvalidTag InvalidTagPolicy
+	// VerifyPlatforms skips tags missing a platform of the current tag, or
+	// of Platforms when set.
+	VerifyPlatforms bool
+	Platforms       []string
+}
+
+// UpdateOptions returns the comparison options the config selects tags of
+// the given image with.
+func (c KustomizationImagesConfig) UpdateOptions(imageRef container.ImageRef) []update.Option {
+	options := []update.Option{
+		update.WithCalVerPolicy(c.CalVerPolicy),
+		update.WithOrdering(c.Ordering),
+		update.WithStrategy(c.Strategy),
+		update.WithPrereleases(c.Prereleases),
+	}
+	if c.Transform != nil {
+		options = append(options, update.WithTransform(c.Transform))
+	}
+	if c.VerifyPlatforms || len(c.Platforms) > 0 {
+		options = append(options, container.WithPlatforms(imageRef, c.Platforms...))
+	}
+	return options
 }
 
 // UnmarshalJSON parses the JSON representation of KustomizationImagesConfig.
 func (c *KustomizationImagesConfig) UnmarshalJSON(data []byte) error {
 	var raw struct {
-		Name        string   `json:"name"`
-		TagRegex    string   `json:"tag-regex"`
-		ExcludeTags []string `json:"exclude-tags"`
+		Name         string   `json:"name"`
+		TagRegex     string   `json:"tag-regex"`
+		ExcludeTags  []string `json:"exclude-tags"`
+		CalVerPolicy string   `json:"calver-policy"`
+		Ordering     string   `json:"ordering"`
+		Strategy     string   `json:"update-strategy"`
+		Prereleases  bool     `json:"include-prereleases"`
+		KeepFloating bool     `json:"keep-floating-tag"`
+		InvalidTag   string   `json:"invalid-tag"`
+		Verify       bool     `json:"verify-platforms"`
+		Platforms    []string `json:"platforms"`
 	}
 	if err := json.Unmarshal(data, &raw); err != nil {
 		return err
@@ -344,14 +536,54 @@ func (c *KustomizationImagesConfig) UnmarshalJSON(data []byte) error {
 		c.Excludes = raw.ExcludeTags
 	}
 
+	switch raw.CalVerPolicy {
+	case "", "any":
+		c.CalVerPolicy = update.AnyCalVer
+	case "same-year":
+		c.CalVerPolicy = update.SameYearCalVer
+	case "same-month":
+		c.CalVerPolicy = update.SameMonthCalVer
+	default:
+		return fmt.Errorf("invalid calver-policy %q", raw.CalVerPolicy)
+	}
+
+	ordering, err := update.ParseOrdering(raw.Ordering)
+	if err != nil {
+		return err
+	}
+	c.Ordering = ordering
+	strategy, err := update.ParseStrategy(raw.Strategy)
+	if err != nil {
+		return err
+	}
+	c.Strategy = strategy
+	c.Prereleases = raw.Prereleases
+	c.KeepFloatingTag = raw.KeepFloating
+	switch raw.InvalidTag {
+	case "", "fail":
+		c.InvalidTag = FailInvalidTag
+	case "skip":
+		c.InvalidTag = SkipInvalidTag
+	case "latest":
+		c.InvalidTag = LatestInvalidTag
+	default:
+		return fmt.Errorf("invalid invalid-tag %q", raw.InvalidTag)
+	}
+	c.VerifyPlatforms = raw.Verify
+	c.Platforms = raw.Platforms
+
 	return nil
 }
 
-// GetKustomizationImagesConfig reads image config from the annotation node.
+// GetKustomizationImagesConfig reads image config from the annotation node,
+// validated against its schema.
 func GetKustomizationImagesConfig(node *yaml.RNode) (map[string]KustomizationImagesConfig, error) {
 	if yaml.IsMissingOrNull(node) {
 		return nil, nil
 	}
+	if err := validateAnnotation(ImagesAnnotation, node); err != nil {
+		return nil, err
+	}
 	var imageConfigs []KustomizationImagesConfig
 	if err := json.Unmarshal([]byte(node.YNode().Value), &imageConfigs); err != nil {
 		return nil, fmt.Errorf("unmarshal ImageConfig from annotation: %w", err)
@@ -2,6 +2,9 @@ package kio
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"sigs.k8s.io/kustomize/kyaml/yaml"
@@ -61,6 +64,119 @@ images:
 	}
 }
 
+// selectingImageUpdater selects the latest of its tags with the options it
+// is given.
+type selectingImageUpdater struct {
+	tags []string
+}
+
+func (f selectingImageUpdater) Update(
+	ctx context.Context,
+	ref *container.ImageRef,
+	opts ...update.Option,
+) (string, error) {
+	return update.SelectLatest(ctx, ref.Tag, f.tags, update.WithCompareOptions(opts...))
+}
+
+func TestUpdateKustomizationImages_IncludePrereleases(t *testing.T) {
+	u := selectingImageUpdater{tags: []string{"1.2.0", "1.3.0-rc.1", "1.3.0-rc.2"}}
+	for annotation, want := range map[string]string{
+		`[{"name":"app"}]`: "1.2.0",
+		`[{"name":"app","include-prereleases":true}]`: "1.3.0-rc.2",
+	} {
+		rn := yaml.MustParse(`metadata:
+  annotations:
+    automata.shikanime.studio/images: '` + annotation + `'
+images:
+- name: app
+  newName: repo/app
+  newTag: 1.1.0`)
+		if _, err := UpdateKustomizationImages(context.Background(), u).Filter(rn); err != nil {
+			t.Fatalf("%s: unexpected error: %v", annotation, err)
+		}
+		newTag, err := rn.Pipe(yaml.Lookup("images", "0", "newTag"))
+		if err != nil {
+			t.Fatalf("%s: lookup newTag: %v", annotation, err)
+		}
+		if got := yaml.GetValue(newTag); got != want {
+			t.Errorf("%s: got newTag %s, want %s", annotation, got, want)
+		}
+	}
+}
+
+func TestUpdateKustomizationImages_InvalidTag(t *testing.T) {
+	u := selectingImageUpdater{tags: []string{"1.2.0", "1.3.0-rc.1", "main"}}
+	cases := []struct {
+		annotation string
+		want       string
+		wantErr    bool
+	}{
+		{`[{"name":"app"}]`, "", true},
+		{`[{"name":"app","invalid-tag":"skip"}]`, "main", false},
+		{`[{"name":"app","invalid-tag":"latest"}]`, "1.2.0", false},
+	}
+	for _, c := range cases {
+		rn := yaml.MustParse(`metadata:
+  annotations:
+    automata.shikanime.studio/images: '` + c.annotation + `'
+images:
+- name: app
+  newName: repo/app
+  newTag: main`)
+		_, err := UpdateKustomizationImages(context.Background(), u).Filter(rn)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", c.annotation)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.annotation, err)
+		}
+		newTag, err := rn.Pipe(yaml.Lookup("images", "0", "newTag"))
+		if err != nil {
+			t.Fatalf("%s: lookup newTag: %v", c.annotation, err)
+		}
+		if got := yaml.GetValue(newTag); got != c.want {
+			t.Errorf("%s: got newTag %s, want %s", c.annotation, got, c.want)
+		}
+	}
+}
+
+// digestImageUpdater resolves every tag to its digest.
+type digestImageUpdater struct {
+	fakeImageUpdater
+	digest string
+}
+
+func (f digestImageUpdater) Digest(_ context.Context, _ *container.ImageRef) (string, error) {
+	return f.digest, nil
+}
+
+func TestUpdateKustomizationImages_KeepFloatingTag(t *testing.T) {
+	rn := yaml.MustParse(`metadata:
+  annotations:
+    automata.shikanime.studio/images: '[{"name":"app","keep-floating-tag":true}]'
+images:
+- name: app
+  newName: repo/app
+  newTag: stable
+  digest: sha256:old`)
+	u := digestImageUpdater{fakeImageUpdater: fakeImageUpdater{latest: "v2"}, digest: "sha256:new"}
+	if _, err := UpdateKustomizationImages(context.Background(), u).Filter(rn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for field, want := range map[string]string{"newTag": "stable", "digest": "sha256:new"} {
+		node, err := rn.Pipe(yaml.Lookup("images", "0", field))
+		if err != nil {
+			t.Fatalf("lookup %s: %v", field, err)
+		}
+		if got := yaml.GetValue(node); got != want {
+			t.Errorf("got %s %s, want %s", field, got, want)
+		}
+	}
+}
+
 func TestUpdateKustomizationLabelsNode_CanonicalTransform(t *testing.T) {
 	doc := `metadata:
   annotations:
@@ -188,3 +304,94 @@ func TestGetKustomizationImagesConfig_InvalidRegex(t *testing.T) {
 		t.Fatalf("expected error for invalid regex")
 	}
 }
+
+func TestUpdateKustomizationLiteralsNode(t *testing.T) {
+	doc := `metadata:
+  annotations:
+    automata.shikanime.studio/literals: '[{"image":"app","generator":"app-config","key":"APP_VERSION"}]'
+images:
+- name: app
+  newName: repo/app
+  newTag: 1.3.0
+configMapGenerator:
+- name: app-config
+  literals:
+  - APP_VERSION=1.2.3
+  - OTHER=1.2.3
+- name: other-config
+  literals:
+  - APP_VERSION=1.2.3`
+	rn := yaml.MustParse(doc)
+	_, err := UpdateKustomizationLiteralsNode(context.Background()).Filter(rn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	gens, err := rn.Pipe(yaml.Lookup("configMapGenerator"))
+	if err != nil {
+		t.Fatalf("lookup configMapGenerator: %v", err)
+	}
+	elems, err := gens.Elements()
+	if err != nil {
+		t.Fatalf("elements: %v", err)
+	}
+	want := [][]string{{"APP_VERSION=1.3.0", "OTHER=1.2.3"}, {"APP_VERSION=1.2.3"}}
+	for i, gen := range elems {
+		lits, err := gen.Pipe(yaml.Lookup("literals"))
+		if err != nil {
+			t.Fatalf("lookup literals: %v", err)
+		}
+		litElems, err := lits.Elements()
+		if err != nil {
+			t.Fatalf("literal elements: %v", err)
+		}
+		got := make([]string, 0, len(litElems))
+		for _, lit := range litElems {
+			got = append(got, yaml.GetValue(lit))
+		}
+		if strings.Join(got, ",") != strings.Join(want[i], ",") {
+			t.Fatalf("unexpected literals for generator %d: %v", i, got)
+		}
+	}
+}
+
+func TestUpdateKustomization_SingleFile(t *testing.T) {
+	dir := t.TempDir()
+	doc := `metadata:
+  annotations:
+    automata.shikanime.studio/images: '[{"name":"app"}]'
+images:
+- name: app
+  newName: repo/app
+  newTag: 1.0.0
+`
+	target := filepath.Join(dir, "kustomization.yml")
+	other := filepath.Join(dir, "overlay", "Kustomization")
+	if err := os.MkdirAll(filepath.Dir(other), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, p := range []string{target, other} {
+		if err := os.WriteFile(p, []byte(doc), 0o644); err != nil {
+			t.Fatalf("write %s: %v", p, err)
+		}
+	}
+
+	err := UpdateKustomization(context.Background(), fakeImageUpdater{latest: "1.1.0"}, target).
+		Execute()
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if data, _ := os.ReadFile(target); !strings.Contains(string(data), "newTag: 1.1.0") {
+		t.Fatalf("expected target to be updated, got:\n%s", data)
+	}
+	if data, _ := os.ReadFile(other); !strings.Contains(string(data), "newTag: 1.0.0") {
+		t.Fatalf("expected overlay to be left alone, got:\n%s", data)
+	}
+
+	err = UpdateKustomization(context.Background(), fakeImageUpdater{latest: "1.1.0"}, dir).Execute()
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if data, _ := os.ReadFile(other); !strings.Contains(string(data), "newTag: 1.1.0") {
+		t.Fatalf("expected Kustomization to be discovered, got:\n%s", data)
+	}
+}
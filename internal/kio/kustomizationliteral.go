@@ -0,0 +1,149 @@
+package kio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// LiteralsAnnotation lists generator literals that mirror an image tag.
+const LiteralsAnnotation = "automata.shikanime.studio/literals"
+
+// GetLiteralsAnnotation retrieves the literals config annotation.
+func GetLiteralsAnnotation() yaml.Filter {
+	return yaml.GetAnnotation(LiteralsAnnotation)
+}
+
+// KustomizationLiteralConfig binds a generator literal key to an image tag.
+type KustomizationLiteralConfig struct {
+	// Image is the name of the images entry whose newTag is mirrored.
+	Image string `json:"image"`
+	// Generator restricts the rewrite to the generator with this name.
+	Generator string `json:"generator,omitempty"`
+	// Key is the literal key to rewrite, e.g. APP_VERSION.
+	Key string `json:"key"`
+}
+
+// GetKustomizationLiteralsConfig reads literal configs from the annotation
+// node, validated against its schema.
+func GetKustomizationLiteralsConfig(node *yaml.RNode) ([]KustomizationLiteralConfig, error) {
+	if yaml.IsMissingOrNull(node) {
+		return nil, nil
+	}
+	if err := validateAnnotation(LiteralsAnnotation, node); err != nil {
+		return nil, err
+	}
+	var cfgs []KustomizationLiteralConfig
+	if err := json.Unmarshal([]byte(node.YNode().Value), &cfgs); err != nil {
+		return nil, fmt.Errorf("unmarshal LiteralConfig from annotation: %w", err)
+	}
+	for _, c := range cfgs {
+		if c.Image == "" || c.Key == "" {
+			return nil, fmt.Errorf("literal config requires image and key: %+v", c)
+		}
+	}
+	return cfgs, nil
+}
+
+// UpdateKustomizationsLiterals rewrites generator literals across kustomization
+// files.
+func UpdateKustomizationsLiterals(ctx context.Context) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		g := errgroup.Group{}
+		for _, node := range nodes {
+			g.Go(func() error {
+				if err := node.PipeE(UpdateKustomizationLiteralsNode(ctx)); err != nil {
+					return err
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+		return nodes, nil
+	})
+}
+
+// UpdateKustomizationLiteralsNode sets annotated configMapGenerator and
+// secretGenerator literals to the current newTag of their image.
+func UpdateKustomizationLiteralsNode(ctx context.Context) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		literalsAnnotationNode, err := node.Pipe(GetLiteralsAnnotation())
+		if err != nil {
+			return nil, fmt.Errorf("get literals annotation: %w", err)
+		}
+		cfgs, err := GetKustomizationLiteralsConfig(literalsAnnotationNode)
+		if err != nil {
+			return nil, fmt.Errorf("get literals config: %w", err)
+		}
+		if len(cfgs) == 0 {
+			return node, nil
+		}
+		tags, err := GetKustomizationImageTags(node)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, field := range []string{"configMapGenerator", "secretGenerator"} {
+			genNode, err := node.Pipe(yaml.Lookup(field))
+			if err != nil {
+				return nil, fmt.Errorf("lookup %s: %w", field, err)
+			}
+			gens, err := genNode.Elements()
+			if err != nil {
+				return nil, fmt.Errorf("get %s elements: %w", field, err)
+			}
+			for _, gen := range gens {
+				genNameNode, err := gen.Pipe(yaml.Get("name"))
+				if err != nil {
+					return nil, fmt.Errorf("get %s name: %w", field, err)
+				}
+				genName := yaml.GetValue(genNameNode)
+				literalsNode, err := gen.Pipe(yaml.Lookup("literals"))
+				if err != nil {
+					return nil, fmt.Errorf("lookup %s literals: %w", genName, err)
+				}
+				literals, err := literalsNode.Elements()
+				if err != nil {
+					return nil, fmt.Errorf("get %s literals: %w", genName, err)
+				}
+				for _, cfg := range cfgs {
+					if cfg.Generator != "" && cfg.Generator != genName {
+						continue
+					}
+					tag := tags[cfg.Image]
+					if tag == "" {
+						continue
+					}
+					for _, lit := range literals {
+						key, value, ok := strings.Cut(yaml.GetValue(lit), "=")
+						if !ok || key != cfg.Key || value == tag {
+							continue
+						}
+						lit.YNode().Value = key + "=" + tag
+						slog.InfoContext(
+							ctx,
+							"updated generator literal",
+							"generator",
+							genName,
+							"key",
+							key,
+							"from",
+							value,
+							"to",
+							tag,
+						)
+					}
+				}
+			}
+		}
+		return node, nil
+	})
+}
@@ -0,0 +1,183 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+)
+
+// GetKustomizationImageTags returns the newTag of every images entry keyed by
+// image name.
+func GetKustomizationImageTags(node *yaml.RNode) (map[string]string, error) {
+	imagesNode, err := node.Pipe(yaml.Lookup("images"))
+	if err != nil {
+		return nil, fmt.Errorf("lookup images: %w", err)
+	}
+	imageNodes, err := imagesNode.Elements()
+	if err != nil {
+		return nil, fmt.Errorf("get images elements: %w", err)
+	}
+	tags := make(map[string]string, len(imageNodes))
+	for _, img := range imageNodes {
+		nameNode, err := img.Pipe(yaml.Get("name"))
+		if err != nil {
+			continue
+		}
+		newTagNode, err := img.Pipe(yaml.Get("newTag"))
+		if err != nil {
+			return nil, fmt.Errorf("get newTag: %w", err)
+		}
+		tags[yaml.GetValue(nameNode)] = yaml.GetValue(newTagNode)
+	}
+	return tags, nil
+}
+
+// GetKustomizationDir returns the directory of the kustomization file the node
+// was read from, resolved against the package root.
+func GetKustomizationDir(root string, node *yaml.RNode) (string, error) {
+	p, _, err := kioutil.GetFileAnnotations(node)
+	if err != nil {
+		return "", fmt.Errorf("get file annotations: %w", err)
+	}
+	return filepath.Join(root, filepath.Dir(p)), nil
+}
+
+// PropagateKustomizationTag rewrites the old tag of the named image into the
+// new one in the patches and replacements of a kustomization, both inline and
+// in the files they reference relative to dir. Referenced files are queued
+// for the package writer of the pipeline, which validates them, reverts them
+// with the kustomization and records them as updates of the image into the
+// Applied of the context.
+func PropagateKustomizationTag(ctx context.Context, dir, name, from, to string) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		if from == "" || from == to {
+			return node, nil
+		}
+		p := policy.Proposal{Source: "image", Name: name, From: from, To: to}
+		// patchesStrategicMerge entries are either file paths or inline documents.
+		psmNode, err := node.Pipe(yaml.Lookup("patchesStrategicMerge"))
+		if err != nil {
+			return nil, fmt.Errorf("lookup patchesStrategicMerge: %w", err)
+		}
+		psmElems, err := psmNode.Elements()
+		if err != nil {
+			return nil, fmt.Errorf("get patchesStrategicMerge elements: %w", err)
+		}
+		for _, e := range psmElems {
+			v := yaml.GetValue(e)
+			if v == "" {
+				continue
+			}
+			if isInlinePatch(v) {
+				e.YNode().Value = ReplaceVersion(v, from, to)
+				continue
+			}
+			if err := propagateTagToFile(ctx, filepath.Join(dir, v), dir, p); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, field := range []string{"patches", "patchesJson6902", "replacements"} {
+			listNode, err := node.Pipe(yaml.Lookup(field))
+			if err != nil {
+				return nil, fmt.Errorf("lookup %s: %w", field, err)
+			}
+			elems, err := listNode.Elements()
+			if err != nil {
+				return nil, fmt.Errorf("get %s elements: %w", field, err)
+			}
+			for _, e := range elems {
+				patchNode, err := e.Pipe(yaml.Get("patch"))
+				if err != nil {
+					return nil, fmt.Errorf("get %s patch: %w", field, err)
+				}
+				if patchNode != nil {
+					patchNode.YNode().Value = ReplaceVersion(yaml.GetValue(patchNode), from, to)
+				}
+				pathNode, err := e.Pipe(yaml.Get("path"))
+				if err != nil {
+					return nil, fmt.Errorf("get %s path: %w", field, err)
+				}
+				if path := yaml.GetValue(pathNode); path != "" {
+					if err := propagateTagToFile(ctx, filepath.Join(dir, path), dir, p); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		return node, nil
+	})
+}
+
+// ReplaceVersion replaces every standalone occurrence of the from version in s
+// with to. Occurrences embedded in a longer version-like token (e.g. "v1.2.3"
+// or "1.2.30" when from is "1.2.3") are left untouched.
+func ReplaceVersion(s, from, to string) string {
+	if from == "" || from == to {
+		return s
+	}
+	var b strings.Builder
+	rest := s
+	for {
+		i := strings.Index(rest, from)
+		if i < 0 {
+			b.WriteString(rest)
+			return b.String()
+		}
+		j := i + len(from)
+		if isVersionBoundary(rest[:i], true) && isVersionBoundary(rest[j:], false) {
+			b.WriteString(rest[:i])
+			b.WriteString(to)
+		} else {
+			b.WriteString(rest[:j])
+		}
+		rest = rest[j:]
+	}
+}
+
+// isVersionBoundary reports whether the rune adjacent to a match in s does not
+// extend the version token.
+func isVersionBoundary(s string, before bool) bool {
+	if s == "" {
+		return true
+	}
+	var r rune
+	if before {
+		r, _ = utf8.DecodeLastRuneInString(s)
+	} else {
+		r, _ = utf8.DecodeRuneInString(s)
+	}
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("_.+-", r)
+}
+
+func isInlinePatch(v string) bool {
+	return strings.ContainsAny(v, "\n:")
+}
+
+// propagateTagToFile queues the rewrite of the old tag of the proposal into
+// the new one in the file at path, referenced by the kustomization in dir,
+// for the package writer.
+func propagateTagToFile(ctx context.Context, path, dir string, p policy.Proposal) error {
+	found, err := editFile(ctx, path, dir, p, func(data []byte) []byte {
+		return []byte(ReplaceVersion(string(data), p.From, p.To))
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		slog.WarnContext(ctx, "referenced patch file not found", logging.Failed.Attr(), "path", path)
+		return nil
+	}
+	slog.InfoContext(ctx, "propagated image tag", "path", path, "from", p.From, "to", p.To)
+	return nil
+}
@@ -0,0 +1,88 @@
+package kio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/policy"
+)
+
+func TestReplaceVersion(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"APP_VERSION: 1.2.3", "APP_VERSION: 1.3.0"},
+		{"image: repo/app:1.2.3", "image: repo/app:1.3.0"},
+		{"v1.2.3", "v1.2.3"},
+		{"1.2.30", "1.2.30"},
+		{"1.2.3-rc1", "1.2.3-rc1"},
+	}
+	for _, c := range cases {
+		if got := ReplaceVersion(c.in, "1.2.3", "1.3.0"); got != c.want {
+			t.Fatalf("ReplaceVersion(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestPropagateKustomizationTag(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(
+		filepath.Join(dir, "configmap.yaml"),
+		[]byte("data:\n  APP_VERSION: 1.2.3\n"),
+		0o644,
+	); err != nil {
+		t.Fatalf("write patch: %v", err)
+	}
+	doc := `patchesStrategicMerge:
+- configmap.yaml
+patches:
+- patch: |-
+    - op: replace
+      path: /data/APP_VERSION
+      value: 1.2.3`
+	rn := yaml.MustParse(doc)
+	applied := policy.NewApplied()
+	ctx := withFileEdits(policy.WithApplied(context.Background(), applied))
+	_, err := PropagateKustomizationTag(ctx, dir, "app", "1.2.3", "1.3.0").Filter(rn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "configmap.yaml"))
+	if err != nil {
+		t.Fatalf("read patch: %v", err)
+	}
+	if strings.Contains(string(data), "1.3.0") {
+		t.Fatalf("patch file written before the package writer: %s", data)
+	}
+	if err := newPackageWriter(ctx, dir).Write(nil); err != nil {
+		t.Fatalf("write package: %v", err)
+	}
+	patchNode, err := rn.Pipe(yaml.Lookup("patches"), yaml.ElementIndexer{Index: 0}, yaml.Get("patch"))
+	if err != nil {
+		t.Fatalf("get patch: %v", err)
+	}
+	if !strings.Contains(yaml.GetValue(patchNode), "value: 1.3.0") {
+		t.Fatalf("unexpected inline patch: %s", yaml.GetValue(patchNode))
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "configmap.yaml"))
+	if err != nil {
+		t.Fatalf("read patch: %v", err)
+	}
+	if !strings.Contains(string(data), "APP_VERSION: 1.3.0") {
+		t.Fatalf("unexpected patch file: %s", data)
+	}
+	if got := applied.Files(); !slices.Equal(got, []string{filepath.Join(dir, "configmap.yaml")}) {
+		t.Fatalf("unexpected applied files: %v", got)
+	}
+	want := []policy.Proposal{{Source: "image", Name: "app", From: "1.2.3", To: "1.3.0"}}
+	if got := applied.Proposals(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected applied proposals: %+v", got)
+	}
+}
@@ -0,0 +1,159 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+)
+
+// KustomizationTree orders kustomizations so that the bases they reference
+// through resources, bases or components come first.
+type KustomizationTree struct {
+	// Levels groups kustomizations whose bases all belong to earlier levels,
+	// each level sorted by path.
+	Levels [][]*yaml.RNode
+	// Parents maps the directory of each kustomization to the directories of
+	// the kustomizations it references.
+	Parents map[string][]string
+}
+
+// KustomizationKey returns the directory of the kustomization relative to the
+// package root, identifying it in a KustomizationTree.
+func KustomizationKey(node *yaml.RNode) (string, error) {
+	p, _, err := kioutil.GetFileAnnotations(node)
+	if err != nil {
+		return "", fmt.Errorf("get file annotations: %w", err)
+	}
+	return filepath.Dir(p), nil
+}
+
+// NewKustomizationTree builds the tree of the given kustomizations. Members of
+// a reference cycle are placed in a last level.
+func NewKustomizationTree(nodes []*yaml.RNode) (*KustomizationTree, error) {
+	byKey := make(map[string]*yaml.RNode, len(nodes))
+	keys := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		key, err := KustomizationKey(node)
+		if err != nil {
+			return nil, err
+		}
+		byKey[key] = node
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	t := &KustomizationTree{Parents: make(map[string][]string, len(keys))}
+	for _, key := range keys {
+		for _, field := range []string{"resources", "bases", "components"} {
+			refs, err := byKey[key].Pipe(yaml.Lookup(field))
+			if err != nil {
+				return nil, fmt.Errorf("lookup %s: %w", field, err)
+			}
+			elems, err := refs.Elements()
+			if err != nil {
+				return nil, fmt.Errorf("get %s elements: %w", field, err)
+			}
+			for _, e := range elems {
+				ref := filepath.Clean(filepath.Join(key, yaml.GetValue(e)))
+				if _, ok := byKey[ref]; ok && ref != key {
+					t.Parents[key] = append(t.Parents[key], ref)
+				}
+			}
+		}
+	}
+
+	done := make(map[string]bool, len(keys))
+	for len(done) < len(keys) {
+		var level []string
+		for _, key := range keys {
+			if done[key] {
+				continue
+			}
+			ready := true
+			for _, p := range t.Parents[key] {
+				if !done[p] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				level = append(level, key)
+			}
+		}
+		if len(level) == 0 {
+			for _, key := range keys {
+				if !done[key] {
+					level = append(level, key)
+				}
+			}
+			slog.Warn("kustomization reference cycle", logging.Failed.Attr(), "kustomizations", level)
+		}
+		nodes := make([]*yaml.RNode, 0, len(level))
+		for _, key := range level {
+			done[key] = true
+			nodes = append(nodes, byKey[key])
+		}
+		t.Levels = append(t.Levels, nodes)
+	}
+	return t, nil
+}
+
+// TagBump records an image tag changed in a kustomization.
+type TagBump struct {
+	From string
+	To   string
+}
+
+// InheritKustomizationTags bumps the images a kustomization overrides with the
+// tag its bases just moved away from, so overlays pinning the base tag follow
+// it. The bumps go through the policies like any other update, and are
+// recorded. Images configured in the kustomization's own images annotation
+// are resolved on their own and left alone.
+func InheritKustomizationTags(ctx context.Context, bumps map[string]TagBump) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		if len(bumps) == 0 {
+			return node, nil
+		}
+		annotation, err := node.Pipe(GetImagesAnnotation())
+		if err != nil {
+			return nil, fmt.Errorf("get images annotation: %w", err)
+		}
+		configs, err := GetKustomizationImagesConfig(annotation)
+		if err != nil {
+			return nil, fmt.Errorf("get image config: %w", err)
+		}
+		tags, err := GetKustomizationImageTags(node)
+		if err != nil {
+			return nil, err
+		}
+		for name, tag := range tags {
+			if _, ok := configs[name]; ok {
+				continue
+			}
+			bump, ok := bumps[name]
+			if !ok || tag == "" || tag != bump.From {
+				continue
+			}
+			to, err := policy.Apply(ctx, policy.Proposal{Source: "image", Name: name, From: tag, To: bump.To})
+			if err != nil {
+				return nil, err
+			}
+			if to == "" || to == tag {
+				continue
+			}
+			if err := node.PipeE(SetKustomizationImage(name, "", to)); err != nil {
+				return nil, fmt.Errorf("set newTag for %s: %w", name, err)
+			}
+			slog.InfoContext(ctx, "updated image tag", "name", name, "from", tag, "to", to)
+		}
+		return node, nil
+	})
+}
@@ -0,0 +1,132 @@
+package kio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/policy"
+)
+
+func TestNewKustomizationTree_OrdersBasesFirst(t *testing.T) {
+	var nodes []*yaml.RNode
+	for path, doc := range map[string]string{
+		"overlays/prod/kustomization.yaml": "resources:\n- ../../base\n- ../../components/db\n",
+		"components/db/kustomization.yaml": "resources:\n- ../../base\n",
+		"base/kustomization.yaml":          "resources:\n- deployment.yaml\n",
+		"overlays/dev/kustomization.yaml":  "resources:\n- ../../base\n",
+	} {
+		node := yaml.MustParse(doc)
+		if err := node.PipeE(yaml.SetAnnotation(kioutil.PathAnnotation, path)); err != nil {
+			t.Fatalf("set path: %v", err)
+		}
+		nodes = append(nodes, node)
+	}
+	tree, err := NewKustomizationTree(nodes)
+	if err != nil {
+		t.Fatalf("new tree: %v", err)
+	}
+	var got []string
+	for _, level := range tree.Levels {
+		var keys []string
+		for _, node := range level {
+			key, _ := KustomizationKey(node)
+			keys = append(keys, key)
+		}
+		got = append(got, strings.Join(keys, ","))
+	}
+	want := []string{"base", "components/db,overlays/dev", "overlays/prod"}
+	if strings.Join(got, ";") != strings.Join(want, ";") {
+		t.Fatalf("unexpected levels %v, want %v", got, want)
+	}
+}
+
+func TestUpdateKustomization_OverlaysFollowBases(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"base/kustomization.yaml": `metadata:
+  annotations:
+    automata.shikanime.studio/images: '[{"name":"app"}]'
+images:
+- name: app
+  newName: repo/app
+  newTag: 1.0.0
+`,
+		"overlays/prod/kustomization.yaml": `resources:
+- ../../base
+images:
+- name: app
+  newTag: 1.0.0
+`,
+		"overlays/pinned/kustomization.yaml": `resources:
+- ../../base
+images:
+- name: app
+  newTag: 0.9.0
+`,
+	}
+	for p, doc := range files {
+		p = filepath.Join(dir, p)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(doc), 0o644); err != nil {
+			t.Fatalf("write %s: %v", p, err)
+		}
+	}
+	err := UpdateKustomization(context.Background(), fakeImageUpdater{latest: "1.1.0"}, dir).Execute()
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	for p, want := range map[string]string{
+		"base/kustomization.yaml":            "newTag: 1.1.0",
+		"overlays/prod/kustomization.yaml":   "newTag: 1.1.0",
+		"overlays/pinned/kustomization.yaml": "newTag: 0.9.0",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, p))
+		if err != nil {
+			t.Fatalf("read %s: %v", p, err)
+		}
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("%s: expected %q, got:\n%s", p, want, data)
+		}
+	}
+}
+
+func TestInheritKustomizationTags_AppliesPolicies(t *testing.T) {
+	doc := `images:
+- name: app
+  newTag: 1.0.0
+- name: db
+  newTag: 15.0
+`
+	e, err := policy.New([]config.Policy{{Expr: `name == "db"`, Action: "reject"}})
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	applied := policy.NewApplied()
+	ctx := policy.WithApplied(policy.WithEngine(context.Background(), e), applied)
+	node := yaml.MustParse(doc)
+	bumps := map[string]TagBump{"app": {From: "1.0.0", To: "1.1.0"}, "db": {From: "15.0", To: "16.0"}}
+	if err := node.PipeE(InheritKustomizationTags(ctx, bumps)); err != nil {
+		t.Fatalf("inherit: %v", err)
+	}
+	tags, err := GetKustomizationImageTags(node)
+	if err != nil {
+		t.Fatalf("tags: %v", err)
+	}
+	if tags["app"] != "1.1.0" || tags["db"] != "15.0" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	want := []policy.Proposal{{Source: "image", Name: "app", From: "1.0.0", To: "1.1.0"}}
+	if got := applied.Proposals(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected recorded %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,265 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// OPAImage is the image of the OPA runtime.
+const OPAImage = "openpolicyagent/opa"
+
+// opaBundleRevision matches the revision in a bundle resource, e.g. 1.2.0 in
+// bundles/authz-1.2.0.tar.gz or ghcr.io/acme/authz:v1.2.0.
+var opaBundleRevision = regexp.MustCompile(`(?P<version>v?\d+\.\d+(?:\.\d+)?(?:-[0-9A-Za-z]+(?:\.[0-9A-Za-z]+)*)?)`)
+
+// opaImageTag splits the tag of an OPA image into its version and variant,
+// e.g. -static or -envoy-1.
+var opaImageTag = regexp.MustCompile(`^(?P<version>v?\d+\.\d+\.\d+)(?P<variant>-.*)?$`)
+
+// OPABundle describes an OPA bundle whose revision is pinned by the resource
+// of OPA configs.
+type OPABundle struct {
+	// Name is the name of the bundle under bundles.
+	Name string
+	// Regex extracts the revision from the resource through its "version"
+	// named group.
+	Regex *regexp.Regexp
+	// Resolver resolves the latest revision.
+	Resolver VersionResolver
+}
+
+// NewOPABundles builds OPABundles from their config declarations.
+func NewOPABundles(decls []config.OPABundle, s RuleSources) ([]OPABundle, error) {
+	bundles := make([]OPABundle, 0, len(decls))
+	for i, d := range decls {
+		if d.Name == "" {
+			return nil, fmt.Errorf("opa bundle %d: bundle requires name", i)
+		}
+		b := OPABundle{Name: d.Name, Regex: opaBundleRevision}
+		if d.Regex != "" {
+			re, err := regexp.Compile(d.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("opa bundle %s: invalid regex %q: %w", d.Name, d.Regex, err)
+			}
+			if re.SubexpIndex("version") < 0 {
+				return nil, fmt.Errorf("opa bundle %s: regex %q has no version group", d.Name, d.Regex)
+			}
+			b.Regex = re
+		}
+		resolver, err := NewRuleResolver(d.Rule, s)
+		if err != nil {
+			return nil, fmt.Errorf("opa bundle %s: %w", d.Name, err)
+		}
+		b.Resolver = resolver
+		bundles = append(bundles, b)
+	}
+	return bundles, nil
+}
+
+// UpdateOPAManifests builds a pipeline that updates the revisions of the
+// given bundles pinned by the OPA configs under path, and the OPA runtime
+// images of the workloads under path. Only files holding one of them are
+// written back.
+func UpdateOPAManifests(
+	ctx context.Context,
+	bundles []OPABundle,
+	u update.Updater[*container.ImageRef],
+	path string,
+) kio.Pipeline {
+	ctx = policy.WithDir(ctx, path)
+	return kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{
+				PackagePath:    path,
+				MatchFilesGlob: []string{"*.yaml", "*.yml"},
+			},
+		},
+		Filters: []kio.Filter{
+			kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+				matched, err := KeepFilesWith(nodes, func(node *yaml.RNode) bool {
+					return IsOPAConfig(node) || hasOPAContainer(node)
+				})
+				if err != nil {
+					return nil, err
+				}
+				g := errgroup.Group{}
+				for _, node := range matched {
+					g.Go(func() error {
+						ctx := withFile(ctx, node)
+						if err := node.PipeE(
+							UpdateOPAConfig(ctx, bundles),
+							UpdateOPAImages(ctx, u),
+						); err != nil {
+							slog.WarnContext(ctx, "opa update failed", logging.Failed.Attr(), "path", policy.File(ctx), "err", err)
+						}
+						return nil
+					})
+				}
+				if err := g.Wait(); err != nil {
+					return nil, err
+				}
+				return matched, nil
+			}),
+		},
+		Outputs: []kio.Writer{
+			newPackageWriter(ctx, path),
+		},
+	}
+}
+
+// IsOPAConfig reports whether the document is an OPA config declaring
+// bundles.
+func IsOPAConfig(node *yaml.RNode) bool {
+	if node.GetApiVersion() != "" || node.GetKind() != "" {
+		return false
+	}
+	bundles := node.Field("bundles")
+	return bundles != nil && bundles.Value.YNode().Kind == yaml.MappingNode
+}
+
+// UpdateOPAConfig updates the revisions pinned by the resource of the
+// declared bundles of an OPA config. Undeclared bundles and other documents
+// are left alone.
+func UpdateOPAConfig(ctx context.Context, bundles []OPABundle) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		if !IsOPAConfig(node) {
+			return node, nil
+		}
+		for _, b := range bundles {
+			resourceNode, err := node.Pipe(yaml.Lookup("bundles", b.Name, "resource"))
+			if err != nil {
+				return nil, fmt.Errorf("lookup bundle %s: %w", b.Name, err)
+			}
+			value := yaml.GetValue(resourceNode)
+			m := b.Regex.FindStringSubmatchIndex(value)
+			idx := b.Regex.SubexpIndex("version")
+			if m == nil || m[2*idx] < 0 {
+				continue
+			}
+			start, end := m[2*idx], m[2*idx+1]
+			current := value[start:end]
+			latest, err := b.Resolver.Resolve(atNode(ctx, resourceNode.YNode()), current)
+			if err != nil {
+				return nil, fmt.Errorf("resolve bundle %s: %w", b.Name, err)
+			}
+			if latest == "" || latest == current {
+				continue
+			}
+			resourceNode.YNode().Value = value[:start] + latest + value[end:]
+			slog.InfoContext(ctx, "updated opa bundle", "bundle", b.Name, "from", current, "to", latest)
+		}
+		return node, nil
+	})
+}
+
+// podSpecPath returns the path of the pod spec of a workload, or nil.
+func podSpecPath(node *yaml.RNode) []string {
+	switch node.GetKind() {
+	case "Pod":
+		return []string{"spec"}
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job":
+		return []string{"spec", "template", "spec"}
+	case "CronJob":
+		return []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	default:
+		return nil
+	}
+}
+
+// opaContainers returns the containers and init containers of a workload
+// running the OPA runtime.
+func opaContainers(node *yaml.RNode) ([]*yaml.RNode, error) {
+	path := podSpecPath(node)
+	if path == nil {
+		return nil, nil
+	}
+	var out []*yaml.RNode
+	for _, field := range []string{"initContainers", "containers"} {
+		list, err := node.Pipe(yaml.Lookup(append(path, field)...))
+		if err != nil {
+			return nil, fmt.Errorf("lookup %s: %w", field, err)
+		}
+		if list == nil {
+			continue
+		}
+		elems, err := list.Elements()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field, err)
+		}
+		for _, c := range elems {
+			image, _ := c.GetString("image")
+			if ref, err := container.ParseImageRef(image); err == nil && isOPAImage(ref.Name) {
+				out = append(out, c)
+			}
+		}
+	}
+	return out, nil
+}
+
+// isOPAImage reports whether the image name is the OPA runtime, on Docker
+// Hub or a mirror of it.
+func isOPAImage(name string) bool {
+	return name == OPAImage || strings.HasSuffix(name, "/"+OPAImage)
+}
+
+// hasOPAContainer reports whether the document is a workload running the OPA
+// runtime.
+func hasOPAContainer(node *yaml.RNode) bool {
+	containers, err := opaContainers(node)
+	return err == nil && len(containers) > 0
+}
+
+// UpdateOPAImages updates the OPA runtime images of a workload, keeping the
+// variant of their tag, e.g. 0.68.0-static to 0.69.0-static. Untagged and
+// digest-pinned images, and tags without a version such as latest-static, are
+// left alone.
+func UpdateOPAImages(ctx context.Context, u update.Updater[*container.ImageRef]) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		containers, err := opaContainers(node)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range containers {
+			imageNode, err := c.Pipe(yaml.Lookup("image"))
+			if err != nil {
+				return nil, fmt.Errorf("lookup image: %w", err)
+			}
+			current := yaml.GetValue(imageNode)
+			if strings.Contains(current, "@") || !hasExplicitTag(current) {
+				continue
+			}
+			ref, err := container.ParseImageRef(current)
+			if err != nil {
+				return nil, fmt.Errorf("parse image ref %s: %w", current, err)
+			}
+			m := opaImageTag.FindStringSubmatch(ref.Tag)
+			if m == nil {
+				continue
+			}
+			version, variant := m[1], m[2]
+			latest, err := u.Update(atNode(ctx, imageNode.YNode()), &container.ImageRef{Name: ref.Name, Tag: version})
+			if err != nil {
+				return nil, fmt.Errorf("find latest tag: %w", err)
+			}
+			if latest == "" || latest == version {
+				continue
+			}
+			imageNode.YNode().Value = strings.TrimSuffix(current, ":"+ref.Tag) + ":" + latest + variant
+			slog.InfoContext(ctx, "updated opa image", "image", ref.Name, "from", ref.Tag, "to", latest+variant)
+		}
+		return node, nil
+	})
+}
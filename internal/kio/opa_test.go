@@ -0,0 +1,93 @@
+package kio
+
+import (
+	"context"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/config"
+)
+
+func TestUpdateOPAConfig(t *testing.T) {
+	rn := yaml.MustParse(`services:
+  acme:
+    url: https://bundles.example.com
+bundles:
+  authz:
+    service: acme
+    resource: bundles/authz-1.2.0.tar.gz
+  rbac:
+    service: acme
+    resource: bundles/rbac-2.0.0.tar.gz
+`)
+	bundles, err := NewOPABundles([]config.OPABundle{
+		{Name: "authz", Rule: config.Rule{Source: "github", Repository: "acme/authz"}},
+	}, RuleSources{GitHub: fakeUpdater{latest: "1.3.0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UpdateOPAConfig(context.Background(), bundles).Filter(rn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ := rn.GetString("bundles.authz.resource"); got != "bundles/authz-1.3.0.tar.gz" {
+		t.Errorf("authz resource = %s", got)
+	}
+	if got, _ := rn.GetString("bundles.rbac.resource"); got != "bundles/rbac-2.0.0.tar.gz" {
+		t.Errorf("undeclared rbac resource changed to %s", got)
+	}
+}
+
+func TestNewOPABundles_Invalid(t *testing.T) {
+	for name, d := range map[string]config.OPABundle{
+		"no name":   {Rule: config.Rule{Source: "image", Image: "ghcr.io/acme/authz"}},
+		"no group":  {Name: "authz", Rule: config.Rule{Source: "image", Image: "ghcr.io/acme/authz", Regex: `\d+`}},
+		"no source": {Name: "authz"},
+	} {
+		if _, err := NewOPABundles([]config.OPABundle{d}, RuleSources{}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestUpdateOPAImages(t *testing.T) {
+	rn := yaml.MustParse(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: opa
+spec:
+  template:
+    spec:
+      containers:
+        - name: opa
+          image: openpolicyagent/opa:0.68.0-static
+        - name: envoy
+          image: envoyproxy/envoy:v1.31.0
+        - name: sidecar
+          image: ghcr.io/mirror/openpolicyagent/opa:latest-envoy
+`)
+	if !hasOPAContainer(rn) {
+		t.Fatal("expected an OPA container")
+	}
+	if _, err := UpdateOPAImages(context.Background(), fakeImageUpdater{latest: "0.69.0"}).Filter(rn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"openpolicyagent/opa:0.69.0-static",
+		"envoyproxy/envoy:v1.31.0",
+		"ghcr.io/mirror/openpolicyagent/opa:latest-envoy",
+	}
+	containers, err := rn.Pipe(yaml.Lookup("spec", "template", "spec", "containers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	elems, err := containers.Elements()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range elems {
+		if got, _ := c.GetString("image"); got != want[i] {
+			t.Errorf("image %d = %s, want %s", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package kio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// KafkaVersionsAnnotation lists the Kafka versions, comma separated, that the
+// Strimzi operator managing a resource supports.
+const KafkaVersionsAnnotation = "automata.shikanime.studio/kafka-versions"
+
+// VersionResolver resolves the latest value for a version field.
+type VersionResolver interface {
+	Resolve(ctx context.Context, current string) (string, error)
+}
+
+// VersionResolverFunc adapts a function to a VersionResolver.
+type VersionResolverFunc func(ctx context.Context, current string) (string, error)
+
+// Resolve calls f(ctx, current).
+func (f VersionResolverFunc) Resolve(ctx context.Context, current string) (string, error) {
+	return f(ctx, current)
+}
+
+// ResourceRule describes a version field embedded in a custom resource.
+type ResourceRule struct {
+	// Group is the API group of the resource, e.g. postgresql.cnpg.io.
+	Group string
+	// Kind is the resource kind, e.g. Cluster.
+	Kind string
+	// Path is the field path holding the version, e.g. spec.version.
+	Path []string
+	// Resolver resolves the latest value for the field.
+	Resolver VersionResolver
+	// Annotation, when set, names the annotation of the resource listing the
+	// versions the field may move to, comma separated. Resources without it
+	// are left alone.
+	Annotation string
+}
+
+// Matches reports whether the rule applies to the given resource.
+func (r ResourceRule) Matches(node *yaml.RNode) bool {
+	group, _, _ := strings.Cut(node.GetApiVersion(), "/")
+	return group == r.Group && node.GetKind() == r.Kind
+}
+
+// ResolveImage resolves full image references ("name:tag") with the container
+// updater, preserving the reference as written apart from its tag. Untagged
+// and digest-pinned references are left alone.
+func ResolveImage(u update.Updater[*container.ImageRef]) VersionResolver {
+	return VersionResolverFunc(func(ctx context.Context, current string) (string, error) {
+		if strings.Contains(current, "@") || !hasExplicitTag(current) {
+			return "", nil
+		}
+		ref, err := container.ParseImageRef(current)
+		if err != nil {
+			return "", fmt.Errorf("parse image ref %s: %w", current, err)
+		}
+		latest, err := u.Update(ctx, &ref)
+		if err != nil {
+			return "", err
+		}
+		if latest == "" || latest == ref.Tag {
+			return "", nil
+		}
+		return strings.TrimSuffix(current, ":"+ref.Tag) + ":" + latest, nil
+	})
+}
+
+// hasExplicitTag reports whether an image reference carries a tag.
+func hasExplicitTag(ref string) bool {
+	return strings.LastIndex(ref, ":") > strings.LastIndex(ref, "/")
+}
+
+// ResolveImageTag resolves bare versions from the tags of the given image,
+// among the allowed versions of the resource when a rule lists them.
+func ResolveImageTag(
+	u update.Updater[*container.ImageRef],
+	image string,
+	opts ...update.Option,
+) VersionResolver {
+	return VersionResolverFunc(func(ctx context.Context, current string) (string, error) {
+		return u.Update(
+			ctx,
+			&container.ImageRef{Name: image, Tag: current},
+			append(slices.Clone(opts), allowedOptions(ctx)...)...,
+		)
+	})
+}
+
+// ResolveGitHubTag resolves bare versions from the tags of a GitHub
+// repository, among the allowed versions of the resource when a rule lists
+// them.
+func ResolveGitHubTag(
+	u update.Updater[*github.ActionRef],
+	owner, repo string,
+	opts ...update.Option,
+) VersionResolver {
+	return VersionResolverFunc(func(ctx context.Context, current string) (string, error) {
+		return u.Update(
+			ctx,
+			&github.ActionRef{Owner: owner, Repo: repo, Version: current},
+			append(slices.Clone(opts), allowedOptions(ctx)...)...,
+		)
+	})
+}
+
+// allowedKey is the context key of the versions a field may move to.
+type allowedKey struct{}
+
+// withAllowed returns a context bounding resolved versions to the allowed
+// ones.
+func withAllowed(ctx context.Context, allowed []string) context.Context {
+	return context.WithValue(ctx, allowedKey{}, allowed)
+}
+
+// allowedOptions returns the candidate check rejecting the versions missing
+// from the allowed ones of the context, or nothing when it has none.
+func allowedOptions(ctx context.Context) []update.Option {
+	allowed, ok := ctx.Value(allowedKey{}).([]string)
+	if !ok {
+		return nil
+	}
+	return []update.Option{update.WithCandidateCheck(
+		func(_ context.Context, candidate string) (bool, error) {
+			return slices.Contains(allowed, candidate), nil
+		},
+	)}
+}
+
+// allowedVersions returns the comma-separated versions listed by the
+// annotation of a resource.
+func allowedVersions(node *yaml.RNode, annotation string) []string {
+	var allowed []string
+	for _, v := range strings.Split(node.GetAnnotations()[annotation], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			allowed = append(allowed, v)
+		}
+	}
+	return allowed
+}
+
+// CloudNativePGRules returns rules for CloudNativePG clusters.
+func CloudNativePGRules(cu update.Updater[*container.ImageRef]) []ResourceRule {
+	return []ResourceRule{
+		{
+			Group:    "postgresql.cnpg.io",
+			Kind:     "Cluster",
+			Path:     []string{"spec", "imageName"},
+			Resolver: ResolveImage(cu),
+		},
+	}
+}
+
+// StrimziRules returns rules for Strimzi Kafka resources, resolving Kafka
+// versions from the Apache Kafka release tags. A Strimzi operator only runs
+// the Kafka versions it was released with, so only the resources listing
+// them in their KafkaVersionsAnnotation are updated, to one of those.
+func StrimziRules(gu update.Updater[*github.ActionRef]) []ResourceRule {
+	kafka := ResolveGitHubTag(gu, "apache", "kafka")
+	rules := []ResourceRule{
+		{
+			Group:      "kafka.strimzi.io",
+			Kind:       "Kafka",
+			Path:       []string{"spec", "kafka", "version"},
+			Resolver:   kafka,
+			Annotation: KafkaVersionsAnnotation,
+		},
+	}
+	for _, kind := range []string{"KafkaConnect", "KafkaMirrorMaker2"} {
+		rules = append(rules, ResourceRule{
+			Group:      "kafka.strimzi.io",
+			Kind:       kind,
+			Path:       []string{"spec", "version"},
+			Resolver:   kafka,
+			Annotation: KafkaVersionsAnnotation,
+		})
+	}
+	return rules
+}
+
+// ECKRules returns rules for Elastic Cloud on Kubernetes resources, resolving
+// stack versions from the Elastic registry.
+func ECKRules(cu update.Updater[*container.ImageRef]) []ResourceRule {
+	images := []struct{ group, kind, image string }{
+		{"elasticsearch.k8s.elastic.co", "Elasticsearch", "docker.elastic.co/elasticsearch/elasticsearch"},
+		{"kibana.k8s.elastic.co", "Kibana", "docker.elastic.co/kibana/kibana"},
+		{"apm.k8s.elastic.co", "ApmServer", "docker.elastic.co/apm/apm-server"},
+		{"agent.k8s.elastic.co", "Agent", "docker.elastic.co/elastic-agent/elastic-agent"},
+		{"logstash.k8s.elastic.co", "Logstash", "docker.elastic.co/logstash/logstash"},
+	}
+	rules := make([]ResourceRule, 0, len(images))
+	for _, i := range images {
+		rules = append(rules, ResourceRule{
+			Group:    i.group,
+			Kind:     i.kind,
+			Path:     []string{"spec", "version"},
+			Resolver: ResolveImageTag(cu, i.image),
+		})
+	}
+	return rules
+}
+
+// OperatorRules returns the built-in rules for popular operators.
+func OperatorRules(
+	cu update.Updater[*container.ImageRef],
+	gu update.Updater[*github.ActionRef],
+) []ResourceRule {
+	var rules []ResourceRule
+	rules = append(rules, CloudNativePGRules(cu)...)
+	rules = append(rules, StrimziRules(gu)...)
+	rules = append(rules, ECKRules(cu)...)
+	return rules
+}
+
+// UpdateResources builds a pipeline that updates version fields of custom
+// resources matching the given rules.
+func UpdateResources(ctx context.Context, rules []ResourceRule, path string) kio.Pipeline {
+	ctx = policy.WithDir(ctx, path)
+	return kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{
+				PackagePath:    path,
+				MatchFilesGlob: []string{"*.yaml", "*.yml"},
+			},
+		},
+		Filters: []kio.Filter{
+			UpdateResourcesVersions(ctx, rules),
+		},
+		Outputs: []kio.Writer{
+			newPackageWriter(ctx, path),
+		},
+	}
+}
+
+// UpdateResourcesVersions applies resource rules across all loaded resources
+// and drops the files holding no resource matched by a rule.
+func UpdateResourcesVersions(ctx context.Context, rules []ResourceRule) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		matched, err := KeepFilesWith(nodes, func(node *yaml.RNode) bool {
+			return slices.ContainsFunc(rules, func(r ResourceRule) bool { return r.Matches(node) })
+		})
+		if err != nil {
+			return nil, err
+		}
+		var (
+			mu   sync.Mutex
+			errs []error
+		)
+		g := errgroup.Group{}
+		for _, node := range matched {
+			g.Go(func() error {
+				if err := node.PipeE(UpdateResourceVersions(withFile(ctx, node), rules)); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+				return nil
+			})
+		}
+		_ = g.Wait()
+		if err := errors.Join(errs...); err != nil {
+			return nil, err
+		}
+		return matched, nil
+	})
+}
+
+// UpdateResourceVersions updates the version fields of one resource. Every
+// rule is applied and the errors of the failed ones are joined.
+func UpdateResourceVersions(ctx context.Context, rules []ResourceRule) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		var errs []error
+		for _, rule := range rules {
+			if !rule.Matches(node) {
+				continue
+			}
+			rctx := ctx
+			if rule.Annotation != "" {
+				allowed := allowedVersions(node, rule.Annotation)
+				if len(allowed) == 0 {
+					continue
+				}
+				rctx = withAllowed(ctx, allowed)
+			}
+			fieldNode, err := node.Pipe(yaml.Lookup(rule.Path...))
+			if err != nil {
+				errs = append(errs, fmt.Errorf(
+					"lookup %s %s %s: %w", rule.Kind, node.GetName(), strings.Join(rule.Path, "."), err,
+				))
+				continue
+			}
+			current := yaml.GetValue(fieldNode)
+			if current == "" {
+				continue
+			}
+			latest, err := rule.Resolver.Resolve(atNode(rctx, fieldNode.YNode()), current)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("resolve %s %s: %w", rule.Kind, node.GetName(), err))
+				continue
+			}
+			if latest == "" || latest == current {
+				continue
+			}
+			fieldNode.YNode().Value = latest
+			slog.InfoContext(
+				ctx,
+				"updated resource version",
+				"kind",
+				rule.Kind,
+				"name",
+				node.GetName(),
+				"from",
+				current,
+				"to",
+				latest,
+			)
+		}
+		if err := errors.Join(errs...); err != nil {
+			return nil, err
+		}
+		return node, nil
+	})
+}
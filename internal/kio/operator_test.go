@@ -0,0 +1,226 @@
+package kio
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// fakeTagUpdater selects the latest of its tags, honoring the options.
+type fakeTagUpdater struct {
+	tags []string
+}
+
+func (f fakeTagUpdater) Update(
+	ctx context.Context,
+	ref *github.ActionRef,
+	opts ...update.Option,
+) (string, error) {
+	return update.SelectLatest(ctx, ref.Version, f.tags, update.WithCompareOptions(opts...))
+}
+
+func TestUpdateResourceVersions(t *testing.T) {
+	doc := `apiVersion: postgresql.cnpg.io/v1
+kind: Cluster
+metadata:
+  name: db
+spec:
+  imageName: ghcr.io/cloudnative-pg/postgresql:16.1`
+	rn := yaml.MustParse(doc)
+	rules := CloudNativePGRules(fakeImageUpdater{latest: "16.4"})
+	_, err := UpdateResourceVersions(context.Background(), rules).Filter(rn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	imageNode, err := rn.Pipe(yaml.Lookup("spec", "imageName"))
+	if err != nil {
+		t.Fatalf("lookup imageName: %v", err)
+	}
+	if got := yaml.GetValue(imageNode); got != "ghcr.io/cloudnative-pg/postgresql:16.4" {
+		t.Fatalf("unexpected imageName: %s", got)
+	}
+}
+
+func TestUpdateResourceVersions_KindMismatch(t *testing.T) {
+	doc := `apiVersion: elasticsearch.k8s.elastic.co/v1
+kind: Kibana
+metadata:
+  name: kb
+spec:
+  version: 8.10.0`
+	rn := yaml.MustParse(doc)
+	rules := []ResourceRule{
+		{
+			Group:    "elasticsearch.k8s.elastic.co",
+			Kind:     "Elasticsearch",
+			Path:     []string{"spec", "version"},
+			Resolver: ResolveImageTag(fakeImageUpdater{latest: "8.15.0"}, "es"),
+		},
+	}
+	_, err := UpdateResourceVersions(context.Background(), rules).Filter(rn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	versionNode, err := rn.Pipe(yaml.Lookup("spec", "version"))
+	if err != nil {
+		t.Fatalf("lookup version: %v", err)
+	}
+	if got := yaml.GetValue(versionNode); got != "8.10.0" {
+		t.Fatalf("unexpected version: %s", got)
+	}
+}
+
+func TestResolveImage_SkipsUntaggedAndDigests(t *testing.T) {
+	r := ResolveImage(fakeImageUpdater{latest: "16.4"})
+	for _, ref := range []string{
+		"ghcr.io/cloudnative-pg/postgresql",
+		"localhost:5000/postgresql",
+		"ghcr.io/cloudnative-pg/postgresql:16.1@sha256:0123",
+	} {
+		got, err := r.Resolve(context.Background(), ref)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", ref, err)
+		}
+		if got != "" {
+			t.Fatalf("%s: resolved to %s", ref, got)
+		}
+	}
+}
+
+func TestUpdateResources_WritesOnlyMatchedFiles(t *testing.T) {
+	dir := t.TempDir()
+	cluster := `apiVersion: postgresql.cnpg.io/v1
+kind: Cluster
+metadata:
+  name: db
+spec:
+  imageName: ghcr.io/cloudnative-pg/postgresql:16.1
+`
+	other := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n    name: cm\n"
+	if err := os.WriteFile(filepath.Join(dir, "cluster.yaml"), []byte(cluster), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.yaml"), []byte(other), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	rules := CloudNativePGRules(fakeImageUpdater{latest: "16.4"})
+	if err := UpdateResources(context.Background(), rules, dir).Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "other.yaml"))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(got) != other {
+		t.Fatalf("rewrote an unmatched file:\n%s", got)
+	}
+	got, err = os.ReadFile(filepath.Join(dir, "cluster.yaml"))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if want := "postgresql:16.4"; !strings.Contains(string(got), want) {
+		t.Fatalf("expected %q in:\n%s", want, got)
+	}
+}
+
+func TestUpdateResources_RecordsVersionPosition(t *testing.T) {
+	dir := t.TempDir()
+	manifests := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+---
+apiVersion: postgresql.cnpg.io/v1
+kind: Cluster
+metadata:
+  name: db
+spec:
+  imageName: ghcr.io/cloudnative-pg/postgresql:16.1
+`
+	if err := os.WriteFile(filepath.Join(dir, "cluster.yaml"), []byte(manifests), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var line, column int
+	rules := []ResourceRule{{
+		Group: "postgresql.cnpg.io",
+		Kind:  "Cluster",
+		Path:  []string{"spec", "imageName"},
+		Resolver: VersionResolverFunc(func(ctx context.Context, _ string) (string, error) {
+			line, column = policy.Position(ctx)
+			return "", nil
+		}),
+	}}
+	if err := UpdateResources(context.Background(), rules, dir).Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if line != 11 || column != 14 {
+		t.Fatalf("expected the version at 11:14, got %d:%d", line, column)
+	}
+}
+
+func TestUpdateResourceVersions_KafkaBoundedToAnnotation(t *testing.T) {
+	rules := StrimziRules(fakeTagUpdater{tags: []string{"3.8.0", "3.9.0", "4.0.0"}})
+	for _, tc := range []struct {
+		name        string
+		annotations string
+		want        string
+	}{
+		{name: "unannotated", want: "3.8.0"},
+		{
+			name:        "annotated",
+			annotations: "\n  annotations:\n    automata.shikanime.studio/kafka-versions: 3.8.0, 3.9.0",
+			want:        "3.9.0",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rn := yaml.MustParse(`apiVersion: kafka.strimzi.io/v1beta2
+kind: Kafka
+metadata:
+  name: kafka` + tc.annotations + `
+spec:
+  kafka:
+    version: 3.8.0`)
+			if _, err := UpdateResourceVersions(context.Background(), rules).Filter(rn); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			versionNode, err := rn.Pipe(yaml.Lookup("spec", "kafka", "version"))
+			if err != nil {
+				t.Fatalf("lookup version: %v", err)
+			}
+			if got := yaml.GetValue(versionNode); got != tc.want {
+				t.Fatalf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUpdateResources_ReturnsResolveErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		cluster := "apiVersion: postgresql.cnpg.io/v1\nkind: Cluster\nmetadata:\n  name: " + name +
+			"\nspec:\n  imageName: ghcr.io/cloudnative-pg/postgresql:16.1\n"
+		if err := os.WriteFile(filepath.Join(dir, name+".yaml"), []byte(cluster), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	boom := errors.New("registry unavailable")
+	rules := CloudNativePGRules(fakeImageUpdater{err: boom})
+	err := UpdateResources(context.Background(), rules, dir).Execute()
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected the resolve error, got %v", err)
+	}
+	for _, name := range []string{"Cluster a", "Cluster b"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Fatalf("expected %q in %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// PolicyLibraries are the GitHub repositories of the policy libraries of
+// Kyverno and Gatekeeper, as owner/repo.
+var PolicyLibraries = []string{
+	"kyverno/policies",
+	"open-policy-agent/gatekeeper-library",
+}
+
+// FluxSourceGroup is the API group of Flux sources.
+const FluxSourceGroup = "source.toolkit.fluxcd.io"
+
+// UpdatePolicyBundles builds a pipeline that updates the versions of the
+// policy libraries installed by the manifests under path. Only files
+// referencing one of them are written back.
+func UpdatePolicyBundles(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	gu update.Updater[*github.ActionRef],
+	path string,
+) kio.Pipeline {
+	ctx = policy.WithDir(ctx, path)
+	return kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{
+				PackagePath:    path,
+				MatchFilesGlob: append([]string{"*.yaml", "*.yml"}, KustomizationFiles...),
+			},
+		},
+		Filters: []kio.Filter{
+			UpdatePolicyBundlesVersions(ctx, cu, gu),
+		},
+		Outputs: []kio.Writer{
+			newPackageWriter(ctx, path),
+		},
+	}
+}
+
+// UpdatePolicyBundlesVersions updates the policy library references across
+// the loaded files, dropping the files holding none.
+func UpdatePolicyBundlesVersions(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	gu update.Updater[*github.ActionRef],
+) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		matched, err := KeepFilesWith(nodes, IsPolicyBundleSource)
+		if err != nil {
+			return nil, err
+		}
+		g := errgroup.Group{}
+		for _, node := range matched {
+			g.Go(func() error {
+				ctx := withFile(ctx, node)
+				if err := node.PipeE(UpdatePolicyBundle(ctx, cu, gu)); err != nil {
+					slog.WarnContext(ctx, "policy bundle update failed", logging.Failed.Attr(), "path", policy.File(ctx), "err", err)
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+		return matched, nil
+	})
+}
+
+// IsPolicyBundleSource reports whether the document references a policy
+// library: a kustomization with a remote resource of one, or a Flux
+// GitRepository or OCIRepository pulling one.
+func IsPolicyBundleSource(node *yaml.RNode) bool {
+	switch {
+	case isKustomizationFile(node):
+		resources, err := node.GetSlice("resources")
+		if err != nil {
+			return false
+		}
+		for _, r := range resources {
+			if s, ok := r.(string); ok {
+				if _, _, _, ok := parsePolicyGitURL(s); ok {
+					return true
+				}
+			}
+		}
+		return false
+	case isFluxSource(node, "GitRepository"):
+		u, _ := node.GetString("spec.url")
+		_, _, ok := policyLibrary(u)
+		return ok
+	case isFluxSource(node, "OCIRepository"):
+		u, _ := node.GetString("spec.url")
+		return isPolicyOCIURL(u)
+	default:
+		return false
+	}
+}
+
+// isKustomizationFile reports whether the document is a kustomization,
+// declared or named as one.
+func isKustomizationFile(node *yaml.RNode) bool {
+	if strings.HasPrefix(node.GetApiVersion(), "kustomize.config.k8s.io/") {
+		return node.GetKind() == "Kustomization"
+	}
+	p, _, err := kioutil.GetFileAnnotations(node)
+	return err == nil && node.GetApiVersion() == "" && slices.Contains(KustomizationFiles, filepath.Base(p))
+}
+
+// isFluxSource reports whether the document is a Flux source of the kind.
+func isFluxSource(node *yaml.RNode, kind string) bool {
+	group, _, _ := strings.Cut(node.GetApiVersion(), "/")
+	return group == FluxSourceGroup && node.GetKind() == kind
+}
+
+// policyLibrary returns the owner and repository of the GitHub URL of a
+// policy library, with or without scheme and .git suffix.
+func policyLibrary(s string) (owner, repo string, ok bool) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "https://"), "git::https://")
+	rest, ok := strings.CutPrefix(s, "github.com/")
+	if !ok {
+		return "", "", false
+	}
+	parts := strings.SplitN(rest, "/", 3)
+	if len(parts) < 2 {
+		return "", "", false
+	}
+	owner, repo = parts[0], strings.TrimSuffix(parts[1], ".git")
+	return owner, repo, slices.Contains(PolicyLibraries, owner+"/"+repo)
+}
+
+// parsePolicyGitURL returns the owner, repository and ref of a kustomize
+// remote resource of a policy library, e.g.
+// https://github.com/kyverno/policies//pod-security?ref=v1.12.0.
+func parsePolicyGitURL(s string) (owner, repo, ref string, ok bool) {
+	base, query, found := strings.Cut(s, "?")
+	if !found {
+		return "", "", "", false
+	}
+	owner, repo, ok = policyLibrary(base)
+	if !ok {
+		return "", "", "", false
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil || !isVersionRef(values.Get("ref")) {
+		return "", "", "", false
+	}
+	return owner, repo, values.Get("ref"), true
+}
+
+// isVersionRef reports whether a git ref is a version tag rather than a
+// branch or a commit.
+func isVersionRef(ref string) bool {
+	return semver.IsValid("v" + strings.TrimPrefix(ref, "v"))
+}
+
+// isPolicyOCIURL reports whether the OCI URL is a repository published under
+// the owner and name of a policy library, e.g.
+// oci://ghcr.io/kyverno/policies/pod-security.
+func isPolicyOCIURL(s string) bool {
+	rest, ok := strings.CutPrefix(s, "oci://")
+	if !ok {
+		return false
+	}
+	_, path, _ := strings.Cut(rest, "/")
+	for _, l := range PolicyLibraries {
+		if path == l || strings.HasPrefix(path, l+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdatePolicyBundle updates the policy library references of one document.
+// The refs of kustomization remote resources and the tags of Flux
+// GitRepository sources follow the tags of the library, and the tags of Flux
+// OCIRepository sources the tags of their registry. Branches and commits are
+// left alone, as are references to other repositories.
+func UpdatePolicyBundle(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	gu update.Updater[*github.ActionRef],
+) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		switch {
+		case isKustomizationFile(node):
+			resources, err := node.Pipe(yaml.Lookup("resources"))
+			if err != nil {
+				return nil, fmt.Errorf("lookup resources: %w", err)
+			}
+			if resources == nil {
+				return node, nil
+			}
+			elems, err := resources.Elements()
+			if err != nil {
+				return nil, fmt.Errorf("resources: %w", err)
+			}
+			for _, elem := range elems {
+				current := yaml.GetValue(elem)
+				owner, repo, ref, ok := parsePolicyGitURL(current)
+				if !ok {
+					continue
+				}
+				latest, err := ResolveGitHubTag(gu, owner, repo).Resolve(atNode(ctx, elem.YNode()), ref)
+				if err != nil {
+					return nil, fmt.Errorf("resolve %s/%s: %w", owner, repo, err)
+				}
+				if latest == "" || latest == ref {
+					continue
+				}
+				elem.YNode().Value = strings.Replace(current, "ref="+ref, "ref="+latest, 1)
+				slog.InfoContext(ctx, "updated policy bundle", "bundle", owner+"/"+repo, "from", ref, "to", latest)
+			}
+		case isFluxSource(node, "GitRepository"):
+			u, _ := node.GetString("spec.url")
+			owner, repo, ok := policyLibrary(u)
+			if !ok {
+				return node, nil
+			}
+			if err := updatePolicyBundleTag(ctx, node, owner+"/"+repo, ResolveGitHubTag(gu, owner, repo)); err != nil {
+				return nil, err
+			}
+		case isFluxSource(node, "OCIRepository"):
+			u, _ := node.GetString("spec.url")
+			if !isPolicyOCIURL(u) {
+				return node, nil
+			}
+			name := strings.TrimPrefix(u, "oci://")
+			if err := updatePolicyBundleTag(ctx, node, name, ResolveImageTag(cu, name)); err != nil {
+				return nil, err
+			}
+		}
+		return node, nil
+	})
+}
+
+// updatePolicyBundleTag resolves and rewrites the spec.ref.tag of a Flux
+// source.
+func updatePolicyBundleTag(ctx context.Context, node *yaml.RNode, bundle string, r VersionResolver) error {
+	tagNode, err := node.Pipe(yaml.Lookup("spec", "ref", "tag"))
+	if err != nil {
+		return fmt.Errorf("lookup tag: %w", err)
+	}
+	current := yaml.GetValue(tagNode)
+	if !isVersionRef(current) {
+		return nil
+	}
+	latest, err := r.Resolve(atNode(ctx, tagNode.YNode()), current)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", bundle, err)
+	}
+	if latest == "" || latest == current {
+		return nil
+	}
+	tagNode.YNode().Value = latest
+	slog.InfoContext(ctx, "updated policy bundle", "bundle", bundle, "from", current, "to", latest)
+	return nil
+}
@@ -0,0 +1,141 @@
+package kio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestUpdatePolicyBundle_KustomizationResources(t *testing.T) {
+	rn := yaml.MustParse(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+  - https://github.com/kyverno/policies//pod-security/baseline?ref=v1.11.0
+  - github.com/open-policy-agent/gatekeeper-library/library/general?ref=main
+  - https://github.com/example/policies//base?ref=v1.0.0
+  - deployment.yaml
+`)
+	if !IsPolicyBundleSource(rn) {
+		t.Fatal("expected kustomization to reference a policy bundle")
+	}
+	if _, err := UpdatePolicyBundle(
+		context.Background(),
+		fakeImageUpdater{},
+		fakeUpdater{latest: "v1.12.0"},
+	).Filter(rn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := rn.GetSlice("resources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []any{
+		"https://github.com/kyverno/policies//pod-security/baseline?ref=v1.12.0",
+		"github.com/open-policy-agent/gatekeeper-library/library/general?ref=main",
+		"https://github.com/example/policies//base?ref=v1.0.0",
+		"deployment.yaml",
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("resources[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestUpdatePolicyBundle_FluxSources(t *testing.T) {
+	cases := []struct {
+		name, doc, want string
+	}{
+		{
+			name: "git",
+			doc: `apiVersion: source.toolkit.fluxcd.io/v1
+kind: GitRepository
+metadata:
+  name: gatekeeper-library
+spec:
+  url: https://github.com/open-policy-agent/gatekeeper-library.git
+  ref:
+    tag: v3.15.0`,
+			want: "v3.16.0",
+		},
+		{
+			name: "oci",
+			doc: `apiVersion: source.toolkit.fluxcd.io/v1beta2
+kind: OCIRepository
+metadata:
+  name: kyverno-policies
+spec:
+  url: oci://ghcr.io/kyverno/policies/pod-security
+  ref:
+    tag: v1.11.0`,
+			want: "v1.12.0",
+		},
+		{
+			name: "other",
+			doc: `apiVersion: source.toolkit.fluxcd.io/v1beta2
+kind: OCIRepository
+metadata:
+  name: app
+spec:
+  url: oci://ghcr.io/example/app
+  ref:
+    tag: v1.0.0`,
+			want: "v1.0.0",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rn := yaml.MustParse(c.doc)
+			if _, err := UpdatePolicyBundle(
+				context.Background(),
+				fakeImageUpdater{latest: "v1.12.0"},
+				fakeUpdater{latest: "v3.16.0"},
+			).Filter(rn); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, _ := rn.GetString("spec.ref.tag"); got != c.want {
+				t.Errorf("tag = %s, want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestUpdatePolicyBundles_WritesOnlyBundleFiles(t *testing.T) {
+	dir := t.TempDir()
+	kustomization := `resources:
+- https://github.com/kyverno/policies//best-practices?ref=v1.11.0
+`
+	deployment := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+`
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(kustomization), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "deployment.yaml"), []byte(deployment), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdatePolicyBundles(
+		context.Background(),
+		fakeImageUpdater{},
+		fakeUpdater{latest: "v1.12.0"},
+		dir,
+	).Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "?ref=v1.12.0") {
+		t.Errorf("kustomization not bumped:\n%s", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "deployment.yaml")); string(got) != deployment {
+		t.Errorf("deployment rewritten:\n%s", got)
+	}
+}
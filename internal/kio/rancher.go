@@ -0,0 +1,160 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/helm"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	"github.com/shikanime-studio/automata/internal/rancher"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// ResolveRancherRelease resolves k3s and RKE2 releases from the given
+// channel of their Rancher release channels.
+func ResolveRancherRelease(u update.Updater[*rancher.ReleaseRef], channel string) VersionResolver {
+	return VersionResolverFunc(func(ctx context.Context, current string) (string, error) {
+		return u.Update(ctx, &rancher.ReleaseRef{Channel: channel, Version: current})
+	})
+}
+
+// RancherRules returns rules for the k3s and RKE2 releases pinned by
+// system-upgrade-controller plans and Rancher provisioning clusters.
+func RancherRules(u update.Updater[*rancher.ReleaseRef], channel string) []ResourceRule {
+	release := ResolveRancherRelease(u, channel)
+	return []ResourceRule{
+		{
+			Group:    "upgrade.cattle.io",
+			Kind:     "Plan",
+			Path:     []string{"spec", "version"},
+			Resolver: release,
+		},
+		{
+			Group:    "provisioning.cattle.io",
+			Kind:     "Cluster",
+			Path:     []string{"spec", "kubernetesVersion"},
+			Resolver: release,
+		},
+	}
+}
+
+// isRancherResource reports whether the resource is updated by
+// UpdateRancherManifests.
+func isRancherResource(rules []ResourceRule) func(*yaml.RNode) bool {
+	return func(node *yaml.RNode) bool {
+		if isHelmChart(node) {
+			return true
+		}
+		for _, r := range rules {
+			if r.Matches(node) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// isHelmChart reports whether the resource is a HelmChart of the k3s and RKE2
+// Helm controller.
+func isHelmChart(node *yaml.RNode) bool {
+	return strings.HasPrefix(node.GetApiVersion(), "helm.cattle.io/") && node.GetKind() == "HelmChart"
+}
+
+// UpdateRancherManifests builds a pipeline that updates the k3s and RKE2
+// releases pinned under path with the given rules, and the HelmChart
+// resources of their manifests directories with the Helm updater. Only files
+// holding one of them are written back.
+func UpdateRancherManifests(
+	ctx context.Context,
+	rules []ResourceRule,
+	hu update.Updater[*helm.ChartRef],
+	path string,
+) kio.Pipeline {
+	ctx = policy.WithDir(ctx, path)
+	return kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{
+				PackagePath:    path,
+				MatchFilesGlob: []string{"*.yaml", "*.yml"},
+			},
+		},
+		Filters: []kio.Filter{
+			kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+				matched, err := KeepFilesWith(nodes, isRancherResource(rules))
+				if err != nil {
+					return nil, err
+				}
+				g := errgroup.Group{}
+				for _, node := range matched {
+					g.Go(func() error {
+						ctx := withFile(ctx, node)
+						if err := node.PipeE(
+							UpdateResourceVersions(ctx, rules),
+							UpdateHelmChart(ctx, hu),
+						); err != nil {
+							slog.WarnContext(ctx, "rancher manifest update failed", logging.Failed.Attr(), "path", policy.File(ctx), "err", err)
+						}
+						return nil
+					})
+				}
+				if err := g.Wait(); err != nil {
+					return nil, err
+				}
+				return matched, nil
+			}),
+		},
+		Outputs: []kio.Writer{
+			newPackageWriter(ctx, path),
+		},
+	}
+}
+
+// UpdateHelmChart updates the version of a HelmChart installing a chart from
+// a repository. Charts referenced by URL, such as the archives of air-gap
+// bundles served from the static charts directory, or embedded as
+// chartContent, are left alone, as their archive is not fetched. Other
+// resources, HelmChartConfig included, are left alone.
+func UpdateHelmChart(ctx context.Context, u update.Updater[*helm.ChartRef]) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		if !isHelmChart(node) {
+			return node, nil
+		}
+		repo, _ := node.GetString("spec.repo")
+		chart, _ := node.GetString("spec.chart")
+		version, _ := node.GetString("spec.version")
+		if repo == "" || chart == "" || version == "" || strings.Contains(chart, "://") {
+			return node, nil
+		}
+		versionNode, err := node.Pipe(yaml.Lookup("spec", "version"))
+		if err != nil {
+			return nil, fmt.Errorf("lookup version: %w", err)
+		}
+		latest, err := u.Update(
+			atNode(ctx, versionNode.YNode()), &helm.ChartRef{RepoURL: repo, Name: chart, Version: version},
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch chart version: %w", err)
+		}
+		if latest == "" || latest == version {
+			return node, nil
+		}
+		if err := node.PipeE(
+			yaml.Lookup("spec"),
+			yaml.SetField("version", yaml.NewStringRNode(latest)),
+		); err != nil {
+			return nil, fmt.Errorf("set version failed: %w", err)
+		}
+		recordChartBump(ctx, helm.ChartRef{RepoURL: repo, Name: chart, Version: latest})
+		slog.InfoContext(ctx, "updated chart version",
+			"name", chart, "from", version, "to", latest, "repo", repo)
+		return node, nil
+	})
+}
@@ -0,0 +1,72 @@
+package kio
+
+import (
+	"context"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/rancher"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+type fakeRancherUpdater struct {
+	latest string
+}
+
+func (f fakeRancherUpdater) Update(
+	_ context.Context,
+	_ *rancher.ReleaseRef,
+	_ ...update.Option,
+) (string, error) {
+	return f.latest, nil
+}
+
+func TestRancherRules_Plan(t *testing.T) {
+	rn := yaml.MustParse(`apiVersion: upgrade.cattle.io/v1
+kind: Plan
+metadata:
+  name: server-plan
+spec:
+  version: v1.29.5+rke2r1
+  upgrade:
+    image: rancher/rke2-upgrade`)
+	rules := RancherRules(fakeRancherUpdater{latest: "v1.30.4+rke2r1"}, "")
+	if _, err := UpdateResourceVersions(context.Background(), rules).Filter(rn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ := rn.GetString("spec.version"); got != "v1.30.4+rke2r1" {
+		t.Fatalf("unexpected version: %s", got)
+	}
+}
+
+func TestUpdateHelmChart(t *testing.T) {
+	cases := []struct {
+		doc, want string
+	}{
+		{`apiVersion: helm.cattle.io/v1
+kind: HelmChart
+metadata:
+  name: cert-manager
+spec:
+  repo: https://charts.jetstack.io
+  chart: cert-manager
+  version: v1.14.2`, "v1.15.0"},
+		{`apiVersion: helm.cattle.io/v1
+kind: HelmChart
+metadata:
+  name: traefik
+spec:
+  chart: https://%{KUBERNETES_API}%/static/charts/traefik-25.0.2.tgz
+  version: 25.0.2`, "25.0.2"},
+	}
+	for _, c := range cases {
+		rn := yaml.MustParse(c.doc)
+		if _, err := UpdateHelmChart(context.Background(), fakeHelmUpdater{latest: "v1.15.0"}).Filter(rn); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, _ := rn.GetString("spec.version"); got != c.want {
+			t.Errorf("%s: unexpected version %s, want %s", rn.GetName(), got, c.want)
+		}
+	}
+}
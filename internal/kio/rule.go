@@ -0,0 +1,288 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/utils"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/git"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/helm"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/plugin"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// PathRule updates the value at a YAML path in files matching a glob.
+type PathRule struct {
+	// Files is a glob matched against the relative path or base name.
+	Files string
+	// Path is the field path holding the value.
+	Path []string
+	// Regex extracts the version through its "version" named group.
+	Regex *regexp.Regexp
+	// Resolver resolves the latest version.
+	Resolver VersionResolver
+}
+
+// MatchesFile reports whether the rule applies to the given relative path.
+func (r PathRule) MatchesFile(path string) bool {
+	if ok, _ := filepath.Match(r.Files, path); ok {
+		return true
+	}
+	ok, _ := filepath.Match(r.Files, filepath.Base(path))
+	return ok
+}
+
+// RuleSources holds the updaters rules resolve versions with.
+type RuleSources struct {
+	Image  update.Updater[*container.ImageRef]
+	GitHub update.Updater[*github.ActionRef]
+	Helm   update.Updater[*helm.ChartRef]
+	Git    update.Updater[*git.RepoRef]
+	Plugin update.Updater[*plugin.Request]
+}
+
+// ResolveHelmVersion resolves chart versions from a Helm repository.
+func ResolveHelmVersion(
+	u update.Updater[*helm.ChartRef],
+	repoURL, chart string,
+	opts ...update.Option,
+) VersionResolver {
+	return VersionResolverFunc(func(ctx context.Context, current string) (string, error) {
+		return u.Update(ctx, &helm.ChartRef{RepoURL: repoURL, Name: chart, Version: current}, opts...)
+	})
+}
+
+// ResolveGitTag resolves versions from the tags of a remote git repository.
+func ResolveGitTag(u update.Updater[*git.RepoRef], url string, opts ...update.Option) VersionResolver {
+	return VersionResolverFunc(func(ctx context.Context, current string) (string, error) {
+		return u.Update(ctx, &git.RepoRef{URL: url, Version: current}, opts...)
+	})
+}
+
+// ResolvePlugin resolves versions from the source plugin named by the rule
+// source.
+func ResolvePlugin(u update.Updater[*plugin.Request], r config.Rule, opts ...update.Option) VersionResolver {
+	name := r.Repository
+	if r.Image != "" {
+		name = r.Image
+	} else if r.Chart != "" {
+		name = r.Chart
+	}
+	return VersionResolverFunc(func(ctx context.Context, current string) (string, error) {
+		return u.Update(ctx, &plugin.Request{
+			Source:     r.Source,
+			Name:       name,
+			Repository: r.Repository,
+			Image:      r.Image,
+			Chart:      r.Chart,
+			Version:    current,
+		}, opts...)
+	})
+}
+
+// NewPathRule builds a PathRule from its config declaration.
+func NewPathRule(r config.Rule, s RuleSources) (PathRule, error) {
+	if r.Files == "" || r.Path == "" {
+		return PathRule{}, fmt.Errorf("rule requires files and path")
+	}
+	if _, err := filepath.Match(r.Files, ""); err != nil {
+		return PathRule{}, fmt.Errorf("invalid files glob %q: %w", r.Files, err)
+	}
+	rule := PathRule{
+		Files: r.Files,
+		Path:  utils.SmarterPathSplitter(r.Path, "."),
+	}
+	if r.Regex != "" {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return PathRule{}, fmt.Errorf("invalid regex %q: %w", r.Regex, err)
+		}
+		if re.SubexpIndex("version") < 0 {
+			return PathRule{}, fmt.Errorf("regex %q has no version group", r.Regex)
+		}
+		rule.Regex = re
+	}
+	resolver, err := NewRuleResolver(r, s)
+	if err != nil {
+		return PathRule{}, err
+	}
+	rule.Resolver = resolver
+	return rule, nil
+}
+
+// NewRuleResolver builds the resolver of the source declared by a rule.
+func NewRuleResolver(r config.Rule, s RuleSources) (VersionResolver, error) {
+	ordering, err := update.ParseOrdering(r.Ordering)
+	if err != nil {
+		return nil, err
+	}
+	opts := []update.Option{update.WithOrdering(ordering)}
+	switch r.Source {
+	case "image":
+		if r.Image == "" {
+			return nil, fmt.Errorf("image source requires image")
+		}
+		return ResolveImageTag(s.Image, r.Image, opts...), nil
+	case "github":
+		owner, repo, ok := strings.Cut(r.Repository, "/")
+		if !ok || owner == "" || repo == "" {
+			return nil, fmt.Errorf("github source requires owner/repo repository")
+		}
+		return ResolveGitHubTag(s.GitHub, owner, repo, opts...), nil
+	case "helm":
+		if r.Repository == "" || r.Chart == "" {
+			return nil, fmt.Errorf("helm source requires repository and chart")
+		}
+		return ResolveHelmVersion(s.Helm, r.Repository, r.Chart, opts...), nil
+	case "git":
+		if r.Repository == "" {
+			return nil, fmt.Errorf("git source requires repository")
+		}
+		return ResolveGitTag(s.Git, r.Repository, opts...), nil
+	default:
+		if _, err := plugin.LookupSource(r.Source); err != nil {
+			return nil, fmt.Errorf("unknown source %q: no %s%s plugin on PATH", r.Source, plugin.SourcePrefix, r.Source)
+		}
+		return ResolvePlugin(s.Plugin, r, opts...), nil
+	}
+}
+
+// NewPathRules builds PathRules from their config declarations.
+func NewPathRules(rules []config.Rule, s RuleSources) ([]PathRule, error) {
+	out := make([]PathRule, 0, len(rules))
+	for i, r := range rules {
+		rule, err := NewPathRule(r, s)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		out = append(out, rule)
+	}
+	return out, nil
+}
+
+// UpdatePathRules builds a pipeline that applies path rules to the YAML files
+// under path. Only files matched by a rule are written back.
+func UpdatePathRules(ctx context.Context, rules []PathRule, path string) kio.Pipeline {
+	ctx = policy.WithDir(ctx, path)
+	return kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{
+				PackagePath:    path,
+				MatchFilesGlob: []string{"*.yaml", "*.yml"},
+			},
+		},
+		Filters: []kio.Filter{
+			UpdatePathRulesValues(ctx, rules),
+		},
+		Outputs: []kio.Writer{
+			newPackageWriter(ctx, path),
+		},
+	}
+}
+
+// UpdatePathRulesValues applies path rules across the loaded files, dropping
+// resources from files no rule matches.
+func UpdatePathRulesValues(ctx context.Context, rules []PathRule) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		var matched []*yaml.RNode
+		g := errgroup.Group{}
+		for _, node := range nodes {
+			p, _, err := kioutil.GetFileAnnotations(node)
+			if err != nil {
+				return nil, fmt.Errorf("get file annotations: %w", err)
+			}
+			var fileRules []PathRule
+			for _, r := range rules {
+				if r.MatchesFile(p) {
+					fileRules = append(fileRules, r)
+				}
+			}
+			if len(fileRules) == 0 {
+				continue
+			}
+			matched = append(matched, node)
+			g.Go(func() error {
+				if err := node.PipeE(UpdatePathRulesNode(withFile(ctx, node), fileRules)); err != nil {
+					slog.WarnContext(ctx, "rule update failed", logging.Failed.Attr(), "path", p, "err", err)
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+		return matched, nil
+	})
+}
+
+// UpdatePathRulesNode applies path rules to one resource.
+func UpdatePathRulesNode(ctx context.Context, rules []PathRule) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		for _, rule := range rules {
+			fieldNode, err := node.Pipe(yaml.Lookup(rule.Path...))
+			if err != nil {
+				return nil, fmt.Errorf("lookup %s: %w", strings.Join(rule.Path, "."), err)
+			}
+			value := yaml.GetValue(fieldNode)
+			if value == "" {
+				continue
+			}
+			start, end := 0, len(value)
+			if rule.Regex != nil {
+				m := rule.Regex.FindStringSubmatchIndex(value)
+				idx := rule.Regex.SubexpIndex("version")
+				if m == nil || m[2*idx] < 0 {
+					continue
+				}
+				start, end = m[2*idx], m[2*idx+1]
+			}
+			current := value[start:end]
+			latest, err := rule.Resolver.Resolve(atNode(ctx, fieldNode.YNode()), current)
+			if err != nil {
+				return nil, fmt.Errorf("resolve %s: %w", strings.Join(rule.Path, "."), err)
+			}
+			if latest == "" || latest == current {
+				continue
+			}
+			next := value[:start] + latest + value[end:]
+			if isK0sctlConfig(node) && strings.Join(rule.Path, ".") == "spec.k0s.version" {
+				host, unsupported, err := unsupportedK0sctlHost(ctx, node, next)
+				if err != nil {
+					return nil, err
+				}
+				if unsupported {
+					slog.InfoContext(ctx, "incompatible k0s version rejected update", logging.Incompatible.Attr(),
+						"name", "k0s", "from", value, "to", next, "file", policy.File(ctx),
+						"host", host.Name, "os", host.OS, "arch", host.Arch)
+					continue
+				}
+			}
+			fieldNode.YNode().Value = next
+			slog.InfoContext(
+				ctx,
+				"updated rule value",
+				"path",
+				strings.Join(rule.Path, "."),
+				"from",
+				current,
+				"to",
+				latest,
+			)
+		}
+		return node, nil
+	})
+}
@@ -0,0 +1,64 @@
+package kio
+
+import (
+	"context"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/config"
+)
+
+func TestUpdatePathRulesNode_Regex(t *testing.T) {
+	rule, err := NewPathRule(config.Rule{
+		Files:  "values.yaml",
+		Path:   "controller.image",
+		Source: "image",
+		Image:  "quay.io/jetstack/cert-manager-controller",
+		Regex:  `:(?P<version>v[0-9.]+)$`,
+	}, RuleSources{Image: fakeImageUpdater{latest: "v1.15.0"}})
+	if err != nil {
+		t.Fatalf("new rule: %v", err)
+	}
+	rn := yaml.MustParse(`controller:
+  image: quay.io/jetstack/cert-manager-controller:v1.14.2`)
+	_, err = UpdatePathRulesNode(context.Background(), []PathRule{rule}).Filter(rn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	imageNode, err := rn.Pipe(yaml.Lookup("controller", "image"))
+	if err != nil {
+		t.Fatalf("lookup image: %v", err)
+	}
+	if got := yaml.GetValue(imageNode); got != "quay.io/jetstack/cert-manager-controller:v1.15.0" {
+		t.Fatalf("unexpected image: %s", got)
+	}
+}
+
+func TestNewPathRule_Invalid(t *testing.T) {
+	cases := []config.Rule{
+		{Files: "*.yaml", Path: "spec.version", Source: "unknown"},
+		{Files: "*.yaml", Path: "spec.version", Source: "github", Repository: "kafka"},
+		{Files: "*.yaml", Path: "spec.version", Source: "git", Repository: "x", Regex: "v(.*)"},
+		{Path: "spec.version", Source: "git", Repository: "x"},
+	}
+	for _, c := range cases {
+		if _, err := NewPathRule(c, RuleSources{}); err == nil {
+			t.Fatalf("expected error for %+v", c)
+		}
+	}
+}
+
+func TestPathRule_MatchesFile(t *testing.T) {
+	r := PathRule{Files: "certificate.yaml"}
+	if !r.MatchesFile("infra/cert/certificate.yaml") {
+		t.Fatalf("expected base name match")
+	}
+	r = PathRule{Files: "infra/*/values.yaml"}
+	if !r.MatchesFile("infra/cert/values.yaml") {
+		t.Fatalf("expected relative path match")
+	}
+	if r.MatchesFile("apps/cert/values.yaml") {
+		t.Fatalf("unexpected match")
+	}
+}
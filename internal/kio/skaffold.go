@@ -0,0 +1,236 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/helm"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// UpdateSkaffoldConfigs builds a pipeline that updates artifact base images,
+// Helm release chart versions and templated image values of skaffold.yaml.
+func UpdateSkaffoldConfigs(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	hu update.Updater[*helm.ChartRef],
+	path string,
+) kio.Pipeline {
+	ctx = policy.WithDir(ctx, path)
+	return kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{
+				PackagePath:    path,
+				MatchFilesGlob: []string{"skaffold.yaml", "skaffold.yml"},
+			},
+		},
+		Filters: []kio.Filter{
+			UpdateSkaffoldConfigsImages(ctx, cu, hu),
+		},
+		Outputs: []kio.Writer{
+			newPackageWriter(ctx, path),
+		},
+	}
+}
+
+// UpdateSkaffoldConfigsImages runs image and chart updates across skaffold
+// configs.
+func UpdateSkaffoldConfigsImages(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	hu update.Updater[*helm.ChartRef],
+) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		g := errgroup.Group{}
+		for _, node := range nodes {
+			g.Go(func() error {
+				if err := node.PipeE(UpdateSkaffoldConfig(withFile(ctx, node), cu, hu)); err != nil {
+					return err
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+		return nodes, nil
+	})
+}
+
+// UpdateSkaffoldConfig updates one skaffold config and each of its profiles.
+func UpdateSkaffoldConfig(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	hu update.Updater[*helm.ChartRef],
+) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		if err := node.PipeE(UpdateSkaffoldPipeline(ctx, cu, hu)); err != nil {
+			return nil, err
+		}
+		profilesNode, err := node.Pipe(yaml.Lookup("profiles"))
+		if err != nil {
+			return nil, fmt.Errorf("lookup profiles: %w", err)
+		}
+		profiles, err := profilesNode.Elements()
+		if err != nil {
+			return nil, fmt.Errorf("get profiles elements: %w", err)
+		}
+		for _, p := range profiles {
+			if err := p.PipeE(UpdateSkaffoldPipeline(ctx, cu, hu)); err != nil {
+				return nil, err
+			}
+		}
+		return node, nil
+	})
+}
+
+// UpdateSkaffoldPipeline updates the build and deploy sections of a config or
+// profile.
+func UpdateSkaffoldPipeline(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	hu update.Updater[*helm.ChartRef],
+) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		artifactsNode, err := node.Pipe(yaml.Lookup("build", "artifacts"))
+		if err != nil {
+			return nil, fmt.Errorf("lookup build artifacts: %w", err)
+		}
+		artifacts, err := artifactsNode.Elements()
+		if err != nil {
+			return nil, fmt.Errorf("get artifacts elements: %w", err)
+		}
+		for _, a := range artifacts {
+			for _, path := range [][]string{
+				{"image"},
+				{"ko", "fromImage"},
+				{"jib", "fromImage"},
+				{"buildpacks", "builder"},
+				{"buildpacks", "runImage"},
+			} {
+				if err := updateSkaffoldImage(ctx, cu, a, path); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		// Skaffold v2 declares Helm releases under manifests, v1 under deploy.
+		for _, section := range []string{"deploy", "manifests"} {
+			releasesNode, err := node.Pipe(yaml.Lookup(section, "helm", "releases"))
+			if err != nil {
+				return nil, fmt.Errorf("lookup %s helm releases: %w", section, err)
+			}
+			releases, err := releasesNode.Elements()
+			if err != nil {
+				return nil, fmt.Errorf("get releases elements: %w", err)
+			}
+			for _, r := range releases {
+				if err := r.PipeE(UpdateSkaffoldRelease(ctx, cu, hu)); err != nil {
+					slog.WarnContext(ctx, "release update failed", logging.Failed.Attr(), "err", err)
+				}
+			}
+		}
+		return node, nil
+	})
+}
+
+// UpdateSkaffoldRelease updates the chart version and the templated image
+// values of one Helm release.
+func UpdateSkaffoldRelease(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	hu update.Updater[*helm.ChartRef],
+) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		templatesNode, err := node.Pipe(yaml.Lookup("setValueTemplates"))
+		if err != nil {
+			return nil, fmt.Errorf("lookup setValueTemplates: %w", err)
+		}
+		if templatesNode != nil {
+			if err := templatesNode.VisitFields(func(f *yaml.MapNode) error {
+				if f.Value.YNode().Kind != yaml.ScalarNode {
+					return nil
+				}
+				return updateSkaffoldImage(ctx, cu, f.Value, nil)
+			}); err != nil {
+				return nil, err
+			}
+		}
+
+		versionNode, err := node.Pipe(yaml.Get("chartVersion"))
+		if err != nil {
+			return nil, fmt.Errorf("get chartVersion: %w", err)
+		}
+		version := yaml.GetValue(versionNode)
+		if version == "" {
+			return node, nil
+		}
+		chartNode, err := node.Pipe(yaml.Get("remoteChart"))
+		if err != nil {
+			return nil, fmt.Errorf("get remoteChart: %w", err)
+		}
+		repoNode, err := node.Pipe(yaml.Get("repo"))
+		if err != nil {
+			return nil, fmt.Errorf("get repo: %w", err)
+		}
+		chart, repoURL := yaml.GetValue(chartNode), yaml.GetValue(repoNode)
+		if chart == "" || repoURL == "" {
+			return node, nil
+		}
+		if i := strings.LastIndex(chart, "/"); i >= 0 {
+			chart = chart[i+1:]
+		}
+		latest, err := hu.Update(
+			atNode(ctx, versionNode.YNode()), &helm.ChartRef{RepoURL: repoURL, Name: chart, Version: version},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("find latest chart version: %w", err)
+		}
+		if latest == "" || latest == version {
+			return node, nil
+		}
+		versionNode.YNode().Value = latest
+		recordChartBump(ctx, helm.ChartRef{RepoURL: repoURL, Name: chart, Version: latest})
+		slog.InfoContext(ctx, "updated chart version", "name", chart, "from", version, "to", latest)
+		return node, nil
+	})
+}
+
+func updateSkaffoldImage(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	node *yaml.RNode,
+	path []string,
+) error {
+	imageNode := node
+	if len(path) > 0 {
+		var err error
+		imageNode, err = node.Pipe(yaml.Lookup(path...))
+		if err != nil {
+			return fmt.Errorf("lookup %s: %w", strings.Join(path, "."), err)
+		}
+	}
+	current := yaml.GetValue(imageNode)
+	if current == "" || strings.Contains(current, "{{") {
+		return nil
+	}
+	latest, err := ResolveImage(cu).Resolve(atNode(ctx, imageNode.YNode()), current)
+	if err != nil {
+		return fmt.Errorf("resolve image %s: %w", current, err)
+	}
+	if latest == "" {
+		return nil
+	}
+	imageNode.YNode().Value = latest
+	slog.InfoContext(ctx, "updated skaffold image", "from", current, "to", latest)
+	return nil
+}
@@ -0,0 +1,67 @@
+package kio
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/helm"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+type fakeChartUpdater struct {
+	latest string
+}
+
+func (f fakeChartUpdater) Update(
+	_ context.Context,
+	_ *helm.ChartRef,
+	_ ...update.Option,
+) (string, error) {
+	return f.latest, nil
+}
+
+func TestUpdateSkaffoldConfig(t *testing.T) {
+	doc := `apiVersion: skaffold/v4beta6
+kind: Config
+build:
+  artifacts:
+  - image: app
+    ko:
+      fromImage: gcr.io/distroless/static:1.0
+profiles:
+- name: dev
+  deploy:
+    helm:
+      releases:
+      - name: redis
+        remoteChart: bitnami/redis
+        repo: https://charts.bitnami.com/bitnami
+        chartVersion: 18.0.0
+        setValueTemplates:
+          image: "{{.IMAGE_FULLY_QUALIFIED_app}}"
+          sidecar: busybox:1.0`
+	rn := yaml.MustParse(doc)
+	_, err := UpdateSkaffoldConfig(
+		context.Background(),
+		fakeImageUpdater{latest: "2.0"},
+		fakeChartUpdater{latest: "19.1.0"},
+	).Filter(rn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := rn.MustString()
+	for _, want := range []string{
+		"- image: app\n",
+		"fromImage: gcr.io/distroless/static:2.0",
+		"chartVersion: 19.1.0",
+		"sidecar: busybox:2.0",
+		"{{.IMAGE_FULLY_QUALIFIED_app}}",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in:\n%s", want, out)
+		}
+	}
+}
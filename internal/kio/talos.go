@@ -0,0 +1,202 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// TalosComponentImages are the fields of a Talos machine config holding the
+// image of a Kubernetes component, whose tag is the Kubernetes version.
+var TalosComponentImages = [][]string{
+	{"machine", "kubelet", "image"},
+	{"cluster", "apiServer", "image"},
+	{"cluster", "controllerManager", "image"},
+	{"cluster", "scheduler", "image"},
+	{"cluster", "proxy", "image"},
+}
+
+// UpdateTalosConfigs builds a pipeline that updates the Talos machine configs
+// and Omni cluster templates under path. Only files holding one of them are
+// written back.
+func UpdateTalosConfigs(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	gu update.Updater[*github.ActionRef],
+	path string,
+) kio.Pipeline {
+	ctx = policy.WithDir(ctx, path)
+	return kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{
+				PackagePath:    path,
+				MatchFilesGlob: []string{"*.yaml", "*.yml"},
+			},
+		},
+		Filters: []kio.Filter{
+			UpdateTalosConfigsVersions(ctx, cu, gu),
+		},
+		Outputs: []kio.Writer{
+			newPackageWriter(ctx, path),
+		},
+	}
+}
+
+// IsTalosConfig reports whether the document is a Talos machine config.
+func IsTalosConfig(node *yaml.RNode) bool {
+	version, err := node.Pipe(yaml.Get("version"))
+	if err != nil || yaml.GetValue(version) != "v1alpha1" {
+		return false
+	}
+	return node.Field("machine") != nil || node.Field("cluster") != nil
+}
+
+// IsOmniCluster reports whether the document is the cluster of an Omni
+// cluster template.
+func IsOmniCluster(node *yaml.RNode) bool {
+	return node.GetApiVersion() == "" && node.GetKind() == "Cluster" &&
+		(node.Field("talos") != nil || node.Field("kubernetes") != nil)
+}
+
+// UpdateTalosConfigsVersions updates the Talos documents across the loaded
+// files, dropping the files holding none.
+func UpdateTalosConfigsVersions(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	gu update.Updater[*github.ActionRef],
+) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		matched, err := KeepFilesWith(nodes, func(node *yaml.RNode) bool {
+			return IsTalosConfig(node) || IsOmniCluster(node)
+		})
+		if err != nil {
+			return nil, err
+		}
+		g := errgroup.Group{}
+		for _, node := range matched {
+			g.Go(func() error {
+				ctx := withFile(ctx, node)
+				if err := node.PipeE(UpdateTalosConfig(ctx, cu, gu)); err != nil {
+					slog.WarnContext(ctx, "talos config update failed", logging.Failed.Attr(), "path", policy.File(ctx), "err", err)
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+		return matched, nil
+	})
+}
+
+// UpdateTalosConfig updates one Talos document. In machine configs, the
+// installer follows the Talos releases while system extensions and
+// Kubernetes components follow the tags of their images. In Omni cluster
+// templates, the Talos and Kubernetes versions follow their releases. Other
+// documents are left alone.
+func UpdateTalosConfig(
+	ctx context.Context,
+	cu update.Updater[*container.ImageRef],
+	gu update.Updater[*github.ActionRef],
+) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		talos := ResolveGitHubTag(gu, "siderolabs", "talos")
+		switch {
+		case IsTalosConfig(node):
+			installer := []string{"machine", "install", "image"}
+			if err := updateTalosField(ctx, node, installer, resolveImageRelease(talos)); err != nil {
+				return nil, err
+			}
+			extensions, err := node.Pipe(yaml.Lookup("machine", "install", "extensions"))
+			if err != nil {
+				return nil, fmt.Errorf("lookup extensions: %w", err)
+			}
+			if extensions != nil {
+				elems, err := extensions.Elements()
+				if err != nil {
+					return nil, fmt.Errorf("extensions: %w", err)
+				}
+				for i := range elems {
+					path := []string{"machine", "install", "extensions", strconv.Itoa(i), "image"}
+					if err := updateTalosField(ctx, node, path, ResolveImage(cu)); err != nil {
+						return nil, err
+					}
+				}
+			}
+			for _, path := range TalosComponentImages {
+				if err := updateTalosField(ctx, node, path, ResolveImage(cu)); err != nil {
+					return nil, err
+				}
+			}
+		case IsOmniCluster(node):
+			if err := updateTalosField(ctx, node, []string{"talos", "version"}, talos); err != nil {
+				return nil, err
+			}
+			kubernetes := ResolveGitHubTag(gu, "kubernetes", "kubernetes")
+			if err := updateTalosField(ctx, node, []string{"kubernetes", "version"}, kubernetes); err != nil {
+				return nil, err
+			}
+		}
+		return node, nil
+	})
+}
+
+// updateTalosField resolves and rewrites the value at path.
+func updateTalosField(ctx context.Context, node *yaml.RNode, path []string, r VersionResolver) error {
+	field := strings.Join(path, ".")
+	fieldNode, err := node.Pipe(yaml.Lookup(path...))
+	if err != nil {
+		return fmt.Errorf("lookup %s: %w", field, err)
+	}
+	current := yaml.GetValue(fieldNode)
+	if current == "" {
+		return nil
+	}
+	latest, err := r.Resolve(atNode(ctx, fieldNode.YNode()), current)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", field, err)
+	}
+
+	if latest == "" || latest == current {
+		return nil
+	}
+	fieldNode.YNode().Value = latest
+	slog.InfoContext(ctx, "updated talos config", "field", field, "from", current, "to", latest)
+	return nil
+}
+
+// resolveImageRelease resolves image references whose tag follows the
+// releases resolved by r, e.g. Talos installers, including those of the image
+// factory, following the Talos releases. Untagged and digest-pinned
+// references are left alone.
+func resolveImageRelease(r VersionResolver) VersionResolver {
+	return VersionResolverFunc(func(ctx context.Context, current string) (string, error) {
+		if strings.Contains(current, "@") || !hasExplicitTag(current) {
+			return "", nil
+		}
+		ref, err := container.ParseImageRef(current)
+		if err != nil {
+			return "", fmt.Errorf("parse image ref %s: %w", current, err)
+		}
+		latest, err := r.Resolve(ctx, ref.Tag)
+		if err != nil {
+			return "", err
+		}
+		if latest == "" || latest == ref.Tag {
+			return "", nil
+		}
+		return strings.TrimSuffix(current, ":"+ref.Tag) + ":" + latest, nil
+	})
+}
@@ -0,0 +1,107 @@
+package kio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestUpdateTalosConfig_MachineConfig(t *testing.T) {
+	rn := yaml.MustParse(`version: v1alpha1
+machine:
+  install:
+    image: factory.talos.dev/installer/376567988ad3:v1.7.0
+    extensions:
+    - image: ghcr.io/siderolabs/gvisor:20231214.0-v1.7.0
+  kubelet:
+    image: ghcr.io/siderolabs/kubelet:v1.30.0
+cluster:
+  apiServer:
+    image: registry.k8s.io/kube-apiserver:v1.30.0`)
+	_, err := UpdateTalosConfig(
+		context.Background(),
+		fakeImageUpdater{latest: "v1.30.2"},
+		fakeUpdater{latest: "v1.7.5"},
+	).Filter(rn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for path, want := range map[string]string{
+		"machine.install.image":              "factory.talos.dev/installer/376567988ad3:v1.7.5",
+		"machine.install.extensions.0.image": "ghcr.io/siderolabs/gvisor:v1.30.2",
+		"machine.kubelet.image":              "ghcr.io/siderolabs/kubelet:v1.30.2",
+		"cluster.apiServer.image":            "registry.k8s.io/kube-apiserver:v1.30.2",
+	} {
+		n, err := rn.Pipe(yaml.Lookup(strings.Split(path, ".")...))
+		if err != nil {
+			t.Fatalf("lookup %s: %v", path, err)
+		}
+		if got := yaml.GetValue(n); got != want {
+			t.Errorf("%s = %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestUpdateTalosConfig_OmniCluster(t *testing.T) {
+	rn := yaml.MustParse(`kind: Cluster
+name: homelab
+kubernetes:
+  version: v1.30.0
+talos:
+  version: v1.7.0`)
+	_, err := UpdateTalosConfig(
+		context.Background(),
+		fakeImageUpdater{},
+		fakeUpdater{latest: "v1.31.0"},
+	).Filter(rn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, path := range [][]string{{"kubernetes", "version"}, {"talos", "version"}} {
+		n, err := rn.Pipe(yaml.Lookup(path...))
+		if err != nil {
+			t.Fatalf("lookup %v: %v", path, err)
+		}
+		if got := yaml.GetValue(n); got != "v1.31.0" {
+			t.Errorf("%v = %s, want v1.31.0", path, got)
+		}
+	}
+}
+
+func TestUpdateTalosConfigs_SkipsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	talos := "version: v1alpha1\nmachine:\n  install:\n    image: ghcr.io/siderolabs/installer:v1.7.0\n"
+	other := "# keep\nversion: v1alpha1\nname:   app\n"
+	for name, data := range map[string]string{"controlplane.yaml": talos, "other.yaml": other} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := UpdateTalosConfigs(
+		context.Background(),
+		fakeImageUpdater{},
+		fakeUpdater{latest: "v1.7.5"},
+		dir,
+	).Execute()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "controlplane.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "ghcr.io/siderolabs/installer:v1.7.5") {
+		t.Errorf("installer not updated:\n%s", got)
+	}
+	got, err = os.ReadFile(filepath.Join(dir, "other.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != other {
+		t.Errorf("other file rewritten:\n%s", got)
+	}
+}
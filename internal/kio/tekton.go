@@ -0,0 +1,174 @@
+package kio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// TektonGroup is the API group of Tekton pipeline resources.
+const TektonGroup = "tekton.dev"
+
+// IsTektonResource reports whether the node is a Tekton pipeline resource.
+func IsTektonResource(node *yaml.RNode) bool {
+	group, _, _ := strings.Cut(node.GetApiVersion(), "/")
+	return group == TektonGroup
+}
+
+// UpdateTektonResources builds a pipeline that updates step images and OCI
+// bundle references of the Tekton resources under path.
+func UpdateTektonResources(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+	path string,
+) kio.Pipeline {
+	ctx = policy.WithDir(ctx, path)
+	return kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{
+				PackagePath:    path,
+				MatchFilesGlob: []string{"*.yaml", "*.yml"},
+			},
+		},
+		Filters: []kio.Filter{
+			UpdateTektonResourcesImages(ctx, u),
+		},
+		Outputs: []kio.Writer{
+			newPackageWriter(ctx, path),
+		},
+	}
+}
+
+// UpdateTektonResourcesImages runs image updates across Tekton resources,
+// dropping files without any.
+func UpdateTektonResourcesImages(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		g := errgroup.Group{}
+		for _, node := range nodes {
+			if !IsTektonResource(node) {
+				continue
+			}
+			g.Go(func() error {
+				if err := node.PipeE(UpdateTektonResource(withFile(ctx, node), u)); err != nil {
+					slog.WarnContext(ctx, "tekton update failed", logging.Failed.Attr(), "name", node.GetName(), "err", err)
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+		return KeepFilesWith(nodes, IsTektonResource)
+	})
+}
+
+// UpdateTektonResource updates the images of steps, step templates and
+// sidecars, and the bundles of task and pipeline references, at any depth of
+// one Tekton resource so embedded specs are covered.
+func UpdateTektonResource(
+	ctx context.Context,
+	u update.Updater[*container.ImageRef],
+) yaml.Filter {
+	return yaml.FilterFunc(func(node *yaml.RNode) (*yaml.RNode, error) {
+		if err := updateTektonNode(ctx, ResolveImage(u), node.YNode()); err != nil {
+			return nil, err
+		}
+		return node, nil
+	})
+}
+
+func updateTektonNode(ctx context.Context, r VersionResolver, n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			if err := updateTektonNode(ctx, r, c); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i].Value, n.Content[i+1]
+			switch key {
+			case "steps", "sidecars":
+				for _, c := range value.Content {
+					if err := updateTektonField(ctx, r, c, "image"); err != nil {
+						return err
+					}
+				}
+			case "stepTemplate":
+				if err := updateTektonField(ctx, r, value, "image"); err != nil {
+					return err
+				}
+			case "taskRef", "pipelineRef":
+				if err := updateTektonField(ctx, r, value, "bundle"); err != nil {
+					return err
+				}
+				if err := updateTektonBundleParam(ctx, r, value); err != nil {
+					return err
+				}
+			}
+			if err := updateTektonNode(ctx, r, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// updateTektonBundleParam updates the bundle param of a bundles resolver
+// reference.
+func updateTektonBundleParam(ctx context.Context, r VersionResolver, ref *yaml.Node) error {
+	rn := yaml.NewRNode(ref)
+	resolverNode, err := rn.Pipe(yaml.Get("resolver"))
+	if err != nil {
+		return fmt.Errorf("get resolver: %w", err)
+	}
+	if yaml.GetValue(resolverNode) != "bundles" {
+		return nil
+	}
+	paramNode, err := rn.Pipe(yaml.Lookup("params", "[name=bundle]"))
+	if err != nil {
+		return fmt.Errorf("lookup bundle param: %w", err)
+	}
+	if paramNode == nil {
+		return nil
+	}
+	return updateTektonField(ctx, r, paramNode.YNode(), "value")
+}
+
+func updateTektonField(ctx context.Context, r VersionResolver, n *yaml.Node, field string) error {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	fieldNode, err := yaml.NewRNode(n).Pipe(yaml.Get(field))
+	if err != nil {
+		return fmt.Errorf("get %s: %w", field, err)
+	}
+	current := yaml.GetValue(fieldNode)
+	if current == "" || strings.Contains(current, "$(") {
+		return nil
+	}
+	latest, err := r.Resolve(atNode(ctx, fieldNode.YNode()), current)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", current, err)
+	}
+	if latest == "" || latest == current {
+		return nil
+	}
+	fieldNode.YNode().Value = latest
+	slog.InfoContext(ctx, "updated tekton reference", "field", field, "from", current, "to", latest)
+	return nil
+}
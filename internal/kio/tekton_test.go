@@ -0,0 +1,47 @@
+package kio
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestUpdateTektonResource(t *testing.T) {
+	doc := `apiVersion: tekton.dev/v1
+kind: Pipeline
+metadata:
+  name: build
+spec:
+  tasks:
+  - name: clone
+    taskRef:
+      resolver: bundles
+      params:
+      - name: bundle
+        value: ghcr.io/tektoncd/catalog/git-clone:0.9
+      - name: name
+        value: git-clone
+  - name: test
+    taskSpec:
+      stepTemplate:
+        image: golang:0.9
+      steps:
+      - name: unit
+        image: golang:0.9
+      - name: param
+        image: $(params.image)`
+	rn := yaml.MustParse(doc)
+	_, err := UpdateTektonResource(context.Background(), fakeImageUpdater{latest: "1.0"}).Filter(rn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := rn.MustString()
+	if strings.Count(out, ":1.0") != 3 {
+		t.Fatalf("expected three bumped references, got:\n%s", out)
+	}
+	if !strings.Contains(out, "image: $(params.image)") {
+		t.Fatalf("expected param reference to be preserved, got:\n%s", out)
+	}
+}
@@ -0,0 +1,78 @@
+package kio
+
+import (
+	"context"
+	"os"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+
+	"github.com/shikanime-studio/automata/internal/toolversion"
+)
+
+// TreeConfig configures the pipelines updating every format of a tree.
+type TreeConfig struct {
+	// Sources are the updaters versions are resolved with.
+	Sources RuleSources
+	// Inputs are the tools whose versions workflow inputs pin.
+	Inputs map[string]toolversion.Tool
+	// Rules are the path rules of the config.
+	Rules []PathRule
+	// Bundles are the OPA bundles of the config.
+	Bundles []OPABundle
+	// Actions are the workflow action configs by action name.
+	Actions map[string]ActionConfig
+	// Resources are the rules of the operator and kOps resources.
+	Resources []ResourceRule
+	// Rancher are the rules of the Rancher upgrade plans and clusters.
+	Rancher []ResourceRule
+}
+
+// TreePipelines returns the update pipelines of every manifest format over
+// path, leaving out those whose input directories do not exist, as the
+// workflow pipelines read .github/workflows.
+func TreePipelines(ctx context.Context, c TreeConfig, path string) []kio.Pipeline {
+	s := c.Sources
+	pipelines := []kio.Pipeline{
+		UpdateKustomization(ctx, s.Image, path),
+		UpdateK0sctlConfigs(ctx, s.Helm, path),
+		UpdateHelmCharts(ctx, s.Helm, path),
+		UpdateTalosConfigs(ctx, s.Image, s.GitHub, path),
+		UpdateGitHubWorkflows(ctx, s.GitHub, c.Actions, path),
+		UpdateGitHubWorkflowInputs(ctx, s.GitHub, c.Inputs, path),
+		UpdateSkaffoldConfigs(ctx, s.Image, s.Helm, path),
+		UpdateDronePipelines(ctx, s.Image, path),
+		UpdateTektonResources(ctx, s.Image, path),
+		UpdateCrossplanePackages(ctx, s.Image, path),
+		UpdatePolicyBundles(ctx, s.Image, s.GitHub, path),
+		UpdateOPAManifests(ctx, c.Bundles, s.Image, path),
+		UpdateDevContainers(ctx, s.Image, path),
+		UpdateComposeFiles(ctx, s.Image, path),
+		UpdatePathRules(ctx, c.Rules, path),
+	}
+	if len(c.Resources) > 0 {
+		pipelines = append(pipelines, UpdateResources(ctx, c.Resources, path))
+	}
+	if len(c.Rancher) > 0 {
+		pipelines = append(pipelines, UpdateRancherManifests(ctx, c.Rancher, s.Helm, path))
+	}
+	existing := pipelines[:0]
+	for _, p := range pipelines {
+		if pipelineInputsExist(p) {
+			existing = append(existing, p)
+		}
+	}
+	return existing
+}
+
+// pipelineInputsExist reports whether the directories a pipeline reads
+// exist.
+func pipelineInputsExist(p kio.Pipeline) bool {
+	for _, in := range p.Inputs {
+		if r, ok := in.(kio.LocalPackageReader); ok {
+			if _, err := os.Stat(r.PackagePath); err != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
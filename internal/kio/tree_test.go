@@ -0,0 +1,42 @@
+package kio
+
+import (
+	"context"
+	"flag"
+	"testing"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/helm"
+	"github.com/shikanime-studio/automata/internal/testsource"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden trees of testdata/fixtures")
+
+func TestTreePipelines_Golden(t *testing.T) {
+	ctx := context.Background()
+	tree := TreeConfig{Sources: RuleSources{
+		Image:  container.NewUpdater(),
+		GitHub: github.NewUpdater(github.NewTokenClient(ctx, "")),
+		Helm:   helm.NewUpdater(),
+	}}
+	mismatches, err := testsource.RunGolden(
+		ctx,
+		"../../testdata/fixtures",
+		*updateGolden,
+		func(ctx context.Context, dir string) error {
+			for _, p := range TreePipelines(ctx, tree, dir) {
+				if err := p.Execute(); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, m := range mismatches {
+		t.Errorf("%s", m)
+	}
+}
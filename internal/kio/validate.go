@@ -0,0 +1,411 @@
+package kio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/semver"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/fsutil"
+	"github.com/shikanime-studio/automata/internal/policy"
+)
+
+type validationKey struct{}
+
+// WithValidation returns a context enabling the optional checks of the files
+// rewritten by pipelines.
+func WithValidation(ctx context.Context, v config.Validation) context.Context {
+	return context.WithValue(ctx, validationKey{}, v)
+}
+
+// newFileSystem returns the file system pipelines write with: files are
+// written atomically and reverted when their new content fails validation.
+func newFileSystem(ctx context.Context) filesys.FileSystemOrOnDisk {
+	v, _ := ctx.Value(validationKey{}).(config.Validation)
+	return fsutil.NewAtomicFS(ctx, ValidateFile(ctx, v))
+}
+
+type fileEditsKey struct{}
+
+// fileEdits queues the rewrites of the files a pipeline edits besides the
+// resources it reads, such as the patch files of kustomizations, so that the
+// package writer writes, validates and reverts them with the package, and
+// none is written when a filter fails.
+type fileEdits struct {
+	mu    sync.Mutex
+	files map[string]*fileEdit
+}
+
+// fileEdit is the queued content of a file, the directory of the
+// kustomization reading it and the updates it applies.
+type fileEdit struct {
+	data      []byte
+	dir       string
+	proposals []policy.Proposal
+}
+
+// withFileEdits returns a context queueing file edits for the package writer
+// created from it.
+func withFileEdits(ctx context.Context) context.Context {
+	return context.WithValue(ctx, fileEditsKey{}, &fileEdits{files: map[string]*fileEdit{}})
+}
+
+// editFile applies edit to the queued content of the file at path, or to its
+// content on disk, and queues the result with the update it applies, for the
+// kustomization in dir. It reports false when the file does not exist.
+func editFile(ctx context.Context, path, dir string, p policy.Proposal, edit func([]byte) []byte) (bool, error) {
+	q, _ := ctx.Value(fileEditsKey{}).(*fileEdits)
+	if q == nil {
+		return false, fmt.Errorf("edit %s: no package writer to write it", path)
+	}
+	path = filepath.Clean(path)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	e, ok := q.files[path]
+	if !ok {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("read %s: %w", path, err)
+		}
+		e = &fileEdit{data: data, dir: dir}
+	}
+	updated := edit(e.data)
+	if bytes.Equal(updated, e.data) {
+		return true, nil
+	}
+	e.data = updated
+	e.proposals = append(e.proposals, p)
+	q.files[path] = e
+	return true, nil
+}
+
+// take returns the queued edits and empties the queue.
+func (q *fileEdits) take() map[string]*fileEdit {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	files := q.files
+	q.files = map[string]*fileEdit{}
+	return files
+}
+
+// packageWriter writes the resources of a package and the queued file edits
+// with the file system of newFileSystem, then builds the kustomizations of
+// the directories it changed when enabled. Kustomizations are built once the
+// whole package is written, as they read the other files of their directory.
+type packageWriter struct {
+	kio.LocalPackageWriter
+	ctx       context.Context
+	kustomize bool
+	edits     *fileEdits
+}
+
+// newPackageWriter returns the writer of the package at path.
+func newPackageWriter(ctx context.Context, path string) kio.Writer {
+	v, _ := ctx.Value(validationKey{}).(config.Validation)
+	edits, _ := ctx.Value(fileEditsKey{}).(*fileEdits)
+	return packageWriter{
+		LocalPackageWriter: kio.LocalPackageWriter{PackagePath: path, FileSystem: newFileSystem(ctx)},
+		ctx:                ctx,
+		kustomize:          v.Kustomize,
+		edits:              edits,
+	}
+}
+
+// writeEdits writes the queued file edits, dropping those it could not
+// write.
+func (w packageWriter) writeEdits(edits map[string]*fileEdit) error {
+	var errs []error
+	for _, path := range slices.Sorted(maps.Keys(edits)) {
+		if err := w.FileSystem.WriteFile(path, edits[path].data); err != nil {
+			errs = append(errs, err)
+			delete(edits, path)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// recordEdits records the updates of the written file edits.
+func (w packageWriter) recordEdits(edits map[string]*fileEdit) {
+	for _, path := range slices.Sorted(maps.Keys(edits)) {
+		ctx := policy.WithFile(policy.WithDir(w.ctx, ""), path)
+		for _, p := range edits[path].proposals {
+			policy.Record(ctx, p)
+		}
+	}
+}
+
+// snapshot is the content of a file before a package is written.
+type snapshot struct {
+	data    []byte
+	existed bool
+}
+
+// Write writes the resources and the queued file edits, then builds the
+// kustomizations of the directories whose files changed, reverting those
+// files when the build fails. Edited files are reverted with the
+// kustomization reading them.
+func (w packageWriter) Write(nodes []*yaml.RNode) error {
+	edits := w.edits.take()
+	if !w.kustomize {
+		if err := w.LocalPackageWriter.Write(nodes); err != nil {
+			return err
+		}
+		err := w.writeEdits(edits)
+		w.recordEdits(edits)
+		return err
+	}
+	before := map[string]snapshot{}
+	owners := map[string]string{}
+	for path, e := range edits {
+		data, err := os.ReadFile(path)
+		before[path] = snapshot{data: data, existed: err == nil}
+		owners[path] = e.dir
+	}
+	for _, node := range nodes {
+		rel, _, err := kioutil.GetFileAnnotations(node)
+		if err != nil || rel == "" {
+			continue
+		}
+		path := filepath.Join(w.PackagePath, rel)
+		if _, ok := before[path]; ok {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		before[path] = snapshot{data: data, existed: err == nil}
+	}
+	if err := w.LocalPackageWriter.Write(nodes); err != nil {
+		return err
+	}
+	var errs []error
+	if err := w.writeEdits(edits); err != nil {
+		errs = append(errs, err)
+	}
+	changed := map[string][]string{}
+	for path, s := range before {
+		data, err := os.ReadFile(path)
+		if err != nil || s.existed && bytes.Equal(data, s.data) {
+			continue
+		}
+		dir := filepath.Dir(path)
+		if owner, ok := owners[path]; ok {
+			dir = owner
+		}
+		changed[dir] = append(changed[dir], path)
+	}
+	for _, dir := range slices.Sorted(maps.Keys(changed)) {
+		if kustomizationFile(dir) == "" {
+			continue
+		}
+		buildErr := KustomizeBuild(w.ctx, dir)
+		if buildErr == nil {
+			continue
+		}
+		for _, path := range changed[dir] {
+			if err := revert(path, before[path]); err != nil {
+				buildErr = errors.Join(buildErr, fmt.Errorf("revert %s: %w", path, err))
+			}
+			delete(edits, path)
+		}
+		errs = append(errs, fmt.Errorf("invalid rewrite of %s, reverted: %w", dir, buildErr))
+	}
+	w.recordEdits(edits)
+	return errors.Join(errs...)
+}
+
+// revert restores the content of a file before a package was written.
+func revert(path string, s snapshot) error {
+	if !s.existed {
+		return os.Remove(path)
+	}
+	return fsutil.WriteFile(path, s.data, 0o644, false)
+}
+
+// kustomizationFile returns the path of the kustomization file of dir, or ""
+// when it has none.
+func kustomizationFile(dir string) string {
+	for _, name := range KustomizationFiles {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// ValidateFile returns the check of a rewritten file: its content must parse
+// again, and the enabled checks must pass, validating the structure of
+// GitHub workflows and k0sctl configurations. Kustomizations are built by
+// the package writer once the whole package is written.
+func ValidateFile(ctx context.Context, v config.Validation) fsutil.CheckFunc {
+	return func(path string, data []byte) error {
+		nodes, err := kio.FromBytes(data)
+		if err != nil {
+			return fmt.Errorf("parse: %w", err)
+		}
+		for _, node := range nodes {
+			if v.Workflows && isWorkflowFile(path) {
+				if err := ValidateWorkflow(node); err != nil {
+					return err
+				}
+			}
+			if v.K0sctl && isK0sctlConfig(node) {
+				if err := ValidateK0sctlConfig(node); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// KustomizeBuild builds the kustomization in dir with kustomize, or kubectl
+// when kustomize is not installed, discarding the output. Kustomizations
+// inflating Helm charts are built with Helm enabled.
+func KustomizeBuild(ctx context.Context, dir string) error {
+	name, args := "kustomize", []string{"build", dir}
+	if _, err := exec.LookPath(name); err != nil {
+		name, args = "kubectl", []string{"kustomize", dir}
+	}
+	if inflatesCharts(dir) {
+		args = append(args, "--enable-helm")
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("kustomize build %s: %w: %s", dir, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// isWorkflowFile reports whether path is a GitHub workflow.
+func isWorkflowFile(path string) bool {
+	return filepath.Base(filepath.Dir(path)) == "workflows" &&
+		filepath.Base(filepath.Dir(filepath.Dir(path))) == ".github"
+}
+
+// ValidateWorkflow checks the structure of a GitHub workflow: it declares its
+// triggers and jobs, each job runs on a runner or calls a reusable workflow,
+// and each step either uses an action or runs a command.
+func ValidateWorkflow(node *yaml.RNode) error {
+	if node.Field("on") == nil {
+		return errors.New("workflow has no on triggers")
+	}
+	jobs := node.Field("jobs")
+	if jobs == nil || jobs.Value.YNode().Kind != yaml.MappingNode {
+		return errors.New("workflow has no jobs mapping")
+	}
+	return jobs.Value.VisitFields(func(job *yaml.MapNode) error {
+		name := job.Key.YNode().Value
+		if job.Value.YNode().Kind != yaml.MappingNode {
+			return fmt.Errorf("job %s is not a mapping", name)
+		}
+		if job.Value.Field("runs-on") == nil && job.Value.Field("uses") == nil {
+			return fmt.Errorf("job %s has neither runs-on nor uses", name)
+		}
+		steps := job.Value.Field("steps")
+		if steps == nil {
+			return nil
+		}
+		elems, err := steps.Value.Elements()
+		if err != nil {
+			return fmt.Errorf("job %s steps: %w", name, err)
+		}
+		for i, step := range elems {
+			if (step.Field("uses") == nil) == (step.Field("run") == nil) {
+				return fmt.Errorf("job %s step %d needs exactly one of uses or run", name, i)
+			}
+		}
+		return nil
+	})
+}
+
+// isK0sctlConfig reports whether the resource is a k0sctl cluster
+// configuration.
+func isK0sctlConfig(node *yaml.RNode) bool {
+	return strings.HasPrefix(node.GetApiVersion(), "k0sctl.k0sproject.io/") && node.GetKind() == "Cluster"
+}
+
+// k0sctlRoles are the host roles k0sctl accepts.
+var k0sctlRoles = []string{"controller", "worker", "controller+worker", "single"}
+
+// ValidateK0sctlConfig checks a k0sctl configuration the way k0sctl does
+// before applying it: hosts have a known role, the k0s version is a semantic
+// version and Helm charts name their chart and version.
+func ValidateK0sctlConfig(node *yaml.RNode) error {
+	hosts, err := node.Pipe(yaml.Lookup("spec", "hosts"))
+	if err != nil {
+		return fmt.Errorf("lookup hosts: %w", err)
+	}
+	if hosts != nil {
+		elems, err := hosts.Elements()
+		if err != nil {
+			return fmt.Errorf("hosts: %w", err)
+		}
+		for i, host := range elems {
+			role, err := host.GetString("role")
+			if err != nil || !slices.Contains(k0sctlRoles, role) {
+				return fmt.Errorf("host %d has invalid role %q", i, role)
+			}
+		}
+	}
+	versionNode, err := node.Pipe(yaml.Lookup("spec", "k0s", "version"))
+	if err != nil {
+		return fmt.Errorf("lookup k0s version: %w", err)
+	}
+	if v := yaml.GetValue(versionNode); v != "" && !semver.IsValid("v"+strings.TrimPrefix(v, "v")) {
+		return fmt.Errorf("invalid k0s version %q", v)
+	}
+	charts, err := node.Pipe(
+		yaml.Lookup("spec", "k0s", "config", "spec", "extensions", "helm", "charts"),
+	)
+	if err != nil {
+		return fmt.Errorf("lookup charts: %w", err)
+	}
+	if charts == nil {
+		return nil
+	}
+	elems, err := charts.Elements()
+	if err != nil {
+		return fmt.Errorf("charts: %w", err)
+	}
+	for i, chart := range elems {
+		for _, field := range []string{"name", "chartname", "version"} {
+			if v, err := chart.GetString(field); err != nil || v == "" {
+				return fmt.Errorf("chart %d has no %s", i, field)
+			}
+		}
+	}
+	return nil
+}
+
+// inflatesCharts reports whether the kustomization of dir declares Helm
+// charts, which kustomize only inflates with Helm enabled.
+func inflatesCharts(dir string) bool {
+	path := kustomizationFile(dir)
+	if path == "" {
+		return false
+	}
+	node, err := yaml.ReadFile(path)
+	return err == nil && node.Field("helmCharts") != nil
+}
@@ -0,0 +1,170 @@
+package kio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/fsutil"
+	"github.com/shikanime-studio/automata/internal/policy"
+)
+
+func TestValidateWorkflow(t *testing.T) {
+	for doc, valid := range map[string]bool{
+		"'on': push\njobs:\n  build:\n    runs-on: ubuntu-latest\n    steps:\n      - uses: actions/checkout@v4\n      - run: make\n": true,
+		"'on': push\njobs:\n  release:\n    uses: org/repo/.github/workflows/release.yaml@v1\n":                                       true,
+		"jobs:\n  build:\n    runs-on: ubuntu-latest\n":                                                                               false,
+		"'on': push\njobs:\n  build:\n    steps:\n      - run: make\n":                                                                false,
+		"'on': push\njobs:\n  build:\n    runs-on: ubuntu-latest\n    steps:\n      - name: empty\n":                                  false,
+	} {
+		node, err := yaml.Parse(doc)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if err := ValidateWorkflow(node); (err == nil) != valid {
+			t.Fatalf("ValidateWorkflow(%q) = %v, want valid %v", doc, err, valid)
+		}
+	}
+}
+
+func TestValidateK0sctlConfig(t *testing.T) {
+	for doc, valid := range map[string]bool{
+		"spec:\n  hosts:\n    - role: controller\n  k0s:\n    version: v1.30.2+k0s.0\n": true,
+		"spec:\n  hosts:\n    - role: master\n":                                         false,
+		"spec:\n  k0s:\n    version: latest\n":                                          false,
+		"spec:\n  k0s:\n    config:\n      spec:\n        extensions:\n          helm:\n            charts:\n              - name: cilium\n                chartname: cilium/cilium\n": false,
+	} {
+		node, err := yaml.Parse(doc)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if err := ValidateK0sctlConfig(node); (err == nil) != valid {
+			t.Fatalf("ValidateK0sctlConfig(%q) = %v, want valid %v", doc, err, valid)
+		}
+	}
+}
+
+func TestFileSystemRevertsInvalidRewrites(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), ".github", "workflows")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	path := filepath.Join(dir, "ci.yaml")
+	valid := "'on': push\njobs:\n  build:\n    runs-on: ubuntu-latest\n"
+	if err := os.WriteFile(path, []byte(valid), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	ctx := WithValidation(context.Background(), config.Validation{Workflows: true})
+	fs := newFileSystem(ctx)
+	if err := fs.WriteFile(path, []byte("'on': push\njobs: {}\n  - broken\n")); err == nil {
+		t.Fatalf("expected unparsable content to be rejected")
+	}
+	if err := fs.WriteFile(path, []byte("jobs:\n  build:\n    runs-on: ubuntu-latest\n")); err == nil {
+		t.Fatalf("expected an invalid workflow to be rejected")
+	}
+	got, err := os.ReadFile(path)
+	if err != nil || string(got) != valid {
+		t.Fatalf("expected the file to be reverted, got %q: %v", got, err)
+	}
+	if _, err := os.Stat(path + fsutil.BackupSuffix); !os.IsNotExist(err) {
+		t.Fatalf("expected no backup without backups enabled, got %v", err)
+	}
+}
+
+func TestPackageWriterBuildsKustomizationsOnceWritten(t *testing.T) {
+	bin := t.TempDir()
+	log := filepath.Join(bin, "log")
+	script := "#!/bin/sh\necho \"$@\" >> " + log + "\n! grep -q broken \"$2/deploy.yaml\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "kustomize"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	dir := t.TempDir()
+	kustomization := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - deploy.yaml\nhelmCharts:\n  - name: app\n"
+	deploy := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\n"
+	for name, data := range map[string]string{"kustomization.yaml": kustomization, "deploy.yaml": deploy} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rename := func(name string) kio.Pipeline {
+		return kio.Pipeline{
+			Inputs: []kio.Reader{kio.LocalPackageReader{PackagePath: dir}},
+			Filters: []kio.Filter{kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+				for _, node := range nodes {
+					if err := node.PipeE(yaml.SetAnnotation("name", name)); err != nil {
+						return nil, err
+					}
+				}
+				return nodes, nil
+			})},
+			Outputs: []kio.Writer{newPackageWriter(
+				WithValidation(context.Background(), config.Validation{Kustomize: true}), dir)},
+		}
+	}
+
+	if err := rename("broken").Execute(); err == nil {
+		t.Fatal("expected the failing build to be reported")
+	}
+	for name, want := range map[string]string{"kustomization.yaml": kustomization, "deploy.yaml": deploy} {
+		if got, _ := os.ReadFile(filepath.Join(dir, name)); string(got) != want {
+			t.Errorf("%s not reverted: %q", name, got)
+		}
+	}
+	if err := rename("fixed").Execute(); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	calls, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "build " + dir + " --enable-helm\nbuild " + dir + " --enable-helm\n"
+	if string(calls) != want {
+		t.Errorf("kustomize calls = %q, want %q", calls, want)
+	}
+}
+
+func TestPackageWriterRevertsFileEditsWithKustomization(t *testing.T) {
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "kustomize"), []byte("#!/bin/sh\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	dir := t.TempDir()
+	patch := filepath.Join(dir, "patches", "configmap.yaml")
+	if err := os.MkdirAll(filepath.Dir(patch), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(dir, "kustomization.yaml"): "patches:\n  - path: patches/configmap.yaml\n",
+		patch:                                    "data:\n  APP_VERSION: 1.2.3\n",
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	applied := policy.NewApplied()
+	ctx := withFileEdits(policy.WithApplied(
+		WithValidation(context.Background(), config.Validation{Kustomize: true}), applied))
+	p := policy.Proposal{Source: "image", Name: "app", From: "1.2.3", To: "1.3.0"}
+	if err := propagateTagToFile(ctx, patch, dir, p); err != nil {
+		t.Fatalf("propagate: %v", err)
+	}
+	if err := newPackageWriter(ctx, dir).Write(nil); err == nil {
+		t.Fatal("expected the failing build to be reported")
+	}
+	if got, _ := os.ReadFile(patch); string(got) != files[patch] {
+		t.Errorf("patch not reverted: %q", got)
+	}
+	if got := applied.Files(); len(got) > 0 {
+		t.Errorf("reverted patch recorded: %v", got)
+	}
+}
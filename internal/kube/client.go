@@ -0,0 +1,291 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/retry"
+	"github.com/shikanime-studio/automata/internal/timeout"
+)
+
+// Patch types accepted by Patch.
+const (
+	MergePatch          = "application/merge-patch+json"
+	StrategicMergePatch = "application/strategic-merge-patch+json"
+)
+
+// Watch event types.
+const (
+	Added    = "ADDED"
+	Modified = "MODIFIED"
+	Deleted  = "DELETED"
+	Bookmark = "BOOKMARK"
+)
+
+// watchTimeout is how long the server streams a watch before ending it.
+const watchTimeout = 5 * time.Minute
+
+// Client calls the API server of a cluster.
+type Client struct {
+	cfg  Config
+	http *http.Client
+	// exec caches the credentials of the exec plugin of the config.
+	exec *execCredentials
+}
+
+// NewClient creates a client of the API server described by cfg.
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.Server == "" {
+		return nil, errors.New("kubernetes config has no server")
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.Insecure}
+	if len(cfg.CAData) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(cfg.CAData) {
+			return nil, errors.New("kubernetes config has no valid certificate authority")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(cfg.CertData) > 0 {
+		cert, err := tls.X509KeyPair(cfg.CertData, cfg.KeyData)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	c := &Client{cfg: cfg}
+	if cfg.Exec != nil {
+		c.exec = &execCredentials{cfg: *cfg.Exec}
+		if len(cfg.CertData) == 0 {
+			tlsConfig.GetClientCertificate = func(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				_, cert, err := c.exec.get(info.Context())
+				if err != nil || cert == nil {
+					return &tls.Certificate{}, err
+				}
+				return cert, nil
+			}
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	c.http = &http.Client{Transport: transport}
+	return c, nil
+}
+
+// Namespace returns the default namespace of the client config, "default"
+// when unset.
+func (c *Client) Namespace() string {
+	if ns := strings.TrimSpace(c.cfg.Namespace); ns != "" {
+		return ns
+	}
+	return "default"
+}
+
+// StatusError is an error status returned by the API server.
+type StatusError struct {
+	Code    int
+	Reason  string
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("kubernetes api: %s (%d)", e.Message, e.Code)
+	}
+	return fmt.Sprintf("kubernetes api: %s", http.StatusText(e.Code))
+}
+
+// IsNotFound reports whether err is a not found status.
+func IsNotFound(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Code == http.StatusNotFound
+}
+
+// IsGone reports whether err is the expiry of the resource version a watch
+// started from, after which the resources have to be listed again.
+func IsGone(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Code == http.StatusGone
+}
+
+// statusError decodes the Status body of an error response.
+func statusError(code int, data []byte) *StatusError {
+	se := &StatusError{Code: code}
+	var status struct {
+		Code    int    `json:"code"`
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &status) == nil {
+		se.Reason, se.Message = status.Reason, status.Message
+		if status.Code != 0 {
+			se.Code = status.Code
+		}
+	}
+	return se
+}
+
+// Get reads the resource at the API path, e.g. /api/v1/namespaces/default,
+// into out.
+func (c *Client) Get(ctx context.Context, path string, out any) error {
+	return c.Do(ctx, http.MethodGet, path, "", nil, out)
+}
+
+// Patch applies a patch of the given type to the resource at the API path,
+// reading the patched resource into out when not nil.
+func (c *Client) Patch(ctx context.Context, path, patchType string, patch, out any) error {
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("encode patch: %w", err)
+	}
+	return c.Do(ctx, http.MethodPatch, path, patchType, body, out)
+}
+
+// WatchEvent is a change of a watched resource.
+type WatchEvent struct {
+	// Type is Added, Modified, Deleted or Bookmark.
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// Watch streams the changes of the resources at the API path after
+// resourceVersion to fn, until the server ends the watch, fn fails or the
+// context is canceled. The server ends watches after a few minutes, so
+// callers watch again from the last resource version they saw, or list the
+// resources again when the watch fails with a gone status.
+func (c *Client) Watch(ctx context.Context, path, resourceVersion string, fn func(WatchEvent) error) error {
+	q := url.Values{
+		"watch":               {"true"},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {strconv.Itoa(int(watchTimeout.Seconds()))},
+	}
+	if resourceVersion != "" {
+		q.Set("resourceVersion", resourceVersion)
+	}
+	req, err := c.newRequest(ctx, http.MethodGet, path+"?"+q.Encode(), "", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("watch %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		data, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusUnauthorized && c.exec != nil {
+			c.exec.reset()
+		}
+		return statusError(resp.StatusCode, data)
+	}
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var e WatchEvent
+		if err := dec.Decode(&e); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("watch %s: %w", path, err)
+		}
+		if e.Type == "ERROR" {
+			return statusError(http.StatusInternalServerError, e.Object)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+}
+
+// newRequest creates a request to the API server, authenticated with the
+// token of the config or of its exec plugin.
+func (c *Client) newRequest(ctx context.Context, method, path, contentType string, body []byte) (*http.Request, error) {
+	endpoint := strings.TrimSuffix(c.cfg.Server, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create kubernetes request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	token := c.cfg.Token
+	if c.exec != nil && token == "" {
+		if token, _, err = c.exec.get(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(token))
+	}
+	return req, nil
+}
+
+// Do sends a request to the API server, retrying server errors, and decodes
+// the JSON response into out when not nil. Credentials of an exec plugin
+// refused by the server are fetched again once.
+func (c *Client) Do(ctx context.Context, method, path, contentType string, body []byte, out any) error {
+	err := c.do(ctx, method, path, contentType, body, out)
+	var se *StatusError
+	if c.exec != nil && errors.As(err, &se) && se.Code == http.StatusUnauthorized {
+		c.exec.reset()
+		err = c.do(ctx, method, path, contentType, body, out)
+	}
+	return err
+}
+
+func (c *Client) do(ctx context.Context, method, path, contentType string, body []byte, out any) error {
+	data, err := retry.Value(ctx, isRetryable, func() ([]byte, error) {
+		ctx, cancel := timeout.Context(ctx, timeout.Kubernetes)
+		defer cancel()
+		req, err := c.newRequest(ctx, method, path, contentType, body)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		if resp.StatusCode >= http.StatusMultipleChoices {
+			return nil, statusError(resp.StatusCode, data)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// isRetryable reports whether a failed call may succeed when retried.
+func isRetryable(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return retry.IsServerError(se.Code) || se.Code == http.StatusTooManyRequests
+	}
+	return retry.IsTransient(err)
+}
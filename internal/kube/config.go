@@ -0,0 +1,294 @@
+// Package kube is a minimal client of the Kubernetes API, covering the few
+// calls automata makes to clusters: reading resources, listing them and
+// patching them.
+package kube
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// serviceAccountDir is where the service account credentials are mounted in
+// pods.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Config holds what is needed to reach an API server.
+type Config struct {
+	// Server is the URL of the API server.
+	Server string
+	// Token is the bearer token authenticating requests.
+	Token string
+	// CAData is the PEM bundle of the certificate authorities trusted for the
+	// server, the system ones when empty.
+	CAData []byte
+	// CertData and KeyData are the PEM client certificate and key.
+	CertData []byte
+	KeyData  []byte
+	// Insecure skips the verification of the server certificate.
+	Insecure bool
+	// Namespace is the default namespace of the context.
+	Namespace string
+	// Exec is the credential plugin run to get the token or client
+	// certificate, when the user has one.
+	Exec *ExecConfig
+}
+
+// InCluster returns the config of the service account of the pod running
+// automata.
+func InCluster() (Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return Config{}, errors.New("not running in a cluster: KUBERNETES_SERVICE_HOST is unset")
+	}
+	token, err := os.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return Config{}, fmt.Errorf("read service account token: %w", err)
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return Config{}, fmt.Errorf("read service account ca: %w", err)
+	}
+	ns, _ := os.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+	return Config{
+		Server:    "https://" + net.JoinHostPort(host, port),
+		Token:     string(token),
+		CAData:    ca,
+		Namespace: string(ns),
+	}, nil
+}
+
+// kubeconfig is the subset of the kubeconfig format automata reads.
+type kubeconfig struct {
+	CurrentContext string         `yaml:"current-context"`
+	Clusters       []namedCluster `yaml:"clusters"`
+	Contexts       []namedContext `yaml:"contexts"`
+	Users          []namedUser    `yaml:"users"`
+}
+
+// namedCluster is a cluster entry of a kubeconfig.
+type namedCluster struct {
+	Name    string `yaml:"name"`
+	Cluster struct {
+		Server                   string `yaml:"server"`
+		CertificateAuthority     string `yaml:"certificate-authority"`
+		CertificateAuthorityData string `yaml:"certificate-authority-data"`
+		InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+	} `yaml:"cluster"`
+	// dir is the directory of the file defining the cluster, which its
+	// relative paths are resolved against.
+	dir string
+}
+
+// namedContext is a context entry of a kubeconfig.
+type namedContext struct {
+	Name    string `yaml:"name"`
+	Context struct {
+		Cluster   string `yaml:"cluster"`
+		User      string `yaml:"user"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"context"`
+}
+
+// namedUser is a user entry of a kubeconfig.
+type namedUser struct {
+	Name string `yaml:"name"`
+	User struct {
+		Token                 string      `yaml:"token"`
+		TokenFile             string      `yaml:"tokenFile"`
+		ClientCertificate     string      `yaml:"client-certificate"`
+		ClientCertificateData string      `yaml:"client-certificate-data"`
+		ClientKey             string      `yaml:"client-key"`
+		ClientKeyData         string      `yaml:"client-key-data"`
+		Exec                  *ExecConfig `yaml:"exec"`
+	} `yaml:"user"`
+	// dir is the directory of the file defining the user.
+	dir string
+}
+
+// LoadKubeconfig returns the config of a context of the kubeconfig file at
+// path, or of its current context when name is empty.
+func LoadKubeconfig(path, name string) (Config, error) {
+	kc, err := readKubeconfigs([]string{path}, false)
+	if err != nil {
+		return Config{}, err
+	}
+	return kc.config(path, name)
+}
+
+// readKubeconfigs merges kubeconfig files like kubectl does for $KUBECONFIG:
+// the first file setting the current context sets it, and the first
+// definition of a cluster, context or user wins. Missing files are skipped
+// when optional, as long as one exists.
+func readKubeconfigs(paths []string, optional bool) (kubeconfig, error) {
+	var merged kubeconfig
+	clusters, contexts, users := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	found := false
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if optional && errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return kubeconfig{}, fmt.Errorf("read kubeconfig: %w", err)
+		}
+		found = true
+		var kc kubeconfig
+		if err := yaml.Unmarshal(data, &kc); err != nil {
+			return kubeconfig{}, fmt.Errorf("parse kubeconfig %s: %w", path, err)
+		}
+		if merged.CurrentContext == "" {
+			merged.CurrentContext = kc.CurrentContext
+		}
+		dir := filepath.Dir(path)
+		for _, c := range kc.Clusters {
+			if !clusters[c.Name] {
+				clusters[c.Name] = true
+				c.dir = dir
+				merged.Clusters = append(merged.Clusters, c)
+			}
+		}
+		for _, c := range kc.Contexts {
+			if !contexts[c.Name] {
+				contexts[c.Name] = true
+				merged.Contexts = append(merged.Contexts, c)
+			}
+		}
+		for _, u := range kc.Users {
+			if !users[u.Name] {
+				users[u.Name] = true
+				u.dir = dir
+				merged.Users = append(merged.Users, u)
+			}
+		}
+	}
+	if !found {
+		return kubeconfig{}, fmt.Errorf("read kubeconfig: none of %s exists", strings.Join(paths, ", "))
+	}
+	return merged, nil
+}
+
+// config returns the config of a context, or of the current context when
+// name is empty. source names the kubeconfig in errors.
+func (kc kubeconfig) config(source, name string) (Config, error) {
+	if name == "" {
+		name = kc.CurrentContext
+	}
+	if name == "" {
+		return Config{}, fmt.Errorf("kubeconfig %s has no current context", source)
+	}
+	var err error
+	for _, c := range kc.Contexts {
+		if c.Name != name {
+			continue
+		}
+		cfg := Config{Namespace: c.Context.Namespace}
+		found := false
+		for _, cl := range kc.Clusters {
+			if cl.Name != c.Context.Cluster {
+				continue
+			}
+			found = true
+			cfg.Server = cl.Cluster.Server
+			cfg.Insecure = cl.Cluster.InsecureSkipTLSVerify
+			if cfg.CAData, err = inlineOrFile(cl.Cluster.CertificateAuthorityData, cl.Cluster.CertificateAuthority, cl.dir); err != nil {
+				return Config{}, fmt.Errorf("cluster %s: %w", cl.Name, err)
+			}
+		}
+		if !found {
+			return Config{}, fmt.Errorf("context %s: unknown cluster %q", name, c.Context.Cluster)
+		}
+		for _, u := range kc.Users {
+			if u.Name != c.Context.User {
+				continue
+			}
+			if u.User.Exec != nil {
+				exec := *u.User.Exec
+				exec.dir = u.dir
+				cfg.Exec = &exec
+			}
+			cfg.Token = u.User.Token
+			if u.User.TokenFile != "" {
+				token, err := os.ReadFile(resolve(u.User.TokenFile, u.dir))
+				if err != nil {
+					return Config{}, fmt.Errorf("user %s: read token: %w", u.Name, err)
+				}
+				cfg.Token = string(token)
+			}
+			if cfg.CertData, err = inlineOrFile(u.User.ClientCertificateData, u.User.ClientCertificate, u.dir); err != nil {
+				return Config{}, fmt.Errorf("user %s: %w", u.Name, err)
+			}
+			if cfg.KeyData, err = inlineOrFile(u.User.ClientKeyData, u.User.ClientKey, u.dir); err != nil {
+				return Config{}, fmt.Errorf("user %s: %w", u.Name, err)
+			}
+		}
+		return cfg, nil
+	}
+	return Config{}, fmt.Errorf("kubeconfig %s has no context %q", source, name)
+}
+
+// inlineOrFile decodes base64 inline data, or reads the file it is given
+// instead, relative to the kubeconfig directory.
+func inlineOrFile(data, file, dir string) ([]byte, error) {
+	if data != "" {
+		b, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, fmt.Errorf("decode certificate data: %w", err)
+		}
+		return b, nil
+	}
+	if file == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(resolve(file, dir))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", file, err)
+	}
+	return b, nil
+}
+
+// resolve makes a kubeconfig path absolute against its directory.
+func resolve(path, dir string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// Load returns the config of the named kubeconfig context, like kubectl: the
+// kubeconfig is the given file, else the files of $KUBECONFIG merged, else
+// ~/.kube/config. Without an explicit file or context, the service account
+// of the pod is used when running in a cluster.
+func Load(path, context string) (Config, error) {
+	if path == "" && context == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return InCluster()
+	}
+	if path != "" {
+		return LoadKubeconfig(path, context)
+	}
+	var paths []string
+	for _, p := range filepath.SplitList(os.Getenv("KUBECONFIG")) {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return Config{}, fmt.Errorf("locate kubeconfig: %w", err)
+		}
+		return LoadKubeconfig(filepath.Join(home, ".kube", "config"), context)
+	}
+	kc, err := readKubeconfigs(paths, true)
+	if err != nil {
+		return Config{}, err
+	}
+	return kc.config("$KUBECONFIG", context)
+}
@@ -0,0 +1,125 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExecConfig is an exec credential plugin of a kubeconfig user, a command
+// printing short-lived credentials, as cloud providers use for their
+// clusters.
+type ExecConfig struct {
+	// APIVersion is the client.authentication.k8s.io version of the
+	// ExecCredential exchanged with the command.
+	APIVersion string   `yaml:"apiVersion"`
+	Command    string   `yaml:"command"`
+	Args       []string `yaml:"args"`
+	// Env is added to the environment of the command.
+	Env []struct {
+		Name  string `yaml:"name"`
+		Value string `yaml:"value"`
+	} `yaml:"env"`
+	// dir is the directory of the kubeconfig, which relative commands are
+	// resolved against.
+	dir string
+}
+
+// execCredential is the ExecCredential printed by a plugin.
+type execCredential struct {
+	Status struct {
+		Token                 string     `json:"token"`
+		ClientCertificateData string     `json:"clientCertificateData"`
+		ClientKeyData         string     `json:"clientKeyData"`
+		ExpirationTimestamp   *time.Time `json:"expirationTimestamp"`
+	} `json:"status"`
+}
+
+// execCredentials caches the credentials of a plugin until they expire.
+type execCredentials struct {
+	cfg ExecConfig
+
+	mu      sync.Mutex
+	token   string
+	cert    *tls.Certificate
+	expiry  time.Time
+	fetched bool
+}
+
+// get returns the cached credentials, running the plugin when there are
+// none or they expired.
+func (e *execCredentials) get(ctx context.Context) (string, *tls.Certificate, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.fetched && (e.expiry.IsZero() || time.Now().Before(e.expiry)) {
+		return e.token, e.cert, nil
+	}
+	cred, err := e.run(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	e.token, e.cert = cred.Status.Token, nil
+	if cred.Status.ClientCertificateData != "" {
+		cert, err := tls.X509KeyPair([]byte(cred.Status.ClientCertificateData), []byte(cred.Status.ClientKeyData))
+		if err != nil {
+			return "", nil, fmt.Errorf("exec plugin %s: load client certificate: %w", e.cfg.Command, err)
+		}
+		e.cert = &cert
+	}
+	e.expiry = time.Time{}
+	if t := cred.Status.ExpirationTimestamp; t != nil {
+		e.expiry = *t
+	}
+	e.fetched = true
+	return e.token, e.cert, nil
+}
+
+// reset drops the cached credentials, after the server refused them.
+func (e *execCredentials) reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.fetched = false
+}
+
+// run runs the plugin and decodes the credential it prints.
+func (e *execCredentials) run(ctx context.Context) (execCredential, error) {
+	command := e.cfg.Command
+	if strings.ContainsRune(command, filepath.Separator) {
+		command = resolve(command, e.cfg.dir)
+	}
+	info, err := json.Marshal(map[string]any{
+		"apiVersion": e.cfg.APIVersion,
+		"kind":       "ExecCredential",
+		"spec":       map[string]any{"interactive": false},
+	})
+	if err != nil {
+		return execCredential{}, err
+	}
+	cmd := exec.CommandContext(ctx, command, e.cfg.Args...)
+	cmd.Env = append(os.Environ(), "KUBERNETES_EXEC_INFO="+string(info))
+	for _, v := range e.cfg.Env {
+		cmd.Env = append(cmd.Env, v.Name+"="+v.Value)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return execCredential{}, fmt.Errorf("exec plugin %s: %w: %s", e.cfg.Command, err, strings.TrimSpace(stderr.String()))
+	}
+	var cred execCredential
+	if err := json.Unmarshal(out, &cred); err != nil {
+		return execCredential{}, fmt.Errorf("exec plugin %s: decode credential: %w", e.cfg.Command, err)
+	}
+	if cred.Status.Token == "" && cred.Status.ClientCertificateData == "" {
+		return execCredential{}, fmt.Errorf("exec plugin %s: credential has no token nor client certificate", e.cfg.Command)
+	}
+	return cred, nil
+}
@@ -0,0 +1,307 @@
+package kube
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("file-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ca := base64.StdEncoding.EncodeToString([]byte("ca"))
+	path := filepath.Join(dir, "config")
+	data := `current-context: dev
+clusters:
+  - name: dev
+    cluster:
+      server: https://dev.example.com
+      certificate-authority-data: ` + ca + `
+  - name: prod
+    cluster:
+      server: https://prod.example.com
+      insecure-skip-tls-verify: true
+contexts:
+  - name: dev
+    context: {cluster: dev, user: dev, namespace: apps}
+  - name: prod
+    context: {cluster: prod, user: prod}
+  - name: exec
+    context: {cluster: prod, user: exec}
+users:
+  - name: dev
+    user: {token: dev-token}
+  - name: prod
+    user: {tokenFile: token}
+  - name: exec
+    user:
+      exec: {command: aws}
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadKubeconfig(path, "")
+	if err != nil {
+		t.Fatalf("LoadKubeconfig: %v", err)
+	}
+	if cfg.Server != "https://dev.example.com" || cfg.Token != "dev-token" ||
+		string(cfg.CAData) != "ca" || cfg.Namespace != "apps" {
+		t.Errorf("current context = %+v", cfg)
+	}
+
+	cfg, err = LoadKubeconfig(path, "prod")
+	if err != nil {
+		t.Fatalf("LoadKubeconfig(prod): %v", err)
+	}
+	if cfg.Server != "https://prod.example.com" || cfg.Token != "file-token\n" || !cfg.Insecure {
+		t.Errorf("prod context = %+v", cfg)
+	}
+
+	cfg, err = LoadKubeconfig(path, "exec")
+	if err != nil {
+		t.Fatalf("LoadKubeconfig(exec): %v", err)
+	}
+	if cfg.Exec == nil || cfg.Exec.Command != "aws" || cfg.Exec.dir != dir {
+		t.Errorf("exec context = %+v", cfg)
+	}
+	if _, err := LoadKubeconfig(path, "missing"); err == nil {
+		t.Error("LoadKubeconfig(missing) succeeded, want unknown context")
+	}
+}
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/namespaces/default":
+			_, _ = w.Write([]byte(`{"metadata":{"name":"default"}}`))
+		case r.Method == http.MethodPatch && r.URL.Path == "/api/v1/namespaces/default":
+			if got := r.Header.Get("Content-Type"); got != MergePatch {
+				t.Errorf("Content-Type = %q", got)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"reason":"NotFound","message":"not found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(Config{Server: srv.URL, Token: "token\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	var ns struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	}
+	if err := c.Get(ctx, "/api/v1/namespaces/default", &ns); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if ns.Metadata.Name != "default" {
+		t.Errorf("name = %q", ns.Metadata.Name)
+	}
+	if err := c.Patch(ctx, "/api/v1/namespaces/default", MergePatch, map[string]any{}, nil); err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	if err := c.Get(ctx, "/api/v1/namespaces/missing", &ns); !IsNotFound(err) {
+		t.Errorf("Get(missing) = %v, want not found", err)
+	}
+}
+
+func TestWorkloads(t *testing.T) {
+	var patch map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/apis/apps/v1/namespaces/apps/deployments":
+			if got := r.URL.Query().Get("labelSelector"); got != "tier=web" {
+				t.Errorf("labelSelector = %q", got)
+			}
+			_, _ = w.Write([]byte(`{"items":[{"metadata":{"name":"web","namespace":"apps"},
+				"spec":{"template":{"spec":{
+					"initContainers":[{"name":"migrate","image":"app:1.0.0"}],
+					"containers":[{"name":"web","image":"nginx:1.25.0"}]}}}}]}`))
+		case r.Method == http.MethodGet:
+			_, _ = w.Write([]byte(`{"items":[]}`))
+		case r.URL.Path == "/apis/apps/v1/namespaces/apps/deployments/web":
+			if got := r.Header.Get("Content-Type"); got != StrategicMergePatch {
+				t.Errorf("Content-Type = %q", got)
+			}
+			if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+				t.Errorf("decode patch: %v", err)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(Config{Server: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	workloads, err := c.ListWorkloads(ctx, "apps", "tier=web")
+	if err != nil {
+		t.Fatalf("ListWorkloads: %v", err)
+	}
+	want := []Workload{{
+		Kind:      "Deployment",
+		Namespace: "apps",
+		Name:      "web",
+		Containers: []Container{
+			{Name: "migrate", Image: "app:1.0.0", Init: true},
+			{Name: "web", Image: "nginx:1.25.0"},
+		},
+	}}
+	if !reflect.DeepEqual(workloads, want) {
+		t.Fatalf("workloads = %+v, want %+v", workloads, want)
+	}
+
+	if err := c.SetImage(ctx, workloads[0], workloads[0].Containers[0], "app:1.1.0"); err != nil {
+		t.Fatalf("SetImage: %v", err)
+	}
+	wantPatch := map[string]any{"spec": map[string]any{"template": map[string]any{"spec": map[string]any{
+		"initContainers": []any{map[string]any{"name": "migrate", "image": "app:1.1.0"}},
+	}}}}
+	if !reflect.DeepEqual(patch, wantPatch) {
+		t.Errorf("patch = %v, want %v", patch, wantPatch)
+	}
+}
+
+func TestLoadMergesKubeconfigs(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first")
+	second := filepath.Join(dir, "second")
+	if err := os.WriteFile(first, []byte(`contexts:
+  - name: dev
+    context: {cluster: dev, user: dev}
+clusters:
+  - name: dev
+    cluster: {server: https://first.example.com}
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte(`current-context: dev
+clusters:
+  - name: dev
+    cluster: {server: https://second.example.com}
+users:
+  - name: dev
+    user: {token: second-token}
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBECONFIG", strings.Join([]string{first, filepath.Join(dir, "missing"), second}, string(os.PathListSeparator)))
+
+	cfg, err := Load("", "")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server != "https://first.example.com" || cfg.Token != "second-token" {
+		t.Errorf("merged config = %+v", cfg)
+	}
+}
+
+func TestClientExecCredentials(t *testing.T) {
+	dir := t.TempDir()
+	count := filepath.Join(dir, "count")
+	plugin := filepath.Join(dir, "plugin")
+	script := `#!/bin/sh
+echo run >> ` + count + `
+echo '{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","status":{"token":"'$TOKEN'"}}'
+`
+	if err := os.WriteFile(plugin, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	var tokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	exec := &ExecConfig{
+		APIVersion: "client.authentication.k8s.io/v1",
+		Command:    "./plugin",
+		Env: []struct {
+			Name  string `yaml:"name"`
+			Value string `yaml:"value"`
+		}{{Name: "TOKEN", Value: "exec-token"}},
+		dir: dir,
+	}
+	c, err := NewClient(Config{Server: srv.URL, Exec: exec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for range 2 {
+		if err := c.Get(ctx, "/api", nil); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+	if want := []string{"Bearer exec-token", "Bearer exec-token"}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("tokens = %v, want %v", tokens, want)
+	}
+	runs, err := os.ReadFile(count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(runs), "run"); got != 1 {
+		t.Errorf("plugin ran %d times, want once", got)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("watch") != "true" {
+			t.Errorf("watch = %q", q.Get("watch"))
+		}
+		switch q.Get("resourceVersion") {
+		case "1":
+			_, _ = w.Write([]byte(`{"type":"ADDED","object":{"metadata":{"name":"a","resourceVersion":"2"}}}
+{"type":"MODIFIED","object":{"metadata":{"name":"a","resourceVersion":"3"}}}
+`))
+		default:
+			_, _ = w.Write([]byte(`{"type":"ERROR","object":{"kind":"Status","code":410,"reason":"Expired"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(Config{Server: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	var events []string
+	err = c.Watch(ctx, "/apis/example.com/v1/things", "1", func(e WatchEvent) error {
+		events = append(events, e.Type)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	if want := []string{Added, Modified}; !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	err = c.Watch(ctx, "/apis/example.com/v1/things", "0", func(WatchEvent) error { return nil })
+	if !IsGone(err) {
+		t.Errorf("Watch(expired) = %v, want gone", err)
+	}
+}
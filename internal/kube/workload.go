@@ -0,0 +1,105 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// WorkloadKinds are the apps/v1 workload resources automata reads, by kind.
+var WorkloadKinds = map[string]string{
+	"Deployment":  "deployments",
+	"StatefulSet": "statefulsets",
+	"DaemonSet":   "daemonsets",
+}
+
+// Container is a container of a workload pod template.
+type Container struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	// Init is set on init containers.
+	Init bool `json:"-"`
+}
+
+// Workload is a Deployment, StatefulSet or DaemonSet.
+type Workload struct {
+	Kind       string
+	Namespace  string
+	Name       string
+	Containers []Container
+}
+
+// String returns the workload as kind/namespace/name.
+func (w Workload) String() string {
+	return fmt.Sprintf("%s/%s/%s", w.Kind, w.Namespace, w.Name)
+}
+
+// workloadList is the subset of an apps/v1 list automata decodes.
+type workloadList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			Template struct {
+				Spec struct {
+					InitContainers []Container `json:"initContainers"`
+					Containers     []Container `json:"containers"`
+				} `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// ListWorkloads lists the workloads of a namespace, or of all namespaces
+// when it is empty, matching the label selector, ordered by kind.
+func (c *Client) ListWorkloads(ctx context.Context, namespace, selector string) ([]Workload, error) {
+	var workloads []Workload
+	for _, kind := range []string{"Deployment", "StatefulSet", "DaemonSet"} {
+		path := "/apis/apps/v1/" + WorkloadKinds[kind]
+		if namespace != "" {
+			path = "/apis/apps/v1/namespaces/" + namespace + "/" + WorkloadKinds[kind]
+		}
+		if selector != "" {
+			path += "?labelSelector=" + url.QueryEscape(selector)
+		}
+		var list workloadList
+		if err := c.Get(ctx, path, &list); err != nil {
+			return nil, fmt.Errorf("list %s: %w", WorkloadKinds[kind], err)
+		}
+		for _, item := range list.Items {
+			w := Workload{Kind: kind, Namespace: item.Metadata.Namespace, Name: item.Metadata.Name}
+			for _, ct := range item.Spec.Template.Spec.InitContainers {
+				ct.Init = true
+				w.Containers = append(w.Containers, ct)
+			}
+			w.Containers = append(w.Containers, item.Spec.Template.Spec.Containers...)
+			workloads = append(workloads, w)
+		}
+	}
+	return workloads, nil
+}
+
+// SetImage sets the image of a container of a workload with a strategic
+// merge patch, rolling out its pods.
+func (c *Client) SetImage(ctx context.Context, w Workload, ct Container, image string) error {
+	field := "containers"
+	if ct.Init {
+		field = "initContainers"
+	}
+	patch := map[string]any{
+		"spec": map[string]any{
+			"template": map[string]any{
+				"spec": map[string]any{
+					field: []map[string]string{{"name": ct.Name, "image": image}},
+				},
+			},
+		},
+	}
+	path := fmt.Sprintf("/apis/apps/v1/namespaces/%s/%s/%s", w.Namespace, WorkloadKinds[w.Kind], w.Name)
+	if err := c.Patch(ctx, path, StrategicMergePatch, patch, nil); err != nil {
+		return fmt.Errorf("patch %s: %w", w, err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Package license detects license changes between the current and the
+// candidate version of a dependency, so automated bumps relicensing a
+// dependency are flagged for review.
+package license
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/mirror"
+)
+
+// ImageLabel is the OCI image label declaring the licenses of an image as an
+// SPDX expression.
+const ImageLabel = "org.opencontainers.image.licenses"
+
+// Resolver returns the license of a version of a dependency, or "" when it
+// declares none.
+type Resolver func(ctx context.Context, version string) (string, error)
+
+type checkKey struct{}
+
+// WithCheck returns a context enabling or disabling license checks of the
+// updates made with it.
+func WithCheck(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, checkKey{}, enabled)
+}
+
+// Enabled reports whether the context checks licenses. Offline runs never do,
+// as metadata snapshots record no licenses.
+func Enabled(ctx context.Context) bool {
+	enabled, _ := ctx.Value(checkKey{}).(bool)
+	return enabled && !mirror.Offline(ctx)
+}
+
+// Check resolves the licenses of the current and the candidate version of a
+// dependency and warns when they differ. Versions declaring no license are
+// not compared, and lookup failures are only logged, never failing the
+// update.
+func Check(ctx context.Context, source, name, from, to string, resolve Resolver) {
+	if !Enabled(ctx) || from == "" || to == "" || from == to {
+		return
+	}
+	before, err := resolve(ctx, from)
+	if err != nil {
+		slog.DebugContext(ctx, "failed to resolve license", "name", name, "version", from, "err", err)
+		return
+	}
+	after, err := resolve(ctx, to)
+	if err != nil {
+		slog.DebugContext(ctx, "failed to resolve license", "name", name, "version", to, "err", err)
+		return
+	}
+	if Changed(before, after) {
+		slog.WarnContext(ctx, "license changed", logging.LicenseChanged.Attr(),
+			"source", source, "name", name, "from", from, "to", to,
+			"from_license", normalize(before), "to_license", normalize(after))
+	}
+}
+
+// Changed reports whether two license expressions differ, ignoring case and
+// surrounding space. Unknown licenses are never reported as changed.
+func Changed(before, after string) bool {
+	before, after = normalize(before), normalize(after)
+	if before == "" || after == "" {
+		return false
+	}
+	return !strings.EqualFold(before, after)
+}
+
+// normalize trims an SPDX expression, mapping the NOASSERTION and NONE
+// placeholders to "".
+func normalize(s string) string {
+	s = strings.TrimSpace(s)
+	switch strings.ToUpper(s) {
+	case "NOASSERTION", "NONE", "OTHER":
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+package license
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/mirror"
+)
+
+func TestChanged(t *testing.T) {
+	cases := []struct {
+		before, after string
+		want          bool
+	}{
+		{"MIT", "MIT", false},
+		{"Apache-2.0", "apache-2.0 ", false},
+		{"Apache-2.0", "BUSL-1.1", true},
+		{"", "BUSL-1.1", false},
+		{"MIT", "NOASSERTION", false},
+	}
+	for _, c := range cases {
+		if got := Changed(c.before, c.after); got != c.want {
+			t.Fatalf("Changed(%q, %q) = %v, want %v", c.before, c.after, got, c.want)
+		}
+	}
+}
+
+func capture(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func TestCheckWarnsOnLicenseChange(t *testing.T) {
+	buf := capture(t)
+	licenses := map[string]string{"v1.5.0": "MPL-2.0", "v1.6.0": "BUSL-1.1"}
+	resolve := func(_ context.Context, version string) (string, error) {
+		return licenses[version], nil
+	}
+	ctx := WithCheck(context.Background(), true)
+	Check(ctx, "github", "hashicorp/terraform", "v1.5.0", "v1.6.0", resolve)
+	out := buf.String()
+	if !strings.Contains(out, "license changed") ||
+		!strings.Contains(out, "from_license=MPL-2.0") ||
+		!strings.Contains(out, "to_license=BUSL-1.1") {
+		t.Fatalf("expected license change warning, got %q", out)
+	}
+}
+
+func TestCheckSkipped(t *testing.T) {
+	buf := capture(t)
+	calls := 0
+	resolve := func(context.Context, string) (string, error) {
+		calls++
+		return "", errors.New("unexpected lookup")
+	}
+	Check(context.Background(), "image", "nginx", "1.0", "1.1", resolve)
+	ctx := WithCheck(context.Background(), true)
+	Check(ctx, "image", "nginx", "1.0", "1.0", resolve)
+	Check(mirror.WithOffline(ctx, mirror.NewSnapshot(time.Time{})), "image", "nginx", "1.0", "1.1", resolve)
+	if calls != 0 || buf.Len() != 0 {
+		t.Fatalf("expected no lookup, got %d calls and %q", calls, buf.String())
+	}
+}
+
+func TestCheckIgnoresLookupFailures(t *testing.T) {
+	buf := capture(t)
+	resolve := func(context.Context, string) (string, error) {
+		return "", errors.New("not found")
+	}
+	Check(WithCheck(context.Background(), true), "image", "nginx", "1.0", "1.1", resolve)
+	if strings.Contains(buf.String(), "license changed") {
+		t.Fatalf("unexpected warning %q", buf.String())
+	}
+}
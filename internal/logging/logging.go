@@ -0,0 +1,142 @@
+// Package logging propagates log attributes through contexts and tunes log
+// levels per subsystem.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+type attrsKey struct{}
+
+type subsystemKey struct{}
+
+// WithAttrs returns a context whose log records carry the given attributes,
+// as key-value pairs or slog.Attr values, in addition to those of ctx.
+func WithAttrs(ctx context.Context, args ...any) context.Context {
+	prev, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	r := slog.Record{}
+	r.Add(args...)
+	attrs := append([]slog.Attr(nil), prev...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, attrsKey{}, attrs)
+}
+
+// Attrs returns the attributes carried by the context.
+func Attrs(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+// WithSubsystem returns a context whose log records are leveled as the
+// subsystem, e.g. a command or a source such as helm. The innermost subsystem
+// wins.
+func WithSubsystem(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, subsystemKey{}, name)
+}
+
+// Subsystem returns the subsystem of the context, if any.
+func Subsystem(ctx context.Context) string {
+	name, _ := ctx.Value(subsystemKey{}).(string)
+	return name
+}
+
+// NewRunID returns a random identifier correlating the records of a run.
+func NewRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// Levels holds the minimum level of each subsystem, falling back to a base
+// level. It is safe for concurrent use.
+type Levels struct {
+	mu         sync.RWMutex
+	base       slog.Level
+	subsystems map[string]slog.Level
+}
+
+// NewLevels creates Levels with the given base level.
+func NewLevels(base slog.Level) *Levels {
+	return &Levels{base: base, subsystems: make(map[string]slog.Level)}
+}
+
+// Set sets the level of the subsystems, or the base level for "all".
+func (l *Levels) Set(level slog.Level, subsystems ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, s := range subsystems {
+		s = strings.TrimSpace(s)
+		switch s {
+		case "":
+		case "all":
+			l.base = level
+		default:
+			l.subsystems[s] = level
+		}
+	}
+}
+
+// Level returns the minimum level of the subsystem.
+func (l *Levels) Level(subsystem string) slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if level, ok := l.subsystems[subsystem]; ok {
+		return level
+	}
+	return l.base
+}
+
+// Handler is a slog.Handler adding the attributes of the context to records
+// and filtering them by the level of their subsystem. The next handler should
+// accept every level.
+type Handler struct {
+	next   slog.Handler
+	levels *Levels
+}
+
+// NewHandler creates a Handler forwarding records to next.
+func NewHandler(next slog.Handler, levels *Levels) *Handler {
+	return &Handler{next: next, levels: levels}
+}
+
+// Enabled reports whether the level reaches the level of the subsystem of the
+// context.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.levels.Level(Subsystem(ctx))
+}
+
+// Handle adds the subsystem and attributes of the context to the record and
+// forwards it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := Attrs(ctx)
+	sub := Subsystem(ctx)
+	if len(attrs) == 0 && sub == "" {
+		return h.next.Handle(ctx, r)
+	}
+	r = r.Clone()
+	if sub != "" {
+		r.AddAttrs(slog.String("subsystem", sub))
+	}
+	r.AddAttrs(attrs...)
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a Handler sharing the same levels.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{next: h.next.WithAttrs(attrs), levels: h.levels}
+}
+
+// WithGroup returns a Handler sharing the same levels.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), levels: h.levels}
+}
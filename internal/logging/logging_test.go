@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlerAddsContextAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	levels := NewLevels(slog.LevelInfo)
+	logger := slog.New(NewHandler(
+		slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		levels,
+	))
+	ctx := WithAttrs(context.Background(), "run", "abc")
+	ctx = WithAttrs(WithSubsystem(ctx, "helm"), "dependency", "cert-manager")
+	logger.InfoContext(ctx, "updated chart version")
+	out := buf.String()
+	for _, want := range []string{"subsystem=helm", "run=abc", "dependency=cert-manager"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in %q", want, out)
+		}
+	}
+}
+
+func TestHandlerLevelsPerSubsystem(t *testing.T) {
+	var buf bytes.Buffer
+	levels := NewLevels(slog.LevelInfo)
+	levels.Set(slog.LevelDebug, "helm")
+	levels.Set(slog.LevelWarn, "image")
+	logger := slog.New(NewHandler(
+		slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		levels,
+	))
+	ctx := context.Background()
+	logger.DebugContext(WithSubsystem(ctx, "helm"), "helm debug")
+	logger.InfoContext(WithSubsystem(ctx, "image"), "image info")
+	logger.DebugContext(ctx, "root debug")
+	logger.InfoContext(ctx, "root info")
+	out := buf.String()
+	for msg, want := range map[string]bool{
+		"helm debug": true,
+		"image info": false,
+		"root debug": false,
+		"root info":  true,
+	} {
+		if strings.Contains(out, msg) != want {
+			t.Fatalf("%q logged = %v, want %v:\n%s", msg, !want, want, out)
+		}
+	}
+
+	levels.Set(slog.LevelWarn, "all")
+	if levels.Level("github") != slog.LevelWarn || levels.Level("helm") != slog.LevelDebug {
+		t.Fatalf("unexpected levels after setting all")
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	r := slog.NewRecord(time.Time{}, slog.LevelWarn, "rule update failed", 0)
+	r.Add("path", "app", Failed.Attr())
+	if got := KindOf(r); got != Failed {
+		t.Fatalf("expected %q, got %q", Failed, got)
+	}
+	plain := slog.NewRecord(time.Time{}, slog.LevelWarn, "interrupted", 0)
+	if got := KindOf(plain); got != "" {
+		t.Fatalf("expected a plain log, got %q", got)
+	}
+}
@@ -0,0 +1,57 @@
+package logging
+
+import "log/slog"
+
+// ReportKey is the key of the attribute reporting a record as an event of the
+// run, such as an applied update.
+const ReportKey = "report"
+
+// Kind is the category a record is reported as. Records without one are
+// plain logs, whatever their level or message.
+type Kind string
+
+// Kind values.
+const (
+	// Tracked reports a dependency lookup.
+	Tracked Kind = "tracked"
+	// Updated reports an applied update.
+	Updated Kind = "updated"
+	// Failed reports an update that failed.
+	Failed Kind = "failed"
+	// Rejected reports an update dropped by a policy or the version skew.
+	Rejected Kind = "rejected"
+	// Pending reports an update held for manual approval.
+	Pending Kind = "pending"
+	// PendingMajor reports a major-version bump held for review.
+	PendingMajor Kind = "pending-major"
+	// Queued reports an update held until the next schedule window.
+	Queued Kind = "queued"
+	// Incompatible reports an update unsupported by the platform of a host.
+	Incompatible Kind = "incompatible"
+	// Unversioned reports a dependency whose version is not a version.
+	Unversioned Kind = "unversioned"
+	// RateLimited reports a lookup refused by rate limits.
+	RateLimited Kind = "rate-limited"
+	// EndOfLife reports a dependency past the end of life of its cycle.
+	EndOfLife Kind = "end-of-life"
+	// LicenseChanged reports an update changing the license of a dependency.
+	LicenseChanged Kind = "license-changed"
+)
+
+// Attr returns the attribute reporting a record as k.
+func (k Kind) Attr() slog.Attr {
+	return slog.String(ReportKey, string(k))
+}
+
+// KindOf returns the kind a record is reported as, or "" for plain logs.
+func KindOf(r slog.Record) Kind {
+	var k Kind
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == ReportKey {
+			k = Kind(a.Value.String())
+			return false
+		}
+		return true
+	})
+	return k
+}
@@ -0,0 +1,122 @@
+// Package message renders the commit messages, branch names and pull request
+// titles and bodies publishing updates from user templates, as organizations
+// enforce different conventions for them.
+package message
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/shikanime-studio/automata/internal/policy"
+)
+
+// Update is an applied update as seen by templates.
+type Update struct {
+	Source     string
+	Name       string
+	OldVersion string
+	NewVersion string
+	// Type is the part of the version bumped: major, minor or patch, or ""
+	// for versions that are not semantic versions.
+	Type string
+}
+
+// Data is the data templates are executed with.
+type Data struct {
+	// Group names the updates published together.
+	Group string
+	// Updates are the applied updates, sorted by dependency.
+	Updates []Update
+	// Name, OldVersion and NewVersion describe the update when exactly one
+	// was applied, and are empty otherwise.
+	Name       string
+	OldVersion string
+	NewVersion string
+	// Type is the largest bump among the updates: major, minor or patch.
+	Type string
+	// Summary is the generated summary of the major upgrades, for pull
+	// request bodies.
+	Summary string
+}
+
+// rank orders the update types from the smallest bump.
+var rank = map[string]int{"patch": 1, "minor": 2, "major": 3}
+
+// NewData returns the data of a group of applied updates.
+func NewData(group string, applied []policy.Proposal) Data {
+	d := Data{Group: group}
+	for _, p := range applied {
+		u := Update{
+			Source:     p.Source,
+			Name:       p.Name,
+			OldVersion: p.From,
+			NewVersion: p.To,
+			Type:       policy.UpdateType(p.From, p.To),
+		}
+		if rank[u.Type] > rank[d.Type] {
+			d.Type = u.Type
+		}
+		d.Updates = append(d.Updates, u)
+	}
+	if len(d.Updates) == 1 {
+		u := d.Updates[0]
+		d.Name, d.OldVersion, d.NewVersion = u.Name, u.OldVersion, u.NewVersion
+	}
+	return d
+}
+
+// Parse compiles a template, naming it in errors.
+func Parse(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s template: %w", name, err)
+	}
+	return t, nil
+}
+
+// Render executes a template over the data, trimming surrounding space. An
+// empty template renders the fallback.
+func Render(name, text, fallback string, d Data) (string, error) {
+	if text == "" {
+		return fallback, nil
+	}
+	t, err := Parse(name, text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, d); err != nil {
+		return "", fmt.Errorf("render %s template: %w", name, err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// BranchPrefix returns the part of the branches a branch template renders
+// for a group that does not depend on the updates, so the branches of
+// earlier runs of the group can be told apart from those of other groups. It
+// reports whether the template renders the same branch whatever the updates,
+// in which case the prefix is that branch.
+func BranchPrefix(text, fallback, group string) (string, bool, error) {
+	a, err := Render("branch", text, fallback, NewData(group, []policy.Proposal{
+		{Source: "a", Name: "a", From: "1.0.0", To: "2.0.0"},
+	}))
+	if err != nil {
+		return "", false, err
+	}
+	b, err := Render("branch", text, fallback, NewData(group, []policy.Proposal{
+		{Source: "b", Name: "b", From: "3.0.0", To: "3.0.1"},
+		{Source: "z", Name: "z", From: "4.0.0", To: "4.0.1"},
+	}))
+	if err != nil {
+		return "", false, err
+	}
+	if a == b {
+		return a, true, nil
+	}
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return a[:n], false, nil
+}
@@ -0,0 +1,68 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/shikanime-studio/automata/internal/policy"
+)
+
+func TestRender(t *testing.T) {
+	one := NewData("deps", []policy.Proposal{
+		{Source: "image", Name: "nginx", From: "1.27.0", To: "1.27.1"},
+	})
+	many := NewData("deps", []policy.Proposal{
+		{Source: "image", Name: "nginx", From: "1.27.0", To: "1.27.1"},
+		{Source: "github", Name: "actions/checkout", From: "v4", To: "v5"},
+	})
+	cases := []struct {
+		text string
+		data Data
+		want string
+	}{
+		{"", one, "fallback"},
+		{"fix(deps): bump {{.Name}} from {{.OldVersion}} to {{.NewVersion}}", one,
+			"fix(deps): bump nginx from 1.27.0 to 1.27.1"},
+		{"automata/{{.Group}}-{{.Type}}", many, "automata/deps-major"},
+		{"{{range .Updates}}- {{.Name}} {{.Type}}\n{{end}}", many,
+			"- nginx patch\n- actions/checkout major"},
+	}
+	for _, c := range cases {
+		got, err := Render("commit-message", c.text, "fallback", c.data)
+		if err != nil {
+			t.Fatalf("render %q: %v", c.text, err)
+		}
+		if got != c.want {
+			t.Fatalf("render %q = %q, want %q", c.text, got, c.want)
+		}
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	for _, text := range []string{"{{.Name", "{{.Unknown}}"} {
+		if _, err := Render("title", text, "", Data{}); err == nil {
+			t.Fatalf("render %q: expected error", text)
+		}
+	}
+}
+
+func TestBranchPrefix(t *testing.T) {
+	cases := []struct {
+		text   string
+		prefix string
+		exact  bool
+	}{
+		{"", "automata/update", true},
+		{"automata/{{.Group}}", "automata/deps", true},
+		{"automata/{{.Group}}/{{.Type}}", "automata/deps/", false},
+		{"automata/{{.Group}}/{{range .Updates}}{{.Name}}-{{.NewVersion}}{{end}}", "automata/deps/", false},
+	}
+	for _, c := range cases {
+		prefix, exact, err := BranchPrefix(c.text, "automata/update", "deps")
+		if err != nil {
+			t.Fatalf("branch prefix %q: %v", c.text, err)
+		}
+		if prefix != c.prefix || exact != c.exact {
+			t.Fatalf("branch prefix %q = %q, %v, want %q, %v", c.text, prefix, exact, c.prefix, c.exact)
+		}
+	}
+}
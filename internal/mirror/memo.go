@@ -0,0 +1,112 @@
+package mirror
+
+import (
+	"context"
+	"sync"
+)
+
+// Memo shares the lookups of a run, so that a dependency referenced by many
+// manifests, or by concurrently running pipelines, is only fetched once.
+type Memo struct {
+	mu      sync.Mutex
+	lookups map[memoKey]*lookup
+}
+
+type memoKey struct{ kind, name string }
+
+// lookup is a fetch in flight, or done when its channel is closed.
+type lookup struct {
+	done       chan struct{}
+	candidates []Candidate
+	err        error
+}
+
+// NewMemo creates an empty memo.
+func NewMemo() *Memo {
+	return &Memo{lookups: make(map[memoKey]*lookup)}
+}
+
+type memoCtxKey struct{}
+
+// WithMemo returns a context whose lookups are shared through the memo. A
+// memo should live for a single run, as the candidates it holds are never
+// refreshed.
+func WithMemo(ctx context.Context, m *Memo) context.Context {
+	return context.WithValue(ctx, memoCtxKey{}, m)
+}
+
+// Fetch returns the candidates of a dependency from the snapshot when the
+// context is offline, otherwise from fetch, recording them when the context
+// has a recorder. With a memo, lookups of a dependency being fetched wait for
+// that fetch and share its result, and the candidates it succeeds with serve
+// the rest of the run, while a failed fetch is tried again by the next
+// lookup. The candidates must not be modified.
+func Fetch(
+	ctx context.Context,
+	kind, name string,
+	fetch func(ctx context.Context) ([]Candidate, error),
+) ([]Candidate, error) {
+	if c, ok, err := Lookup(ctx, kind, name); ok {
+		return c, err
+	}
+	m, ok := ctx.Value(memoCtxKey{}).(*Memo)
+	if !ok {
+		return fetchAndRecord(ctx, kind, name, fetch)
+	}
+	key := memoKey{kind, name}
+	m.mu.Lock()
+	l, ok := m.lookups[key]
+	if ok {
+		m.mu.Unlock()
+		select {
+		case <-l.done:
+			return l.candidates, l.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	l = &lookup{done: make(chan struct{})}
+	m.lookups[key] = l
+	m.mu.Unlock()
+	l.candidates, l.err = fetchAndRecord(ctx, kind, name, fetch)
+	if l.err != nil {
+		m.mu.Lock()
+		delete(m.lookups, key)
+		m.mu.Unlock()
+	}
+	close(l.done)
+	return l.candidates, l.err
+}
+
+// FetchVersions is Fetch for plain versions.
+func FetchVersions(
+	ctx context.Context,
+	kind, name string,
+	fetch func(ctx context.Context) ([]string, error),
+) ([]string, error) {
+	c, err := Fetch(ctx, kind, name, func(ctx context.Context) ([]Candidate, error) {
+		versions, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return candidates(versions), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return Versions(c), nil
+}
+
+// fetchAndRecord fetches the candidates of a dependency and records them.
+func fetchAndRecord(
+	ctx context.Context,
+	kind, name string,
+	fetch func(ctx context.Context) ([]Candidate, error),
+) ([]Candidate, error) {
+	c, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	Record(ctx, kind, name, c)
+	return c, nil
+}
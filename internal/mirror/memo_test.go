@@ -0,0 +1,91 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetch_Memo(t *testing.T) {
+	snap := NewSnapshot(time.Time{})
+	ctx := WithRecorder(WithMemo(context.Background(), NewMemo()), snap)
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	fetch := func(context.Context) ([]string, error) {
+		fetches.Add(1)
+		<-release
+		return []string{"1.0.0", "1.1.0"}, nil
+	}
+	var wg sync.WaitGroup
+	results := make([][]string, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tags, err := FetchVersions(ctx, Images, "docker.io/library/nginx", fetch)
+			if err != nil {
+				t.Errorf("FetchVersions: %v", err)
+			}
+			results[i] = tags
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if _, err := FetchVersions(ctx, Images, "docker.io/library/nginx", fetch); err != nil {
+		t.Fatalf("FetchVersions: %v", err)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetched %d times, want 1", n)
+	}
+	for _, tags := range results {
+		if !reflect.DeepEqual(tags, []string{"1.0.0", "1.1.0"}) {
+			t.Errorf("FetchVersions = %v", tags)
+		}
+	}
+	if _, ok := snap.Get(Images, "docker.io/library/nginx"); !ok {
+		t.Error("fetched versions were not recorded")
+	}
+	if _, err := FetchVersions(ctx, Git, "https://example.com/repo.git", fetch); err != nil {
+		t.Fatalf("FetchVersions: %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("fetched %d times for two dependencies, want 2", n)
+	}
+}
+
+func TestFetch_MemoRetriesFailures(t *testing.T) {
+	ctx := WithMemo(context.Background(), NewMemo())
+	var fetches int
+	fetch := func(context.Context) ([]string, error) {
+		fetches++
+		if fetches == 1 {
+			return nil, errors.New("unavailable")
+		}
+		return []string{"1.0.0"}, nil
+	}
+	if _, err := FetchVersions(ctx, Images, "app", fetch); err == nil {
+		t.Fatal("expected the first fetch to fail")
+	}
+	tags, err := FetchVersions(ctx, Images, "app", fetch)
+	if err != nil || !reflect.DeepEqual(tags, []string{"1.0.0"}) {
+		t.Errorf("FetchVersions = %v, %v", tags, err)
+	}
+}
+
+func TestFetch_Offline(t *testing.T) {
+	snap := NewSnapshot(time.Time{})
+	snap.Add(Images, "app", []Candidate{{Version: "2.0.0"}})
+	ctx := WithMemo(WithOffline(context.Background(), snap), NewMemo())
+	tags, err := FetchVersions(ctx, Images, "app", func(context.Context) ([]string, error) {
+		t.Error("fetched while offline")
+		return nil, nil
+	})
+	if err != nil || !reflect.DeepEqual(tags, []string{"2.0.0"}) {
+		t.Errorf("FetchVersions = %v, %v", tags, err)
+	}
+}
@@ -0,0 +1,181 @@
+// Package mirror records the version candidates looked up by updates into a
+// snapshot on a connected machine, and serves them back from the snapshot so
+// updates can be computed in air-gapped networks.
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kinds of sources recorded in a snapshot.
+const (
+	// Images are container images, by name.
+	Images = "images"
+	// Charts are Helm charts, by repository URL and chart name.
+	Charts = "charts"
+	// Actions are GitHub repositories, by owner/repo.
+	Actions = "actions"
+	// Git are remote git repositories, by URL.
+	Git = "git"
+)
+
+// SnapshotFile is the file name of a snapshot in a mirror directory.
+const SnapshotFile = "metadata.json"
+
+// Candidate is a version available for a dependency.
+type Candidate struct {
+	Version string `json:"version"`
+	// AppVersion is the application version of a chart version.
+	AppVersion string `json:"appVersion,omitempty"`
+}
+
+// Snapshot holds the candidates of dependencies by kind and name.
+type Snapshot struct {
+	// Created is when the snapshot was exported.
+	Created time.Time `json:"created"`
+	// Sources maps kinds to the candidates of their dependencies by name.
+	Sources map[string]map[string][]Candidate `json:"sources"`
+
+	mu sync.Mutex
+}
+
+// NewSnapshot creates an empty snapshot.
+func NewSnapshot(created time.Time) *Snapshot {
+	return &Snapshot{Created: created, Sources: map[string]map[string][]Candidate{}}
+}
+
+// Load reads the snapshot at path, or the metadata.json snapshot of the
+// mirror directory at path.
+func Load(path string) (*Snapshot, error) {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		path = filepath.Join(path, SnapshotFile)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read metadata snapshot: %w", err)
+	}
+	s := NewSnapshot(time.Time{})
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("parse metadata snapshot %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Write writes the snapshot to path as indented JSON.
+func (s *Snapshot) Write(path string) error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encode metadata snapshot: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write metadata snapshot: %w", err)
+	}
+	return nil
+}
+
+// Add records the candidates of a dependency, sorted by version.
+func (s *Snapshot) Add(kind, name string, candidates []Candidate) {
+	candidates = append([]Candidate(nil), candidates...)
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Version < candidates[j].Version })
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Sources[kind] == nil {
+		s.Sources[kind] = map[string][]Candidate{}
+	}
+	s.Sources[kind][name] = candidates
+}
+
+// Get returns the candidates of a dependency.
+func (s *Snapshot) Get(kind, name string) ([]Candidate, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.Sources[kind][name]
+	return c, ok
+}
+
+type (
+	offlineKey  struct{}
+	recorderKey struct{}
+)
+
+// WithOffline returns a context serving the lookups made with it from the
+// snapshot instead of the network.
+func WithOffline(ctx context.Context, s *Snapshot) context.Context {
+	return context.WithValue(ctx, offlineKey{}, s)
+}
+
+// WithRecorder returns a context recording the lookups made with it into the
+// snapshot.
+func WithRecorder(ctx context.Context, s *Snapshot) context.Context {
+	return context.WithValue(ctx, recorderKey{}, s)
+}
+
+// Offline reports whether the context serves lookups from a snapshot.
+func Offline(ctx context.Context) bool {
+	_, ok := ctx.Value(offlineKey{}).(*Snapshot)
+	return ok
+}
+
+// Lookup returns the candidates of a dependency when the context is offline,
+// failing when the snapshot has none. It reports false when the context is
+// online and the candidates should be fetched.
+func Lookup(ctx context.Context, kind, name string) ([]Candidate, bool, error) {
+	s, ok := ctx.Value(offlineKey{}).(*Snapshot)
+	if !ok {
+		return nil, false, nil
+	}
+	c, ok := s.Get(kind, name)
+	if !ok {
+		return nil, true, fmt.Errorf("offline: %s %s is not in the metadata snapshot", kind, name)
+	}
+	return c, true, nil
+}
+
+// LookupVersions is Lookup returning the versions of the candidates.
+func LookupVersions(ctx context.Context, kind, name string) ([]string, bool, error) {
+	c, ok, err := Lookup(ctx, kind, name)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	return Versions(c), true, nil
+}
+
+// Record records the candidates fetched for a dependency when the context
+// has a recorder.
+func Record(ctx context.Context, kind, name string, candidates []Candidate) {
+	if s, ok := ctx.Value(recorderKey{}).(*Snapshot); ok {
+		s.Add(kind, name, candidates)
+	}
+}
+
+// RecordVersions is Record for plain versions.
+func RecordVersions(ctx context.Context, kind, name string, versions []string) {
+	Record(ctx, kind, name, candidates(versions))
+}
+
+// candidates returns the candidates of plain versions.
+func candidates(versions []string) []Candidate {
+	c := make([]Candidate, len(versions))
+	for i, v := range versions {
+		c[i] = Candidate{Version: v}
+	}
+	return c
+}
+
+// Versions returns the versions of candidates.
+func Versions(candidates []Candidate) []string {
+	v := make([]string, len(candidates))
+	for i, c := range candidates {
+		v[i] = c.Version
+	}
+	return v
+}
@@ -0,0 +1,45 @@
+package mirror
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordAndLookup(t *testing.T) {
+	created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	snap := NewSnapshot(created)
+	ctx := WithRecorder(context.Background(), snap)
+	RecordVersions(ctx, Images, "docker.io/library/nginx", []string{"1.27.0", "1.25.0"})
+	Record(ctx, Charts, "https://charts.example.com/app", []Candidate{{Version: "1.0.0", AppVersion: "v2"}})
+
+	if _, ok, _ := Lookup(context.Background(), Images, "docker.io/library/nginx"); ok {
+		t.Error("Lookup served an online context")
+	}
+
+	dir := t.TempDir()
+	if err := snap.Write(filepath.Join(dir, SnapshotFile)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !loaded.Created.Equal(created) {
+		t.Errorf("Created = %v, want %v", loaded.Created, created)
+	}
+	ctx = WithOffline(context.Background(), loaded)
+	tags, ok, err := LookupVersions(ctx, Images, "docker.io/library/nginx")
+	if !ok || err != nil || !reflect.DeepEqual(tags, []string{"1.25.0", "1.27.0"}) {
+		t.Errorf("LookupVersions = %v, %v, %v", tags, ok, err)
+	}
+	charts, ok, err := Lookup(ctx, Charts, "https://charts.example.com/app")
+	if !ok || err != nil || !reflect.DeepEqual(charts, []Candidate{{Version: "1.0.0", AppVersion: "v2"}}) {
+		t.Errorf("Lookup(chart) = %v, %v, %v", charts, ok, err)
+	}
+	if _, ok, err := Lookup(ctx, Images, "docker.io/library/redis"); !ok || err == nil {
+		t.Errorf("Lookup(missing) = %v, %v, want an offline error", ok, err)
+	}
+}
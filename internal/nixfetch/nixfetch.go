@@ -0,0 +1,322 @@
+// Package nixfetch bumps the versions of Nix package definitions fetching
+// their sources from GitHub, along with the hashes of their fetchers.
+package nixfetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/fsutil"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/policy"
+	"github.com/shikanime-studio/automata/internal/textfile"
+	"github.com/shikanime-studio/automata/internal/timeout"
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+var (
+	// githubURL matches the GitHub repository of a release asset or archive
+	// URL.
+	githubURL = regexp.MustCompile(`^https://github\.com/([\w.-]+)/([\w.-]+)/(?:releases/download|archive)/`)
+	// urlTag matches the path element of a GitHub URL naming the tag.
+	urlTag = regexp.MustCompile(`/(?:releases/download|archive(?:/refs/tags)?)/([^/]*\$\{version\}[^/]*?)(?:\.tar\.gz|\.zip)?(?:/|$)`)
+)
+
+// fetchers are the fetchers of the sources whose versions are bumped.
+var fetchers = []string{"fetchFromGitHub", "fetchurl", "fetchzip"}
+
+// sourceAttrs are the string attributes of the fetchers read and written.
+var sourceAttrs = []string{"owner", "repo", "rev", "tag", "url", "hash", "sha256"}
+
+// dependencyHashes are the hashes of dependencies vendored from the sources,
+// which cannot be computed without building.
+var dependencyHashes = []string{"vendorHash", "vendorSha256", "cargoHash", "cargoSha256", "npmDepsHash", "yarnHash", "outputHash"}
+
+// Prefetcher returns the SRI hash of the file at url, of its unpacked
+// content when unpack is set.
+type Prefetcher func(ctx context.Context, url string, unpack bool) (string, error)
+
+// PrefetchSRI hashes a URL with nix store prefetch-file, which also adds it
+// to the Nix store.
+func PrefetchSRI(ctx context.Context, url string, unpack bool) (string, error) {
+	ctx, cancel := timeout.Context(ctx, timeout.Nix)
+	defer cancel()
+	args := []string{"--extra-experimental-features", "nix-command", "store", "prefetch-file", "--json", "--hash-type", "sha256"}
+	if unpack {
+		args = append(args, "--unpack")
+	}
+	out, err := exec.CommandContext(ctx, "nix", append(args, url)...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("prefetch %s: %w: %s", url, err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("prefetch %s: %w", url, err)
+	}
+	var result struct {
+		Hash string `json:"hash"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil || result.Hash == "" {
+		return "", fmt.Errorf("prefetch %s: unexpected output %q", url, out)
+	}
+	return result.Hash, nil
+}
+
+// Updater bumps the versions and hashes of Nix package definitions.
+type Updater struct {
+	// GitHub resolves the latest tag of the repositories.
+	GitHub updater.Updater[*github.ActionRef]
+	// Prefetch hashes the sources of the new versions.
+	Prefetch Prefetcher
+}
+
+// NewUpdater creates an Updater prefetching sources with nix store
+// prefetch-file.
+func NewUpdater(u updater.Updater[*github.ActionRef]) *Updater {
+	return &Updater{GitHub: u, Prefetch: PrefetchSRI}
+}
+
+// Update walks root and updates every .nix file found, skipping hidden
+// directories and ignored files.
+func (u *Updater) Update(ctx context.Context, root string) error {
+	var g errgroup.Group
+	g.SetLimit(fsutil.Workers)
+	handler := func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(path) == ".nix" {
+			g.Go(func() error { return u.UpdateFile(ctx, path) })
+		}
+		return nil
+	}
+	handler = fsutil.SkipHidden(root, handler)
+	handler = fsutil.SkipIgnored(root, handler)
+	if err := fsutil.Walk(root, handler); err != nil {
+		return fmt.Errorf("scan for nix files: %w", err)
+	}
+	return g.Wait()
+}
+
+// UpdateFile updates the package definitions of the .nix file at path,
+// writing it back only when its content changed.
+func (u *Updater) UpdateFile(ctx context.Context, path string) error {
+	ctx = policy.WithFile(ctx, path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	content, err := u.Replace(ctx, string(data))
+	if err != nil {
+		return fmt.Errorf("update %s: %w", path, err)
+	}
+	if content == string(data) {
+		return nil
+	}
+	return fsutil.WriteFile(path, []byte(content), info.Mode().Perm(), fsutil.Backups(ctx))
+}
+
+// source is a fetcher call interpolating the version of its definition.
+type source struct {
+	kind  string
+	attrs map[string]string
+	// spans locates the values of the attributes in the content.
+	spans map[string][2]int
+}
+
+// template returns the attribute interpolating the version: the tag or rev
+// of fetchFromGitHub, the url of the other fetchers.
+func (s source) template() string {
+	if s.kind == "fetchFromGitHub" {
+		if s.attrs["tag"] != "" {
+			return "tag"
+		}
+		return "rev"
+	}
+	return "url"
+}
+
+// repository returns the GitHub repository the source is fetched from.
+func (s source) repository() (string, string, bool) {
+	if s.kind == "fetchFromGitHub" {
+		return s.attrs["owner"], s.attrs["repo"], s.attrs["owner"] != "" && s.attrs["repo"] != ""
+	}
+	m := githubURL.FindStringSubmatch(s.attrs["url"])
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// tag returns the tag of a version, interpolated in the source URL or rev.
+func (s source) tag(version string) (string, bool) {
+	t := s.attrs[s.template()]
+	if s.kind != "fetchFromGitHub" {
+		m := urlTag.FindStringSubmatch(t)
+		if m == nil {
+			return "", false
+		}
+		t = m[1]
+	}
+	if !strings.Contains(t, "${version}") {
+		return "", false
+	}
+	return strings.ReplaceAll(t, "${version}", version), true
+}
+
+// url returns the URL the source fetches for a version, and whether it is
+// unpacked.
+func (s source) url(version string) (string, bool) {
+	if s.kind == "fetchFromGitHub" {
+		tag, _ := s.tag(version)
+		return fmt.Sprintf("https://github.com/%s/%s/archive/%s.tar.gz", s.attrs["owner"], s.attrs["repo"], tag), true
+	}
+	return strings.ReplaceAll(s.attrs["url"], "${version}", version), s.kind == "fetchzip"
+}
+
+// hashAttr returns the attribute holding the hash of the source.
+func (s source) hashAttr() string {
+	if _, ok := s.attrs["hash"]; ok {
+		return "hash"
+	}
+	return "sha256"
+}
+
+// Replace bumps the version attributes of content followed by fetchers of
+// GitHub sources interpolating it, recomputing the hashes of these fetchers.
+// Definitions also pinning a hash of vendored dependencies are left alone,
+// as it cannot be computed without building them.
+func (u *Updater) Replace(ctx context.Context, content string) (string, error) {
+	attrs := textfile.NixAttrs(content)
+	var versions []int
+	for i, a := range attrs {
+		if a.Key == "version" && a.String && a.Plain {
+			versions = append(versions, i)
+		}
+	}
+	type edit struct {
+		start, end int
+		text       string
+	}
+	var edits []edit
+	for i, v := range versions {
+		end := len(attrs)
+		if i+1 < len(versions) {
+			end = versions[i+1]
+		}
+		segment := attrs[v+1 : end]
+		current := attrs[v].Value
+		sources, skipped := segmentSources(segment, current)
+		if len(sources) == 0 {
+			continue
+		}
+		owner, repo, ok := sources[0].repository()
+		if !ok {
+			continue
+		}
+		if skipped != "" {
+			slog.InfoContext(ctx, "skipped nix package with dependency hash",
+				"name", owner+"/"+repo, "version", current, "attr", skipped)
+			continue
+		}
+		latest, err := u.resolve(ctx, sources[0], owner, repo, current)
+		if err != nil {
+			return "", fmt.Errorf("resolve %s/%s: %w", owner, repo, err)
+		}
+		if latest == "" || latest == current {
+			continue
+		}
+		var hashes []edit
+		for _, s := range sources {
+			span, ok := s.spans[s.hashAttr()]
+			if !ok {
+				continue
+			}
+			url, unpack := s.url(latest)
+			hash, err := u.Prefetch(ctx, url, unpack)
+			if err != nil {
+				return "", err
+			}
+			hashes = append(hashes, edit{span[0], span[1], hash})
+		}
+		edits = append(edits, edit{attrs[v].Start, attrs[v].End, latest})
+		edits = append(edits, hashes...)
+		slog.InfoContext(ctx, "updated nix package version",
+			"name", owner+"/"+repo, "from", current, "to", latest, "file", policy.File(ctx))
+	}
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		content = content[:e.start] + e.text + content[e.end:]
+	}
+	return content, nil
+}
+
+// segmentSources returns the sources among the attributes following a
+// version attribute that interpolate the version, and the dependency hash
+// the definition pins, if any.
+func segmentSources(attrs []textfile.NixAttr, version string) ([]source, string) {
+	var (
+		sources []source
+		sets    = make(map[int]int)
+		skipped string
+	)
+	for _, a := range attrs {
+		name := a.Key[strings.LastIndex(a.Key, ".")+1:]
+		if slices.Contains(dependencyHashes, name) && skipped == "" {
+			skipped = name
+		}
+		if !slices.Contains(fetchers, a.Call) || !a.String || !slices.Contains(sourceAttrs, a.Key) {
+			continue
+		}
+		i, ok := sets[a.Set]
+		if !ok {
+			i = len(sources)
+			sets[a.Set] = i
+			sources = append(sources, source{kind: a.Call, attrs: map[string]string{}, spans: map[string][2]int{}})
+		}
+		sources[i].attrs[a.Key] = a.Value
+		sources[i].spans[a.Key] = [2]int{a.Start, a.End}
+	}
+	interpolating := sources[:0]
+	for _, s := range sources {
+		if _, ok := s.tag(version); ok {
+			interpolating = append(interpolating, s)
+		}
+	}
+	return interpolating, skipped
+}
+
+// resolve returns the latest version of a source from the tags of its
+// repository, or "" when none applies.
+func (u *Updater) resolve(ctx context.Context, s source, owner, repo, current string) (string, error) {
+	tag, _ := s.tag(current)
+	placeholder, _ := s.tag("\x00")
+	prefix, suffix, _ := strings.Cut(placeholder, "\x00")
+	transform := regexp.MustCompile("^" + regexp.QuoteMeta(prefix) + `(?P<version>.+)` + regexp.QuoteMeta(suffix) + "$")
+	latest, err := u.GitHub.Update(ctx, &github.ActionRef{Owner: owner, Repo: repo, Version: tag},
+		updater.WithTransform(transform))
+	if err != nil {
+		return "", err
+	}
+	m := transform.FindStringSubmatch(latest)
+	if m == nil {
+		return "", nil
+	}
+	return m[transform.SubexpIndex("version")], nil
+}
@@ -0,0 +1,139 @@
+package nixfetch
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+// latestTags resolves the latest tag of repositories from a fixed map.
+type latestTags map[string]string
+
+func (l latestTags) Update(_ context.Context, ref *github.ActionRef, _ ...updater.Option) (string, error) {
+	return l[ref.Owner+"/"+ref.Repo], nil
+}
+
+// prefetched records the URLs prefetched, hashing each to a fixed SRI hash.
+type prefetched []string
+
+func (p *prefetched) prefetch(_ context.Context, url string, unpack bool) (string, error) {
+	if unpack {
+		url += " (unpacked)"
+	}
+	*p = append(*p, url)
+	return "sha256-NEW=", nil
+}
+
+func TestReplaceBumpsVersionAndHashes(t *testing.T) {
+	var urls prefetched
+	u := &Updater{
+		GitHub:   latestTags{"junegunn/fzf": "v0.55.0", "sharkdp/bat": "v0.25.0"},
+		Prefetch: urls.prefetch,
+	}
+	content := `{
+  fzf = buildGoModule' rec {
+    pname = "fzf";
+    version = "0.54.0";
+    src = fetchFromGitHub {
+      owner = "junegunn";
+      repo = "fzf";
+      rev = "v${version}";
+      hash = "sha256-OLD=";
+    };
+  };
+  bat-bin = stdenv.mkDerivation rec {
+    pname = "bat";
+    version = "0.24.0";
+    src = fetchurl {
+      url = "https://github.com/sharkdp/bat/releases/download/v${version}/bat-v${version}-x86_64-unknown-linux-gnu.tar.gz";
+      sha256 = "0000000000000000000000000000000000000000000000000000";
+    };
+  };
+}
+`
+	got, err := u.Replace(context.Background(), content)
+	if err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	for _, want := range []string{
+		`version = "0.55.0";`,
+		`hash = "sha256-NEW=";`,
+		`version = "0.25.0";`,
+		`sha256 = "sha256-NEW=";`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in:\n%s", want, got)
+		}
+	}
+	wantURLs := []string{
+		"https://github.com/junegunn/fzf/archive/v0.55.0.tar.gz (unpacked)",
+		"https://github.com/sharkdp/bat/releases/download/v0.25.0/bat-v0.25.0-x86_64-unknown-linux-gnu.tar.gz",
+	}
+	if strings.Join(urls, "\n") != strings.Join(wantURLs, "\n") {
+		t.Fatalf("expected prefetches %v, got %v", wantURLs, urls)
+	}
+}
+
+func TestReplaceSkipsDependencyHashes(t *testing.T) {
+	u := &Updater{
+		GitHub: latestTags{"junegunn/fzf": "v0.55.0"},
+		Prefetch: func(context.Context, string, bool) (string, error) {
+			t.Fatal("unexpected prefetch")
+			return "", nil
+		},
+	}
+	content := `buildGoModule rec {
+  version = "0.54.0";
+  src = fetchFromGitHub {
+    owner = "junegunn";
+    repo = "fzf";
+    tag = "v${version}";
+    hash = "sha256-OLD=";
+  };
+  vendorHash = "sha256-VENDOR=";
+}
+`
+	got, err := u.Replace(context.Background(), content)
+	if err != nil || got != content {
+		t.Fatalf("expected content to be left alone, got %v:\n%s", err, got)
+	}
+}
+
+func TestReplaceSkipsCommentsAndStrings(t *testing.T) {
+	var urls prefetched
+	u := &Updater{
+		GitHub:   latestTags{"junegunn/fzf": "v0.55.0", "old/fzf": "v9.0.0"},
+		Prefetch: urls.prefetch,
+	}
+	content := `{ pkgs }:
+let
+  version = "0.54.0";
+in
+pkgs.buildGoModule {
+  pname = "fzf";
+  inherit version;
+  # src = fetchFromGitHub { owner = "old"; repo = "fzf"; rev = "v${version}"; hash = "x"; };
+  src = pkgs.fetchFromGitHub {
+    owner = "junegunn";
+    repo = "fzf";
+    rev = "v${version}";
+    hash = "sha256-OLD=";
+  };
+  meta.description = "version = \"1.0\"; a fuzzy finder";
+}
+`
+	got, err := u.Replace(context.Background(), content)
+	if err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	want := strings.NewReplacer(`"0.54.0"`, `"0.55.0"`, `"sha256-OLD="`, `"sha256-NEW="`).Replace(content)
+	if got != want {
+		t.Fatalf("unexpected content:\n%s", got)
+	}
+	if want := []string{"https://github.com/junegunn/fzf/archive/v0.55.0.tar.gz (unpacked)"}; strings.Join(urls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected prefetches %v, got %v", want, urls)
+	}
+}
@@ -0,0 +1,122 @@
+// Package nixpkgs bumps the nixpkgs pins of projects without flakes: niv and
+// npins sources and fetchTarball calls, to the latest release of the channel
+// they follow.
+package nixpkgs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/shikanime-studio/automata/internal/mirror"
+	"github.com/shikanime-studio/automata/internal/timeout"
+)
+
+// ChannelsURL is the base URL of the NixOS channels, redirecting to their
+// latest release.
+const ChannelsURL = "https://channels.nixos.org"
+
+// ArchiveURL is the URL of the nixpkgs source tarball of a revision.
+func ArchiveURL(rev string) string {
+	return "https://github.com/NixOS/nixpkgs/archive/" + rev + ".tar.gz"
+}
+
+// Release is the latest release of a channel.
+type Release struct {
+	// Name is the release name, e.g. nixos-24.05.1234.abcdef0.
+	Name string
+	// URL is the release directory, holding nixexprs.tar.xz.
+	URL string
+	// Rev is the nixpkgs revision of the release.
+	Rev string
+}
+
+// Client resolves the latest release of channels, caching them per channel.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	mu         sync.Mutex
+	cache      map[string]Release
+}
+
+// NewClient creates a client of the public NixOS channels.
+func NewClient() *Client {
+	return &Client{BaseURL: ChannelsURL, HTTPClient: http.DefaultClient}
+}
+
+// Latest returns the latest release of a channel, e.g. nixos-24.05 or
+// nixpkgs-unstable, following the redirect of its git-revision file to the
+// release directory.
+func (c *Client) Latest(ctx context.Context, channel string) (Release, error) {
+	if mirror.Offline(ctx) {
+		return Release{}, errors.New("channel releases are not recorded in metadata snapshots")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r, ok := c.cache[channel]; ok {
+		return r, nil
+	}
+	url := fmt.Sprintf("%s/%s/git-revision", strings.TrimSuffix(c.BaseURL, "/"), channel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Release{}, fmt.Errorf("create channel request: %w", err)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return Release{}, fmt.Errorf("query channel %s: %w", channel, err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.WarnContext(ctx, "close channel response", "err", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return Release{}, fmt.Errorf("query channel %s: unexpected status %s", channel, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Release{}, fmt.Errorf("read revision of %s: %w", channel, err)
+	}
+	dir := *resp.Request.URL
+	dir.Path = path.Dir(dir.Path)
+	r := Release{Name: path.Base(dir.Path), URL: dir.String(), Rev: strings.TrimSpace(string(data))}
+	if r.Rev == "" {
+		return Release{}, fmt.Errorf("channel %s has no revision", channel)
+	}
+	if c.cache == nil {
+		c.cache = make(map[string]Release)
+	}
+	c.cache[channel] = r
+	return r, nil
+}
+
+// Prefetcher returns the sha256 hash, in Nix base32, of the unpacked
+// tarball at url.
+type Prefetcher func(ctx context.Context, url string) (string, error)
+
+// PrefetchURL hashes a tarball with nix-prefetch-url --unpack, which also
+// adds it to the Nix store.
+func PrefetchURL(ctx context.Context, url string) (string, error) {
+	ctx, cancel := timeout.Context(ctx, timeout.Nix)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "nix-prefetch-url", "--unpack", "--type", "sha256", url).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("prefetch %s: %w: %s", url, err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("prefetch %s: %w", url, err)
+	}
+	lines := strings.Fields(string(out))
+	if len(lines) == 0 {
+		return "", fmt.Errorf("prefetch %s: no hash printed", url)
+	}
+	return lines[len(lines)-1], nil
+}
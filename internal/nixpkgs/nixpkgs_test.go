@@ -0,0 +1,128 @@
+package nixpkgs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	oldRev = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	newRev = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+// newTestUpdater serves the latest release of nixos-24.05 and
+// nixos-unstable, prefetching tarballs to a fixed hash.
+func newTestUpdater(t *testing.T) *Updater {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/nixos-24.05/git-revision":
+			http.Redirect(w, r, "/nixos/24.05/nixos-24.05.2000.bbbbbbb/git-revision", http.StatusFound)
+		case "/nixos-unstable/git-revision":
+			http.Redirect(w, r, "/nixos/unstable/nixos-24.11pre2000.bbbbbbb/git-revision", http.StatusFound)
+		case "/nixos/24.05/nixos-24.05.2000.bbbbbbb/git-revision",
+			"/nixos/unstable/nixos-24.11pre2000.bbbbbbb/git-revision":
+			_, _ = w.Write([]byte(newRev + "\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return &Updater{
+		Client:   &Client{BaseURL: srv.URL, HTTPClient: srv.Client()},
+		Prefetch: func(context.Context, string) (string, error) { return "newhash", nil },
+		Channel:  "nixos-unstable",
+	}
+}
+
+// writeFile writes content to root/name, returning its path.
+func writeFile(t *testing.T, root, name, content string) string {
+	t.Helper()
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return path
+}
+
+// readFile returns the content of the file at path.
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return string(data)
+}
+
+func TestUpdateBumpsNivAndNpinsSources(t *testing.T) {
+	root := t.TempDir()
+	niv := writeFile(t, root, "nix/sources.json", `{
+    "nixpkgs": {
+        "branch": "nixos-24.05",
+        "owner": "NixOS",
+        "repo": "nixpkgs",
+        "rev": "`+oldRev+`",
+        "sha256": "oldhash",
+        "type": "tarball",
+        "url": "https://github.com/NixOS/nixpkgs/archive/`+oldRev+`.tar.gz",
+        "url_template": "https://github.com/<owner>/<repo>/archive/<rev>.tar.gz"
+    }
+}
+`)
+	npins := writeFile(t, root, "npins/sources.json", `{
+  "pins": {
+    "nixos": {
+      "type": "Channel",
+      "name": "nixos-24.05",
+      "url": "https://releases.nixos.org/nixos/24.05/nixos-24.05.1000.aaaaaaa/nixexprs.tar.xz",
+      "hash": "oldhash"
+    }
+  },
+  "version": 3
+}
+`)
+	if err := newTestUpdater(t).Update(context.Background(), root); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got := readFile(t, niv)
+	if strings.Contains(got, oldRev) || strings.Contains(got, "oldhash") ||
+		!strings.Contains(got, `"url": "https://github.com/NixOS/nixpkgs/archive/`+newRev+`.tar.gz"`) ||
+		!strings.Contains(got, `"url_template": "https://github.com/<owner>/<repo>/archive/<rev>.tar.gz"`) {
+		t.Fatalf("unexpected niv sources:\n%s", got)
+	}
+	got = readFile(t, npins)
+	if !strings.Contains(got, `"url": "`) || !strings.Contains(got, "/nixos/24.05/nixos-24.05.2000.bbbbbbb/nixexprs.tar.xz") ||
+		!strings.Contains(got, `"hash": "newhash"`) {
+		t.Fatalf("unexpected npins sources:\n%s", got)
+	}
+}
+
+func TestUpdateBumpsFetchTarball(t *testing.T) {
+	root := t.TempDir()
+	path := writeFile(t, root, "default.nix", `let
+  pkgs = import (builtins.fetchTarball {
+    url = "https://github.com/NixOS/nixpkgs/archive/`+oldRev+`.tar.gz";
+    sha256 = "oldhash";
+  }) { };
+  stable = import (fetchTarball "https://releases.nixos.org/nixos/24.05/nixos-24.05.1000.aaaaaaa/nixexprs.tar.xz") { };
+in
+pkgs.hello
+`)
+	if err := newTestUpdater(t).Update(context.Background(), root); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got := readFile(t, path)
+	if !strings.Contains(got, "archive/"+newRev+".tar.gz") || !strings.Contains(got, `sha256 = "newhash";`) ||
+		!strings.Contains(got, "nixos-24.05.2000.bbbbbbb/nixexprs.tar.xz") {
+		t.Fatalf("unexpected nix file:\n%s", got)
+	}
+}
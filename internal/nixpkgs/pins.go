@@ -0,0 +1,326 @@
+package nixpkgs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/fsutil"
+	"github.com/shikanime-studio/automata/internal/policy"
+)
+
+var (
+	// archiveURL matches the nixpkgs tarball of a revision.
+	archiveURL = regexp.MustCompile(`https://github\.com/NixOS/nixpkgs/archive/([0-9a-f]{40})\.tar\.gz`)
+	// releaseURL matches the tarball of a stable channel release, capturing
+	// the release and channel names.
+	releaseURL = regexp.MustCompile(`https://releases\.nixos\.org/[\w./-]+/((nix(?:os|pkgs)-\d+\.\d+(?:-small)?)\.\d+\.[0-9a-f]+)/nixexprs\.tar\.xz`)
+	// sha256Attr matches the sha256 attribute of a fetchTarball call.
+	sha256Attr = regexp.MustCompile(`sha256\s*=\s*"([^"]*)"`)
+)
+
+// Updater bumps nixpkgs pins to the latest release of their channel.
+type Updater struct {
+	// Client resolves the latest release of channels.
+	Client *Client
+	// Prefetch hashes the tarballs pinned.
+	Prefetch Prefetcher
+	// Channel is followed by fetchTarball calls of nixpkgs revisions, which
+	// name no channel. They are left alone when unset.
+	Channel string
+}
+
+// NewUpdater creates an Updater of the public NixOS channels, prefetching
+// tarballs with nix-prefetch-url.
+func NewUpdater(channel string) *Updater {
+	return &Updater{Client: NewClient(), Prefetch: PrefetchURL, Channel: channel}
+}
+
+// edit replaces the span [start, end) of a file.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// Update walks root and bumps the nixpkgs pins of niv (nix/sources.json),
+// npins (npins/sources.json) and fetchTarball calls in .nix files, skipping
+// hidden directories and ignored files.
+func (u *Updater) Update(ctx context.Context, root string) error {
+	var g errgroup.Group
+	g.SetLimit(fsutil.Workers)
+	handler := func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		var update func(context.Context, []byte) ([]edit, error)
+		switch dir := filepath.Base(filepath.Dir(path)); {
+		case d.Name() == "sources.json" && dir == "nix":
+			update = u.updateNiv
+		case d.Name() == "sources.json" && dir == "npins":
+			update = u.updateNpins
+		case filepath.Ext(path) == ".nix":
+			update = u.updateNix
+		default:
+			return nil
+		}
+		g.Go(func() error { return u.updateFile(ctx, path, update) })
+		return nil
+	}
+	handler = fsutil.SkipHidden(root, handler)
+	handler = fsutil.SkipIgnored(root, handler)
+	if err := fsutil.Walk(root, handler); err != nil {
+		return fmt.Errorf("scan for nixpkgs pins: %w", err)
+	}
+	return g.Wait()
+}
+
+// updateFile applies the edits of update to the file at path.
+func (u *Updater) updateFile(ctx context.Context, path string, update func(context.Context, []byte) ([]edit, error)) error {
+	ctx = policy.WithFile(ctx, path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	edits, err := update(ctx, data)
+	if err != nil {
+		return fmt.Errorf("update %s: %w", path, err)
+	}
+	if len(edits) == 0 {
+		return nil
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	content := string(data)
+	for _, e := range edits {
+		content = content[:e.start] + e.text + content[e.end:]
+	}
+	return fsutil.WriteFile(path, []byte(content), info.Mode().Perm(), fsutil.Backups(ctx))
+}
+
+// pin is a nixpkgs pin about to be bumped.
+type pin struct {
+	name    string
+	channel string
+	// current is the version pinned: a revision, or a release name for
+	// channel pins.
+	current string
+	// release reports whether the pin follows channel release tarballs
+	// rather than revision tarballs.
+	release bool
+}
+
+// bump resolves the latest release of the channel of a pin and vets it with
+// the policies of the context. It returns the URL and hash to pin, and false
+// when the pin is current or the update was held.
+func (u *Updater) bump(ctx context.Context, p pin) (string, string, bool, error) {
+	r, err := u.Client.Latest(ctx, p.channel)
+	if err != nil {
+		return "", "", false, err
+	}
+	from, to, url := shortRev(p.current), shortRev(r.Rev), ArchiveURL(r.Rev)
+	if p.release {
+		from, to, url = p.current, r.Name, r.URL+"/nixexprs.tar.xz"
+	}
+	if from == to {
+		return "", "", false, nil
+	}
+	version, err := policy.Apply(ctx, policy.Proposal{Source: "nixpkgs", Name: p.name, From: from, To: to})
+	if err != nil || version != to {
+		return "", "", false, err
+	}
+	hash, err := u.Prefetch(ctx, url)
+	if err != nil {
+		return "", "", false, err
+	}
+	slog.InfoContext(ctx, "updated nixpkgs pin",
+		"source", "nixpkgs", "name", p.name, "channel", p.channel, "from", from, "to", to,
+		"file", policy.File(ctx))
+	return url, hash, true, nil
+}
+
+// shortRev abbreviates a revision.
+func shortRev(rev string) string {
+	if len(rev) > 7 {
+		return rev[:7]
+	}
+	return rev
+}
+
+// replaceString returns the edits replacing every JSON string value old of
+// data with new.
+func replaceString(data []byte, old, new string) []edit {
+	if old == "" {
+		return nil
+	}
+	quoted := `"` + old + `"`
+	var edits []edit
+	content := string(data)
+	for i := 0; ; {
+		j := strings.Index(content[i:], quoted)
+		if j < 0 {
+			return edits
+		}
+		start := i + j + 1
+		edits = append(edits, edit{start: start, end: start + len(old), text: new})
+		i = start + len(old)
+	}
+}
+
+// updateNiv bumps the nixpkgs sources of a niv sources.json following a
+// branch.
+func (u *Updater) updateNiv(ctx context.Context, data []byte) ([]edit, error) {
+	var sources map[string]struct {
+		Branch string `json:"branch"`
+		Owner  string `json:"owner"`
+		Repo   string `json:"repo"`
+		Rev    string `json:"rev"`
+		Sha256 string `json:"sha256"`
+		URL    string `json:"url"`
+	}
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return nil, fmt.Errorf("parse niv sources: %w", err)
+	}
+	var edits []edit
+	for _, name := range sortedKeys(sources) {
+		s := sources[name]
+		if s.Owner != "NixOS" || s.Repo != "nixpkgs" || s.Branch == "" || s.Rev == "" {
+			continue
+		}
+		url, hash, ok, err := u.bump(ctx, pin{name: name, channel: s.Branch, current: s.Rev})
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		rev := archiveURL.FindStringSubmatch(url)[1]
+		edits = append(edits, replaceString(data, s.Rev, rev)...)
+		edits = append(edits, replaceString(data, s.Sha256, hash)...)
+		edits = append(edits, replaceString(data, s.URL, url)...)
+	}
+	return edits, nil
+}
+
+// updateNpins bumps the nixpkgs channel pins, and git pins of the nixpkgs
+// GitHub repository following a branch, of an npins sources.json.
+func (u *Updater) updateNpins(ctx context.Context, data []byte) ([]edit, error) {
+	var sources struct {
+		Pins map[string]struct {
+			Type       string `json:"type"`
+			Name       string `json:"name"`
+			Repository struct {
+				Type  string `json:"type"`
+				Owner string `json:"owner"`
+				Repo  string `json:"repo"`
+			} `json:"repository"`
+			Branch   string `json:"branch"`
+			Revision string `json:"revision"`
+			URL      string `json:"url"`
+			Hash     string `json:"hash"`
+		} `json:"pins"`
+	}
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return nil, fmt.Errorf("parse npins sources: %w", err)
+	}
+	var edits []edit
+	for _, name := range sortedKeys(sources.Pins) {
+		s := sources.Pins[name]
+		var p pin
+		switch {
+		case s.Type == "Channel" && s.URL != "":
+			p = pin{name: name, channel: s.Name, current: path.Base(path.Dir(s.URL)), release: true}
+		case s.Type == "Git" && s.Repository.Type == "GitHub" && s.Repository.Owner == "NixOS" &&
+			s.Repository.Repo == "nixpkgs" && s.Branch != "" && s.Revision != "":
+			p = pin{name: name, channel: s.Branch, current: s.Revision}
+		default:
+			continue
+		}
+		url, hash, ok, err := u.bump(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if !p.release {
+			edits = append(edits, replaceString(data, s.Revision, archiveURL.FindStringSubmatch(url)[1])...)
+		}
+		edits = append(edits, replaceString(data, s.URL, url)...)
+		edits = append(edits, replaceString(data, s.Hash, hash)...)
+	}
+	return edits, nil
+}
+
+// updateNix bumps the nixpkgs tarballs fetched in a .nix file: stable channel
+// releases, and revisions when the Updater follows a channel. The sha256 of
+// the fetchTarball attribute set holding the URL, if any, is updated too.
+func (u *Updater) updateNix(ctx context.Context, data []byte) ([]edit, error) {
+	content := string(data)
+	var edits []edit
+	for _, m := range releaseURL.FindAllStringSubmatchIndex(content, -1) {
+		p := pin{name: "nixpkgs", channel: content[m[4]:m[5]], current: content[m[2]:m[3]], release: true}
+		found, err := u.bumpNix(ctx, content, m[0], m[1], p)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, found...)
+	}
+	if u.Channel == "" {
+		return edits, nil
+	}
+	for _, m := range archiveURL.FindAllStringSubmatchIndex(content, -1) {
+		p := pin{name: "nixpkgs", channel: u.Channel, current: content[m[2]:m[3]]}
+		found, err := u.bumpNix(ctx, content, m[0], m[1], p)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, found...)
+	}
+	return edits, nil
+}
+
+// bumpNix returns the edits bumping the URL at [start, end) of content and
+// the sha256 of its enclosing attribute set.
+func (u *Updater) bumpNix(ctx context.Context, content string, start, end int, p pin) ([]edit, error) {
+	url, hash, ok, err := u.bump(ctx, p)
+	if err != nil || !ok {
+		return nil, err
+	}
+	edits := []edit{{start: start, end: end, text: url}}
+	lo := strings.LastIndexAny(content[:start], "{}")
+	hi := strings.IndexAny(content[end:], "{}")
+	if lo < 0 || content[lo] != '{' || hi < 0 || content[end+hi] != '}' {
+		return edits, nil
+	}
+	if m := sha256Attr.FindStringSubmatchIndex(content[lo : end+hi]); m != nil {
+		edits = append(edits, edit{start: lo + m[2], end: lo + m[3], text: hash})
+	}
+	return edits, nil
+}
+
+// sortedKeys returns the keys of a map in order, so pins are bumped
+// deterministically.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,124 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+)
+
+// Annotator is a slog.Handler that writes GitHub Actions workflow commands
+// for applied and skipped updates, so runs annotate the files they touch,
+// before passing records to the next handler.
+type Annotator struct {
+	next  slog.Handler
+	attrs []slog.Attr
+	mu    *sync.Mutex
+	w     io.Writer
+}
+
+// NewAnnotator creates an Annotator writing workflow commands to w and
+// forwarding records to next.
+func NewAnnotator(next slog.Handler, w io.Writer) *Annotator {
+	return &Annotator{next: next, mu: &sync.Mutex{}, w: w}
+}
+
+// Enabled reports whether the level is annotated or handled by the next
+// handler.
+func (h *Annotator) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo || h.next.Enabled(ctx, level)
+}
+
+// Handle writes the workflow command of the record, if any, and forwards it
+// to the next handler when enabled.
+func (h *Annotator) Handle(ctx context.Context, r slog.Record) error {
+	if command := annotation(r); command != "" {
+		attrs := make(map[string]string)
+		var b strings.Builder
+		b.WriteString(r.Message)
+		add := func(a slog.Attr) bool {
+			if a.Key == logging.ReportKey {
+				return true
+			}
+			attrs[a.Key] = a.Value.String()
+			fmt.Fprintf(&b, " %s=%s", a.Key, a.Value.String())
+			return true
+		}
+		for _, a := range h.attrs {
+			add(a)
+		}
+		r.Attrs(add)
+		var props []string
+		if path := policy.Path(ctx); path != "" {
+			props = append(props, "file="+escapeProperty(artifactURI(path)))
+			line, col := position(Event{Attrs: attrs})
+			if line == 0 && attrs["from"] != "" {
+				if data, err := os.ReadFile(path); err == nil {
+					line, col = locate(data, attrs["name"], attrs["from"])
+				}
+			}
+			if line > 0 {
+				props = append(props, "line="+strconv.Itoa(line), "col="+strconv.Itoa(col))
+			}
+		}
+		props = append(props, "title=automata")
+		h.mu.Lock()
+		_, err := fmt.Fprintf(h.w, "::%s %s::%s\n", command, strings.Join(props, ","), escapeData(b.String()))
+		h.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("write annotation: %w", err)
+		}
+	}
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// annotation returns the workflow command annotating the record: notice for
+// applied updates, warning for held, queued or rejected ones, or "" when the
+// record is not annotated.
+func annotation(r slog.Record) string {
+	switch logging.KindOf(r) {
+	case logging.Updated:
+		return "notice"
+	case logging.Pending, logging.PendingMajor, logging.Queued, logging.Incompatible, logging.Rejected:
+		return "warning"
+	default:
+		return ""
+	}
+}
+
+// WithAttrs returns an Annotator sharing the same writer.
+func (h *Annotator) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.next = h.next.WithAttrs(attrs)
+	c.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &c
+}
+
+// WithGroup returns an Annotator sharing the same writer.
+func (h *Annotator) WithGroup(name string) slog.Handler {
+	c := *h
+	c.next = h.next.WithGroup(name)
+	return &c
+}
+
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property value of a workflow command.
+func escapeProperty(s string) string {
+	return strings.NewReplacer(
+		"%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C",
+	).Replace(s)
+}
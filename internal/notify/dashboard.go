@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+
+	"github.com/shikanime-studio/automata/internal/config"
+)
+
+// DefaultDashboardTitle is the title of the dashboard issue when none is
+// configured.
+const DefaultDashboardTitle = "Dependency Dashboard"
+
+// DashboardTemplate renders the dashboard issue body in Markdown.
+const DashboardTemplate = `This issue lists the dependencies tracked by automata and the outcome of its
+last run.
+{{- with .PendingMajors}}
+
+## Pending Major Upgrades
+{{range .}}
+- [ ] {{index .Attrs "name"}}: {{index .Attrs "from"}} → {{index .Attrs "to"}}
+{{- end}}
+{{- end}}
+{{- with .Pending}}
+
+## Pending Approval
+{{range .}}
+- [ ] {{index .Attrs "name"}}: {{index .Attrs "from"}} → {{index .Attrs "to"}}
+{{- end}}
+{{- end}}
+{{- with .Queued}}
+
+## Queued
+{{range .}}
+- {{index .Attrs "name"}}: {{index .Attrs "from"}} → {{index .Attrs "to"}}
+{{- end}}
+{{- end}}
+{{- with .EndOfLife}}
+
+## End of Life
+{{range .}}
+- {{index .Attrs "name"}}: {{index .Attrs "version"}} ({{index .Attrs "product"}} {{index .Attrs "cycle"}})
+{{- with index .Attrs "suggested"}}, upgrade to {{.}}{{end}}
+{{- end}}
+{{- end}}
+{{- with .LicenseChanges}}
+
+## License Changes
+{{range .}}
+- {{index .Attrs "name"}}: {{index .Attrs "from"}} → {{index .Attrs "to"}} ({{index .Attrs "from_license"}} → {{index .Attrs "to_license"}})
+{{- end}}
+{{- end}}
+{{- with .Incompatible}}
+
+## Incompatible Updates
+{{range .}}
+- {{index .Attrs "name"}} {{or (index .Attrs "to") (index .Attrs "version")}}: host {{index .Attrs "host"}} ({{index .Attrs "os"}}/{{index .Attrs "arch"}}) is unsupported
+{{- end}}
+{{- end}}
+{{- with .Unversioned}}
+
+## Unversioned Dependencies
+{{range .}}
+- {{index .Attrs "name"}}: {{index .Attrs "version"}} is not a version
+{{- end}}
+{{- end}}
+{{- with .RateLimited}}
+
+## Rate-Limited Lookups
+{{range .}}
+- {{.}}
+{{- end}}
+{{- end}}
+{{- if or .Failures .Err}}
+
+## Errors
+{{range .Failures}}
+- {{.}}
+{{- end}}
+{{- if .Err}}
+- {{.Err}}
+{{- end}}
+{{- end}}
+
+## Tracked Dependencies
+{{with .Dependencies}}
+| Source | Name | Version | Latest |
+| --- | --- | --- | --- |
+{{- range .}}
+| {{index .Attrs "source"}} | {{index .Attrs "name"}} | {{index .Attrs "version"}} | {{index .Attrs "latest"}} |
+{{- end}}
+{{- else}}
+No dependencies were looked up.
+{{- end}}
+`
+
+// IssueWriter creates or updates an issue by title.
+type IssueWriter interface {
+	UpsertIssue(ctx context.Context, owner, repo, title, body string) (string, error)
+}
+
+// Dashboard keeps a GitHub issue summarizing the dependencies of a run.
+type Dashboard struct {
+	Client   IssueWriter
+	Owner    string
+	Repo     string
+	Title    string
+	Template *template.Template
+}
+
+// NewDashboard creates a dashboard publishing to the configured repository.
+func NewDashboard(c IssueWriter, d config.Dashboard) (*Dashboard, error) {
+	owner, repo, ok := strings.Cut(d.Repository, "/")
+	if !ok || owner == "" || repo == "" {
+		return nil, fmt.Errorf("dashboard requires owner/repo repository, got %q", d.Repository)
+	}
+	title := d.Title
+	if title == "" {
+		title = DefaultDashboardTitle
+	}
+	text := d.Template
+	if text == "" {
+		text = DashboardTemplate
+	}
+	tmpl, err := template.New("dashboard").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse dashboard template: %w", err)
+	}
+	return &Dashboard{Client: c, Owner: owner, Repo: repo, Title: title, Template: tmpl}, nil
+}
+
+// Notify renders the report into the dashboard issue.
+func (d *Dashboard) Notify(ctx context.Context, r Report) error {
+	body, err := r.Render(d.Template)
+	if err != nil {
+		return err
+	}
+	url, err := d.Client.UpsertIssue(ctx, d.Owner, d.Repo, d.Title, body)
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "updated dependency dashboard", "url", url)
+	return nil
+}
@@ -0,0 +1,352 @@
+// Package notify reports the outcome of an update run to chat webhooks or
+// by email.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/logging"
+)
+
+// DefaultTemplate renders the report when a notification sets no template.
+const DefaultTemplate = `automata: {{len .Updates}} update(s), {{len .Failures}} failure(s)
+{{- range .Updates}}
+- {{.}}
+{{- end}}
+{{- range .Failures}}
+- failed: {{.}}
+{{- end}}
+{{- range .PendingMajors}}
+- pending major: {{.}}
+{{- end}}
+{{- range .Pending}}
+- pending approval: {{.}}
+{{- end}}
+{{- range .Queued}}
+- queued: {{.}}
+{{- end}}
+{{- range .RateLimited}}
+- rate limited: {{.}}
+{{- end}}
+{{- range .EndOfLife}}
+- end of life: {{.}}
+{{- end}}
+{{- range .LicenseChanges}}
+- license changed: {{.}}
+{{- end}}
+{{- range .Incompatible}}
+- incompatible: {{.}}
+{{- end}}
+{{- range .Unversioned}}
+- unversioned: {{.}}
+{{- end}}
+{{- if .Err}}
+error: {{.Err}}
+{{- end}}`
+
+// Event is a log record collected during a run.
+type Event struct {
+	Message string            `json:"message"`
+	Attrs   map[string]string `json:"attrs"`
+	keys    []string
+}
+
+// String returns the message followed by its attributes as key=value pairs.
+func (e Event) String() string {
+	var b strings.Builder
+	b.WriteString(e.Message)
+	for _, k := range e.keys {
+		fmt.Fprintf(&b, " %s=%s", k, e.Attrs[k])
+	}
+	return b.String()
+}
+
+// Report summarizes an update run.
+type Report struct {
+	// Updates are the applied updates.
+	Updates []Event
+	// Failures are the updates that failed during the run.
+	Failures []Event
+	// Pending are the updates held for manual approval by a policy.
+	Pending []Event
+	// PendingMajors are the major-version bumps held for review.
+	PendingMajors []Event
+	// Queued are the updates held until the next schedule window.
+	Queued []Event
+	// RateLimited are the lookups refused by rate limits.
+	RateLimited []Event
+	// EndOfLife are the dependencies pinned to a release cycle past its end
+	// of life.
+	EndOfLife []Event
+	// LicenseChanges are the updates changing the license of a dependency.
+	LicenseChanges []Event
+	// Incompatible are the updates skipped as unsupported by the platform of
+	// a host, e.g. k0s versions dropping an architecture of a k0sctl cluster.
+	Incompatible []Event
+	// Unversioned are the dependencies skipped as their current version is
+	// not a version, e.g. a branch name.
+	Unversioned []Event
+	// Tracked are the dependency lookups, one per occurrence.
+	Tracked []Event
+	// Err is the error the run ended with, if any.
+	Err error
+}
+
+// Empty reports whether the run neither updated nor failed anything.
+func (r Report) Empty() bool {
+	return len(r.Updates) == 0 && len(r.Failures) == 0 && len(r.Pending) == 0 &&
+		len(r.PendingMajors) == 0 && len(r.Queued) == 0 && len(r.RateLimited) == 0 &&
+		len(r.EndOfLife) == 0 && len(r.LicenseChanges) == 0 && len(r.Incompatible) == 0 &&
+		len(r.Unversioned) == 0 && r.Err == nil
+}
+
+// Dependencies returns the tracked dependencies, deduplicated by source, name
+// and version and sorted.
+func (r Report) Dependencies() []Event {
+	seen := make(map[string]bool)
+	var deps []Event
+	for _, e := range r.Tracked {
+		key := e.Attrs["source"] + "\x00" + e.Attrs["name"] + "\x00" + e.Attrs["version"]
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		deps = append(deps, e)
+	}
+	sort.Slice(deps, func(i, j int) bool {
+		a, b := deps[i].Attrs, deps[j].Attrs
+		if a["source"] != b["source"] {
+			return a["source"] < b["source"]
+		}
+		if a["name"] != b["name"] {
+			return a["name"] < b["name"]
+		}
+		return a["version"] < b["version"]
+	})
+	return deps
+}
+
+// Render executes the template over the report.
+func (r Report) Render(tmpl *template.Template) (string, error) {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, r); err != nil {
+		return "", fmt.Errorf("render notification: %w", err)
+	}
+	return b.String(), nil
+}
+
+// WriteEvents writes events to path as a JSON array.
+func WriteEvents(path string, events []Event) error {
+	if events == nil {
+		events = []Event{}
+	}
+	data, err := json.MarshalIndent(events, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal events: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
+
+// Recorder is a slog.Handler that collects the records reported as a
+// logging.Kind, such as applied, held or failed updates, into a Report before
+// passing records to the next handler. Plain logs are only passed on, so a
+// warning is a failure only when reported as one.
+type Recorder struct {
+	next   slog.Handler
+	attrs  []slog.Attr
+	mu     *sync.Mutex
+	report *Report
+}
+
+// NewRecorder creates a Recorder forwarding records to next.
+func NewRecorder(next slog.Handler) *Recorder {
+	return &Recorder{next: next, mu: &sync.Mutex{}, report: &Report{}}
+}
+
+// Enabled reports whether the level is recorded or handled by the next
+// handler. Debug records are always enabled to track looked up dependencies.
+func (h *Recorder) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelDebug || h.next.Enabled(ctx, level)
+}
+
+// Handle records the event and forwards it to the next handler when enabled.
+func (h *Recorder) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	events := h.category(r)
+	h.mu.Unlock()
+	if events != nil {
+		e := Event{Message: r.Message, Attrs: make(map[string]string)}
+		add := func(a slog.Attr) bool {
+			if a.Key == logging.ReportKey {
+				return true
+			}
+			if _, ok := e.Attrs[a.Key]; !ok {
+				e.keys = append(e.keys, a.Key)
+			}
+			e.Attrs[a.Key] = a.Value.String()
+			return true
+		}
+		for _, a := range h.attrs {
+			add(a)
+		}
+		r.Attrs(add)
+		h.mu.Lock()
+		*events = append(*events, e)
+		h.mu.Unlock()
+	}
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// category returns the report list of the kind the record is reported as,
+// or nil for plain logs. The caller must hold the lock.
+func (h *Recorder) category(r slog.Record) *[]Event {
+	switch logging.KindOf(r) {
+	case logging.Tracked:
+		return &h.report.Tracked
+	case logging.Updated:
+		return &h.report.Updates
+	case logging.Failed:
+		return &h.report.Failures
+	case logging.PendingMajor:
+		return &h.report.PendingMajors
+	case logging.Pending:
+		return &h.report.Pending
+	case logging.Queued:
+		return &h.report.Queued
+	case logging.Incompatible:
+		return &h.report.Incompatible
+	case logging.Unversioned:
+		return &h.report.Unversioned
+	case logging.RateLimited:
+		return &h.report.RateLimited
+	case logging.EndOfLife:
+		return &h.report.EndOfLife
+	case logging.LicenseChanged:
+		return &h.report.LicenseChanges
+	default:
+		return nil
+	}
+}
+
+// WithAttrs returns a Recorder sharing the same report.
+func (h *Recorder) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.next = h.next.WithAttrs(attrs)
+	c.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &c
+}
+
+// WithGroup returns a Recorder sharing the same report.
+func (h *Recorder) WithGroup(name string) slog.Handler {
+	c := *h
+	c.next = h.next.WithGroup(name)
+	return &c
+}
+
+// Report returns a copy of the events collected so far, each category sorted
+// by sortEvents so reports of identical runs are identical.
+func (h *Recorder) Report() Report {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return Report{
+		Updates:        sortEvents(h.report.Updates),
+		Failures:       sortEvents(h.report.Failures),
+		Pending:        sortEvents(h.report.Pending),
+		PendingMajors:  sortEvents(h.report.PendingMajors),
+		Queued:         sortEvents(h.report.Queued),
+		RateLimited:    sortEvents(h.report.RateLimited),
+		EndOfLife:      sortEvents(h.report.EndOfLife),
+		LicenseChanges: sortEvents(h.report.LicenseChanges),
+		Incompatible:   sortEvents(h.report.Incompatible),
+		Unversioned:    sortEvents(h.report.Unversioned),
+		Tracked:        sortEvents(h.report.Tracked),
+	}
+}
+
+// sortEvents returns a copy of events sorted by file, source and name, then
+// by their rendering, rather than by the order concurrent updates logged
+// them in.
+func sortEvents(events []Event) []Event {
+	sorted := append([]Event(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		for _, k := range []string{"file", "path", "source", "name"} {
+			if a.Attrs[k] != b.Attrs[k] {
+				return a.Attrs[k] < b.Attrs[k]
+			}
+		}
+		return a.String() < b.String()
+	})
+	return sorted
+}
+
+// Notifier delivers a report.
+type Notifier interface {
+	Notify(ctx context.Context, r Report) error
+}
+
+// New builds the notifier declared by a config entry.
+func New(n config.Notification) (Notifier, error) {
+	text := n.Template
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New(n.Type).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	switch n.Type {
+	case "slack", "discord", "matrix":
+		return NewWebhook(n.Type, n.URL, n.Room, n.Token, tmpl)
+	case "smtp":
+		return NewEmail(n.SMTP, tmpl)
+	default:
+		return nil, fmt.Errorf("unknown notification type %q", n.Type)
+	}
+}
+
+// NewAll builds the notifiers declared in the config file.
+func NewAll(decls []config.Notification) ([]Notifier, error) {
+	out := make([]Notifier, 0, len(decls))
+	for i, d := range decls {
+		n, err := New(d)
+		if err != nil {
+			return nil, fmt.Errorf("notification %d: %w", i, err)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// NotifyAll delivers the report to every notifier, returning the joined
+// errors of those that failed.
+func NotifyAll(ctx context.Context, notifiers []Notifier, r Report) error {
+	errs := make([]error, len(notifiers))
+	var wg sync.WaitGroup
+	for i, n := range notifiers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = n.Notify(ctx, r)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,366 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+)
+
+func TestRecorderCollectsUpdatesAndFailures(t *testing.T) {
+	rec := NewRecorder(slog.NewTextHandler(io.Discard, nil))
+	log := slog.New(rec).With("path", "app")
+	log.Info("updated image", logging.Updated.Attr(), "from", "1.0.0", "to", "1.1.0")
+	log.Info("updated dependency dashboard")
+	log.Warn("rule update failed", logging.Failed.Attr(), "err", "boom")
+	log.Warn("interrupted, finishing in-flight writes")
+
+	r := rec.Report()
+	if len(r.Updates) != 1 || len(r.Failures) != 1 {
+		t.Fatalf("expected 1 update and 1 failure, got %+v", r)
+	}
+	if got := r.Updates[0].String(); got != "updated image path=app from=1.0.0 to=1.1.0" {
+		t.Fatalf("unexpected update event %q", got)
+	}
+}
+
+func TestRecorderSortsEvents(t *testing.T) {
+	rec := NewRecorder(slog.NewTextHandler(io.Discard, nil))
+	log := slog.New(rec)
+	log.Info("updated image", logging.Updated.Attr(), "file", "b.yaml", "name", "redis")
+	log.Info("updated image", logging.Updated.Attr(), "file", "a.yaml", "name", "redis")
+	log.Info("updated image", logging.Updated.Attr(), "file", "a.yaml", "name", "nginx")
+
+	var got []string
+	for _, e := range rec.Report().Updates {
+		got = append(got, e.Attrs["file"]+":"+e.Attrs["name"])
+	}
+	if strings.Join(got, ",") != "a.yaml:nginx,a.yaml:redis,b.yaml:redis" {
+		t.Fatalf("unexpected update order %v", got)
+	}
+}
+
+func TestRecorderCollectsLicenseChanges(t *testing.T) {
+	rec := NewRecorder(slog.NewTextHandler(io.Discard, nil))
+	slog.New(rec).Warn("license changed", logging.LicenseChanged.Attr(), "name", "hashicorp/terraform",
+		"from_license", "MPL-2.0", "to_license", "BUSL-1.1")
+
+	r := rec.Report()
+	if len(r.LicenseChanges) != 1 || len(r.Failures) != 0 || r.Empty() {
+		t.Fatalf("expected 1 license change, got %+v", r)
+	}
+}
+
+func TestRecorderCollectsIncompatibleUpdates(t *testing.T) {
+	rec := NewRecorder(slog.NewTextHandler(io.Discard, nil))
+	slog.New(rec).Info("incompatible k0s version rejected update", logging.Incompatible.Attr(), "name", "k0s",
+		"from", "v1.30.4+k0s.0", "to", "v1.31.1+k0s.0", "arch", "arm")
+
+	r := rec.Report()
+	if len(r.Incompatible) != 1 || len(r.Updates) != 0 || r.Empty() {
+		t.Fatalf("expected 1 incompatible update, got %+v", r)
+	}
+}
+
+func TestRecorderCollectsUnversionedDependencies(t *testing.T) {
+	rec := NewRecorder(slog.NewTextHandler(io.Discard, nil))
+	slog.New(rec).Info("unversioned image skipped", logging.Unversioned.Attr(), "name", "app", "version", "main")
+
+	r := rec.Report()
+	if len(r.Unversioned) != 1 || len(r.Updates) != 0 || r.Empty() {
+		t.Fatalf("expected 1 unversioned dependency, got %+v", r)
+	}
+}
+
+func TestWebhookPostsRenderedReport(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	n, err := New(config.Notification{
+		Type:     "slack",
+		URL:      srv.URL,
+		Template: "{{len .Updates}} updates{{if .Err}}, {{.Err}}{{end}}",
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	r := Report{Updates: []Event{{Message: "updated image"}}, Err: errors.New("boom")}
+	if err := n.Notify(context.Background(), r); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if got["text"] != "1 updates, boom" {
+		t.Fatalf("unexpected payload %v", got)
+	}
+}
+
+func TestMatrixRequiresRoomAndToken(t *testing.T) {
+	_, err := New(config.Notification{Type: "matrix", URL: "https://matrix.org"})
+	if err == nil || !strings.Contains(err.Error(), "room and token") {
+		t.Fatalf("expected matrix config error, got %v", err)
+	}
+}
+
+func TestEmailSendsRenderedReport(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	data := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost")
+		var body strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					data <- body.String()
+					reply("250 ok")
+					continue
+				}
+				body.WriteString(line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO", "HELO", "MAIL", "RCPT":
+				reply("250 ok")
+			case "DATA":
+				inData = true
+				reply("354 go ahead")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 unknown")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	n, err := New(config.Notification{
+		Type:     "smtp",
+		Template: "{{len .Updates}} updates",
+		SMTP: config.SMTP{
+			Host: host,
+			Port: p,
+			TLS:  "none",
+			From: "automata@example.com",
+			To:   []string{"ops@example.com"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	r := Report{Updates: []Event{{Message: "updated image"}}}
+	if err := n.Notify(context.Background(), r); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	got := <-data
+	if !strings.Contains(got, "Subject: automata update report\r\n") ||
+		!strings.HasSuffix(got, "\r\n\r\n1 updates\r\n") {
+		t.Fatalf("unexpected message %q", got)
+	}
+}
+
+type fakeIssueWriter struct {
+	title, body string
+}
+
+func (f *fakeIssueWriter) UpsertIssue(_ context.Context, _, _, title, body string) (string, error) {
+	f.title, f.body = title, body
+	return "https://github.com/org/infra/issues/1", nil
+}
+
+func TestDashboardRendersTrackedDependencies(t *testing.T) {
+	rec := NewRecorder(slog.NewTextHandler(io.Discard, nil))
+	log := slog.New(rec)
+	for range 2 {
+		log.Debug("tracked dependency", logging.Tracked.Attr(), "source", "image", "name", "nginx", "version", "1.25", "latest", "1.27")
+	}
+	log.Info("pending major update", logging.PendingMajor.Attr(), "name", "postgres", "from", "16", "to", "17")
+	log.Warn("rate limited lookup", logging.RateLimited.Attr(), "action", "actions/checkout@v4")
+
+	w := &fakeIssueWriter{}
+	d, err := NewDashboard(w, config.Dashboard{Repository: "org/infra"})
+	if err != nil {
+		t.Fatalf("new dashboard: %v", err)
+	}
+	if err := d.Notify(context.Background(), rec.Report()); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if w.title != DefaultDashboardTitle {
+		t.Fatalf("unexpected title %q", w.title)
+	}
+	for _, want := range []string{
+		"- [ ] postgres: 16 → 17",
+		"## Rate-Limited Lookups",
+		"| image | nginx | 1.25 | 1.27 |",
+	} {
+		if !strings.Contains(w.body, want) {
+			t.Fatalf("expected body to contain %q, got:\n%s", want, w.body)
+		}
+	}
+	if strings.Count(w.body, "| nginx |") != 1 {
+		t.Fatalf("expected deduplicated dependencies, got:\n%s", w.body)
+	}
+}
+
+func TestWriteSARIFLocatesOutdatedDependencies(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "kustomization.yaml")
+	content := "images:\n  - name: nginx\n    newTag: 1.0.0\n  - name: redis\n    newTag: 7.0.0\n"
+	if err := os.WriteFile(manifest, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	tracked := func(name, version, latest string) Event {
+		return Event{Attrs: map[string]string{
+			"source": "image", "name": name, "version": version, "latest": latest, "path": manifest,
+		}}
+	}
+	r := Report{Tracked: []Event{
+		tracked("nginx", "1.0.0", "1.1.0"),
+		tracked("nginx", "1.0.0", "1.1.0"),
+		tracked("redis", "7.0.0", "7.0.0"),
+		tracked("postgres", "15.0", "16.0"),
+	}}
+	out := filepath.Join(dir, "automata.sarif")
+	if err := WriteSARIF(out, r); err != nil {
+		t.Fatalf("write sarif: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected log %+v", log)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %+v", results)
+	}
+	loc := results[0].Locations[0].PhysicalLocation
+	if results[0].RuleID != SARIFRule || loc.Region.StartLine != 3 || loc.Region.StartColumn != 13 {
+		t.Fatalf("unexpected result %+v", results[0])
+	}
+}
+
+func TestWriteSARIFUsesRecordedPositions(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "deployment.yaml")
+	content := "# nginx 1.0.0 is pinned below\nimage: nginx:1.0.0\nsidecar: redis:7.0.0\n"
+	if err := os.WriteFile(manifest, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	r := Report{
+		Tracked: []Event{
+			{Attrs: map[string]string{
+				"source": "image", "name": "nginx", "version": "1.0.0", "latest": "1.1.0",
+				"path": manifest, "line": "2", "column": "8",
+			}},
+			{Attrs: map[string]string{
+				"source": "image", "name": "redis", "version": "7.0.0", "latest": "7.2.0",
+				"path": manifest, "line": "3", "column": "10",
+			}},
+		},
+		Updates: []Event{{Attrs: map[string]string{
+			"source": "image", "name": "redis", "from": "7.0.0", "to": "7.2.0", "path": manifest,
+		}}},
+	}
+	results, err := sarifResults(r.Tracked, r.Updates)
+	if err != nil {
+		t.Fatalf("sarif results: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %+v", results)
+	}
+	region := results[0].Locations[0].PhysicalLocation.Region
+	if results[0].Properties["name"] != "nginx" || region.StartLine != 2 || region.StartColumn != 8 {
+		t.Fatalf("unexpected result %+v", results[0])
+	}
+}
+
+func TestAnnotatorWritesWorkflowCommands(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("values.yaml", []byte("image:\n  tag: 1.0.0\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var out strings.Builder
+	log := slog.New(NewAnnotator(slog.NewTextHandler(io.Discard, nil), &out))
+	ctx := policy.WithFile(context.Background(), "values.yaml")
+	log.InfoContext(ctx, "updated image", logging.Updated.Attr(), "from", "1.0.0", "to", "1.1.0")
+	log.InfoContext(ctx, "pending approval", logging.Pending.Attr(), "name", "nginx", "from", "1.0.0", "to", "2.0.0",
+		"line", 1, "column", 1)
+	log.InfoContext(ctx, "updated dependency dashboard")
+	log.WarnContext(ctx, "rule update failed", logging.Failed.Attr(), "err", "boom")
+
+	want := "::notice file=values.yaml,line=2,col=8,title=automata::updated image from=1.0.0 to=1.1.0\n" +
+		"::warning file=values.yaml,line=1,col=1,title=automata::pending approval name=nginx from=1.0.0 to=2.0.0 line=1 column=1\n"
+	if got := out.String(); got != want {
+		t.Fatalf("unexpected annotations %q, want %q", got, want)
+	}
+}
+
+func TestStepSummaryLinksUpdates(t *testing.T) {
+	r := Report{
+		Updates: []Event{
+			{Message: "updated dependency", Attrs: map[string]string{
+				"source": "github", "name": "actions/checkout", "from": "v3", "to": "v4",
+				"path": ".github/workflows/ci.yaml",
+				"link": "https://github.com/actions/checkout/releases/tag/v4",
+			}},
+			{Message: "updated dependency", Attrs: map[string]string{
+				"source": "github", "name": "actions/setup-go", "from": "v3", "to": "v4",
+				"path": ".github/workflows/ci.yaml",
+				"link": "https://github.com/actions/setup-go/releases/tag/v4",
+			}},
+			{Message: "updated dependency", Attrs: map[string]string{
+				"source": "plugin", "name": "go", "from": "1.22", "to": "1.23",
+			}},
+		},
+	}
+	want := "## automata\n\n" +
+		"3 update(s), 0 failure(s), 0 pending, 0 queued\n\n" +
+		"| Dependency | From | To | File |\n| --- | --- | --- | --- |\n" +
+		"| actions/checkout | `v3` | [v4](https://github.com/actions/checkout/releases/tag/v4) | `.github/workflows/ci.yaml` |\n" +
+		"| actions/setup-go | `v3` | [v4](https://github.com/actions/setup-go/releases/tag/v4) | `.github/workflows/ci.yaml` |\n" +
+		"| go | `1.22` | `1.23` |  |\n"
+	if got := StepSummary(r); got != want {
+		t.Fatalf("unexpected summary:\n%s\nwant:\n%s", got, want)
+	}
+}
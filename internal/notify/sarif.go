@@ -0,0 +1,219 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// SARIFRule is the identifier of the outdated dependency findings.
+const SARIFRule = "outdated-dependency"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+}
+
+// WriteSARIF writes the tracked dependencies of the report lagging behind
+// their latest version to file as a SARIF 2.1.0 log, so code scanning can
+// annotate the line each version is pinned on. Dependencies updated during
+// the run are left out, as are those whose position is unknown and whose
+// pinned version no longer appears in their file.
+func WriteSARIF(file string, report Report) error {
+	results, err := sarifResults(report.Tracked, report.Updates)
+	if err != nil {
+		return err
+	}
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "automata",
+				InformationURI: "https://github.com/shikanime-studio/automata",
+				Rules: []sarifRule{{
+					ID:               SARIFRule,
+					ShortDescription: sarifMessage{Text: "Dependency has a newer version"},
+				}},
+			}},
+			Results: results,
+		}},
+	}
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal sarif: %w", err)
+	}
+	if err := os.WriteFile(file, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", file, err)
+	}
+	return nil
+}
+
+// sarifResults locates the outdated tracked dependencies in their files, at
+// the position their lookup recorded, if any.
+func sarifResults(tracked, updates []Event) ([]sarifResult, error) {
+	results := []sarifResult{}
+	contents := make(map[string][]byte)
+	seen := make(map[string]bool)
+	for _, e := range updates {
+		seen[e.Attrs["path"]+"\x00"+e.Attrs["name"]+"\x00"+e.Attrs["from"]] = true
+	}
+	for _, e := range tracked {
+		file, version, latest := e.Attrs["path"], e.Attrs["version"], e.Attrs["latest"]
+		if file == "" || version == "" || latest == "" || latest == version {
+			continue
+		}
+		key := file + "\x00" + e.Attrs["name"] + "\x00" + version
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		line, column := position(e)
+		if line == 0 {
+			data, ok := contents[file]
+			if !ok {
+				var err error
+				data, err = os.ReadFile(file)
+				if err != nil && !os.IsNotExist(err) {
+					return nil, fmt.Errorf("read %s: %w", file, err)
+				}
+				contents[file] = data
+			}
+			line, column = locate(data, e.Attrs["name"], version)
+		}
+		if line == 0 {
+			continue
+		}
+		results = append(results, sarifResult{
+			RuleID: SARIFRule,
+			Level:  "warning",
+			Message: sarifMessage{
+				Text: fmt.Sprintf("%s %s is outdated, latest is %s", e.Attrs["name"], version, latest),
+			},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: artifactURI(file)},
+					Region:           sarifRegion{StartLine: line, StartColumn: column},
+				},
+			}},
+			Properties: map[string]string{
+				"source":  e.Attrs["source"],
+				"name":    e.Attrs["name"],
+				"version": version,
+				"latest":  latest,
+			},
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i].Locations[0].PhysicalLocation, results[j].Locations[0].PhysicalLocation
+		if a.ArtifactLocation.URI != b.ArtifactLocation.URI {
+			return a.ArtifactLocation.URI < b.ArtifactLocation.URI
+		}
+		return a.Region.StartLine < b.Region.StartLine
+	})
+	return results, nil
+}
+
+// position returns the line and column an event recorded for the node
+// holding the version, or zeros.
+func position(e Event) (int, int) {
+	line, err := strconv.Atoi(e.Attrs["line"])
+	if err != nil {
+		return 0, 0
+	}
+	column, err := strconv.Atoi(e.Attrs["column"])
+	if err != nil {
+		return 0, 0
+	}
+	return line, column
+}
+
+// locate returns the 1-based line and column of version in data, preferring
+// its first occurrence after the last segment of name, or zeros when it does
+// not occur. It places versions whose lookup recorded no position, such as
+// those of text files.
+func locate(data []byte, name, version string) (int, int) {
+	i := -1
+	if n := bytes.Index(data, []byte(path.Base(name))); name != "" && n >= 0 {
+		if j := bytes.Index(data[n:], []byte(version)); j >= 0 {
+			i = n + j
+		}
+	}
+	if i < 0 {
+		i = bytes.Index(data, []byte(version))
+	}
+	if i < 0 {
+		return 0, 0
+	}
+	start := bytes.LastIndexByte(data[:i], '\n') + 1
+	return bytes.Count(data[:i], []byte("\n")) + 1, i - start + 1
+}
+
+// artifactURI returns file relative to the working directory with forward
+// slashes, as code scanning resolves locations from the repository root.
+func artifactURI(file string) string {
+	if filepath.IsAbs(file) {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, file); err == nil {
+				file = rel
+			}
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(file))
+}
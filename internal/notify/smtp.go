@@ -0,0 +1,149 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/config"
+)
+
+// defaultSubject is the email subject used when none is configured.
+const defaultSubject = "automata update report"
+
+// Email sends reports through an SMTP server.
+type Email struct {
+	Config    config.SMTP
+	Template  *template.Template
+	TLSConfig *tls.Config
+}
+
+// NewEmail creates an email notifier, loading the extra CA bundle if any.
+func NewEmail(c config.SMTP, tmpl *template.Template) (*Email, error) {
+	if c.Host == "" || c.From == "" || len(c.To) == 0 {
+		return nil, fmt.Errorf("smtp notification requires host, from and to")
+	}
+	if c.Port == 0 {
+		c.Port = 587
+	}
+	if c.Subject == "" {
+		c.Subject = defaultSubject
+	}
+	switch c.TLS {
+	case "":
+		c.TLS = "starttls"
+	case "starttls", "tls", "none":
+	default:
+		return nil, fmt.Errorf("unknown smtp tls mode %q", c.TLS)
+	}
+	tlsConfig := &tls.Config{ServerName: c.Host, MinVersion: tls.VersionTLS12}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read smtp ca file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in smtp ca file %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return &Email{Config: c, Template: tmpl, TLSConfig: tlsConfig}, nil
+}
+
+// Notify renders the report and emails it to the recipients.
+func (e *Email) Notify(ctx context.Context, r Report) error {
+	text, err := r.Render(e.Template)
+	if err != nil {
+		return err
+	}
+	c, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := c.Close(); err != nil {
+			slog.WarnContext(ctx, "close smtp connection", "err", err)
+		}
+	}()
+	if e.Config.TLS == "starttls" {
+		if err := c.StartTLS(e.TLSConfig); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if e.Config.Username != "" {
+		auth := smtp.PlainAuth("", e.Config.Username, e.Config.Password, e.Config.Host)
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(e.Config.From); err != nil {
+		return fmt.Errorf("smtp mail: %w", err)
+	}
+	for _, to := range e.Config.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("smtp rcpt %s: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(e.message(text)); err != nil {
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	return c.Quit()
+}
+
+// dial connects to the server, over TLS from the start in "tls" mode.
+func (e *Email) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(e.Config.Host, strconv.Itoa(e.Config.Port))
+	var conn net.Conn
+	var err error
+	if e.Config.TLS == "tls" {
+		d := &tls.Dialer{Config: e.TLSConfig}
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("smtp dial %s: %w", addr, err)
+	}
+	c, err := smtp.NewClient(conn, e.Config.Host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("smtp client: %w", err)
+	}
+	return c, nil
+}
+
+// message formats the email headers and body with CRLF line endings.
+func (e *Email) message(text string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.Config.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.Config.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", e.Config.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// WriteStepSummary appends a Markdown table of the applied updates of the
+// report, with their versions, release links and files, to the GitHub Actions
+// job summary at file.
+func WriteStepSummary(file string, r Report) error {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", file, err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.WriteString(StepSummary(r)); err != nil {
+		return fmt.Errorf("write %s: %w", file, err)
+	}
+	return nil
+}
+
+// StepSummary renders the applied updates of the report as Markdown.
+func StepSummary(r Report) string {
+	var b strings.Builder
+	b.WriteString("## automata\n\n")
+	fmt.Fprintf(&b, "%d update(s), %d failure(s), %d pending, %d queued\n",
+		len(r.Updates), len(r.Failures), len(r.Pending)+len(r.PendingMajors), len(r.Queued))
+	if len(r.Updates) == 0 {
+		return b.String()
+	}
+	b.WriteString("\n| Dependency | From | To | File |\n| --- | --- | --- | --- |\n")
+	for _, u := range r.Updates {
+		name, from, to, link := u.Attrs["name"], u.Attrs["from"], u.Attrs["to"], u.Attrs["link"]
+		file := ""
+		if path := u.Attrs["path"]; path != "" {
+			file = "`" + artifactURI(path) + "`"
+		}
+		if link != "" {
+			to = fmt.Sprintf("[%s](%s)", to, link)
+		} else {
+			to = "`" + to + "`"
+		}
+		fmt.Fprintf(&b, "| %s | `%s` | %s | %s |\n", escapeCell(name), from, to, file)
+	}
+	return b.String()
+}
+
+// escapeCell escapes pipes in a Markdown table cell.
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
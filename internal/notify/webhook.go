@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// discordMaxContent is the maximum length of a Discord message.
+const discordMaxContent = 2000
+
+// Webhook posts reports to a Slack or Discord incoming webhook, or to a Matrix
+// room through the client-server API.
+type Webhook struct {
+	Kind     string
+	URL      string
+	Room     string
+	Token    string
+	Template *template.Template
+	Client   *http.Client
+}
+
+// NewWebhook creates a webhook notifier of the given kind: "slack",
+// "discord" or "matrix". Matrix requires the homeserver URL, a room ID and an
+// access token.
+func NewWebhook(kind, u, room, token string, tmpl *template.Template) (*Webhook, error) {
+	if u == "" {
+		return nil, fmt.Errorf("%s notification requires url", kind)
+	}
+	if kind == "matrix" && (room == "" || token == "") {
+		return nil, fmt.Errorf("matrix notification requires room and token")
+	}
+	return &Webhook{
+		Kind:     kind,
+		URL:      u,
+		Room:     room,
+		Token:    token,
+		Template: tmpl,
+		Client:   http.DefaultClient,
+	}, nil
+}
+
+// Notify renders the report and delivers it.
+func (w *Webhook) Notify(ctx context.Context, r Report) error {
+	text, err := r.Render(w.Template)
+	if err != nil {
+		return err
+	}
+	method, endpoint := http.MethodPost, w.URL
+	var payload any
+	switch w.Kind {
+	case "slack":
+		payload = map[string]string{"text": text}
+	case "discord":
+		if len(text) > discordMaxContent {
+			text = text[:discordMaxContent]
+		}
+		payload = map[string]string{"content": text}
+	case "matrix":
+		method = http.MethodPut
+		endpoint = fmt.Sprintf(
+			"%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+			strings.TrimSuffix(w.URL, "/"),
+			url.PathEscape(w.Room),
+			strconv.FormatInt(time.Now().UnixNano(), 10),
+		)
+		payload = map[string]string{"msgtype": "m.text", "body": text}
+	default:
+		return fmt.Errorf("unknown webhook kind %q", w.Kind)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal %s payload: %w", w.Kind, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create %s request: %w", w.Kind, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.Token)
+	}
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s notify: %w", w.Kind, err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.WarnContext(ctx, "close notification response", "kind", w.Kind, "err", err)
+		}
+	}()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf(
+			"%s notify: unexpected status %s: %s",
+			w.Kind,
+			resp.Status,
+			strings.TrimSpace(string(msg)),
+		)
+	}
+	return nil
+}
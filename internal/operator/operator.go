@@ -0,0 +1,299 @@
+// Package operator runs automata as a Kubernetes operator: it reconciles
+// DependencyUpdatePolicy resources by updating the Git repository of a Flux
+// or Argo CD source on a schedule, and reports the outcome in their status
+// conditions.
+package operator
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/kube"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/mirror"
+)
+
+// CRD is the CustomResourceDefinition of DependencyUpdatePolicy.
+//
+//go:embed crd.yaml
+var CRD []byte
+
+// Target is a repository to update, resolved from a policy.
+type Target struct {
+	// URL is the Git URL of the repository.
+	URL string
+	// Paths are the directories to update, the repository root when empty.
+	Paths []string
+	// Branch is the branch the updates are pushed to.
+	Branch string
+	// Message is the commit message and pull request title.
+	Message string
+	// PullRequest opens a pull request for the branch.
+	PullRequest bool
+}
+
+// UpdateFunc updates a repository, pushing the result to its branch.
+type UpdateFunc func(ctx context.Context, t Target) error
+
+// Operator reconciles DependencyUpdatePolicy resources.
+type Operator struct {
+	client    *kube.Client
+	update    UpdateFunc
+	namespace string
+	now       func() time.Time
+}
+
+// New creates an operator reconciling the policies of a namespace, or of
+// all namespaces when it is empty, with update.
+func New(c *kube.Client, update UpdateFunc, namespace string) *Operator {
+	return &Operator{client: c, update: update, namespace: namespace, now: time.Now}
+}
+
+// watchRetry is how long the operator waits before watching the policies
+// again after a failed watch.
+const watchRetry = 5 * time.Second
+
+// Run reconciles the due policies every resync period until the context is
+// canceled, and the policies created or changed in between as the API
+// server streams them. The policies of a pass share their lookups, which
+// are fetched again on the next pass.
+func (o *Operator) Run(ctx context.Context, resync time.Duration) error {
+	ctx = logging.WithSubsystem(ctx, "operator")
+	ticker := time.NewTicker(resync)
+	defer ticker.Stop()
+	changed := make(chan ObjectMeta)
+	go o.watch(ctx, changed)
+	for {
+		if err := o.ReconcileAll(mirror.WithMemo(ctx, mirror.NewMemo())); err != nil {
+			slog.ErrorContext(ctx, "failed to reconcile policies", logging.Failed.Attr(), "err", err)
+		}
+		for resync := false; !resync; {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				resync = true
+			case m := <-changed:
+				if err := o.reconcileLatest(mirror.WithMemo(ctx, mirror.NewMemo()), m); err != nil {
+					slog.ErrorContext(ctx, "failed to reconcile policy", logging.Failed.Attr(),
+						"policy", m.Namespace+"/"+m.Name, "err", err)
+				}
+			}
+		}
+	}
+}
+
+// reconcileLatest reads a changed policy again before reconciling it, as
+// its change may be older than a reconcile of the policy since.
+func (o *Operator) reconcileLatest(ctx context.Context, m ObjectMeta) error {
+	var p Policy
+	if err := o.client.Get(ctx, policyPath(m.Namespace, m.Name), &p); err != nil {
+		if kube.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("get policy: %w", err)
+	}
+	return o.Reconcile(ctx, p)
+}
+
+// watch sends the policies created or changed to changed until the context
+// is canceled, listing them again when the watch expires.
+func (o *Operator) watch(ctx context.Context, changed chan<- ObjectMeta) {
+	var version string
+	for ctx.Err() == nil {
+		if version == "" {
+			var list PolicyList
+			if err := o.client.Get(ctx, policiesPath(o.namespace), &list); err != nil {
+				slog.WarnContext(ctx, "failed to list policies", "err", err)
+				sleep(ctx, watchRetry)
+				continue
+			}
+			version = list.Metadata.ResourceVersion
+		}
+		err := o.client.Watch(ctx, policiesPath(o.namespace), version, func(e kube.WatchEvent) error {
+			var p Policy
+			if err := json.Unmarshal(e.Object, &p); err != nil {
+				return fmt.Errorf("decode policy: %w", err)
+			}
+			version = p.Metadata.ResourceVersion
+			if e.Type != kube.Added && e.Type != kube.Modified {
+				return nil
+			}
+			select {
+			case changed <- p.Metadata:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		switch {
+		case ctx.Err() != nil:
+		case kube.IsGone(err):
+			version = ""
+		case err != nil:
+			slog.WarnContext(ctx, "failed to watch policies", "err", err)
+			sleep(ctx, watchRetry)
+		}
+	}
+}
+
+// sleep waits for d or until the context is canceled.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+// ReconcileAll lists the policies and reconciles the due ones.
+func (o *Operator) ReconcileAll(ctx context.Context) error {
+	var list PolicyList
+	if err := o.client.Get(ctx, policiesPath(o.namespace), &list); err != nil {
+		return fmt.Errorf("list policies: %w", err)
+	}
+	var errs []error
+	for _, p := range list.Items {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := o.Reconcile(ctx, p); err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s: %w", p.Metadata.Namespace, p.Metadata.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Reconcile updates the repository of a policy when it is due and not
+// suspended, then records the outcome in its Ready condition. Update
+// failures are reported in the status; the returned error is the failure
+// to reconcile the resource itself.
+func (o *Operator) Reconcile(ctx context.Context, p Policy) error {
+	ctx = logging.WithAttrs(ctx, "policy", p.Metadata.Namespace+"/"+p.Metadata.Name)
+	if p.Spec.Suspend {
+		slog.DebugContext(ctx, "policy suspended")
+		return nil
+	}
+	now := o.now().UTC().Truncate(time.Second)
+	cond := Condition{Type: Ready, ObservedGeneration: p.Metadata.Generation, LastTransitionTime: now}
+	interval, err := p.Interval()
+	if err != nil {
+		cond.Status, cond.Reason = "False", "InvalidSpec"
+		cond.Message = fmt.Sprintf("invalid interval %q: %v", p.Spec.Interval, err)
+		return o.setStatus(ctx, p, now, cond)
+	}
+	if !p.Due(now, interval) {
+		return nil
+	}
+	t, err := o.Resolve(ctx, p)
+	if err == nil {
+		slog.InfoContext(ctx, "updating repository", "repo", t.URL)
+		err = o.update(ctx, t)
+		cond.Reason = "UpdateFailed"
+	} else {
+		cond.Reason = "SourceNotResolved"
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to update repository", logging.Failed.Attr(), "reason", cond.Reason, "err", err)
+		cond.Status, cond.Message = "False", err.Error()
+	} else {
+		cond.Status, cond.Reason, cond.Message = "True", "Succeeded", "Updated "+t.URL
+	}
+	return o.setStatus(ctx, p, now, cond)
+}
+
+// setStatus patches the status of a policy with its reconcile time and
+// condition.
+func (o *Operator) setStatus(ctx context.Context, p Policy, now time.Time, cond Condition) error {
+	status := p.Status
+	status.ObservedGeneration = p.Metadata.Generation
+	status.LastReconcileTime = &now
+	status.SetCondition(cond)
+	path := policyPath(p.Metadata.Namespace, p.Metadata.Name) + "/status"
+	if err := o.client.Patch(ctx, path, kube.MergePatch, map[string]any{"status": status}, nil); err != nil {
+		return fmt.Errorf("update status: %w", err)
+	}
+	return nil
+}
+
+// Resolve returns the repository a policy updates, reading the Flux
+// GitRepository or Argo CD Application it references.
+func (o *Operator) Resolve(ctx context.Context, p Policy) (Target, error) {
+	t := Target{
+		Paths:       p.Spec.Paths,
+		Branch:      p.Spec.Branch,
+		Message:     p.Spec.Message,
+		PullRequest: p.Spec.PullRequest,
+	}
+	if t.Branch == "" {
+		t.Branch = "automata/update"
+	}
+	if t.Message == "" {
+		t.Message = "chore: update dependencies"
+	}
+	switch ref := p.Spec.SourceRef; {
+	case ref != nil:
+		ns := ref.Namespace
+		if ns == "" {
+			ns = p.Metadata.Namespace
+		}
+		switch ref.Kind {
+		case GitRepository:
+			var repo struct {
+				Spec struct {
+					URL string `json:"url"`
+				} `json:"spec"`
+			}
+			path := fmt.Sprintf("/apis/source.toolkit.fluxcd.io/v1/namespaces/%s/gitrepositories/%s", ns, ref.Name)
+			if err := o.client.Get(ctx, path, &repo); err != nil {
+				return Target{}, fmt.Errorf("get GitRepository %s/%s: %w", ns, ref.Name, err)
+			}
+			t.URL = repo.Spec.URL
+		case Application:
+			var app struct {
+				Spec struct {
+					Source struct {
+						RepoURL string `json:"repoURL"`
+						Path    string `json:"path"`
+					} `json:"source"`
+				} `json:"spec"`
+			}
+			path := fmt.Sprintf("/apis/argoproj.io/v1alpha1/namespaces/%s/applications/%s", ns, ref.Name)
+			if err := o.client.Get(ctx, path, &app); err != nil {
+				return Target{}, fmt.Errorf("get Application %s/%s: %w", ns, ref.Name, err)
+			}
+			t.URL = app.Spec.Source.RepoURL
+			if len(t.Paths) == 0 && app.Spec.Source.Path != "" {
+				t.Paths = []string{app.Spec.Source.Path}
+			}
+		default:
+			return Target{}, fmt.Errorf("unsupported source kind %q", ref.Kind)
+		}
+	case p.Spec.Git != nil:
+		t.URL = p.Spec.Git.URL
+	}
+	if t.URL == "" {
+		return Target{}, errors.New("policy has no repository: set sourceRef or git.url")
+	}
+	return t, nil
+}
+
+// policiesPath returns the API path of the policies of a namespace, or of
+// all namespaces when it is empty.
+func policiesPath(namespace string) string {
+	if namespace == "" {
+		return fmt.Sprintf("/apis/%s/%s/%s", Group, Version, Resource)
+	}
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", Group, Version, namespace, Resource)
+}
+
+// policyPath returns the API path of a policy.
+func policyPath(namespace, name string) string {
+	return policiesPath(namespace) + "/" + name
+}
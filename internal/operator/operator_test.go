@@ -0,0 +1,198 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/kube"
+)
+
+// fakeAPI serves policies, a Flux GitRepository and an Argo CD Application,
+// recording the status patches.
+func fakeAPI(t *testing.T, policies []Policy, patches map[string]PolicyStatus) *kube.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case policiesPath(""):
+			_ = json.NewEncoder(w).Encode(PolicyList{Items: policies})
+		case "/apis/source.toolkit.fluxcd.io/v1/namespaces/flux-system/gitrepositories/infra":
+			_, _ = w.Write([]byte(`{"spec":{"url":"https://github.com/org/infra"}}`))
+		case "/apis/argoproj.io/v1alpha1/namespaces/argocd/applications/apps":
+			_, _ = w.Write([]byte(`{"spec":{"source":{"repoURL":"https://github.com/org/apps","path":"overlays/prod"}}}`))
+		default:
+			if r.Method != http.MethodPatch {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			var patch struct {
+				Status PolicyStatus `json:"status"`
+			}
+			if err := json.Unmarshal(body, &patch); err != nil {
+				t.Errorf("decode patch: %v", err)
+			}
+			patches[r.URL.Path] = patch.Status
+		}
+	}))
+	t.Cleanup(srv.Close)
+	c, err := kube.NewClient(kube.Config{Server: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestReconcileAll(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-time.Minute)
+	policies := []Policy{
+		{
+			Metadata: ObjectMeta{Name: "flux", Namespace: "flux-system", Generation: 1},
+			Spec:     PolicySpec{SourceRef: &SourceRef{Kind: GitRepository, Name: "infra"}},
+		},
+		{
+			Metadata: ObjectMeta{Name: "argo", Namespace: "apps", Generation: 2},
+			Spec: PolicySpec{
+				SourceRef:   &SourceRef{Kind: Application, Name: "apps", Namespace: "argocd"},
+				PullRequest: true,
+			},
+		},
+		{
+			Metadata: ObjectMeta{Name: "failing", Namespace: "default", Generation: 1},
+			Spec:     PolicySpec{Git: &GitSource{URL: "https://github.com/org/broken"}},
+		},
+		{
+			Metadata: ObjectMeta{Name: "recent", Namespace: "default", Generation: 1},
+			Spec:     PolicySpec{Git: &GitSource{URL: "https://github.com/org/recent"}},
+			Status:   PolicyStatus{ObservedGeneration: 1, LastReconcileTime: &recent},
+		},
+		{
+			Metadata: ObjectMeta{Name: "suspended", Namespace: "default"},
+			Spec:     PolicySpec{Suspend: true, Git: &GitSource{URL: "https://github.com/org/suspended"}},
+		},
+	}
+	patches := map[string]PolicyStatus{}
+	var updated []Target
+	update := func(_ context.Context, t Target) error {
+		updated = append(updated, t)
+		if t.URL == "https://github.com/org/broken" {
+			return errors.New("push rejected")
+		}
+		return nil
+	}
+	o := New(fakeAPI(t, policies, patches), update, "")
+	o.now = func() time.Time { return now }
+
+	if err := o.ReconcileAll(context.Background()); err != nil {
+		t.Fatalf("ReconcileAll: %v", err)
+	}
+
+	want := []Target{
+		{URL: "https://github.com/org/infra", Branch: "automata/update", Message: "chore: update dependencies"},
+		{
+			URL:         "https://github.com/org/apps",
+			Paths:       []string{"overlays/prod"},
+			Branch:      "automata/update",
+			Message:     "chore: update dependencies",
+			PullRequest: true,
+		},
+		{URL: "https://github.com/org/broken", Branch: "automata/update", Message: "chore: update dependencies"},
+	}
+	if !reflect.DeepEqual(updated, want) {
+		t.Errorf("updated = %+v, want %+v", updated, want)
+	}
+	if len(patches) != 3 {
+		t.Fatalf("patched %d statuses, want 3: %v", len(patches), patches)
+	}
+	argo := patches[policyPath("apps", "argo")+"/status"]
+	if argo.ObservedGeneration != 2 || argo.LastReconcileTime == nil || !argo.LastReconcileTime.Equal(now) {
+		t.Errorf("argo status = %+v", argo)
+	}
+	if c := argo.Conditions; len(c) != 1 || c[0].Status != "True" || c[0].Reason != "Succeeded" {
+		t.Errorf("argo conditions = %+v", c)
+	}
+	failing := patches[policyPath("default", "failing")+"/status"]
+	if c := failing.Conditions; len(c) != 1 || c[0].Status != "False" || c[0].Message != "push rejected" {
+		t.Errorf("failing conditions = %+v", c)
+	}
+}
+
+func TestResolve_NoRepository(t *testing.T) {
+	o := New(nil, nil, "")
+	if _, err := o.Resolve(context.Background(), Policy{}); err == nil {
+		t.Error("Resolve succeeded without a repository")
+	}
+}
+
+func TestSetCondition(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Hour)
+	var s PolicyStatus
+	s.SetCondition(Condition{Type: Ready, Status: "True", LastTransitionTime: t0})
+	s.SetCondition(Condition{Type: Ready, Status: "True", Message: "again", LastTransitionTime: t1})
+	if got := s.Conditions[0]; !got.LastTransitionTime.Equal(t0) || got.Message != "again" {
+		t.Errorf("unchanged status moved transition time: %+v", got)
+	}
+	s.SetCondition(Condition{Type: Ready, Status: "False", LastTransitionTime: t1})
+	if got := s.Conditions[0]; !got.LastTransitionTime.Equal(t1) || len(s.Conditions) != 1 {
+		t.Errorf("status change kept transition time: %+v", s.Conditions)
+	}
+}
+
+func TestRunReconcilesWatchedPolicies(t *testing.T) {
+	created := Policy{
+		Metadata: ObjectMeta{Name: "new", Namespace: "default", Generation: 1, ResourceVersion: "2"},
+		Spec:     PolicySpec{Git: &GitSource{URL: "https://github.com/org/new"}},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == policiesPath("") && r.URL.Query().Get("watch") == "true":
+			if got := r.URL.Query().Get("resourceVersion"); got != "1" {
+				// Later watches resume from the event, and stay open.
+				<-r.Context().Done()
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"type": kube.Added, "object": created})
+		case r.URL.Path == policiesPath(""):
+			_, _ = w.Write([]byte(`{"metadata":{"resourceVersion":"1"},"items":[]}`))
+		case r.URL.Path == policyPath("default", "new") && r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(created)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+	c, err := kube.NewClient(kube.Config{Server: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updated := make(chan Target, 1)
+	update := func(_ context.Context, t Target) error {
+		updated <- t
+		return nil
+	}
+	done := make(chan error)
+	go func() { done <- New(c, update, "").Run(ctx, time.Hour) }()
+	select {
+	case got := <-updated:
+		if got.URL != "https://github.com/org/new" {
+			t.Errorf("updated %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watched policy not reconciled")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+}
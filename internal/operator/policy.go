@@ -0,0 +1,129 @@
+package operator
+
+import (
+	"time"
+)
+
+// API group and version of the DependencyUpdatePolicy resource.
+const (
+	Group    = "automata.shikanime.studio"
+	Version  = "v1alpha1"
+	Resource = "dependencyupdatepolicies"
+)
+
+// Kinds of the sources a policy can reference.
+const (
+	// GitRepository is a Flux source.
+	GitRepository = "GitRepository"
+	// Application is an Argo CD application.
+	Application = "Application"
+)
+
+// ObjectMeta is the metadata of a resource used by the operator.
+type ObjectMeta struct {
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	Generation int64  `json:"generation,omitempty"`
+	// ResourceVersion is the version of the resource watches resume from.
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// SourceRef references the Flux or Argo CD resource of a repository.
+type SourceRef struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// GitSource is a Git repository updated directly.
+type GitSource struct {
+	URL string `json:"url"`
+}
+
+// PolicySpec is the desired state of a DependencyUpdatePolicy.
+type PolicySpec struct {
+	// Interval is how often the repository is updated, e.g. 30m.
+	Interval string `json:"interval,omitempty"`
+	// Suspend stops the updates until unset.
+	Suspend bool `json:"suspend,omitempty"`
+	// SourceRef references the Flux GitRepository or Argo CD Application of
+	// the repository.
+	SourceRef *SourceRef `json:"sourceRef,omitempty"`
+	// Git is the repository updated without a source reference.
+	Git *GitSource `json:"git,omitempty"`
+	// Paths are the directories of the repository to update.
+	Paths []string `json:"paths,omitempty"`
+	// Branch is the branch the updates are pushed to.
+	Branch string `json:"branch,omitempty"`
+	// Message is the commit message and pull request title.
+	Message string `json:"message,omitempty"`
+	// PullRequest opens a GitHub pull request for the branch.
+	PullRequest bool `json:"pullRequest,omitempty"`
+}
+
+// Condition is a status condition of a policy.
+type Condition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
+	ObservedGeneration int64     `json:"observedGeneration,omitempty"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
+
+// PolicyStatus is the observed state of a DependencyUpdatePolicy.
+type PolicyStatus struct {
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+	LastReconcileTime  *time.Time  `json:"lastReconcileTime,omitempty"`
+	Conditions         []Condition `json:"conditions,omitempty"`
+}
+
+// Policy is a DependencyUpdatePolicy resource.
+type Policy struct {
+	Metadata ObjectMeta   `json:"metadata"`
+	Spec     PolicySpec   `json:"spec"`
+	Status   PolicyStatus `json:"status,omitempty"`
+}
+
+// PolicyList is a list of DependencyUpdatePolicy resources.
+type PolicyList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Items []Policy `json:"items"`
+}
+
+// Ready is the condition reporting whether the last update succeeded.
+const Ready = "Ready"
+
+// Interval returns the update interval of the policy, an hour by default.
+func (p Policy) Interval() (time.Duration, error) {
+	if p.Spec.Interval == "" {
+		return time.Hour, nil
+	}
+	return time.ParseDuration(p.Spec.Interval)
+}
+
+// Due reports whether the policy should be reconciled at now: its spec
+// changed since the last reconcile, or its interval elapsed.
+func (p Policy) Due(now time.Time, interval time.Duration) bool {
+	last := p.Status.LastReconcileTime
+	return last == nil || p.Status.ObservedGeneration != p.Metadata.Generation ||
+		!now.Before(last.Add(interval))
+}
+
+// SetCondition sets a condition of the status, keeping its transition time
+// when its status is unchanged.
+func (s *PolicyStatus) SetCondition(c Condition) {
+	for i, old := range s.Conditions {
+		if old.Type != c.Type {
+			continue
+		}
+		if old.Status == c.Status {
+			c.LastTransitionTime = old.LastTransitionTime
+		}
+		s.Conditions[i] = c
+		return
+	}
+	s.Conditions = append(s.Conditions, c)
+}
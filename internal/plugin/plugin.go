@@ -0,0 +1,157 @@
+// Package plugin discovers and runs external executables extending automata
+// without forking it. Plugins are found on PATH by name prefix and speak a
+// JSON protocol over stdin and stdout:
+//
+//   - Source plugins, named automata-source-NAME, list the versions of a
+//     dependency for rules declaring source NAME. They read a Request and
+//     write a Response; automata selects the latest version itself, with the
+//     ordering and policies of the rule.
+//   - Format plugins, named automata-update-NAME, update files of a custom
+//     format as the "automata update NAME [DIR...]" subcommand. They receive
+//     the arguments of the subcommand and the environment of automata.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/shikanime-studio/automata/internal/timeout"
+)
+
+// Executable name prefixes of the plugin kinds.
+const (
+	SourcePrefix = "automata-source-"
+	FormatPrefix = "automata-update-"
+)
+
+// Request is the dependency a source plugin lists the versions of, written as
+// JSON to its stdin.
+type Request struct {
+	// Source is the name of the plugin source, e.g. "gitlab".
+	Source string `json:"source"`
+	// Name identifies the dependency, from the image, chart or repository of
+	// the rule.
+	Name string `json:"name"`
+	// Repository, Image and Chart are the fields of the rule as declared.
+	Repository string `json:"repository,omitempty"`
+	Image      string `json:"image,omitempty"`
+	Chart      string `json:"chart,omitempty"`
+	// Version is the current version.
+	Version string `json:"version"`
+}
+
+// Response lists the versions available, read as JSON from the stdout of a
+// source plugin.
+type Response struct {
+	Versions []string `json:"versions"`
+}
+
+// Plugin is an executable found on PATH.
+type Plugin struct {
+	// Kind is "source" or "format".
+	Kind string
+	// Name is the executable name without its prefix.
+	Name string
+	// Path is the path of the executable.
+	Path string
+}
+
+// LookupSource returns the path of the source plugin with the given name.
+func LookupSource(name string) (string, error) {
+	path, err := exec.LookPath(SourcePrefix + name)
+	if err != nil {
+		return "", fmt.Errorf("source plugin %s: %w", name, err)
+	}
+	return path, nil
+}
+
+// Discover lists the plugins found on PATH, sorted by kind and name. The first
+// executable of a name shadows the next ones, as for commands.
+func Discover() []Plugin {
+	seen := map[string]bool{}
+	var plugins []Plugin
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			kind, name := "", ""
+			switch {
+			case strings.HasPrefix(e.Name(), SourcePrefix):
+				kind, name = "source", strings.TrimPrefix(e.Name(), SourcePrefix)
+			case strings.HasPrefix(e.Name(), FormatPrefix):
+				kind, name = "format", strings.TrimPrefix(e.Name(), FormatPrefix)
+			default:
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if name == "" || seen[e.Name()] || !isExecutable(path) {
+				continue
+			}
+			seen[e.Name()] = true
+			plugins = append(plugins, Plugin{Kind: kind, Name: name, Path: path})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool {
+		if plugins[i].Kind != plugins[j].Kind {
+			return plugins[i].Kind < plugins[j].Kind
+		}
+		return plugins[i].Name < plugins[j].Name
+	})
+	return plugins
+}
+
+// isExecutable reports whether path is an executable regular file.
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0o111 != 0
+}
+
+// ListVersions runs the source plugin of the request and returns the versions
+// it lists, bounded by the plugin timeout.
+func ListVersions(ctx context.Context, req Request) ([]string, error) {
+	path, err := LookupSource(req.Source)
+	if err != nil {
+		return nil, err
+	}
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encode plugin request: %w", err)
+	}
+	ctx, cancel := timeout.Context(ctx, timeout.Plugin)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("source plugin %s: %w: %s", req.Source, err, strings.TrimSpace(stderr.String()))
+	}
+	var resp Response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("decode source plugin %s response: %w", req.Source, err)
+	}
+	return resp.Versions, nil
+}
+
+// RunFormat runs the format plugin at path with the given arguments,
+// forwarding the standard streams.
+func RunFormat(ctx context.Context, path string, args []string) error {
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
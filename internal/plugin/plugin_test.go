@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePlugin(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatalf("write plugin: %v", err)
+	}
+}
+
+func TestUpdaterSelectsLatestListedVersion(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, SourcePrefix+"demo",
+		"read -r req\necho \"$req\" > "+filepath.Join(dir, "request.json")+"\n"+
+			"echo '{\"versions\":[\"1.0.0\",\"1.10.1\",\"2.0.0-rc.1\",\"1.2.0\"]}'\n")
+	t.Setenv("PATH", dir)
+
+	latest, err := NewUpdater().Update(context.Background(), &Request{
+		Source:     "demo",
+		Name:       "org/thing",
+		Repository: "org/thing",
+		Version:    "1.0.0",
+	})
+	if err != nil || latest != "1.10.1" {
+		t.Fatalf("got %q, %v", latest, err)
+	}
+	req, err := os.ReadFile(filepath.Join(dir, "request.json"))
+	if err != nil {
+		t.Fatalf("read request: %v", err)
+	}
+	if want := `{"source":"demo","name":"org/thing","repository":"org/thing","version":"1.0.0"}` + "\n"; string(req) != want {
+		t.Fatalf("request = %s, want %s", req, want)
+	}
+}
+
+func TestListVersionsReportsFailures(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, SourcePrefix+"broken", "echo 'rate limited' >&2\nexit 1\n")
+	writePlugin(t, dir, SourcePrefix+"garbled", "echo 'not json'\n")
+	t.Setenv("PATH", dir)
+	for _, source := range []string{"broken", "garbled", "missing"} {
+		if _, err := ListVersions(context.Background(), Request{Source: source}); err == nil {
+			t.Fatalf("expected %s to fail", source)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	writePlugin(t, first, FormatPrefix+"hello", "")
+	writePlugin(t, second, FormatPrefix+"hello", "")
+	writePlugin(t, second, SourcePrefix+"demo", "")
+	if err := os.WriteFile(filepath.Join(second, SourcePrefix+"data"), nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv("PATH", first+string(os.PathListSeparator)+second)
+	got := Discover()
+	want := []Plugin{
+		{Kind: "format", Name: "hello", Path: filepath.Join(first, FormatPrefix+"hello")},
+		{Kind: "source", Name: "demo", Path: filepath.Join(second, SourcePrefix+"demo")},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("plugin %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package plugin
+
+import (
+	"context"
+	"slices"
+
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// Updater finds the latest versions listed by source plugins.
+type Updater struct{}
+
+// NewUpdater constructs an Updater.
+func NewUpdater() Updater {
+	return Updater{}
+}
+
+// Update returns the latest version the source plugin of the request lists.
+func (u Updater) Update(ctx context.Context, req *Request, opts ...update.Option) (string, error) {
+	ctx = logging.WithAttrs(logging.WithSubsystem(ctx, req.Source), "dependency", req.Name)
+	versions, err := ListVersions(ctx, *req)
+	if err != nil {
+		return "", err
+	}
+	find := func(ctx context.Context, extra ...update.Option) (string, error) {
+		return update.SelectLatest(
+			ctx,
+			req.Version,
+			versions,
+			update.WithCompareOptions(slices.Concat(opts, extra)...),
+			update.WithLogAttrs("source", req.Source, "name", req.Name),
+		)
+	}
+	latest, err := find(ctx)
+	if err != nil {
+		return "", err
+	}
+	return policy.Apply(ctx, policy.Proposal{
+		Source:   req.Source,
+		Name:     req.Name,
+		From:     req.Version,
+		To:       latest,
+		Reselect: find,
+	})
+}
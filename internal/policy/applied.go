@@ -0,0 +1,110 @@
+package policy
+
+import (
+	"cmp"
+	"context"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// Applied collects the proposals applied during a run, and the files they
+// were applied to, for the commits and pull requests publishing them.
+type Applied struct {
+	mu        sync.Mutex
+	proposals []Proposal
+	files     map[string]bool
+}
+
+// NewApplied creates an empty Applied.
+func NewApplied() *Applied {
+	return &Applied{files: make(map[string]bool)}
+}
+
+// Files returns the on-disk paths of the files proposals were applied to,
+// sorted. Proposals applied without a file, e.g. by scripts, add none.
+func (a *Applied) Files() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	files := make([]string, 0, len(a.files))
+	for f := range a.files {
+		files = append(files, f)
+	}
+	slices.Sort(files)
+	return files
+}
+
+// Proposals returns the applied proposals once per dependency and version
+// change, sorted by source, name and versions so the commits and pull
+// requests publishing them do not depend on the order updates completed in.
+func (a *Applied) Proposals() []Proposal {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	proposals := slices.Clone(a.proposals)
+	slices.SortFunc(proposals, func(p, q Proposal) int {
+		return cmp.Or(
+			cmp.Compare(p.Source, q.Source),
+			cmp.Compare(p.Name, q.Name),
+			cmp.Compare(p.From, q.From),
+			cmp.Compare(p.To, q.To),
+		)
+	})
+	return proposals
+}
+
+type appliedKey struct{}
+
+// WithApplied returns a context recording the applied proposals into a, as
+// well as into the Applied of the enclosing contexts.
+func WithApplied(ctx context.Context, a *Applied) context.Context {
+	outer, _ := ctx.Value(appliedKey{}).([]*Applied)
+	return context.WithValue(ctx, appliedKey{}, append(slices.Clip(outer), a))
+}
+
+// AppliedFrom returns the innermost Applied the context records into, or nil.
+func AppliedFrom(ctx context.Context) *Applied {
+	all, _ := ctx.Value(appliedKey{}).([]*Applied)
+	if len(all) == 0 {
+		return nil
+	}
+	return all[len(all)-1]
+}
+
+// Record reports and records an update applied by a tool automata runs, such
+// as nix flake update, rather than through Apply.
+func Record(ctx context.Context, p Proposal) {
+	recordApplied(ctx, p)
+	reportUpdated(ctx, p)
+}
+
+// recordApplied records an applied proposal into the Applied the context
+// carries. The file is left out so a dependency updated in several files is
+// recorded once, and so is the reselection of the updater.
+func recordApplied(ctx context.Context, p Proposal) {
+	all, _ := ctx.Value(appliedKey{}).([]*Applied)
+	p.File = ""
+	p.Reselect = nil
+	path := Path(ctx)
+	for _, a := range all {
+		a.record(p, path)
+	}
+}
+
+// record adds a proposal unless already recorded, and the file it was
+// applied to, if any.
+func (a *Applied) record(p Proposal, path string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if path != "" {
+		if a.files == nil {
+			a.files = make(map[string]bool)
+		}
+		a.files[filepath.Clean(path)] = true
+	}
+	for _, q := range a.proposals {
+		if q.Source == p.Source && q.Name == p.Name && q.From == p.From && q.To == p.To {
+			return
+		}
+	}
+	a.proposals = append(a.proposals, p)
+}
@@ -0,0 +1,95 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+// fields are the proposal fields expressions read, all strings.
+var fields = []string{"source", "name", "from", "to", "file"}
+
+// env is the CEL environment of policy expressions: the proposal fields and
+// the major, minor and patch functions extracting semver components.
+// Literal lists must be homogeneous and literal matches patterns valid, so
+// such mistakes are reported when the configuration is loaded rather than
+// when an update is proposed.
+var env = func() *cel.Env {
+	opts := []cel.EnvOption{
+		cel.ASTValidators(
+			cel.ValidateHomogeneousAggregateLiterals(),
+			cel.ValidateRegexLiterals(),
+		),
+	}
+	for _, f := range fields {
+		opts = append(opts, cel.Variable(f, cel.StringType))
+	}
+	for i, name := range []string{"major", "minor", "patch"} {
+		opts = append(opts, cel.Function(name, cel.Overload(
+			name+"_string", []*cel.Type{cel.StringType}, cel.IntType,
+			cel.UnaryBinding(func(v ref.Val) ref.Val {
+				return semverPart(name, i, string(v.(types.String)))
+			}),
+		)))
+	}
+	e, err := cel.NewEnv(opts...)
+	if err != nil {
+		panic(fmt.Sprintf("policy: cel environment: %v", err))
+	}
+	return e
+}()
+
+// Compile parses and type checks a CEL expression over the proposal fields
+// source, name, from, to and file, with the major, minor and patch functions
+// returning the semver components of a version as integers. Expressions
+// that are not booleans fail to compile.
+func Compile(expr string) (func(p Proposal) (bool, error), error) {
+	ast, iss := env.Compile(expr)
+	if err := iss.Err(); err != nil {
+		return nil, err
+	}
+	if !ast.OutputType().IsExactType(cel.BoolType) {
+		return nil, fmt.Errorf("expression %q is a %s, not a bool", expr, ast.OutputType())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	return func(p Proposal) (bool, error) {
+		out, _, err := prg.Eval(map[string]any{
+			"source": p.Source,
+			"name":   p.Name,
+			"from":   p.From,
+			"to":     p.To,
+			"file":   p.File,
+		})
+		if err != nil {
+			return false, err
+		}
+		return out.Value().(bool), nil
+	}, nil
+}
+
+// semverPart returns the i-th semver component of a version, or an error
+// value when it is not a version.
+func semverPart(name string, i int, s string) ref.Val {
+	mmp, err := updater.MajorMinorPatch(s)
+	if err != nil {
+		return types.NewErr("%s(%q): %v", name, s, err)
+	}
+	parts := strings.Split(strings.TrimPrefix(mmp, "v"), ".")
+	if i >= len(parts) {
+		return types.Int(0)
+	}
+	n, err := strconv.ParseInt(parts[i], 10, 64)
+	if err != nil {
+		return types.NewErr("%s(%q): %v", name, s, err)
+	}
+	return types.Int(n)
+}
@@ -0,0 +1,40 @@
+package policy
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ReleaseLink returns the page listing the given version of a dependency, or
+// "" when its source has none.
+func ReleaseLink(source, name, version string) string {
+	switch source {
+	case "github":
+		return "https://github.com/" + name + "/releases/tag/" + url.PathEscape(version)
+	case "git":
+		if rest, ok := strings.CutPrefix(strings.TrimSuffix(name, ".git"), "https://github.com/"); ok {
+			return ReleaseLink("github", rest, version)
+		}
+		return ""
+	case "orb":
+		return "https://circleci.com/developer/orbs/orb/" + name + "?version=" + url.QueryEscape(version)
+	case "helm":
+		return "https://artifacthub.io/packages/search?ts_query_web=" + url.QueryEscape(name)
+	case "image":
+		host, rest, ok := strings.Cut(name, "/")
+		switch {
+		case !ok:
+			return "https://hub.docker.com/_/" + name + "/tags?name=" + url.QueryEscape(version)
+		case host == "docker.io":
+			return ReleaseLink(source, rest, version)
+		case host == "quay.io":
+			return "https://quay.io/repository/" + rest + "?tab=tags"
+		case !strings.ContainsAny(host, ".:") && host != "localhost":
+			return "https://hub.docker.com/r/" + name + "/tags?name=" + url.QueryEscape(version)
+		default:
+			return ""
+		}
+	default:
+		return ""
+	}
+}
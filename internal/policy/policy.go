@@ -0,0 +1,370 @@
+// Package policy evaluates proposed updates against user-provided expressions
+// that approve, reject or hold them for manual approval.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/mod/semver"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+// Action is the decision taken for a proposed update.
+type Action string
+
+// Action values.
+const (
+	// Approve applies the update.
+	Approve Action = "approve"
+	// Reject drops the update.
+	Reject Action = "reject"
+	// Manual holds the update until a human approves it.
+	Manual Action = "manual"
+)
+
+// Proposal describes an update about to be applied.
+type Proposal struct {
+	// Source is the kind of dependency: image, helm, github, git, azure,
+	// orb, nixpkgs, flake or rancher.
+	Source string
+	// Name identifies the dependency, e.g. an image or chart name.
+	Name string
+	// From and To are the current and proposed versions.
+	From string
+	To   string
+	// File is the file being updated, when known.
+	File string
+	// Reselect selects a version again with extra options, when the updater
+	// can, so a proposal held by the skew or the major hold falls back to
+	// the greatest candidate passing them.
+	Reselect func(ctx context.Context, opts ...updater.Option) (string, error)
+}
+
+// reselect selects a version again with the given options, returning From
+// when the proposal cannot be reselected or nothing else qualifies.
+func (p Proposal) reselect(ctx context.Context, opts ...updater.Option) (string, error) {
+	if p.Reselect == nil {
+		return p.From, nil
+	}
+	to, err := p.Reselect(ctx, opts...)
+	if err != nil {
+		return "", fmt.Errorf("reselect %s: %w", p.Name, err)
+	}
+	if to == "" {
+		return p.From, nil
+	}
+	return to, nil
+}
+
+// Rule applies its action to the proposals matching its CEL expression or
+// Rego query.
+type Rule struct {
+	Expr   string
+	Action Action
+	match  func(p Proposal) (bool, error)
+}
+
+// Engine evaluates proposals against rules in order, then holds approved
+// updates outside the schedule.
+type Engine struct {
+	rules       []Rule
+	schedule    Schedule
+	holdMajors  bool
+	allowMajors map[string]bool
+	skew        *skew
+	now         func() time.Time
+}
+
+// Option configures an Engine.
+type Option func(*Engine)
+
+// WithSchedule only lets updates through within the schedule.
+func WithSchedule(s Schedule) Option {
+	return func(e *Engine) { e.schedule = s }
+}
+
+// WithMajorHold holds major-version bumps for manual review, except for the
+// allowed dependency names and those explicitly approved by a rule.
+func WithMajorHold(allow []string) Option {
+	return func(e *Engine) {
+		e.holdMajors = true
+		e.allowMajors = make(map[string]bool, len(allow))
+		for _, name := range allow {
+			e.allowMajors[name] = true
+		}
+	}
+}
+
+// WithClock sets the function returning the current time.
+func WithClock(now func() time.Time) Option {
+	return func(e *Engine) { e.now = now }
+}
+
+// New compiles the policies declared in the config file.
+func New(decls []config.Policy, opts ...Option) (*Engine, error) {
+	e := &Engine{now: time.Now}
+	for _, opt := range opts {
+		opt(e)
+	}
+	for i, d := range decls {
+		action := Action(d.Action)
+		switch action {
+		case Approve, Reject, Manual:
+		default:
+			return nil, fmt.Errorf("policy %d: unknown action %q", i, d.Action)
+		}
+		expr, compile := d.Expr, Compile
+		switch {
+		case d.Expr != "" && d.Rego != "":
+			return nil, fmt.Errorf("policy %d: expr and rego are exclusive", i)
+		case d.Rego != "":
+			expr = d.Rego
+			compile = func(query string) (func(p Proposal) (bool, error), error) {
+				return CompileRego(query, d.Modules)
+			}
+		}
+		match, err := compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("policy %d: compile %q: %w", i, expr, err)
+		}
+		e.rules = append(e.rules, Rule{Expr: expr, Action: action, match: match})
+	}
+	return e, nil
+}
+
+// Evaluate returns the action of the first rule matching the proposal, or
+// Approve when none does.
+func (e *Engine) Evaluate(p Proposal) (Action, error) {
+	action, _, err := e.evaluate(p)
+	return action, err
+}
+
+// evaluate also reports whether a rule matched, so explicit approvals can opt
+// out of the major-version hold.
+func (e *Engine) evaluate(p Proposal) (Action, bool, error) {
+	for _, r := range e.rules {
+		ok, err := r.match(p)
+		if err != nil {
+			return "", false, fmt.Errorf("evaluate %q: %w", r.Expr, err)
+		}
+		if ok {
+			return r.Action, true, nil
+		}
+	}
+	return Approve, false, nil
+}
+
+// IsMajor reports whether going from one version to the other bumps the major
+// version. Versions that are not semantic versions are never majors.
+func IsMajor(from, to string) bool {
+	f, err := updater.MajorMinorPatch(from)
+	if err != nil {
+		return false
+	}
+	t, err := updater.MajorMinorPatch(to)
+	if err != nil {
+		return false
+	}
+	return semver.Major(f) != semver.Major(t)
+}
+
+// UpdateType returns the part of the version going from one version to the
+// other bumps: major, minor or patch. It returns "" for versions that are
+// not semantic versions or do not differ.
+func UpdateType(from, to string) string {
+	f, err := updater.MajorMinorPatch(from)
+	if err != nil {
+		return ""
+	}
+	t, err := updater.MajorMinorPatch(to)
+	if err != nil || !semver.IsValid(f) || !semver.IsValid(t) {
+		return ""
+	}
+	switch {
+	case semver.Major(f) != semver.Major(t):
+		return "major"
+	case semver.MajorMinor(f) != semver.MajorMinor(t):
+		return "minor"
+	case semver.Compare(f, t) != 0:
+		return "patch"
+	default:
+		return ""
+	}
+}
+
+type engineKey struct{}
+
+type fileKey struct{}
+
+type dirKey struct{}
+
+type positionKey struct{}
+
+// WithEngine returns a context carrying the engine updaters consult.
+func WithEngine(ctx context.Context, e *Engine) context.Context {
+	return context.WithValue(ctx, engineKey{}, e)
+}
+
+// WithFile returns a context recording the file being updated.
+func WithFile(ctx context.Context, file string) context.Context {
+	return context.WithValue(ctx, fileKey{}, file)
+}
+
+// File returns the file recorded in the context, if any.
+func File(ctx context.Context) string {
+	file, _ := ctx.Value(fileKey{}).(string)
+	return file
+}
+
+// WithDir returns a context recording the directory files are read from, for
+// pipelines that record files relative to it.
+func WithDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, dirKey{}, dir)
+}
+
+// WithPosition returns a context recording the 1-based line and column the
+// node holding the version being updated starts at in the file, so reports
+// point at it.
+func WithPosition(ctx context.Context, line, column int) context.Context {
+	return context.WithValue(ctx, positionKey{}, [2]int{line, column})
+}
+
+// Position returns the line and column recorded in the context, or zeros.
+func Position(ctx context.Context) (int, int) {
+	pos, _ := ctx.Value(positionKey{}).([2]int)
+	return pos[0], pos[1]
+}
+
+// Path returns the on-disk path of the file recorded in the context, joining
+// it with the recorded directory, if any.
+func Path(ctx context.Context) string {
+	file := File(ctx)
+	if file == "" {
+		return ""
+	}
+	dir, _ := ctx.Value(dirKey{}).(string)
+	return filepath.Join(dir, file)
+}
+
+// Apply evaluates the proposal with the engine of the context and returns the
+// version to write: To when approved within the schedule, From otherwise.
+// Candidates moving a Kubernetes component out of the supported version skew
+// are skipped before any rule applies, and held major bumps are recorded as
+// pending majors; both fall back to the greatest remaining candidate when the
+// proposal can be reselected. Approved updates outside the schedule are queued
+// into the report. Proposals that change nothing and contexts without an
+// engine pass through. Applied updates are reported and recorded into the
+// Applied of the context, if any.
+func Apply(ctx context.Context, p Proposal) (string, error) {
+	version, err := apply(ctx, p)
+	if err == nil && version != "" && version != p.From {
+		p.To = version
+		recordApplied(ctx, p)
+		reportUpdated(ctx, p)
+	}
+	return version, err
+}
+
+// reportUpdated reports an applied update, with the on-disk path of the file
+// of the context it was applied to and the release page of the new version,
+// so reports need no other record to describe it.
+func reportUpdated(ctx context.Context, p Proposal) {
+	args := []any{
+		logging.Updated.Attr(),
+		"source", p.Source, "name", p.Name, "from", p.From, "to", p.To, "path", Path(ctx),
+	}
+	if link := ReleaseLink(p.Source, p.Name, p.To); link != "" {
+		args = append(args, "link", link)
+	}
+	slog.InfoContext(ctx, "updated dependency", withPosition(ctx, args...)...)
+}
+
+// attrs returns the attributes reporting the proposal as kind, followed by
+// extra ones and the position of its version, when known.
+func (p Proposal) attrs(ctx context.Context, kind logging.Kind, extra ...any) []any {
+	args := append([]any{
+		kind.Attr(),
+		"source", p.Source, "name", p.Name, "from", p.From, "to", p.To, "file", p.File,
+	}, extra...)
+	return withPosition(ctx, args...)
+}
+
+// withPosition appends the line and column recorded in the context to args,
+// when known.
+func withPosition(ctx context.Context, args ...any) []any {
+	if line, column := Position(ctx); line > 0 {
+		args = append(args, "line", line, "column", column)
+	}
+	return args
+}
+
+// apply decides the version to write for Apply.
+func apply(ctx context.Context, p Proposal) (string, error) {
+	slog.DebugContext(ctx, "tracked dependency", withPosition(ctx,
+		logging.Tracked.Attr(),
+		"source", p.Source, "name", p.Name, "version", p.From, "latest", p.To,
+		"path", Path(ctx))...)
+	e, _ := ctx.Value(engineKey{}).(*Engine)
+	if e == nil || p.To == "" || p.To == p.From {
+		return p.To, nil
+	}
+	p.File = File(ctx)
+	var checks []updater.Option
+	if e.skew != nil && e.skew.isComponent(p.Name) {
+		checks = append(checks, updater.WithCandidateCheck(func(_ context.Context, c string) (bool, error) {
+			return e.skew.allows(c), nil
+		}))
+		if !e.skew.allows(p.To) {
+			slog.InfoContext(ctx, "skew rejected update",
+				p.attrs(ctx, logging.Rejected, "kubernetes", e.skew.minor)...)
+			to, err := p.reselect(ctx, checks...)
+			if err != nil || to == p.From {
+				return to, err
+			}
+			p.To = to
+		}
+	}
+	for {
+		action, matched, err := e.evaluate(p)
+		if err != nil {
+			return "", err
+		}
+		switch action {
+		case Reject:
+			slog.InfoContext(ctx, "policy rejected update", p.attrs(ctx, logging.Rejected)...)
+			return p.From, nil
+		case Manual:
+			slog.InfoContext(ctx, "pending approval", p.attrs(ctx, logging.Pending)...)
+			return p.From, nil
+		}
+		if !e.holdMajors || matched || e.allowMajors[p.Name] || !IsMajor(p.From, p.To) {
+			break
+		}
+		slog.InfoContext(ctx, "pending major update", p.attrs(ctx, logging.PendingMajor)...)
+		// Fall back to the greatest candidate of the current major, which
+		// the rules evaluate again.
+		from := p.From
+		to, err := p.reselect(ctx, append(checks, updater.WithCandidateCheck(
+			func(_ context.Context, c string) (bool, error) { return !IsMajor(from, c), nil },
+		))...)
+		if err != nil {
+			return "", err
+		}
+		if to == p.From || IsMajor(p.From, to) {
+			return p.From, nil
+		}
+		p.To = to
+	}
+	if !e.schedule.Allows(e.now()) {
+		slog.InfoContext(ctx, "queued update outside schedule", p.attrs(ctx, logging.Queued)...)
+		return p.From, nil
+	}
+	return p.To, nil
+}
@@ -0,0 +1,409 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+func TestCompileEvaluatesExpressions(t *testing.T) {
+	p := Proposal{
+		Source: "image",
+		Name:   "ghcr.io/org/app",
+		From:   "1.4.2",
+		To:     "2.0.0",
+		File:   "prod/kustomization.yaml",
+	}
+	cases := map[string]bool{
+		`major(to) > major(from)`:                         true,
+		`source == "image" && name.startsWith("ghcr.io")`: true,
+		`file.matches("^staging/")`:                       false,
+		`!(source in ["helm", "git"])`:                    true,
+		`minor(from) == 4 || patch(to) != 0`:              true,
+		`to.endsWith("-rc") || from.contains("1.4")`:      true,
+		`name.matches(r"^ghcr\.io/")`:                     true,
+		`"\x61\u0062" == 'ab'`:                            true,
+	}
+	for expr, want := range cases {
+		match, err := Compile(expr)
+		if err != nil {
+			t.Fatalf("compile %q: %v", expr, err)
+		}
+		got, err := match(p)
+		if err != nil {
+			t.Fatalf("evaluate %q: %v", expr, err)
+		}
+		if got != want {
+			t.Fatalf("%q: expected %v, got %v", expr, want, got)
+		}
+	}
+}
+
+func TestCompileRejectsInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{
+		`version == "1"`, `source ==`, `to.trim()`, `"unterminated`,
+		`name == 1`, `source`, `'\d'`, `file.matches("(")`, `1 in ["a"]`,
+		`["a", 1] == ["a"]`, `!name`, `major(1) > 0`,
+	} {
+		if _, err := Compile(expr); err == nil {
+			t.Fatalf("expected %q to fail", expr)
+		}
+	}
+}
+
+func TestRegoPoliciesRunOpa(t *testing.T) {
+	bin := t.TempDir()
+	log := filepath.Join(bin, "log")
+	script := "#!/bin/sh\necho \"$@\" >> " + log + "\n" +
+		"case \"$*\" in *broken*) echo 'rego_parse_error' >&2; exit 2;; esac\n" +
+		"if grep -q '\"source\":\"helm\"'; then echo '{\"result\":[{\"expressions\":[{\"value\":true}]}]}'; else echo '{}'; fi\n"
+	if err := os.WriteFile(filepath.Join(bin, "opa"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	e, err := New([]config.Policy{
+		{Rego: `input.source == "helm"`, Modules: []string{"policies"}, Action: "reject"},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for source, want := range map[string]Action{"helm": Reject, "image": Approve} {
+		got, err := e.Evaluate(Proposal{Source: source, From: "1.0.0", To: "1.1.0"})
+		if err != nil {
+			t.Fatalf("evaluate %s: %v", source, err)
+		}
+		if got != want {
+			t.Errorf("%s: got %s, want %s", source, got, want)
+		}
+	}
+	calls, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "eval --format json --stdin-input --data policies input.source == \"helm\"\n"; !strings.HasPrefix(string(calls), want) {
+		t.Errorf("opa called with %q, want %q", calls, want)
+	}
+	for _, p := range []config.Policy{
+		{Rego: "broken", Action: "reject"},
+		{Expr: `source == "helm"`, Rego: `input.source == "helm"`, Action: "reject"},
+	} {
+		if _, err := New([]config.Policy{p}); err == nil {
+			t.Errorf("expected %+v to fail", p)
+		}
+	}
+}
+
+func TestApplyFollowsFirstMatchingRule(t *testing.T) {
+	e, err := New([]config.Policy{
+		{Expr: `file.startsWith("prod/")`, Action: "reject"},
+		{Expr: `major(to) > major(from)`, Action: "manual"},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ctx := WithEngine(context.Background(), e)
+	cases := []struct {
+		file, from, to, want string
+	}{
+		{"prod/app.yaml", "1.0.0", "1.1.0", "1.0.0"},
+		{"dev/app.yaml", "1.0.0", "2.0.0", "1.0.0"},
+		{"dev/app.yaml", "1.0.0", "1.1.0", "1.1.0"},
+	}
+	for _, c := range cases {
+		got, err := Apply(WithFile(ctx, c.file), Proposal{Source: "image", From: c.from, To: c.to})
+		if err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+		if got != c.want {
+			t.Fatalf("%s %s -> %s: expected %s, got %s", c.file, c.from, c.to, c.want, got)
+		}
+	}
+}
+
+func TestScheduleAllows(t *testing.T) {
+	s, err := NewSchedule(config.Schedule{
+		Days:    []string{"weekends"},
+		Hours:   "22-06",
+		Freezes: []config.Freeze{{From: "2026-12-19", To: "2026-12-20"}},
+	})
+	if err != nil {
+		t.Fatalf("new schedule: %v", err)
+	}
+	cases := map[string]bool{
+		"2026-10-17T23:00:00Z": true,  // Saturday night
+		"2026-10-18T05:59:00Z": true,  // Sunday early morning
+		"2026-10-17T12:00:00Z": false, // Saturday noon
+		"2026-10-16T23:00:00Z": false, // Friday night
+		"2026-12-20T23:00:00Z": false, // frozen Sunday
+	}
+	for ts, want := range cases {
+		at, _ := time.Parse(time.RFC3339, ts)
+		if got := s.Allows(at); got != want {
+			t.Fatalf("%s: expected %v, got %v", ts, want, got)
+		}
+	}
+}
+
+func TestApplyQueuesOutsideSchedule(t *testing.T) {
+	s, err := NewSchedule(config.Schedule{Days: []string{"saturday"}})
+	if err != nil {
+		t.Fatalf("new schedule: %v", err)
+	}
+	friday := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	e, err := New(nil, WithSchedule(s), WithClock(func() time.Time { return friday }))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	got, err := Apply(WithEngine(context.Background(), e), Proposal{From: "1.0.0", To: "1.1.0"})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if got != "1.0.0" {
+		t.Fatalf("expected update to be queued, got %s", got)
+	}
+}
+
+func TestApplyHoldsMajorsUnlessOptedIn(t *testing.T) {
+	e, err := New(
+		[]config.Policy{{Expr: `name == "approved"`, Action: "approve"}},
+		WithMajorHold([]string{"allowed"}),
+	)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ctx := WithEngine(context.Background(), e)
+	cases := []struct {
+		name, to, want string
+	}{
+		{"app", "1.5.0", "1.5.0"},
+		{"app", "2.0.0", "1.4.0"},
+		{"allowed", "2.0.0", "2.0.0"},
+		{"approved", "2.0.0", "2.0.0"},
+	}
+	for _, c := range cases {
+		got, err := Apply(ctx, Proposal{Name: c.name, From: "1.4.0", To: c.to})
+		if err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+		if got != c.want {
+			t.Fatalf("%s 1.4.0 -> %s: expected %s, got %s", c.name, c.to, c.want, got)
+		}
+	}
+}
+
+func TestApplyRejectsKubernetesSkew(t *testing.T) {
+	e, err := New(
+		[]config.Policy{{Expr: `name == "registry.k8s.io/kube-proxy"`, Action: "approve"}},
+		WithKubernetesSkew("v1.30.2+k0s.0", []string{"ghcr.io/org/kubelet-plugin"}),
+	)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ctx := WithEngine(context.Background(), e)
+	cases := []struct {
+		name, from, to, want string
+	}{
+		{"registry.k8s.io/kube-proxy", "v1.30.2", "v1.31.0", "v1.31.0"},
+		{"registry.k8s.io/kube-proxy", "v1.30.2", "v1.32.0", "v1.30.2"},
+		{"registry.k8s.io/kube-proxy", "v1.30.2", "v2.0.0", "v1.30.2"},
+		{"ghcr.io/org/kubelet-plugin", "1.29.0", "1.33.0", "1.29.0"},
+		{"docker.io/library/nginx", "1.25.0", "1.32.0", "1.32.0"},
+	}
+	for _, c := range cases {
+		got, err := Apply(ctx, Proposal{Source: "image", Name: c.name, From: c.from, To: c.to})
+		if err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+		if got != c.want {
+			t.Fatalf("%s %s -> %s: expected %s, got %s", c.name, c.from, c.to, c.want, got)
+		}
+	}
+}
+
+// reselector selects among candidates the way updaters do.
+func reselector(from string, candidates ...string) func(context.Context, ...updater.Option) (string, error) {
+	return func(ctx context.Context, opts ...updater.Option) (string, error) {
+		return updater.SelectLatest(ctx, from, candidates, updater.WithCompareOptions(opts...))
+	}
+}
+
+func TestApplyFallsBackBelowHeldMajors(t *testing.T) {
+	e, err := New([]config.Policy{{Expr: `to == "1.4.0"`, Action: "reject"}}, WithMajorHold(nil))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	applied := NewApplied()
+	ctx := WithApplied(WithEngine(context.Background(), e), applied)
+	cases := []struct {
+		candidates []string
+		want       string
+	}{
+		{[]string{"1.2.1", "1.3.0", "2.0.0"}, "1.3.0"},
+		{[]string{"1.3.0", "1.4.0", "2.0.0"}, "1.2.0"},
+		{[]string{"2.0.0"}, "1.2.0"},
+	}
+	for _, c := range cases {
+		got, err := Apply(ctx, Proposal{
+			Source:   "image",
+			Name:     "app",
+			From:     "1.2.0",
+			To:       "2.0.0",
+			Reselect: reselector("1.2.0", c.candidates...),
+		})
+		if err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+		if got != c.want {
+			t.Fatalf("%v: expected %s, got %s", c.candidates, c.want, got)
+		}
+	}
+	for _, p := range applied.Proposals() {
+		if p.To == "2.0.0" {
+			t.Fatalf("held major recorded as applied: %+v", p)
+		}
+	}
+}
+
+func TestApplyFallsBackWithinKubernetesSkew(t *testing.T) {
+	e, err := New(nil, WithKubernetesSkew("v1.30.2", nil))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ctx := WithEngine(context.Background(), e)
+	got, err := Apply(ctx, Proposal{
+		Source:   "image",
+		Name:     "registry.k8s.io/kube-proxy",
+		From:     "v1.30.2",
+		To:       "v1.33.0",
+		Reselect: reselector("v1.30.2", "v1.30.5", "v1.31.4", "v1.32.1", "v1.33.0"),
+	})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if got != "v1.31.4" {
+		t.Fatalf("expected v1.31.4, got %s", got)
+	}
+}
+
+func TestApplyRecordsAppliedProposals(t *testing.T) {
+	e, err := New([]config.Policy{{Expr: `name == "redis"`, Action: "reject"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := NewApplied()
+	ctx := WithApplied(WithEngine(context.Background(), e), applied)
+	for _, file := range []string{"a.yaml", "b.yaml"} {
+		fctx := WithFile(ctx, file)
+		if _, err := Apply(fctx, Proposal{Source: "image", Name: "nginx", From: "1.0.0", To: "2.0.0"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []Proposal{
+		{Source: "image", Name: "redis", From: "7.0.0", To: "7.2.0"},
+		{Source: "image", Name: "postgres", From: "16.1", To: "16.1"},
+	} {
+		if _, err := Apply(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := applied.Proposals()
+	want := Proposal{Source: "image", Name: "nginx", From: "1.0.0", To: "2.0.0"}
+	if len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Fatalf("expected %+v only, got %+v", want, got)
+	}
+}
+
+func TestAppliedSortsProposals(t *testing.T) {
+	applied := NewApplied()
+	ctx := WithApplied(context.Background(), applied)
+	for _, p := range []Proposal{
+		{Source: "image", Name: "redis", From: "7.0.0", To: "7.2.0"},
+		{Source: "helm", Name: "cilium", From: "1.15.0", To: "1.16.0"},
+		{Source: "image", Name: "nginx", From: "1.0.0", To: "2.0.0"},
+	} {
+		if _, err := Apply(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	for _, p := range applied.Proposals() {
+		got = append(got, p.Name)
+	}
+	if strings.Join(got, ",") != "cilium,nginx,redis" {
+		t.Fatalf("unexpected proposal order %v", got)
+	}
+}
+
+func TestAppliedRecordsFiles(t *testing.T) {
+	applied := NewApplied()
+	ctx := WithDir(WithApplied(context.Background(), applied), "clone")
+	for _, file := range []string{"b.yaml", "a.yaml", "b.yaml"} {
+		p := Proposal{Source: "image", Name: "nginx", From: "1.0.0", To: "1.1.0"}
+		if _, err := Apply(WithFile(ctx, file), p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Apply(ctx, Proposal{Source: "image", Name: "redis", From: "7.0.0", To: "7.2.0"}); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(applied.Files(), ",")
+	if want := "clone/a.yaml,clone/b.yaml"; got != want {
+		t.Fatalf("expected files %s, got %s", want, got)
+	}
+}
+
+func TestApplyReportsUpdates(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	ctx := WithFile(WithDir(context.Background(), "clone"), "ci.yaml")
+	p := Proposal{Source: "github", Name: "actions/checkout", From: "v3", To: "v4"}
+	if _, err := Apply(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"report=updated", "name=actions/checkout", "from=v3", "to=v4", "path=clone/ci.yaml",
+		"link=https://github.com/actions/checkout/releases/tag/v4",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected %q in %q", want, buf.String())
+		}
+	}
+}
+
+func TestNestedAppliedRecordIntoEnclosing(t *testing.T) {
+	outer, inner := NewApplied(), NewApplied()
+	ctx := WithApplied(WithApplied(context.Background(), outer), inner)
+	if _, err := Apply(ctx, Proposal{Source: "image", Name: "nginx", From: "1.0.0", To: "1.1.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if AppliedFrom(ctx) != inner || len(inner.Proposals()) != 1 || len(outer.Proposals()) != 1 {
+		t.Fatalf("expected both collectors to record, got %+v and %+v", inner.Proposals(), outer.Proposals())
+	}
+}
+
+func TestUpdateType(t *testing.T) {
+	cases := map[[2]string]string{
+		{"1.2.3", "2.0.0"}:   "major",
+		{"v1.2.3", "v1.3.0"}: "minor",
+		{"1.2.3", "1.2.4"}:   "patch",
+		{"1.2.3", "1.2.3"}:   "",
+		{"main", "1.2.3"}:    "",
+		{"1.29", "1.30"}:     "minor",
+	}
+	for c, want := range cases {
+		if got := UpdateType(c[0], c[1]); got != want {
+			t.Fatalf("UpdateType(%q, %q) = %q, want %q", c[0], c[1], got, want)
+		}
+	}
+}
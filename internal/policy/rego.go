@@ -0,0 +1,77 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// CompileRego checks a Rego query over input.source, input.name, input.from,
+// input.to and input.file, with the modules it may refer to, by evaluating it
+// once with the opa CLI. A proposal matches when the query evaluates to true
+// for it; undefined and non-boolean results do not match.
+func CompileRego(query string, modules []string) (func(p Proposal) (bool, error), error) {
+	if _, err := exec.LookPath("opa"); err != nil {
+		return nil, fmt.Errorf("rego policies need the opa CLI: %w", err)
+	}
+	args := []string{"eval", "--format", "json", "--stdin-input"}
+	for _, m := range modules {
+		args = append(args, "--data", m)
+	}
+	args = append(args, query)
+	match := func(p Proposal) (bool, error) {
+		input, err := json.Marshal(map[string]string{
+			"source": p.Source,
+			"name":   p.Name,
+			"from":   p.From,
+			"to":     p.To,
+			"file":   p.File,
+		})
+		if err != nil {
+			return false, err
+		}
+		return evalRego(args, input)
+	}
+	if _, err := match(Proposal{}); err != nil {
+		return nil, err
+	}
+	return match, nil
+}
+
+// evalRego runs opa eval and reports whether a result of the query has only
+// true expressions.
+func evalRego(args []string, input []byte) (bool, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("opa", args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String() + stdout.String())
+		return false, fmt.Errorf("opa eval: %w: %s", err, msg)
+	}
+	var out struct {
+		Result []struct {
+			Expressions []struct {
+				Value any `json:"value"`
+			} `json:"expressions"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return false, fmt.Errorf("opa eval: decode result: %w", err)
+	}
+	for _, r := range out.Result {
+		matched := len(r.Expressions) > 0
+		for _, e := range r.Expressions {
+			if v, ok := e.Value.(bool); !ok || !v {
+				matched = false
+			}
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
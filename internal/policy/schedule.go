@@ -0,0 +1,123 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/config"
+)
+
+// Freeze is an inclusive range of dates during which nothing is written.
+type Freeze struct {
+	From time.Time
+	To   time.Time
+}
+
+// Schedule restricts when updates are written. The zero Schedule allows any
+// time.
+type Schedule struct {
+	// Days are the allowed weekdays; any day is allowed when empty.
+	Days map[time.Weekday]bool
+	// Start and End bound the allowed hours, [Start, End), wrapping past
+	// midnight when End <= Start. Any hour is allowed when both are zero.
+	Start, End int
+	// Freezes are the dates when no update is written.
+	Freezes []Freeze
+	// Location is the time zone the schedule is expressed in.
+	Location *time.Location
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// NewSchedule parses a schedule declared in the config file. Days accept
+// weekday names along with "weekdays" and "weekends", hours a "HH-HH" range
+// and freezes YYYY-MM-DD dates.
+func NewSchedule(c config.Schedule) (Schedule, error) {
+	s := Schedule{Location: time.UTC}
+	if c.Timezone != "" {
+		loc, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("load timezone %q: %w", c.Timezone, err)
+		}
+		s.Location = loc
+	}
+	for _, d := range c.Days {
+		if s.Days == nil {
+			s.Days = make(map[time.Weekday]bool)
+		}
+		switch d = strings.ToLower(strings.TrimSpace(d)); d {
+		case "weekdays":
+			for w := time.Monday; w <= time.Friday; w++ {
+				s.Days[w] = true
+			}
+		case "weekends":
+			s.Days[time.Saturday] = true
+			s.Days[time.Sunday] = true
+		default:
+			w, ok := weekdays[d]
+			if !ok {
+				return Schedule{}, fmt.Errorf("unknown day %q", d)
+			}
+			s.Days[w] = true
+		}
+	}
+	if c.Hours != "" {
+		start, end, ok := strings.Cut(c.Hours, "-")
+		var err1, err2 error
+		s.Start, err1 = strconv.Atoi(strings.TrimSpace(start))
+		s.End, err2 = strconv.Atoi(strings.TrimSpace(end))
+		if !ok || err1 != nil || err2 != nil || s.Start < 0 || s.Start > 24 || s.End < 0 || s.End > 24 {
+			return Schedule{}, fmt.Errorf("invalid hours %q, expected HH-HH", c.Hours)
+		}
+	}
+	for _, f := range c.Freezes {
+		from, err := time.ParseInLocation(time.DateOnly, f.From, s.Location)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("invalid freeze start %q: %w", f.From, err)
+		}
+		to := from
+		if f.To != "" {
+			to, err = time.ParseInLocation(time.DateOnly, f.To, s.Location)
+			if err != nil {
+				return Schedule{}, fmt.Errorf("invalid freeze end %q: %w", f.To, err)
+			}
+		}
+		s.Freezes = append(s.Freezes, Freeze{From: from, To: to.AddDate(0, 0, 1)})
+	}
+	return s, nil
+}
+
+// Allows reports whether updates may be written at t.
+func (s Schedule) Allows(t time.Time) bool {
+	if s.Location != nil {
+		t = t.In(s.Location)
+	}
+	if len(s.Days) > 0 && !s.Days[t.Weekday()] {
+		return false
+	}
+	if s.Start != 0 || s.End != 0 {
+		h := t.Hour()
+		if s.Start < s.End && (h < s.Start || h >= s.End) {
+			return false
+		}
+		if s.Start >= s.End && h < s.Start && h >= s.End {
+			return false
+		}
+	}
+	for _, f := range s.Freezes {
+		if !t.Before(f.From) && t.Before(f.To) {
+			return false
+		}
+	}
+	return true
+}
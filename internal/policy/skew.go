@@ -0,0 +1,93 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+// KubernetesComponents are the base names of the images and charts versioned
+// with Kubernetes itself.
+var KubernetesComponents = []string{
+	"k0s",
+	"kubelet",
+	"kube-proxy",
+	"kube-apiserver",
+	"kube-controller-manager",
+	"kube-scheduler",
+	"kubectl",
+}
+
+// MaxKubernetesSkew is the number of minor versions a component may be ahead
+// of or behind the cluster.
+const MaxKubernetesSkew = 1
+
+// skew holds the Kubernetes version of the cluster components are checked
+// against.
+type skew struct {
+	minor      string
+	components map[string]bool
+}
+
+// WithKubernetesSkew rejects updates moving a Kubernetes component more than
+// MaxKubernetesSkew minor versions away from the cluster version, e.g. the
+// k0s version of a k0sctl configuration. Components are matched by the base
+// name of the dependency against KubernetesComponents and the given names.
+func WithKubernetesSkew(version string, components []string) Option {
+	return func(e *Engine) {
+		s := &skew{components: make(map[string]bool)}
+		if v, err := updater.Canonical(version); err == nil {
+			s.minor = semver.MajorMinor(v)
+		}
+		for _, c := range append(append([]string{}, KubernetesComponents...), components...) {
+			s.components[c] = true
+		}
+		e.skew = s
+	}
+}
+
+// isComponent reports whether the dependency is versioned with Kubernetes.
+func (s *skew) isComponent(name string) bool {
+	return s.components[name] || s.components[path.Base(name)]
+}
+
+// allows reports whether the version is within the supported skew of the
+// cluster. Versions that are not semantic versions are allowed.
+func (s *skew) allows(version string) bool {
+	v, err := updater.MajorMinorPatch(version)
+	if err != nil || !semver.IsValid(v) || s.minor == "" {
+		return true
+	}
+	d, err := minorDistance(s.minor, semver.MajorMinor(v))
+	if err != nil {
+		return false
+	}
+	return d <= MaxKubernetesSkew
+}
+
+// minorDistance returns the number of minor versions between two vX.Y
+// versions, failing when their majors differ.
+func minorDistance(a, b string) (int, error) {
+	am, an, _ := strings.Cut(strings.TrimPrefix(a, "v"), ".")
+	bm, bn, _ := strings.Cut(strings.TrimPrefix(b, "v"), ".")
+	if am != bm {
+		return 0, fmt.Errorf("major %s differs from %s", bm, am)
+	}
+	x, err := strconv.Atoi(an)
+	if err != nil {
+		return 0, err
+	}
+	y, err := strconv.Atoi(bn)
+	if err != nil {
+		return 0, err
+	}
+	if x > y {
+		return x - y, nil
+	}
+	return y - x, nil
+}
@@ -0,0 +1,203 @@
+// Package rancher resolves the latest k3s and RKE2 releases from the Rancher
+// release channels.
+package rancher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/semver"
+
+	"github.com/shikanime-studio/automata/internal/logging"
+	"github.com/shikanime-studio/automata/internal/mirror"
+	"github.com/shikanime-studio/automata/internal/policy"
+	update "github.com/shikanime-studio/automata/internal/updater"
+)
+
+// Distributions maps the distributions to the URL of their release channels.
+var Distributions = map[string]string{
+	"k3s":  "https://update.k3s.io/v1-release/channels",
+	"rke2": "https://update.rke2.io/v1-release/channels",
+}
+
+// DefaultChannel is the channel followed when none is configured.
+const DefaultChannel = "stable"
+
+// Channel is a release channel of a distribution.
+type Channel struct {
+	// Name is the channel name, e.g. stable, latest or v1.30.
+	Name string `json:"name"`
+	// Latest is the latest release of the channel, e.g. v1.30.4+k3s1.
+	Latest string `json:"latest"`
+}
+
+// Client lists the release channels of distributions, caching them per
+// distribution.
+type Client struct {
+	// URLs maps the distributions to the URL of their release channels.
+	URLs       map[string]string
+	HTTPClient *http.Client
+	mu         sync.Mutex
+	cache      map[string][]Channel
+}
+
+// NewClient creates a client of the public Rancher release channels.
+func NewClient() *Client {
+	return &Client{URLs: Distributions, HTTPClient: http.DefaultClient}
+}
+
+// Channels returns the release channels of a distribution, k3s or rke2.
+func (c *Client) Channels(ctx context.Context, distribution string) ([]Channel, error) {
+	if mirror.Offline(ctx) {
+		return nil, errors.New("release channels are not recorded in metadata snapshots")
+	}
+	url, ok := c.URLs[distribution]
+	if !ok {
+		return nil, fmt.Errorf("unknown distribution %q", distribution)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if channels, ok := c.cache[distribution]; ok {
+		return channels, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create channels request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("query %s channels: %w", distribution, err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.WarnContext(ctx, "close channels response", "err", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("query %s channels: unexpected status %s", distribution, resp.Status)
+	}
+	var body struct {
+		Data []Channel `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode %s channels: %w", distribution, err)
+	}
+	if c.cache == nil {
+		c.cache = make(map[string][]Channel)
+	}
+	c.cache[distribution] = body.Data
+	return body.Data, nil
+}
+
+// Latest returns the latest release of a channel of a distribution.
+func (c *Client) Latest(ctx context.Context, distribution, channel string) (string, error) {
+	channels, err := c.Channels(ctx, distribution)
+	if err != nil {
+		return "", err
+	}
+	for _, ch := range channels {
+		if ch.Name == channel {
+			return ch.Latest, nil
+		}
+	}
+	return "", fmt.Errorf("%s has no channel %q", distribution, channel)
+}
+
+// Distribution returns the distribution of a release from its build
+// metadata, k3s for v1.30.4+k3s1 and rke2 for v1.30.4+rke2r1, or "".
+func Distribution(version string) string {
+	_, build, _ := strings.Cut(version, "+")
+	switch {
+	case strings.HasPrefix(build, "rke2"):
+		return "rke2"
+	case strings.HasPrefix(build, "k3s"):
+		return "k3s"
+	default:
+		return ""
+	}
+}
+
+// Newer reports whether release a is newer than release b, comparing their
+// Kubernetes versions, then the revisions of their builds, e.g. k3s2 after
+// k3s1.
+func Newer(a, b string) bool {
+	av, ab, _ := strings.Cut(a, "+")
+	bv, bb, _ := strings.Cut(b, "+")
+	av, bv = "v"+strings.TrimPrefix(av, "v"), "v"+strings.TrimPrefix(bv, "v")
+	if !semver.IsValid(av) || !semver.IsValid(bv) {
+		return false
+	}
+	if c := semver.Compare(av, bv); c != 0 {
+		return c > 0
+	}
+	return revision(ab) > revision(bb)
+}
+
+// revision returns the trailing number of a build, e.g. 1 for rke2r1.
+func revision(build string) int {
+	i := len(build)
+	for i > 0 && build[i-1] >= '0' && build[i-1] <= '9' {
+		i--
+	}
+	n, _ := strconv.Atoi(build[i:])
+	return n
+}
+
+// ReleaseRef is a pinned release of a distribution.
+type ReleaseRef struct {
+	// Distribution is k3s or rke2, detected from Version when empty.
+	Distribution string
+	// Channel is the channel followed, DefaultChannel when empty.
+	Channel string
+	// Version is the current release.
+	Version string
+}
+
+// Updater finds the latest release of the channel a pin follows.
+type Updater struct {
+	c *Client
+}
+
+// NewUpdater creates an Updater resolving releases with the client.
+func NewUpdater(c *Client) Updater {
+	return Updater{c: c}
+}
+
+// Update returns the latest release of the channel of the reference, or its
+// current release when the channel lags behind it. Releases of an unknown
+// distribution are left alone.
+func (u Updater) Update(ctx context.Context, ref *ReleaseRef, _ ...update.Option) (string, error) {
+	distribution := ref.Distribution
+	if distribution == "" {
+		distribution = Distribution(ref.Version)
+	}
+	if distribution == "" {
+		return ref.Version, nil
+	}
+	channel := ref.Channel
+	if channel == "" {
+		channel = DefaultChannel
+	}
+	ctx = logging.WithAttrs(logging.WithSubsystem(ctx, "rancher"), "dependency", distribution)
+	latest, err := u.c.Latest(ctx, distribution, channel)
+	if err != nil {
+		return "", err
+	}
+	if !Newer(latest, ref.Version) {
+		latest = ref.Version
+	}
+	return policy.Apply(ctx, policy.Proposal{
+		Source: "rancher",
+		Name:   distribution,
+		From:   ref.Version,
+		To:     latest,
+	})
+}
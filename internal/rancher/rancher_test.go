@@ -0,0 +1,80 @@
+package rancher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestClient serves the k3s channels.
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/k3s" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[
+			{"id":"stable","name":"stable","latest":"v1.30.4+k3s1"},
+			{"id":"v1.29","name":"v1.29","latest":"v1.29.8+k3s1"}
+		]}`))
+	}))
+	t.Cleanup(srv.Close)
+	return &Client{URLs: map[string]string{"k3s": srv.URL + "/k3s"}, HTTPClient: srv.Client()}
+}
+
+func TestUpdater(t *testing.T) {
+	u := NewUpdater(newTestClient(t))
+	cases := []struct {
+		ref  ReleaseRef
+		want string
+	}{
+		{ReleaseRef{Version: "v1.29.5+k3s1"}, "v1.30.4+k3s1"},
+		{ReleaseRef{Channel: "v1.29", Version: "v1.29.5+k3s1"}, "v1.29.8+k3s1"},
+		{ReleaseRef{Channel: "v1.29", Version: "v1.30.1+k3s1"}, "v1.30.1+k3s1"},
+		{ReleaseRef{Version: "v1.30.0"}, "v1.30.0"},
+	}
+	for _, c := range cases {
+		got, err := u.Update(context.Background(), &c.ref)
+		if err != nil {
+			t.Fatalf("Update(%+v): %v", c.ref, err)
+		}
+		if got != c.want {
+			t.Errorf("Update(%+v) = %s, want %s", c.ref, got, c.want)
+		}
+	}
+	if _, err := u.Update(context.Background(), &ReleaseRef{Channel: "edge", Version: "v1.30.0+k3s1"}); err == nil {
+		t.Error("expected an error for an unknown channel")
+	}
+}
+
+func TestNewer(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"v1.30.4+k3s1", "v1.30.3+k3s2", true},
+		{"v1.30.4+rke2r2", "v1.30.4+rke2r1", true},
+		{"v1.30.4+rke2r1", "v1.30.4+rke2r1", false},
+		{"v1.29.9+k3s1", "v1.30.0+k3s1", false},
+		{"latest", "v1.30.0+k3s1", false},
+	}
+	for _, c := range cases {
+		if got := Newer(c.a, c.b); got != c.want {
+			t.Errorf("Newer(%s, %s) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	for version, want := range map[string]string{
+		"v1.30.4+k3s1":   "k3s",
+		"v1.30.4+rke2r1": "rke2",
+		"v1.30.4":        "",
+	} {
+		if got := Distribution(version); got != want {
+			t.Errorf("Distribution(%s) = %q, want %q", version, got, want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Package releasenotes scans the release notes of a dependency for breaking
+// changes, migration steps and deprecations, and rates the risk of upgrading
+// across them.
+package releasenotes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxEntries bounds the entries kept per finding kind.
+const MaxEntries = 5
+
+// maxEntryLength bounds the length of an entry, in bytes.
+const maxEntryLength = 200
+
+var (
+	heading  = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	bullet   = regexp.MustCompile(`^\s*(?:[-*+]|\d+\.)\s+`)
+	breaking = regexp.MustCompile(`(?i)\bbreaking\b|^\s*(?:[-*+]\s+)?\w+(?:\([^)]*\))?!:`)
+	migrate  = regexp.MustCompile(`(?i)\bmigrat|\bupgrad(?:e|ing) (?:guide|notes|instructions)`)
+	deprec   = regexp.MustCompile(`(?i)\bdeprecat`)
+)
+
+// Findings are the notable entries of release notes.
+type Findings struct {
+	// Breaking are the breaking changes.
+	Breaking []string
+	// Migrations are the migration or upgrade steps.
+	Migrations []string
+	// Deprecations are the deprecated features.
+	Deprecations []string
+}
+
+// Empty reports whether nothing notable was found.
+func (f Findings) Empty() bool {
+	return len(f.Breaking) == 0 && len(f.Migrations) == 0 && len(f.Deprecations) == 0
+}
+
+// section is the kind of entries a heading introduces.
+type section int
+
+const (
+	other section = iota
+	breakingSection
+	migrationSection
+	deprecationSection
+)
+
+// classify returns the kind of section a heading introduces.
+func classify(title string) section {
+	switch {
+	case breaking.MatchString(title):
+		return breakingSection
+	case migrate.MatchString(title):
+		return migrationSection
+	case deprec.MatchString(title):
+		return deprecationSection
+	default:
+		return other
+	}
+}
+
+// Scan extracts the breaking changes, migration steps and deprecations of
+// release notes. Entries under a heading naming them, e.g. "Breaking
+// Changes" or "Migration Guide", are kept, as are lines elsewhere flagged
+// BREAKING, written as conventional commits with a "!" or mentioning a
+// deprecation.
+func Scan(notes string) Findings {
+	var f Findings
+	current := other
+	for _, line := range strings.Split(notes, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := heading.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			current = classify(m[2])
+			continue
+		}
+		entry := clean(line)
+		if entry == "" {
+			continue
+		}
+		switch {
+		case current == breakingSection:
+			f.Breaking = add(f.Breaking, entry)
+		case current == migrationSection:
+			f.Migrations = add(f.Migrations, entry)
+		case current == deprecationSection:
+			f.Deprecations = add(f.Deprecations, entry)
+		case breaking.MatchString(line):
+			f.Breaking = add(f.Breaking, entry)
+		case deprec.MatchString(line):
+			f.Deprecations = add(f.Deprecations, entry)
+		}
+	}
+	return f
+}
+
+// Merge appends the findings of other, keeping the entry bounds.
+func (f Findings) Merge(other Findings) Findings {
+	for _, e := range other.Breaking {
+		f.Breaking = add(f.Breaking, e)
+	}
+	for _, e := range other.Migrations {
+		f.Migrations = add(f.Migrations, e)
+	}
+	for _, e := range other.Deprecations {
+		f.Deprecations = add(f.Deprecations, e)
+	}
+	return f
+}
+
+// clean strips the list marker of a line and truncates it.
+func clean(line string) string {
+	entry := strings.TrimSpace(bullet.ReplaceAllString(line, ""))
+	if len(entry) > maxEntryLength {
+		n := maxEntryLength
+		for !utf8.RuneStart(entry[n]) {
+			n--
+		}
+		entry = strings.TrimSpace(entry[:n]) + "…"
+	}
+	return entry
+}
+
+// add appends an entry unless already present or the list is full.
+func add(entries []string, entry string) []string {
+	if len(entries) >= MaxEntries {
+		return entries
+	}
+	for _, e := range entries {
+		if e == entry {
+			return entries
+		}
+	}
+	return append(entries, entry)
+}
+
+// Risk rates the risk of an upgrade.
+type Risk string
+
+// Risk values, from the least to the most risky.
+const (
+	Low    Risk = "low"
+	Medium Risk = "medium"
+	High   Risk = "high"
+)
+
+// Assess rates the risk of an upgrade from the findings of its release
+// notes: high with breaking changes, medium with migration steps,
+// deprecations or when no release notes were found, low otherwise.
+func Assess(f Findings, found bool) Risk {
+	switch {
+	case len(f.Breaking) > 0:
+		return High
+	case !found || len(f.Migrations) > 0 || len(f.Deprecations) > 0:
+		return Medium
+	default:
+		return Low
+	}
+}
+
+// Label returns the pull request label of the risk.
+func (r Risk) Label() string {
+	return "risk/" + string(r)
+}
+
+// Max returns the riskier of two risks.
+func Max(a, b Risk) Risk {
+	rank := map[Risk]int{Low: 1, Medium: 2, High: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// Summary renders the findings of an upgrade as a Markdown section of a pull
+// request body, noting when no release notes were found.
+func Summary(name, from, to string, f Findings, found bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s %s → %s (%s risk)\n", name, from, to, Assess(f, found))
+	if !found {
+		b.WriteString("\nNo release notes found; review the upstream changelog.\n")
+		return b.String()
+	}
+	if f.Empty() {
+		b.WriteString("\nNo breaking changes, migration steps or deprecations found in the release notes.\n")
+		return b.String()
+	}
+	for _, s := range []struct {
+		title   string
+		entries []string
+	}{
+		{"Breaking changes", f.Breaking},
+		{"Migration", f.Migrations},
+		{"Deprecations", f.Deprecations},
+	} {
+		if len(s.entries) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s:\n\n", s.title)
+		for _, e := range s.entries {
+			fmt.Fprintf(&b, "- %s\n", e)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,87 @@
+package releasenotes
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const notes = `## What's Changed
+
+* feat!: drop support for Node 16 by @octocat
+* fix: handle empty inputs
+* chore: deprecate the legacy-mode input
+
+## ⚠ BREAKING CHANGES
+
+- The default branch input is now required.
+
+## Migration Guide
+
+1. Rename ` + "`token`" + ` to ` + "`github-token`" + `.
+
+## Deprecations
+
+- The ` + "`v1` API" + ` is deprecated.
+`
+
+func TestScan(t *testing.T) {
+	got := Scan(notes)
+	want := Findings{
+		Breaking: []string{
+			"feat!: drop support for Node 16 by @octocat",
+			"The default branch input is now required.",
+		},
+		Migrations:   []string{"Rename `token` to `github-token`."},
+		Deprecations: []string{"chore: deprecate the legacy-mode input", "The `v1` API is deprecated."},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Scan() = %+v, want %+v", got, want)
+	}
+}
+
+func TestScanBoundsEntries(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("## Breaking\n")
+	for i := 0; i < MaxEntries+2; i++ {
+		b.WriteString("- change " + strings.Repeat("x", i) + "\n")
+	}
+	b.WriteString("- " + strings.Repeat("é", maxEntryLength) + "\n")
+	got := Scan(b.String()).Merge(Scan("- BREAKING: other"))
+	if len(got.Breaking) != MaxEntries {
+		t.Fatalf("expected %d entries, got %d", MaxEntries, len(got.Breaking))
+	}
+	long := Scan("BREAKING " + strings.Repeat("é", maxEntryLength)).Breaking[0]
+	if !strings.HasSuffix(long, "…") || len(long) > maxEntryLength+len("…") {
+		t.Fatalf("expected truncated entry, got %q", long)
+	}
+}
+
+func TestAssess(t *testing.T) {
+	cases := []struct {
+		f     Findings
+		found bool
+		want  Risk
+	}{
+		{Findings{Breaking: []string{"x"}}, true, High},
+		{Findings{Deprecations: []string{"x"}}, true, Medium},
+		{Findings{}, false, Medium},
+		{Findings{}, true, Low},
+	}
+	for _, c := range cases {
+		if got := Assess(c.f, c.found); got != c.want {
+			t.Fatalf("Assess(%+v, %v) = %s, want %s", c.f, c.found, got, c.want)
+		}
+	}
+	if got := Max(Max("", Low), High); got != High || got.Label() != "risk/high" {
+		t.Fatalf("unexpected max risk %s", got)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	got := Summary("actions/checkout", "v3", "v4", Findings{Breaking: []string{"Node 20"}}, true)
+	want := "### actions/checkout v3 → v4 (high risk)\n\nBreaking changes:\n\n- Node 20\n"
+	if got != want {
+		t.Fatalf("Summary() = %q, want %q", got, want)
+	}
+}
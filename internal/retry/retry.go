@@ -0,0 +1,153 @@
+// Package retry retries registry and API calls failing with transient errors,
+// backing off exponentially between attempts.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/config"
+)
+
+// Policy configures how calls are retried.
+type Policy struct {
+	// Attempts is the maximum number of calls, 1 disabling retries.
+	Attempts int
+	// BaseDelay is the delay before the first retry, doubled for each next
+	// one.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts.
+	MaxDelay time.Duration
+	// Jitter randomizes each delay by up to this fraction of it, so
+	// concurrent lookups do not retry in lockstep.
+	Jitter float64
+	// Retryable classifies the errors worth retrying, IsTransient when nil.
+	Retryable func(error) bool
+}
+
+// DefaultPolicy retries calls up to three times, starting after half a
+// second.
+var DefaultPolicy = Policy{
+	Attempts:  3,
+	BaseDelay: 500 * time.Millisecond,
+	MaxDelay:  10 * time.Second,
+	Jitter:    0.2,
+}
+
+// NewPolicy builds a Policy from its config declaration, keeping the defaults
+// of the unset fields.
+func NewPolicy(r config.Retry) Policy {
+	p := DefaultPolicy
+	if r.Attempts > 0 {
+		p.Attempts = r.Attempts
+	}
+	if r.BaseDelay > 0 {
+		p.BaseDelay = r.BaseDelay
+	}
+	if r.MaxDelay > 0 {
+		p.MaxDelay = r.MaxDelay
+	}
+	if r.Jitter != nil {
+		p.Jitter = *r.Jitter
+	}
+	return p
+}
+
+type policyKey struct{}
+
+// WithPolicy returns a context retrying calls with the given policy.
+func WithPolicy(ctx context.Context, p Policy) context.Context {
+	return context.WithValue(ctx, policyKey{}, p)
+}
+
+// FromContext returns the policy of the context, or DefaultPolicy.
+func FromContext(ctx context.Context) Policy {
+	if p, ok := ctx.Value(policyKey{}).(Policy); ok {
+		return p
+	}
+	return DefaultPolicy
+}
+
+// Do calls fn until it succeeds, fails with an error the retryable function
+// rejects or runs out of attempts, with the policy of the context. A nil
+// retryable function defers to the policy.
+func Do(ctx context.Context, retryable func(error) bool, fn func() error) error {
+	_, err := Value(ctx, retryable, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// Value is Do for calls returning a value.
+func Value[T any](ctx context.Context, retryable func(error) bool, fn func() (T, error)) (T, error) {
+	p := FromContext(ctx)
+	if retryable == nil {
+		retryable = p.Retryable
+	}
+	if retryable == nil {
+		retryable = IsTransient
+	}
+	for attempt := 1; ; attempt++ {
+		v, err := fn()
+		if err == nil || attempt >= p.Attempts || ctx.Err() != nil || !retryable(err) {
+			return v, err
+		}
+		delay := p.delay(attempt)
+		slog.DebugContext(ctx, "retrying call", "attempt", attempt, "delay", delay, "err", err)
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return v, err
+		case <-t.C:
+		}
+	}
+}
+
+// delay returns the backoff before the retry following the given attempt.
+func (p Policy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return max(d, 0)
+}
+
+// IsTransient reports whether the error is a network failure likely to go
+// away on retry: timeouts, reset or refused connections, truncated responses
+// and errors reporting themselves as temporary.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) && temporary.Temporary() {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// IsServerError reports whether the HTTP status code is a server failure or
+// a request timeout, worth retrying.
+func IsServerError(code int) bool {
+	return code >= 500 || code == 408
+}
@@ -0,0 +1,70 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/config"
+)
+
+func TestValueRetriesTransientErrors(t *testing.T) {
+	ctx := WithPolicy(context.Background(), Policy{Attempts: 3, BaseDelay: time.Millisecond})
+	calls := 0
+	v, err := Value(ctx, nil, func() (string, error) {
+		calls++
+		if calls < 3 {
+			return "", fmt.Errorf("list tags: %w", io.ErrUnexpectedEOF)
+		}
+		return "v1", nil
+	})
+	if err != nil || v != "v1" || calls != 3 {
+		t.Fatalf("got %q, %v after %d calls", v, err, calls)
+	}
+}
+
+func TestValueStopsOnPermanentErrorsAndAttempts(t *testing.T) {
+	ctx := WithPolicy(context.Background(), Policy{Attempts: 2, BaseDelay: time.Millisecond})
+	calls := 0
+	err := Do(ctx, nil, func() error {
+		calls++
+		return errors.New("not found")
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("expected a single call, got %d: %v", calls, err)
+	}
+	calls = 0
+	err = Do(ctx, func(error) bool { return true }, func() error {
+		calls++
+		return errors.New("unavailable")
+	})
+	if err == nil || calls != 2 {
+		t.Fatalf("expected 2 calls, got %d: %v", calls, err)
+	}
+}
+
+func TestDelayBacksOffWithinBounds(t *testing.T) {
+	p := Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for attempt, want := range map[int]time.Duration{1: 100, 2: 200, 3: 300, 6: 300} {
+		if got := p.delay(attempt); got != want*time.Millisecond {
+			t.Fatalf("delay(%d) = %v, want %v", attempt, got, want*time.Millisecond)
+		}
+	}
+	p.Jitter = 0.5
+	for range 100 {
+		if d := p.delay(1); d < 50*time.Millisecond || d > 150*time.Millisecond {
+			t.Fatalf("jittered delay %v out of bounds", d)
+		}
+	}
+}
+
+func TestNewPolicyKeepsDefaults(t *testing.T) {
+	jitter := 0.0
+	p := NewPolicy(config.Retry{Attempts: 5, Jitter: &jitter})
+	if p.Attempts != 5 || p.BaseDelay != DefaultPolicy.BaseDelay || p.Jitter != 0 {
+		t.Fatalf("unexpected policy %+v", p)
+	}
+}
@@ -0,0 +1,152 @@
+// Package sbom renders the dependency inventory of a tree as a CycloneDX
+// software bill of materials.
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/inventory"
+)
+
+// SpecVersion is the CycloneDX specification version of the documents.
+const SpecVersion = "1.5"
+
+// Document is a CycloneDX BOM.
+type Document struct {
+	BOMFormat   string      `json:"bomFormat"`
+	SpecVersion string      `json:"specVersion"`
+	Version     int         `json:"version"`
+	Metadata    Metadata    `json:"metadata"`
+	Components  []Component `json:"components"`
+}
+
+// Metadata describes the BOM and the tree it inventories.
+type Metadata struct {
+	Timestamp string `json:"timestamp"`
+	Tools     struct {
+		Components []Component `json:"components"`
+	} `json:"tools"`
+	Component Component `json:"component"`
+}
+
+// Component is a dependency of the tree.
+type Component struct {
+	Type       string     `json:"type"`
+	BOMRef     string     `json:"bom-ref,omitempty"`
+	Name       string     `json:"name"`
+	Version    string     `json:"version,omitempty"`
+	PURL       string     `json:"purl,omitempty"`
+	Properties []Property `json:"properties,omitempty"`
+}
+
+// Property is a name-value pair of a component.
+type Property struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Properties recorded on components.
+const (
+	// SourceProperty is the automata source of the dependency, e.g. image.
+	SourceProperty = "automata:source"
+	// FileProperty is a file pinning the dependency, one per file.
+	FileProperty = "automata:file"
+	// RepositoryProperty locates dependencies without a package URL.
+	RepositoryProperty = "automata:repository"
+)
+
+// New returns the BOM of the dependencies of the tree named name, generated
+// at now by the given version of automata. Dependencies pinned by several
+// files are listed once with a file property per file.
+func New(name, version string, now time.Time, deps []inventory.Dependency) Document {
+	doc := Document{BOMFormat: "CycloneDX", SpecVersion: SpecVersion, Version: 1}
+	doc.Metadata.Timestamp = now.UTC().Format(time.RFC3339)
+	doc.Metadata.Tools.Components = []Component{{Type: "application", Name: "automata", Version: version}}
+	doc.Metadata.Component = Component{Type: "application", Name: name}
+	doc.Components = []Component{}
+	index := map[string]int{}
+	for _, d := range deps {
+		c := component(d)
+		if i, ok := index[c.BOMRef]; ok {
+			doc.Components[i].Properties = append(doc.Components[i].Properties, Property{FileProperty, d.File})
+			continue
+		}
+		index[c.BOMRef] = len(doc.Components)
+		doc.Components = append(doc.Components, c)
+	}
+	return doc
+}
+
+// Marshal encodes the document as indented JSON.
+func (d Document) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode sbom: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// component returns the component of a dependency, identified by its package
+// URL.
+func component(d inventory.Dependency) Component {
+	c := Component{
+		Type:       "library",
+		Name:       d.Name,
+		Version:    d.Version,
+		PURL:       PURL(d),
+		Properties: []Property{{SourceProperty, d.Source}},
+	}
+	switch d.Source {
+	case "image":
+		c.Type = "container"
+	case "github", "orb", "azure":
+		c.Type = "application"
+	}
+	if d.Repository != "" {
+		c.Properties = append(c.Properties, Property{RepositoryProperty, d.Repository})
+	}
+	c.Properties = append(c.Properties, Property{FileProperty, d.File})
+	c.BOMRef = c.PURL
+	return c
+}
+
+// PURL returns the package URL of a dependency: docker for images, github
+// for actions and GitHub flake inputs, helm for charts and generic for the
+// others.
+func PURL(d inventory.Dependency) string {
+	version := "@" + url.PathEscape(d.Version)
+	switch d.Source {
+	case "image":
+		ref, err := container.ParseImageRef(d.Name)
+		if err != nil {
+			break
+		}
+		registry, path, ok := strings.Cut(ref.Name, "/")
+		if !ok {
+			break
+		}
+		purl := "pkg:docker/" + path + version
+		if registry != "docker.io" {
+			purl += "?repository_url=" + url.QueryEscape(registry)
+		}
+		return purl
+	case "github":
+		return "pkg:github/" + strings.ToLower(d.Name) + version
+	case "helm":
+		purl := "pkg:helm/" + url.PathEscape(d.Name) + version
+		if d.Repository != "" {
+			purl += "?repository_url=" + url.QueryEscape(d.Repository)
+		}
+		return purl
+	case "flake":
+		if repo, ok := strings.CutPrefix(d.Repository, "github:"); ok {
+			return "pkg:github/" + strings.ToLower(repo) + version
+		}
+	}
+	return "pkg:generic/" + url.PathEscape(d.Source) + "/" + url.PathEscape(d.Name) + version
+}
@@ -0,0 +1,71 @@
+package sbom
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/inventory"
+)
+
+func TestPURL(t *testing.T) {
+	tests := []struct {
+		dep  inventory.Dependency
+		want string
+	}{
+		{inventory.Dependency{Source: "image", Name: "docker.io/library/nginx", Version: "1.25.0"},
+			"pkg:docker/library/nginx@1.25.0"},
+		{inventory.Dependency{Source: "image", Name: "ghcr.io/org/app", Version: "v1"},
+			"pkg:docker/org/app@v1?repository_url=ghcr.io"},
+		{inventory.Dependency{Source: "github", Name: "actions/checkout", Version: "v4"},
+			"pkg:github/actions/checkout@v4"},
+		{inventory.Dependency{Source: "helm", Name: "cilium", Version: "1.15.0", Repository: "https://helm.cilium.io"},
+			"pkg:helm/cilium@1.15.0?repository_url=https%3A%2F%2Fhelm.cilium.io"},
+		{inventory.Dependency{Source: "flake", Name: "nixpkgs", Version: "abc", Repository: "github:NixOS/nixpkgs"},
+			"pkg:github/nixos/nixpkgs@abc"},
+		{inventory.Dependency{Source: "orb", Name: "circleci/node", Version: "5.0.0"},
+			"pkg:generic/orb/circleci%2Fnode@5.0.0"},
+	}
+	for _, tt := range tests {
+		if got := PURL(tt.dep); got != tt.want {
+			t.Errorf("PURL(%+v) = %q, want %q", tt.dep, got, tt.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	doc := New("infra", "v1.0.0", now, []inventory.Dependency{
+		{Source: "github", Name: "actions/checkout", Version: "v4", File: "ci.yaml"},
+		{Source: "github", Name: "actions/checkout", Version: "v4", File: "release.yaml"},
+		{Source: "image", Name: "docker.io/library/nginx", Version: "1.25.0", File: "kustomization.yaml"},
+	})
+	if doc.Metadata.Timestamp != "2024-05-01T12:00:00Z" || doc.Metadata.Component.Name != "infra" {
+		t.Errorf("metadata = %+v", doc.Metadata)
+	}
+	want := []Component{
+		{
+			Type:    "application",
+			BOMRef:  "pkg:github/actions/checkout@v4",
+			Name:    "actions/checkout",
+			Version: "v4",
+			PURL:    "pkg:github/actions/checkout@v4",
+			Properties: []Property{
+				{SourceProperty, "github"},
+				{FileProperty, "ci.yaml"},
+				{FileProperty, "release.yaml"},
+			},
+		},
+		{
+			Type:       "container",
+			BOMRef:     "pkg:docker/library/nginx@1.25.0",
+			Name:       "docker.io/library/nginx",
+			Version:    "1.25.0",
+			PURL:       "pkg:docker/library/nginx@1.25.0",
+			Properties: []Property{{SourceProperty, "image"}, {FileProperty, "kustomization.yaml"}},
+		},
+	}
+	if !reflect.DeepEqual(doc.Components, want) {
+		t.Errorf("components = %+v, want %+v", doc.Components, want)
+	}
+}
@@ -0,0 +1,327 @@
+// Package scaffold inspects a repository for the formats automata updates and
+// generates a starter configuration for them.
+package scaffold
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/fsutil"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+)
+
+// ComposeFiles are the file names of Docker Compose files.
+var ComposeFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// WorkflowInputs maps the setup action inputs pinning a tool version to the
+// tool they pin, for the workflow-inputs section.
+var WorkflowInputs = map[string]string{
+	"go-version":     "go",
+	"node-version":   "node",
+	"python-version": "python",
+	"deno-version":   "deno",
+	"helm-version":   "helm",
+}
+
+// skipDirs are directories never inspected.
+var skipDirs = []string{".git", "node_modules", "vendor", ".direnv"}
+
+// ComposeImage is a service image pinned by tag in a Compose file.
+type ComposeImage struct {
+	// File is the path of the Compose file relative to the repository.
+	File string
+	// Service is the name of the service.
+	Service string
+	// Image is the image name without its tag.
+	Image string
+}
+
+// Detection lists the formats found in a repository, with paths relative to
+// it.
+type Detection struct {
+	Kustomizations []string
+	Workflows      []string
+	Flakes         []string
+	ComposeFiles   []string
+	// ComposeImages are the tagged service images of the Compose files.
+	ComposeImages []ComposeImage
+	// WorkflowInputs are the known setup action inputs used by workflows.
+	WorkflowInputs map[string]string
+}
+
+// Empty reports whether no format was detected.
+func (d Detection) Empty() bool {
+	return len(d.Kustomizations) == 0 && len(d.Workflows) == 0 &&
+		len(d.Flakes) == 0 && len(d.ComposeFiles) == 0
+}
+
+// Detect walks the repository at root and lists the formats it contains.
+func Detect(root string) (Detection, error) {
+	d := Detection{WorkflowInputs: map[string]string{}}
+	err := filepath.WalkDir(root, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() {
+			if path != root && slices.Contains(skipDirs, e.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		switch {
+		case slices.Contains(ikio.KustomizationFiles, e.Name()):
+			d.Kustomizations = append(d.Kustomizations, rel)
+		case e.Name() == "flake.nix":
+			d.Flakes = append(d.Flakes, rel)
+		case slices.Contains(ComposeFiles, e.Name()):
+			d.ComposeFiles = append(d.ComposeFiles, rel)
+			images, err := composeImages(path, rel)
+			if err != nil {
+				return err
+			}
+			d.ComposeImages = append(d.ComposeImages, images...)
+		case isWorkflow(rel):
+			d.Workflows = append(d.Workflows, rel)
+			if err := workflowInputs(path, d.WorkflowInputs); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Detection{}, fmt.Errorf("inspect %s: %w", root, err)
+	}
+	return d, nil
+}
+
+// isWorkflow reports whether the relative path is a GitHub workflow.
+func isWorkflow(rel string) bool {
+	dir, name := filepath.Split(rel)
+	ext := filepath.Ext(name)
+	return dir == ".github/workflows/" && (ext == ".yaml" || ext == ".yml")
+}
+
+// composeImages lists the services of a Compose file whose image is pinned
+// by tag alone. Images interpolating variables are skipped.
+func composeImages(path, rel string) ([]ComposeImage, error) {
+	node, err := yaml.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", rel, err)
+	}
+	services, err := node.Pipe(yaml.Lookup("services"))
+	if err != nil || services == nil {
+		return nil, err
+	}
+	var images []ComposeImage
+	err = services.VisitFields(func(service *yaml.MapNode) error {
+		image, err := service.Value.GetString("image")
+		if err != nil || strings.Contains(image, "$") {
+			return nil
+		}
+		ref, err := container.ParseImageRef(image)
+		if err != nil || ref.Digest != "" || !strings.HasSuffix(image, ":"+ref.Tag) {
+			return nil
+		}
+		images = append(images, ComposeImage{
+			File:    rel,
+			Service: service.Key.YNode().Value,
+			Image:   strings.TrimSuffix(image, ":"+ref.Tag),
+		})
+		return nil
+	})
+	return images, err
+}
+
+// workflowInputs records the known setup action inputs used by the steps of
+// a workflow.
+func workflowInputs(path string, inputs map[string]string) error {
+	node, err := yaml.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	jobs, err := node.Pipe(yaml.Lookup("jobs"))
+	if err != nil || jobs == nil {
+		return err
+	}
+	return jobs.VisitFields(func(job *yaml.MapNode) error {
+		steps, err := job.Value.Pipe(yaml.Lookup("steps"))
+		if err != nil || steps == nil {
+			return err
+		}
+		elems, err := steps.Elements()
+		if err != nil {
+			return nil
+		}
+		for _, step := range elems {
+			with := step.Field("with")
+			if with == nil {
+				continue
+			}
+			keys, err := with.Value.Fields()
+			if err != nil {
+				continue
+			}
+			for _, key := range keys {
+				if tool, ok := WorkflowInputs[key]; ok {
+					inputs[key] = tool
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Commands returns the update commands covering the detected formats.
+func (d Detection) Commands() []string {
+	var cmds []string
+	if len(d.Kustomizations) > 0 {
+		cmds = append(cmds, "automata update kustomization .")
+	}
+	if len(d.Workflows) > 0 {
+		cmds = append(cmds, "automata update githubworkflow .")
+	}
+	if len(d.Flakes) > 0 {
+		cmds = append(cmds, "automata update flake .")
+	}
+	if len(d.ComposeImages) > 0 {
+		cmds = append(cmds, "automata update rule .")
+	}
+	return cmds
+}
+
+// Config renders a starter .automata.yaml for the detected formats: the update
+// commands to run, the workflow inputs to bump and a rule per Compose service
+// image.
+func Config(d Detection) []byte {
+	var b bytes.Buffer
+	b.WriteString("# Starter configuration generated by automata init; check it with\n")
+	b.WriteString("# automata validate. Update with:\n")
+	for _, cmd := range d.Commands() {
+		fmt.Fprintf(&b, "#   %s\n", cmd)
+	}
+	b.WriteString("\n# Keep a .bak copy of each rewritten file.\nbackup: false\n")
+	if len(d.WorkflowInputs) > 0 {
+		b.WriteString("\n# Tool versions pinned in setup action inputs.\nworkflow-inputs:\n")
+		keys := make([]string, 0, len(d.WorkflowInputs))
+		for k := range d.WorkflowInputs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "  %s: %s\n", k, d.WorkflowInputs[k])
+		}
+	}
+	if len(d.ComposeImages) > 0 {
+		b.WriteString("\n# Service images of the Compose files.\nrules:\n")
+		for _, img := range d.ComposeImages {
+			fmt.Fprintf(&b, "  - files: %s\n", img.File)
+			fmt.Fprintf(&b, "    path: services.%s.image\n", img.Service)
+			b.WriteString("    source: image\n")
+			fmt.Fprintf(&b, "    image: %s\n", img.Image)
+			b.WriteString("    regex: \":(?P<version>[^:@]+)$\"\n")
+		}
+	}
+	return b.Bytes()
+}
+
+// ErrExists is returned by Write when the config file already exists.
+var ErrExists = errors.New("config file already exists")
+
+// Write writes the starter configuration to path, refusing to overwrite an
+// existing file unless force is set.
+func Write(path string, d Detection, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s: %w", path, ErrExists)
+	}
+	if err := os.WriteFile(path, Config(d), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
+
+// annotateFilter sets, on each kustomization declaring images without the
+// images annotation, an example annotation selecting tags for every image,
+// recording the annotated files relative to the package.
+func annotateFilter(annotated map[string]string) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		for _, node := range nodes {
+			existing, err := node.Pipe(ikio.GetImagesAnnotation())
+			if err != nil {
+				return nil, err
+			}
+			if existing != nil {
+				continue
+			}
+			tags, err := ikio.GetKustomizationImageTags(node)
+			if err != nil {
+				return nil, err
+			}
+			if len(tags) == 0 {
+				continue
+			}
+			names := make([]string, 0, len(tags))
+			for name := range tags {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			configs := make([]map[string]string, len(names))
+			for i, name := range names {
+				configs[i] = map[string]string{"name": name}
+			}
+			value, err := json.Marshal(configs)
+			if err != nil {
+				return nil, err
+			}
+			if err := node.PipeE(yaml.SetAnnotation(ikio.ImagesAnnotation, string(value))); err != nil {
+				return nil, fmt.Errorf("set images annotation: %w", err)
+			}
+			file, _, err := kioutil.GetFileAnnotations(node)
+			if err != nil {
+				return nil, err
+			}
+			annotated[filepath.ToSlash(file)] = string(value)
+		}
+		return nodes, nil
+	})
+}
+
+// Annotations returns the example images annotation of each kustomization
+// under root declaring images without one, by file relative to root. With
+// write, the annotations are added to the kustomizations.
+func Annotations(ctx context.Context, root string, write bool) (map[string]string, error) {
+	annotated := map[string]string{}
+	p := kio.Pipeline{
+		Inputs: []kio.Reader{
+			kio.LocalPackageReader{PackagePath: root, MatchFilesGlob: ikio.KustomizationFiles},
+		},
+		Filters: []kio.Filter{annotateFilter(annotated)},
+	}
+	if write {
+		p.Outputs = []kio.Writer{
+			kio.LocalPackageWriter{PackagePath: root, FileSystem: fsutil.NewAtomicFS(ctx, nil)},
+		}
+	}
+	if err := p.Execute(); err != nil {
+		return nil, fmt.Errorf("annotate kustomizations: %w", err)
+	}
+	return annotated, nil
+}
@@ -0,0 +1,88 @@
+package scaffold
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func TestDetectAndConfig(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "k8s", "kustomization.yaml"), "images:\n  - name: app\n    newTag: v1.0.0\n")
+	writeFile(t, filepath.Join(root, "flake.nix"), "{}\n")
+	writeFile(t, filepath.Join(root, "compose.yaml"),
+		"services:\n  web:\n    image: nginx:1.25\n  db:\n    image: postgres:${PG}\n  cache:\n    image: redis\n")
+	writeFile(t, filepath.Join(root, ".github", "workflows", "ci.yaml"),
+		"on: push\njobs:\n  build:\n    runs-on: ubuntu-latest\n    steps:\n      - uses: actions/setup-node@v4\n        with:\n          node-version: 20\n")
+	writeFile(t, filepath.Join(root, "node_modules", "dep", "kustomization.yaml"), "images: []\n")
+
+	d, err := Detect(root)
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if len(d.Kustomizations) != 1 || d.Kustomizations[0] != "k8s/kustomization.yaml" {
+		t.Fatalf("unexpected kustomizations %v", d.Kustomizations)
+	}
+	if len(d.Workflows) != 1 || len(d.Flakes) != 1 || len(d.ComposeFiles) != 1 {
+		t.Fatalf("unexpected detection %+v", d)
+	}
+	if len(d.ComposeImages) != 1 || d.ComposeImages[0] != (ComposeImage{File: "compose.yaml", Service: "web", Image: "nginx"}) {
+		t.Fatalf("unexpected compose images %+v", d.ComposeImages)
+	}
+	cfg := string(Config(d))
+	for _, want := range []string{
+		"#   automata update flake .",
+		"  node-version: node",
+		"    path: services.web.image",
+		"    image: nginx",
+	} {
+		if !strings.Contains(cfg, want) {
+			t.Fatalf("config misses %q:\n%s", want, cfg)
+		}
+	}
+
+	path := filepath.Join(root, "automata.yaml")
+	if err := Write(path, d, false); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := Write(path, d, false); err == nil {
+		t.Fatalf("expected an existing config to be kept")
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "kustomization.yaml")
+	writeFile(t, path, "images:\n- name: redis\n  newTag: \"7.2\"\n- name: app\n  newTag: v1.0.0\n")
+	writeFile(t, filepath.Join(root, "annotated", "kustomization.yaml"),
+		"metadata:\n  annotations:\n    automata.shikanime.studio/images: '[{\"name\":\"app\"}]'\nimages:\n- name: app\n  newTag: v1.0.0\n")
+
+	got, err := Annotations(context.Background(), root, false)
+	if err != nil {
+		t.Fatalf("Annotations: %v", err)
+	}
+	if len(got) != 1 || got["kustomization.yaml"] != `[{"name":"app"},{"name":"redis"}]` {
+		t.Fatalf("unexpected annotations %v", got)
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "automata.shikanime.studio") {
+		t.Fatalf("expected no write without write")
+	}
+	if _, err := Annotations(context.Background(), root, true); err != nil {
+		t.Fatalf("Annotations: %v", err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `automata.shikanime.studio/images: '[{"name":"app"},{"name":"redis"}]'`) {
+		t.Fatalf("expected the annotation to be written:\n%s", data)
+	}
+}
@@ -0,0 +1,312 @@
+// Package schema validates JSON documents against the subset of JSON Schema
+// the annotation schemas use, reporting each violation at its line and column
+// in the document.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema is a JSON Schema restricted to the type, properties, required,
+// additionalProperties, items, enum, minLength, pattern and regex format
+// keywords, plus errorMessage.
+type Schema struct {
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	MinLength            int                `json:"minLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	// ErrorMessage replaces the message of pattern violations, as in the
+	// ajv-errors extension.
+	ErrorMessage string `json:"errorMessage,omitempty"`
+	Format       string `json:"format,omitempty"`
+}
+
+// Parse decodes a schema document.
+func Parse(data []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse schema: %w", err)
+	}
+	return &s, nil
+}
+
+// MustParse is Parse panicking on error, for embedded schemas.
+func MustParse(data []byte) *Schema {
+	s, err := Parse(data)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Error is a violation of a schema, positioned in the validated document.
+type Error struct {
+	// Pointer is the JSON pointer of the offending value, e.g. /0/tag-regex.
+	Pointer string
+	// Line and Column locate the offending value, starting at 1.
+	Line, Column int
+	// Message describes the violation.
+	Message string
+}
+
+// Error formats the violation as line:column: pointer: message.
+func (e Error) Error() string {
+	if e.Pointer == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Column, e.Pointer, e.Message)
+}
+
+// Validate checks the JSON document against the schema, returning every
+// violation in document order, or the syntax error preventing the check.
+func (s *Schema) Validate(data []byte) []Error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decode(dec, data)
+	if err != nil {
+		return []Error{syntaxError(data, err)}
+	}
+	if offset := start(data, dec.InputOffset()); offset < len(data) {
+		return []Error{newError(data, "", offset, "invalid JSON: unexpected content after the document")}
+	}
+	var errs []Error
+	s.validate(data, v, "", &errs)
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Column < errs[j].Column
+	})
+	return errs
+}
+
+// syntaxError positions the error that stopped decoding data.
+func syntaxError(data []byte, err error) Error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return newError(data, "", int(syntaxErr.Offset), "invalid JSON: "+err.Error())
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return newError(data, "", len(data), "invalid JSON: unexpected end of document")
+	}
+	return newError(data, "", len(data), "invalid JSON: "+err.Error())
+}
+
+// value is a decoded JSON value with the offset it starts at.
+type value struct {
+	offset  int
+	kind    string
+	scalar  any
+	members []member
+	items   []*value
+}
+
+// member is an object property with the offset of its key.
+type member struct {
+	key    string
+	offset int
+	value  *value
+}
+
+// decode reads the next value of the decoder, recording the offsets of values
+// and keys in data.
+func decode(dec *json.Decoder, data []byte) (*value, error) {
+	offset := start(data, dec.InputOffset())
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	v := &value{offset: offset}
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			v.kind = "array"
+			for dec.More() {
+				item, err := decode(dec, data)
+				if err != nil {
+					return nil, err
+				}
+				v.items = append(v.items, item)
+			}
+		} else {
+			v.kind = "object"
+			for dec.More() {
+				keyOffset := start(data, dec.InputOffset())
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				val, err := decode(dec, data)
+				if err != nil {
+					return nil, err
+				}
+				v.members = append(v.members, member{key: key.(string), offset: keyOffset, value: val})
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	case string:
+		v.kind, v.scalar = "string", tok
+	case bool:
+		v.kind, v.scalar = "boolean", tok
+	case json.Number:
+		v.kind, v.scalar = "number", tok
+		if _, err := tok.Int64(); err == nil {
+			v.kind = "integer"
+		}
+	case nil:
+		v.kind = "null"
+	}
+	return v, nil
+}
+
+// start skips the separators between the decoder offset and the next token.
+func start(data []byte, offset int64) int {
+	i := int(offset)
+	for i < len(data) && strings.IndexByte(" \t\r\n,:", data[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// newError positions an error at the offset of data.
+func newError(data []byte, pointer string, offset int, msg string) Error {
+	offset = min(offset, len(data))
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	column := 1 + offset - (bytes.LastIndexByte(data[:offset], '\n') + 1)
+	return Error{Pointer: pointer, Line: line, Column: column, Message: msg}
+}
+
+// validate appends the violations of v to errs.
+func (s *Schema) validate(data []byte, v *value, pointer string, errs *[]Error) {
+	fail := func(offset int, pointer, format string, args ...any) {
+		*errs = append(*errs, newError(data, pointer, offset, fmt.Sprintf(format, args...)))
+	}
+	if s.Type != "" && s.Type != v.kind && (s.Type != "number" || v.kind != "integer") {
+		fail(v.offset, pointer, "expected %s, got %s", s.Type, v.kind)
+		return
+	}
+	switch v.kind {
+	case "string":
+		str := v.scalar.(string)
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			fail(v.offset, pointer, "%q is not one of %s", str, quoteAll(s.Enum))
+		}
+		if len(str) < s.MinLength {
+			fail(v.offset, pointer, "must not be empty")
+		}
+		if s.Pattern != "" {
+			if ok, err := regexp.MatchString(s.Pattern, str); err != nil || !ok {
+				msg := fmt.Sprintf("does not match %q", s.Pattern)
+				if s.ErrorMessage != "" {
+					msg = s.ErrorMessage
+				}
+				fail(v.offset, pointer, "%q %s", str, msg)
+			}
+		}
+		if s.Format == "regex" {
+			if _, err := regexp.Compile(str); err != nil {
+				fail(v.offset, pointer, "invalid regular expression: %v", err)
+			}
+		}
+	case "array":
+		if s.Items != nil {
+			for i, item := range v.items {
+				s.Items.validate(data, item, pointer+"/"+strconv.Itoa(i), errs)
+			}
+		}
+	case "object":
+		seen := make(map[string]bool, len(v.members))
+		for _, m := range v.members {
+			seen[m.key] = true
+			p := pointer + "/" + escape(m.key)
+			if prop, ok := s.Properties[m.key]; ok {
+				prop.validate(data, m.value, p, errs)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				msg := fmt.Sprintf("unknown property %q", m.key)
+				if near := s.nearest(m.key); near != "" {
+					msg += fmt.Sprintf(", did you mean %q?", near)
+				}
+				fail(m.offset, p, "%s", msg)
+			}
+		}
+		for _, name := range s.Required {
+			if !seen[name] {
+				fail(v.offset, pointer, "missing required property %q", name)
+			}
+		}
+	}
+}
+
+// nearest returns the property closest to the misspelled key.
+func (s *Schema) nearest(key string) string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return Nearest(key, names)
+}
+
+// Nearest returns the name closest to the misspelled key, if any is within
+// two edits, for did-you-mean suggestions.
+func Nearest(key string, names []string) string {
+	best, bestDist := "", 3
+	for _, name := range names {
+		if d := distance(strings.ToLower(key), name); d < bestDist {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// escape escapes a property name for a JSON pointer.
+func escape(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// quoteAll quotes and joins the allowed values of an enum.
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return strings.Join(quoted, ", ")
+}
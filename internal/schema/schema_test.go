@@ -0,0 +1,73 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+var testSchema = MustParse([]byte(`{
+  "type": "array",
+  "items": {
+    "type": "object",
+    "required": ["name"],
+    "additionalProperties": false,
+    "properties": {
+      "name": {"type": "string", "minLength": 1},
+      "tag-regex": {
+        "type": "string",
+        "format": "regex",
+        "pattern": "\\(\\?P<version>",
+        "errorMessage": "has no version group"
+      },
+      "ordering": {"type": "string", "enum": ["semver", "numeric"]},
+      "verify": {"type": "boolean"}
+    }
+  }
+}`))
+
+func TestValidateReportsPositionedViolations(t *testing.T) {
+	doc := "[\n  {\n    \"tag-regx\": \"(\",\n    \"ordering\": \"date\",\n    \"verify\": \"yes\"\n  }\n]"
+	want := []string{
+		`2:3: /0: missing required property "name"`,
+		`3:5: /0/tag-regx: unknown property "tag-regx", did you mean "tag-regex"?`,
+		`4:17: /0/ordering: "date" is not one of "semver", "numeric"`,
+		`5:15: /0/verify: expected boolean, got string`,
+	}
+	errs := testSchema.Validate([]byte(doc))
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(errs), len(want), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != want[i] {
+			t.Fatalf("error %d = %q, want %q", i, err.Error(), want[i])
+		}
+	}
+}
+
+func TestValidateChecksRegexesAndAcceptsValidDocuments(t *testing.T) {
+	errs := testSchema.Validate([]byte(`[{"name":"app","tag-regex":"(?P<version>"}]`))
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, "invalid regular expression") {
+		t.Fatalf("expected an invalid regex error, got %v", errs)
+	}
+	errs = testSchema.Validate([]byte(`[{"name":"app","tag-regex":"^v(.*)$"}]`))
+	if len(errs) != 1 || errs[0].Message != `"^v(.*)$" has no version group` {
+		t.Fatalf("expected a pattern error, got %v", errs)
+	}
+	if errs := testSchema.Validate([]byte(`[{"name":"app","tag-regex":"^v(?P<version>.*)$","verify":true}]`)); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestValidateReportsSyntaxErrors(t *testing.T) {
+	for doc, want := range map[string]string{
+		"[\n  {\"name\": \"app\",}\n]": "2:",
+		`[{"name": "app"}`:             "1:17:",
+		`[] []`:                        "1:4:",
+	} {
+		errs := testSchema.Validate([]byte(doc))
+		if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), want) ||
+			!strings.Contains(errs[0].Message, "invalid JSON") {
+			t.Fatalf("Validate(%q) = %v, want a syntax error at %s", doc, errs, want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package secret fetches credentials from external secret managers at
+// runtime, through the CLI of each manager, so CI jobs need not hold them in
+// plain text in their environment.
+package secret
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/timeout"
+)
+
+// Providers of secrets.
+const (
+	// Vault reads a field of a Vault KV secret with the vault CLI.
+	Vault = "vault"
+	// AWS reads an AWS Secrets Manager secret with the aws CLI.
+	AWS = "aws"
+	// GCP reads a GCP Secret Manager secret version with the gcloud CLI.
+	GCP = "gcp"
+	// Exec runs a credential helper printing the secret.
+	Exec = "exec"
+)
+
+// Fetch returns the secret a reference points to, without its trailing
+// newline. The CLIs authenticate as they are configured to, e.g. with
+// VAULT_TOKEN or the workload identity of the job.
+func Fetch(ctx context.Context, ref config.SecretRef) (string, error) {
+	args, err := command(ref)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := timeout.Context(ctx, timeout.Secret)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = os.Environ()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("fetch %s secret: %w: %s", ref.Provider, err, msg)
+		}
+		return "", fmt.Errorf("fetch %s secret: %w", ref.Provider, err)
+	}
+	s := strings.TrimRight(string(out), "\r\n")
+	if ref.Key == "" {
+		return s, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(s), &fields); err != nil {
+		return "", fmt.Errorf("%s secret is not a JSON object: %w", ref.Provider, err)
+	}
+	v, ok := fields[ref.Key].(string)
+	if !ok {
+		return "", fmt.Errorf("%s secret has no string key %q", ref.Provider, ref.Key)
+	}
+	return v, nil
+}
+
+// command returns the command line reading the secret of a reference.
+func command(ref config.SecretRef) ([]string, error) {
+	switch ref.Provider {
+	case Vault:
+		if ref.Path == "" {
+			return nil, errors.New("vault secret without path")
+		}
+		field := ref.Field
+		if field == "" {
+			field = "value"
+		}
+		return []string{"vault", "kv", "get", "-field=" + field, ref.Path}, nil
+	case AWS:
+		if ref.Name == "" {
+			return nil, errors.New("aws secret without name")
+		}
+		args := []string{
+			"aws", "secretsmanager", "get-secret-value",
+			"--secret-id", ref.Name,
+			"--query", "SecretString",
+			"--output", "text",
+		}
+		if ref.Region != "" {
+			args = append(args, "--region", ref.Region)
+		}
+		return args, nil
+	case GCP:
+		if ref.Name == "" {
+			return nil, errors.New("gcp secret without name")
+		}
+		version := ref.Version
+		if version == "" {
+			version = "latest"
+		}
+		args := []string{"gcloud", "secrets", "versions", "access", version, "--secret", ref.Name}
+		if ref.Project != "" {
+			args = append(args, "--project", ref.Project)
+		}
+		return args, nil
+	case Exec:
+		if len(ref.Command) == 0 {
+			return nil, errors.New("exec secret without command")
+		}
+		return ref.Command, nil
+	default:
+		return nil, fmt.Errorf("unknown secret provider %q", ref.Provider)
+	}
+}
+
+// ResolveHelmRepository returns the repository with its password and token
+// fetched when it declares a secret for them.
+func ResolveHelmRepository(ctx context.Context, r config.HelmRepository) (config.HelmRepository, error) {
+	if r.PasswordFrom != nil {
+		p, err := Fetch(ctx, *r.PasswordFrom)
+		if err != nil {
+			return r, fmt.Errorf("password of helm repository %s: %w", r.URL, err)
+		}
+		r.Password = p
+	}
+	if r.TokenFrom != nil {
+		t, err := Fetch(ctx, *r.TokenFrom)
+		if err != nil {
+			return r, fmt.Errorf("token of helm repository %s: %w", r.URL, err)
+		}
+		r.Token = t
+	}
+	return r, nil
+}
+
+// ResolveRegistryCredential returns the credential with its password fetched
+// when it declares a secret for it.
+func ResolveRegistryCredential(ctx context.Context, c config.RegistryCredential) (config.RegistryCredential, error) {
+	if c.PasswordFrom != nil {
+		p, err := Fetch(ctx, *c.PasswordFrom)
+		if err != nil {
+			return c, fmt.Errorf("password of registry %s: %w", c.Registry, err)
+		}
+		c.Password = p
+	}
+	return c, nil
+}
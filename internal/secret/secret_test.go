@@ -0,0 +1,87 @@
+package secret
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/shikanime-studio/automata/internal/config"
+)
+
+func TestCommand(t *testing.T) {
+	for _, tc := range []struct {
+		ref  config.SecretRef
+		want []string
+	}{
+		{
+			config.SecretRef{Provider: Vault, Path: "secret/ci"},
+			[]string{"vault", "kv", "get", "-field=value", "secret/ci"},
+		},
+		{
+			config.SecretRef{Provider: AWS, Name: "ci/github", Region: "eu-west-3"},
+			[]string{
+				"aws", "secretsmanager", "get-secret-value", "--secret-id", "ci/github",
+				"--query", "SecretString", "--output", "text", "--region", "eu-west-3",
+			},
+		},
+		{
+			config.SecretRef{Provider: GCP, Name: "github-token", Project: "acme"},
+			[]string{"gcloud", "secrets", "versions", "access", "latest", "--secret", "github-token", "--project", "acme"},
+		},
+	} {
+		got, err := command(tc.ref)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.ref.Provider, err)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.ref.Provider, got, tc.want)
+		}
+	}
+	for _, ref := range []config.SecretRef{
+		{Provider: Vault},
+		{Provider: AWS},
+		{Provider: GCP},
+		{Provider: Exec},
+		{Provider: "keepass", Name: "token"},
+	} {
+		if _, err := command(ref); err == nil {
+			t.Errorf("%+v: expected an error", ref)
+		}
+	}
+}
+
+func TestFetch_Exec(t *testing.T) {
+	ctx := context.Background()
+	got, err := Fetch(ctx, config.SecretRef{Provider: Exec, Command: []string{"echo", "s3cr3t"}})
+	if err != nil || got != "s3cr3t" {
+		t.Errorf("got %q, %v, want s3cr3t", got, err)
+	}
+	ref := config.SecretRef{Provider: Exec, Command: []string{"echo", `{"password": "hunter2"}`}, Key: "password"}
+	if got, err := Fetch(ctx, ref); err != nil || got != "hunter2" {
+		t.Errorf("key: got %q, %v, want hunter2", got, err)
+	}
+	ref.Key = "token"
+	if _, err := Fetch(ctx, ref); err == nil {
+		t.Error("missing key: expected an error")
+	}
+	if _, err := Fetch(ctx, config.SecretRef{Provider: Exec, Command: []string{"false"}}); err == nil {
+		t.Error("failing helper: expected an error")
+	}
+}
+
+func TestResolveRegistryCredential(t *testing.T) {
+	ctx := context.Background()
+	fetched, err := ResolveRegistryCredential(ctx, config.RegistryCredential{
+		Registry: "ghcr.io", Username: "ci", PasswordFrom: &config.SecretRef{Provider: Exec, Command: []string{"echo", "token"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	plain, err := ResolveRegistryCredential(ctx, config.RegistryCredential{Registry: "quay.io", Username: "ci", Password: "plain"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fetched.Password != "token" || plain.Password != "plain" {
+		t.Errorf("got passwords %q and %q", fetched.Password, plain.Password)
+	}
+}
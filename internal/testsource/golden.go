@@ -0,0 +1,207 @@
+package testsource
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/shikanime-studio/automata/internal/fsutil"
+	"github.com/shikanime-studio/automata/internal/mirror"
+)
+
+// Directories of a golden case.
+const (
+	// InputDir holds the tree the updates run over.
+	InputDir = "input"
+	// GoldenDir holds the tree the updates are expected to leave.
+	GoldenDir = "golden"
+)
+
+// Mismatch is a file of a golden case the updates left different from its
+// golden output.
+type Mismatch struct {
+	// Case is the name of the case.
+	Case string
+	// Path is the path of the file relative to the case trees.
+	Path string
+	// Diff lists the lines of the golden file missing from the output with a
+	// "-" prefix and the lines of the output missing from it with a "+".
+	Diff string
+}
+
+// String returns the mismatch as a header followed by its diff.
+func (m Mismatch) String() string {
+	return fmt.Sprintf("--- %s/%s\n%s", m.Case, m.Path, m.Diff)
+}
+
+// RunFunc runs the updates over the tree at dir.
+type RunFunc func(ctx context.Context, dir string) error
+
+// RunGolden runs the updates over a copy of the input tree of every case
+// under dir, each a directory, and compares the result with its golden tree.
+// Lookups are served from the metadata.json snapshot of the case when it has
+// one, otherwise from the recorded fixtures. With update, the golden trees
+// are rewritten from the results instead.
+func RunGolden(ctx context.Context, dir string, update bool, run RunFunc) ([]Mismatch, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read golden cases: %w", err)
+	}
+	var mismatches []Mismatch
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		m, err := runCase(ctx, filepath.Join(dir, e.Name()), update, run)
+		if err != nil {
+			return nil, fmt.Errorf("golden case %s: %w", e.Name(), err)
+		}
+		mismatches = append(mismatches, m...)
+	}
+	return mismatches, nil
+}
+
+// runCase runs the updates of one golden case.
+func runCase(ctx context.Context, dir string, update bool, run RunFunc) ([]Mismatch, error) {
+	snap := Fixtures()
+	if _, err := os.Stat(filepath.Join(dir, mirror.SnapshotFile)); err == nil {
+		if snap, err = mirror.Load(filepath.Join(dir, mirror.SnapshotFile)); err != nil {
+			return nil, err
+		}
+	}
+	ctx = fsutil.WithBackups(mirror.WithOffline(ctx, snap), false)
+	out, err := os.MkdirTemp("", "automata-golden-")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(out)
+	if err := fsutil.CopyDir(filepath.Join(dir, InputDir), out); err != nil {
+		return nil, fmt.Errorf("copy input: %w", err)
+	}
+	if err := run(ctx, out); err != nil {
+		return nil, err
+	}
+	golden := filepath.Join(dir, GoldenDir)
+	if update {
+		if err := os.RemoveAll(golden); err != nil {
+			return nil, fmt.Errorf("remove golden: %w", err)
+		}
+		if err := fsutil.CopyDir(out, golden); err != nil {
+			return nil, fmt.Errorf("write golden: %w", err)
+		}
+		return nil, nil
+	}
+	return compareTrees(filepath.Base(dir), golden, out)
+}
+
+// compareTrees compares the files of the golden tree with those of the
+// output tree.
+func compareTrees(name, golden, out string) ([]Mismatch, error) {
+	want, err := readTree(golden)
+	if err != nil {
+		return nil, err
+	}
+	got, err := readTree(out)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(want)+len(got))
+	for p := range want {
+		paths = append(paths, p)
+	}
+	for p := range got {
+		if _, ok := want[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	slices.Sort(paths)
+	var mismatches []Mismatch
+	for _, p := range paths {
+		w, inWant := want[p]
+		g, inGot := got[p]
+		switch {
+		case !inWant:
+			mismatches = append(mismatches, Mismatch{Case: name, Path: p, Diff: "unexpected file\n"})
+		case !inGot:
+			mismatches = append(mismatches, Mismatch{Case: name, Path: p, Diff: "missing file\n"})
+		case !bytes.Equal(w, g):
+			mismatches = append(mismatches, Mismatch{Case: name, Path: p, Diff: lineDiff(string(w), string(g))})
+		}
+	}
+	return mismatches, nil
+}
+
+// readTree reads the regular files of a tree by path relative to its root.
+// A missing tree holds no file.
+func readTree(root string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return files, nil
+	}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", root, err)
+	}
+	return files, nil
+}
+
+// lineDiff lists the lines of want missing from got with a "-" prefix and
+// the lines of got missing from want with a "+", in the order of a longest
+// common subsequence of their lines.
+func lineDiff(want, got string) string {
+	a := strings.SplitAfter(want, "\n")
+	b := strings.SplitAfter(got, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var sb strings.Builder
+	line := func(prefix, s string) {
+		sb.WriteString(prefix + strings.TrimSuffix(s, "\n") + "\n")
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			line("-", a[i])
+			i++
+		default:
+			line("+", b[j])
+			j++
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,200 @@
+package testsource
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Media types of the manifests and configs served by the registry.
+const (
+	manifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	configMediaType   = "application/vnd.oci.image.config.v1+json"
+)
+
+// Registry is a fake container registry implementing the tag listing,
+// manifest and blob endpoints of the distribution API, without
+// authentication. Its host is a loopback address, which registry clients
+// reach over plain HTTP.
+type Registry struct {
+	server *httptest.Server
+
+	mu sync.Mutex
+	// tags maps repositories to their tags, in push order.
+	tags map[string][]string
+	// revisions counts the pushes of each repository:tag, so a pushed again
+	// tag points to a new manifest.
+	revisions map[string]int
+	// blobs maps the digests of the served manifests and configs to them.
+	blobs map[string][]byte
+}
+
+// NewRegistry starts a registry holding the given tags by repository, e.g.
+// "library/nginx". Close it when done.
+func NewRegistry(tags map[string][]string) *Registry {
+	r := &Registry{
+		tags:      make(map[string][]string),
+		revisions: make(map[string]int),
+		blobs:     make(map[string][]byte),
+	}
+	for repo, ts := range tags {
+		r.Push(repo, ts...)
+	}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serve))
+	return r
+}
+
+// Close shuts the registry down.
+func (r *Registry) Close() {
+	r.server.Close()
+}
+
+// Host returns the host and port of the registry, e.g. 127.0.0.1:40123.
+func (r *Registry) Host() string {
+	return strings.TrimPrefix(r.server.URL, "http://")
+}
+
+// Image returns the name of the repository in the registry, e.g.
+// 127.0.0.1:40123/library/nginx.
+func (r *Registry) Image(repo string) string {
+	return r.Host() + "/" + repo
+}
+
+// Push adds tags to a repository. Pushing a tag it already holds points the
+// tag to a new manifest, as when a floating tag moves.
+func (r *Registry) Push(repo string, tags ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, tag := range tags {
+		key := repo + ":" + tag
+		if _, ok := r.revisions[key]; !ok {
+			r.tags[repo] = append(r.tags[repo], tag)
+		}
+		r.revisions[key]++
+		r.manifest(repo, tag)
+	}
+}
+
+// Digest returns the digest of the manifest a tag points to, or "" when the
+// repository has no such tag.
+func (r *Registry) Digest(repo, tag string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.revisions[repo+":"+tag]; !ok {
+		return ""
+	}
+	_, digest := r.manifest(repo, tag)
+	return digest
+}
+
+// manifest returns the manifest a tag points to and its digest, storing it
+// and its config as blobs. The caller holds the lock.
+func (r *Registry) manifest(repo, tag string) ([]byte, string) {
+	config, _ := json.Marshal(map[string]any{
+		"architecture": "amd64",
+		"os":           "linux",
+		"config": map[string]any{
+			"Labels": map[string]string{
+				"org.opencontainers.image.version":  tag,
+				"org.opencontainers.image.revision": strconv.Itoa(r.revisions[repo+":"+tag]),
+			},
+		},
+	})
+	configDigest := r.store(config)
+	manifest, _ := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     manifestMediaType,
+		"config": map[string]any{
+			"mediaType": configMediaType,
+			"digest":    configDigest,
+			"size":      len(config),
+		},
+		"layers": []any{},
+		"annotations": map[string]string{
+			"org.opencontainers.image.ref.name": repo + ":" + tag,
+		},
+	})
+	return manifest, r.store(manifest)
+}
+
+// store stores a blob and returns its digest. The caller holds the lock.
+func (r *Registry) store(blob []byte) string {
+	sum := sha256.Sum256(blob)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	r.blobs[digest] = blob
+	return digest
+}
+
+// serve handles the requests of the distribution API.
+func (r *Registry) serve(w http.ResponseWriter, req *http.Request) {
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if path == "" || path == req.URL.Path {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case strings.HasSuffix(path, "/tags/list"):
+		repo := strings.TrimSuffix(path, "/tags/list")
+		tags, ok := r.tags[repo]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NAME_UNKNOWN", "repository "+repo+" not found")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"name": repo, "tags": slices.Clone(tags)})
+	case strings.Contains(path, "/manifests/"):
+		i := strings.LastIndex(path, "/manifests/")
+		repo, ref := path[:i], path[i+len("/manifests/"):]
+		var body []byte
+		digest := ref
+		if strings.HasPrefix(ref, "sha256:") {
+			body = r.blobs[ref]
+		} else if _, ok := r.revisions[repo+":"+ref]; ok {
+			body, digest = r.manifest(repo, ref)
+		}
+		if body == nil {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest "+repo+":"+ref+" not found")
+			return
+		}
+		writeBlob(w, req, manifestMediaType, digest, body)
+	case strings.Contains(path, "/blobs/"):
+		i := strings.LastIndex(path, "/blobs/")
+		digest := path[i+len("/blobs/"):]
+		body, ok := r.blobs[digest]
+		if !ok {
+			writeError(w, http.StatusNotFound, "BLOB_UNKNOWN", "blob "+digest+" not found")
+			return
+		}
+		writeBlob(w, req, "application/octet-stream", digest, body)
+	default:
+		writeError(w, http.StatusNotFound, "UNSUPPORTED", "unsupported endpoint "+req.URL.Path)
+	}
+}
+
+// writeBlob writes a manifest or blob, or only its headers to a HEAD request.
+func writeBlob(w http.ResponseWriter, req *http.Request, mediaType, digest string, body []byte) {
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("Docker-Content-Digest", digest)
+	if req.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(body)
+}
+
+// writeError writes an error of the distribution API.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"errors": []map[string]string{{"code": code, "message": message}},
+	})
+}
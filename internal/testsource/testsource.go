@@ -0,0 +1,36 @@
+// Package testsource provides version sources for testing updates offline:
+// recorded tag lists of container registries, GitHub repositories, Helm
+// repositories and git remotes, and a fake container registry served over
+// HTTP on the loopback interface.
+package testsource
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/mirror"
+)
+
+//go:embed fixtures/metadata.json
+var fixtures []byte
+
+// Fixtures returns a snapshot of the recorded candidates, keyed as exported
+// by export-metadata: images by name, charts by repository URL and chart
+// name, actions by owner/repo and git remotes by URL. Each call returns a new
+// snapshot, so tests may add their own candidates to it.
+func Fixtures() *mirror.Snapshot {
+	s := mirror.NewSnapshot(time.Time{})
+	if err := json.Unmarshal(fixtures, s); err != nil {
+		panic(fmt.Sprintf("testsource: parse fixtures: %v", err))
+	}
+	return s
+}
+
+// WithFixtures returns a context serving the lookups made with it from the
+// recorded candidates instead of the network.
+func WithFixtures(ctx context.Context) context.Context {
+	return mirror.WithOffline(ctx, Fixtures())
+}
@@ -0,0 +1,160 @@
+package testsource
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/helm"
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+	"github.com/shikanime-studio/automata/internal/mirror"
+)
+
+func TestWithFixtures(t *testing.T) {
+	ctx := WithFixtures(context.Background())
+	image, err := container.FindLatestTag(ctx, &container.ImageRef{Name: "docker.io/library/nginx", Tag: "1.25.4"})
+	if err != nil {
+		t.Fatalf("image: unexpected error: %v", err)
+	}
+	if image != "1.27.0" {
+		t.Errorf("image: got %s, want 1.27.0", image)
+	}
+	chart, err := helm.FindLatestVersion(ctx, &helm.ChartRef{
+		RepoURL: "https://charts.jetstack.io",
+		Name:    "cert-manager",
+		Version: "v1.13.6",
+	})
+	if err != nil {
+		t.Fatalf("chart: unexpected error: %v", err)
+	}
+	if chart != "v1.14.5" {
+		t.Errorf("chart: got %s, want v1.14.5", chart)
+	}
+	action, err := github.NewTokenClient(ctx, "").FindLatestActionTag(
+		ctx,
+		&github.ActionRef{Owner: "actions", Repo: "checkout", Version: "v3"},
+	)
+	if err != nil {
+		t.Fatalf("action: unexpected error: %v", err)
+	}
+	if action != "v4" {
+		t.Errorf("action: got %s, want v4", action)
+	}
+}
+
+func TestFixtures_Independent(t *testing.T) {
+	Fixtures().Add(mirror.Images, "docker.io/library/nginx", nil)
+	if c, _ := Fixtures().Get(mirror.Images, "docker.io/library/nginx"); len(c) == 0 {
+		t.Fatalf("fixtures changed by a previous snapshot")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(map[string][]string{"library/nginx": {"1.25.4", "1.26.0", "latest"}})
+	defer r.Close()
+	ctx := context.Background()
+	image := &container.ImageRef{Name: r.Image("library/nginx"), Tag: "1.25.4"}
+	tags, err := container.ListTags(ctx, image)
+	if err != nil {
+		t.Fatalf("list tags: %v", err)
+	}
+	if got := strings.Join(tags, ","); got != "1.25.4,1.26.0,latest" {
+		t.Errorf("tags: got %s", got)
+	}
+	before, err := container.Digest(ctx, &container.ImageRef{Name: image.Name, Tag: "latest"})
+	if err != nil {
+		t.Fatalf("digest: %v", err)
+	}
+	if before != r.Digest("library/nginx", "latest") {
+		t.Errorf("digest: got %s, want %s", before, r.Digest("library/nginx", "latest"))
+	}
+	r.Push("library/nginx", "latest")
+	after, err := container.Digest(ctx, &container.ImageRef{Name: image.Name, Tag: "latest"})
+	if err != nil {
+		t.Fatalf("digest: %v", err)
+	}
+	if after == before {
+		t.Errorf("digest: pushed tag still points to %s", before)
+	}
+	if _, err := container.ListTags(ctx, &container.ImageRef{Name: r.Image("library/redis")}); err == nil {
+		t.Errorf("list tags: expected error for unknown repository")
+	}
+}
+
+func TestRegistry_Pipeline(t *testing.T) {
+	r := NewRegistry(map[string][]string{"acme/app": {"1.0.0", "1.1.0", "2.0.0-rc.1"}})
+	defer r.Close()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kustomization.yaml")
+	in := `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+metadata:
+  annotations:
+    automata.shikanime.studio/images: '[{"name":"app"}]'
+images:
+  - name: app
+    newName: ` + r.Image("acme/app") + `
+    newTag: 1.0.0
+`
+	if err := os.WriteFile(path, []byte(in), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := ikio.UpdateKustomization(context.Background(), container.NewUpdater(), dir)
+	if err := p.Execute(); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "newTag: 1.1.0"; !strings.Contains(string(out), want) {
+		t.Errorf("kustomization = %s, want %q", out, want)
+	}
+}
+
+func TestRunGolden(t *testing.T) {
+	dir := t.TempDir()
+	write := func(path, data string) {
+		t.Helper()
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("app/input/version.txt", "name: app\nversion: 1.0.0\n")
+	write("app/input/notes.txt", "unchanged\n")
+	write("app/golden/version.txt", "name: app\nversion: 1.1.0\n")
+	write("app/golden/stale.txt", "removed\n")
+	bump := func(_ context.Context, dir string) error {
+		return os.WriteFile(filepath.Join(dir, "version.txt"), []byte("name: app\nversion: 1.2.0\n"), 0o644)
+	}
+	mismatches, err := RunGolden(context.Background(), dir, false, bump)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, m := range mismatches {
+		got = append(got, m.String())
+	}
+	want := []string{
+		"--- app/notes.txt\nunexpected file\n",
+		"--- app/stale.txt\nmissing file\n",
+		"--- app/version.txt\n-version: 1.1.0\n+version: 1.2.0\n",
+	}
+	if strings.Join(got, "") != strings.Join(want, "") {
+		t.Fatalf("mismatches:\n%s\nwant:\n%s", strings.Join(got, ""), strings.Join(want, ""))
+	}
+	if _, err := RunGolden(context.Background(), dir, true, bump); err != nil {
+		t.Fatalf("update: unexpected error: %v", err)
+	}
+	if mismatches, err := RunGolden(context.Background(), dir, false, bump); err != nil || len(mismatches) > 0 {
+		t.Fatalf("after update: mismatches %v, err %v", mismatches, err)
+	}
+}
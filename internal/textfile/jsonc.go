@@ -0,0 +1,110 @@
+package textfile
+
+import "strings"
+
+// JSONCString is a string literal of a JSON with comments document, such as
+// a devcontainer.json.
+type JSONCString struct {
+	// Path is the keys leading to the string, ending with the string itself
+	// for object keys. Array elements are keyed by "".
+	Path []string
+	// Key reports whether the string is an object key.
+	Key bool
+	// Value is the content of the literal, escape sequences included.
+	Value string
+	// Start and End delimit the content of the literal, quotes excluded.
+	Start, End int
+}
+
+// Escaped reports whether the literal holds escape sequences, so its value
+// differs from its content.
+func (s JSONCString) Escaped() bool {
+	return strings.Contains(s.Value, `\`)
+}
+
+// jsoncFrame is an object or array of a JSONC document.
+type jsoncFrame struct {
+	object bool
+	// key is the key of the current member of an object.
+	key string
+	// expectKey is set where an object expects a key.
+	expectKey bool
+}
+
+// JSONCStrings returns the string literals of a JSON with comments document
+// in order, so they can be edited in place without reformatting the rest of
+// the document. The scan is tolerant: it skips line and block comments and
+// ignores trailing commas and malformed values.
+func JSONCStrings(content string) []JSONCString {
+	var strs []JSONCString
+	var stack []jsoncFrame
+	path := func() []string {
+		p := make([]string, len(stack))
+		for i, f := range stack {
+			p[i] = f.key
+		}
+		return p
+	}
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case strings.HasPrefix(content[i:], "//"):
+			end := strings.IndexByte(content[i:], '\n')
+			if end < 0 {
+				return strs
+			}
+			i += end + 1
+		case strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end < 0 {
+				return strs
+			}
+			i += end + 4
+		case c == '{':
+			stack = append(stack, jsoncFrame{object: true, expectKey: true})
+			i++
+		case c == '[':
+			stack = append(stack, jsoncFrame{})
+			i++
+		case c == '}' || c == ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			i++
+		case c == ':':
+			if len(stack) > 0 {
+				stack[len(stack)-1].expectKey = false
+			}
+			i++
+		case c == ',':
+			if n := len(stack); n > 0 && stack[n-1].object {
+				stack[n-1].expectKey = true
+				stack[n-1].key = ""
+			}
+			i++
+		case c == '"':
+			start := i + 1
+			end := start
+			for end < len(content) && content[end] != '"' {
+				if content[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(content) {
+				return strs
+			}
+			s := JSONCString{Value: content[start:end], Start: start, End: end}
+			if n := len(stack); n > 0 && stack[n-1].object && stack[n-1].expectKey {
+				stack[n-1].key = s.Value
+				s.Key = true
+			}
+			s.Path = path()
+			strs = append(strs, s)
+			i = end + 1
+		default:
+			i++
+		}
+	}
+	return strs
+}
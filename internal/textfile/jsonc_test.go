@@ -0,0 +1,44 @@
+package textfile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSONCStrings(t *testing.T) {
+	content := `// devcontainer
+{
+  /* "image": "commented" */
+  "image": "mcr.microsoft.com/devcontainers/go:1.22", // trailing
+  "features": {
+    "ghcr.io/devcontainers/features/node:1": {"version": "lts"},
+  },
+  "args": ["a\"b", "c"],
+}
+`
+	var got []string
+	for _, s := range JSONCStrings(content) {
+		if content[s.Start:s.End] != s.Value {
+			t.Fatalf("span of %q holds %q", s.Value, content[s.Start:s.End])
+		}
+		kind := "value"
+		if s.Key {
+			kind = "key"
+		}
+		got = append(got, kind+" "+strings.Join(s.Path, "/")+"="+s.Value)
+	}
+	want := []string{
+		"key image=image",
+		"value image=mcr.microsoft.com/devcontainers/go:1.22",
+		"key features=features",
+		"key features/ghcr.io/devcontainers/features/node:1=ghcr.io/devcontainers/features/node:1",
+		"key features/ghcr.io/devcontainers/features/node:1/version=version",
+		"value features/ghcr.io/devcontainers/features/node:1/version=lts",
+		"key args=args",
+		`value args/=a\"b`,
+		"value args/=c",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
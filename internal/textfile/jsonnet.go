@@ -0,0 +1,18 @@
+package textfile
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// JsonnetFiles are the default globs of jsonnet sources.
+var JsonnetFiles = []string{"*.jsonnet", "*.libsonnet"}
+
+// JsonnetConstantRegex returns the expression matching the string value of a
+// local jsonnet constant, e.g. local version = '1.2.3';.
+func JsonnetConstantRegex(name string) string {
+	return fmt.Sprintf(
+		`\blocal\s+%s\s*=\s*['"](?P<version>[^'"]+)['"]`,
+		regexp.QuoteMeta(name),
+	)
+}
@@ -0,0 +1,47 @@
+package textfile
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MarkdownFiles are the default globs of README-like files.
+var MarkdownFiles = []string{"*.md", "*.markdown"}
+
+var markdownImage = regexp.MustCompile(`!\[[^\]]*\]\([^)\s]+[^)]*\)`)
+
+// MarkdownRegions returns the spans of fenced code blocks and images, such as
+// badges, in a Markdown document. Versions are only rewritten within them so
+// prose mentioning older releases is preserved.
+func MarkdownRegions(content string) [][2]int {
+	var regions [][2]int
+	fence := ""
+	start := 0
+	offset := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence == "" && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")):
+			fence = trimmed[:3]
+			start = offset + len(line)
+		case fence != "" && strings.HasPrefix(trimmed, fence):
+			regions = append(regions, [2]int{start, offset})
+			fence = ""
+		case fence == "":
+			for _, m := range markdownImage.FindAllStringIndex(line, -1) {
+				regions = append(regions, [2]int{offset + m[0], offset + m[1]})
+			}
+		}
+		offset += len(line)
+	}
+	if fence != "" {
+		regions = append(regions, [2]int{start, len(content)})
+	}
+	return regions
+}
+
+// ImageVersionRegex returns the expression matching the tag of the given
+// image in text.
+func ImageVersionRegex(image string) string {
+	return regexp.QuoteMeta(image) + `:(?P<version>\w[\w.+-]*)`
+}
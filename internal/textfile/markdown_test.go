@@ -0,0 +1,31 @@
+package textfile
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRuleReplace_MarkdownRegions(t *testing.T) {
+	r, err := NewRule(MarkdownFiles, ImageVersionRegex("ghcr.io/org/app"), fakeResolver{
+		"1.2.3": "1.3.0",
+	})
+	if err != nil {
+		t.Fatalf("new rule: %v", err)
+	}
+	r.Regions = MarkdownRegions
+	content := "# App\n\n" +
+		"![version](https://img.shields.io/static/v1?label=image&message=ghcr.io/org/app:1.2.3)\n\n" +
+		"Upgrading from ghcr.io/org/app:1.2.3 requires a migration.\n\n" +
+		"```bash\ndocker run ghcr.io/org/app:1.2.3\n```\n"
+	got, err := r.Replace(context.Background(), content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "# App\n\n" +
+		"![version](https://img.shields.io/static/v1?label=image&message=ghcr.io/org/app:1.3.0)\n\n" +
+		"Upgrading from ghcr.io/org/app:1.2.3 requires a migration.\n\n" +
+		"```bash\ndocker run ghcr.io/org/app:1.3.0\n```\n"
+	if got != want {
+		t.Fatalf("unexpected content:\n%s", got)
+	}
+}
@@ -0,0 +1,270 @@
+package textfile
+
+import (
+	"strings"
+)
+
+// NixFiles are the default globs of Nix expressions.
+var NixFiles = []string{"*.nix"}
+
+// NixVersionRegex matches a whole string value found by NixAttributeRegions.
+const NixVersionRegex = `(?P<version>[^"\s]+)`
+
+// NixAttr is an attribute assignment of a Nix expression.
+type NixAttr struct {
+	// Key is the attribute path as written in its attribute set, e.g.
+	// package.version.
+	Key string
+	// Path is the full attribute path of the value, from the root attribute
+	// set, or "" where it is unknown, e.g. in let blocks and function
+	// arguments.
+	Path string
+	// Call is the name of the function the attribute set of the assignment
+	// is applied to, e.g. fetchFromGitHub, without the attribute path
+	// selecting it.
+	Call string
+	// Set identifies the attribute set of the assignment within the
+	// expression.
+	Set int
+	// String reports whether the value is a double-quoted string, and Plain
+	// whether it interpolates nothing.
+	String, Plain bool
+	// Value is the content of a string value, escape sequences included.
+	Value string
+	// Start and End delimit the content of a string value, quotes
+	// excluded, or the position following the equal sign otherwise.
+	Start, End int
+}
+
+// nixFrame is a nesting level of a Nix expression: an attribute set, list,
+// parenthesized expression or let block.
+type nixFrame struct {
+	// id identifies the frame among those of the expression.
+	id int
+	// prefix is the attribute path of an attribute set assigned directly to
+	// an attribute.
+	prefix string
+	// paths reports whether the attributes assigned in the frame have a
+	// known path; false in let blocks, lists and expressions.
+	paths bool
+	// let marks a let block, closed by in.
+	let bool
+	// call is the function an attribute set is applied to.
+	call string
+}
+
+// NixAttributeRegions returns a function locating the string values assigned
+// to the dot-separated attribute path in a Nix expression, e.g.
+// services.foo.package.version, whether assigned as a whole or through nested
+// attribute sets. Assignments under config, as in full NixOS modules, match
+// too.
+func NixAttributeRegions(path string) func(content string) [][2]int {
+	return func(content string) [][2]int {
+		var regions [][2]int
+		for _, a := range NixAttrs(content) {
+			if a.String && a.Plain && a.Path != "" && (a.Path == path || a.Path == "config."+path) {
+				regions = append(regions, [2]int{a.Start, a.End})
+			}
+		}
+		return regions
+	}
+}
+
+// NixAttrs returns the attribute assignments of a Nix expression in order, so
+// their string values can be edited in place. The scan is tolerant: it skips
+// strings and comments, and only knows the full path of the attributes of
+// attribute sets directly assigned to an attribute, not of function
+// arguments, let blocks and lists.
+func NixAttrs(content string) []NixAttr {
+	var attrs []NixAttr
+	stack := []nixFrame{{paths: true}}
+	frames := 1
+	push := func(f nixFrame) {
+		f.id = frames
+		frames++
+		stack = append(stack, f)
+	}
+	var words []string
+	value := false
+	// word is the identifier preceding the current token, naming the
+	// function an attribute set is applied to.
+	word := ""
+	// body is set where an expression starts a file, lambda or let body,
+	// so an attribute set found there is a root one, e.g. a module.
+	body := true
+	top := func() nixFrame { return stack[len(stack)-1] }
+	pop := func() {
+		if len(stack) > 1 {
+			stack = stack[:len(stack)-1]
+		}
+	}
+	for i := 0; i < len(content); {
+		c := content[i]
+		start, prev := body, word
+		body, word = false, ""
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			body, word = start, prev
+			i++
+		case c == '#':
+			body, word = start, prev
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(content[i:], "/*"):
+			body, word = start, prev
+			if end := strings.Index(content[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(content)
+			}
+		case c == '"':
+			end, plain := nixStringEnd(content, i+1)
+			if value && len(attrs) > 0 {
+				a := &attrs[len(attrs)-1]
+				a.String, a.Plain, a.Value = true, plain, content[i+1:end]
+				a.Start, a.End = i+1, end
+			}
+			if !value {
+				words = append(words, content[i+1:end])
+			}
+			value = false
+			i = end + 1
+		case strings.HasPrefix(content[i:], "''"):
+			i = nixIndentedStringEnd(content, i+2)
+			value = false
+		case c == '{':
+			switch {
+			case value && top().paths:
+				push(nixFrame{prefix: attrs[len(attrs)-1].Path, paths: true})
+			case start:
+				push(nixFrame{paths: true})
+			default:
+				push(nixFrame{call: prev})
+			}
+			words, value = nil, false
+			i++
+		case c == '[' || c == '(':
+			push(nixFrame{})
+			words, value = nil, false
+			i++
+		case c == '}' || c == ']' || c == ')':
+			pop()
+			words, value = nil, false
+			i++
+		case c == '=' && !strings.HasPrefix(content[i:], "=="):
+			if len(words) > 0 {
+				f := top()
+				a := NixAttr{Key: strings.Join(words, "."), Call: f.call, Set: f.id, Start: i + 1, End: i + 1}
+				if f.paths {
+					a.Path = a.Key
+					if f.prefix != "" {
+						a.Path = f.prefix + "." + a.Key
+					}
+				}
+				attrs = append(attrs, a)
+				value = true
+			}
+			words = nil
+			i++
+		case c == ';' || c == ',':
+			words, value = nil, false
+			i++
+		case c == ':':
+			words, value, body = nil, false, true
+			i++
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(content) && (content[j] == '_' || content[j] == '-' || content[j] == '\'' ||
+				content[j] >= 'a' && content[j] <= 'z' || content[j] >= 'A' && content[j] <= 'Z' ||
+				content[j] >= '0' && content[j] <= '9') {
+				j++
+			}
+			switch w := content[i:j]; {
+			case w == "let":
+				push(nixFrame{let: true})
+				words, value = nil, false
+			case w == "in" && top().let:
+				pop()
+				words, value, body = nil, false, true
+			case w == "rec":
+				word = prev
+			case value:
+				value, word = false, w
+			default:
+				words, word = append(words, w), w
+			}
+			i = j
+		case c == '.':
+			i++
+		default:
+			value = false
+			i++
+		}
+	}
+	return attrs
+}
+
+// nixStringEnd returns the index of the quote closing the string starting
+// at i, and whether the string interpolates nothing.
+func nixStringEnd(content string, i int) (int, bool) {
+	plain := true
+	for i < len(content) {
+		switch {
+		case content[i] == '\\':
+			i += 2
+		case content[i] == '"':
+			return i, plain
+		case strings.HasPrefix(content[i:], "${"):
+			plain = false
+			i = nixInterpolationEnd(content, i+2)
+		default:
+			i++
+		}
+	}
+	return len(content), false
+}
+
+// nixIndentedStringEnd returns the index following the ” closing the
+// indented string starting at i.
+func nixIndentedStringEnd(content string, i int) int {
+	for i < len(content) {
+		switch {
+		case strings.HasPrefix(content[i:], "'''"), strings.HasPrefix(content[i:], "''$"),
+			strings.HasPrefix(content[i:], "''\\"):
+			i += 3
+		case strings.HasPrefix(content[i:], "''"):
+			return i + 2
+		case strings.HasPrefix(content[i:], "${"):
+			i = nixInterpolationEnd(content, i+2)
+		default:
+			i++
+		}
+	}
+	return len(content)
+}
+
+// nixInterpolationEnd returns the index following the brace closing the
+// interpolation starting at i, skipping the strings it holds.
+func nixInterpolationEnd(content string, i int) int {
+	depth := 1
+	for i < len(content) {
+		switch c := content[i]; {
+		case c == '"':
+			end, _ := nixStringEnd(content, i+1)
+			i = end + 1
+		case c == '{':
+			depth++
+			i++
+		case c == '}':
+			depth--
+			i++
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return len(content)
+}
@@ -0,0 +1,57 @@
+package textfile
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRuleReplace_NixAttribute(t *testing.T) {
+	r, err := NewRule(NixFiles, NixVersionRegex, fakeResolver{"1.2.3": "1.3.0", "2.0.0": "2.1.0"})
+	if err != nil {
+		t.Fatalf("new rule: %v", err)
+	}
+	r.Regions = NixAttributeRegions("services.foo.package.version")
+	content := `{ config, pkgs, ... }:
+let
+  version = "1.2.3"; # not an option
+in
+{
+  services.foo = {
+    enable = true;
+    package.version = "1.2.3";
+    settings = { version = "1.2.3"; };
+  };
+  services.bar.package.version = "1.2.3";
+  /* services.foo.package.version = "1.2.3"; */
+  config.services.foo.package = rec { version = "2.0.0"; url = "https://example.com/${version}"; };
+  environment.etc."foo.conf".text = ''
+    version = "1.2.3"
+  '';
+}
+`
+	got, err := r.Replace(context.Background(), content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{ config, pkgs, ... }:
+let
+  version = "1.2.3"; # not an option
+in
+{
+  services.foo = {
+    enable = true;
+    package.version = "1.3.0";
+    settings = { version = "1.2.3"; };
+  };
+  services.bar.package.version = "1.2.3";
+  /* services.foo.package.version = "1.2.3"; */
+  config.services.foo.package = rec { version = "2.1.0"; url = "https://example.com/${version}"; };
+  environment.etc."foo.conf".text = ''
+    version = "1.2.3"
+  '';
+}
+`
+	if got != want {
+		t.Fatalf("unexpected content:\n%s", got)
+	}
+}
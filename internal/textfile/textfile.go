@@ -0,0 +1,190 @@
+// Package textfile rewrites versions embedded in arbitrary text files through
+// regular expressions.
+package textfile
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/fsutil"
+	"github.com/shikanime-studio/automata/internal/policy"
+)
+
+// Resolver resolves the latest version for a current one.
+type Resolver interface {
+	Resolve(ctx context.Context, current string) (string, error)
+}
+
+// Rule rewrites the "version" group of every regex match in matching files.
+type Rule struct {
+	// Files are globs matched against the relative path or base name.
+	Files []string
+	// Regex locates versions through its "version" named group.
+	Regex *regexp.Regexp
+	// Resolver resolves the latest version.
+	Resolver Resolver
+	// Regions restricts matches to the returned spans of the content. The
+	// whole content is considered when nil.
+	Regions func(content string) [][2]int
+}
+
+// NewRule validates and builds a Rule.
+func NewRule(files []string, expr string, r Resolver) (Rule, error) {
+	if len(files) == 0 {
+		return Rule{}, fmt.Errorf("rule requires files")
+	}
+	for _, f := range files {
+		if _, err := filepath.Match(f, ""); err != nil {
+			return Rule{}, fmt.Errorf("invalid files glob %q: %w", f, err)
+		}
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid regex %q: %w", expr, err)
+	}
+	if re.SubexpIndex("version") < 0 {
+		return Rule{}, fmt.Errorf("regex %q has no version group", expr)
+	}
+	return Rule{Files: files, Regex: re, Resolver: r}, nil
+}
+
+// MatchesFile reports whether the rule applies to the given relative path.
+func (r Rule) MatchesFile(path string) bool {
+	for _, f := range r.Files {
+		if ok, _ := filepath.Match(f, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(f, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Replace rewrites the versions matched by the rule in content. Each distinct
+// version is resolved once.
+func (r Rule) Replace(ctx context.Context, content string) (string, error) {
+	idx := r.Regex.SubexpIndex("version")
+	var regions [][2]int
+	if r.Regions != nil {
+		regions = r.Regions(content)
+	}
+	resolved := map[string]string{}
+	var b strings.Builder
+	last := 0
+	for _, m := range r.Regex.FindAllStringSubmatchIndex(content, -1) {
+		start, end := m[2*idx], m[2*idx+1]
+		if start < 0 || (r.Regions != nil && !within(regions, start, end)) {
+			continue
+		}
+		current := content[start:end]
+		latest, ok := resolved[current]
+		if !ok {
+			var err error
+			line, column := Position(content, start)
+			latest, err = r.Resolver.Resolve(policy.WithPosition(ctx, line, column), current)
+			if err != nil {
+				return "", fmt.Errorf("resolve %s: %w", current, err)
+			}
+			resolved[current] = latest
+		}
+		if latest == "" || latest == current {
+			continue
+		}
+		b.WriteString(content[last:start])
+		b.WriteString(latest)
+		last = end
+		slog.InfoContext(ctx, "updated text version", "from", current, "to", latest)
+	}
+	b.WriteString(content[last:])
+	return b.String(), nil
+}
+
+// Position returns the 1-based line and column of the byte at offset in
+// content.
+func Position(content string, offset int) (int, int) {
+	start := strings.LastIndexByte(content[:offset], '\n') + 1
+	return strings.Count(content[:offset], "\n") + 1, offset - start + 1
+}
+
+func within(regions [][2]int, start, end int) bool {
+
+	for _, r := range regions {
+		if start >= r[0] && end <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// Update walks root and applies the matching rules to each file, skipping
+// hidden directories and git-ignored files.
+func Update(ctx context.Context, rules []Rule, root string) error {
+	var g errgroup.Group
+	g.SetLimit(fsutil.Workers)
+	handler := func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		var fileRules []Rule
+		for _, r := range rules {
+			if r.MatchesFile(rel) {
+				fileRules = append(fileRules, r)
+			}
+		}
+		if len(fileRules) == 0 {
+			return nil
+		}
+		g.Go(func() error { return UpdateFile(ctx, fileRules, path) })
+		return nil
+	}
+	handler = fsutil.SkipHidden(root, handler)
+	handler = fsutil.SkipIgnored(root, handler)
+	if err := fsutil.Walk(root, handler); err != nil {
+		return fmt.Errorf("scan for text files: %w", err)
+	}
+	return g.Wait()
+}
+
+// UpdateFile applies the rules to the file at path, writing it back only when
+// its content changed.
+func UpdateFile(ctx context.Context, rules []Rule, path string) error {
+	ctx = policy.WithFile(ctx, path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	content := string(data)
+	for _, r := range rules {
+		content, err = r.Replace(ctx, content)
+		if err != nil {
+			return fmt.Errorf("update %s: %w", path, err)
+		}
+	}
+	if content == string(data) {
+		return nil
+	}
+	if err := fsutil.WriteFile(path, []byte(content), info.Mode().Perm(), fsutil.Backups(ctx)); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package textfile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeResolver map[string]string
+
+func (f fakeResolver) Resolve(_ context.Context, current string) (string, error) {
+	return f[current], nil
+}
+
+func TestRuleReplace_JsonnetConstant(t *testing.T) {
+	r, err := NewRule(
+		JsonnetFiles,
+		JsonnetConstantRegex("version"),
+		fakeResolver{"1.2.3": "1.3.0"},
+	)
+	if err != nil {
+		t.Fatalf("new rule: %v", err)
+	}
+	content := `local version = '1.2.3';
+local other_version = "1.2.3";
+{ image: 'app:' + version }
+`
+	got, err := r.Replace(context.Background(), content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `local version = '1.3.0';
+local other_version = "1.2.3";
+{ image: 'app:' + version }
+`
+	if got != want {
+		t.Fatalf("unexpected content:\n%s", got)
+	}
+}
+
+func TestRuleMatchesFile(t *testing.T) {
+	r := Rule{Files: JsonnetFiles}
+	if !r.MatchesFile("environments/prod/main.jsonnet") {
+		t.Fatalf("expected jsonnet match")
+	}
+	if r.MatchesFile("environments/prod/spec.json") {
+		t.Fatalf("unexpected json match")
+	}
+}
+
+func TestUpdateFile_Makefile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Makefile")
+	content := "VERSION=1.2.3\nTOOL_VERSION ?= 0.4.0\n\nbuild:\n\tgo build -ldflags \"-X main.v=$(VERSION)\"\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	r, err := NewRule(
+		[]string{"Makefile"},
+		`(?m)^\w*VERSION\s*\??=\s*(?P<version>\S+)$`,
+		fakeResolver{"1.2.3": "1.3.0", "0.4.0": "0.5.1"},
+	)
+	if err != nil {
+		t.Fatalf("new rule: %v", err)
+	}
+	if err := UpdateFile(context.Background(), []Rule{r}, path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	want := "VERSION=1.3.0\nTOOL_VERSION ?= 0.5.1\n\nbuild:\n\tgo build -ldflags \"-X main.v=$(VERSION)\"\n"
+	if string(data) != want {
+		t.Fatalf("unexpected content:\n%s", data)
+	}
+}
+
+func TestNewRule_Invalid(t *testing.T) {
+	if _, err := NewRule([]string{"Makefile"}, `VERSION=(\S+)`, fakeResolver{}); err == nil {
+		t.Fatalf("expected error for regex without version group")
+	}
+	if _, err := NewRule(nil, `(?P<version>\S+)`, fakeResolver{}); err == nil {
+		t.Fatalf("expected error for rule without files")
+	}
+}
@@ -0,0 +1,109 @@
+// Package timeout bounds the duration of external calls, so a single hung
+// registry or command cannot stall a whole run.
+package timeout
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/config"
+)
+
+// Operation is a kind of external call.
+type Operation string
+
+// Operations with a timeout.
+const (
+	// Registry lists tags and fetches manifests from container registries.
+	Registry Operation = "registry"
+	// GitHub calls the GitHub API.
+	GitHub Operation = "github"
+	// Helm fetches and searches Helm repository indexes.
+	Helm Operation = "helm"
+	// Git lists the tags of remote git repositories.
+	Git Operation = "git"
+	// Nix runs nix flake update.
+	Nix Operation = "nix"
+	// Script runs update.sh scripts, including the tools they call such as
+	// sops.
+	Script Operation = "script"
+	// Plugin runs source plugins listing versions.
+	Plugin Operation = "plugin"
+	// Kubernetes calls the Kubernetes API server.
+	Kubernetes Operation = "kubernetes"
+	// Secret fetches credentials from external secret managers.
+	Secret Operation = "secret"
+)
+
+// Timeouts maps operations to the duration they are bounded to; zero
+// disables the timeout.
+type Timeouts map[Operation]time.Duration
+
+// Defaults are the timeouts of the operations the config leaves unset.
+var Defaults = Timeouts{
+	Registry:   time.Minute,
+	GitHub:     30 * time.Second,
+	Helm:       2 * time.Minute,
+	Git:        time.Minute,
+	Nix:        10 * time.Minute,
+	Script:     30 * time.Minute,
+	Plugin:     time.Minute,
+	Kubernetes: 30 * time.Second,
+	Secret:     30 * time.Second,
+}
+
+// New builds timeouts from their config declaration and flag overrides,
+// given as operation to duration strings, on top of the defaults.
+func New(c config.Timeouts, overrides map[string]string) (Timeouts, error) {
+	t := Timeouts{}
+	for op, d := range Defaults {
+		t[op] = d
+	}
+	for op, d := range map[Operation]time.Duration{
+		Registry:   c.Registry,
+		GitHub:     c.GitHub,
+		Helm:       c.Helm,
+		Git:        c.Git,
+		Nix:        c.Nix,
+		Script:     c.Script,
+		Plugin:     c.Plugin,
+		Kubernetes: c.Kubernetes,
+		Secret:     c.Secret,
+	} {
+		if d != 0 {
+			t[op] = d
+		}
+	}
+	for op, s := range overrides {
+		if _, ok := Defaults[Operation(op)]; !ok {
+			return nil, fmt.Errorf("unknown timeout operation %q", op)
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("timeout %s: %w", op, err)
+		}
+		t[Operation(op)] = d
+	}
+	return t, nil
+}
+
+type timeoutsKey struct{}
+
+// WithTimeouts returns a context bounding the calls made with it.
+func WithTimeouts(ctx context.Context, t Timeouts) context.Context {
+	return context.WithValue(ctx, timeoutsKey{}, t)
+}
+
+// Context derives the context of one call of the operation, canceled after
+// its timeout from the context, or the default one.
+func Context(ctx context.Context, op Operation) (context.Context, context.CancelFunc) {
+	t, ok := ctx.Value(timeoutsKey{}).(Timeouts)
+	if !ok {
+		t = Defaults
+	}
+	if d := t[op]; d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return context.WithCancel(ctx)
+}
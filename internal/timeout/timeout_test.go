@@ -0,0 +1,42 @@
+package timeout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shikanime-studio/automata/internal/config"
+)
+
+func TestNewLayersConfigAndOverrides(t *testing.T) {
+	got, err := New(config.Timeouts{Registry: 10 * time.Second}, map[string]string{"nix": "0s", "github": "5s"})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if got[Registry] != 10*time.Second || got[GitHub] != 5*time.Second || got[Nix] != 0 ||
+		got[Helm] != Defaults[Helm] {
+		t.Fatalf("unexpected timeouts %v", got)
+	}
+	if _, err := New(config.Timeouts{}, map[string]string{"sops": "1s"}); err == nil {
+		t.Fatalf("expected an unknown operation error")
+	}
+	if _, err := New(config.Timeouts{}, map[string]string{"git": "soon"}); err == nil {
+		t.Fatalf("expected an invalid duration error")
+	}
+}
+
+func TestContextBoundsCalls(t *testing.T) {
+	ctx := WithTimeouts(context.Background(), Timeouts{Registry: time.Millisecond})
+	call, cancel := Context(ctx, Registry)
+	defer cancel()
+	select {
+	case <-call.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("registry call was not canceled")
+	}
+	call, cancel = Context(ctx, Nix)
+	defer cancel()
+	if _, ok := call.Deadline(); ok {
+		t.Fatalf("expected no deadline for unset operations")
+	}
+}
@@ -0,0 +1,301 @@
+// Package toolversion updates tool version pins in asdf .tool-versions and
+// mise configuration files.
+package toolversion
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/fsutil"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/policy"
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+// Tool locates the upstream releases of a tool on GitHub.
+type Tool struct {
+	Owner string
+	Repo  string
+	// Transform extracts the version from upstream tags through its "version"
+	// named group. It must also match bare pinned versions.
+	Transform *regexp.Regexp
+	// Excludes are the versions, or their tags, never updated to.
+	Excludes []string
+	// Prereleases allows updates to prerelease tags.
+	Prereleases bool
+	// Strategy bounds the versions the pins are updated to.
+	Strategy updater.Strategy
+}
+
+var (
+	semverTag    = regexp.MustCompile(`^v?(?P<version>\d+(\.\d+){0,2})$`)
+	goTag        = regexp.MustCompile(`^(go)?(?P<version>\d+(\.\d+){0,2})$`)
+	kustomizeTag = regexp.MustCompile(`^(kustomize/)?v?(?P<version>\d+(\.\d+){0,2})$`)
+)
+
+// DefaultTools maps asdf plugin and mise tool names to their upstreams.
+var DefaultTools = map[string]Tool{
+	"golang":    {Owner: "golang", Repo: "go", Transform: goTag},
+	"go":        {Owner: "golang", Repo: "go", Transform: goTag},
+	"nodejs":    {Owner: "nodejs", Repo: "node", Transform: semverTag},
+	"node":      {Owner: "nodejs", Repo: "node", Transform: semverTag},
+	"python":    {Owner: "python", Repo: "cpython", Transform: semverTag},
+	"terraform": {Owner: "hashicorp", Repo: "terraform", Transform: semverTag},
+	"helm":      {Owner: "helm", Repo: "helm", Transform: semverTag},
+	"kubectl":   {Owner: "kubernetes", Repo: "kubernetes", Transform: semverTag},
+	"rust":      {Owner: "rust-lang", Repo: "rust", Transform: semverTag},
+	"deno":      {Owner: "denoland", Repo: "deno", Transform: semverTag},
+	"kustomize": {Owner: "kubernetes-sigs", Repo: "kustomize", Transform: kustomizeTag},
+}
+
+// NewTools returns the known tools, configured or added by the tool
+// declarations of the config file.
+func NewTools(decls []config.ToolVersion) (map[string]Tool, error) {
+	tools := maps.Clone(DefaultTools)
+	for _, d := range decls {
+		tool, known := tools[d.Name]
+		if d.Owner != "" {
+			tool.Owner = d.Owner
+		}
+		if d.Repo != "" {
+			tool.Repo = d.Repo
+		}
+		if tool.Owner == "" || tool.Repo == "" {
+			return nil, fmt.Errorf("tool %s: owner and repo are required for unknown tools", d.Name)
+		}
+		if d.TagRegex != "" {
+			re, err := regexp.Compile(d.TagRegex)
+			if err != nil {
+				return nil, fmt.Errorf("tool %s: invalid tag-regex %q: %w", d.Name, d.TagRegex, err)
+			}
+			if re.SubexpIndex("version") < 0 {
+				return nil, fmt.Errorf("tool %s: tag-regex %q has no version group", d.Name, d.TagRegex)
+			}
+			tool.Transform = re
+		} else if !known {
+			tool.Transform = semverTag
+		}
+		strategy, err := updater.ParseStrategy(d.UpdateStrategy)
+		if err != nil {
+			return nil, fmt.Errorf("tool %s: %w", d.Name, err)
+		}
+		tool.Excludes, tool.Prereleases, tool.Strategy = d.ExcludeTags, d.IncludePrereleases, strategy
+		tools[d.Name] = tool
+	}
+	return tools, nil
+}
+
+// LookupTools maps each key to the tool of tools with the given name.
+func LookupTools(names map[string]string, tools map[string]Tool) (map[string]Tool, error) {
+	found := make(map[string]Tool, len(names))
+	for k, name := range names {
+		tool, ok := tools[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown tool %q for %s", name, k)
+		}
+		found[k] = tool
+	}
+	return found, nil
+}
+
+// UpdateOptions returns the comparison options the tool selects tags with.
+// Excluded versions are dropped so the next greatest one is selected
+// instead.
+func (t Tool) UpdateOptions() []updater.Option {
+	options := []updater.Option{
+		updater.WithTransform(t.Transform),
+		updater.WithStrategy(t.Strategy),
+		updater.WithPrereleases(t.Prereleases),
+	}
+	if len(t.Excludes) > 0 {
+		options = append(options, updater.WithCandidateCheck(func(_ context.Context, candidate string) (bool, error) {
+			return !slices.Contains(t.Excludes, candidate) && !slices.Contains(t.Excludes, t.version(candidate)), nil
+		}))
+	}
+	return options
+}
+
+// version returns the version a tag holds, or "" when it holds none.
+func (t Tool) version(tag string) string {
+	m := t.Transform.FindStringSubmatch(tag)
+	if m == nil {
+		return ""
+	}
+	return m[t.Transform.SubexpIndex("version")]
+}
+
+// Resolve returns the latest upstream version for the pinned version, or an
+// empty string when the pin cannot be resolved.
+func (t Tool) Resolve(
+	ctx context.Context,
+	u updater.Updater[*github.ActionRef],
+	current string,
+) (string, error) {
+	if !t.Transform.MatchString(current) {
+		return "", nil
+	}
+	tag, err := u.Update(
+		ctx,
+		&github.ActionRef{Owner: t.Owner, Repo: t.Repo, Version: current},
+		t.UpdateOptions()...,
+	)
+	if err != nil {
+		return "", err
+	}
+	return t.version(tag), nil
+}
+
+// UpdateToolVersions rewrites the first version of each known tool in the
+// content of a .tool-versions file.
+func UpdateToolVersions(
+	ctx context.Context,
+	u updater.Updater[*github.ActionRef],
+	tools map[string]Tool,
+	content string,
+) (string, error) {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		tool, ok := tools[fields[0]]
+		if !ok {
+			continue
+		}
+		current := fields[1]
+		latest, err := tool.Resolve(policy.WithPosition(ctx, i+1, strings.Index(line, current)+1), u, current)
+		if err != nil {
+			return "", fmt.Errorf("resolve %s: %w", fields[0], err)
+		}
+		if latest == "" || latest == current {
+			continue
+		}
+		lines[i] = strings.Replace(line, current, latest, 1)
+		slog.InfoContext(ctx, "updated tool version", "tool", fields[0], "from", current, "to", latest)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+var miseToolLine = regexp.MustCompile(`^(\s*"?([\w.-]+)"?\s*=\s*")([^"]+)(".*)$`)
+
+// UpdateMiseConfig rewrites the string versions of known tools in the [tools]
+// table of a mise configuration file.
+func UpdateMiseConfig(
+	ctx context.Context,
+	u updater.Updater[*github.ActionRef],
+	tools map[string]Tool,
+	content string,
+) (string, error) {
+	lines := strings.Split(content, "\n")
+	inTools := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			inTools = trimmed == "[tools]"
+			continue
+		}
+		if !inTools {
+			continue
+		}
+		m := miseToolLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		tool, ok := tools[m[2]]
+		if !ok {
+			continue
+		}
+		current := m[3]
+		latest, err := tool.Resolve(policy.WithPosition(ctx, i+1, len(m[1])+1), u, current)
+		if err != nil {
+			return "", fmt.Errorf("resolve %s: %w", m[2], err)
+		}
+		if latest == "" || latest == current {
+			continue
+		}
+		lines[i] = m[1] + latest + m[4]
+		slog.InfoContext(ctx, "updated tool version", "tool", m[2], "from", current, "to", latest)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Update walks root and updates every .tool-versions and mise configuration
+// file found, skipping hidden directories and git-ignored files.
+func Update(
+	ctx context.Context,
+	u updater.Updater[*github.ActionRef],
+	tools map[string]Tool,
+	root string,
+) error {
+	var g errgroup.Group
+	g.SetLimit(fsutil.Workers)
+	handler := func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch filepath.Base(path) {
+		case ".tool-versions":
+			g.Go(func() error { return updateFile(ctx, path, u, tools, UpdateToolVersions) })
+		case ".mise.toml", "mise.toml":
+			g.Go(func() error { return updateFile(ctx, path, u, tools, UpdateMiseConfig) })
+		}
+		return nil
+	}
+	handler = fsutil.SkipHidden(root, handler)
+	handler = fsutil.SkipIgnored(root, handler)
+	if err := fsutil.Walk(root, handler); err != nil {
+		return fmt.Errorf("scan for tool versions: %w", err)
+	}
+	return g.Wait()
+}
+
+type rewriteFunc func(
+	context.Context,
+	updater.Updater[*github.ActionRef],
+	map[string]Tool,
+	string,
+) (string, error)
+
+func updateFile(
+	ctx context.Context,
+	path string,
+	u updater.Updater[*github.ActionRef],
+	tools map[string]Tool,
+	rewrite rewriteFunc,
+) error {
+	ctx = policy.WithFile(ctx, path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	updated, err := rewrite(ctx, u, tools, string(data))
+	if err != nil {
+		return fmt.Errorf("update %s: %w", path, err)
+	}
+	if updated == string(data) {
+		return nil
+	}
+	if err := fsutil.WriteFile(path, []byte(updated), info.Mode().Perm(), fsutil.Backups(ctx)); err != nil {
+		return err
+	}
+	return nil
+}
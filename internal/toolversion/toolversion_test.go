@@ -0,0 +1,113 @@
+package toolversion
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shikanime-studio/automata/internal/config"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+type fakeActionUpdater struct {
+	latest map[string]string
+}
+
+func (f fakeActionUpdater) Update(
+	_ context.Context,
+	a *github.ActionRef,
+	_ ...updater.Option,
+) (string, error) {
+	return f.latest[a.Owner+"/"+a.Repo], nil
+}
+
+func TestUpdateToolVersions(t *testing.T) {
+	u := fakeActionUpdater{latest: map[string]string{
+		"golang/go":   "go1.22.1",
+		"nodejs/node": "v20.11.1",
+	}}
+	content := "# pins\ngolang 1.21.3\nnodejs 18.17.0 16.0.0\nunknown 1.0.0\n"
+	got, err := UpdateToolVersions(context.Background(), u, DefaultTools, content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "# pins\ngolang 1.22.1\nnodejs 20.11.1 16.0.0\nunknown 1.0.0\n"
+	if got != want {
+		t.Fatalf("unexpected content:\n%s", got)
+	}
+}
+
+func TestUpdateMiseConfig(t *testing.T) {
+	u := fakeActionUpdater{latest: map[string]string{
+		"golang/go":   "go1.22.1",
+		"nodejs/node": "v20.11.1",
+	}}
+	content := "[env]\nnode = \"18.0.0\"\n\n[tools]\ngo = \"1.21.3\"\nnode = \"latest\"\n"
+	got, err := UpdateMiseConfig(context.Background(), u, DefaultTools, content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "[env]\nnode = \"18.0.0\"\n\n[tools]\ngo = \"1.22.1\"\nnode = \"latest\"\n"
+	if got != want {
+		t.Fatalf("unexpected content:\n%s", got)
+	}
+}
+
+type fakeTagUpdater struct {
+	tags map[string][]string
+}
+
+func (f fakeTagUpdater) Update(
+	ctx context.Context,
+	a *github.ActionRef,
+	opts ...updater.Option,
+) (string, error) {
+	return updater.SelectLatest(
+		ctx,
+		a.Version,
+		f.tags[a.Owner+"/"+a.Repo],
+		updater.WithCompareOptions(opts...),
+	)
+}
+
+func TestNewTools(t *testing.T) {
+	tools, err := NewTools([]config.ToolVersion{
+		{Name: "golang", UpdateStrategy: "PatchUpdate", ExcludeTags: []string{"1.21.5"}},
+		{Name: "just", Owner: "casey", Repo: "just"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	u := fakeTagUpdater{tags: map[string][]string{
+		"golang/go":  {"go1.21.4", "go1.21.5", "go1.22.0"},
+		"casey/just": {"1.25.0", "1.26.0"},
+	}}
+	content := "golang 1.21.3\njust 1.25.0\n"
+	got, err := UpdateToolVersions(context.Background(), u, tools, content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "golang 1.21.4\njust 1.26.0\n"
+	if got != want {
+		t.Fatalf("unexpected content:\n%s", got)
+	}
+}
+
+func TestNewToolsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		decl config.ToolVersion
+	}{
+		{name: "unknown tool", decl: config.ToolVersion{Name: "just"}},
+		{name: "no version group", decl: config.ToolVersion{Name: "go", TagRegex: `^go(.+)$`}},
+		{name: "invalid regex", decl: config.ToolVersion{Name: "go", TagRegex: `(`}},
+		{name: "unknown strategy", decl: config.ToolVersion{Name: "go", UpdateStrategy: "Latest"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTools([]config.ToolVersion{tt.decl}); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
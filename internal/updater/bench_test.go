@@ -0,0 +1,63 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+// benchTags returns n tags as a busy image lists them: releases, variants,
+// prereleases, calendar and build tags, and commit tags.
+func benchTags(n int) []string {
+	tags := make([]string, 0, n)
+	for i := 0; len(tags) < n; i++ {
+		v := fmt.Sprintf("%d.%d.%d", i/400, (i/20)%20, i%20)
+		switch i % 8 {
+		case 0, 1, 2:
+			tags = append(tags, v)
+		case 3:
+			tags = append(tags, v+"-alpine")
+		case 4:
+			tags = append(tags, "v"+v+"-rc.1")
+		case 5:
+			tags = append(tags, fmt.Sprintf("sha-%07x", i*7919))
+		case 6:
+			tags = append(tags, fmt.Sprintf("2024.%02d.%02d", i%12+1, i%28+1))
+		case 7:
+			tags = append(tags, v+"-bookworm")
+		}
+	}
+	return tags
+}
+
+func BenchmarkCompare(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		_, _ = Compare("1.25.3-alpine", "1.27.0-alpine")
+	}
+}
+
+func BenchmarkSelectLatest(b *testing.B) {
+	tags := benchTags(2000)
+	ctx := context.Background()
+	for _, bc := range []struct {
+		name     string
+		baseline string
+		opts     []Option
+	}{
+		{"semver", "1.2.3", nil},
+		{"variant", "1.2.3-alpine", nil},
+		{"strategy", "1.2.3", []Option{WithStrategy(MinorUpdate)}},
+		{"transform", "1.2.3", []Option{WithTransform(regexp.MustCompile(`^(?P<version>\d+\.\d+\.\d+)$`))}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := SelectLatest(ctx, bc.baseline, tags, WithCompareOptions(bc.opts...)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package updater
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CalVerPolicy restricts which calendar versions a baseline may move to.
+type CalVerPolicy int
+
+// CalVerPolicy values.
+const (
+	// AnyCalVer accepts any later calendar version.
+	AnyCalVer CalVerPolicy = iota
+	// SameYearCalVer only accepts versions of the baseline's year.
+	SameYearCalVer
+	// SameMonthCalVer only accepts versions sharing the baseline's month, e.g.
+	// Ubuntu LTS releases 22.04 to 24.04.
+	SameMonthCalVer
+)
+
+// WithCalVerPolicy sets the policy applied to calendar versions.
+func WithCalVerPolicy(p CalVerPolicy) Option {
+	return func(o *options) {
+		o.calVerPolicy = p
+	}
+}
+
+var (
+	// dottedCalVer matches YYYY.MM[.DD][.MICRO] and YY.MM[.DD][.MICRO].
+	dottedCalVer = regexp.MustCompile(`^(\d{2}|\d{4})\.(\d{1,2})(?:\.(\d{1,2}))?(?:\.(\d+))?$`)
+	// compactCalVer matches YYYYMMDD[.MICRO...].
+	compactCalVer = regexp.MustCompile(`^(\d{4})(\d{2})(\d{2})((?:\.\d+)*)$`)
+)
+
+// CalVer is a parsed calendar version.
+type CalVer struct {
+	// Parts holds the year, month, and the optional day and micro numbers.
+	Parts []int
+	// Layout identifies the shape of the version, e.g. "YYYY.MM.DD"; only
+	// versions of the same layout are compared.
+	Layout string
+}
+
+// ParseCalVer parses a calendar version. Versions that are also valid
+// semantic versions are only treated as calendar versions when they start with
+// a four-digit year, so that "1.25.3" is not mistaken for a date.
+func ParseCalVer(v string) (CalVer, bool) {
+	return parseCalVer(v, true)
+}
+
+// parseCalVer parses a calendar version, only applying the heuristics telling
+// calendar versions from semantic versions when detect is set.
+func parseCalVer(v string, detect bool) (CalVer, bool) {
+	// Both layouts start with two digits, unlike most semantic versions, which
+	// are then rejected without matching.
+	if len(v) < 2 || !isDigit(v[0]) || !isDigit(v[1]) {
+		return CalVer{}, false
+	}
+	if m := compactCalVer.FindStringSubmatch(v); m != nil {
+		c := CalVer{Layout: "YYYYMMDD"}
+		for _, s := range m[1:4] {
+			n, _ := strconv.Atoi(s)
+			c.Parts = append(c.Parts, n)
+		}
+		for _, s := range strings.Split(strings.TrimPrefix(m[4], "."), ".") {
+			if s == "" {
+				continue
+			}
+			n, _ := strconv.Atoi(s)
+			c.Parts = append(c.Parts, n)
+			c.Layout += ".MICRO"
+		}
+		return c, validDate(c.Parts)
+	}
+	m := dottedCalVer.FindStringSubmatch(v)
+	if m == nil {
+		return CalVer{}, false
+	}
+	leadingZero := false
+	for _, s := range m[2:] {
+		if len(s) > 1 && s[0] == '0' {
+			leadingZero = true
+		}
+	}
+	if detect && len(m[1]) != 4 && !leadingZero {
+		return CalVer{}, false
+	}
+	c := CalVer{Layout: strings.Repeat("Y", len(m[1])) + ".MM"}
+	for i, s := range m[1:] {
+		if s == "" {
+			continue
+		}
+		n, _ := strconv.Atoi(s)
+		c.Parts = append(c.Parts, n)
+		switch i {
+		case 2:
+			c.Layout += ".DD"
+		case 3:
+			c.Layout += ".MICRO"
+		}
+	}
+	return c, validDate(c.Parts)
+}
+
+// isDigit reports whether b is an ASCII digit.
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
+
+// validDate reports whether the month and day parts are plausible.
+func validDate(parts []int) bool {
+	if parts[1] < 1 || parts[1] > 12 {
+		return false
+	}
+	return len(parts) < 3 || (parts[2] >= 1 && parts[2] <= 31)
+}
+
+// compareCalVer compares two calendar versions of the same layout, applying
+// the calendar policy to upgrades.
+func compareCalVer(baseline, target CalVer, policy CalVerPolicy) (Comparison, error) {
+	if baseline.Layout != target.Layout {
+		return Equal, fmt.Errorf(
+			"%w: calendar layout mismatch: %s != %s",
+			ErrTypeMismatch,
+			target.Layout,
+			baseline.Layout,
+		)
+	}
+	cmp := Equal
+	for i := range baseline.Parts {
+		if baseline.Parts[i] != target.Parts[i] {
+			if baseline.Parts[i] < target.Parts[i] {
+				cmp = Greater
+			} else {
+				cmp = Less
+			}
+			break
+		}
+	}
+	if cmp != Greater {
+		return cmp, nil
+	}
+	switch {
+	case policy == SameYearCalVer && target.Parts[0] != baseline.Parts[0]:
+		return Equal, fmt.Errorf("%w: calendar year changed", ErrPolicyRejection)
+	case policy == SameMonthCalVer && target.Parts[1] != baseline.Parts[1]:
+		return Equal, fmt.Errorf("%w: calendar month changed", ErrPolicyRejection)
+	}
+	return Greater, nil
+}
+
+// calVerOf extracts the calendar version of v, through the version group of
+// the transform regex when set, m being its match on v. Detection heuristics
+// apply unless the version is compared against a known calendar version.
+func calVerOf(v string, o options, m []string, detect bool) (CalVer, bool) {
+	if o.transformRegex != nil {
+		if m == nil {
+			return CalVer{}, false
+		}
+		v = getSubexpValue(o.transformRegex, m, "version")
+	}
+	return parseCalVer(v, detect)
+}
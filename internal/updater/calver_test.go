@@ -0,0 +1,94 @@
+package updater
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestParseCalVer(t *testing.T) {
+	cases := map[string]string{
+		"24.04":      "YY.MM",
+		"2024.10.06": "YYYY.MM.DD",
+		"2024.10":    "YYYY.MM",
+		"20241006":   "YYYYMMDD",
+		"20241006.0": "YYYYMMDD.MICRO",
+		"2024.1.5.2": "YYYY.MM.DD.MICRO",
+		"1.25.3":     "",
+		"24.4":       "",
+		"2024.13":    "",
+		"v1.2.3":     "",
+	}
+	for v, want := range cases {
+		t.Run(v, func(t *testing.T) {
+			c, ok := ParseCalVer(v)
+			if want == "" {
+				if ok {
+					t.Fatalf("ParseCalVer(%q) = %+v, want no match", v, c)
+				}
+				return
+			}
+			if !ok || c.Layout != want {
+				t.Fatalf("ParseCalVer(%q) = %+v, %v, want layout %s", v, c, ok, want)
+			}
+		})
+	}
+}
+
+func TestCompare_CalVer(t *testing.T) {
+	cases := []struct {
+		baseline, target string
+		opts             []Option
+		want             Comparison
+		invalid          bool
+	}{
+		{baseline: "22.04", target: "24.04", want: Greater},
+		{baseline: "24.04", target: "22.10", want: Less},
+		{baseline: "2024.10.06", target: "2024.10.16", want: Greater},
+		{baseline: "20241006", target: "20241006", want: Equal},
+		{baseline: "22.04", target: "2024.10", invalid: true},
+		{baseline: "22.04", target: "latest", invalid: true},
+		{
+			baseline: "22.04",
+			target:   "24.10",
+			opts:     []Option{WithCalVerPolicy(SameMonthCalVer)},
+			invalid:  true,
+		},
+		{
+			baseline: "22.04",
+			target:   "24.04",
+			opts:     []Option{WithCalVerPolicy(SameMonthCalVer)},
+			want:     Greater,
+		},
+		{
+			baseline: "2024.10.06",
+			target:   "2025.01.02",
+			opts:     []Option{WithCalVerPolicy(SameYearCalVer)},
+			invalid:  true,
+		},
+		{
+			baseline: "base-20241006.0.269705",
+			target:   "base-20241101.0.270001",
+			opts: []Option{
+				WithTransform(regexp.MustCompile(`^base-(?P<version>\d{8})\.`)),
+			},
+			want: Greater,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.baseline+"->"+c.target, func(t *testing.T) {
+			got, err := Compare(c.baseline, c.target, c.opts...)
+			if c.invalid {
+				if !IsNotValid(err) {
+					t.Fatalf("Compare(%q,%q) = %v, %v, want invalid", c.baseline, c.target, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Compare(%q,%q) error: %v", c.baseline, c.target, err)
+			}
+			if got != c.want {
+				t.Fatalf("Compare(%q,%q)=%v want %v", c.baseline, c.target, got, c.want)
+			}
+		})
+	}
+}
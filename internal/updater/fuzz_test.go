@@ -0,0 +1,73 @@
+package updater
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// fuzzTransforms are transform regexes whose groups may match empty strings.
+var fuzzTransforms = []*regexp.Regexp{
+	nil,
+	regexp.MustCompile(`^release-(?P<major>\d*)\.?(?P<minor>\d*)\.?(?P<patch>\d*)(?:-(?P<prerelease>.*))?$`),
+	regexp.MustCompile(`^(?P<version>.*?)(?P<suffix>-[a-z]+)?$`),
+	regexp.MustCompile(`(?P<major>\d+)?`),
+}
+
+// fuzzTags seeds version tags as registries list them.
+var fuzzTags = []string{
+	"1.25.3", "v1.2.3", "V2", "1.2", "v1.2.3-rc.1", "1.2.3+build.5",
+	"1.25.3-alpine", "2024.06.01", "20240601", "2024.6", "latest", "main",
+	"release-1.2.3", "release-", "release-..-", "", "v", "-", ".", "1..2",
+	"99999999999999999999.0.0", "1.2.3-ß", "１.２.３", "v1.2.3\x00", "\xff\xfe",
+}
+
+func FuzzCanonical(f *testing.F) {
+	for _, tag := range fuzzTags {
+		f.Add(tag)
+	}
+	f.Fuzz(func(t *testing.T, tag string) {
+		for _, re := range fuzzTransforms {
+			got, err := Canonical(tag, WithTransform(re))
+			if err != nil {
+				continue
+			}
+			if !strings.HasPrefix(got, "v") {
+				t.Fatalf("Canonical(%q, %v) = %q, want a v prefix", tag, re, got)
+			}
+			if re == nil {
+				if again, err := Canonical(got); err != nil || again != got {
+					t.Fatalf("Canonical(%q) = %q, %v, want %q", got, again, err, got)
+				}
+			}
+			_, _ = Type(tag, WithTransform(re))
+			_, _ = MajorMinorPatch(tag, WithTransform(re))
+		}
+	})
+}
+
+func FuzzCompare(f *testing.F) {
+	for i, baseline := range fuzzTags {
+		f.Add(baseline, fuzzTags[(i+1)%len(fuzzTags)])
+	}
+	f.Fuzz(func(t *testing.T, baseline, target string) {
+		for _, re := range fuzzTransforms {
+			for _, ord := range []Ordering{SemverOrdering, NumericOrdering, LexicalOrdering} {
+				opts := []Option{WithTransform(re), WithOrdering(ord)}
+				got, err := Compare(baseline, target, opts...)
+				if err != nil || baseline == "latest" || target == "latest" {
+					continue
+				}
+				back, err := Compare(target, baseline, opts...)
+				if err != nil {
+					continue
+				}
+				want := map[Comparison]Comparison{Equal: Equal, Greater: Less, Less: Greater}[got]
+				if back != want {
+					t.Fatalf("Compare(%q, %q) = %v but Compare(%q, %q) = %v with %v, ordering %v",
+						baseline, target, got, target, baseline, back, re, ord)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package updater
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// Ordering selects how versions are ranked.
+type Ordering int
+
+// Ordering values.
+const (
+	// SemverOrdering ranks semantic and calendar versions.
+	SemverOrdering Ordering = iota
+	// NumericOrdering ranks versions by the number they hold, e.g. build
+	// numbers such as 1234 or build-567.
+	NumericOrdering
+	// LexicalOrdering ranks versions as strings, e.g. plain dates.
+	LexicalOrdering
+)
+
+// ParseOrdering parses "semver", "numeric" or "lexical", defaulting to semver
+// when empty.
+func ParseOrdering(s string) (Ordering, error) {
+	switch s {
+	case "", "semver":
+		return SemverOrdering, nil
+	case "numeric":
+		return NumericOrdering, nil
+	case "lexical":
+		return LexicalOrdering, nil
+	default:
+		return SemverOrdering, fmt.Errorf("unknown ordering %q", s)
+	}
+}
+
+// WithOrdering ranks versions with the given ordering instead of semver.
+func WithOrdering(ord Ordering) Option {
+	return func(o *options) {
+		o.ordering = ord
+	}
+}
+
+var digits = regexp.MustCompile(`\d+`)
+
+// shape replaces every digit run of v with a placeholder, so that only
+// versions sharing their non-numeric parts are compared.
+func shape(v string) string {
+	return digits.ReplaceAllString(v, "#")
+}
+
+// fallbackVersion extracts the version group of the transform regex when set.
+func fallbackVersion(v string, o options) (string, error) {
+	if o.transformRegex == nil {
+		return v, nil
+	}
+	m := o.transformRegex.FindStringSubmatch(v)
+	if m == nil {
+		return "", fmt.Errorf("no match in tag %q using regex %q", v, o.transformRegex.String())
+	}
+	if s := getSubexpValue(o.transformRegex, m, "version"); s != "" {
+		return s, nil
+	}
+	return m[0], nil
+}
+
+// compareFallback compares versions with the numeric or lexical ordering.
+func compareFallback(baseline, target string, o options) (Comparison, error) {
+	b, err := fallbackVersion(baseline, o)
+	if err != nil {
+		return Equal, fmt.Errorf("%w: failed to parse baseline %q: %w", ErrInvalidBaseline, baseline, err)
+	}
+	t, err := fallbackVersion(target, o)
+	if err != nil {
+		return Equal, fmt.Errorf("%w: %v", ErrInvalidTarget, err)
+	}
+	if o.ordering == NumericOrdering {
+		if !digits.MatchString(b) {
+			return Equal, fmt.Errorf("%w: baseline %q holds no number", ErrInvalidBaseline, baseline)
+		}
+		bn, tn := digits.FindAllString(b, -1), digits.FindAllString(t, -1)
+		// The placeholder of shape may also be a literal character of the
+		// target, so the numbers are counted as well.
+		if shape(b) != shape(t) || len(bn) != len(tn) {
+			return Equal, fmt.Errorf("%w: %q does not match the shape of %q", ErrTypeMismatch, target, baseline)
+		}
+		for i := range bn {
+			x, _ := new(big.Int).SetString(bn[i], 10)
+			y, _ := new(big.Int).SetString(tn[i], 10)
+			if c := x.Cmp(y); c != 0 {
+				return comparison(c), nil
+			}
+		}
+		return Equal, nil
+	}
+	if len(b) != len(t) || shape(b) != shape(t) {
+		return Equal, fmt.Errorf("%w: %q does not match the shape of %q", ErrTypeMismatch, target, baseline)
+	}
+	return comparison(strings.Compare(b, t)), nil
+}
+
+// comparison converts a baseline-relative comparison result into the
+// Comparison of the target.
+func comparison(c int) Comparison {
+	switch {
+	case c < 0:
+		return Greater
+	case c > 0:
+		return Less
+	default:
+		return Equal
+	}
+}
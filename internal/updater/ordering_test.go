@@ -0,0 +1,60 @@
+package updater
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestCompare_Ordering(t *testing.T) {
+	cases := []struct {
+		baseline, target string
+		opts             []Option
+		want             Comparison
+		err              error
+	}{
+		{"1234", "1240", []Option{WithOrdering(NumericOrdering)}, Greater, nil},
+		{"1234", "999", []Option{WithOrdering(NumericOrdering)}, Less, nil},
+		{"build-567", "build-1002", []Option{WithOrdering(NumericOrdering)}, Greater, nil},
+		{"build-567", "build-567", []Option{WithOrdering(NumericOrdering)}, Equal, nil},
+		{"build-567", "rc-900", []Option{WithOrdering(NumericOrdering)}, Equal, ErrTypeMismatch},
+		{"build-567", "latest", []Option{WithOrdering(NumericOrdering)}, Equal, ErrTypeMismatch},
+		{
+			"app-1234-alpine", "app-1300-alpine",
+			[]Option{
+				WithOrdering(NumericOrdering),
+				WithTransform(regexp.MustCompile(`^app-(?P<version>\d+)`)),
+			},
+			Greater, nil,
+		},
+		{"2024-10-06", "2024-11-01", []Option{WithOrdering(LexicalOrdering)}, Greater, nil},
+		{"2024-10-06", "2023-12-31", []Option{WithOrdering(LexicalOrdering)}, Less, nil},
+		{"2024-10-06", "latest", []Option{WithOrdering(LexicalOrdering)}, Equal, ErrTypeMismatch},
+	}
+	for _, c := range cases {
+		t.Run(c.baseline+"->"+c.target, func(t *testing.T) {
+			got, err := Compare(c.baseline, c.target, c.opts...)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("Compare(%q, %q) error = %v, want %v", c.baseline, c.target, err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Compare(%q, %q) error = %v", c.baseline, c.target, err)
+			}
+			if got != c.want {
+				t.Fatalf("Compare(%q, %q) = %v, want %v", c.baseline, c.target, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseOrdering(t *testing.T) {
+	if _, err := ParseOrdering("random"); err == nil {
+		t.Fatalf("ParseOrdering(random) error = nil, want error")
+	}
+	if o, err := ParseOrdering(""); err != nil || o != SemverOrdering {
+		t.Fatalf("ParseOrdering(\"\") = %v, %v, want semver", o, err)
+	}
+}
@@ -0,0 +1,251 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+type selectOptions struct {
+	excludes       map[string]struct{}
+	compareOptions []Option
+	logAttrs       []any
+}
+
+// SelectOption configures how the latest candidate is selected.
+type SelectOption func(*selectOptions)
+
+// WithExcludes ignores any candidate present in the provided set.
+func WithExcludes(excludes map[string]struct{}) SelectOption {
+	return func(o *selectOptions) { o.excludes = excludes }
+}
+
+// WithCompareOptions forwards options to Compare.
+func WithCompareOptions(opts ...Option) SelectOption {
+	return func(o *selectOptions) { o.compareOptions = opts }
+}
+
+// WithLogAttrs adds attributes, such as the dependency name, to the debug
+// logs explaining why candidates were skipped.
+func WithLogAttrs(args ...any) SelectOption {
+	return func(o *selectOptions) { o.logAttrs = args }
+}
+
+// CandidateCheck reports whether a candidate may replace the baseline, e.g.
+// after inspecting its manifest.
+type CandidateCheck func(ctx context.Context, candidate string) (bool, error)
+
+// WithCandidateCheck verifies the selected candidate before it is returned.
+// A rejected candidate is dropped and the next greatest one is checked. When
+// given more than once, a candidate must pass every check.
+func WithCandidateCheck(check CandidateCheck) Option {
+	return func(o *options) {
+		prev := o.check
+		if prev == nil {
+			o.check = check
+			return
+		}
+		o.check = func(ctx context.Context, candidate string) (bool, error) {
+			ok, err := prev(ctx, candidate)
+			if err != nil || !ok {
+				return false, err
+			}
+			return check(ctx, candidate)
+		}
+	}
+}
+
+type downgradesKey struct{}
+
+// WithDowngrades returns a context whose selections may move below the
+// baseline when the source no longer lists it, e.g. a retracted release, to
+// the greatest candidate left. Otherwise only candidates strictly greater than
+// the baseline are selected.
+func WithDowngrades(ctx context.Context, allow bool) context.Context {
+	return context.WithValue(ctx, downgradesKey{}, allow)
+}
+
+// Downgrades reports whether selections made with the context may move below
+// the baseline.
+func Downgrades(ctx context.Context) bool {
+	allow, _ := ctx.Value(downgradesKey{}).(bool)
+	return allow
+}
+
+// SelectLatest returns the greatest candidate compared to the baseline, or the
+// baseline itself when no candidate is strictly greater. Excluded candidates
+// and those Compare reports as not valid are skipped; other comparison errors
+// abort the selection. Candidates are compared from the greatest version down,
+// so the result does not depend on the order the source lists them in. When
+// the context allows downgrades and the candidates no longer list the
+// baseline, the greatest lower candidate is selected instead.
+func SelectLatest(
+	ctx context.Context,
+	baseline string,
+	candidates []string,
+	opts ...SelectOption,
+) (string, error) {
+	o := selectOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	compare := makeOptions(o.compareOptions...)
+	check := compare.check
+	candidates = sortCandidates(candidates, compare)
+	base := newComparer(baseline, compare)
+	rejected := make(map[string]struct{})
+	for {
+		best, lower, err := selectBest(ctx, base, candidates, o, rejected)
+		if err == nil && best == baseline && lower != "" &&
+			Downgrades(ctx) && !listed(base, candidates) {
+			slog.InfoContext(
+				ctx,
+				"baseline not listed, downgrading",
+				append([]any{"baseline", baseline, "candidate", lower}, o.logAttrs...)...,
+			)
+			best = lower
+		}
+		if err != nil || best == baseline || check == nil {
+			return best, err
+		}
+		ok, err := check(ctx, best)
+		if err != nil {
+			return "", fmt.Errorf("check candidate %s: %w", best, err)
+		}
+		if ok {
+			return best, nil
+		}
+		slog.DebugContext(
+			ctx,
+			"candidate rejected by check",
+			append([]any{"candidate", best}, o.logAttrs...)...,
+		)
+		rejected[best] = struct{}{}
+	}
+}
+
+// listed reports whether a candidate compares equal to the baseline, such as
+// 1.25.0 for a v1.25.0 baseline, so the baseline is still published.
+func listed(base *comparer, candidates []string) bool {
+	return slices.ContainsFunc(candidates, func(c string) bool {
+		cmp, err := base.compare(c)
+		return err == nil && cmp == Equal
+	})
+}
+
+// sortCandidates returns the candidates from the greatest semantic version
+// down, equal versions in reverse lexical order. Candidates that are not
+// semantic versions follow, in reverse lexical order.
+func sortCandidates(candidates []string, o options) []string {
+	type keyed struct{ candidate, key string }
+	keys := make([]keyed, len(candidates))
+	for i, c := range candidates {
+		keys[i].candidate = c
+		if v, err := canonical(c, o, o.match(c)); err == nil && semver.IsValid(v) {
+			keys[i].key = v
+		}
+	}
+	slices.SortFunc(keys, func(a, b keyed) int {
+		switch {
+		case a.key != "" && b.key != "":
+			if c := semver.Compare(b.key, a.key); c != 0 {
+				return c
+			}
+		case a.key != "":
+			return -1
+		case b.key != "":
+			return 1
+		}
+		return strings.Compare(b.candidate, a.candidate)
+	})
+	sorted := make([]string, len(keys))
+	for i, k := range keys {
+		sorted[i] = k.candidate
+	}
+	return sorted
+}
+
+// selectBest returns the greatest candidate that is neither excluded nor
+// rejected, and the greatest such candidate below the baseline. Skipped
+// candidates are only logged when debug logs are enabled, as building their
+// attributes would otherwise dominate selections among thousands of tags.
+func selectBest(
+	ctx context.Context,
+	base *comparer,
+	candidates []string,
+	o selectOptions,
+	rejected map[string]struct{},
+) (best, lower string, err error) {
+	baseline := base.baseline
+	best = baseline
+	current := base
+	debug := slog.Default().Enabled(ctx, slog.LevelDebug)
+	for _, c := range candidates {
+		if _, ok := rejected[c]; ok {
+			continue
+		}
+		if _, ok := o.excludes[c]; ok {
+			if debug {
+				slog.DebugContext(
+					ctx,
+					"candidate excluded by exclude list",
+					append([]any{"candidate", c}, o.logAttrs...)...,
+				)
+			}
+			continue
+		}
+		if !base.within(c) {
+			if debug {
+				slog.DebugContext(
+					ctx,
+					"candidate out of update strategy",
+					append([]any{"candidate", c, "baseline", baseline}, o.logAttrs...)...,
+				)
+			}
+			continue
+		}
+		cmp, err := current.compare(c)
+		if err != nil {
+			if IsNotValid(err) {
+				if debug {
+					slog.DebugContext(
+						ctx,
+						err.Error(),
+						append([]any{"candidate", c, "baseline", baseline}, o.logAttrs...)...,
+					)
+				}
+				continue
+			}
+			return "", "", fmt.Errorf("compare versions: %w", err)
+		}
+		switch cmp {
+		case Greater:
+			best = c
+			current = newComparer(best, base.o)
+		case Equal:
+			if debug {
+				slog.DebugContext(
+					ctx,
+					"candidate is equal to best",
+					append([]any{"candidate", c, "best", best}, o.logAttrs...)...,
+				)
+			}
+		case Less:
+			if best == baseline && lower == "" {
+				lower = c
+			}
+			if debug {
+				slog.DebugContext(
+					ctx,
+					"candidate is less than best",
+					append([]any{"candidate", c, "best", best}, o.logAttrs...)...,
+				)
+			}
+		}
+	}
+	return best, lower, nil
+}
@@ -0,0 +1,203 @@
+package updater
+
+import (
+	"context"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestSelectLatest(t *testing.T) {
+	release := regexp.MustCompile(`^release-(?P<major>\d+)\.(?P<minor>\d+)\.(?P<patch>\d+)$`)
+	cases := []struct {
+		name       string
+		baseline   string
+		candidates []string
+		opts       []SelectOption
+		want       string
+	}{
+		{
+			name:       "greatest canonical",
+			baseline:   "1.0.0",
+			candidates: []string{"1.2.0", "1.10.0", "1.9.3"},
+			want:       "1.10.0",
+		},
+		{
+			name:       "keeps baseline when nothing is greater",
+			baseline:   "2.0.0",
+			candidates: []string{"1.0.0", "2.0.0"},
+			want:       "2.0.0",
+		},
+		{
+			name:       "skips invalid and prerelease candidates",
+			baseline:   "1.0.0",
+			candidates: []string{"latest", "1.1.0-rc1", "main", "1.0.1"},
+			want:       "1.0.1",
+		},
+		{
+			name:       "keeps version shape",
+			baseline:   "v1",
+			candidates: []string{"v1.2.3", "v2", "v3.0.0"},
+			want:       "v2",
+		},
+		{
+			name:       "honours excludes",
+			baseline:   "1.0.0",
+			candidates: []string{"1.1.0", "1.2.0"},
+			opts:       []SelectOption{WithExcludes(map[string]struct{}{"1.2.0": {}})},
+			want:       "1.1.0",
+		},
+		{
+			name:       "forwards compare options",
+			baseline:   "release-1.0.0",
+			candidates: []string{"release-1.1.0", "1.5.0"},
+			opts:       []SelectOption{WithCompareOptions(WithTransform(release))},
+			want:       "release-1.1.0",
+		},
+		{
+			name:       "rejected by check",
+			baseline:   "1.0.0",
+			candidates: []string{"1.1.0", "1.3.0", "1.2.0"},
+			opts: []SelectOption{WithCompareOptions(WithCandidateCheck(
+				func(_ context.Context, c string) (bool, error) { return c != "1.3.0", nil },
+			))},
+			want: "1.2.0",
+		},
+		{
+			name:       "all rejected by check",
+			baseline:   "1.0.0",
+			candidates: []string{"1.1.0"},
+			opts: []SelectOption{WithCompareOptions(WithCandidateCheck(
+				func(context.Context, string) (bool, error) { return false, nil },
+			))},
+			want: "1.0.0",
+		},
+		{
+			name:       "every check must pass",
+			baseline:   "1.0.0",
+			candidates: []string{"1.1.0", "1.2.0", "1.3.0"},
+			opts: []SelectOption{WithCompareOptions(
+				WithCandidateCheck(func(_ context.Context, c string) (bool, error) { return c != "1.3.0", nil }),
+				WithCandidateCheck(func(_ context.Context, c string) (bool, error) { return c != "1.2.0", nil }),
+			)},
+			want: "1.1.0",
+		},
+		{
+			name:       "no candidates",
+			baseline:   "1.0.0",
+			candidates: nil,
+			want:       "1.0.0",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := SelectLatest(context.Background(), c.baseline, c.candidates, c.opts...)
+			if err != nil {
+				t.Fatalf("SelectLatest error: %v", err)
+			}
+			if got != c.want {
+				t.Fatalf("SelectLatest(%q, %v)=%q want %q", c.baseline, c.candidates, got, c.want)
+			}
+		})
+	}
+}
+
+func TestSelectLatest_InvalidBaseline(t *testing.T) {
+	if _, err := SelectLatest(context.Background(), "main", []string{"1.0.0"}); err == nil {
+		t.Fatalf("expected error for invalid baseline")
+	}
+}
+
+func TestSelectLatest_Prereleases(t *testing.T) {
+	candidates := []string{"v1.2.4", "v1.3.0-rc.1", "v2.0.0-beta.1"}
+	got, err := SelectLatest(context.Background(), "v1.2.3", candidates)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "v1.2.4" {
+		t.Errorf("without prereleases: got %s, want v1.2.4", got)
+	}
+	got, err = SelectLatest(
+		context.Background(),
+		"v1.2.3",
+		candidates,
+		WithCompareOptions(WithPrereleases(true)),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "v2.0.0-beta.1" {
+		t.Errorf("with prereleases: got %s, want v2.0.0-beta.1", got)
+	}
+	got, err = SelectLatest(
+		context.Background(),
+		"v1.3.0-rc.1",
+		[]string{"v1.3.0", "v1.3.0-rc.2"},
+		WithCompareOptions(WithPrereleases(true)),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "v1.3.0" {
+		t.Errorf("from prerelease: got %s, want v1.3.0", got)
+	}
+}
+
+func TestSelectLatest_IndependentOfOrder(t *testing.T) {
+	cases := []struct {
+		baseline   string
+		candidates []string
+		want       string
+	}{
+		{"latest", []string{"1.0", "1.10.0", "1.2.0", "1.9.0"}, "1.10.0"},
+		{"v1.2.3", []string{"v1.2.4", "1.3.0", "v1.3.0", "v1.10.0-rc.1"}, "v1.3.0"},
+		{"1.25.3-alpine", []string{"1.27.0", "1.26.1-alpine", "1.26.0-alpine", "main"}, "1.26.1-alpine"},
+	}
+	rng := rand.New(rand.NewPCG(1, 2))
+	for _, c := range cases {
+		for range 20 {
+			candidates := slices.Clone(c.candidates)
+			rng.Shuffle(len(candidates), func(i, j int) {
+				candidates[i], candidates[j] = candidates[j], candidates[i]
+			})
+			got, err := SelectLatest(context.Background(), c.baseline, candidates)
+			if err != nil {
+				t.Fatalf("%s %v: unexpected error: %v", c.baseline, candidates, err)
+			}
+			if got != c.want {
+				t.Fatalf("%s %v: got %s, want %s", c.baseline, candidates, got, c.want)
+			}
+		}
+	}
+}
+
+func TestSelectLatest_Downgrades(t *testing.T) {
+	cases := []struct {
+		name       string
+		allow      bool
+		baseline   string
+		candidates []string
+		want       string
+	}{
+		{"disallowed", false, "1.3.0", []string{"1.1.0", "1.2.0"}, "1.3.0"},
+		{"retracted baseline", true, "1.3.0", []string{"1.1.0", "1.2.0"}, "1.2.0"},
+		{"listed baseline", true, "1.2.0", []string{"1.1.0", "1.2.0"}, "1.2.0"},
+		{"baseline listed without prefix", true, "v1.25.0", []string{"1.25.0", "1.24.0"}, "v1.25.0"},
+
+		{"greater candidate", true, "1.1.5", []string{"1.1.0", "1.2.0"}, "1.2.0"},
+		{"no lower candidate", true, "1.0.0", []string{"main"}, "1.0.0"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := WithDowngrades(context.Background(), c.allow)
+			got, err := SelectLatest(ctx, c.baseline, c.candidates)
+			if err != nil {
+				t.Fatalf("SelectLatest error: %v", err)
+			}
+			if got != c.want {
+				t.Fatalf("SelectLatest(%q, %v)=%q want %q", c.baseline, c.candidates, got, c.want)
+			}
+		})
+	}
+}
@@ -18,8 +18,16 @@ var (
 	ErrTypeMismatch = errors.New("type mismatch")
 	// ErrInvalidTarget indicates the target version is not a valid semantic version.
 	ErrInvalidTarget = errors.New("invalid target version")
+	// ErrInvalidBaseline indicates the current version is not a version, e.g. a
+	// branch name.
+	ErrInvalidBaseline = errors.New("invalid baseline version")
 )
 
+// maxVersionLength bounds the length of versions, well above the 128
+// characters of OCI tags, so that tags listed by a registry cannot make
+// comparisons arbitrarily slow.
+const maxVersionLength = 256
+
 // IsNotValid reports whether the error denotes an invalid target, policy rejection,
 // or type mismatch encountered during version comparison.
 func IsNotValid(err error) bool {
@@ -29,6 +37,11 @@ func IsNotValid(err error) bool {
 type options struct {
 	transformRegex *regexp.Regexp
 	policy         *PolicyType
+	calVerPolicy   CalVerPolicy
+	ordering       Ordering
+	strategy       Strategy
+	prereleases    bool
+	check          CandidateCheck
 }
 
 // Option configures semver parsing and comparison behavior.
@@ -49,6 +62,15 @@ func WithPolicy(ut PolicyType) Option {
 	}
 }
 
+// WithPrereleases accepts prerelease targets for release baselines, and
+// releases for prerelease baselines, instead of only comparing versions of
+// the same type.
+func WithPrereleases(include bool) Option {
+	return func(o *options) {
+		o.prereleases = include
+	}
+}
+
 func makeOptions(opts ...Option) options {
 	o := options{}
 	for _, opt := range opts {
@@ -57,6 +79,14 @@ func makeOptions(opts ...Option) options {
 	return o
 }
 
+// match returns the match of the transform regex on v, or nil without one.
+func (o options) match(v string) []string {
+	if o.transformRegex == nil {
+		return nil
+	}
+	return o.transformRegex.FindStringSubmatch(v)
+}
+
 // Comparison indicates relative ordering between two versions.
 type Comparison int
 
@@ -68,35 +98,100 @@ const (
 )
 
 // Compare compares two versions using consistent strategy and canonicalization.
+// Calendar version baselines are compared with targets of the same layout, and
+// the numeric and lexical orderings replace semver when selected. Tags carrying
+// a variant suffix, e.g. "-alpine", are only compared with the same variant.
 func Compare(baseline, target string, opts ...Option) (Comparison, error) {
-	if baseline == "latest" {
-		tv, err := Canonical(target, opts...)
+	return newComparer(baseline, makeOptions(opts...)).compare(target)
+}
+
+// comparer compares targets with a baseline analysed once, so that selecting
+// among thousands of tags does not match and canonicalize the baseline again
+// for each of them.
+type comparer struct {
+	o        options
+	baseline string
+	// calVer is the calendar version of the baseline, when isCalVer.
+	calVer   CalVer
+	isCalVer bool
+	// version and suffix split the variant suffix off the baseline.
+	version string
+	suffix  string
+	// typ and canonical are the type and canonical form of the version,
+	// unless typeErr or canonicalErr are set.
+	typ          VersionType
+	typeErr      error
+	canonical    string
+	canonicalErr error
+}
+
+// newComparer analyses the baseline for the semantic version ordering.
+func newComparer(baseline string, o options) *comparer {
+	c := &comparer{o: o, baseline: baseline}
+	if len(baseline) > maxVersionLength || o.ordering != SemverOrdering {
+		return c
+	}
+	m := o.match(baseline)
+	if c.calVer, c.isCalVer = calVerOf(baseline, o, m, true); c.isCalVer || baseline == "latest" {
+		return c
+	}
+	c.version, c.suffix = splitVariant(baseline, o, m)
+	c.typ, c.typeErr = typeOf(c.version, o, m)
+	c.canonical, c.canonicalErr = canonical(c.version, o, m)
+	return c
+}
+
+// compare compares a target with the baseline.
+func (c *comparer) compare(target string) (Comparison, error) {
+	o := c.o
+	if len(c.baseline) > maxVersionLength {
+		return Equal, fmt.Errorf("%w: longer than %d bytes", ErrInvalidBaseline, maxVersionLength)
+	}
+	if len(target) > maxVersionLength {
+		return Equal, fmt.Errorf("%w: longer than %d bytes", ErrInvalidTarget, maxVersionLength)
+	}
+	if o.ordering != SemverOrdering {
+		return compareFallback(c.baseline, target, o)
+	}
+	m := o.match(target)
+	if c.isCalVer {
+		tc, ok := calVerOf(target, o, m, false)
+		if !ok {
+			return Equal, fmt.Errorf("%w: not a calendar version: %q", ErrInvalidTarget, target)
+		}
+		return compareCalVer(c.calVer, tc, o.calVerPolicy)
+	}
+	if c.baseline == "latest" {
+		tv, err := canonical(target, o, m)
 		if err != nil {
 			return Equal, fmt.Errorf("%w: %v", ErrInvalidTarget, err)
 		}
-		if semver.Prerelease(tv) != "" {
+		if semver.Prerelease(tv) != "" && !o.prereleases {
 			return Equal, fmt.Errorf("%w: prerelease excluded for baseline 'latest': %q", ErrInvalidTarget, target)
 		}
 		return Greater, nil
 	}
 
-	baselineType, err := Type(baseline, opts...)
-	if err != nil {
-		return Equal, fmt.Errorf("failed to determine policy for baseline %q: %w", baseline, err)
+	target, suffix := splitVariant(target, o, m)
+	if suffix != c.suffix {
+		return Equal, fmt.Errorf("%w: variant %q != %q", ErrTypeMismatch, suffix, c.suffix)
+	}
+	if c.typeErr != nil {
+		return Equal, fmt.Errorf("%w: failed to determine policy for baseline %q: %w", ErrInvalidBaseline, c.version, c.typeErr)
 	}
-	targetType, err := Type(target, opts...)
+	targetType, err := typeOf(target, o, m)
 	if err != nil {
 		return Equal, fmt.Errorf("%w: %v", ErrInvalidTarget, err)
 	}
-	if targetType != baselineType {
-		return Equal, fmt.Errorf("%w: type mismatch: %v != %v", ErrTypeMismatch, targetType, baselineType)
+	if targetType != c.typ && !(o.prereleases && isReleaseOrPrerelease(c.typ, targetType)) {
+		return Equal, fmt.Errorf("%w: type mismatch: %v != %v", ErrTypeMismatch, targetType, c.typ)
 	}
 
-	baseline, err = Canonical(baseline, opts...)
-	if err != nil {
-		return Equal, fmt.Errorf("failed to canonicalize baseline %q: %w", baseline, err)
+	if c.canonicalErr != nil {
+		return Equal, fmt.Errorf("%w: failed to canonicalize baseline %q: %w", ErrInvalidBaseline, c.version, c.canonicalErr)
 	}
-	target, err = Canonical(target, opts...)
+	baseline := c.canonical
+	target, err = canonical(target, o, m)
 	if err != nil {
 		return Equal, fmt.Errorf("%w: %v", ErrInvalidTarget, err)
 	}
@@ -105,7 +200,9 @@ func Compare(baseline, target string, opts ...Option) (Comparison, error) {
 	case cmp == 0:
 		return Equal, nil
 	case cmp < 0:
-		o := makeOptions(opts...)
+		if err := checkStrategy(o.strategy, baseline, target); err != nil {
+			return Equal, err
+		}
 		if o.policy != nil {
 			pol, err := Policy(baseline)
 			if err != nil {
@@ -121,6 +218,17 @@ func Compare(baseline, target string, opts ...Option) (Comparison, error) {
 	}
 }
 
+// isReleaseOrPrerelease reports whether both types are canonical or
+// prerelease versions.
+func isReleaseOrPrerelease(types ...VersionType) bool {
+	for _, t := range types {
+		if t != CanonicalVersion && t != PreReleaseVersion {
+			return false
+		}
+	}
+	return true
+}
+
 // VersionType describes the kind of version update strategy.
 type VersionType int
 
@@ -130,14 +238,23 @@ const (
 	MajorMinorVersion
 	MajorVersion
 	PreReleaseVersion
+	CalendarVersion
 )
 
 // Type determines the update strategy for a version string.
 func Type(v string, opts ...Option) (VersionType, error) {
 	o := makeOptions(opts...)
+	return typeOf(v, o, o.match(v))
+}
+
+// typeOf determines the type of v, m being the match of the transform regex
+// on it.
+func typeOf(v string, o options, m []string) (VersionType, error) {
+	if _, ok := calVerOf(v, o, m, true); ok {
+		return CalendarVersion, nil
+	}
 
 	if o.transformRegex != nil {
-		m := o.transformRegex.FindStringSubmatch(v)
 		if m == nil {
 			return 0, fmt.Errorf(
 				"no semver match in tag %q using regex %q",
@@ -164,7 +281,7 @@ func Type(v string, opts ...Option) (VersionType, error) {
 		}
 	}
 
-	v, err := Canonical(v)
+	v, err := canonical(v, options{}, nil)
 	if err != nil {
 		return 0, err
 	}
@@ -184,12 +301,20 @@ func Type(v string, opts ...Option) (VersionType, error) {
 }
 
 // Canonical normalizes a tag to have a leading 'v' and converts an uppercase
-// 'V' prefix to lowercase.
+// 'V' prefix to lowercase. Tags longer than 256 bytes, and tags the transform
+// regex matches without capturing a version, are rejected.
 func Canonical(v string, opts ...Option) (string, error) {
 	o := makeOptions(opts...)
+	return canonical(v, o, o.match(v))
+}
+
+// canonical normalizes v, m being the match of the transform regex on it.
+func canonical(v string, o options, m []string) (string, error) {
+	if len(v) > maxVersionLength {
+		return "", fmt.Errorf("tag of %d bytes is longer than %d", len(v), maxVersionLength)
+	}
 
 	if o.transformRegex != nil {
-		m := o.transformRegex.FindStringSubmatch(v)
 		if m == nil {
 			return "", fmt.Errorf(
 				"no semver match in tag %q using regex %q",
@@ -198,20 +323,24 @@ func Canonical(v string, opts ...Option) (string, error) {
 			)
 		}
 
-		v = getSubexpValue(o.transformRegex, m, "version")
-		if v == "" {
-			v = canonicalWithRegex(o.transformRegex, m)
+		version := getSubexpValue(o.transformRegex, m, "version")
+		if version == "" {
+			var err error
+			if version, err = canonicalWithRegex(o.transformRegex, m); err != nil {
+				return "", fmt.Errorf("tag %q: %w", v, err)
+			}
 		}
+		v = version
 	}
 
-	if strings.HasPrefix(v, "V") {
+	switch {
+	case strings.HasPrefix(v, "v"):
+		return v, nil
+	case strings.HasPrefix(v, "V"):
 		return "v" + v[1:], nil
-	}
-	if !strings.HasPrefix(v, "v") {
+	default:
 		return "v" + v, nil
 	}
-
-	return v, nil
 }
 
 func getSubexpValue(re *regexp.Regexp, m []string, name string) string {
@@ -222,16 +351,22 @@ func getSubexpValue(re *regexp.Regexp, m []string, name string) string {
 	return ""
 }
 
-func canonicalWithRegex(re *regexp.Regexp, m []string) string {
+// canonicalWithRegex assembles a version from the major, minor, patch,
+// prerelease and build groups of a match, missing numbers defaulting to 0. A
+// match capturing no number is not a version.
+func canonicalWithRegex(re *regexp.Regexp, m []string) (string, error) {
 	maj := getSubexpValue(re, m, "major")
+	minor := getSubexpValue(re, m, "minor")
+	pat := getSubexpValue(re, m, "patch")
+	if maj == "" && minor == "" && pat == "" {
+		return "", fmt.Errorf("no version captured using regex %q", re.String())
+	}
 	if maj == "" {
 		maj = "0"
 	}
-	minor := getSubexpValue(re, m, "minor")
 	if minor == "" {
 		minor = "0"
 	}
-	pat := getSubexpValue(re, m, "patch")
 	if pat == "" {
 		pat = "0"
 	}
@@ -244,7 +379,7 @@ func canonicalWithRegex(re *regexp.Regexp, m []string) string {
 	if bld != "" {
 		s += "+" + bld
 	}
-	return fmt.Sprintf("v%s", s)
+	return fmt.Sprintf("v%s", s), nil
 }
 
 // MajorMinorPatch returns the major.minor.patch part of the version.
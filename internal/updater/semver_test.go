@@ -1,7 +1,9 @@
 package updater
 
 import (
+	"errors"
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -189,3 +191,37 @@ func TestPolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestCanonical_EmptyGroups(t *testing.T) {
+	re := regexp.MustCompile(`^(?P<major>\d*)\.?(?P<minor>\d*)-?(?P<suffix>[a-z]*)$`)
+	for tag, want := range map[string]string{
+		"1.2-alpine": "v1.2.0",
+		".3":         "v0.3.0",
+		"-alpine":    "",
+		"":           "",
+	} {
+		got, err := Canonical(tag, WithTransform(re))
+		if want == "" {
+			if err == nil {
+				t.Errorf("Canonical(%q) = %q, want error", tag, got)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("Canonical(%q) = %q, %v, want %q", tag, got, err, want)
+		}
+	}
+}
+
+func TestCompare_TooLong(t *testing.T) {
+	long := "1.0.0-" + strings.Repeat("a", maxVersionLength)
+	if _, err := Compare("1.0.0", long); !errors.Is(err, ErrInvalidTarget) {
+		t.Errorf("long target: got %v, want ErrInvalidTarget", err)
+	}
+	if _, err := Compare(long, "1.0.0"); !errors.Is(err, ErrInvalidBaseline) {
+		t.Errorf("long baseline: got %v, want ErrInvalidBaseline", err)
+	}
+	if _, err := Canonical(long); err == nil {
+		t.Errorf("Canonical: expected error for a long tag")
+	}
+}
@@ -0,0 +1,79 @@
+package updater
+
+import (
+	"fmt"
+
+	"golang.org/x/mod/semver"
+)
+
+// Strategy bounds the range of versions a baseline is upgraded to.
+type Strategy int
+
+// Strategy values.
+const (
+	// FullUpdate accepts any greater version.
+	FullUpdate Strategy = iota
+	// MinorUpdate only accepts versions of the baseline's major.
+	MinorUpdate
+	// PatchUpdate only accepts versions of the baseline's major and minor.
+	PatchUpdate
+)
+
+// ParseStrategy parses "FullUpdate", "MinorUpdate" or "PatchUpdate",
+// defaulting to FullUpdate when empty.
+func ParseStrategy(s string) (Strategy, error) {
+	switch s {
+	case "", "FullUpdate":
+		return FullUpdate, nil
+	case "MinorUpdate":
+		return MinorUpdate, nil
+	case "PatchUpdate":
+		return PatchUpdate, nil
+	default:
+		return FullUpdate, fmt.Errorf("unknown update strategy %q", s)
+	}
+}
+
+// WithStrategy restricts the semantic versions a baseline is upgraded to.
+func WithStrategy(s Strategy) Option {
+	return func(o *options) {
+		o.strategy = s
+	}
+}
+
+// checkStrategy rejects a target, greater than the baseline, out of the range
+// of the strategy. Both are canonical semantic versions.
+func checkStrategy(s Strategy, baseline, target string) error {
+	switch {
+	case s == MinorUpdate && semver.Major(baseline) != semver.Major(target):
+		return fmt.Errorf("%w: %s leaves major %s", ErrPolicyRejection, target, semver.Major(baseline))
+	case s == PatchUpdate && semver.MajorMinor(baseline) != semver.MajorMinor(target):
+		return fmt.Errorf("%w: %s leaves minor %s", ErrPolicyRejection, target, semver.MajorMinor(baseline))
+	default:
+		return nil
+	}
+}
+
+// within reports whether a target stays within the range of the strategy
+// around the baseline, so candidates are filtered against the baseline rather
+// than the best candidate so far. Versions that are not semantic versions, and
+// calendar versions, are left to compare.
+func (c *comparer) within(target string) bool {
+	o := c.o
+	if o.strategy == FullUpdate || o.ordering != SemverOrdering || c.baseline == "latest" || c.isCalVer {
+		return true
+	}
+	if c.canonicalErr != nil || !semver.IsValid(c.canonical) {
+		return true
+	}
+	m := o.match(target)
+	target, suffix := splitVariant(target, o, m)
+	if suffix != c.suffix {
+		return true
+	}
+	t, err := canonical(target, o, m)
+	if err != nil || !semver.IsValid(t) {
+		return true
+	}
+	return semver.Compare(c.canonical, t) >= 0 || checkStrategy(o.strategy, c.canonical, t) == nil
+}
@@ -0,0 +1,48 @@
+package updater
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSelectLatest_Strategy(t *testing.T) {
+	candidates := []string{"v1.2.4", "v1.3.0", "v1.3.1", "v2.0.0"}
+	cases := []struct {
+		strategy Strategy
+		want     string
+	}{
+		{FullUpdate, "v2.0.0"},
+		{MinorUpdate, "v1.3.1"},
+		{PatchUpdate, "v1.2.4"},
+	}
+	for _, c := range cases {
+		got, err := SelectLatest(
+			context.Background(),
+			"v1.2.3",
+			candidates,
+			WithCompareOptions(WithStrategy(c.strategy)),
+		)
+		if err != nil {
+			t.Fatalf("strategy %d: unexpected error: %v", c.strategy, err)
+		}
+		if got != c.want {
+			t.Errorf("strategy %d: got %s, want %s", c.strategy, got, c.want)
+		}
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	for s, want := range map[string]Strategy{
+		"":            FullUpdate,
+		"FullUpdate":  FullUpdate,
+		"MinorUpdate": MinorUpdate,
+		"PatchUpdate": PatchUpdate,
+	} {
+		if got, err := ParseStrategy(s); err != nil || got != want {
+			t.Errorf("ParseStrategy(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	if _, err := ParseStrategy("patch"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
@@ -0,0 +1,44 @@
+package updater
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	variantTag = regexp.MustCompile(`^([vV]?\d+(?:\.\d+)*)-(.*[a-zA-Z].*)$`)
+	prerelease = regexp.MustCompile(
+		`^(?i:alpha|beta|rc|pre|preview|dev|snapshot|nightly|canary|next)[.-]?\d*(?:\..*)?$`,
+	)
+)
+
+// Variant returns the variant suffix of a tag, e.g. "alpine" for
+// "1.25.3-alpine", or an empty string when the tag has none. The "suffix"
+// named group of the transform regex extracts it when set; without one, only
+// tags without a transform are inspected and prerelease identifiers such as
+// "rc.1" are not variants.
+func Variant(v string, opts ...Option) string {
+	o := makeOptions(opts...)
+	_, suffix := splitVariant(v, o, o.match(v))
+	return suffix
+}
+
+// splitVariant returns the version and variant suffix of a tag, m being the
+// match of the transform regex on it. The version is left untouched when the
+// transform regex extracts the suffix.
+func splitVariant(v string, o options, m []string) (string, string) {
+	if o.transformRegex != nil {
+		if m == nil {
+			return v, ""
+		}
+		return v, getSubexpValue(o.transformRegex, m, "suffix")
+	}
+	if !strings.Contains(v, "-") {
+		return v, ""
+	}
+	m = variantTag.FindStringSubmatch(v)
+	if m == nil || prerelease.MatchString(m[2]) {
+		return v, ""
+	}
+	return m[1], m[2]
+}
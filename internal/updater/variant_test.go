@@ -0,0 +1,71 @@
+package updater
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestVariant(t *testing.T) {
+	cases := map[string]string{
+		"1.25.3-alpine":       "alpine",
+		"v1.25-slim-bookworm": "slim-bookworm",
+		"1.25.3-rc.1":         "",
+		"1.25.3-beta2":        "",
+		"1.25.3-1":            "",
+		"1.25.3":              "",
+		"latest":              "",
+	}
+	for v, want := range cases {
+		if got := Variant(v); got != want {
+			t.Fatalf("Variant(%q) = %q, want %q", v, got, want)
+		}
+	}
+	re := regexp.MustCompile(`^(?P<version>[\d.]+)(?:-(?P<suffix>.+))?$`)
+	if got := Variant("1.25.3-rc.1", WithTransform(re)); got != "rc.1" {
+		t.Fatalf("Variant with suffix group = %q, want rc.1", got)
+	}
+}
+
+func TestCompare_Variant(t *testing.T) {
+	cases := []struct {
+		baseline, target string
+		want             Comparison
+		err              error
+	}{
+		{"1.25.3-alpine", "1.25.4-alpine", Greater, nil},
+		{"1.25.3-alpine", "1.25.2-alpine", Less, nil},
+		{"1.25.3-alpine", "1.25.4-slim", Equal, ErrTypeMismatch},
+		{"1.25.3-alpine", "1.25.4", Equal, ErrTypeMismatch},
+		{"1.25.3", "1.25.4-distroless", Equal, ErrTypeMismatch},
+		{"1.25-alpine", "1.26-alpine", Greater, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.baseline+"->"+c.target, func(t *testing.T) {
+			got, err := Compare(c.baseline, c.target)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("Compare(%q, %q) error = %v, want %v", c.baseline, c.target, err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Compare(%q, %q) error = %v", c.baseline, c.target, err)
+			}
+			if got != c.want {
+				t.Fatalf("Compare(%q, %q) = %v, want %v", c.baseline, c.target, got, c.want)
+			}
+		})
+	}
+}
+
+func TestCompare_VariantSuffixGroup(t *testing.T) {
+	re := regexp.MustCompile(`^(?P<major>\d+)\.(?P<minor>\d+)\.(?P<patch>\d+)(?:-(?P<suffix>.+))?$`)
+	if _, err := Compare("1.25.3-alpine", "1.25.4-slim", WithTransform(re)); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("Compare error = %v, want %v", err, ErrTypeMismatch)
+	}
+	got, err := Compare("1.25.3-alpine", "1.25.4-alpine", WithTransform(re))
+	if err != nil || got != Greater {
+		t.Fatalf("Compare = %v, %v, want Greater", got, err)
+	}
+}
@@ -0,0 +1,133 @@
+// Package automata is the public Go API of automata. It exposes the version
+// updaters and the manifest pipelines behind the command line, so other
+// programs such as operators and bots can embed the update logic.
+//
+// The API is versioned with the module: until v1, minor releases may still
+// change it, and anything outside this package remains internal.
+package automata
+
+import (
+	"context"
+	"regexp"
+	"slices"
+
+	"github.com/shikanime-studio/automata/internal/container"
+	"github.com/shikanime-studio/automata/internal/git"
+	"github.com/shikanime-studio/automata/internal/github"
+	"github.com/shikanime-studio/automata/internal/helm"
+	"github.com/shikanime-studio/automata/internal/mirror"
+	"github.com/shikanime-studio/automata/internal/updater"
+)
+
+// Updater resolves the version a reference should be updated to.
+type Updater[T any] = updater.Updater[T]
+
+// Option configures how versions are compared.
+type Option = updater.Option
+
+// Comparison is the result of comparing two versions.
+type Comparison = updater.Comparison
+
+// Results of Compare.
+const (
+	Equal   = updater.Equal
+	Greater = updater.Greater
+	Less    = updater.Less
+)
+
+// Compare compares a baseline version with a target version.
+func Compare(baseline, target string, opts ...Option) (Comparison, error) {
+	return updater.Compare(baseline, target, opts...)
+}
+
+// Strategy bounds the range of versions a baseline is upgraded to.
+type Strategy = updater.Strategy
+
+// Strategy values.
+const (
+	// FullUpdate accepts any greater version.
+	FullUpdate = updater.FullUpdate
+	// MinorUpdate only accepts versions of the baseline's major.
+	MinorUpdate = updater.MinorUpdate
+	// PatchUpdate only accepts versions of the baseline's major and minor.
+	PatchUpdate = updater.PatchUpdate
+)
+
+// WithStrategy restricts the semantic versions a baseline is upgraded to.
+func WithStrategy(s Strategy) Option {
+	return updater.WithStrategy(s)
+}
+
+// WithExcludes skips the given versions, so the greatest version left is
+// selected instead.
+func WithExcludes(versions ...string) Option {
+	return updater.WithCandidateCheck(func(_ context.Context, candidate string) (bool, error) {
+		return !slices.Contains(versions, candidate), nil
+	})
+}
+
+// WithTransform extracts the semantic version parts of versions with the
+// named groups of re, e.g. major, minor and patch.
+func WithTransform(re *regexp.Regexp) Option {
+	return updater.WithTransform(re)
+}
+
+// WithPrereleases accepts prerelease targets for release baselines, and
+// releases for prerelease baselines, instead of only comparing versions of
+// the same type.
+func WithPrereleases(include bool) Option {
+	return updater.WithPrereleases(include)
+}
+
+// References to the artifacts updated by the updaters.
+type (
+	// ImageRef is an OCI image reference.
+	ImageRef = container.ImageRef
+	// ChartRef is a Helm chart in a repository.
+	ChartRef = helm.ChartRef
+	// ActionRef is a GitHub Action reference.
+	ActionRef = github.ActionRef
+	// RepoRef is a Git repository.
+	RepoRef = git.RepoRef
+)
+
+// ParseImageRef parses a Docker-style image reference.
+func ParseImageRef(ref string) (ImageRef, error) {
+	return container.ParseImageRef(ref)
+}
+
+// ParseActionRef parses a GitHub Actions uses string like "owner/repo@v1".
+func ParseActionRef(uses string) (*ActionRef, error) {
+	return github.ParseActionRef(uses)
+}
+
+// NewImageUpdater returns an updater resolving image tags from their
+// registry.
+func NewImageUpdater(opts ...Option) Updater[*ImageRef] {
+	return container.NewUpdater(opts...)
+}
+
+// NewChartUpdater returns an updater resolving chart versions from their
+// repository index.
+func NewChartUpdater() Updater[*ChartRef] {
+	return helm.NewUpdater()
+}
+
+// NewGitUpdater returns an updater resolving the tags of Git repositories.
+func NewGitUpdater() Updater[*RepoRef] {
+	return git.NewUpdater()
+}
+
+// NewActionUpdater returns an updater resolving GitHub Action tags through
+// the GitHub API, authenticated with token when it is not empty.
+func NewActionUpdater(ctx context.Context, token string) Updater[*ActionRef] {
+	return github.NewUpdater(github.NewTokenClient(ctx, token))
+}
+
+// WithSharedLookups returns a context under which the updaters fetch the
+// versions of each dependency once, however many references to it are
+// updated, concurrently or not. Use a new one for each run, as the versions
+// are never fetched again.
+func WithSharedLookups(ctx context.Context) context.Context {
+	return mirror.WithMemo(ctx, mirror.NewMemo())
+}
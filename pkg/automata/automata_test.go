@@ -0,0 +1,85 @@
+package automata_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/shikanime-studio/automata/pkg/automata"
+	"github.com/shikanime-studio/automata/pkg/automata/automatatest"
+)
+
+type fixedUpdater string
+
+func (f fixedUpdater) Update(context.Context, *automata.ImageRef, ...automata.Option) (string, error) {
+	return string(f), nil
+}
+
+func TestUpdateKustomizations(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kustomization.yaml")
+	in := `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+metadata:
+  annotations:
+    automata.shikanime.studio/images: '[{"name":"nginx"}]'
+images:
+  - name: nginx
+    newTag: 1.25.0
+`
+	if err := os.WriteFile(path, []byte(in), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := automata.UpdateKustomizations(context.Background(), fixedUpdater("1.27.0"), dir)
+	if err := p.Execute(); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "newTag: 1.27.0"; !strings.Contains(string(out), want) {
+		t.Errorf("kustomization = %s, want %q", out, want)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	got, err := automata.Compare("v1.2.3", "v1.3.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != automata.Greater {
+		t.Errorf("Compare = %v, want Greater", got)
+	}
+}
+
+func TestImageUpdaterOptions(t *testing.T) {
+	r := automatatest.NewRegistry(map[string][]string{
+		"acme/app": {"1.0.0", "1.0.1", "1.1.0", "1.2.0-rc.1", "2.0.0"},
+	})
+	defer r.Close()
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		opts []automata.Option
+		want string
+	}{
+		{"strategy", []automata.Option{automata.WithStrategy(automata.MinorUpdate)}, "1.1.0"},
+		{"excludes", []automata.Option{automata.WithStrategy(automata.MinorUpdate), automata.WithExcludes("1.1.0")}, "1.0.1"},
+		{"prereleases", []automata.Option{automata.WithStrategy(automata.MinorUpdate), automata.WithPrereleases(true)}, "1.2.0-rc.1"},
+		{"transform", []automata.Option{automata.WithTransform(regexp.MustCompile(`^(?P<major>\d+)\.(?P<minor>\d+)\.(?P<patch>\d+)$`))}, "2.0.0"},
+	}
+	for _, tt := range tests {
+		image := automata.ImageRef{Name: r.Image("acme/app"), Tag: "1.0.0"}
+		got, err := automata.NewImageUpdater().Update(ctx, &image, tt.opts...)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Package automatatest provides version sources for testing the updaters and
+// pipelines of package automata offline, such as the configs of programs
+// embedding them: recorded tag lists of popular images, charts, GitHub
+// repositories and git remotes, and a fake container registry.
+package automatatest
+
+import (
+	"context"
+
+	"github.com/shikanime-studio/automata/internal/mirror"
+	"github.com/shikanime-studio/automata/internal/testsource"
+)
+
+// Snapshot holds the candidate versions of dependencies by kind and name.
+type Snapshot = mirror.Snapshot
+
+// Candidate is a version available for a dependency.
+type Candidate = mirror.Candidate
+
+// Kinds of sources in a snapshot.
+const (
+	// Images are container images, by name.
+	Images = mirror.Images
+	// Charts are Helm charts, by repository URL and chart name.
+	Charts = mirror.Charts
+	// Actions are GitHub repositories, by owner/repo.
+	Actions = mirror.Actions
+	// Git are remote git repositories, by URL.
+	Git = mirror.Git
+)
+
+// Fixtures returns a new snapshot of the recorded candidates, to which tests
+// may add their own.
+func Fixtures() *Snapshot {
+	return testsource.Fixtures()
+}
+
+// WithSnapshot returns a context serving the lookups of the updaters from
+// the snapshot instead of the network, failing on dependencies it does not
+// hold.
+func WithSnapshot(ctx context.Context, s *Snapshot) context.Context {
+	return mirror.WithOffline(ctx, s)
+}
+
+// WithFixtures returns a context serving the lookups of the updaters from the
+// recorded candidates.
+func WithFixtures(ctx context.Context) context.Context {
+	return testsource.WithFixtures(ctx)
+}
+
+// Registry is a fake container registry served over HTTP on the loopback
+// interface.
+type Registry = testsource.Registry
+
+// NewRegistry starts a registry holding the given tags by repository. Close
+// it when done.
+func NewRegistry(tags map[string][]string) *Registry {
+	return testsource.NewRegistry(tags)
+}
@@ -0,0 +1,34 @@
+package automatatest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shikanime-studio/automata/pkg/automata"
+	"github.com/shikanime-studio/automata/pkg/automata/automatatest"
+)
+
+func TestWithSnapshot(t *testing.T) {
+	snap := automatatest.Fixtures()
+	snap.Add(automatatest.Images, "ghcr.io/acme/app", []automatatest.Candidate{
+		{Version: "1.0.0"}, {Version: "1.1.0"},
+	})
+	ctx := automatatest.WithSnapshot(context.Background(), snap)
+	images := automata.NewImageUpdater()
+	for ref, want := range map[string]string{
+		"ghcr.io/acme/app:1.0.0": "1.1.0",
+		"nginx:1.26.0":           "1.27.0",
+	} {
+		image, err := automata.ParseImageRef(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := images.Update(ctx, &image)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", ref, err)
+		}
+		if got != want {
+			t.Errorf("%s: got %s, want %s", ref, got, want)
+		}
+	}
+}
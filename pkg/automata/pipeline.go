@@ -0,0 +1,99 @@
+package automata
+
+import (
+	"context"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+
+	ikio "github.com/shikanime-studio/automata/internal/kio"
+)
+
+// Pipelines read the manifests of a format under path, update them with the
+// given updaters and write them back in place. Run them with Execute.
+
+// UpdateKustomizations returns the pipeline updating the images of the
+// kustomizations under path.
+func UpdateKustomizations(ctx context.Context, u Updater[*ImageRef], path string) kio.Pipeline {
+	return ikio.UpdateKustomization(ctx, u, path)
+}
+
+// UpdateGitHubWorkflows returns the pipeline updating the actions used by
+// the workflows of the repository at path.
+func UpdateGitHubWorkflows(ctx context.Context, u Updater[*ActionRef], path string) kio.Pipeline {
+	return ikio.UpdateGitHubWorkflows(ctx, u, nil, path)
+}
+
+// UpdateSkaffoldConfigs returns the pipeline updating the images and charts
+// of the Skaffold configs under path.
+func UpdateSkaffoldConfigs(
+	ctx context.Context,
+	cu Updater[*ImageRef],
+	hu Updater[*ChartRef],
+	path string,
+) kio.Pipeline {
+	return ikio.UpdateSkaffoldConfigs(ctx, cu, hu, path)
+}
+
+// UpdateK0sctlConfigs returns the pipeline updating the charts of the k0sctl
+// configs under path.
+func UpdateK0sctlConfigs(ctx context.Context, u Updater[*ChartRef], path string) kio.Pipeline {
+	return ikio.UpdateK0sctlConfigs(ctx, u, path)
+}
+
+// UpdateHelmCharts returns the pipeline updating the charts of the
+// kustomization helmCharts entries and Flux HelmReleases under path.
+func UpdateHelmCharts(ctx context.Context, u Updater[*ChartRef], path string) kio.Pipeline {
+	return ikio.UpdateHelmCharts(ctx, u, path)
+}
+
+// UpdateTalosConfigs returns the pipeline updating the Talos machine configs
+// and Omni cluster templates under path.
+func UpdateTalosConfigs(
+	ctx context.Context,
+	cu Updater[*ImageRef],
+	gu Updater[*ActionRef],
+	path string,
+) kio.Pipeline {
+	return ikio.UpdateTalosConfigs(ctx, cu, gu, path)
+}
+
+// UpdateDronePipelines returns the pipeline updating the images of the Drone
+// pipelines under path.
+func UpdateDronePipelines(ctx context.Context, u Updater[*ImageRef], path string) kio.Pipeline {
+	return ikio.UpdateDronePipelines(ctx, u, path)
+}
+
+// UpdateDevContainers returns the pipeline updating the images of the dev
+// containers under path.
+func UpdateDevContainers(ctx context.Context, u Updater[*ImageRef], path string) kio.Pipeline {
+	return ikio.UpdateDevContainers(ctx, u, path)
+}
+
+// UpdateTektonResources returns the pipeline updating the step images of the
+// Tekton resources under path.
+func UpdateTektonResources(ctx context.Context, u Updater[*ImageRef], path string) kio.Pipeline {
+	return ikio.UpdateTektonResources(ctx, u, path)
+}
+
+// UpdateCrossplanePackages returns the pipeline updating the package
+// references of the Crossplane packages under path.
+func UpdateCrossplanePackages(ctx context.Context, u Updater[*ImageRef], path string) kio.Pipeline {
+	return ikio.UpdateCrossplanePackages(ctx, u, path)
+}
+
+// UpdatePolicyBundles returns the pipeline updating the Kyverno and
+// Gatekeeper policy libraries referenced by the manifests under path.
+func UpdatePolicyBundles(
+	ctx context.Context,
+	cu Updater[*ImageRef],
+	gu Updater[*ActionRef],
+	path string,
+) kio.Pipeline {
+	return ikio.UpdatePolicyBundles(ctx, cu, gu, path)
+}
+
+// UpdateOPAManifests returns the pipeline updating the OPA runtime images of
+// the workloads under path.
+func UpdateOPAManifests(ctx context.Context, u Updater[*ImageRef], path string) kio.Pipeline {
+	return ikio.UpdateOPAManifests(ctx, nil, u, path)
+}